@@ -4,11 +4,21 @@ import (
     "context"
     "os"
     "os/signal"
+    "regexp"
     "strconv"
+    "strings"
     "syscall"
     "time"
 
+    "github.com/shopspring/decimal"
+    "github.com/walletera/dinopay-gateway/internal/adapters/auth"
+    "github.com/walletera/dinopay-gateway/internal/adapters/dinopay"
     "github.com/walletera/dinopay-gateway/internal/app"
+    "github.com/walletera/dinopay-gateway/internal/domain/events/walletera/gateway/inbound"
+    "github.com/walletera/dinopay-gateway/internal/domain/events/walletera/gateway/outbound"
+    riskport "github.com/walletera/dinopay-gateway/internal/domain/ports/output/risk"
+    "github.com/walletera/dinopay-gateway/internal/domain/risk"
+    "github.com/walletera/dinopay-gateway/internal/domain/velocity"
 )
 
 const shutdownTimeout = 10 * time.Second
@@ -23,17 +33,70 @@ func main() {
     rabbitmqPassword := mustGetEnv("RABBITMQ_PASSWORD")
     dinopayURL := mustGetEnv("DINOPAY_URL")
     paymentsURL := mustGetEnv("PAYMENTS_URL")
-    eventstoredbURL := mustGetEnv("EVENTSTOREDB_URL")
+    dinopayCredentials := dinopay.Credentials{
+        APIKey:       getEnv("DINOPAY_API_KEY", ""),
+        APIKeyHeader: getEnv("DINOPAY_API_KEY_HEADER", ""),
+        ClientId:     getEnv("DINOPAY_OAUTH2_CLIENT_ID", ""),
+        ClientSecret: getEnv("DINOPAY_OAUTH2_CLIENT_SECRET", ""),
+        TokenUrl:     getEnv("DINOPAY_OAUTH2_TOKEN_URL", ""),
+        Scopes:       strings.Fields(getEnv("DINOPAY_OAUTH2_SCOPES", "")),
+    }
 
-    app, err := app.NewApp(
+    appOpts := []app.Option{
         app.WithRabbitmqHost(rabbitmqHost),
         app.WithRabbitmqPort(rabbitmqPort),
         app.WithRabbitmqUser(rabbitmqUser),
         app.WithRabbitmqPassword(rabbitmqPassword),
         app.WithDinopayUrl(dinopayURL),
+        app.WithDinopayCredentials(dinopayCredentials),
+        app.WithDinopayCredentialsFile(getEnv("DINOPAY_CREDENTIALS_FILE", "")),
+        app.WithDinopayOwnAccounts(strings.Fields(getEnv("DINOPAY_OWN_ACCOUNTS", ""))...),
+        app.WithDinopayPolling(getDurationEnv("DINOPAY_POLL_INTERVAL", "0")),
         app.WithPaymentsUrl(paymentsURL),
-        app.WithESDBUrl(eventstoredbURL),
-    )
+        app.WithAccountsCache(
+            getDurationEnv("ACCOUNTS_CACHE_TTL", "5m"),
+            getDurationEnv("ACCOUNTS_CACHE_NEGATIVE_TTL", "30s"),
+            getDurationEnv("ACCOUNTS_CACHE_MAX_STALE", "1h"),
+        ),
+        app.WithUnknownOutcomeResolver(
+            getDurationEnv("UNKNOWN_OUTCOME_RESOLVE_INTERVAL", "30s"),
+            getDurationEnv("UNKNOWN_OUTCOME_RESOLVE_BACKOFF", "1m"),
+            getIntEnv("UNKNOWN_OUTCOME_MAX_ATTEMPTS", 5),
+        ),
+        app.WithPendingSweeper(
+            getDurationEnv("PENDING_SWEEP_INTERVAL", "5m"),
+            getDurationEnv("PENDING_SLA", "1h"),
+        ),
+        app.WithOperatorTokensFile(getEnv("OPERATOR_TOKENS_FILE", "")),
+        app.WithStatementsDir(
+            getEnv("STATEMENTS_DIR", ""),
+            getDurationEnv("STATEMENTS_SCAN_INTERVAL", "1m"),
+        ),
+        app.WithSanctionsList(
+            getEnv("SANCTIONS_LIST_FILE", ""),
+            getFloatEnv("SANCTIONS_MATCH_THRESHOLD", 0.9),
+        ),
+        app.WithIntegrationEventsExchange(getEnv("INTEGRATION_EVENTS_EXCHANGE", app.RabbitMQIntegrationEventsExchangeName)),
+        app.WithIdempotencyRetention(getDurationEnv("IDEMPOTENCY_RETENTION", "168h")),
+        eventStoreOpt(),
+    }
+    appOpts = append(appOpts, serviceAuthOpts()...)
+    if natsURL, found := os.LookupEnv("NATS_URL"); found {
+        appOpts = append(appOpts, app.WithPaymentsEventsFromNATS(
+            natsURL,
+            getEnv("NATS_PAYMENTS_STREAM", "PAYMENTS"),
+            getEnv("NATS_PAYMENTS_DEAD_LETTER_SUBJECT", ""),
+        ))
+    }
+    appOpts = append(appOpts, app.WithInboundReturnRules(inboundReturnRules()...))
+    appOpts = append(appOpts, app.WithOutboundValidationRules(outboundValidationRules()...))
+    appOpts = append(appOpts, app.WithOutboundVelocityLimits(outboundVelocityLimits()...))
+    appOpts = append(appOpts, app.WithOutboundApprovalThresholds(outboundApprovalThresholds()))
+    if rules := riskRules(); len(rules) > 0 {
+        appOpts = append(appOpts, app.WithRiskScorer(rules))
+    }
+
+    app, err := app.NewApp(appOpts...)
     if err != nil {
         panic(err)
     }
@@ -51,6 +114,190 @@ func main() {
     app.Stop(shutdownCtx)
 }
 
+// eventStoreOpt selects PostgreSQL as the event store when POSTGRES_URL is set, EventStoreDB otherwise
+func eventStoreOpt() app.Option {
+    if postgresURL, found := os.LookupEnv("POSTGRES_URL"); found {
+        return app.WithPostgresEventStore(postgresURL)
+    }
+    return app.WithESDBUrl(mustGetEnv("EVENTSTOREDB_URL"))
+}
+
+func serviceAuthOpts() []app.Option {
+    roles := strings.Fields(getEnv("SERVICE_AUTH_ROLES", ""))
+    if privateKeyFile, found := os.LookupEnv("SERVICE_AUTH_JWT_PRIVATE_KEY_FILE"); found {
+        ttl := getDurationEnv("SERVICE_AUTH_JWT_TTL", "15m")
+        return []app.Option{app.WithServiceJWT(auth.JWTConfig{
+            PrivateKeyFile: privateKeyFile,
+            KeyId:          getEnv("SERVICE_AUTH_JWT_KEY_ID", ""),
+            Issuer:         getEnv("SERVICE_AUTH_JWT_ISSUER", "dinopay-gateway"),
+            Subject:        getEnv("SERVICE_AUTH_JWT_SUBJECT", "dinopay-gateway"),
+            Audience:       strings.Fields(getEnv("SERVICE_AUTH_JWT_AUDIENCE", "")),
+            Roles:          roles,
+            TTL:            ttl,
+        })}
+    }
+    if tokenFile, found := os.LookupEnv("SERVICE_AUTH_TOKEN_FILE"); found {
+        return []app.Option{app.WithServiceTokenFile(tokenFile, roles...)}
+    }
+    // the app fails starting without a token provider
+    return nil
+}
+
+func inboundReturnRules() []inbound.ReturnRule {
+    var rules []inbound.ReturnRule
+    if currencies := strings.Fields(getEnv("INBOUND_SUPPORTED_CURRENCIES", "")); len(currencies) > 0 {
+        rules = append(rules, inbound.NewSupportedCurrenciesRule(currencies...))
+    }
+    if accountNumbers := strings.Fields(getEnv("INBOUND_CLOSED_ACCOUNTS", "")); len(accountNumbers) > 0 {
+        rules = append(rules, inbound.NewClosedAccountsRule(accountNumbers...))
+    }
+    if accountNumbers := strings.Fields(getEnv("INBOUND_BLOCKED_SENDERS", "")); len(accountNumbers) > 0 {
+        rules = append(rules, inbound.NewBlockedSendersRule(accountNumbers...))
+    }
+    return rules
+}
+
+// outboundValidationRules reads the per currency rules, e.g.
+// OUTBOUND_AMOUNT_LIMITS="USD=1:10000 ARS=:5000000" (an empty limit is unbounded) and
+// OUTBOUND_ACCOUNT_NUMBER_PATTERNS="USD=^[0-9]{10}$ ARS=^[0-9]{22}$"
+func outboundValidationRules() []outbound.ValidationRule {
+    var rules []outbound.ValidationRule
+    if currencies := strings.Fields(getEnv("OUTBOUND_SUPPORTED_CURRENCIES", "")); len(currencies) > 0 {
+        rules = append(rules, outbound.NewCurrencyAllowlistRule(currencies...))
+    }
+    for currency, limits := range perCurrencyEnv("OUTBOUND_AMOUNT_LIMITS") {
+        minLimit, maxLimit, found := strings.Cut(limits, ":")
+        if !found {
+            panic("env var OUTBOUND_AMOUNT_LIMITS has no min:max limits for " + currency)
+        }
+        rules = append(rules, outbound.NewAmountLimitsRule(currency, amountLimit("OUTBOUND_AMOUNT_LIMITS", minLimit), amountLimit("OUTBOUND_AMOUNT_LIMITS", maxLimit)))
+    }
+    for currency, pattern := range perCurrencyEnv("OUTBOUND_ACCOUNT_NUMBER_PATTERNS") {
+        compiledPattern, err := regexp.Compile(pattern)
+        if err != nil {
+            panic("env var OUTBOUND_ACCOUNT_NUMBER_PATTERNS has an invalid pattern for " + currency)
+        }
+        rules = append(rules, outbound.NewAccountNumberPatternRule(currency, compiledPattern))
+    }
+    if getBoolEnv("OUTBOUND_REJECT_SAME_ACCOUNT", true) {
+        rules = append(rules, outbound.NewDistinctAccountsRule())
+    }
+    return rules
+}
+
+// outboundVelocityLimits reads the per currency limits of every customer and
+// beneficiary as window:maxCount:maxAmount, e.g.
+// OUTBOUND_CUSTOMER_VELOCITY_LIMITS="USD=24h:10:5000 ARS=1h::1000000" (an empty max is unbounded)
+func outboundVelocityLimits() []velocity.Limit {
+    var limits []velocity.Limit
+    scopes := []struct {
+        scope   velocity.Scope
+        envName string
+    }{
+        {velocity.ScopeCustomer, "OUTBOUND_CUSTOMER_VELOCITY_LIMITS"},
+        {velocity.ScopeBeneficiary, "OUTBOUND_BENEFICIARY_VELOCITY_LIMITS"},
+    }
+    for _, scope := range scopes {
+        for currency, limit := range perCurrencyEnv(scope.envName) {
+            parts := strings.Split(limit, ":")
+            if len(parts) != 3 {
+                panic("env var " + scope.envName + " has no window:maxCount:maxAmount limit for " + currency)
+            }
+            window, err := time.ParseDuration(parts[0])
+            if err != nil {
+                panic("env var " + scope.envName + " has an invalid window for " + currency)
+            }
+            maxCount := 0
+            if parts[1] != "" {
+                maxCount, err = strconv.Atoi(parts[1])
+                if err != nil {
+                    panic("env var " + scope.envName + " has an invalid max count for " + currency)
+                }
+            }
+            limits = append(limits, velocity.Limit{
+                Scope:     scope.scope,
+                Currency:  currency,
+                Window:    window,
+                MaxCount:  maxCount,
+                MaxAmount: amountLimit(scope.envName, parts[2]),
+            })
+        }
+    }
+    return limits
+}
+
+// outboundApprovalThresholds reads the amounts per currency above which
+// payouts must be approved, e.g. OUTBOUND_APPROVAL_THRESHOLDS="USD=10000 ARS=5000000"
+func outboundApprovalThresholds() outbound.ApprovalThresholds {
+    thresholds := make(outbound.ApprovalThresholds)
+    for currency, threshold := range perCurrencyEnv("OUTBOUND_APPROVAL_THRESHOLDS") {
+        amount := amountLimit("OUTBOUND_APPROVAL_THRESHOLDS", threshold)
+        if amount == nil {
+            panic("env var OUTBOUND_APPROVAL_THRESHOLDS has no threshold for " + currency)
+        }
+        thresholds[currency] = *amount
+    }
+    return thresholds
+}
+
+// riskRules reads the rules of the local risk scoring, the payments they apply to are held for review.
+// RISK_NEW_BENEFICIARY_PAYOUT_AMOUNTS="USD=1000" reviews the payouts of at least 1000 USD to a new beneficiary.
+// RISK_SMALL_DEPOSITS="USD=24h:5:100" reviews the 5th deposit of at most 100 USD from the same sender within 24h.
+func riskRules() risk.Rules {
+    var rules risk.Rules
+    for currency, amount := range perCurrencyEnv("RISK_NEW_BENEFICIARY_PAYOUT_AMOUNTS") {
+        minAmount := amountLimit("RISK_NEW_BENEFICIARY_PAYOUT_AMOUNTS", amount)
+        if minAmount == nil {
+            panic("env var RISK_NEW_BENEFICIARY_PAYOUT_AMOUNTS has no amount for " + currency)
+        }
+        rules = append(rules, risk.NewLargePayoutToNewBeneficiaryRule(currency, *minAmount, riskport.OutcomeReview))
+    }
+    for currency, rule := range perCurrencyEnv("RISK_SMALL_DEPOSITS") {
+        parts := strings.Split(rule, ":")
+        if len(parts) != 3 {
+            panic("env var RISK_SMALL_DEPOSITS has no window:count:maxAmount rule for " + currency)
+        }
+        window, err := time.ParseDuration(parts[0])
+        if err != nil {
+            panic("env var RISK_SMALL_DEPOSITS has an invalid window for " + currency)
+        }
+        count, err := strconv.Atoi(parts[1])
+        if err != nil {
+            panic("env var RISK_SMALL_DEPOSITS has an invalid count for " + currency)
+        }
+        maxAmount := amountLimit("RISK_SMALL_DEPOSITS", parts[2])
+        if maxAmount == nil {
+            panic("env var RISK_SMALL_DEPOSITS has no max amount for " + currency)
+        }
+        rules = append(rules, risk.NewSmallDepositsFromSourceRule(currency, *maxAmount, count, window, riskport.OutcomeReview))
+    }
+    return rules
+}
+
+// perCurrencyEnv parses an env var made of space separated CURRENCY=value entries
+func perCurrencyEnv(envName string) map[string]string {
+    values := make(map[string]string)
+    for _, entry := range strings.Fields(getEnv(envName, "")) {
+        currency, value, found := strings.Cut(entry, "=")
+        if !found {
+            panic("env var " + envName + " entries must be CURRENCY=value")
+        }
+        values[currency] = value
+    }
+    return values
+}
+
+func amountLimit(envName string, limit string) *decimal.Decimal {
+    if limit == "" {
+        return nil
+    }
+    amount, err := decimal.NewFromString(limit)
+    if err != nil {
+        panic("env var " + envName + " has an invalid amount " + limit)
+    }
+    return &amount
+}
+
 func mustGetEnv(envName string) string {
     value, found := os.LookupEnv(envName)
     if !found {
@@ -59,6 +306,58 @@ func mustGetEnv(envName string) string {
     return value
 }
 
+func getEnv(envName string, defaultValue string) string {
+    value, found := os.LookupEnv(envName)
+    if !found {
+        return defaultValue
+    }
+    return value
+}
+
+func getDurationEnv(envName string, defaultValue string) time.Duration {
+    duration, err := time.ParseDuration(getEnv(envName, defaultValue))
+    if err != nil {
+        panic("env var is not a duration: " + envName)
+    }
+    return duration
+}
+
+func getIntEnv(envName string, defaultValue int) int {
+    strEnvValue, found := os.LookupEnv(envName)
+    if !found {
+        return defaultValue
+    }
+    intEnvValue, err := strconv.Atoi(strEnvValue)
+    if err != nil {
+        panic("env var is not an int: " + envName)
+    }
+    return intEnvValue
+}
+
+func getFloatEnv(envName string, defaultValue float64) float64 {
+    strEnvValue, found := os.LookupEnv(envName)
+    if !found {
+        return defaultValue
+    }
+    floatEnvValue, err := strconv.ParseFloat(strEnvValue, 64)
+    if err != nil {
+        panic("env var is not a float: " + envName)
+    }
+    return floatEnvValue
+}
+
+func getBoolEnv(envName string, defaultValue bool) bool {
+    strEnvValue, found := os.LookupEnv(envName)
+    if !found {
+        return defaultValue
+    }
+    boolEnvValue, err := strconv.ParseBool(strEnvValue)
+    if err != nil {
+        panic("env var is not a bool: " + envName)
+    }
+    return boolEnvValue
+}
+
 func mustGetIntEnv(envName string) int {
     strEnvValue := mustGetEnv(envName)
     intEnvValue, err := strconv.Atoi(strEnvValue)
@@ -0,0 +1,312 @@
+package accounts
+
+import (
+    "context"
+    "sync"
+    "sync/atomic"
+    "time"
+
+    accountsapi "github.com/walletera/accounts/publicapi"
+    "github.com/walletera/dinopay-gateway/internal/domain/ports/output/accounts"
+    "go.opentelemetry.io/otel"
+    "go.opentelemetry.io/otel/metric"
+)
+
+const (
+    DefaultTTL         = 5 * time.Minute
+    DefaultNegativeTTL = 30 * time.Second
+    DefaultMaxStale    = 1 * time.Hour
+    DefaultMaxEntries  = 10000
+
+    metricNamePrefix = "dinopay_gateway.accounts_cache."
+)
+
+var (
+    _ accounts.Client    = (*CachedClient)(nil)
+    _ accounts.Refresher = (*CachedClient)(nil)
+)
+
+type entry struct {
+    accounts  accountsapi.ListAccountsOKApplicationJSON
+    fetchedAt time.Time
+}
+
+// Stats are the counters of a CachedClient since it was created
+type Stats struct {
+    Hits         uint64  `json:"hits"`
+    NegativeHits uint64  `json:"negativeHits"`
+    Misses       uint64  `json:"misses"`
+    StaleServed  uint64  `json:"staleServed"`
+    Errors       uint64  `json:"errors"`
+    Refreshes    uint64  `json:"refreshes"`
+    Entries      int     `json:"entries"`
+    HitRate      float64 `json:"hitRate"`
+}
+
+// CachedClient caches the accounts found by DinopayAccountNumber
+// so that the Accounts service is not on the critical path of every
+// DinoPay webhook. Empty results are cached for a shorter time (negative
+// caching), and when the Accounts API fails the last known result is
+// served as long as it's not older than the max stale period.
+// The counters are exported as metrics too.
+type CachedClient struct {
+    client      accounts.Client
+    ttl         time.Duration
+    negativeTTL time.Duration
+    maxStale    time.Duration
+    maxEntries  int
+    now         func() time.Time
+
+    mu      sync.Mutex
+    entries map[string]entry
+
+    hits         atomic.Uint64
+    negativeHits atomic.Uint64
+    misses       atomic.Uint64
+    staleServed  atomic.Uint64
+    errors       atomic.Uint64
+    refreshes    atomic.Uint64
+    metrics      cacheMetrics
+}
+
+// cacheMetrics are the counters of the Stats exported as metrics
+type cacheMetrics struct {
+    hits         metric.Int64Counter
+    negativeHits metric.Int64Counter
+    misses       metric.Int64Counter
+    staleServed  metric.Int64Counter
+    errors       metric.Int64Counter
+    refreshes    metric.Int64Counter
+}
+
+type Opt func(c *CachedClient)
+
+func WithTTL(ttl time.Duration) Opt {
+    return func(c *CachedClient) { c.ttl = ttl }
+}
+
+func WithNegativeTTL(ttl time.Duration) Opt {
+    return func(c *CachedClient) { c.negativeTTL = ttl }
+}
+
+// WithMaxStale sets for how long a cached result can still be served
+// after it expired when the Accounts API is failing. Zero disables it.
+func WithMaxStale(maxStale time.Duration) Opt {
+    return func(c *CachedClient) { c.maxStale = maxStale }
+}
+
+func WithMaxEntries(maxEntries int) Opt {
+    return func(c *CachedClient) { c.maxEntries = maxEntries }
+}
+
+// WithMeter replaces the otel global meter
+func WithMeter(meter metric.Meter) Opt {
+    return func(c *CachedClient) { c.metrics = newCacheMetrics(meter) }
+}
+
+// WithClock replaces time.Now, mostly for tests
+func WithClock(now func() time.Time) Opt {
+    return func(c *CachedClient) { c.now = now }
+}
+
+func NewCachedClient(client accounts.Client, opts ...Opt) *CachedClient {
+    c := &CachedClient{
+        client:      client,
+        ttl:         DefaultTTL,
+        negativeTTL: DefaultNegativeTTL,
+        maxStale:    DefaultMaxStale,
+        maxEntries:  DefaultMaxEntries,
+        now:         time.Now,
+        entries:     make(map[string]entry),
+        metrics:     newCacheMetrics(otel.Meter("github.com/walletera/dinopay-gateway")),
+    }
+    for _, opt := range opts {
+        opt(c)
+    }
+    return c
+}
+
+func (c *CachedClient) ListAccounts(ctx context.Context, params accountsapi.ListAccountsParams) (accountsapi.ListAccountsRes, error) {
+    accountNumber, cacheable := cacheKey(params)
+    if !cacheable {
+        return c.client.ListAccounts(ctx, params)
+    }
+
+    cached, found := c.get(accountNumber)
+    if found && c.fresh(cached) {
+        if len(cached.accounts) == 0 {
+            c.count(ctx, &c.negativeHits, c.metrics.negativeHits)
+        } else {
+            c.count(ctx, &c.hits, c.metrics.hits)
+        }
+        return copyOf(cached.accounts), nil
+    }
+    c.count(ctx, &c.misses, c.metrics.misses)
+
+    resp, err := c.client.ListAccounts(ctx, params)
+    if err == nil {
+        if accountList, ok := resp.(*accountsapi.ListAccountsOKApplicationJSON); ok {
+            c.set(accountNumber, *accountList)
+            return resp, nil
+        }
+        if _, ok := resp.(*accountsapi.ApiError); !ok {
+            return resp, nil
+        }
+    }
+
+    c.count(ctx, &c.errors, c.metrics.errors)
+    if found && c.now().Sub(cached.fetchedAt) <= c.ttlOf(cached)+c.maxStale {
+        c.count(ctx, &c.staleServed, c.metrics.staleServed)
+        return copyOf(cached.accounts), nil
+    }
+    return resp, err
+}
+
+// RefreshAccounts asks the Accounts API without looking at the cache and caches the
+// result. No stale result is served, a failure is returned to be retried instead.
+func (c *CachedClient) RefreshAccounts(ctx context.Context, params accountsapi.ListAccountsParams) (accountsapi.ListAccountsRes, error) {
+    accountNumber, cacheable := cacheKey(params)
+    if !cacheable {
+        return c.client.ListAccounts(ctx, params)
+    }
+    c.count(ctx, &c.refreshes, c.metrics.refreshes)
+    resp, err := c.client.ListAccounts(ctx, params)
+    if err != nil {
+        c.count(ctx, &c.errors, c.metrics.errors)
+        return resp, err
+    }
+    switch accountList := resp.(type) {
+    case *accountsapi.ListAccountsOKApplicationJSON:
+        c.set(accountNumber, *accountList)
+    case *accountsapi.ApiError:
+        c.count(ctx, &c.errors, c.metrics.errors)
+    }
+    return resp, nil
+}
+
+// Invalidate removes the cached accounts of the given DinoPay account number.
+// The operators call it when an account is created, updated or closed. The deposits
+// don't depend on it, a cached "not found" is refreshed before going to suspense.
+func (c *CachedClient) Invalidate(accountNumber string) {
+    c.mu.Lock()
+    defer c.mu.Unlock()
+    delete(c.entries, accountNumber)
+}
+
+func (c *CachedClient) InvalidateAll() {
+    c.mu.Lock()
+    defer c.mu.Unlock()
+    c.entries = make(map[string]entry)
+}
+
+func (c *CachedClient) Stats() Stats {
+    c.mu.Lock()
+    entries := len(c.entries)
+    c.mu.Unlock()
+    stats := Stats{
+        Hits:         c.hits.Load(),
+        NegativeHits: c.negativeHits.Load(),
+        Misses:       c.misses.Load(),
+        StaleServed:  c.staleServed.Load(),
+        Errors:       c.errors.Load(),
+        Refreshes:    c.refreshes.Load(),
+        Entries:      entries,
+    }
+    lookups := stats.Hits + stats.NegativeHits + stats.Misses
+    if lookups > 0 {
+        stats.HitRate = float64(stats.Hits+stats.NegativeHits) / float64(lookups)
+    }
+    return stats
+}
+
+func (c *CachedClient) count(ctx context.Context, value *atomic.Uint64, counter metric.Int64Counter) {
+    value.Add(1)
+    counter.Add(ctx, 1)
+}
+
+func (c *CachedClient) get(accountNumber string) (entry, bool) {
+    c.mu.Lock()
+    defer c.mu.Unlock()
+    cached, found := c.entries[accountNumber]
+    return cached, found
+}
+
+func (c *CachedClient) set(accountNumber string, accountList accountsapi.ListAccountsOKApplicationJSON) {
+    c.mu.Lock()
+    defer c.mu.Unlock()
+    if _, found := c.entries[accountNumber]; !found && len(c.entries) >= c.maxEntries {
+        c.evict()
+    }
+    c.entries[accountNumber] = entry{
+        accounts:  *copyOf(accountList),
+        fetchedAt: c.now(),
+    }
+}
+
+// evict removes the entries that can't be served anymore,
+// or the oldest one when all of them can still be served
+func (c *CachedClient) evict() {
+    var oldestKey string
+    var oldest time.Time
+    for key, cached := range c.entries {
+        if c.now().Sub(cached.fetchedAt) > c.ttlOf(cached)+c.maxStale {
+            delete(c.entries, key)
+            continue
+        }
+        if oldestKey == "" || cached.fetchedAt.Before(oldest) {
+            oldestKey, oldest = key, cached.fetchedAt
+        }
+    }
+    if len(c.entries) >= c.maxEntries {
+        delete(c.entries, oldestKey)
+    }
+}
+
+func (c *CachedClient) fresh(cached entry) bool {
+    return c.now().Sub(cached.fetchedAt) < c.ttlOf(cached)
+}
+
+func (c *CachedClient) ttlOf(cached entry) time.Duration {
+    if len(cached.accounts) == 0 {
+        return c.negativeTTL
+    }
+    return c.ttl
+}
+
+// cacheKey returns the DinoPay account number of the lookup.
+// Only the lookups by DinopayAccountNumber alone are cached.
+func cacheKey(params accountsapi.ListAccountsParams) (string, bool) {
+    accountNumber, ok := params.DinopayAccountNumber.Get()
+    if !ok {
+        return "", false
+    }
+    if params != (accountsapi.ListAccountsParams{DinopayAccountNumber: params.DinopayAccountNumber}) {
+        return "", false
+    }
+    return accountNumber, true
+}
+
+func copyOf(accountList accountsapi.ListAccountsOKApplicationJSON) *accountsapi.ListAccountsOKApplicationJSON {
+    accountListCopy := make(accountsapi.ListAccountsOKApplicationJSON, len(accountList))
+    copy(accountListCopy, accountList)
+    return &accountListCopy
+}
+
+func newCacheMetrics(meter metric.Meter) cacheMetrics {
+    return cacheMetrics{
+        hits:         newCounter(meter, "hits", "Accounts lookups served from the cache"),
+        negativeHits: newCounter(meter, "negative_hits", "Accounts lookups served from the cache without accounts"),
+        misses:       newCounter(meter, "misses", "Accounts lookups sent to the Accounts API"),
+        staleServed:  newCounter(meter, "stale_served", "Expired accounts lookups served while the Accounts API was failing"),
+        errors:       newCounter(meter, "errors", "Accounts lookups the Accounts API failed"),
+        refreshes:    newCounter(meter, "refreshes", "Accounts lookups sent to the Accounts API bypassing the cache"),
+    }
+}
+
+func newCounter(meter metric.Meter, name string, description string) metric.Int64Counter {
+    counter, err := meter.Int64Counter(metricNamePrefix+name, metric.WithDescription(description))
+    if err != nil {
+        otel.Handle(err)
+    }
+    return counter
+}
@@ -0,0 +1,137 @@
+package accounts
+
+import (
+    "context"
+    "errors"
+    "net/http"
+    "net/http/httptest"
+    "testing"
+    "time"
+
+    "github.com/google/uuid"
+    "github.com/stretchr/testify/assert"
+    "github.com/stretchr/testify/require"
+    accountsapi "github.com/walletera/accounts/publicapi"
+    "github.com/walletera/dinopay-gateway/internal/adapters/metrics"
+)
+
+type fakeAccountsClient struct {
+    calls int
+    resp  accountsapi.ListAccountsRes
+    err   error
+}
+
+func (f *fakeAccountsClient) ListAccounts(_ context.Context, _ accountsapi.ListAccountsParams) (accountsapi.ListAccountsRes, error) {
+    f.calls++
+    return f.resp, f.err
+}
+
+type fakeClock struct {
+    now time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+    return c.now
+}
+
+func byAccountNumber(accountNumber string) accountsapi.ListAccountsParams {
+    return accountsapi.ListAccountsParams{DinopayAccountNumber: accountsapi.NewOptString(accountNumber)}
+}
+
+func TestCachedClient_ServesFreshAndStaleResults(t *testing.T) {
+    customerId := uuid.New()
+    fake := &fakeAccountsClient{resp: &accountsapi.ListAccountsOKApplicationJSON{{CustomerId: customerId}}}
+    clock := &fakeClock{now: time.Now()}
+    client := NewCachedClient(fake, WithTTL(time.Minute), WithMaxStale(time.Hour), WithClock(clock.Now))
+
+    for i := 0; i < 2; i++ {
+        resp, err := client.ListAccounts(context.Background(), byAccountNumber("IE12BOFI90000112349876"))
+        require.NoError(t, err)
+        assert.Equal(t, customerId, (*resp.(*accountsapi.ListAccountsOKApplicationJSON))[0].CustomerId)
+    }
+    assert.Equal(t, 1, fake.calls)
+
+    clock.now = clock.now.Add(2 * time.Minute)
+    fake.resp, fake.err = nil, errors.New("connection refused")
+    resp, err := client.ListAccounts(context.Background(), byAccountNumber("IE12BOFI90000112349876"))
+    require.NoError(t, err)
+    assert.Equal(t, customerId, (*resp.(*accountsapi.ListAccountsOKApplicationJSON))[0].CustomerId)
+    assert.Equal(t, 2, fake.calls)
+
+    clock.now = clock.now.Add(2 * time.Hour)
+    _, err = client.ListAccounts(context.Background(), byAccountNumber("IE12BOFI90000112349876"))
+    assert.Error(t, err)
+
+    stats := client.Stats()
+    assert.Equal(t, uint64(1), stats.Hits)
+    assert.Equal(t, uint64(3), stats.Misses)
+    assert.Equal(t, uint64(1), stats.StaleServed)
+    assert.Equal(t, uint64(2), stats.Errors)
+}
+
+func TestCachedClient_NegativeCachingAndInvalidation(t *testing.T) {
+    fake := &fakeAccountsClient{resp: &accountsapi.ListAccountsOKApplicationJSON{}}
+    clock := &fakeClock{now: time.Now()}
+    client := NewCachedClient(fake, WithTTL(time.Hour), WithNegativeTTL(time.Minute), WithClock(clock.Now))
+
+    _, err := client.ListAccounts(context.Background(), byAccountNumber("IE12BOFI90000199999999"))
+    require.NoError(t, err)
+    _, err = client.ListAccounts(context.Background(), byAccountNumber("IE12BOFI90000199999999"))
+    require.NoError(t, err)
+    assert.Equal(t, 1, fake.calls)
+    assert.Equal(t, uint64(1), client.Stats().NegativeHits)
+
+    clock.now = clock.now.Add(2 * time.Minute)
+    _, err = client.ListAccounts(context.Background(), byAccountNumber("IE12BOFI90000199999999"))
+    require.NoError(t, err)
+    assert.Equal(t, 2, fake.calls)
+
+    client.Invalidate("IE12BOFI90000199999999")
+    _, err = client.ListAccounts(context.Background(), byAccountNumber("IE12BOFI90000199999999"))
+    require.NoError(t, err)
+    assert.Equal(t, 3, fake.calls)
+}
+
+func TestCachedClient_RefreshBypassesTheNegativeCache(t *testing.T) {
+    fake := &fakeAccountsClient{resp: &accountsapi.ListAccountsOKApplicationJSON{}}
+    client := NewCachedClient(fake, WithNegativeTTL(time.Minute))
+
+    _, err := client.ListAccounts(context.Background(), byAccountNumber("IE12BOFI90000199999999"))
+    require.NoError(t, err)
+
+    // the account is created right after the lookup
+    customerId := uuid.New()
+    fake.resp = &accountsapi.ListAccountsOKApplicationJSON{{CustomerId: customerId}}
+    resp, err := client.RefreshAccounts(context.Background(), byAccountNumber("IE12BOFI90000199999999"))
+    require.NoError(t, err)
+    assert.Equal(t, customerId, (*resp.(*accountsapi.ListAccountsOKApplicationJSON))[0].CustomerId)
+    assert.Equal(t, 2, fake.calls)
+
+    // the refreshed result is cached
+    resp, err = client.ListAccounts(context.Background(), byAccountNumber("IE12BOFI90000199999999"))
+    require.NoError(t, err)
+    assert.Equal(t, customerId, (*resp.(*accountsapi.ListAccountsOKApplicationJSON))[0].CustomerId)
+    assert.Equal(t, 2, fake.calls)
+    assert.Equal(t, uint64(1), client.Stats().Refreshes)
+
+    // a failed refresh is not served from the cache
+    fake.resp, fake.err = nil, errors.New("connection refused")
+    _, err = client.RefreshAccounts(context.Background(), byAccountNumber("IE12BOFI90000199999999"))
+    assert.Error(t, err)
+}
+
+func TestCachedClient_ExportsTheCountersAsMetrics(t *testing.T) {
+    registry := metrics.NewRegistry()
+    fake := &fakeAccountsClient{resp: &accountsapi.ListAccountsOKApplicationJSON{{CustomerId: uuid.New()}}}
+    client := NewCachedClient(fake, WithMeter(registry.Meter("test")))
+
+    for i := 0; i < 3; i++ {
+        _, err := client.ListAccounts(context.Background(), byAccountNumber("IE12BOFI90000112349876"))
+        require.NoError(t, err)
+    }
+
+    recorder := httptest.NewRecorder()
+    registry.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+    assert.Contains(t, recorder.Body.String(), "dinopay_gateway_accounts_cache_hits_total 2\n")
+    assert.Contains(t, recorder.Body.String(), "dinopay_gateway_accounts_cache_misses_total 1\n")
+}
@@ -0,0 +1,30 @@
+package auth
+
+import (
+    "context"
+
+    accountsapi "github.com/walletera/accounts/publicapi"
+)
+
+// AccountsSecuritySource provides the bearer token required
+// by the ogen generated Accounts api client.
+type AccountsSecuritySource struct {
+    tokenProvider TokenProvider
+}
+
+var _ accountsapi.SecuritySource = (*AccountsSecuritySource)(nil)
+
+func NewAccountsSecuritySource(tokenProvider TokenProvider) *AccountsSecuritySource {
+    return &AccountsSecuritySource{tokenProvider: tokenProvider}
+}
+
+func (a *AccountsSecuritySource) BearerAuth(ctx context.Context, _ accountsapi.OperationName) (accountsapi.BearerAuth, error) {
+    token, err := a.tokenProvider.Token(ctx)
+    if err != nil {
+        return accountsapi.BearerAuth{}, err
+    }
+    return accountsapi.BearerAuth{
+        Token: token,
+        Roles: a.tokenProvider.Roles(),
+    }, nil
+}
@@ -0,0 +1,71 @@
+package auth
+
+import (
+    "context"
+    "fmt"
+    "os"
+    "strings"
+    "sync"
+    "time"
+)
+
+// FileTokenProvider reads the token from a file, typically a projected
+// service account token or a secret mounted into the container.
+// The file is read again whenever its modification time changes.
+type FileTokenProvider struct {
+    path  string
+    roles []string
+
+    mutex   sync.Mutex
+    token   string
+    modTime time.Time
+}
+
+var _ TokenProvider = (*FileTokenProvider)(nil)
+
+func NewFileTokenProvider(path string, roles ...string) (*FileTokenProvider, error) {
+    provider := &FileTokenProvider{
+        path:  path,
+        roles: roles,
+    }
+    _, err := provider.Token(context.Background())
+    if err != nil {
+        return nil, err
+    }
+    return provider, nil
+}
+
+func (p *FileTokenProvider) Token(_ context.Context) (string, error) {
+    p.mutex.Lock()
+    defer p.mutex.Unlock()
+    fileInfo, err := os.Stat(p.path)
+    if err != nil {
+        return "", fmt.Errorf("failed reading token file %s: %w", p.path, err)
+    }
+    if p.token != "" && fileInfo.ModTime().Equal(p.modTime) {
+        return p.token, nil
+    }
+    rawToken, err := os.ReadFile(p.path)
+    if err != nil {
+        return "", fmt.Errorf("failed reading token file %s: %w", p.path, err)
+    }
+    token := strings.TrimSpace(string(rawToken))
+    if token == "" {
+        return "", fmt.Errorf("token file %s is empty", p.path)
+    }
+    p.token = token
+    p.modTime = fileInfo.ModTime()
+    return p.token, nil
+}
+
+func (p *FileTokenProvider) Roles() []string {
+    return p.roles
+}
+
+// Invalidate forces the file to be read again even if
+// its modification time didn't change (e.g. an atomic symlink swap).
+func (p *FileTokenProvider) Invalidate() {
+    p.mutex.Lock()
+    defer p.mutex.Unlock()
+    p.token = ""
+}
@@ -0,0 +1,65 @@
+package auth
+
+import (
+    "context"
+    "os"
+    "path/filepath"
+    "testing"
+    "time"
+
+    "github.com/stretchr/testify/require"
+)
+
+func TestFileTokenProvider_ReloadsTheRotatedToken(t *testing.T) {
+    tokenFile := filepath.Join(t.TempDir(), "token")
+    err := os.WriteFile(tokenFile, []byte("token-1\n"), 0600)
+    require.NoError(t, err)
+
+    provider, err := NewFileTokenProvider(tokenFile, "gateway")
+    require.NoError(t, err)
+    token, err := provider.Token(context.Background())
+    require.NoError(t, err)
+    require.Equal(t, "token-1", token)
+    require.Equal(t, []string{"gateway"}, provider.Roles())
+
+    err = os.WriteFile(tokenFile, []byte("token-2"), 0600)
+    require.NoError(t, err)
+    modTime := time.Now().Add(time.Minute)
+    err = os.Chtimes(tokenFile, modTime, modTime)
+    require.NoError(t, err)
+    token, err = provider.Token(context.Background())
+    require.NoError(t, err)
+    require.Equal(t, "token-2", token)
+}
+
+func TestFileTokenProvider_InvalidateReadsTheFileAgain(t *testing.T) {
+    tokenFile := filepath.Join(t.TempDir(), "token")
+    err := os.WriteFile(tokenFile, []byte("token-1"), 0600)
+    require.NoError(t, err)
+    provider, err := NewFileTokenProvider(tokenFile)
+    require.NoError(t, err)
+
+    // the token changes keeping the modification time
+    fileInfo, err := os.Stat(tokenFile)
+    require.NoError(t, err)
+    err = os.WriteFile(tokenFile, []byte("token-2"), 0600)
+    require.NoError(t, err)
+    err = os.Chtimes(tokenFile, fileInfo.ModTime(), fileInfo.ModTime())
+    require.NoError(t, err)
+    token, err := provider.Token(context.Background())
+    require.NoError(t, err)
+    require.Equal(t, "token-1", token)
+
+    provider.Invalidate()
+    token, err = provider.Token(context.Background())
+    require.NoError(t, err)
+    require.Equal(t, "token-2", token)
+}
+
+func TestNewFileTokenProvider_FailsOnEmptyFile(t *testing.T) {
+    tokenFile := filepath.Join(t.TempDir(), "token")
+    err := os.WriteFile(tokenFile, []byte("\n"), 0600)
+    require.NoError(t, err)
+    _, err = NewFileTokenProvider(tokenFile)
+    require.Error(t, err)
+}
@@ -0,0 +1,77 @@
+package auth
+
+import (
+    "fmt"
+    "net/http"
+)
+
+type httpDoer interface {
+    Do(req *http.Request) (*http.Response, error)
+}
+
+// HttpClient sets the bearer token on every request. After a 401
+// response the token is invalidated and the request is retried once.
+// It can be passed to the ogen generated clients through their WithClient option.
+// The token set by the security source of a client is kept, the header is only
+// replaced when the request is retried.
+type HttpClient struct {
+    client        httpDoer
+    tokenProvider TokenProvider
+}
+
+func NewHttpClient(client httpDoer, tokenProvider TokenProvider) *HttpClient {
+    return &HttpClient{
+        client:        client,
+        tokenProvider: tokenProvider,
+    }
+}
+
+func (c *HttpClient) Do(req *http.Request) (*http.Response, error) {
+    if req.Header.Get("Authorization") == "" {
+        err := c.authenticate(req)
+        if err != nil {
+            return nil, err
+        }
+    }
+    resp, err := c.client.Do(req)
+    if err != nil || resp.StatusCode != http.StatusUnauthorized {
+        return resp, err
+    }
+    resp.Body.Close()
+    c.tokenProvider.Invalidate()
+    retryReq, err := CloneRequest(req)
+    if err != nil {
+        return nil, err
+    }
+    err = c.authenticate(retryReq)
+    if err != nil {
+        return nil, err
+    }
+    return c.client.Do(retryReq)
+}
+
+func (c *HttpClient) authenticate(req *http.Request) error {
+    token, err := c.tokenProvider.Token(req.Context())
+    if err != nil {
+        return fmt.Errorf("failed getting service token: %w", err)
+    }
+    req.Header.Set("Authorization", "Bearer "+token)
+    return nil
+}
+
+// CloneRequest returns a copy of req that can be sent again, with a fresh body
+func CloneRequest(req *http.Request) (*http.Request, error) {
+    clonedReq := req.Clone(req.Context())
+    if req.Body == nil || req.Body == http.NoBody {
+        return clonedReq, nil
+    }
+    if req.GetBody == nil {
+        return nil, fmt.Errorf("request body can't be replayed after a 401 response")
+    }
+    body, err := req.GetBody()
+    if err != nil {
+        return nil, fmt.Errorf("failed replaying request body: %w", err)
+    }
+    clonedReq.Body = body
+    return clonedReq, nil
+}
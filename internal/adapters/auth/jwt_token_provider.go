@@ -0,0 +1,215 @@
+package auth
+
+import (
+    "context"
+    "crypto"
+    "crypto/ecdsa"
+    "crypto/rand"
+    "crypto/rsa"
+    "crypto/sha256"
+    "crypto/x509"
+    "encoding/base64"
+    "encoding/json"
+    "encoding/pem"
+    "fmt"
+    "os"
+    "sync"
+    "time"
+
+    "github.com/google/uuid"
+)
+
+const (
+    defaultJWTTTL = 15 * time.Minute
+    // jwtRefreshLeeway is how long before its expiration a token is re-signed
+    jwtRefreshLeeway = 1 * time.Minute
+)
+
+type JWTConfig struct {
+    // PrivateKeyFile is the path of a PEM encoded RSA or ECDSA (P-256) private key
+    PrivateKeyFile string
+    KeyId          string
+    Issuer         string
+    Subject        string
+    Audience       []string
+    Roles          []string
+    // Claims are added to the registered claims. They can't override jti, iat, nbf and exp,
+    // nor iss, sub, aud and roles when those are configured, but they set them when they are not
+    Claims map[string]any
+    TTL    time.Duration
+}
+
+// JWTTokenProvider signs its own tokens with a local private key.
+// A token is reused until it is about to expire.
+type JWTTokenProvider struct {
+    config JWTConfig
+    signer jwtSigner
+    now    func() time.Time
+
+    mutex     sync.Mutex
+    token     string
+    expiresAt time.Time
+}
+
+var _ TokenProvider = (*JWTTokenProvider)(nil)
+
+func NewJWTTokenProvider(config JWTConfig) (*JWTTokenProvider, error) {
+    rawKey, err := os.ReadFile(config.PrivateKeyFile)
+    if err != nil {
+        return nil, fmt.Errorf("failed reading jwt private key file %s: %w", config.PrivateKeyFile, err)
+    }
+    signer, err := newJWTSigner(rawKey)
+    if err != nil {
+        return nil, err
+    }
+    if config.TTL <= 0 {
+        config.TTL = defaultJWTTTL
+    }
+    return &JWTTokenProvider{
+        config: config,
+        signer: signer,
+        now:    time.Now,
+    }, nil
+}
+
+func (p *JWTTokenProvider) Token(_ context.Context) (string, error) {
+    p.mutex.Lock()
+    defer p.mutex.Unlock()
+    now := p.now()
+    if p.token != "" && now.Add(jwtRefreshLeeway).Before(p.expiresAt) {
+        return p.token, nil
+    }
+    expiresAt := now.Add(p.config.TTL)
+    token, err := p.sign(now, expiresAt)
+    if err != nil {
+        return "", err
+    }
+    p.token = token
+    p.expiresAt = expiresAt
+    return p.token, nil
+}
+
+func (p *JWTTokenProvider) Roles() []string {
+    return p.config.Roles
+}
+
+func (p *JWTTokenProvider) Invalidate() {
+    p.mutex.Lock()
+    defer p.mutex.Unlock()
+    p.token = ""
+}
+
+func (p *JWTTokenProvider) sign(issuedAt time.Time, expiresAt time.Time) (string, error) {
+    claims := make(map[string]any, len(p.config.Claims)+7)
+    for name, value := range p.config.Claims {
+        claims[name] = value
+    }
+    claims["jti"] = uuid.NewString()
+    claims["iat"] = issuedAt.Unix()
+    claims["nbf"] = issuedAt.Unix()
+    claims["exp"] = expiresAt.Unix()
+    if p.config.Issuer != "" {
+        claims["iss"] = p.config.Issuer
+    }
+    if p.config.Subject != "" {
+        claims["sub"] = p.config.Subject
+    }
+    if len(p.config.Audience) > 0 {
+        claims["aud"] = p.config.Audience
+    }
+    if len(p.config.Roles) > 0 {
+        claims["roles"] = p.config.Roles
+    }
+
+    header := map[string]string{
+        "alg": p.signer.algorithm(),
+        "typ": "JWT",
+    }
+    if p.config.KeyId != "" {
+        header["kid"] = p.config.KeyId
+    }
+    rawHeader, err := json.Marshal(header)
+    if err != nil {
+        return "", fmt.Errorf("failed serializing jwt header: %w", err)
+    }
+    rawClaims, err := json.Marshal(claims)
+    if err != nil {
+        return "", fmt.Errorf("failed serializing jwt claims: %w", err)
+    }
+    signingInput := base64.RawURLEncoding.EncodeToString(rawHeader) + "." + base64.RawURLEncoding.EncodeToString(rawClaims)
+    signature, err := p.signer.sign([]byte(signingInput))
+    if err != nil {
+        return "", fmt.Errorf("failed signing jwt: %w", err)
+    }
+    return signingInput + "." + base64.RawURLEncoding.EncodeToString(signature), nil
+}
+
+type jwtSigner interface {
+    algorithm() string
+    sign(signingInput []byte) ([]byte, error)
+}
+
+func newJWTSigner(rawKey []byte) (jwtSigner, error) {
+    block, _ := pem.Decode(rawKey)
+    if block == nil {
+        return nil, fmt.Errorf("jwt private key is not PEM encoded")
+    }
+    var key any
+    var err error
+    switch block.Type {
+    case "RSA PRIVATE KEY":
+        key, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+    case "EC PRIVATE KEY":
+        key, err = x509.ParseECPrivateKey(block.Bytes)
+    default:
+        key, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+    }
+    if err != nil {
+        return nil, fmt.Errorf("failed parsing jwt private key: %w", err)
+    }
+    switch typedKey := key.(type) {
+    case *rsa.PrivateKey:
+        return rsaSigner{key: typedKey}, nil
+    case *ecdsa.PrivateKey:
+        if typedKey.Curve.Params().BitSize != 256 {
+            return nil, fmt.Errorf("unsupported jwt ecdsa curve %s", typedKey.Curve.Params().Name)
+        }
+        return ecdsaSigner{key: typedKey}, nil
+    default:
+        return nil, fmt.Errorf("unsupported jwt private key type %T", key)
+    }
+}
+
+type rsaSigner struct {
+    key *rsa.PrivateKey
+}
+
+func (s rsaSigner) algorithm() string {
+    return "RS256"
+}
+
+func (s rsaSigner) sign(signingInput []byte) ([]byte, error) {
+    hash := sha256.Sum256(signingInput)
+    return rsa.SignPKCS1v15(rand.Reader, s.key, crypto.SHA256, hash[:])
+}
+
+type ecdsaSigner struct {
+    key *ecdsa.PrivateKey
+}
+
+func (s ecdsaSigner) algorithm() string {
+    return "ES256"
+}
+
+func (s ecdsaSigner) sign(signingInput []byte) ([]byte, error) {
+    hash := sha256.Sum256(signingInput)
+    r, sig, err := ecdsa.Sign(rand.Reader, s.key, hash[:])
+    if err != nil {
+        return nil, err
+    }
+    // JWS expects the fixed size R || S encoding instead of ASN.1
+    signature := make([]byte, 64)
+    r.FillBytes(signature[:32])
+    sig.FillBytes(signature[32:])
+    return signature, nil
+}
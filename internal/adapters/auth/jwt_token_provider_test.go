@@ -0,0 +1,68 @@
+package auth
+
+import (
+    "context"
+    "crypto/ecdsa"
+    "crypto/elliptic"
+    "crypto/rand"
+    "crypto/sha256"
+    "crypto/x509"
+    "encoding/base64"
+    "encoding/json"
+    "encoding/pem"
+    "math/big"
+    "os"
+    "path/filepath"
+    "strings"
+    "testing"
+
+    "github.com/stretchr/testify/require"
+)
+
+func TestJWTTokenProvider_SignsVerifiableTokenWithConfiguredClaims(t *testing.T) {
+    privateKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+    require.NoError(t, err)
+    rawKey, err := x509.MarshalPKCS8PrivateKey(privateKey)
+    require.NoError(t, err)
+    keyFile := filepath.Join(t.TempDir(), "key.pem")
+    err = os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: rawKey}), 0600)
+    require.NoError(t, err)
+
+    provider, err := NewJWTTokenProvider(JWTConfig{
+        PrivateKeyFile: keyFile,
+        Issuer:         "dinopay-gateway",
+        Audience:       []string{"payments"},
+        Roles:          []string{"payments:write"},
+        Claims:         map[string]any{"tenant": "walletera"},
+    })
+    require.NoError(t, err)
+
+    token, err := provider.Token(context.Background())
+    require.NoError(t, err)
+    parts := strings.Split(token, ".")
+    require.Len(t, parts, 3)
+
+    signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+    require.NoError(t, err)
+    hash := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+    r := new(big.Int).SetBytes(signature[:32])
+    s := new(big.Int).SetBytes(signature[32:])
+    require.True(t, ecdsa.Verify(&privateKey.PublicKey, hash[:], r, s))
+
+    rawClaims, err := base64.RawURLEncoding.DecodeString(parts[1])
+    require.NoError(t, err)
+    var claims map[string]any
+    require.NoError(t, json.Unmarshal(rawClaims, &claims))
+    require.Equal(t, "dinopay-gateway", claims["iss"])
+    require.Equal(t, "walletera", claims["tenant"])
+    require.Equal(t, []any{"payments:write"}, claims["roles"])
+
+    cachedToken, err := provider.Token(context.Background())
+    require.NoError(t, err)
+    require.Equal(t, token, cachedToken)
+
+    provider.Invalidate()
+    refreshedToken, err := provider.Token(context.Background())
+    require.NoError(t, err)
+    require.NotEqual(t, token, refreshedToken)
+}
@@ -0,0 +1,41 @@
+package auth
+
+import (
+    "context"
+)
+
+// TokenProvider provides the bearer token used by the gateway
+// to authenticate itself against other Walletera services.
+type TokenProvider interface {
+    // Token returns a valid token, refreshing it if necessary
+    Token(ctx context.Context) (string, error)
+    // Roles returns the roles granted by the token
+    Roles() []string
+    // Invalidate discards the current token so the next call
+    // to Token returns a fresh one. It is called after a 401 response.
+    Invalidate()
+}
+
+// StaticTokenProvider always returns the same token.
+// It is only intended for tests, the gateway requires a jwt private key or a token file.
+type StaticTokenProvider struct {
+    token string
+    roles []string
+}
+
+var _ TokenProvider = (*StaticTokenProvider)(nil)
+
+func NewStaticTokenProvider(token string, roles ...string) *StaticTokenProvider {
+    return &StaticTokenProvider{token: token, roles: roles}
+}
+
+func (s *StaticTokenProvider) Token(_ context.Context) (string, error) {
+    return s.token, nil
+}
+
+func (s *StaticTokenProvider) Roles() []string {
+    return s.roles
+}
+
+func (s *StaticTokenProvider) Invalidate() {
+}
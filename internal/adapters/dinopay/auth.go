@@ -0,0 +1,171 @@
+package dinopay
+
+import (
+    "context"
+    "encoding/json"
+    "fmt"
+    "io"
+    "net/http"
+    "net/url"
+    "strings"
+    "sync"
+    "time"
+
+    "github.com/walletera/dinopay-gateway/internal/adapters/auth"
+)
+
+const (
+    // tokenRefreshLeeway is how long before its expiration a token is refreshed
+    tokenRefreshLeeway = 30 * time.Second
+    // defaultTokenLifetime is used when the token endpoint doesn't send expires_in
+    defaultTokenLifetime = 5 * time.Minute
+)
+
+// httpDoer is satisfied by *http.Client and by the http client
+// expected by the ogen generated DinoPay api client
+type httpDoer interface {
+    Do(req *http.Request) (*http.Response, error)
+}
+
+// authenticatedClient decorates an httpDoer adding DinoPay credentials
+// to every request. A 401 response invalidates the cached token and the
+// request is retried once with a fresh one.
+type authenticatedClient struct {
+    client      httpDoer
+    credentials Credentials
+    tokenSource *oauth2TokenSource
+}
+
+func newAuthenticatedClient(client httpDoer, credentials Credentials) *authenticatedClient {
+    authClient := &authenticatedClient{
+        client:      client,
+        credentials: credentials,
+    }
+    if credentials.hasOAuth2() {
+        authClient.tokenSource = newOAuth2TokenSource(client, credentials)
+    }
+    return authClient
+}
+
+func (c *authenticatedClient) Do(req *http.Request) (*http.Response, error) {
+    err := c.authenticate(req)
+    if err != nil {
+        return nil, err
+    }
+    resp, err := c.client.Do(req)
+    if err != nil || resp.StatusCode != http.StatusUnauthorized || c.tokenSource == nil {
+        return resp, err
+    }
+
+    // the token may have been revoked or rotated before its expiration
+    resp.Body.Close()
+    c.tokenSource.invalidate()
+    retryReq, err := auth.CloneRequest(req)
+    if err != nil {
+        return nil, fmt.Errorf("failed retrying dinopay request: %w", err)
+    }
+    err = c.authenticate(retryReq)
+    if err != nil {
+        return nil, err
+    }
+    return c.client.Do(retryReq)
+}
+
+func (c *authenticatedClient) authenticate(req *http.Request) error {
+    if c.credentials.APIKey != "" {
+        req.Header.Set(c.credentials.apiKeyHeader(), c.credentials.APIKey)
+    }
+    if c.tokenSource != nil {
+        token, err := c.tokenSource.token(req.Context())
+        if err != nil {
+            return err
+        }
+        req.Header.Set("Authorization", "Bearer "+token)
+    }
+    return nil
+}
+
+type oauth2Token struct {
+    AccessToken string `json:"access_token"`
+    TokenType   string `json:"token_type"`
+    ExpiresIn   int64  `json:"expires_in"`
+}
+
+// oauth2TokenSource implements the OAuth2 client credentials grant
+// caching the access token until shortly before it expires.
+type oauth2TokenSource struct {
+    client      httpDoer
+    credentials Credentials
+    now         func() time.Time
+
+    mutex       sync.Mutex
+    accessToken string
+    expiresAt   time.Time
+}
+
+func newOAuth2TokenSource(client httpDoer, credentials Credentials) *oauth2TokenSource {
+    return &oauth2TokenSource{
+        client:      client,
+        credentials: credentials,
+        now:         time.Now,
+    }
+}
+
+func (s *oauth2TokenSource) token(ctx context.Context) (string, error) {
+    s.mutex.Lock()
+    defer s.mutex.Unlock()
+    if s.accessToken != "" && s.now().Add(tokenRefreshLeeway).Before(s.expiresAt) {
+        return s.accessToken, nil
+    }
+    token, err := s.fetchToken(ctx)
+    if err != nil {
+        return "", err
+    }
+    lifetime := defaultTokenLifetime
+    if token.ExpiresIn > 0 {
+        lifetime = time.Duration(token.ExpiresIn) * time.Second
+    }
+    s.accessToken = token.AccessToken
+    s.expiresAt = s.now().Add(lifetime)
+    return s.accessToken, nil
+}
+
+func (s *oauth2TokenSource) invalidate() {
+    s.mutex.Lock()
+    defer s.mutex.Unlock()
+    s.accessToken = ""
+    s.expiresAt = time.Time{}
+}
+
+func (s *oauth2TokenSource) fetchToken(ctx context.Context) (oauth2Token, error) {
+    form := url.Values{}
+    form.Set("grant_type", "client_credentials")
+    if len(s.credentials.Scopes) > 0 {
+        form.Set("scope", strings.Join(s.credentials.Scopes, " "))
+    }
+    req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.credentials.TokenUrl, strings.NewReader(form.Encode()))
+    if err != nil {
+        return oauth2Token{}, fmt.Errorf("failed creating dinopay token request: %w", err)
+    }
+    req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+    req.SetBasicAuth(url.QueryEscape(s.credentials.ClientId), url.QueryEscape(s.credentials.ClientSecret))
+    resp, err := s.client.Do(req)
+    if err != nil {
+        return oauth2Token{}, fmt.Errorf("failed requesting dinopay access token: %w", err)
+    }
+    defer resp.Body.Close()
+    if resp.StatusCode != http.StatusOK {
+        // the response body is not included because it may echo the credentials
+        return oauth2Token{}, fmt.Errorf("dinopay token endpoint responded with status code %d", resp.StatusCode)
+    }
+    rawToken, err := io.ReadAll(resp.Body)
+    if err != nil {
+        return oauth2Token{}, fmt.Errorf("failed reading dinopay token response: %w", err)
+    }
+    var token oauth2Token
+    err = json.Unmarshal(rawToken, &token)
+    if err != nil || token.AccessToken == "" {
+        return oauth2Token{}, fmt.Errorf("invalid dinopay token response")
+    }
+    return token, nil
+}
@@ -0,0 +1,68 @@
+package dinopay
+
+import (
+    "fmt"
+    "net/http"
+    "net/http/httptest"
+    "strings"
+    "sync/atomic"
+    "testing"
+
+    "github.com/stretchr/testify/require"
+)
+
+func TestAuthenticatedClient_RefreshesTokenOnceAfterUnauthorized(t *testing.T) {
+    var issuedTokens atomic.Int32
+    var apiCalls atomic.Int32
+    server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        switch r.URL.Path {
+        case "/token":
+            clientId, clientSecret, ok := r.BasicAuth()
+            require.True(t, ok)
+            require.Equal(t, "gateway", clientId)
+            require.Equal(t, "s3cr3t", clientSecret)
+            tokenNumber := issuedTokens.Add(1)
+            w.Header().Set("Content-Type", "application/json")
+            fmt.Fprintf(w, `{"access_token":"token-%d","token_type":"Bearer","expires_in":3600}`, tokenNumber)
+        case "/payments":
+            apiCalls.Add(1)
+            require.Equal(t, "my-api-key", r.Header.Get(DefaultAPIKeyHeader))
+            // the first token is considered revoked
+            if r.Header.Get("Authorization") != "Bearer token-2" {
+                w.WriteHeader(http.StatusUnauthorized)
+                return
+            }
+            w.WriteHeader(http.StatusOK)
+        }
+    }))
+    defer server.Close()
+
+    client := newAuthenticatedClient(http.DefaultClient, Credentials{
+        APIKey:       "my-api-key",
+        ClientId:     "gateway",
+        ClientSecret: "s3cr3t",
+        TokenUrl:     server.URL + "/token",
+    })
+
+    req, err := http.NewRequest(http.MethodPost, server.URL+"/payments", strings.NewReader(`{}`))
+    require.NoError(t, err)
+    resp, err := client.Do(req)
+    require.NoError(t, err)
+    require.Equal(t, http.StatusOK, resp.StatusCode)
+    require.Equal(t, int32(2), issuedTokens.Load())
+    require.Equal(t, int32(2), apiCalls.Load())
+
+    // the refreshed token is cached
+    req, err = http.NewRequest(http.MethodPost, server.URL+"/payments", strings.NewReader(`{}`))
+    require.NoError(t, err)
+    resp, err = client.Do(req)
+    require.NoError(t, err)
+    require.Equal(t, http.StatusOK, resp.StatusCode)
+    require.Equal(t, int32(2), issuedTokens.Load())
+}
+
+func TestCredentials_StringDoesNotLeakSecrets(t *testing.T) {
+    credentials := Credentials{APIKey: "my-api-key", ClientId: "gateway", ClientSecret: "s3cr3t", TokenUrl: "http://token"}
+    require.NotContains(t, credentials.String(), "my-api-key")
+    require.NotContains(t, credentials.String(), "s3cr3t")
+}
@@ -2,26 +2,186 @@ package dinopay
 
 import (
     "context"
+    "encoding/json"
     "fmt"
     "net/http"
+    "net/url"
+    "strings"
 
+    "github.com/google/uuid"
+    dinopayport "github.com/walletera/dinopay-gateway/internal/domain/ports/output/dinopay"
     "github.com/walletera/dinopay/api"
 )
 
 type Client struct {
     client *api.Client
+    url    string
+
+    httpClient  httpDoer
+    credentials Credentials
 }
 
-func NewClient(url string) (*Client, error) {
-    client, err := api.NewClient(url, api.WithClient(http.DefaultClient))
+type Opt func(client *Client)
+
+func WithCredentials(credentials Credentials) Opt {
+    return func(client *Client) {
+        client.credentials = credentials
+    }
+}
+
+func WithHttpClient(httpClient *http.Client) Opt {
+    return func(client *Client) {
+        client.httpClient = httpClient
+    }
+}
+
+func NewClient(url string, opts ...Opt) (*Client, error) {
+    dinopayClient := &Client{
+        url:        strings.TrimSuffix(url, "/"),
+        httpClient: http.DefaultClient,
+    }
+    for _, opt := range opts {
+        opt(dinopayClient)
+    }
+    err := dinopayClient.credentials.Validate()
+    if err != nil {
+        return nil, err
+    }
+    var httpClient httpDoer = dinopayClient.httpClient
+    if !dinopayClient.credentials.IsEmpty() {
+        httpClient = newAuthenticatedClient(httpClient, dinopayClient.credentials)
+    }
+    client, err := api.NewClient(url, api.WithClient(httpClient))
     if err != nil {
         return nil, fmt.Errorf("failed creating dinopay api client: %w", err)
     }
-    return &Client{
-        client: client,
-    }, nil
+    dinopayClient.client = client
+    dinopayClient.httpClient = httpClient
+    return dinopayClient, nil
 }
 
 func (c *Client) CreatePayment(ctx context.Context, req *api.Payment) (api.CreatePaymentRes, error) {
     return c.client.CreatePayment(ctx, req)
 }
+
+// GetPayment calls GET /payments/{id}.
+// The operation is not part of the generated DinoPay client yet, so the
+// request is built by hand on top of the same (authenticated) http client.
+func (c *Client) GetPayment(ctx context.Context, id uuid.UUID) (*api.Payment, error) {
+    var payment api.Payment
+    found, err := c.get(ctx, "/payments/"+id.String(), &payment)
+    if err != nil || !found {
+        return nil, err
+    }
+    return &payment, nil
+}
+
+// FindPaymentByCustomerTransactionId calls GET /payments?customerTransactionId=<id>.
+// Like GetPayment, the request is built by hand. The operation isn't part of the
+// published DinoPay API, so a 404 means DinoPay doesn't serve the lookup, not that
+// the payment doesn't exist, and it is returned as an error.
+func (c *Client) FindPaymentByCustomerTransactionId(ctx context.Context, customerTransactionId string) (*api.Payment, error) {
+    path := "/payments?customerTransactionId=" + url.QueryEscape(customerTransactionId)
+    var payments []api.Payment
+    found, err := c.get(ctx, path, &payments)
+    if err != nil {
+        return nil, err
+    }
+    if !found {
+        return nil, fmt.Errorf("dinopay GET %s not found", path)
+    }
+    for _, payment := range payments {
+        if payment.CustomerTransactionId.Value == customerTransactionId {
+            return &payment, nil
+        }
+    }
+    return nil, nil
+}
+
+// CancelPayment calls POST /payments/{id}/cancel.
+// Like GetPayment, the request is built by hand. DinoPay answers 409
+// when the payment was already processed.
+func (c *Client) CancelPayment(ctx context.Context, id uuid.UUID) (*api.Payment, error) {
+    path := "/payments/" + id.String() + "/cancel"
+    req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.url+path, nil)
+    if err != nil {
+        return nil, fmt.Errorf("failed building dinopay request POST %s: %w", path, err)
+    }
+    req.Header.Set("Accept", "application/json")
+    resp, err := c.httpClient.Do(req)
+    if err != nil {
+        return nil, fmt.Errorf("failed calling dinopay POST %s: %w", path, err)
+    }
+    defer resp.Body.Close()
+    switch resp.StatusCode {
+    case http.StatusOK:
+    case http.StatusConflict:
+        return nil, dinopayport.ErrPaymentNotCancellable
+    default:
+        return nil, fmt.Errorf("unexpected dinopay response status code %d for POST %s", resp.StatusCode, path)
+    }
+    var payment api.Payment
+    err = json.NewDecoder(resp.Body).Decode(&payment)
+    if err != nil {
+        return nil, fmt.Errorf("failed decoding dinopay response for POST %s: %w", path, err)
+    }
+    return &payment, nil
+}
+
+// ListPaymentChanges calls GET /payments/changes?cursor=<cursor>.
+// Like FindPaymentByCustomerTransactionId, the operation isn't part of the
+// published DinoPay API and the request is built by hand. It answers
+// {"payments": [...], "nextCursor": "..."} with the payments created or updated
+// after the cursor, oldest change first, and the same cursor once there are no
+// more changes. A 404 means DinoPay doesn't serve it and is returned as an error,
+// the polling can't replace the webhooks against such DinoPay.
+func (c *Client) ListPaymentChanges(ctx context.Context, cursor string) (dinopayport.PaymentsPage, error) {
+    path := "/payments/changes"
+    if cursor != "" {
+        query := url.Values{}
+        query.Set("cursor", cursor)
+        path += "?" + query.Encode()
+    }
+    var page struct {
+        Payments   []api.Payment `json:"payments"`
+        NextCursor string        `json:"nextCursor"`
+    }
+    found, err := c.get(ctx, path, &page)
+    if err != nil {
+        return dinopayport.PaymentsPage{}, err
+    }
+    if !found {
+        return dinopayport.PaymentsPage{}, fmt.Errorf("dinopay GET %s not found", path)
+    }
+    return dinopayport.PaymentsPage{
+        Payments:   page.Payments,
+        NextCursor: page.NextCursor,
+    }, nil
+}
+
+// get decodes the json response of a GET request into v.
+// It returns false when DinoPay answers 404.
+func (c *Client) get(ctx context.Context, path string, v any) (bool, error) {
+    req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.url+path, nil)
+    if err != nil {
+        return false, fmt.Errorf("failed building dinopay request GET %s: %w", path, err)
+    }
+    req.Header.Set("Accept", "application/json")
+    resp, err := c.httpClient.Do(req)
+    if err != nil {
+        return false, fmt.Errorf("failed calling dinopay GET %s: %w", path, err)
+    }
+    defer resp.Body.Close()
+    switch resp.StatusCode {
+    case http.StatusOK:
+    case http.StatusNotFound:
+        return false, nil
+    default:
+        return false, fmt.Errorf("unexpected dinopay response status code %d for GET %s", resp.StatusCode, path)
+    }
+    err = json.NewDecoder(resp.Body).Decode(v)
+    if err != nil {
+        return false, fmt.Errorf("failed decoding dinopay response for GET %s: %w", path, err)
+    }
+    return true, nil
+}
@@ -0,0 +1,74 @@
+package dinopay
+
+import (
+    "context"
+    "fmt"
+    "net/http"
+    "net/http/httptest"
+    "testing"
+
+    "github.com/stretchr/testify/require"
+)
+
+func TestClient_FindPaymentByCustomerTransactionId(t *testing.T) {
+    const customerTransactionId = "0ae1733e-7538-4908-b90a-5721670cb093"
+    tests := []struct {
+        name       string
+        statusCode int
+        body       string
+        wantFound  bool
+        wantErr    bool
+    }{
+        {
+            name:       "payment found",
+            statusCode: http.StatusOK,
+            body: fmt.Sprintf(`[{
+                "id": "bb17667e-daac-41f6-ada3-2c22f24caf22",
+                "amount": 100,
+                "currency": "USD",
+                "sourceAccount": {"accountHolder": "john doe", "accountNumber": "IE12BOFI90000112345678"},
+                "destinationAccount": {"accountHolder": "jane doe", "accountNumber": "IE12BOFI90000112349876"},
+                "status": "pending",
+                "customerTransactionId": "%s"
+            }]`, customerTransactionId),
+            wantFound: true,
+        },
+        {
+            name:       "payment not found",
+            statusCode: http.StatusOK,
+            body:       `[]`,
+        },
+        {
+            name:       "lookup not served by dinopay",
+            statusCode: http.StatusNotFound,
+            wantErr:    true,
+        },
+        {
+            name:       "unexpected response",
+            statusCode: http.StatusInternalServerError,
+            wantErr:    true,
+        },
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+                require.Equal(t, "/payments", r.URL.Path)
+                require.Equal(t, customerTransactionId, r.URL.Query().Get("customerTransactionId"))
+                w.Header().Set("Content-Type", "application/json")
+                w.WriteHeader(tt.statusCode)
+                fmt.Fprint(w, tt.body)
+            }))
+            defer server.Close()
+
+            client, err := NewClient(server.URL)
+            require.NoError(t, err)
+            payment, err := client.FindPaymentByCustomerTransactionId(context.Background(), customerTransactionId)
+            if tt.wantErr {
+                require.Error(t, err)
+                return
+            }
+            require.NoError(t, err)
+            require.Equal(t, tt.wantFound, payment != nil)
+        })
+    }
+}
@@ -0,0 +1,78 @@
+package dinopay
+
+import (
+    "encoding/json"
+    "fmt"
+    "os"
+)
+
+const DefaultAPIKeyHeader = "X-Api-Key"
+
+// Credentials holds the secrets used to authenticate against DinoPay.
+// Either APIKey or the OAuth2 client credentials (ClientId, ClientSecret
+// and TokenUrl) must be set. When both are set the OAuth2 flow is used
+// and the api key is sent as an additional header.
+type Credentials struct {
+    APIKey       string   `json:"apiKey,omitempty"`
+    APIKeyHeader string   `json:"apiKeyHeader,omitempty"`
+    ClientId     string   `json:"clientId,omitempty"`
+    ClientSecret string   `json:"clientSecret,omitempty"`
+    TokenUrl     string   `json:"tokenUrl,omitempty"`
+    Scopes       []string `json:"scopes,omitempty"`
+}
+
+// LoadCredentialsFile reads the credentials from a json file,
+// typically a secret mounted into the container.
+func LoadCredentialsFile(path string) (Credentials, error) {
+    rawCredentials, err := os.ReadFile(path)
+    if err != nil {
+        return Credentials{}, fmt.Errorf("failed reading dinopay credentials file %s: %w", path, err)
+    }
+    var credentials Credentials
+    err = json.Unmarshal(rawCredentials, &credentials)
+    if err != nil {
+        // the json error may contain part of the secret so it is not wrapped
+        return Credentials{}, fmt.Errorf("failed parsing dinopay credentials file %s", path)
+    }
+    return credentials, nil
+}
+
+func (c Credentials) IsEmpty() bool {
+    return c.APIKey == "" && !c.hasOAuth2()
+}
+
+// String never prints the secrets so that Credentials can be safely logged.
+func (c Credentials) String() string {
+    return fmt.Sprintf("Credentials{APIKey:%s ClientId:%s ClientSecret:%s TokenUrl:%s}",
+        redact(c.APIKey), c.ClientId, redact(c.ClientSecret), c.TokenUrl)
+}
+
+func (c Credentials) Validate() error {
+    if c.IsEmpty() {
+        return nil
+    }
+    if c.ClientId != "" || c.ClientSecret != "" || c.TokenUrl != "" {
+        if !c.hasOAuth2() {
+            return fmt.Errorf("incomplete dinopay oauth2 credentials: clientId, clientSecret and tokenUrl are required")
+        }
+    }
+    return nil
+}
+
+func (c Credentials) hasOAuth2() bool {
+    return c.ClientId != "" && c.ClientSecret != "" && c.TokenUrl != ""
+}
+
+func (c Credentials) apiKeyHeader() string {
+    if c.APIKeyHeader == "" {
+        return DefaultAPIKeyHeader
+    }
+    return c.APIKeyHeader
+}
+
+func redact(secret string) string {
+    if secret == "" {
+        return ""
+    }
+    return "[REDACTED]"
+}
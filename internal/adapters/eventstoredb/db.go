@@ -0,0 +1,53 @@
+// Package eventstoredb extends the EventStoreDB eventsourcing.DB of eventskit
+// with the stream metadata the gateway relies on to bound its streams.
+package eventstoredb
+
+import (
+    "context"
+    "time"
+
+    "github.com/EventStore/EventStore-Client-Go/v4/esdb"
+    "github.com/walletera/eventskit/eventsourcing"
+    "github.com/walletera/eventskit/eventstoredb"
+    "github.com/walletera/werrors"
+)
+
+var _ eventsourcing.DB = (*DB)(nil)
+
+type DB struct {
+    *eventstoredb.DB
+    client *esdb.Client
+}
+
+func NewDB(client *esdb.Client) *DB {
+    return &DB{
+        DB:     eventstoredb.NewDB(client),
+        client: client,
+    }
+}
+
+// SetStreamMaxCount makes EventStoreDB keep only the last maxCount events of the stream.
+// The older ones are not returned by the reads anymore and are scavenged later.
+func (db *DB) SetStreamMaxCount(ctx context.Context, streamName string, maxCount uint64) werrors.WError {
+    metadata := esdb.StreamMetadata{}
+    metadata.SetMaxCount(maxCount)
+    return db.setStreamMetadata(ctx, streamName, metadata)
+}
+
+// SetStreamMaxAge makes EventStoreDB drop the events of the stream older than maxAge.
+// A stream whose events were all dropped still exists, it reads as empty.
+func (db *DB) SetStreamMaxAge(ctx context.Context, streamName string, maxAge time.Duration) werrors.WError {
+    metadata := esdb.StreamMetadata{}
+    metadata.SetMaxAge(maxAge)
+    return db.setStreamMetadata(ctx, streamName, metadata)
+}
+
+// setStreamMetadata replaces the metadata of the stream, the gateway
+// streams have no metadata but the one set by this DB
+func (db *DB) setStreamMetadata(ctx context.Context, streamName string, metadata esdb.StreamMetadata) werrors.WError {
+    _, err := db.client.SetStreamMetadata(ctx, streamName, esdb.AppendToStreamOptions{}, metadata)
+    if err != nil {
+        return werrors.NewRetryableInternalError("failed setting metadata of stream " + streamName + ": " + err.Error())
+    }
+    return nil
+}
@@ -0,0 +1,191 @@
+// Package metrics exports the OpenTelemetry metrics of the gateway in the
+// Prometheus text format, so they can be scraped without an agent.
+package metrics
+
+import (
+    "context"
+    "errors"
+    "fmt"
+    "io"
+    "log/slog"
+    "net/http"
+    "sort"
+    "strconv"
+    "strings"
+    "sync"
+
+    "github.com/walletera/dinopay-gateway/pkg/logattr"
+    "go.opentelemetry.io/otel/attribute"
+    "go.opentelemetry.io/otel/metric"
+    "go.opentelemetry.io/otel/metric/noop"
+)
+
+var (
+    _ metric.MeterProvider = (*Registry)(nil)
+    _ metric.Meter         = (*meter)(nil)
+    _ metric.Int64Counter  = (*counter)(nil)
+)
+
+// Registry is the meter provider of the gateway. It keeps the value of every
+// int64 counter by attribute set and serves them in the Prometheus text format.
+// The other instruments are not exported, they are the no-op ones.
+type Registry struct {
+    noop.MeterProvider
+
+    mutex    sync.Mutex
+    counters map[string]*counter
+}
+
+func NewRegistry() *Registry {
+    return &Registry{counters: make(map[string]*counter)}
+}
+
+func (r *Registry) Meter(_ string, _ ...metric.MeterOption) metric.Meter {
+    return &meter{registry: r}
+}
+
+// ServeHTTP writes the value of every counter in the Prometheus text format
+func (r *Registry) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+    w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+    r.mutex.Lock()
+    counters := make([]*counter, 0, len(r.counters))
+    for _, c := range r.counters {
+        counters = append(counters, c)
+    }
+    r.mutex.Unlock()
+    sort.Slice(counters, func(i, j int) bool { return counters[i].name < counters[j].name })
+    for _, c := range counters {
+        c.write(w)
+    }
+}
+
+// counter returns the counter with the given name, the instruments
+// created with the same name by different meters share their value
+func (r *Registry) counter(name string, description string) *counter {
+    r.mutex.Lock()
+    defer r.mutex.Unlock()
+    c, ok := r.counters[name]
+    if !ok {
+        c = &counter{
+            name:        promName(name),
+            description: description,
+            series:      make(map[attribute.Distinct]*series),
+        }
+        r.counters[name] = c
+    }
+    return c
+}
+
+type meter struct {
+    noop.Meter
+    registry *Registry
+}
+
+func (m *meter) Int64Counter(name string, options ...metric.Int64CounterOption) (metric.Int64Counter, error) {
+    config := metric.NewInt64CounterConfig(options...)
+    return m.registry.counter(name, config.Description()), nil
+}
+
+type series struct {
+    attributes attribute.Set
+    value      int64
+}
+
+type counter struct {
+    noop.Int64Counter
+    name        string
+    description string
+
+    mutex  sync.Mutex
+    series map[attribute.Distinct]*series
+}
+
+func (c *counter) Add(_ context.Context, incr int64, options ...metric.AddOption) {
+    if incr < 0 {
+        // counters are monotonic
+        return
+    }
+    attributes := metric.NewAddConfig(options).Attributes()
+    c.mutex.Lock()
+    defer c.mutex.Unlock()
+    s, ok := c.series[attributes.Equivalent()]
+    if !ok {
+        s = &series{attributes: attributes}
+        c.series[attributes.Equivalent()] = s
+    }
+    s.value += incr
+}
+
+func (c *counter) Enabled(_ context.Context) bool {
+    return true
+}
+
+func (c *counter) write(w io.Writer) {
+    c.mutex.Lock()
+    defer c.mutex.Unlock()
+    if c.description != "" {
+        fmt.Fprintf(w, "# HELP %s_total %s\n", c.name, c.description)
+    }
+    fmt.Fprintf(w, "# TYPE %s_total counter\n", c.name)
+    lines := make([]string, 0, len(c.series))
+    for _, s := range c.series {
+        lines = append(lines, fmt.Sprintf("%s_total%s %d\n", c.name, promLabels(s.attributes), s.value))
+    }
+    sort.Strings(lines)
+    for _, line := range lines {
+        io.WriteString(w, line)
+    }
+}
+
+// promName replaces the characters Prometheus doesn't allow in metric and label names
+func promName(name string) string {
+    return strings.Map(func(r rune) rune {
+        switch {
+        case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '_':
+            return r
+        }
+        return '_'
+    }, name)
+}
+
+func promLabels(attributes attribute.Set) string {
+    if attributes.Len() == 0 {
+        return ""
+    }
+    labels := make([]string, 0, attributes.Len())
+    for _, kv := range attributes.ToSlice() {
+        labels = append(labels, promName(string(kv.Key))+"="+strconv.Quote(kv.Value.Emit()))
+    }
+    return "{" + strings.Join(labels, ",") + "}"
+}
+
+// Server serves the metrics of a registry on /metrics
+type Server struct {
+    httpServer *http.Server
+    logger     *slog.Logger
+}
+
+func NewServer(port int, registry *Registry, logger *slog.Logger) *Server {
+    mux := http.NewServeMux()
+    mux.Handle("GET /metrics", registry)
+    return &Server{
+        httpServer: &http.Server{
+            Addr:    fmt.Sprintf(":%d", port),
+            Handler: mux,
+        },
+        logger: logger.With(logattr.Component("metrics.Server")),
+    }
+}
+
+func (s *Server) Start() {
+    go func() {
+        err := s.httpServer.ListenAndServe()
+        if err != nil && !errors.Is(err, http.ErrServerClosed) {
+            s.logger.Error("metrics server failed", logattr.Error(err.Error()))
+        }
+    }()
+}
+
+func (s *Server) Close(ctx context.Context) error {
+    return s.httpServer.Shutdown(ctx)
+}
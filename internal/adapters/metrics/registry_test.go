@@ -0,0 +1,42 @@
+package metrics
+
+import (
+    "context"
+    "net/http"
+    "net/http/httptest"
+    "testing"
+
+    "github.com/stretchr/testify/require"
+    "go.opentelemetry.io/otel/attribute"
+    "go.opentelemetry.io/otel/metric"
+)
+
+func TestRegistry_ServeHTTP(t *testing.T) {
+    registry := NewRegistry()
+    counter, err := registry.Meter("github.com/walletera/dinopay-gateway").Int64Counter(
+        "dinopay_gateway.outbound_payments.stuck",
+        metric.WithDescription("Outbound payments still pending on DinoPay after the pending SLA"),
+    )
+    require.NoError(t, err)
+    ctx := context.Background()
+    counter.Add(ctx, 1)
+    counter.Add(ctx, 2)
+    counter.Add(ctx, 1, metric.WithAttributes(attribute.String("currency", "USD")))
+
+    // the same instrument from another meter shares the value
+    other, err := registry.Meter("other").Int64Counter("dinopay_gateway.outbound_payments.stuck")
+    require.NoError(t, err)
+    other.Add(ctx, 1)
+
+    recorder := httptest.NewRecorder()
+    registry.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+
+    require.Equal(t, http.StatusOK, recorder.Code)
+    require.Equal(t, ""+
+        "# HELP dinopay_gateway_outbound_payments_stuck_total Outbound payments still pending on DinoPay after the pending SLA\n"+
+        "# TYPE dinopay_gateway_outbound_payments_stuck_total counter\n"+
+        "dinopay_gateway_outbound_payments_stuck_total 4\n"+
+        "dinopay_gateway_outbound_payments_stuck_total{currency=\"USD\"} 1\n",
+        recorder.Body.String(),
+    )
+}
@@ -0,0 +1,134 @@
+package nats
+
+import (
+    "context"
+    "fmt"
+    "log/slog"
+    "strconv"
+    "sync"
+    "time"
+
+    natsgo "github.com/nats-io/nats.go"
+    "github.com/nats-io/nats.go/jetstream"
+    "github.com/walletera/dinopay-gateway/pkg/logattr"
+    "github.com/walletera/eventskit/messages"
+)
+
+const (
+    ErrorCodeHeader       = "w-error-code"
+    ErrorMessageHeader    = "w-error-message"
+    OriginalSubjectHeader = "w-original-subject"
+    DeliveryCountHeader   = "w-delivery-count"
+
+    deadLetterTimeout = 10 * time.Second
+)
+
+type deadLetterPublisher interface {
+    PublishMsg(ctx context.Context, msg *natsgo.Msg, opts ...jetstream.PublishOpt) (*jetstream.PubAck, error)
+}
+
+// Acknowledger maps the ack and nack of a message to the JetStream acks.
+// Only the first ack or nack counts, e.g. a message that timed out is nacked
+// by the processor and may still be acked when its handling finishes.
+type Acknowledger struct {
+    msg               jetstream.Msg
+    publisher         deadLetterPublisher
+    maxDeliver        int
+    retryBackoff      []time.Duration
+    deadLetterSubject string
+    logger            *slog.Logger
+    once              sync.Once
+}
+
+func NewAcknowledger(
+    msg jetstream.Msg,
+    publisher deadLetterPublisher,
+    maxDeliver int,
+    retryBackoff []time.Duration,
+    deadLetterSubject string,
+    logger *slog.Logger,
+) *Acknowledger {
+    return &Acknowledger{
+        msg:               msg,
+        publisher:         publisher,
+        maxDeliver:        maxDeliver,
+        retryBackoff:      retryBackoff,
+        deadLetterSubject: deadLetterSubject,
+        logger:            logger,
+    }
+}
+
+func (a *Acknowledger) Ack() error {
+    var err error
+    a.once.Do(func() {
+        err = a.msg.Ack()
+    })
+    return err
+}
+
+// Nack delivers the message again after the backoff when it is requeued and
+// wasn't delivered max deliver times yet, otherwise it dead letters it
+func (a *Acknowledger) Nack(opts messages.NackOpts) error {
+    var err error
+    a.once.Do(func() {
+        var metadata *jetstream.MsgMetadata
+        metadata, err = a.msg.Metadata()
+        if err != nil {
+            err = fmt.Errorf("failed reading jetstream message metadata: %w", err)
+            return
+        }
+        if opts.Requeue && metadata.NumDelivered < uint64(a.maxDeliver) {
+            err = a.msg.NakWithDelay(a.backoff(metadata.NumDelivered))
+            return
+        }
+        err = a.deadLetter(opts, metadata.NumDelivered)
+    })
+    return err
+}
+
+func (a *Acknowledger) backoff(deliveries uint64) time.Duration {
+    if len(a.retryBackoff) == 0 {
+        return 0
+    }
+    i := int(deliveries) - 1
+    if i >= len(a.retryBackoff) {
+        i = len(a.retryBackoff) - 1
+    }
+    if i < 0 {
+        i = 0
+    }
+    return a.retryBackoff[i]
+}
+
+// deadLetter republishes the message to the dead letter subject and terminates it.
+// When the republishing fails the message is left unacked, so it is delivered
+// again once the ack wait expires instead of being lost.
+func (a *Acknowledger) deadLetter(opts messages.NackOpts, deliveries uint64) error {
+    if a.deadLetterSubject != "" {
+        header := natsgo.Header{}
+        for key, values := range a.msg.Headers() {
+            header[key] = values
+        }
+        header.Set(ErrorCodeHeader, strconv.Itoa(int(opts.ErrorCode)))
+        header.Set(ErrorMessageHeader, opts.ErrorMessage)
+        header.Set(OriginalSubjectHeader, a.msg.Subject())
+        header.Set(DeliveryCountHeader, strconv.FormatUint(deliveries, 10))
+        ctx, cancel := context.WithTimeout(context.Background(), deadLetterTimeout)
+        defer cancel()
+        _, err := a.publisher.PublishMsg(ctx, &natsgo.Msg{
+            Subject: a.deadLetterSubject,
+            Header:  header,
+            Data:    a.msg.Data(),
+        })
+        if err != nil {
+            return fmt.Errorf("failed publishing message to dead letter subject %s: %w", a.deadLetterSubject, err)
+        }
+    }
+    a.logger.Warn(
+        "message dead lettered",
+        slog.String("subject", a.msg.Subject()),
+        slog.Uint64("deliveries", deliveries),
+        logattr.Error(opts.ErrorMessage),
+    )
+    return a.msg.TermWithReason(opts.ErrorMessage)
+}
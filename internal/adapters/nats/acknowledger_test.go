@@ -0,0 +1,117 @@
+package nats
+
+import (
+    "context"
+    "log/slog"
+    "testing"
+    "time"
+
+    natsgo "github.com/nats-io/nats.go"
+    "github.com/nats-io/nats.go/jetstream"
+    "github.com/stretchr/testify/require"
+    "github.com/walletera/eventskit/messages"
+    "github.com/walletera/werrors"
+)
+
+const testDeadLetterSubject = "payments.events.dead-letter"
+
+type fakeMsg struct {
+    jetstream.Msg
+    numDelivered uint64
+    acks         []string
+    nakDelay     time.Duration
+}
+
+func (m *fakeMsg) Metadata() (*jetstream.MsgMetadata, error) {
+    return &jetstream.MsgMetadata{NumDelivered: m.numDelivered}, nil
+}
+
+func (m *fakeMsg) Data() []byte {
+    return []byte(`{"type":"PaymentCreated"}`)
+}
+
+func (m *fakeMsg) Headers() natsgo.Header {
+    return natsgo.Header{"Nats-Msg-Id": []string{"1"}}
+}
+
+func (m *fakeMsg) Subject() string {
+    return "payments.events.payment.created"
+}
+
+func (m *fakeMsg) Ack() error {
+    m.acks = append(m.acks, "ack")
+    return nil
+}
+
+func (m *fakeMsg) NakWithDelay(delay time.Duration) error {
+    m.acks = append(m.acks, "nak")
+    m.nakDelay = delay
+    return nil
+}
+
+func (m *fakeMsg) TermWithReason(_ string) error {
+    m.acks = append(m.acks, "term")
+    return nil
+}
+
+type fakePublisher struct {
+    published []*natsgo.Msg
+}
+
+func (p *fakePublisher) PublishMsg(_ context.Context, msg *natsgo.Msg, _ ...jetstream.PublishOpt) (*jetstream.PubAck, error) {
+    p.published = append(p.published, msg)
+    return &jetstream.PubAck{}, nil
+}
+
+func newTestAcknowledger(msg *fakeMsg, publisher *fakePublisher) *Acknowledger {
+    backoff := []time.Duration{time.Second, time.Minute}
+    return NewAcknowledger(msg, publisher, 3, backoff, testDeadLetterSubject, slog.New(slog.DiscardHandler))
+}
+
+func TestAcknowledger_Nack(t *testing.T) {
+    tests := []struct {
+        name           string
+        numDelivered   uint64
+        requeue        bool
+        wantAcks       []string
+        wantDelay      time.Duration
+        wantDeadLetter bool
+    }{
+        {name: "requeued", numDelivered: 1, requeue: true, wantAcks: []string{"nak"}, wantDelay: time.Second},
+        {name: "requeued past the backoff", numDelivered: 2, requeue: true, wantAcks: []string{"nak"}, wantDelay: time.Minute},
+        {name: "requeued max deliver times", numDelivered: 3, requeue: true, wantAcks: []string{"term"}, wantDeadLetter: true},
+        {name: "not requeued", numDelivered: 1, requeue: false, wantAcks: []string{"term"}, wantDeadLetter: true},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            msg := &fakeMsg{numDelivered: tt.numDelivered}
+            publisher := &fakePublisher{}
+            err := newTestAcknowledger(msg, publisher).Nack(messages.NackOpts{
+                Requeue:      tt.requeue,
+                ErrorCode:    werrors.UnprocessableMessageErrorCode,
+                ErrorMessage: "invalid payment",
+            })
+            require.NoError(t, err)
+            require.Equal(t, tt.wantAcks, msg.acks)
+            require.Equal(t, tt.wantDelay, msg.nakDelay)
+            if !tt.wantDeadLetter {
+                require.Empty(t, publisher.published)
+                return
+            }
+            require.Len(t, publisher.published, 1)
+            deadLetter := publisher.published[0]
+            require.Equal(t, testDeadLetterSubject, deadLetter.Subject)
+            require.Equal(t, "invalid payment", deadLetter.Header.Get(ErrorMessageHeader))
+            require.Equal(t, "payments.events.payment.created", deadLetter.Header.Get(OriginalSubjectHeader))
+            require.Equal(t, "1", deadLetter.Header.Get("Nats-Msg-Id"))
+        })
+    }
+}
+
+func TestAcknowledger_OnlyFirstResultCounts(t *testing.T) {
+    msg := &fakeMsg{numDelivered: 1}
+    acknowledger := newTestAcknowledger(msg, &fakePublisher{})
+    require.NoError(t, acknowledger.Nack(messages.NackOpts{Requeue: true, ErrorCode: werrors.TimeoutErrorCode}))
+    require.NoError(t, acknowledger.Ack())
+    require.Equal(t, []string{"nak"}, msg.acks)
+}
@@ -0,0 +1,154 @@
+// Package nats consumes events from NATS JetStream, as an alternative to
+// RabbitMQ for the event traffic the platform moved to NATS.
+package nats
+
+import (
+    "context"
+    "fmt"
+    "log/slog"
+    "time"
+
+    natsgo "github.com/nats-io/nats.go"
+    "github.com/nats-io/nats.go/jetstream"
+    "github.com/walletera/dinopay-gateway/pkg/logattr"
+    "github.com/walletera/eventskit/messages"
+)
+
+const (
+    DefaultMaxDeliver = 10
+    DefaultAckWait    = 10 * time.Minute
+
+    setupTimeout = 30 * time.Second
+)
+
+// DefaultRetryBackoff is how long the requeued messages wait before their next
+// delivery, by the number of deliveries so far. The last one is used after that.
+var DefaultRetryBackoff = []time.Duration{time.Second, 5 * time.Second, 30 * time.Second, time.Minute}
+
+var _ messages.Consumer = (*MessagesConsumer)(nil)
+
+// MessagesConsumer consumes the messages of a JetStream stream through a durable
+// consumer. Every replica consuming with the same durable name shares it, so each
+// message is delivered to a single replica, like the members of a Kafka consumer group.
+//
+// A message is only acknowledged to JetStream when it is acked, which is what moves
+// the durable consumer past it. A requeued message is delivered again after a backoff
+// until it was delivered max deliver times, then it is dead lettered like a message
+// that is not requeued.
+type MessagesConsumer struct {
+    url               string
+    streamName        string
+    durableName       string
+    subjects          []string
+    maxDeliver        int
+    ackWait           time.Duration
+    retryBackoff      []time.Duration
+    deadLetterSubject string
+    logger            *slog.Logger
+    conn              *natsgo.Conn
+    consumeCtx        jetstream.ConsumeContext
+}
+
+type Opt func(mc *MessagesConsumer)
+
+// WithSubjects restricts the messages to the ones published to the subjects
+func WithSubjects(subjects ...string) Opt {
+    return func(mc *MessagesConsumer) { mc.subjects = subjects }
+}
+
+// WithMaxDeliver sets how many times a message is delivered before it is dead lettered
+func WithMaxDeliver(maxDeliver int) Opt {
+    return func(mc *MessagesConsumer) { mc.maxDeliver = maxDeliver }
+}
+
+// WithAckWait sets how long JetStream waits for the ack before delivering the
+// message again, it should outlive the processing timeout of the messages
+func WithAckWait(ackWait time.Duration) Opt {
+    return func(mc *MessagesConsumer) { mc.ackWait = ackWait }
+}
+
+// WithRetryBackoff sets how long the requeued messages wait by number of deliveries
+func WithRetryBackoff(backoff ...time.Duration) Opt {
+    return func(mc *MessagesConsumer) { mc.retryBackoff = backoff }
+}
+
+// WithDeadLetterSubject republishes the dead lettered messages to subject, with the
+// reason in their headers. Without it they are only terminated, and JetStream
+// reports them with its terminated message advisory.
+func WithDeadLetterSubject(subject string) Opt {
+    return func(mc *MessagesConsumer) { mc.deadLetterSubject = subject }
+}
+
+func NewMessagesConsumer(url string, streamName string, durableName string, logger *slog.Logger, opts ...Opt) *MessagesConsumer {
+    mc := &MessagesConsumer{
+        url:          url,
+        streamName:   streamName,
+        durableName:  durableName,
+        maxDeliver:   DefaultMaxDeliver,
+        ackWait:      DefaultAckWait,
+        retryBackoff: DefaultRetryBackoff,
+        logger: logger.With(
+            logattr.Component("nats.MessagesConsumer"),
+            slog.String("stream", streamName),
+            slog.String("durable", durableName),
+        ),
+    }
+    for _, opt := range opts {
+        opt(mc)
+    }
+    return mc
+}
+
+func (mc *MessagesConsumer) Consume() (<-chan messages.Message, error) {
+    ctx, cancel := context.WithTimeout(context.Background(), setupTimeout)
+    defer cancel()
+
+    conn, err := natsgo.Connect(mc.url)
+    if err != nil {
+        return nil, fmt.Errorf("failed connecting to nats: %w", err)
+    }
+    js, err := jetstream.New(conn)
+    if err != nil {
+        conn.Close()
+        return nil, fmt.Errorf("failed creating jetstream context: %w", err)
+    }
+    consumer, err := js.CreateOrUpdateConsumer(ctx, mc.streamName, jetstream.ConsumerConfig{
+        Durable:        mc.durableName,
+        FilterSubjects: mc.subjects,
+        AckPolicy:      jetstream.AckExplicitPolicy,
+        AckWait:        mc.ackWait,
+        MaxDeliver:     mc.maxDeliver,
+    })
+    if err != nil {
+        conn.Close()
+        return nil, fmt.Errorf("failed creating jetstream consumer %s on stream %s: %w", mc.durableName, mc.streamName, err)
+    }
+
+    messagesCh := make(chan messages.Message)
+    consumeCtx, err := consumer.Consume(func(msg jetstream.Msg) {
+        messagesCh <- messages.NewMessage(msg.Data(), NewAcknowledger(msg, js, mc.maxDeliver, mc.retryBackoff, mc.deadLetterSubject, mc.logger))
+    })
+    if err != nil {
+        conn.Close()
+        return nil, fmt.Errorf("failed consuming from jetstream consumer %s: %w", mc.durableName, err)
+    }
+    go func() {
+        <-consumeCtx.Closed()
+        close(messagesCh)
+    }()
+    mc.conn = conn
+    mc.consumeCtx = consumeCtx
+    return messagesCh, nil
+}
+
+func (mc *MessagesConsumer) Close() error {
+    if mc.consumeCtx == nil {
+        return nil
+    }
+    mc.consumeCtx.Stop()
+    err := mc.conn.Drain()
+    if err != nil {
+        return fmt.Errorf("failed closing nats messages consumer: %w", err)
+    }
+    return nil
+}
@@ -0,0 +1,132 @@
+package operatorapi
+
+import (
+    "bufio"
+    "bytes"
+    "context"
+    "crypto/sha256"
+    "crypto/subtle"
+    "encoding/hex"
+    "errors"
+    "fmt"
+    "net/http"
+    "os"
+    "strings"
+    "sync"
+    "time"
+)
+
+var errUnauthenticated = errors.New("missing or invalid operator token")
+
+// Authenticator tells which operator sent a request
+type Authenticator interface {
+    Authenticate(r *http.Request) (string, error)
+}
+
+// TokensFileAuthenticator authenticates operators with the bearer tokens listed
+// in a file, typically a mounted secret. Every non empty line holds an operator
+// id and the hex encoded sha256 of the operator token, separated by spaces;
+// lines starting with # are ignored. The file is read again whenever its
+// modification time changes, so tokens can be rotated without a restart.
+type TokensFileAuthenticator struct {
+    path string
+
+    mutex   sync.Mutex
+    hashes  map[string]string
+    modTime time.Time
+}
+
+var _ Authenticator = (*TokensFileAuthenticator)(nil)
+
+func NewTokensFileAuthenticator(path string) (*TokensFileAuthenticator, error) {
+    authenticator := &TokensFileAuthenticator{path: path}
+    _, err := authenticator.load()
+    if err != nil {
+        return nil, err
+    }
+    return authenticator, nil
+}
+
+func (a *TokensFileAuthenticator) Authenticate(r *http.Request) (string, error) {
+    token, found := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+    if !found || token == "" {
+        return "", errUnauthenticated
+    }
+    hashes, err := a.load()
+    if err != nil {
+        return "", err
+    }
+    tokenHash := sha256.Sum256([]byte(token))
+    for hash, operator := range hashes {
+        expectedHash, err := hex.DecodeString(hash)
+        if err != nil {
+            continue
+        }
+        if subtle.ConstantTimeCompare(tokenHash[:], expectedHash) == 1 {
+            return operator, nil
+        }
+    }
+    return "", errUnauthenticated
+}
+
+// load returns the operators by token hash, reading the file again when it changed
+func (a *TokensFileAuthenticator) load() (map[string]string, error) {
+    a.mutex.Lock()
+    defer a.mutex.Unlock()
+    fileInfo, err := os.Stat(a.path)
+    if err != nil {
+        return nil, fmt.Errorf("failed reading operator tokens file %s: %w", a.path, err)
+    }
+    if a.hashes != nil && fileInfo.ModTime().Equal(a.modTime) {
+        return a.hashes, nil
+    }
+    content, err := os.ReadFile(a.path)
+    if err != nil {
+        return nil, fmt.Errorf("failed reading operator tokens file %s: %w", a.path, err)
+    }
+    hashes := make(map[string]string)
+    scanner := bufio.NewScanner(bytes.NewReader(content))
+    for lineNumber := 1; scanner.Scan(); lineNumber++ {
+        line := strings.TrimSpace(scanner.Text())
+        if line == "" || strings.HasPrefix(line, "#") {
+            continue
+        }
+        fields := strings.Fields(line)
+        if len(fields) != 2 {
+            return nil, fmt.Errorf("invalid operator tokens file %s: line %d must hold an operator and a token hash", a.path, lineNumber)
+        }
+        hash := strings.ToLower(fields[1])
+        if _, err := hex.DecodeString(hash); err != nil || len(hash) != 2*sha256.Size {
+            return nil, fmt.Errorf("invalid operator tokens file %s: line %d has an invalid sha256 token hash", a.path, lineNumber)
+        }
+        hashes[hash] = fields[0]
+    }
+    a.hashes = hashes
+    a.modTime = fileInfo.ModTime()
+    return a.hashes, nil
+}
+
+type operatorContextKey struct{}
+
+// authenticated rejects the requests of unknown operators and
+// makes the operator available to the handler through operatorOf
+func (s *Server) authenticated(next http.Handler) http.Handler {
+    return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        if s.authenticator == nil {
+            s.writeUnauthorized(w, errUnauthenticated)
+            return
+        }
+        operator, err := s.authenticator.Authenticate(r)
+        if err != nil {
+            s.writeUnauthorized(w, err)
+            return
+        }
+        next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), operatorContextKey{}, operator)))
+    })
+}
+
+// operatorOf returns the authenticated operator that sent the request
+func operatorOf(r *http.Request) string {
+    operator, _ := r.Context().Value(operatorContextKey{}).(string)
+    return operator
+}
@@ -0,0 +1,129 @@
+package operatorapi
+
+import (
+    "crypto/sha256"
+    "encoding/hex"
+    "fmt"
+    "log/slog"
+    "net/http"
+    "net/http/httptest"
+    "os"
+    "path/filepath"
+    "testing"
+    "time"
+
+    "github.com/stretchr/testify/require"
+)
+
+func tokenHash(token string) string {
+    hash := sha256.Sum256([]byte(token))
+    return hex.EncodeToString(hash[:])
+}
+
+func writeTokensFile(t *testing.T, path string, tokens map[string]string, modTime time.Time) {
+    content := "# operator token-sha256\n"
+    for operator, token := range tokens {
+        content += fmt.Sprintf("%s %s\n", operator, tokenHash(token))
+    }
+    require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+    require.NoError(t, os.Chtimes(path, modTime, modTime))
+}
+
+func TestTokensFileAuthenticator_Authenticate(t *testing.T) {
+    path := filepath.Join(t.TempDir(), "operator-tokens")
+    writeTokensFile(t, path, map[string]string{"alice": "alice-token", "bob": "bob-token"}, time.Now().Add(-time.Hour))
+    authenticator, err := NewTokensFileAuthenticator(path)
+    require.NoError(t, err)
+
+    authenticate := func(authorization string) (string, error) {
+        req := httptest.NewRequest(http.MethodGet, "/suspense/inbound-payments", nil)
+        if authorization != "" {
+            req.Header.Set("Authorization", authorization)
+        }
+        return authenticator.Authenticate(req)
+    }
+
+    operator, err := authenticate("Bearer alice-token")
+    require.NoError(t, err)
+    require.Equal(t, "alice", operator)
+    operator, err = authenticate("Bearer bob-token")
+    require.NoError(t, err)
+    require.Equal(t, "bob", operator)
+    _, err = authenticate("Bearer unknown-token")
+    require.ErrorIs(t, err, errUnauthenticated)
+    _, err = authenticate("Basic YWxpY2U6YWxpY2UtdG9rZW4=")
+    require.ErrorIs(t, err, errUnauthenticated)
+    _, err = authenticate("")
+    require.ErrorIs(t, err, errUnauthenticated)
+
+    // rotating the tokens doesn't require a restart
+    writeTokensFile(t, path, map[string]string{"alice": "alice-new-token"}, time.Now())
+    operator, err = authenticate("Bearer alice-new-token")
+    require.NoError(t, err)
+    require.Equal(t, "alice", operator)
+    _, err = authenticate("Bearer bob-token")
+    require.ErrorIs(t, err, errUnauthenticated)
+}
+
+func TestNewTokensFileAuthenticator_InvalidFile(t *testing.T) {
+    path := filepath.Join(t.TempDir(), "operator-tokens")
+    require.NoError(t, os.WriteFile(path, []byte("alice not-a-sha256\n"), 0o600))
+    _, err := NewTokensFileAuthenticator(path)
+    require.Error(t, err)
+
+    _, err = NewTokensFileAuthenticator(filepath.Join(t.TempDir(), "missing"))
+    require.Error(t, err)
+}
+
+func TestServer_Authenticated(t *testing.T) {
+    path := filepath.Join(t.TempDir(), "operator-tokens")
+    writeTokensFile(t, path, map[string]string{"alice": "alice-token"}, time.Now())
+    authenticator, err := NewTokensFileAuthenticator(path)
+    require.NoError(t, err)
+
+    var gotOperator string
+    handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        gotOperator = operatorOf(r)
+        w.WriteHeader(http.StatusAccepted)
+    })
+    tests := []struct {
+        name          string
+        authenticator Authenticator
+        authorization string
+        wantStatus    int
+        wantOperator  string
+    }{
+        {
+            name:          "known operator",
+            authenticator: authenticator,
+            authorization: "Bearer alice-token",
+            wantStatus:    http.StatusAccepted,
+            wantOperator:  "alice",
+        },
+        {
+            name:          "unknown token",
+            authenticator: authenticator,
+            authorization: "Bearer mallory-token",
+            wantStatus:    http.StatusUnauthorized,
+        },
+        {
+            name:          "no authenticator configured",
+            authorization: "Bearer alice-token",
+            wantStatus:    http.StatusUnauthorized,
+        },
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            gotOperator = ""
+            s := &Server{authenticator: tt.authenticator, logger: slog.New(slog.DiscardHandler)}
+            req := httptest.NewRequest(http.MethodPost, "/outbound-payments/some-id/approve", nil)
+            req.Header.Set("Authorization", tt.authorization)
+            recorder := httptest.NewRecorder()
+
+            s.authenticated(handler).ServeHTTP(recorder, req)
+
+            require.Equal(t, tt.wantStatus, recorder.Code)
+            require.Equal(t, tt.wantOperator, gotOperator)
+        })
+    }
+}
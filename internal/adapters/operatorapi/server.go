@@ -0,0 +1,447 @@
+package operatorapi
+
+import (
+    "context"
+    "encoding/json"
+    "errors"
+    "fmt"
+    "io"
+    "log/slog"
+    "net/http"
+
+    "github.com/google/uuid"
+    "github.com/walletera/dinopay-gateway/internal/adapters/accounts"
+    "github.com/walletera/dinopay-gateway/internal/domain/events/walletera/gateway/inbound"
+    "github.com/walletera/dinopay-gateway/internal/domain/events/walletera/gateway/outbound"
+    "github.com/walletera/dinopay-gateway/internal/domain/reconciliation"
+    "github.com/walletera/dinopay-gateway/pkg/logattr"
+    "github.com/walletera/werrors"
+)
+
+type assignRequest struct {
+    CustomerId uuid.UUID `json:"customerId"`
+}
+
+type returnRequest struct {
+    Reason string `json:"reason"`
+}
+
+type rejectRequest struct {
+    Reason string `json:"reason"`
+}
+
+type errorResponse struct {
+    Message string `json:"message"`
+}
+
+// AccountsCache is the cache of the Accounts lookups done for inbound payments
+type AccountsCache interface {
+    Stats() accounts.Stats
+    Invalidate(accountNumber string)
+}
+
+// maxStatementSize limits the size of the uploaded DinoPay statements
+const maxStatementSize = 32 << 20
+
+// Server exposes the endpoints operators use to resolve the inbound
+// payments that are waiting in suspense, to release the held deposits,
+// to return deposits, to resolve
+// the outbound payments whose outcome on DinoPay is unknown, to review
+// the held outbound payments, to approve the high-value ones and to
+// reconcile DinoPay statements. Every request must be authenticated,
+// and the actions are recorded on behalf of the authenticated operator.
+type Server struct {
+    httpServer             *http.Server
+    authenticator          Authenticator
+    suspenseService        *inbound.SuspenseService
+    returnService          *inbound.ReturnService
+    inboundReviewService   *inbound.ReviewService
+    accountsCache          AccountsCache
+    unknownOutcomeResolver *outbound.UnknownOutcomeResolver
+    reviewService          *outbound.ReviewService
+    approvalService        *outbound.ApprovalService
+    reconciler             *reconciliation.Reconciler
+    logger                 *slog.Logger
+}
+
+// NewServer creates the operator api. A nil authenticator rejects every request.
+func NewServer(
+    port int,
+    authenticator Authenticator,
+    suspenseService *inbound.SuspenseService,
+    returnService *inbound.ReturnService,
+    inboundReviewService *inbound.ReviewService,
+    accountsCache AccountsCache,
+    unknownOutcomeResolver *outbound.UnknownOutcomeResolver,
+    reviewService *outbound.ReviewService,
+    approvalService *outbound.ApprovalService,
+    reconciler *reconciliation.Reconciler,
+    logger *slog.Logger,
+) *Server {
+    s := &Server{
+        authenticator:          authenticator,
+        suspenseService:        suspenseService,
+        returnService:          returnService,
+        inboundReviewService:   inboundReviewService,
+        accountsCache:          accountsCache,
+        unknownOutcomeResolver: unknownOutcomeResolver,
+        reviewService:          reviewService,
+        approvalService:        approvalService,
+        reconciler:             reconciler,
+        logger:                 logger.With(logattr.Component("operatorapi.Server")),
+    }
+    mux := http.NewServeMux()
+    mux.HandleFunc("GET /suspense/inbound-payments", s.listUnmatched)
+    mux.HandleFunc("POST /suspense/inbound-payments/{id}/assign", s.assign)
+    mux.HandleFunc("POST /suspense/inbound-payments/{id}/return", s.returnToSender)
+    mux.HandleFunc("POST /inbound-payments/{id}/return", s.requestReturn)
+    mux.HandleFunc("GET /inbound-payments/held", s.listHeldInbound)
+    mux.HandleFunc("POST /inbound-payments/{id}/release", s.releaseInbound)
+    mux.HandleFunc("GET /accounts-cache/stats", s.accountsCacheStats)
+    mux.HandleFunc("DELETE /accounts-cache/{accountNumber}", s.invalidateAccountsCache)
+    mux.HandleFunc("GET /outbound-payments/unknown-outcome", s.listUnknownOutcome)
+    mux.HandleFunc("POST /outbound-payments/{id}/resolve", s.resolveOutcome)
+    mux.HandleFunc("GET /outbound-payments/held", s.listHeld)
+    mux.HandleFunc("POST /outbound-payments/{id}/release", s.release)
+    mux.HandleFunc("POST /outbound-payments/{id}/reject", s.reject)
+    mux.HandleFunc("GET /outbound-payments/awaiting-approval", s.listAwaitingApproval)
+    mux.HandleFunc("POST /outbound-payments/{id}/approve", s.approve)
+    mux.HandleFunc("POST /outbound-payments/{id}/reject-approval", s.rejectApproval)
+    mux.HandleFunc("POST /reconciliation/statements/{name}", s.reconcileStatement)
+    s.httpServer = &http.Server{
+        Addr:    fmt.Sprintf(":%d", port),
+        Handler: s.authenticated(mux),
+    }
+    return s
+}
+
+func (s *Server) Start() {
+    go func() {
+        err := s.httpServer.ListenAndServe()
+        if err != nil && !errors.Is(err, http.ErrServerClosed) {
+            s.logger.Error("operator api server failed", logattr.Error(err.Error()))
+        }
+    }()
+}
+
+func (s *Server) Close(ctx context.Context) error {
+    return s.httpServer.Shutdown(ctx)
+}
+
+func (s *Server) listUnmatched(w http.ResponseWriter, r *http.Request) {
+    payments, werr := s.suspenseService.ListUnmatched(r.Context())
+    if werr != nil {
+        s.writeError(w, werr)
+        return
+    }
+    if payments == nil {
+        payments = []*inbound.Payment{}
+    }
+    s.writeJSON(w, http.StatusOK, payments)
+}
+
+func (s *Server) assign(w http.ResponseWriter, r *http.Request) {
+    dinopayPaymentId, ok := s.parseId(w, r)
+    if !ok {
+        return
+    }
+    var req assignRequest
+    if !s.decode(w, r, &req) {
+        return
+    }
+    if req.CustomerId == uuid.Nil {
+        s.writeError(w, werrors.NewValidationError("customerId is required"))
+        return
+    }
+    werr := s.suspenseService.Assign(r.Context(), dinopayPaymentId, req.CustomerId, operatorOf(r))
+    if werr != nil {
+        s.writeError(w, werr)
+        return
+    }
+    s.logger.Info("inbound payment assigned from suspense",
+        logattr.DinopayPaymentId(dinopayPaymentId.String()),
+        slog.String("assigned_by", operatorOf(r)),
+    )
+    w.WriteHeader(http.StatusAccepted)
+}
+
+func (s *Server) returnToSender(w http.ResponseWriter, r *http.Request) {
+    dinopayPaymentId, ok := s.parseId(w, r)
+    if !ok {
+        return
+    }
+    var req returnRequest
+    if !s.decode(w, r, &req) {
+        return
+    }
+    if req.Reason == "" {
+        s.writeError(w, werrors.NewValidationError("reason is required"))
+        return
+    }
+    werr := s.suspenseService.Return(r.Context(), dinopayPaymentId, req.Reason, operatorOf(r))
+    if werr != nil {
+        s.writeError(w, werr)
+        return
+    }
+    s.logger.Info("inbound payment return requested from suspense",
+        logattr.DinopayPaymentId(dinopayPaymentId.String()),
+        logattr.Reason(req.Reason),
+        slog.String("requested_by", operatorOf(r)),
+    )
+    w.WriteHeader(http.StatusAccepted)
+}
+
+func (s *Server) requestReturn(w http.ResponseWriter, r *http.Request) {
+    dinopayPaymentId, ok := s.parseId(w, r)
+    if !ok {
+        return
+    }
+    var req returnRequest
+    if !s.decode(w, r, &req) {
+        return
+    }
+    if req.Reason == "" {
+        s.writeError(w, werrors.NewValidationError("reason is required"))
+        return
+    }
+    werr := s.returnService.RequestReturn(r.Context(), dinopayPaymentId, req.Reason, operatorOf(r))
+    if werr != nil {
+        s.writeError(w, werr)
+        return
+    }
+    s.logger.Info("inbound payment return requested",
+        logattr.DinopayPaymentId(dinopayPaymentId.String()),
+        logattr.Reason(req.Reason),
+        slog.String("requested_by", operatorOf(r)),
+    )
+    w.WriteHeader(http.StatusAccepted)
+}
+
+func (s *Server) listHeldInbound(w http.ResponseWriter, r *http.Request) {
+    payments, werr := s.inboundReviewService.ListHeld(r.Context())
+    if werr != nil {
+        s.writeError(w, werr)
+        return
+    }
+    if payments == nil {
+        payments = []*inbound.Payment{}
+    }
+    s.writeJSON(w, http.StatusOK, payments)
+}
+
+func (s *Server) releaseInbound(w http.ResponseWriter, r *http.Request) {
+    dinopayPaymentId, ok := s.parseId(w, r)
+    if !ok {
+        return
+    }
+    werr := s.inboundReviewService.Release(r.Context(), dinopayPaymentId, operatorOf(r))
+    if werr != nil {
+        s.writeError(w, werr)
+        return
+    }
+    s.logger.Info("held inbound payment released",
+        logattr.DinopayPaymentId(dinopayPaymentId.String()),
+        slog.String("released_by", operatorOf(r)),
+    )
+    w.WriteHeader(http.StatusAccepted)
+}
+
+func (s *Server) accountsCacheStats(w http.ResponseWriter, _ *http.Request) {
+    s.writeJSON(w, http.StatusOK, s.accountsCache.Stats())
+}
+
+func (s *Server) invalidateAccountsCache(w http.ResponseWriter, r *http.Request) {
+    s.accountsCache.Invalidate(r.PathValue("accountNumber"))
+    w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) listUnknownOutcome(w http.ResponseWriter, r *http.Request) {
+    payments, werr := s.unknownOutcomeResolver.ListUnresolved(r.Context())
+    if werr != nil {
+        s.writeError(w, werr)
+        return
+    }
+    if payments == nil {
+        payments = []*outbound.UnknownOutcomePayment{}
+    }
+    s.writeJSON(w, http.StatusOK, payments)
+}
+
+func (s *Server) resolveOutcome(w http.ResponseWriter, r *http.Request) {
+    paymentId, err := uuid.Parse(r.PathValue("id"))
+    if err != nil {
+        s.writeError(w, werrors.NewValidationError("invalid outbound payment id"))
+        return
+    }
+    werr := s.unknownOutcomeResolver.ResolveById(r.Context(), paymentId)
+    if werr != nil {
+        s.writeError(w, werr)
+        return
+    }
+    s.logger.Info("outbound payment resolution requested",
+        logattr.PaymentId(paymentId.String()),
+        slog.String("requested_by", operatorOf(r)),
+    )
+    w.WriteHeader(http.StatusAccepted)
+}
+
+func (s *Server) listHeld(w http.ResponseWriter, r *http.Request) {
+    payments, werr := s.reviewService.ListHeld(r.Context())
+    if werr != nil {
+        s.writeError(w, werr)
+        return
+    }
+    if payments == nil {
+        payments = []*outbound.HeldPayment{}
+    }
+    s.writeJSON(w, http.StatusOK, payments)
+}
+
+func (s *Server) release(w http.ResponseWriter, r *http.Request) {
+    paymentId, err := uuid.Parse(r.PathValue("id"))
+    if err != nil {
+        s.writeError(w, werrors.NewValidationError("invalid outbound payment id"))
+        return
+    }
+    werr := s.reviewService.Release(r.Context(), paymentId, operatorOf(r))
+    if werr != nil {
+        s.writeError(w, werr)
+        return
+    }
+    w.WriteHeader(http.StatusAccepted)
+}
+
+func (s *Server) reject(w http.ResponseWriter, r *http.Request) {
+    paymentId, err := uuid.Parse(r.PathValue("id"))
+    if err != nil {
+        s.writeError(w, werrors.NewValidationError("invalid outbound payment id"))
+        return
+    }
+    var req rejectRequest
+    if !s.decode(w, r, &req) {
+        return
+    }
+    werr := s.reviewService.Reject(r.Context(), paymentId, operatorOf(r), req.Reason)
+    if werr != nil {
+        s.writeError(w, werr)
+        return
+    }
+    w.WriteHeader(http.StatusAccepted)
+}
+
+func (s *Server) listAwaitingApproval(w http.ResponseWriter, r *http.Request) {
+    payments, werr := s.approvalService.ListAwaitingApproval(r.Context())
+    if werr != nil {
+        s.writeError(w, werr)
+        return
+    }
+    if payments == nil {
+        payments = []*outbound.HeldPayment{}
+    }
+    s.writeJSON(w, http.StatusOK, payments)
+}
+
+func (s *Server) approve(w http.ResponseWriter, r *http.Request) {
+    paymentId, err := uuid.Parse(r.PathValue("id"))
+    if err != nil {
+        s.writeError(w, werrors.NewValidationError("invalid outbound payment id"))
+        return
+    }
+    werr := s.approvalService.Approve(r.Context(), paymentId, operatorOf(r))
+    if werr != nil {
+        s.writeError(w, werr)
+        return
+    }
+    w.WriteHeader(http.StatusAccepted)
+}
+
+func (s *Server) rejectApproval(w http.ResponseWriter, r *http.Request) {
+    paymentId, err := uuid.Parse(r.PathValue("id"))
+    if err != nil {
+        s.writeError(w, werrors.NewValidationError("invalid outbound payment id"))
+        return
+    }
+    var req rejectRequest
+    if !s.decode(w, r, &req) {
+        return
+    }
+    werr := s.approvalService.Reject(r.Context(), paymentId, operatorOf(r), req.Reason)
+    if werr != nil {
+        s.writeError(w, werr)
+        return
+    }
+    w.WriteHeader(http.StatusAccepted)
+}
+
+// reconcileStatement reconciles the statement in the request body. The
+// extension of the statement name (.csv or .json) tells its format.
+func (s *Server) reconcileStatement(w http.ResponseWriter, r *http.Request) {
+    name := r.PathValue("name")
+    content, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxStatementSize))
+    if err != nil {
+        s.writeError(w, werrors.NewValidationError("failed reading statement: "+err.Error()))
+        return
+    }
+    statement, err := reconciliation.ParseStatement(name, content)
+    if err != nil {
+        s.writeError(w, werrors.NewValidationError(err.Error()))
+        return
+    }
+    report, werr := s.reconciler.Reconcile(r.Context(), statement)
+    if werr != nil {
+        s.writeError(w, werr)
+        return
+    }
+    s.writeJSON(w, http.StatusOK, report)
+}
+
+func (s *Server) parseId(w http.ResponseWriter, r *http.Request) (uuid.UUID, bool) {
+    id, err := uuid.Parse(r.PathValue("id"))
+    if err != nil {
+        s.writeError(w, werrors.NewValidationError("invalid inbound payment id"))
+        return uuid.Nil, false
+    }
+    return id, true
+}
+
+func (s *Server) decode(w http.ResponseWriter, r *http.Request, v any) bool {
+    err := json.NewDecoder(r.Body).Decode(v)
+    if err != nil {
+        s.writeError(w, werrors.NewValidationError("invalid request body"))
+        return false
+    }
+    return true
+}
+
+func (s *Server) writeError(w http.ResponseWriter, werr werrors.WError) {
+    status := http.StatusInternalServerError
+    switch werr.Code() {
+    case werrors.ResourceNotFoundErrorCode:
+        status = http.StatusNotFound
+    case werrors.ValidationErrorCode:
+        status = http.StatusBadRequest
+    case werrors.WrongResourceVersionErrorCode:
+        status = http.StatusConflict
+    }
+    if status == http.StatusInternalServerError {
+        s.logger.Error("operator api request failed", logattr.Error(werr.Error()))
+    }
+    s.writeJSON(w, status, errorResponse{Message: werr.Message()})
+}
+
+func (s *Server) writeUnauthorized(w http.ResponseWriter, err error) {
+    if !errors.Is(err, errUnauthenticated) {
+        s.logger.Error("failed authenticating operator", logattr.Error(err.Error()))
+    }
+    w.Header().Set("WWW-Authenticate", "Bearer")
+    s.writeJSON(w, http.StatusUnauthorized, errorResponse{Message: errUnauthenticated.Error()})
+}
+
+func (s *Server) writeJSON(w http.ResponseWriter, status int, v any) {
+    w.Header().Set("Content-Type", "application/json")
+    w.WriteHeader(status)
+    err := json.NewEncoder(w).Encode(v)
+    if err != nil {
+        s.logger.Error("failed writing operator api response", logattr.Error(err.Error()))
+    }
+}
@@ -0,0 +1,190 @@
+package postgres
+
+import (
+    "context"
+    "database/sql"
+    "errors"
+    "strings"
+    "time"
+
+    "github.com/lib/pq"
+    "github.com/walletera/eventskit/events"
+    "github.com/walletera/eventskit/eventsourcing"
+    "github.com/walletera/werrors"
+)
+
+const (
+    // categoryStreamPrefix names the stream of all the events of a category,
+    // the same way the EventStoreDB by-category projection does
+    categoryStreamPrefix = "$ce-"
+
+    // appendLockKey is the transaction level advisory lock taken by every
+    // append, see DB.AppendEvents
+    appendLockKey = 7264930
+
+    uniqueViolationCode = "23505"
+)
+
+var _ eventsourcing.DB = (*DB)(nil)
+
+// DB is an eventsourcing.DB with optimistic concurrency on the stream version
+type DB struct {
+    db *sql.DB
+}
+
+func NewDB(db *sql.DB) *DB {
+    return &DB{db: db}
+}
+
+// AppendEvents appends the events to the stream when it is at the expected version.
+// The appends are serialized with an advisory lock, so the events are committed in
+// the order of their global position and a subscription never skips an event that
+// was committed after a later one.
+func (db *DB) AppendEvents(ctx context.Context, streamName string, expectedAggregateVersion eventsourcing.ExpectedAggregateVersion, eventsData ...events.EventData) (uint64, werrors.WError) {
+    tx, err := db.db.BeginTx(ctx, nil)
+    if err != nil {
+        return 0, werrors.NewRetryableInternalError("failed beginning append transaction: " + err.Error())
+    }
+    defer tx.Rollback()
+
+    _, err = tx.ExecContext(ctx, `SELECT pg_advisory_xact_lock($1)`, appendLockKey)
+    if err != nil {
+        return 0, werrors.NewRetryableInternalError("failed locking events table: " + err.Error())
+    }
+
+    var currentVersion sql.NullInt64
+    err = tx.QueryRowContext(ctx, `SELECT max(stream_version) FROM events WHERE stream_name = $1`, streamName).Scan(&currentVersion)
+    if err != nil {
+        return 0, werrors.NewRetryableInternalError("failed reading version of stream " + streamName + ": " + err.Error())
+    }
+    if expectedAggregateVersion.IsNew && currentVersion.Valid {
+        return 0, werrors.NewResourceAlreadyExistError("stream " + streamName + " already exists")
+    }
+    if !expectedAggregateVersion.IsNew && (!currentVersion.Valid || uint64(currentVersion.Int64) != expectedAggregateVersion.Version) {
+        return 0, werrors.NewWrongResourceVersionError("stream " + streamName + " is not at the expected version")
+    }
+
+    nextVersion := uint64(currentVersion.Int64 + 1)
+    if !currentVersion.Valid {
+        nextVersion = 0
+    }
+    category := categoryOf(streamName)
+    for _, event := range eventsData {
+        data, err := event.Serialize()
+        if err != nil {
+            return 0, werrors.NewNonRetryableInternalError(err.Error())
+        }
+        _, err = tx.ExecContext(
+            ctx,
+            `INSERT INTO events (stream_name, stream_version, category, event_type, data) VALUES ($1, $2, $3, $4, $5)`,
+            streamName,
+            nextVersion,
+            category,
+            event.Type(),
+            data,
+        )
+        if err != nil {
+            return 0, mapAppendErrorToWalleteraError(err, expectedAggregateVersion)
+        }
+        nextVersion++
+    }
+
+    err = tx.Commit()
+    if err != nil {
+        return 0, mapAppendErrorToWalleteraError(err, expectedAggregateVersion)
+    }
+    return nextVersion - 1, nil
+}
+
+// ReadEvents reads the events of a stream, or of a whole category when
+// streamName is the category stream, e.g. $ce-outboundPayment. The version
+// of the events of a category is their position in the category.
+func (db *DB) ReadEvents(ctx context.Context, streamName string) ([]eventsourcing.RetrievedEvent, werrors.WError) {
+    var rows *sql.Rows
+    var err error
+    if strings.HasPrefix(streamName, categoryStreamPrefix) {
+        rows, err = db.db.QueryContext(
+            ctx,
+            `SELECT data, row_number() OVER (ORDER BY global_position) - 1 FROM events WHERE category = $1 ORDER BY global_position`,
+            categoryOf(streamName),
+        )
+    } else {
+        rows, err = db.db.QueryContext(
+            ctx,
+            `SELECT data, stream_version FROM events WHERE stream_name = $1 ORDER BY stream_version`,
+            streamName,
+        )
+    }
+    if err != nil {
+        return nil, werrors.NewRetryableInternalError("failed reading stream " + streamName + ": " + err.Error())
+    }
+    defer rows.Close()
+
+    var retrievedEvents []eventsourcing.RetrievedEvent
+    for rows.Next() {
+        var retrievedEvent eventsourcing.RetrievedEvent
+        err = rows.Scan(&retrievedEvent.RawEvent, &retrievedEvent.AggregateVersion)
+        if err != nil {
+            return nil, werrors.NewRetryableInternalError("failed reading stream " + streamName + ": " + err.Error())
+        }
+        retrievedEvents = append(retrievedEvents, retrievedEvent)
+    }
+    err = rows.Err()
+    if err != nil {
+        return nil, werrors.NewRetryableInternalError("failed reading stream " + streamName + ": " + err.Error())
+    }
+    if len(retrievedEvents) == 0 {
+        return nil, werrors.NewResourceNotFoundError("stream " + streamName + " not found")
+    }
+    return retrievedEvents, nil
+}
+
+// SetStreamMaxCount deletes all but the last maxCount events of the stream.
+// The stream version keeps growing, only the older events are deleted.
+func (db *DB) SetStreamMaxCount(ctx context.Context, streamName string, maxCount uint64) werrors.WError {
+    _, err := db.db.ExecContext(
+        ctx,
+        `DELETE FROM events WHERE stream_name = $1 AND stream_version <= (SELECT max(stream_version) FROM events WHERE stream_name = $1) - $2`,
+        streamName,
+        int64(maxCount),
+    )
+    if err != nil {
+        return werrors.NewRetryableInternalError("failed trimming stream " + streamName + ": " + err.Error())
+    }
+    return nil
+}
+
+// PurgeCategory deletes the events of the category created before createdBefore
+// and returns how many were deleted. The category must have no subscription.
+func (db *DB) PurgeCategory(ctx context.Context, category string, createdBefore time.Time) (int64, werrors.WError) {
+    result, err := db.db.ExecContext(ctx, `DELETE FROM events WHERE category = $1 AND created_at < $2`, category, createdBefore)
+    if err != nil {
+        return 0, werrors.NewRetryableInternalError("failed purging category " + category + ": " + err.Error())
+    }
+    purged, err := result.RowsAffected()
+    if err != nil {
+        return 0, werrors.NewRetryableInternalError("failed purging category " + category + ": " + err.Error())
+    }
+    return purged, nil
+}
+
+// categoryOf returns the category of a stream, the part of its name before
+// the first dot, or the category of a category stream
+func categoryOf(streamName string) string {
+    if category, found := strings.CutPrefix(streamName, categoryStreamPrefix); found {
+        return category
+    }
+    category, _, _ := strings.Cut(streamName, ".")
+    return category
+}
+
+func mapAppendErrorToWalleteraError(err error, version eventsourcing.ExpectedAggregateVersion) werrors.WError {
+    var pqErr *pq.Error
+    if errors.As(err, &pqErr) && pqErr.Code == uniqueViolationCode {
+        if version.IsNew {
+            return werrors.NewResourceAlreadyExistError(err.Error())
+        }
+        return werrors.NewWrongResourceVersionError(err.Error())
+    }
+    return werrors.NewRetryableInternalError(err.Error())
+}
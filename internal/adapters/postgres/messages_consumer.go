@@ -0,0 +1,338 @@
+package postgres
+
+import (
+    "context"
+    "database/sql"
+    "errors"
+    "fmt"
+    "log/slog"
+    "sync"
+    "time"
+
+    "github.com/walletera/dinopay-gateway/pkg/logattr"
+    "github.com/walletera/eventskit/messages"
+)
+
+const (
+    DefaultPollInterval = 500 * time.Millisecond
+    DefaultMaxRetries   = 10
+    DefaultRetryBackoff = time.Second
+    DefaultBatchSize    = 100
+)
+
+var _ messages.Consumer = (*MessagesConsumer)(nil)
+
+// MessagesConsumer delivers the events of a category to a subscription group,
+// one at a time and in the order they were appended. An acked event moves the
+// group checkpoint past it, a requeued one is delivered again after a backoff
+// and an event that is not requeued, or was requeued more than the max retries,
+// is parked and skipped.
+//
+// The checkpoint row is locked while an event is delivered, so when several
+// replicas consume from the same group only one of them delivers at a time.
+// A group is therefore delivered one event at a time whatever the number of
+// replicas, its throughput is bounded by how fast the handler acks an event.
+type MessagesConsumer struct {
+    db           *sql.DB
+    category     string
+    groupName    string
+    pollInterval time.Duration
+    maxRetries   int
+    retryBackoff time.Duration
+    batchSize    int
+    logger       *slog.Logger
+    cancel       context.CancelFunc
+    done         chan struct{}
+}
+
+type Opt func(mc *MessagesConsumer)
+
+// WithPollInterval sets how often the category is polled for new events once they were all delivered
+func WithPollInterval(interval time.Duration) Opt {
+    return func(mc *MessagesConsumer) { mc.pollInterval = interval }
+}
+
+// WithMaxRetries sets how many times a requeued event is delivered again before it is parked
+func WithMaxRetries(maxRetries int) Opt {
+    return func(mc *MessagesConsumer) { mc.maxRetries = maxRetries }
+}
+
+// WithRetryBackoff sets how long a requeued event waits before being delivered again
+func WithRetryBackoff(backoff time.Duration) Opt {
+    return func(mc *MessagesConsumer) { mc.retryBackoff = backoff }
+}
+
+// WithBatchSize sets how many events are read from the category at once
+func WithBatchSize(batchSize int) Opt {
+    return func(mc *MessagesConsumer) { mc.batchSize = batchSize }
+}
+
+// NewMessagesConsumer creates a consumer of the category stream, e.g. $ce-outboundPayment,
+// for a subscription group created with CreateSubscription
+func NewMessagesConsumer(db *sql.DB, categoryStreamName string, groupName string, logger *slog.Logger, opts ...Opt) *MessagesConsumer {
+    mc := &MessagesConsumer{
+        db:           db,
+        category:     categoryOf(categoryStreamName),
+        groupName:    groupName,
+        pollInterval: DefaultPollInterval,
+        maxRetries:   DefaultMaxRetries,
+        retryBackoff: DefaultRetryBackoff,
+        batchSize:    DefaultBatchSize,
+        logger: logger.With(
+            logattr.Component("postgres.MessagesConsumer"),
+            slog.String("category", categoryStreamName),
+            slog.String("group", groupName),
+        ),
+    }
+    for _, opt := range opts {
+        opt(mc)
+    }
+    return mc
+}
+
+type storedEvent struct {
+    position int64
+    data     []byte
+}
+
+func (mc *MessagesConsumer) Consume() (<-chan messages.Message, error) {
+    var exists bool
+    err := mc.db.QueryRow(
+        `SELECT EXISTS (SELECT 1 FROM subscription_checkpoints WHERE category = $1 AND group_name = $2)`,
+        mc.category,
+        mc.groupName,
+    ).Scan(&exists)
+    if err != nil {
+        return nil, fmt.Errorf("failed reading subscription %s to %s: %w", mc.groupName, mc.category, err)
+    }
+    if !exists {
+        return nil, fmt.Errorf("subscription %s to %s does not exist", mc.groupName, mc.category)
+    }
+
+    ctx, cancel := context.WithCancel(context.Background())
+    mc.cancel = cancel
+    mc.done = make(chan struct{})
+    messagesCh := make(chan messages.Message)
+    go func() {
+        defer close(mc.done)
+        defer close(messagesCh)
+        for {
+            delivered, err := mc.deliverBatch(ctx, messagesCh)
+            if err != nil && ctx.Err() == nil {
+                mc.logger.Error("failed delivering events", logattr.Error(err.Error()))
+            }
+            if delivered > 0 && err == nil {
+                continue
+            }
+            select {
+            case <-ctx.Done():
+                return
+            case <-time.After(mc.pollInterval):
+            }
+        }
+    }()
+    return messagesCh, nil
+}
+
+func (mc *MessagesConsumer) Close() error {
+    if mc.cancel == nil {
+        return nil
+    }
+    mc.cancel()
+    <-mc.done
+    return nil
+}
+
+// deliverBatch delivers the events after the checkpoint and returns how many were handled.
+// Each event is delivered in its own transaction, so the checkpoint row is only locked
+// while one event is handled and the checkpoint moves past every handled event.
+func (mc *MessagesConsumer) deliverBatch(ctx context.Context, messagesCh chan<- messages.Message) (int, error) {
+    var position int64
+    err := mc.db.QueryRowContext(
+        ctx,
+        `SELECT position FROM subscription_checkpoints WHERE category = $1 AND group_name = $2`,
+        mc.category,
+        mc.groupName,
+    ).Scan(&position)
+    if err != nil {
+        return 0, fmt.Errorf("failed reading checkpoint: %w", err)
+    }
+
+    batch, err := mc.readBatch(ctx, position)
+    if err != nil {
+        return 0, err
+    }
+
+    delivered := 0
+    for _, event := range batch {
+        ok, err := mc.deliverNext(ctx, messagesCh, position, event)
+        if err != nil || !ok {
+            return delivered, err
+        }
+        position = event.position
+        delivered++
+    }
+    return delivered, nil
+}
+
+// deliverNext delivers the event that follows the checkpoint at position. It returns false,
+// without delivering the event, when another replica is delivering or moved the checkpoint.
+// The transaction outlives ctx, so the checkpoint of an event handled while the consumer
+// was closed is still saved.
+func (mc *MessagesConsumer) deliverNext(ctx context.Context, messagesCh chan<- messages.Message, position int64, event storedEvent) (bool, error) {
+    tx, err := mc.db.BeginTx(context.Background(), nil)
+    if err != nil {
+        return false, fmt.Errorf("failed beginning transaction: %w", err)
+    }
+    defer tx.Rollback()
+
+    var checkpoint int64
+    err = tx.QueryRow(
+        `SELECT position FROM subscription_checkpoints WHERE category = $1 AND group_name = $2 FOR UPDATE SKIP LOCKED`,
+        mc.category,
+        mc.groupName,
+    ).Scan(&checkpoint)
+    if errors.Is(err, sql.ErrNoRows) {
+        // another replica is delivering
+        return false, nil
+    }
+    if err != nil {
+        return false, fmt.Errorf("failed reading checkpoint: %w", err)
+    }
+    if checkpoint != position {
+        // another replica delivered the event
+        return false, nil
+    }
+
+    err = mc.deliver(ctx, tx, messagesCh, event)
+    if err != nil {
+        return false, err
+    }
+    err = tx.Commit()
+    if err != nil {
+        return false, fmt.Errorf("failed saving checkpoint: %w", err)
+    }
+    return true, nil
+}
+
+func (mc *MessagesConsumer) readBatch(ctx context.Context, position int64) ([]storedEvent, error) {
+    rows, err := mc.db.QueryContext(
+        ctx,
+        `SELECT global_position, data FROM events WHERE category = $1 AND global_position > $2 ORDER BY global_position LIMIT $3`,
+        mc.category,
+        position,
+        mc.batchSize,
+    )
+    if err != nil {
+        return nil, fmt.Errorf("failed reading events: %w", err)
+    }
+    defer rows.Close()
+    var batch []storedEvent
+    for rows.Next() {
+        var event storedEvent
+        err = rows.Scan(&event.position, &event.data)
+        if err != nil {
+            return nil, fmt.Errorf("failed reading events: %w", err)
+        }
+        batch = append(batch, event)
+    }
+    return batch, rows.Err()
+}
+
+// deliver sends the event until it is acked, parked or the consumer is closed
+func (mc *MessagesConsumer) deliver(ctx context.Context, tx *sql.Tx, messagesCh chan<- messages.Message, event storedEvent) error {
+    for retries := 0; ; retries++ {
+        acknowledger := NewAcknowledger()
+        select {
+        case messagesCh <- messages.NewMessage(event.data, acknowledger):
+        case <-ctx.Done():
+            return ctx.Err()
+        }
+        var result ackResult
+        select {
+        case result = <-acknowledger.result:
+        case <-ctx.Done():
+            return ctx.Err()
+        }
+        switch {
+        case result.acked:
+            return mc.saveCheckpoint(tx, event.position)
+        case result.requeue && retries < mc.maxRetries:
+            select {
+            case <-time.After(mc.retryBackoff):
+            case <-ctx.Done():
+                return ctx.Err()
+            }
+        default:
+            return mc.park(tx, event, result, retries)
+        }
+    }
+}
+
+func (mc *MessagesConsumer) park(tx *sql.Tx, event storedEvent, result ackResult, retries int) error {
+    errorMessage := result.errorMessage
+    if result.requeue {
+        errorMessage = fmt.Sprintf("exceeded %d retries: %s", retries, errorMessage)
+    }
+    _, err := tx.Exec(
+        `INSERT INTO parked_events (category, group_name, global_position, error_message) VALUES ($1, $2, $3, $4) ON CONFLICT DO NOTHING`,
+        mc.category,
+        mc.groupName,
+        event.position,
+        errorMessage,
+    )
+    if err != nil {
+        return fmt.Errorf("failed parking event at position %d: %w", event.position, err)
+    }
+    mc.logger.Warn(
+        "event parked",
+        slog.Int64("position", event.position),
+        logattr.Error(errorMessage),
+    )
+    return mc.saveCheckpoint(tx, event.position)
+}
+
+func (mc *MessagesConsumer) saveCheckpoint(tx *sql.Tx, position int64) error {
+    _, err := tx.Exec(
+        `UPDATE subscription_checkpoints SET position = $3 WHERE category = $1 AND group_name = $2`,
+        mc.category,
+        mc.groupName,
+        position,
+    )
+    if err != nil {
+        return fmt.Errorf("failed saving checkpoint at position %d: %w", position, err)
+    }
+    return nil
+}
+
+type ackResult struct {
+    acked        bool
+    requeue      bool
+    errorMessage string
+}
+
+// Acknowledger reports how the delivered event was handled. Only the first
+// ack or nack counts, e.g. a message that timed out is nacked by the
+// processor and may still be acked when its handling finishes.
+type Acknowledger struct {
+    once   sync.Once
+    result chan ackResult
+}
+
+func NewAcknowledger() *Acknowledger {
+    return &Acknowledger{result: make(chan ackResult, 1)}
+}
+
+func (a *Acknowledger) Ack() error {
+    a.once.Do(func() {
+        a.result <- ackResult{acked: true}
+    })
+    return nil
+}
+
+func (a *Acknowledger) Nack(opts messages.NackOpts) error {
+    a.once.Do(func() {
+        a.result <- ackResult{requeue: opts.Requeue, errorMessage: opts.ErrorMessage}
+    })
+    return nil
+}
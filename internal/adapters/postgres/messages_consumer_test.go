@@ -0,0 +1,37 @@
+package postgres
+
+import (
+    "testing"
+
+    "github.com/stretchr/testify/require"
+    "github.com/walletera/eventskit/messages"
+)
+
+func TestCategoryOf(t *testing.T) {
+    tests := []struct {
+        streamName string
+        category   string
+    }{
+        {"outboundPayment.0d1c6fd4-5bcf-4c4c-9d33-6d3ae0b4d2a1", "outboundPayment"},
+        {"gatewayLease.outboundPendingSweeper", "gatewayLease"},
+        {"$ce-outboundPayment", "outboundPayment"},
+        {"dinopayPolling", "dinopayPolling"},
+    }
+    for _, tt := range tests {
+        t.Run(tt.streamName, func(t *testing.T) {
+            require.Equal(t, tt.category, categoryOf(tt.streamName))
+        })
+    }
+}
+
+func TestAcknowledger_OnlyFirstResultCounts(t *testing.T) {
+    acknowledger := NewAcknowledger()
+    require.NoError(t, acknowledger.Nack(messages.NackOpts{Requeue: true, ErrorMessage: "timeout"}))
+    require.NoError(t, acknowledger.Ack())
+    require.Equal(t, ackResult{requeue: true, errorMessage: "timeout"}, <-acknowledger.result)
+    select {
+    case result := <-acknowledger.result:
+        t.Fatalf("unexpected second result %+v", result)
+    default:
+    }
+}
@@ -0,0 +1,87 @@
+// Package postgres stores the gateway events in PostgreSQL, as an alternative
+// to EventStoreDB for the deployments that already run a PostgreSQL cluster.
+//
+// The events of all the streams live in a single table ordered by a global
+// position, and the subscription groups of a category keep the position of
+// the last event they handled as their checkpoint.
+package postgres
+
+import (
+    "context"
+    "database/sql"
+    "fmt"
+
+    _ "github.com/lib/pq"
+)
+
+const schema = `
+CREATE TABLE IF NOT EXISTS events (
+    global_position BIGSERIAL PRIMARY KEY,
+    stream_name     TEXT        NOT NULL,
+    stream_version  BIGINT      NOT NULL,
+    category        TEXT        NOT NULL,
+    event_type      TEXT        NOT NULL,
+    data            BYTEA       NOT NULL,
+    created_at      TIMESTAMPTZ NOT NULL DEFAULT now(),
+    UNIQUE (stream_name, stream_version)
+);
+
+CREATE INDEX IF NOT EXISTS events_category_idx ON events (category, global_position);
+
+CREATE INDEX IF NOT EXISTS events_category_created_at_idx ON events (category, created_at);
+
+CREATE TABLE IF NOT EXISTS subscription_checkpoints (
+    category   TEXT   NOT NULL,
+    group_name TEXT   NOT NULL,
+    position   BIGINT NOT NULL DEFAULT 0,
+    PRIMARY KEY (category, group_name)
+);
+
+CREATE TABLE IF NOT EXISTS parked_events (
+    category        TEXT        NOT NULL,
+    group_name      TEXT        NOT NULL,
+    global_position BIGINT      NOT NULL,
+    error_message   TEXT        NOT NULL,
+    parked_at       TIMESTAMPTZ NOT NULL DEFAULT now(),
+    PRIMARY KEY (category, group_name, global_position)
+);
+`
+
+// Open returns the pool of connections to the database at url
+func Open(ctx context.Context, url string) (*sql.DB, error) {
+    db, err := sql.Open("postgres", url)
+    if err != nil {
+        return nil, fmt.Errorf("failed opening postgres database: %w", err)
+    }
+    err = db.PingContext(ctx)
+    if err != nil {
+        db.Close()
+        return nil, fmt.Errorf("failed connecting to postgres database: %w", err)
+    }
+    return db, nil
+}
+
+// EnsureSchema creates the tables of the event store unless they already exist
+func EnsureSchema(ctx context.Context, db *sql.DB) error {
+    _, err := db.ExecContext(ctx, schema)
+    if err != nil {
+        return fmt.Errorf("failed creating postgres event store schema: %w", err)
+    }
+    return nil
+}
+
+// CreateSubscription creates the checkpoint of the subscription group to the
+// category, starting at its first event. It does nothing when it already exists,
+// like the EventStoreDB persistent subscriptions it replaces.
+func CreateSubscription(ctx context.Context, db *sql.DB, categoryStreamName string, groupName string) error {
+    _, err := db.ExecContext(
+        ctx,
+        `INSERT INTO subscription_checkpoints (category, group_name) VALUES ($1, $2) ON CONFLICT DO NOTHING`,
+        categoryOf(categoryStreamName),
+        groupName,
+    )
+    if err != nil {
+        return fmt.Errorf("failed creating subscription %s to %s: %w", groupName, categoryStreamName, err)
+    }
+    return nil
+}
@@ -0,0 +1,178 @@
+// Package rabbitmq publishes events to RabbitMQ with publisher confirms.
+package rabbitmq
+
+import (
+    "context"
+    "fmt"
+    "sync"
+
+    amqp "github.com/rabbitmq/amqp091-go"
+    "github.com/walletera/eventskit/events"
+)
+
+const (
+    DefaultHost     = "localhost"
+    DefaultPort     = 5672
+    DefaultUser     = "guest"
+    DefaultPassword = "guest"
+)
+
+var _ events.Publisher = (*Publisher)(nil)
+
+// Publisher publishes persistent messages to a durable exchange through a channel
+// in confirm mode. Publish only returns once the broker confirmed the message, so
+// the gateway event is only acked after its integration event is safely stored by
+// RabbitMQ. A message the broker nacks, or a confirm that doesn't arrive before the
+// context is done, fails the publish and the gateway event is redelivered.
+//
+// The connection is opened again on the next publish when the broker closes it.
+type Publisher struct {
+    host         string
+    port         uint
+    user         string
+    password     string
+    exchangeName string
+    exchangeType string
+
+    mutex   sync.Mutex
+    conn    *amqp.Connection
+    channel *amqp.Channel
+}
+
+type Opt func(p *Publisher)
+
+func WithHost(host string) Opt {
+    return func(p *Publisher) { p.host = host }
+}
+
+func WithPort(port uint) Opt {
+    return func(p *Publisher) { p.port = port }
+}
+
+func WithUser(user string) Opt {
+    return func(p *Publisher) { p.user = user }
+}
+
+func WithPassword(password string) Opt {
+    return func(p *Publisher) { p.password = password }
+}
+
+// NewPublisher connects to RabbitMQ and declares the durable exchange the events are published to
+func NewPublisher(exchangeName string, exchangeType string, opts ...Opt) (*Publisher, error) {
+    p := &Publisher{
+        host:         DefaultHost,
+        port:         DefaultPort,
+        user:         DefaultUser,
+        password:     DefaultPassword,
+        exchangeName: exchangeName,
+        exchangeType: exchangeType,
+    }
+    for _, opt := range opts {
+        opt(p)
+    }
+    p.mutex.Lock()
+    defer p.mutex.Unlock()
+    _, err := p.openChannel()
+    if err != nil {
+        return nil, err
+    }
+    return p, nil
+}
+
+func (p *Publisher) Publish(ctx context.Context, eventData events.EventData, routingInfo events.RoutingInfo) error {
+    serializedEvent, err := eventData.Serialize()
+    if err != nil {
+        return fmt.Errorf("error serializing event: %w", err)
+    }
+    confirmation, err := p.publish(ctx, routingInfo, serializedEvent)
+    if err != nil {
+        return err
+    }
+    acked, err := confirmation.WaitContext(ctx)
+    if err != nil {
+        return fmt.Errorf("failed waiting for the publish confirm: %w", err)
+    }
+    if !acked {
+        return fmt.Errorf("rabbitmq nacked the message published to exchange %s with routing key %s", routingInfo.Topic, routingInfo.RoutingKey)
+    }
+    return nil
+}
+
+func (p *Publisher) Close() error {
+    p.mutex.Lock()
+    defer p.mutex.Unlock()
+    if p.conn == nil || p.conn.IsClosed() {
+        return nil
+    }
+    err := p.conn.Close()
+    if err != nil {
+        return fmt.Errorf("failed to close rabbitmq connection: %w", err)
+    }
+    return nil
+}
+
+// publish publishes the message and returns its deferred confirmation. The channel
+// is shared by the concurrent publishes, the confirms are matched by delivery tag.
+func (p *Publisher) publish(ctx context.Context, routingInfo events.RoutingInfo, body []byte) (*amqp.DeferredConfirmation, error) {
+    p.mutex.Lock()
+    defer p.mutex.Unlock()
+    channel, err := p.openChannel()
+    if err != nil {
+        return nil, err
+    }
+    confirmation, err := channel.PublishWithDeferredConfirmWithContext(
+        ctx,
+        routingInfo.Topic,      // exchange
+        routingInfo.RoutingKey, // routing key
+        false,                  // mandatory
+        false,                  // immediate
+        amqp.Publishing{
+            ContentType:  "application/json",
+            DeliveryMode: amqp.Persistent,
+            Body:         body,
+        },
+    )
+    if err != nil {
+        return nil, fmt.Errorf("failed publishing to exchange %s: %w", routingInfo.Topic, err)
+    }
+    return confirmation, nil
+}
+
+// openChannel returns the confirm mode channel, opening it again if it was
+// closed. It must be called holding the mutex.
+func (p *Publisher) openChannel() (*amqp.Channel, error) {
+    if p.channel != nil && !p.channel.IsClosed() {
+        return p.channel, nil
+    }
+    if p.conn == nil || p.conn.IsClosed() {
+        conn, err := amqp.Dial(fmt.Sprintf("amqp://%s:%s@%s:%d/", p.user, p.password, p.host, p.port))
+        if err != nil {
+            return nil, fmt.Errorf("failed to connect to RabbitMQ: %w", err)
+        }
+        p.conn = conn
+    }
+    channel, err := p.conn.Channel()
+    if err != nil {
+        return nil, fmt.Errorf("failed to open a channel: %w", err)
+    }
+    err = channel.ExchangeDeclare(
+        p.exchangeName, // name
+        p.exchangeType, // type
+        true,           // durable
+        false,          // auto-deleted
+        false,          // internal
+        false,          // no-wait
+        nil,            // arguments
+    )
+    if err != nil {
+        channel.Close()
+        return nil, fmt.Errorf("failed to declare exchange %s: %w", p.exchangeName, err)
+    }
+    err = channel.Confirm(false)
+    if err != nil {
+        channel.Close()
+        return nil, fmt.Errorf("failed to put the channel in confirm mode: %w", err)
+    }
+    p.channel = channel
+    return channel, nil
+}
@@ -0,0 +1,103 @@
+package sanctions
+
+import (
+    "context"
+    "fmt"
+    "log/slog"
+    "os"
+    "sort"
+    "sync"
+    "time"
+
+    "github.com/walletera/dinopay-gateway/internal/domain/ports/output/screening"
+    "github.com/walletera/dinopay-gateway/pkg/logattr"
+)
+
+// FileScreener screens names against a sanctions list kept in a CSV or JSON
+// file. The file is read again whenever its modification time changes. When
+// the new content can't be parsed the previous list is kept, so that a bad
+// edit doesn't stop the screening.
+type FileScreener struct {
+    path      string
+    threshold float64
+    logger    *slog.Logger
+
+    mutex   sync.Mutex
+    list    List
+    modTime time.Time
+}
+
+var _ screening.Screener = (*FileScreener)(nil)
+
+// NewFileScreener reads the list in path. Names scoring threshold or
+// more against an entry or any of its aliases are hits.
+func NewFileScreener(path string, threshold float64, logger *slog.Logger) (*FileScreener, error) {
+    screener := &FileScreener{
+        path:      path,
+        threshold: threshold,
+        logger:    logger.With(logattr.Component("sanctions.FileScreener")),
+    }
+    err := screener.reload()
+    if err != nil {
+        return nil, err
+    }
+    return screener, nil
+}
+
+func (s *FileScreener) Screen(_ context.Context, name string) (screening.Result, error) {
+    s.mutex.Lock()
+    defer s.mutex.Unlock()
+    err := s.reload()
+    if err != nil {
+        if s.list.Version == "" {
+            return screening.Result{}, err
+        }
+        s.logger.Error("failed reloading sanctions list, keeping version "+s.list.Version, logattr.Error(err.Error()))
+    }
+    result := screening.Result{ListVersion: s.list.Version}
+    for _, entry := range s.list.Entries {
+        score := Similarity(name, entry.Name)
+        for _, alias := range entry.Aliases {
+            score = max(score, Similarity(name, alias))
+        }
+        if score >= s.threshold {
+            result.Matches = append(result.Matches, screening.Match{
+                EntryId: entry.Id,
+                Name:    entry.Name,
+                Score:   score,
+            })
+        }
+    }
+    sort.SliceStable(result.Matches, func(i, j int) bool {
+        return result.Matches[i].Score > result.Matches[j].Score
+    })
+    result.Hit = len(result.Matches) > 0
+    return result, nil
+}
+
+// reload reads the list again if the file changed. The caller must hold the mutex.
+func (s *FileScreener) reload() error {
+    fileInfo, err := os.Stat(s.path)
+    if err != nil {
+        return fmt.Errorf("failed reading sanctions list %s: %w", s.path, err)
+    }
+    if s.list.Version != "" && fileInfo.ModTime().Equal(s.modTime) {
+        return nil
+    }
+    content, err := os.ReadFile(s.path)
+    if err != nil {
+        return fmt.Errorf("failed reading sanctions list %s: %w", s.path, err)
+    }
+    // the modification time is recorded even when the content is invalid,
+    // so that a bad list is reported once rather than on every screening
+    s.modTime = fileInfo.ModTime()
+    list, err := ParseList(s.path, content)
+    if err != nil {
+        return err
+    }
+    if list.Version != s.list.Version {
+        s.logger.Info("sanctions list loaded", slog.String("version", list.Version), slog.Int("entries", len(list.Entries)))
+    }
+    s.list = list
+    return nil
+}
@@ -0,0 +1,86 @@
+package sanctions
+
+import (
+    "context"
+    "log/slog"
+    "os"
+    "path/filepath"
+    "testing"
+    "time"
+
+    "github.com/stretchr/testify/require"
+)
+
+func TestSimilarity(t *testing.T) {
+    tests := []struct {
+        name  string
+        a     string
+        b     string
+        match bool
+    }{
+        {name: "same name", a: "Ivan Petrov", b: "Ivan Petrov", match: true},
+        {name: "case, accents and punctuation", a: "IVÁN PETROV.", b: "ivan petrov", match: true},
+        {name: "word order", a: "Petrov, Ivan", b: "Ivan Petrov", match: true},
+        {name: "misspelling", a: "Ivan Petrof", b: "Ivan Petrov", match: true},
+        {name: "missing middle name", a: "Ivan Petrov", b: "Ivan Sergeyevich Petrov", match: true},
+        {name: "different name", a: "Maria Gonzalez", b: "Ivan Petrov", match: false},
+        {name: "same first name only", a: "Ivan", b: "Ivan Petrov", match: false},
+        {name: "empty name", a: "", b: "Ivan Petrov", match: false},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            score := Similarity(tt.a, tt.b)
+            require.Equal(t, tt.match, score >= 0.9, "score %f", score)
+        })
+    }
+}
+
+func TestParseList(t *testing.T) {
+    csvList, err := ParseList("list.csv", []byte("id,name,aliases\nSDN-1,Ivan Petrov,Ivan P.;I. Petrov\nSDN-2,Acme Trading Ltd,\n"))
+    require.NoError(t, err)
+    jsonList, err := ParseList("list.json", []byte(`[{"id":"SDN-1","name":"Ivan Petrov","aliases":["Ivan P.","I. Petrov"]},{"id":"SDN-2","name":"Acme Trading Ltd"}]`))
+    require.NoError(t, err)
+    require.Equal(t, csvList.Entries, jsonList.Entries)
+    require.NotEmpty(t, csvList.Version)
+
+    _, err = ParseList("list.txt", []byte("Ivan Petrov"))
+    require.ErrorIs(t, err, ErrUnsupportedFormat)
+    _, err = ParseList("list.csv", []byte("id,name\n,Ivan Petrov\n"))
+    require.Error(t, err)
+}
+
+func TestFileScreener_ReloadsTheListWhenTheFileChanges(t *testing.T) {
+    path := filepath.Join(t.TempDir(), "sanctions.csv")
+    writeList(t, path, "id,name,aliases\nSDN-1,Ivan Petrov,Vanya Petrov\n", time.Now().Add(-time.Hour))
+    screener, err := NewFileScreener(path, 0.9, slog.New(slog.DiscardHandler))
+    require.NoError(t, err)
+
+    result, err := screener.Screen(context.Background(), "Vania Petrov")
+    require.NoError(t, err)
+    require.True(t, result.Hit)
+    require.Equal(t, "SDN-1", result.Matches[0].EntryId)
+    firstVersion := result.ListVersion
+
+    result, err = screener.Screen(context.Background(), "Maria Gonzalez")
+    require.NoError(t, err)
+    require.False(t, result.Hit)
+
+    writeList(t, path, "id,name,aliases\nSDN-2,Maria Gonzalez,\n", time.Now())
+    result, err = screener.Screen(context.Background(), "Maria Gonzalez")
+    require.NoError(t, err)
+    require.True(t, result.Hit)
+    require.NotEqual(t, firstVersion, result.ListVersion)
+    secondVersion := result.ListVersion
+
+    // a broken list doesn't replace the last valid one
+    writeList(t, path, "name\nIvan Petrov\n", time.Now().Add(time.Hour))
+    result, err = screener.Screen(context.Background(), "Maria Gonzalez")
+    require.NoError(t, err)
+    require.True(t, result.Hit)
+    require.Equal(t, secondVersion, result.ListVersion)
+}
+
+func writeList(t *testing.T, path string, content string, modTime time.Time) {
+    require.NoError(t, os.WriteFile(path, []byte(content), 0600))
+    require.NoError(t, os.Chtimes(path, modTime, modTime))
+}
@@ -0,0 +1,106 @@
+// Package sanctions screens names against a sanctions list kept in a local file
+package sanctions
+
+import (
+    "bytes"
+    "crypto/sha256"
+    "encoding/csv"
+    "encoding/hex"
+    "encoding/json"
+    "errors"
+    "fmt"
+    "io"
+    "path/filepath"
+    "strings"
+)
+
+var ErrUnsupportedFormat = errors.New("unsupported sanctions list format")
+
+// Entry is a sanctioned party, known by its name and its aliases
+type Entry struct {
+    Id      string   `json:"id"`
+    Name    string   `json:"name"`
+    Aliases []string `json:"aliases,omitempty"`
+}
+
+// List is a sanctions list. Its Version is derived from the content.
+type List struct {
+    Version string
+    Entries []Entry
+}
+
+// ParseList parses a list in the format given by the extension of its name.
+// A CSV list has the id, name and aliases columns, the aliases separated
+// by semicolons. A JSON list is an array of entries.
+func ParseList(name string, content []byte) (List, error) {
+    var entries []Entry
+    var err error
+    switch strings.ToLower(filepath.Ext(name)) {
+    case ".csv":
+        entries, err = parseCSV(content)
+    case ".json":
+        err = json.Unmarshal(content, &entries)
+    default:
+        return List{}, fmt.Errorf("%w: %s", ErrUnsupportedFormat, name)
+    }
+    if err != nil {
+        return List{}, fmt.Errorf("failed parsing sanctions list %s: %w", name, err)
+    }
+    for i, entry := range entries {
+        if entry.Id == "" || entry.Name == "" {
+            return List{}, fmt.Errorf("sanctions list %s entry %d has no id or name", name, i+1)
+        }
+    }
+    hash := sha256.Sum256(content)
+    return List{
+        Version: hex.EncodeToString(hash[:8]),
+        Entries: entries,
+    }, nil
+}
+
+func parseCSV(content []byte) ([]Entry, error) {
+    reader := csv.NewReader(bytes.NewReader(content))
+    reader.FieldsPerRecord = -1
+    header, err := reader.Read()
+    if err != nil {
+        return nil, err
+    }
+    columns := make(map[string]int)
+    for i, column := range header {
+        columns[strings.TrimSpace(strings.ToLower(column))] = i
+    }
+    for _, column := range []string{"id", "name"} {
+        if _, ok := columns[column]; !ok {
+            return nil, fmt.Errorf("missing column %s", column)
+        }
+    }
+    var entries []Entry
+    for {
+        record, err := reader.Read()
+        if errors.Is(err, io.EOF) {
+            return entries, nil
+        }
+        if err != nil {
+            return nil, err
+        }
+        entry := Entry{
+            Id:   field(record, columns["id"]),
+            Name: field(record, columns["name"]),
+        }
+        if i, ok := columns["aliases"]; ok {
+            for _, alias := range strings.Split(field(record, i), ";") {
+                if alias = strings.TrimSpace(alias); alias != "" {
+                    entry.Aliases = append(entry.Aliases, alias)
+                }
+            }
+        }
+        entries = append(entries, entry)
+    }
+}
+
+func field(record []string, i int) string {
+    if i >= len(record) {
+        return ""
+    }
+    return strings.TrimSpace(record[i])
+}
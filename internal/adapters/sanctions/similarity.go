@@ -0,0 +1,117 @@
+package sanctions
+
+import (
+    "sort"
+    "strings"
+    "unicode"
+)
+
+// foldings maps the accented latin letters to their base letter
+var foldings = map[rune]rune{
+    'á': 'a', 'à': 'a', 'â': 'a', 'ä': 'a', 'ã': 'a', 'å': 'a',
+    'é': 'e', 'è': 'e', 'ê': 'e', 'ë': 'e',
+    'í': 'i', 'ì': 'i', 'î': 'i', 'ï': 'i',
+    'ó': 'o', 'ò': 'o', 'ô': 'o', 'ö': 'o', 'õ': 'o', 'ø': 'o',
+    'ú': 'u', 'ù': 'u', 'û': 'u', 'ü': 'u',
+    'ñ': 'n', 'ç': 'c', 'ý': 'y', 'ÿ': 'y',
+}
+
+// tokens normalizes a name into its lowercase words without
+// accents nor punctuation, sorted so that the word order doesn't matter
+func tokens(name string) []string {
+    var normalized strings.Builder
+    for _, r := range strings.ToLower(name) {
+        if folded, ok := foldings[r]; ok {
+            r = folded
+        }
+        if unicode.IsLetter(r) || unicode.IsDigit(r) {
+            normalized.WriteRune(r)
+        } else {
+            normalized.WriteRune(' ')
+        }
+    }
+    words := strings.Fields(normalized.String())
+    sort.Strings(words)
+    return words
+}
+
+// Similarity scores how alike two names are, from 0 to 1. It's the best of
+// the Jaro-Winkler similarity of the whole names and, when both have several
+// words, the average similarity of the words of the shortest name to their
+// closest word in the other, so that a missing middle name still matches.
+func Similarity(a string, b string) float64 {
+    tokensA, tokensB := tokens(a), tokens(b)
+    if len(tokensA) == 0 || len(tokensB) == 0 {
+        return 0
+    }
+    score := jaroWinkler(strings.Join(tokensA, " "), strings.Join(tokensB, " "))
+    if len(tokensA) > len(tokensB) {
+        tokensA, tokensB = tokensB, tokensA
+    }
+    if len(tokensA) < 2 {
+        return score
+    }
+    var sum float64
+    for _, tokenA := range tokensA {
+        var best float64
+        for _, tokenB := range tokensB {
+            best = max(best, jaroWinkler(tokenA, tokenB))
+        }
+        sum += best
+    }
+    return max(score, sum/float64(len(tokensA)))
+}
+
+func jaroWinkler(a string, b string) float64 {
+    runesA, runesB := []rune(a), []rune(b)
+    jaro := jaroSimilarity(runesA, runesB)
+    prefix := 0
+    for prefix < min(4, len(runesA), len(runesB)) && runesA[prefix] == runesB[prefix] {
+        prefix++
+    }
+    return jaro + float64(prefix)*0.1*(1-jaro)
+}
+
+func jaroSimilarity(a []rune, b []rune) float64 {
+    if len(a) == 0 && len(b) == 0 {
+        return 1
+    }
+    if len(a) == 0 || len(b) == 0 {
+        return 0
+    }
+    matchDistance := max(len(a), len(b))/2 - 1
+    matchedA := make([]bool, len(a))
+    matchedB := make([]bool, len(b))
+    matches := 0
+    for i := range a {
+        from := max(0, i-matchDistance)
+        to := min(len(b), i+matchDistance+1)
+        for j := from; j < to; j++ {
+            if matchedB[j] || a[i] != b[j] {
+                continue
+            }
+            matchedA[i], matchedB[j] = true, true
+            matches++
+            break
+        }
+    }
+    if matches == 0 {
+        return 0
+    }
+    transpositions := 0
+    j := 0
+    for i := range a {
+        if !matchedA[i] {
+            continue
+        }
+        for !matchedB[j] {
+            j++
+        }
+        if a[i] != b[j] {
+            transpositions++
+        }
+        j++
+    }
+    m := float64(matches)
+    return (m/float64(len(a)) + m/float64(len(b)) + (m-float64(transpositions)/2)/m) / 3
+}
@@ -0,0 +1,154 @@
+package statements
+
+import (
+    "context"
+    "encoding/json"
+    "errors"
+    "log/slog"
+    "os"
+    "path/filepath"
+    "strings"
+    "time"
+
+    "github.com/walletera/dinopay-gateway/internal/domain/reconciliation"
+    "github.com/walletera/dinopay-gateway/pkg/logattr"
+    "github.com/walletera/werrors"
+)
+
+const (
+    DefaultScanInterval = 1 * time.Minute
+
+    doneDir   = "done"
+    failedDir = "failed"
+)
+
+type Reconciler interface {
+    ReconcileAll(ctx context.Context, statements []reconciliation.Statement) ([]*reconciliation.Report, werrors.WError)
+}
+
+// Watcher imports the DinoPay statements dropped in a local directory.
+// A reconciled statement is moved to the done subdirectory next to its
+// report (<name>.report.json), and a statement that can't be parsed is
+// moved to the failed subdirectory. Statements failing for any other
+// reason are left in place and retried on the next scan. The statements
+// found by a scan are reconciled together, so that the gateway streams
+// are folded once per scan.
+type Watcher struct {
+    dir        string
+    interval   time.Duration
+    reconciler Reconciler
+    logger     *slog.Logger
+}
+
+type Opt func(w *Watcher)
+
+func WithScanInterval(interval time.Duration) Opt {
+    return func(w *Watcher) { w.interval = interval }
+}
+
+func NewWatcher(dir string, reconciler Reconciler, logger *slog.Logger, opts ...Opt) *Watcher {
+    w := &Watcher{
+        dir:        dir,
+        interval:   DefaultScanInterval,
+        reconciler: reconciler,
+        logger:     logger.With(logattr.Component("statements.Watcher")),
+    }
+    for _, opt := range opts {
+        opt(w)
+    }
+    return w
+}
+
+// Run scans the directory every interval until ctx is done
+func (w *Watcher) Run(ctx context.Context) {
+    ticker := time.NewTicker(w.interval)
+    defer ticker.Stop()
+    for {
+        select {
+        case <-ctx.Done():
+            return
+        case <-ticker.C:
+            err := w.Scan(ctx)
+            if err != nil {
+                w.logger.Error("failed scanning statements directory", logattr.Error(err.Error()))
+            }
+        }
+    }
+}
+
+// Scan imports every statement found in the directory
+func (w *Watcher) Scan(ctx context.Context) error {
+    entries, err := os.ReadDir(w.dir)
+    if err != nil {
+        return err
+    }
+    var names []string
+    var statements []reconciliation.Statement
+    for _, entry := range entries {
+        name := entry.Name()
+        ext := strings.ToLower(filepath.Ext(name))
+        if entry.IsDir() || (ext != ".csv" && ext != ".json") {
+            continue
+        }
+        statement, err := w.parseStatement(name)
+        if err != nil {
+            w.logger.Error("failed importing statement", slog.String("statement_name", name), logattr.Error(err.Error()))
+            continue
+        }
+        names = append(names, name)
+        statements = append(statements, statement)
+    }
+    if len(statements) == 0 {
+        return nil
+    }
+    reports, werr := w.reconciler.ReconcileAll(ctx, statements)
+    if werr != nil {
+        return werr
+    }
+    for i, report := range reports {
+        err = w.done(names[i], report)
+        if err != nil {
+            w.logger.Error("failed importing statement", slog.String("statement_name", names[i]), logattr.Error(err.Error()))
+        }
+    }
+    return nil
+}
+
+// parseStatement reads and parses a statement, moving it to the failed subdirectory when it can't be parsed
+func (w *Watcher) parseStatement(name string) (reconciliation.Statement, error) {
+    path := filepath.Join(w.dir, name)
+    content, err := os.ReadFile(path)
+    if err != nil {
+        return reconciliation.Statement{}, err
+    }
+    statement, err := reconciliation.ParseStatement(name, content)
+    if err != nil {
+        return reconciliation.Statement{}, errors.Join(err, w.move(path, failedDir, name))
+    }
+    return statement, nil
+}
+
+// done writes the report of a reconciled statement and moves the statement to the done subdirectory
+func (w *Watcher) done(name string, report *reconciliation.Report) error {
+    reportContent, err := json.MarshalIndent(report, "", "  ")
+    if err != nil {
+        return err
+    }
+    err = os.MkdirAll(filepath.Join(w.dir, doneDir), 0o755)
+    if err != nil {
+        return err
+    }
+    err = os.WriteFile(filepath.Join(w.dir, doneDir, name+".report.json"), reportContent, 0o644)
+    if err != nil {
+        return err
+    }
+    return w.move(filepath.Join(w.dir, name), doneDir, name)
+}
+
+func (w *Watcher) move(path string, subdir string, name string) error {
+    err := os.MkdirAll(filepath.Join(w.dir, subdir), 0o755)
+    if err != nil {
+        return err
+    }
+    return os.Rename(path, filepath.Join(w.dir, subdir, name))
+}
@@ -2,18 +2,43 @@ package app
 
 import (
     "context"
+    "database/sql"
     "fmt"
     "log/slog"
+    "net/http"
+    "os"
     "time"
 
     "github.com/EventStore/EventStore-Client-Go/v4/esdb"
+    "github.com/google/uuid"
     accountsapi "github.com/walletera/accounts/publicapi"
+    accountsadapter "github.com/walletera/dinopay-gateway/internal/adapters/accounts"
+    "github.com/walletera/dinopay-gateway/internal/adapters/auth"
     "github.com/walletera/dinopay-gateway/internal/adapters/dinopay"
+    eventstoredbadapter "github.com/walletera/dinopay-gateway/internal/adapters/eventstoredb"
+    "github.com/walletera/dinopay-gateway/internal/adapters/metrics"
+    "github.com/walletera/dinopay-gateway/internal/adapters/nats"
+    "github.com/walletera/dinopay-gateway/internal/adapters/operatorapi"
+    "github.com/walletera/dinopay-gateway/internal/adapters/postgres"
+    rabbitmqadapter "github.com/walletera/dinopay-gateway/internal/adapters/rabbitmq"
+    "github.com/walletera/dinopay-gateway/internal/adapters/sanctions"
+    "github.com/walletera/dinopay-gateway/internal/adapters/statements"
     dinopayevents "github.com/walletera/dinopay-gateway/internal/domain/events/dinopay"
     "github.com/walletera/dinopay-gateway/internal/domain/events/walletera/gateway/inbound"
     "github.com/walletera/dinopay-gateway/internal/domain/events/walletera/gateway/outbound"
     "github.com/walletera/dinopay-gateway/internal/domain/events/walletera/payments"
+    "github.com/walletera/dinopay-gateway/internal/domain/idempotency"
+    "github.com/walletera/dinopay-gateway/internal/domain/integration"
+    "github.com/walletera/dinopay-gateway/internal/domain/lease"
+    "github.com/walletera/dinopay-gateway/internal/domain/polling"
+    riskport "github.com/walletera/dinopay-gateway/internal/domain/ports/output/risk"
+    screeningport "github.com/walletera/dinopay-gateway/internal/domain/ports/output/screening"
+    "github.com/walletera/dinopay-gateway/internal/domain/reconciliation"
+    "github.com/walletera/dinopay-gateway/internal/domain/risk"
+    "github.com/walletera/dinopay-gateway/internal/domain/screening"
+    "github.com/walletera/dinopay-gateway/internal/domain/velocity"
     "github.com/walletera/dinopay-gateway/pkg/logattr"
+    "github.com/walletera/eventskit/eventsourcing"
     "github.com/walletera/eventskit/eventstoredb"
     "github.com/walletera/eventskit/messages"
     "github.com/walletera/eventskit/rabbitmq"
@@ -21,6 +46,7 @@ import (
     paymentsevents "github.com/walletera/payments-types/events"
     paymentsapi "github.com/walletera/payments-types/privateapi"
     "github.com/walletera/werrors"
+    "go.opentelemetry.io/otel"
     "go.uber.org/zap"
     "go.uber.org/zap/exp/zapslog"
     "go.uber.org/zap/zapcore"
@@ -30,11 +56,28 @@ const (
     RabbitMQPaymentsExchangeName              = "payments.events"
     RabbitMQExchangeType                      = "topic"
     RabbitMQPaymentCreatedRoutingKey          = "payment.created"
+    RabbitMQPaymentUpdatedRoutingKey          = "payment.updated"
     RabbitMQQueueName                         = "dinopay-gateway"
+    RabbitMQIntegrationEventsExchangeName     = "dinopay.gateway.events"
+    NATSPaymentCreatedSubject                 = RabbitMQPaymentsExchangeName + "." + RabbitMQPaymentCreatedRoutingKey
+    NATSPaymentUpdatedSubject                 = RabbitMQPaymentsExchangeName + "." + RabbitMQPaymentUpdatedRoutingKey
     ESDB_ByCategoryProjection_OutboundPayment = "$ce-outboundPayment"
     ESDB_ByCategoryProjection_InboundPayment  = "$ce-inboundPayment"
     ESDB_SubscriptionGroupName                = "dinopay-gateway"
+    ESDB_PublisherSubscriptionGroupName       = "dinopay-gateway-publisher"
     WebhookServerPort                         = 8686
+    OperatorApiServerPort                     = 8687
+    MetricsServerPort                         = 8688
+    PendingSweeperLeaseName                   = "outboundPendingSweeper"
+    DinopayPollerLeaseName                    = "dinopayPoller"
+    UnknownOutcomeResolverLeaseName           = "outboundUnknownOutcomeResolver"
+    IdempotencyPurgerLeaseName                = "idempotencyPurger"
+    PaymentsIdempotencyConsumer               = "payments"
+    DinopayIdempotencyConsumer                = "dinopayWebhook"
+    GatewayInboundIdempotencyConsumer         = "gatewayInbound"
+    GatewayOutboundIdempotencyConsumer        = "gatewayOutbound"
+    IntegrationInboundIdempotencyConsumer     = "integrationInbound"
+    IntegrationOutboundIdempotencyConsumer    = "integrationOutbound"
 )
 
 type App struct {
@@ -43,11 +86,74 @@ type App struct {
     rabbitmqUser     string
     rabbitmqPassword string
     dinopayUrl       string
+    dinopayCreds     dinopay.Credentials
+    dinopayCredsFile string
+    pollInterval     time.Duration
     accountsUrl      string
+    accountsCache    accountsCacheConfig
     paymentsUrl      string
+    paymentsNATS     paymentsNATSConfig
     esdbUrl          string
+    postgresUrl      string
+    postgresDB       *sql.DB
+    serviceJWT       *auth.JWTConfig
+    serviceTokenFile string
+    serviceRoles     []string
+    tokenProvider    auth.TokenProvider
+    returnRules      inbound.ReturnRules
+    ownAccounts      []string
+    validationRules  outbound.ValidationRules
+    velocityLimits   []velocity.Limit
+    approvals        outbound.ApprovalThresholds
+    sanctions        sanctionsConfig
+    screener         screeningport.Screener
+    riskScorer       riskport.Scorer
+    eventsExchange   string
+    dedupRetention   time.Duration
     logHandler       slog.Handler
     logger           *slog.Logger
+    operatorApi      *operatorapi.Server
+    metricsServer    *metrics.Server
+    operatorTokens   string
+    accountsClient   *accountsadapter.CachedClient
+    unknownOutcome   unknownOutcomeConfig
+    resolver         *outbound.UnknownOutcomeResolver
+    pendingSweeper   pendingSweeperConfig
+    statements       statementsConfig
+    reconciler       *reconciliation.Reconciler
+}
+
+type unknownOutcomeConfig struct {
+    interval    time.Duration
+    backoff     time.Duration
+    maxAttempts int
+}
+
+type paymentsNATSConfig struct {
+    url               string
+    streamName        string
+    deadLetterSubject string
+}
+
+type statementsConfig struct {
+    dir          string
+    scanInterval time.Duration
+}
+
+type sanctionsConfig struct {
+    listFile  string
+    threshold float64
+}
+
+type pendingSweeperConfig struct {
+    interval time.Duration
+    sla      time.Duration
+}
+
+type accountsCacheConfig struct {
+    ttl         time.Duration
+    negativeTTL time.Duration
+    maxStale    time.Duration
 }
 
 func NewApp(opts ...Option) (*App, error) {
@@ -59,6 +165,10 @@ func NewApp(opts ...Option) (*App, error) {
     for _, opt := range opts {
         opt(app)
     }
+    err = initServiceTokenProvider(app)
+    if err != nil {
+        return nil, fmt.Errorf("failed initializing service token provider: %w", err)
+    }
     return app, nil
 }
 
@@ -70,11 +180,31 @@ func (app *App) Run(ctx context.Context) error {
         With(logattr.ServiceName("dinopay-gateway"))
     app.logger = appLogger
 
-    err := app.execESDBSetupTasks(ctx)
+    // the instruments created with otel.Meter report to the registry
+    metricsRegistry := metrics.NewRegistry()
+    otel.SetMeterProvider(metricsRegistry)
+    app.metricsServer = metrics.NewServer(MetricsServerPort, metricsRegistry, appLogger)
+    app.metricsServer.Start()
+
+    appLogger.Info("metrics server started", slog.Int("port", MetricsServerPort))
+
+    err := app.execEventStoreSetupTasks(ctx)
     if err != nil {
         return err
     }
 
+    app.accountsClient, err = newCachedAccountsClient(app)
+    if err != nil {
+        return err
+    }
+
+    if app.sanctions.listFile != "" {
+        app.screener, err = sanctions.NewFileScreener(app.sanctions.listFile, app.sanctions.threshold, appLogger)
+        if err != nil {
+            return fmt.Errorf("failed loading sanctions list: %w", err)
+        }
+    }
+
     paymentsMessageProcessor, err := createPaymentsMessageProcessor(app, appLogger)
     if err != nil {
         return fmt.Errorf("failed creating payments message processor: %w", err)
@@ -87,18 +217,28 @@ func (app *App) Run(ctx context.Context) error {
 
     appLogger.Info("payments message processor started")
 
-    dinopayMessageProcessor, err := createDinopayMessageProcessor(app, appLogger)
-    if err != nil {
-        return fmt.Errorf("failed creating dinopay webhook message processor: %w", err)
-    }
-
-    err = dinopayMessageProcessor.Start(ctx)
-    if err != nil {
-        return fmt.Errorf("failed starting payments rabbitmq processor: %w", err)
+    if app.pollInterval > 0 {
+        poller, err := createDinopayPoller(app, appLogger)
+        if err != nil {
+            return fmt.Errorf("failed creating dinopay poller: %w", err)
+        }
+        go poller.Run(ctx)
+
+        appLogger.Info("dinopay poller started", slog.Duration("interval", app.pollInterval))
+    } else {
+        dinopayMessageProcessor, err := createDinopayMessageProcessor(app, appLogger)
+        if err != nil {
+            return fmt.Errorf("failed creating dinopay webhook message processor: %w", err)
+        }
+
+        err = dinopayMessageProcessor.Start(ctx)
+        if err != nil {
+            return fmt.Errorf("failed starting payments rabbitmq processor: %w", err)
+        }
+
+        appLogger.Info("dinopay message processor started")
     }
 
-    appLogger.Info("dinopay message processor started")
-
     gatewayMessageProcessor, err := createGatewayMessageProcessor(app, appLogger)
     if err != nil {
         return fmt.Errorf("failed creating dinopay message processor: %w", err)
@@ -121,6 +261,65 @@ func (app *App) Run(ctx context.Context) error {
 
     appLogger.Info("gateway message processor started")
 
+    if app.eventsExchange != "" {
+        err = startIntegrationEventsPublisher(ctx, app, appLogger)
+        if err != nil {
+            return err
+        }
+
+        appLogger.Info("integration events publisher started", slog.String("exchange", app.eventsExchange))
+    }
+
+    resolver, err := createUnknownOutcomeResolver(app, appLogger)
+    if err != nil {
+        return fmt.Errorf("failed creating unknown outcome resolver: %w", err)
+    }
+    go resolver.Run(ctx)
+    app.resolver = resolver
+
+    appLogger.Info("unknown outcome resolver started")
+
+    if app.postgresUrl != "" {
+        // EventStoreDB expires the processed event records by itself, see idempotency.StreamExpirer
+        go createIdempotencyPurger(app, appLogger).Run(ctx)
+
+        appLogger.Info("idempotency purger started")
+    }
+
+    sweeper, err := createPendingSweeper(app, appLogger)
+    if err != nil {
+        return fmt.Errorf("failed creating pending sweeper: %w", err)
+    }
+    go sweeper.Run(ctx)
+
+    appLogger.Info("pending sweeper started")
+
+    reconciler, err := createReconciler(app, appLogger)
+    if err != nil {
+        return fmt.Errorf("failed creating reconciler: %w", err)
+    }
+    app.reconciler = reconciler
+
+    if app.statements.dir != "" {
+        go statements.NewWatcher(
+            app.statements.dir,
+            reconciler,
+            appLogger,
+            statements.WithScanInterval(app.statements.scanInterval),
+        ).Run(ctx)
+
+        appLogger.Info("statements watcher started", slog.String("dir", app.statements.dir))
+    }
+
+    operatorApi, err := createOperatorApiServer(app, appLogger)
+    if err != nil {
+        return fmt.Errorf("failed creating operator api server: %w", err)
+    }
+    operatorApi.Start()
+    app.operatorApi = operatorApi
+
+    appLogger.Info("operator api server started")
+
     appLogger.Info("dinopay-gateway started")
 
     return nil
@@ -128,6 +327,24 @@ func (app *App) Run(ctx context.Context) error {
 
 func (app *App) Stop(ctx context.Context) {
     // TODO implement processor gracefull shutdown
+    if app.operatorApi != nil {
+        err := app.operatorApi.Close(ctx)
+        if err != nil {
+            app.logger.Error("failed closing operator api server", logattr.Error(err.Error()))
+        }
+    }
+    if app.metricsServer != nil {
+        err := app.metricsServer.Close(ctx)
+        if err != nil {
+            app.logger.Error("failed closing metrics server", logattr.Error(err.Error()))
+        }
+    }
+    if app.postgresDB != nil {
+        err := app.postgresDB.Close()
+        if err != nil {
+            app.logger.Error("failed closing postgres event store", logattr.Error(err.Error()))
+        }
+    }
     app.logger.Info("dinopay-gateway stopped")
 }
 
@@ -141,6 +358,25 @@ func setDefaultOpts(app *App) error {
         // never add stacktrace
         zapslog.AddStacktraceAt(slog.LevelError+1),
     )
+    app.accountsCache = accountsCacheConfig{
+        ttl:         accountsadapter.DefaultTTL,
+        negativeTTL: accountsadapter.DefaultNegativeTTL,
+        maxStale:    accountsadapter.DefaultMaxStale,
+    }
+    app.unknownOutcome = unknownOutcomeConfig{
+        interval:    outbound.DefaultResolveInterval,
+        backoff:     outbound.DefaultResolveBackoff,
+        maxAttempts: outbound.DefaultMaxResolveAttempts,
+    }
+    app.pendingSweeper = pendingSweeperConfig{
+        interval: outbound.DefaultSweepInterval,
+        sla:      outbound.DefaultPendingSLA,
+    }
+    app.statements = statementsConfig{
+        scanInterval: statements.DefaultScanInterval,
+    }
+    app.eventsExchange = RabbitMQIntegrationEventsExchangeName
+    app.dedupRetention = idempotency.DefaultRetention
     return nil
 }
 
@@ -162,6 +398,13 @@ func newZapLogger() (*zap.Logger, error) {
     return zapConfig.Build()
 }
 
+func (app *App) execEventStoreSetupTasks(ctx context.Context) error {
+    if app.postgresUrl != "" {
+        return app.execPostgresSetupTasks(ctx)
+    }
+    return app.execESDBSetupTasks(ctx)
+}
+
 func (app *App) execESDBSetupTasks(_ context.Context) error {
     subscriptionSettings := esdb.SubscriptionSettingsDefault()
     subscriptionSettings.ResolveLinkTos = true
@@ -185,41 +428,127 @@ func (app *App) execESDBSetupTasks(_ context.Context) error {
     if err != nil {
         return fmt.Errorf("failed creating persistent subscription for %s: %w", ESDB_ByCategoryProjection_InboundPayment, err)
     }
+
+    if app.eventsExchange == "" {
+        return nil
+    }
+
+    for _, category := range []string{ESDB_ByCategoryProjection_OutboundPayment, ESDB_ByCategoryProjection_InboundPayment} {
+        err = eventstoredb.CreatePersistentSubscription(
+            app.esdbUrl,
+            category,
+            ESDB_PublisherSubscriptionGroupName,
+            subscriptionSettings,
+        )
+        if err != nil {
+            return fmt.Errorf("failed creating publisher persistent subscription for %s: %w", category, err)
+        }
+    }
     return nil
 }
 
-func createPaymentsMessageProcessor(app *App, logger *slog.Logger) (*messages.Processor[paymentsevents.Handler], error) {
-    dinopayClient, err := dinopay.NewClient(app.dinopayUrl)
+// execPostgresSetupTasks opens the PostgreSQL event store and creates its
+// tables and the subscriptions the EventStoreDB setup creates
+func (app *App) execPostgresSetupTasks(ctx context.Context) error {
+    db, err := postgres.Open(ctx, app.postgresUrl)
+    if err != nil {
+        return err
+    }
+    app.postgresDB = db
+
+    err = postgres.EnsureSchema(ctx, db)
     if err != nil {
-        return nil, fmt.Errorf("failed parsing dinopay url %s: %w", app.dinopayUrl, err)
+        return err
+    }
+
+    groupNames := []string{ESDB_SubscriptionGroupName}
+    if app.eventsExchange != "" {
+        groupNames = append(groupNames, ESDB_PublisherSubscriptionGroupName)
+    }
+    for _, category := range []string{ESDB_ByCategoryProjection_OutboundPayment, ESDB_ByCategoryProjection_InboundPayment} {
+        for _, groupName := range groupNames {
+            err = postgres.CreateSubscription(ctx, db, category, groupName)
+            if err != nil {
+                return err
+            }
+        }
     }
+    return nil
+}
 
+// newEventsDB returns the event store selected with WithESDBUrl or WithPostgresEventStore
+func newEventsDB(app *App) (eventsourcing.DB, error) {
+    if app.postgresUrl != "" {
+        return postgres.NewDB(app.postgresDB), nil
+    }
     esdbClient, err := eventstoredb.GetESDBClient(app.esdbUrl)
     if err != nil {
-        return nil, fmt.Errorf("failed getting esdb client: %w", err)
+        return nil, fmt.Errorf("failed creating esdb client: %w", err)
     }
+    return eventstoredbadapter.NewDB(esdbClient), nil
+}
 
-    eventsDB := eventstoredb.NewDB(esdbClient)
-    handler := payments.NewEventsHandler(dinopayClient, eventsDB, logger)
-    queueName := fmt.Sprintf(RabbitMQQueueName)
+// newMessagesConsumer returns the consumer of a category for the subscription group
+// on the event store selected with WithESDBUrl or WithPostgresEventStore
+func newMessagesConsumer(app *App, categoryStreamName string, groupName string, logger *slog.Logger) (messages.Consumer, error) {
+    if app.postgresUrl != "" {
+        return postgres.NewMessagesConsumer(app.postgresDB, categoryStreamName, groupName, logger), nil
+    }
+    consumer, err := eventstoredb.NewMessagesConsumer(app.esdbUrl, categoryStreamName, groupName)
+    if err != nil {
+        return nil, fmt.Errorf("failed creating esdb messages consumer: %w", err)
+    }
+    return consumer, nil
+}
 
-    rabbitMQClient, err := rabbitmq.NewClient(
-        rabbitmq.WithHost(app.rabbitmqHost),
-        rabbitmq.WithPort(uint(app.rabbitmqPort)),
-        rabbitmq.WithUser(app.rabbitmqUser),
-        rabbitmq.WithPassword(app.rabbitmqPassword),
-        rabbitmq.WithExchangeName(RabbitMQPaymentsExchangeName),
-        rabbitmq.WithExchangeType(RabbitMQExchangeType),
-        rabbitmq.WithConsumerRoutingKeys(RabbitMQPaymentCreatedRoutingKey),
-        rabbitmq.WithQueueName(queueName),
+func createPaymentsMessageProcessor(app *App, logger *slog.Logger) (*messages.Processor[paymentsevents.Handler], error) {
+    dinopayClient, err := newDinopayClient(app)
+    if err != nil {
+        return nil, err
+    }
+
+    eventsDB, err := newEventsDB(app)
+    if err != nil {
+        return nil, err
+    }
+
+    paymentsClient, err := newPaymentsClient(app)
+    if err != nil {
+        return nil, err
+    }
+
+    cancellationService := outbound.NewCancellationService(eventsDB, dinopayClient, logger)
+    velocityChecker := velocity.NewChecker(eventsDB, app.velocityLimits)
+    riskService := risk.NewService(eventsDB, app.riskScorer, logger)
+    submitter := outbound.NewSubmitter(eventsDB, dinopayClient)
+    reviewService := outbound.NewReviewService(eventsDB, submitter, ESDB_ByCategoryProjection_OutboundPayment, logger)
+    approvalService := outbound.NewApprovalService(eventsDB, submitter, ESDB_ByCategoryProjection_OutboundPayment, logger)
+    screeningService := screening.NewService(app.screener, logger)
+    handler := payments.NewEventsHandler(
+        dinopayClient,
+        eventsDB,
+        paymentsClient,
+        cancellationService,
+        app.validationRules,
+        screeningService,
+        app.approvals,
+        velocityChecker,
+        riskService,
+        reviewService,
+        approvalService,
+        logger,
     )
+    messagesConsumer, err := newPaymentsMessagesConsumer(app, logger)
     if err != nil {
-        return nil, fmt.Errorf("creating rabbitmq client: %w", err)
+        return nil, err
     }
 
     paymentsMessageProcessor, err := messages.NewProcessor[paymentsevents.Handler](
-        rabbitMQClient,
-        paymentsevents.NewDeserializer(logger),
+        messagesConsumer,
+        idempotency.NewDeserializer[paymentsevents.Handler](
+            paymentsevents.NewDeserializer(logger),
+            newIdempotencyGuard(app, eventsDB, PaymentsIdempotencyConsumer, logger),
+        ),
         handler,
         withErrorCallback(
             logger.With(
@@ -233,36 +562,122 @@ func createPaymentsMessageProcessor(app *App, logger *slog.Logger) (*messages.Pr
     return paymentsMessageProcessor, nil
 }
 
-type AccountsSecuritySource struct {
+// newPaymentsMessagesConsumer returns the consumer of the Payments events, from
+// RabbitMQ unless they are consumed from NATS JetStream with WithPaymentsEventsFromNATS
+func newPaymentsMessagesConsumer(app *App, logger *slog.Logger) (messages.Consumer, error) {
+    if app.paymentsNATS.url != "" {
+        return nats.NewMessagesConsumer(
+            app.paymentsNATS.url,
+            app.paymentsNATS.streamName,
+            RabbitMQQueueName,
+            logger,
+            nats.WithSubjects(NATSPaymentCreatedSubject, NATSPaymentUpdatedSubject),
+            nats.WithDeadLetterSubject(app.paymentsNATS.deadLetterSubject),
+        ), nil
+    }
+
+    queueName := fmt.Sprintf(RabbitMQQueueName)
+
+    rabbitMQClient, err := rabbitmq.NewClient(
+        rabbitmq.WithHost(app.rabbitmqHost),
+        rabbitmq.WithPort(uint(app.rabbitmqPort)),
+        rabbitmq.WithUser(app.rabbitmqUser),
+        rabbitmq.WithPassword(app.rabbitmqPassword),
+        rabbitmq.WithExchangeName(RabbitMQPaymentsExchangeName),
+        rabbitmq.WithExchangeType(RabbitMQExchangeType),
+        rabbitmq.WithConsumerRoutingKeys(RabbitMQPaymentCreatedRoutingKey, RabbitMQPaymentUpdatedRoutingKey),
+        rabbitmq.WithQueueName(queueName),
+    )
+    if err != nil {
+        return nil, fmt.Errorf("creating rabbitmq client: %w", err)
+    }
+    return rabbitMQClient, nil
+}
+
+func newDinopayClient(app *App) (*dinopay.Client, error) {
+    credentials := app.dinopayCreds
+    if app.dinopayCredsFile != "" {
+        fileCredentials, err := dinopay.LoadCredentialsFile(app.dinopayCredsFile)
+        if err != nil {
+            return nil, err
+        }
+        credentials = fileCredentials
+    }
+    dinopayClient, err := dinopay.NewClient(app.dinopayUrl, dinopay.WithCredentials(credentials))
+    if err != nil {
+        return nil, fmt.Errorf("failed creating dinopay client for url %s: %w", app.dinopayUrl, err)
+    }
+    return dinopayClient, nil
 }
 
-func (a AccountsSecuritySource) BearerAuth(ctx context.Context, operationName accountsapi.OperationName) (accountsapi.BearerAuth, error) {
-    //TODO implement me
-    return accountsapi.BearerAuth{
-        Token: "somejsonwebtoken",
-        Roles: nil,
-    }, nil
+func initServiceTokenProvider(app *App) error {
+    if app.tokenProvider != nil {
+        return nil
+    }
+    var err error
+    switch {
+    case app.serviceJWT != nil:
+        app.tokenProvider, err = auth.NewJWTTokenProvider(*app.serviceJWT)
+    case app.serviceTokenFile != "":
+        app.tokenProvider, err = auth.NewFileTokenProvider(app.serviceTokenFile, app.serviceRoles...)
+    default:
+        err = fmt.Errorf("either a jwt private key or a token file must be configured")
+    }
+    return err
 }
 
-func createDinopayMessageProcessor(app *App, logger *slog.Logger) (*messages.Processor[dinopayevents.EventsHandler], error) {
-    accountsapiClient, err := accountsapi.NewClient(app.accountsUrl, AccountsSecuritySource{})
+func newAccountsClient(app *App) (*accountsapi.Client, error) {
+    accountsClient, err := accountsapi.NewClient(
+        app.accountsUrl,
+        auth.NewAccountsSecuritySource(app.tokenProvider),
+        accountsapi.WithClient(auth.NewHttpClient(http.DefaultClient, app.tokenProvider)),
+    )
     if err != nil {
         return nil, fmt.Errorf("failed creating accounts api client: %w", err)
     }
-    paymentsClient, err := paymentsapi.NewClient(app.paymentsUrl)
+    return accountsClient, nil
+}
+
+func newCachedAccountsClient(app *App) (*accountsadapter.CachedClient, error) {
+    accountsClient, err := newAccountsClient(app)
+    if err != nil {
+        return nil, err
+    }
+    return accountsadapter.NewCachedClient(
+        accountsClient,
+        accountsadapter.WithTTL(app.accountsCache.ttl),
+        accountsadapter.WithNegativeTTL(app.accountsCache.negativeTTL),
+        accountsadapter.WithMaxStale(app.accountsCache.maxStale),
+    ), nil
+}
+
+func newPaymentsClient(app *App) (*paymentsapi.Client, error) {
+    paymentsClient, err := paymentsapi.NewClient(
+        app.paymentsUrl,
+        paymentsapi.WithClient(auth.NewHttpClient(http.DefaultClient, app.tokenProvider)),
+    )
     if err != nil {
         return nil, fmt.Errorf("failed creating payments api client: %w", err)
     }
+    return paymentsClient, nil
+}
+
+func createDinopayMessageProcessor(app *App, logger *slog.Logger) (*messages.Processor[dinopayevents.EventsHandler], error) {
     webhookConsumer := webhook.NewServer(WebhookServerPort, webhook.WithLogger(logger.With(logattr.Component("webhook.Server"))))
-    esdbClient, err := eventstoredb.GetESDBClient(app.esdbUrl)
+    eventsHandler, err := newDinopayEventsHandler(app, logger)
+    if err != nil {
+        return nil, err
+    }
+    eventsDB, err := newEventsDB(app)
     if err != nil {
-        return nil, fmt.Errorf("failed getting esdb client: %w", err)
+        return nil, err
     }
-    eventsDB := eventstoredb.NewDB(esdbClient)
-    eventsHandler := dinopayevents.NewEventsHandlerImpl(eventsDB, accountsapiClient, paymentsClient, logger)
     return messages.NewProcessor[dinopayevents.EventsHandler](
         webhookConsumer,
-        dinopayevents.NewEventsDeserializer(),
+        idempotency.NewDeserializer[dinopayevents.EventsHandler](
+            dinopayevents.NewEventsDeserializer(),
+            newIdempotencyGuard(app, eventsDB, DinopayIdempotencyConsumer, logger),
+        ),
         eventsHandler,
         withErrorCallback(
             logger.With(
@@ -272,26 +687,72 @@ func createDinopayMessageProcessor(app *App, logger *slog.Logger) (*messages.Pro
     ), nil
 }
 
+func newDinopayEventsHandler(app *App, logger *slog.Logger) (*dinopayevents.EventsHandlerImpl, error) {
+    paymentsClient, err := newPaymentsClient(app)
+    if err != nil {
+        return nil, err
+    }
+    eventsDB, err := newEventsDB(app)
+    if err != nil {
+        return nil, err
+    }
+    screeningService := screening.NewService(app.screener, logger)
+    riskService := risk.NewService(eventsDB, app.riskScorer, logger)
+    return dinopayevents.NewEventsHandlerImpl(eventsDB, app.accountsClient, paymentsClient, app.returnRules, app.ownAccounts, screeningService, riskService, logger), nil
+}
+
+func createDinopayPoller(app *App, logger *slog.Logger) (*polling.Poller, error) {
+    eventsDB, err := newEventsDB(app)
+    if err != nil {
+        return nil, err
+    }
+    dinopayClient, err := newDinopayClient(app)
+    if err != nil {
+        return nil, err
+    }
+    eventsHandler, err := newDinopayEventsHandler(app, logger)
+    if err != nil {
+        return nil, err
+    }
+    return polling.NewPoller(
+        eventsDB,
+        dinopayClient,
+        eventsHandler,
+        lease.New(eventsDB, DinopayPollerLeaseName, leaseOwner(), 2*app.pollInterval),
+        logger,
+        polling.WithPollInterval(app.pollInterval),
+    ), nil
+}
+
 func createGatewayInboundMessageProcessor(app *App, logger *slog.Logger) (*messages.Processor[inbound.EventsHandler], error) {
 
-    paymentsClient, err := paymentsapi.NewClient(app.paymentsUrl)
+    paymentsClient, err := newPaymentsClient(app)
     if err != nil {
-        return nil, fmt.Errorf("failed creating payments api client: %w", err)
+        return nil, err
     }
 
-    esdbMessagesConsumer, err := eventstoredb.NewMessagesConsumer(
-        app.esdbUrl,
-        ESDB_ByCategoryProjection_InboundPayment,
-        ESDB_SubscriptionGroupName,
-    )
+    messagesConsumer, err := newMessagesConsumer(app, ESDB_ByCategoryProjection_InboundPayment, ESDB_SubscriptionGroupName, logger)
     if err != nil {
-        return nil, fmt.Errorf("failed creating esdb messages consumer: %w", err)
+        return nil, err
+    }
+
+    dinopayClient, err := newDinopayClient(app)
+    if err != nil {
+        return nil, err
     }
 
-    eventsHandler := inbound.NewEventsHandlerImpl(paymentsClient, logger)
+    eventsDB, err := newEventsDB(app)
+    if err != nil {
+        return nil, err
+    }
+
+    eventsHandler := inbound.NewEventsHandlerImpl(eventsDB, paymentsClient, dinopayClient, logger)
     return messages.NewProcessor[inbound.EventsHandler](
-        esdbMessagesConsumer,
-        inbound.NewEventsDeserializer(),
+        messagesConsumer,
+        idempotency.NewDeserializer[inbound.EventsHandler](
+            inbound.NewEventsDeserializer(),
+            newIdempotencyGuard(app, eventsDB, GatewayInboundIdempotencyConsumer, logger),
+        ),
         eventsHandler,
         withErrorCallback(
             logger.With(
@@ -301,33 +762,159 @@ func createGatewayInboundMessageProcessor(app *App, logger *slog.Logger) (*messa
     ), nil
 }
 
-func createGatewayMessageProcessor(app *App, logger *slog.Logger) (*messages.Processor[outbound.EventsHandler], error) {
+// createOperatorApiServer creates the operator api. Without an operator
+// tokens file there is no way to authenticate operators, so every request
+// is rejected.
+func createOperatorApiServer(app *App, logger *slog.Logger) (*operatorapi.Server, error) {
+    var authenticator operatorapi.Authenticator
+    if app.operatorTokens != "" {
+        tokensFileAuthenticator, err := operatorapi.NewTokensFileAuthenticator(app.operatorTokens)
+        if err != nil {
+            return nil, err
+        }
+        authenticator = tokensFileAuthenticator
+    } else {
+        logger.Warn("operator tokens file not configured, the operator api rejects every request")
+    }
+    eventsDB, err := newEventsDB(app)
+    if err != nil {
+        return nil, err
+    }
+    dinopayClient, err := newDinopayClient(app)
+    if err != nil {
+        return nil, err
+    }
+    suspenseService := inbound.NewSuspenseService(eventsDB, ESDB_ByCategoryProjection_InboundPayment)
+    returnService := inbound.NewReturnService(eventsDB)
+    inboundReviewService := inbound.NewReviewService(eventsDB, ESDB_ByCategoryProjection_InboundPayment)
+    submitter := outbound.NewSubmitter(eventsDB, dinopayClient)
+    reviewService := outbound.NewReviewService(eventsDB, submitter, ESDB_ByCategoryProjection_OutboundPayment, logger)
+    approvalService := outbound.NewApprovalService(eventsDB, submitter, ESDB_ByCategoryProjection_OutboundPayment, logger)
+    return operatorapi.NewServer(
+        OperatorApiServerPort,
+        authenticator,
+        suspenseService,
+        returnService,
+        inboundReviewService,
+        app.accountsClient,
+        app.resolver,
+        reviewService,
+        approvalService,
+        app.reconciler,
+        logger,
+    ), nil
+}
 
-    paymentsClient, err := paymentsapi.NewClient(app.paymentsUrl)
+func createReconciler(app *App, logger *slog.Logger) (*reconciliation.Reconciler, error) {
+    eventsDB, err := newEventsDB(app)
     if err != nil {
-        return nil, fmt.Errorf("failed creating payments api client: %w", err)
+        return nil, err
     }
+    paymentsClient, err := newPaymentsClient(app)
+    if err != nil {
+        return nil, err
+    }
+    return reconciliation.NewReconciler(
+        eventsDB,
+        paymentsClient,
+        ESDB_ByCategoryProjection_OutboundPayment,
+        ESDB_ByCategoryProjection_InboundPayment,
+        logger,
+    ), nil
+}
 
-    esdbMessagesConsumer, err := eventstoredb.NewMessagesConsumer(
-        app.esdbUrl,
+// createPendingSweeper creates the sweeper of the outbound payments stuck in pending.
+// The replicas share a lease that outlives one sweep interval, so that when the
+// replica holding it goes away another one takes over on its next sweeps.
+func createPendingSweeper(app *App, logger *slog.Logger) (*outbound.PendingSweeper, error) {
+    eventsDB, err := newEventsDB(app)
+    if err != nil {
+        return nil, err
+    }
+    dinopayClient, err := newDinopayClient(app)
+    if err != nil {
+        return nil, err
+    }
+    return outbound.NewPendingSweeper(
+        eventsDB,
+        dinopayClient,
+        lease.New(eventsDB, PendingSweeperLeaseName, leaseOwner(), 2*app.pendingSweeper.interval),
         ESDB_ByCategoryProjection_OutboundPayment,
-        ESDB_SubscriptionGroupName,
+        logger,
+        outbound.WithSweepInterval(app.pendingSweeper.interval),
+        outbound.WithPendingSLA(app.pendingSweeper.sla),
+    ), nil
+}
+
+// createIdempotencyPurger creates the purger of the processed event records
+// kept by the PostgreSQL event store. Like the pending sweeper, it runs on one
+// replica at a time.
+func createIdempotencyPurger(app *App, logger *slog.Logger) *idempotency.Purger {
+    eventsDB := postgres.NewDB(app.postgresDB)
+    return idempotency.NewPurger(
+        eventsDB,
+        lease.New(eventsDB, IdempotencyPurgerLeaseName, leaseOwner(), 2*idempotency.DefaultPurgeInterval),
+        logger,
+        idempotency.WithPurgeRetention(app.dedupRetention),
     )
+}
+
+// leaseOwner identifies this replica of the gateway
+func leaseOwner() string {
+    hostname, err := os.Hostname()
     if err != nil {
-        return nil, fmt.Errorf("failed creating esdb messages consumer: %w", err)
+        hostname = "dinopay-gateway"
     }
+    return hostname + "-" + uuid.NewString()
+}
 
-    esdbClient, err := eventstoredb.GetESDBClient(app.esdbUrl)
+// createUnknownOutcomeResolver creates the resolver of the outbound payments with
+// an unknown outcome. Like the pending sweeper, it runs on one replica at a time.
+func createUnknownOutcomeResolver(app *App, logger *slog.Logger) (*outbound.UnknownOutcomeResolver, error) {
+    eventsDB, err := newEventsDB(app)
     if err != nil {
-        return nil, fmt.Errorf("failed creating esdb client: %w", err)
+        return nil, err
+    }
+    dinopayClient, err := newDinopayClient(app)
+    if err != nil {
+        return nil, err
     }
+    return outbound.NewUnknownOutcomeResolver(
+        eventsDB,
+        dinopayClient,
+        lease.New(eventsDB, UnknownOutcomeResolverLeaseName, leaseOwner(), 2*app.unknownOutcome.interval),
+        ESDB_ByCategoryProjection_OutboundPayment,
+        logger,
+        outbound.WithResolveInterval(app.unknownOutcome.interval),
+        outbound.WithResolveBackoff(app.unknownOutcome.backoff),
+        outbound.WithMaxResolveAttempts(app.unknownOutcome.maxAttempts),
+    ), nil
+}
+
+func createGatewayMessageProcessor(app *App, logger *slog.Logger) (*messages.Processor[outbound.EventsHandler], error) {
 
-    eventsDB := eventstoredb.NewDB(esdbClient)
+    paymentsClient, err := newPaymentsClient(app)
+    if err != nil {
+        return nil, err
+    }
+
+    messagesConsumer, err := newMessagesConsumer(app, ESDB_ByCategoryProjection_OutboundPayment, ESDB_SubscriptionGroupName, logger)
+    if err != nil {
+        return nil, err
+    }
+
+    eventsDB, err := newEventsDB(app)
+    if err != nil {
+        return nil, err
+    }
 
     eventsHandler := outbound.NewEventsHandlerImpl(eventsDB, paymentsClient, logger)
     return messages.NewProcessor[outbound.EventsHandler](
-            esdbMessagesConsumer,
-            outbound.NewEventsDeserializer(),
+            messagesConsumer,
+            idempotency.NewDeserializer[outbound.EventsHandler](
+                outbound.NewEventsDeserializer(),
+                newIdempotencyGuard(app, eventsDB, GatewayOutboundIdempotencyConsumer, logger),
+            ),
             eventsHandler,
             withErrorCallback(
                 logger.With(
@@ -337,6 +924,71 @@ func createGatewayMessageProcessor(app *App, logger *slog.Logger) (*messages.Pro
         nil
 }
 
+// startIntegrationEventsPublisher publishes the integration events of both gateway
+// categories through a subscription group of its own, so the publishing keeps its
+// own checkpoint and never holds back the processing of the gateway events
+func startIntegrationEventsPublisher(ctx context.Context, app *App, logger *slog.Logger) error {
+    rabbitMQPublisher, err := rabbitmqadapter.NewPublisher(
+        app.eventsExchange,
+        RabbitMQExchangeType,
+        rabbitmqadapter.WithHost(app.rabbitmqHost),
+        rabbitmqadapter.WithPort(uint(app.rabbitmqPort)),
+        rabbitmqadapter.WithUser(app.rabbitmqUser),
+        rabbitmqadapter.WithPassword(app.rabbitmqPassword),
+    )
+    if err != nil {
+        return fmt.Errorf("creating integration events rabbitmq publisher: %w", err)
+    }
+
+    eventsDB, err := newEventsDB(app)
+    if err != nil {
+        return err
+    }
+
+    publisher := integration.NewPublisher(eventsDB, rabbitMQPublisher, app.eventsExchange, logger)
+
+    outboundConsumer, err := newMessagesConsumer(app, ESDB_ByCategoryProjection_OutboundPayment, ESDB_PublisherSubscriptionGroupName, logger)
+    if err != nil {
+        return err
+    }
+    err = messages.NewProcessor[outbound.EventsHandler](
+        outboundConsumer,
+        idempotency.NewDeserializer[outbound.EventsHandler](
+            outbound.NewEventsDeserializer(),
+            newIdempotencyGuard(app, eventsDB, IntegrationOutboundIdempotencyConsumer, logger),
+        ),
+        publisher,
+        withErrorCallback(logger.With(logattr.Component("integration.outbound.MessageProcessor"))),
+    ).Start(ctx)
+    if err != nil {
+        return fmt.Errorf("failed starting outbound integration events processor: %w", err)
+    }
+
+    inboundConsumer, err := newMessagesConsumer(app, ESDB_ByCategoryProjection_InboundPayment, ESDB_PublisherSubscriptionGroupName, logger)
+    if err != nil {
+        return err
+    }
+    err = messages.NewProcessor[inbound.EventsHandler](
+        inboundConsumer,
+        idempotency.NewDeserializer[inbound.EventsHandler](
+            inbound.NewEventsDeserializer(),
+            newIdempotencyGuard(app, eventsDB, IntegrationInboundIdempotencyConsumer, logger),
+        ),
+        publisher,
+        withErrorCallback(logger.With(logattr.Component("integration.inbound.MessageProcessor"))),
+    ).Start(ctx)
+    if err != nil {
+        return fmt.Errorf("failed starting inbound integration events processor: %w", err)
+    }
+    return nil
+}
+
+// newIdempotencyGuard returns the guard that makes a processor skip the duplicates of the
+// events it already processed, every processor must use its own consumer name
+func newIdempotencyGuard(app *App, eventsDB eventsourcing.DB, consumer string, logger *slog.Logger) *idempotency.Guard {
+    return idempotency.NewGuard(eventsDB, consumer, logger, idempotency.WithRetention(app.dedupRetention))
+}
+
 func withErrorCallback(logger *slog.Logger) messages.ProcessorOpt {
     return messages.WithErrorCallback(func(wError werrors.WError) {
         logger.Error(
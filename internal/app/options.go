@@ -1,6 +1,16 @@
 package app
 
-import "log/slog"
+import (
+    "log/slog"
+    "time"
+
+    "github.com/walletera/dinopay-gateway/internal/adapters/auth"
+    "github.com/walletera/dinopay-gateway/internal/adapters/dinopay"
+    "github.com/walletera/dinopay-gateway/internal/domain/events/walletera/gateway/inbound"
+    "github.com/walletera/dinopay-gateway/internal/domain/events/walletera/gateway/outbound"
+    riskport "github.com/walletera/dinopay-gateway/internal/domain/ports/output/risk"
+    "github.com/walletera/dinopay-gateway/internal/domain/velocity"
+)
 
 type Option func(app *App)
 
@@ -32,17 +42,195 @@ func WithDinopayUrl(url string) func(app *App) {
     return func(app *App) { app.dinopayUrl = url }
 }
 
-func WithAccountsUrl(url string) func(app *App) { return func(app *App) { app.accountsUrl = url }
+// WithDinopayCredentials sets the api key and/or OAuth2 client
+// credentials used to authenticate against DinoPay
+func WithDinopayCredentials(credentials dinopay.Credentials) func(app *App) {
+    return func(app *App) { app.dinopayCreds = credentials }
+}
+
+// WithDinopayCredentialsFile sets the path of a json file (usually a mounted secret)
+// containing the DinoPay credentials. It takes precedence over WithDinopayCredentials.
+func WithDinopayCredentialsFile(path string) func(app *App) {
+    return func(app *App) { app.dinopayCredsFile = path }
+}
+
+func WithAccountsUrl(url string) func(app *App) {
+    return func(app *App) { app.accountsUrl = url }
+}
+
+// WithAccountsCache sets for how long the accounts found (ttl) and not found
+// (negativeTTL) by DinoPay account number are cached, and for how long an
+// expired result can still be used while the Accounts API is failing (maxStale)
+func WithAccountsCache(ttl time.Duration, negativeTTL time.Duration, maxStale time.Duration) func(app *App) {
+    return func(app *App) {
+        app.accountsCache = accountsCacheConfig{
+            ttl:         ttl,
+            negativeTTL: negativeTTL,
+            maxStale:    maxStale,
+        }
+    }
+}
+
+// WithUnknownOutcomeResolver sets how often the outbound payments with an unknown
+// outcome on DinoPay are checked, the min time between two attempts to resolve
+// the same payment, and after how many failed attempts it's escalated to operators
+func WithUnknownOutcomeResolver(interval time.Duration, backoff time.Duration, maxAttempts int) func(app *App) {
+    return func(app *App) {
+        app.unknownOutcome = unknownOutcomeConfig{
+            interval:    interval,
+            backoff:     backoff,
+            maxAttempts: maxAttempts,
+        }
+    }
+}
+
+// WithPendingSweeper sets how often the outbound payments pending on DinoPay
+// are checked, and for how long they can be pending before DinoPay is polled
+func WithPendingSweeper(interval time.Duration, sla time.Duration) func(app *App) {
+    return func(app *App) {
+        app.pendingSweeper = pendingSweeperConfig{
+            interval: interval,
+            sla:      sla,
+        }
+    }
+}
+
+// WithDinopayOwnAccounts sets the DinoPay account numbers the gateway pays out
+// and returns deposits from, the payments sent from them are never credited
+func WithDinopayOwnAccounts(accountNumbers ...string) func(app *App) {
+    return func(app *App) { app.ownAccounts = accountNumbers }
+}
+
+// WithDinopayPolling makes the gateway poll DinoPay for payment changes
+// every interval instead of receiving its webhooks, for the environments
+// DinoPay can't reach the gateway from. Webhooks are used by default.
+func WithDinopayPolling(interval time.Duration) func(app *App) {
+    return func(app *App) { app.pollInterval = interval }
+}
+
+// WithOperatorTokensFile sets the file with the tokens operators authenticate
+// with against the operator api. See operatorapi.TokensFileAuthenticator.
+func WithOperatorTokensFile(path string) func(app *App) {
+    return func(app *App) { app.operatorTokens = path }
+}
+
+// WithStatementsDir sets the directory watched for DinoPay statements to
+// reconcile and how often it's scanned. No directory is watched by default.
+func WithStatementsDir(dir string, scanInterval time.Duration) func(app *App) {
+    return func(app *App) {
+        app.statements = statementsConfig{
+            dir:          dir,
+            scanInterval: scanInterval,
+        }
+    }
 }
 
 func WithPaymentsUrl(url string) func(app *App) {
     return func(app *App) { app.paymentsUrl = url }
 }
 
+// WithPaymentsEventsFromNATS consumes the Payments events from the NATS JetStream
+// stream instead of RabbitMQ, on the subjects named after the RabbitMQ exchange and
+// routing keys. The events that can't be processed are republished to the dead
+// letter subject, when it isn't empty.
+func WithPaymentsEventsFromNATS(url string, streamName string, deadLetterSubject string) func(app *App) {
+    return func(app *App) {
+        app.paymentsNATS = paymentsNATSConfig{
+            url:               url,
+            streamName:        streamName,
+            deadLetterSubject: deadLetterSubject,
+        }
+    }
+}
+
 func WithESDBUrl(url string) func(app *App) {
     return func(app *App) { app.esdbUrl = url }
 }
 
+// WithPostgresEventStore stores the gateway events in the PostgreSQL database
+// at url instead of EventStoreDB
+func WithPostgresEventStore(url string) func(app *App) {
+    return func(app *App) { app.postgresUrl = url }
+}
+
+// WithServiceJWT configures the gateway to authenticate against the
+// Accounts and Payments services with jwt signed by a local private key
+func WithServiceJWT(config auth.JWTConfig) func(app *App) {
+    return func(app *App) { app.serviceJWT = &config }
+}
+
+// WithServiceTokenFile configures the gateway to authenticate against the
+// Accounts and Payments services with a token read from a file.
+// The file is reloaded whenever it changes.
+func WithServiceTokenFile(path string, roles ...string) func(app *App) {
+    return func(app *App) {
+        app.serviceTokenFile = path
+        app.serviceRoles = roles
+    }
+}
+
+// WithServiceTokenProvider overrides the provider of the token used to
+// authenticate against the Accounts and Payments services
+func WithServiceTokenProvider(tokenProvider auth.TokenProvider) func(app *App) {
+    return func(app *App) { app.tokenProvider = tokenProvider }
+}
+
+// WithInboundReturnRules sets the rules deciding which DinoPay deposits
+// are returned to their sender instead of being credited
+func WithInboundReturnRules(rules ...inbound.ReturnRule) func(app *App) {
+    return func(app *App) { app.returnRules = rules }
+}
+
+// WithOutboundValidationRules sets the rules a payout must pass
+// before being submitted to DinoPay
+func WithOutboundValidationRules(rules ...outbound.ValidationRule) func(app *App) {
+    return func(app *App) { app.validationRules = rules }
+}
+
+// WithOutboundVelocityLimits sets the limits on the payouts of every
+// customer and beneficiary, the payouts breaking them are held for review
+func WithOutboundVelocityLimits(limits ...velocity.Limit) func(app *App) {
+    return func(app *App) { app.velocityLimits = limits }
+}
+
+// WithOutboundApprovalThresholds sets the amount per currency above
+// which payouts must be approved by an operator
+func WithOutboundApprovalThresholds(thresholds outbound.ApprovalThresholds) func(app *App) {
+    return func(app *App) { app.approvals = thresholds }
+}
+
+// WithSanctionsList sets the CSV or JSON sanctions list the DinoPay counterparties
+// are screened against, and the similarity score from 0 to 1 a name must reach
+// to match an entry. The list is reloaded whenever the file changes.
+// Nothing is screened by default.
+func WithSanctionsList(path string, threshold float64) func(app *App) {
+    return func(app *App) {
+        app.sanctions = sanctionsConfig{
+            listFile:  path,
+            threshold: threshold,
+        }
+    }
+}
+
+// WithRiskScorer sets the scorer deciding whether the DinoPay deposits and
+// payouts go on, are held for review or are blocked. Nothing is scored by default.
+func WithRiskScorer(scorer riskport.Scorer) func(app *App) {
+    return func(app *App) { app.riskScorer = scorer }
+}
+
+// WithIntegrationEventsExchange sets the RabbitMQ exchange the lifecycle events of
+// the DinoPay deposits and payouts are published to, routed by event type.
+// An empty name disables the publishing.
+func WithIntegrationEventsExchange(exchange string) func(app *App) {
+    return func(app *App) { app.eventsExchange = exchange }
+}
+
+// WithIdempotencyRetention sets how long the message processors remember the
+// events they processed, the duplicates delivered within it are skipped
+func WithIdempotencyRetention(retention time.Duration) func(app *App) {
+    return func(app *App) { app.dedupRetention = retention }
+}
+
 func WithLogHandler(handler slog.Handler) func(app *App) {
     return func(app *App) { app.logHandler = handler }
 }
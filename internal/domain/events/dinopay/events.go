@@ -6,6 +6,7 @@ import (
     "time"
 
     "github.com/google/uuid"
+    "github.com/shopspring/decimal"
     "github.com/walletera/werrors"
 )
 
@@ -17,11 +18,16 @@ type PaymentCreated struct {
 }
 
 type PaymentData struct {
-    Id                 uuid.UUID `json:"id"`
-    Amount             float64   `json:"amount"`
-    Currency           string    `json:"currency"`
-    SourceAccount      Account   `json:"sourceAccount"`
-    DestinationAccount Account   `json:"destinationAccount"`
+    Id                 uuid.UUID       `json:"id"`
+    Amount             decimal.Decimal `json:"amount"`
+    Currency           string          `json:"currency"`
+    SourceAccount      Account         `json:"sourceAccount"`
+    DestinationAccount Account         `json:"destinationAccount"`
+    // CustomerTransactionId is the id given to the payment by its creator
+    CustomerTransactionId string `json:"customerTransactionId,omitempty"`
+    // Status is empty in the webhooks sent before DinoPay reported it,
+    // those payments were final when they were announced
+    Status string `json:"status,omitempty"`
 }
 
 type Account struct {
@@ -61,3 +67,43 @@ func (pc PaymentCreated) Serialize() ([]byte, error) {
 func (pc PaymentCreated) Accept(ctx context.Context, handler EventsHandler) werrors.WError {
     return handler.HandlePaymentCreated(ctx, pc)
 }
+
+// PaymentUpdated is sent by DinoPay when the status of a payment changes
+type PaymentUpdated struct {
+    Id        uuid.UUID   `json:"id"`
+    EventType string      `json:"type"`
+    Time      time.Time   `json:"time"`
+    Data      PaymentData `json:"data"`
+}
+
+func (pu PaymentUpdated) ID() string {
+    return pu.Id.String()
+}
+
+func (pu PaymentUpdated) Type() string {
+    return pu.EventType
+}
+
+func (pu PaymentUpdated) CorrelationID() string {
+    return ""
+}
+
+func (pu PaymentUpdated) DataContentType() string {
+    return "application/json"
+}
+
+func (pu PaymentUpdated) AggregateVersion() uint64 {
+    return 0
+}
+
+func (pu PaymentUpdated) CreatedAt() time.Time {
+    return pu.Time
+}
+
+func (pu PaymentUpdated) Serialize() ([]byte, error) {
+    return json.Marshal(pu)
+}
+
+func (pu PaymentUpdated) Accept(ctx context.Context, handler EventsHandler) werrors.WError {
+    return handler.HandlePaymentUpdated(ctx, pu)
+}
@@ -4,6 +4,7 @@ import (
     "encoding/json"
     "fmt"
 
+    dinopayapi "github.com/walletera/dinopay/api"
     "github.com/walletera/eventskit/events"
 )
 
@@ -31,6 +32,9 @@ func (e EventsDeserializer) Deserialize(rawEvent []byte) (events.Event[EventsHan
         if err != nil {
             return nil, fmt.Errorf("failed unmarshalling PaymentCreated event: %w", err)
         }
+        if paymentData.Status != "" && !validStatus(paymentData.Status) {
+            return nil, fmt.Errorf("invalid PaymentCreated event status: %s", paymentData.Status)
+        }
         paymentCreated := PaymentCreated{
             EventType: "PaymentCreated",
             Data: PaymentData{
@@ -45,10 +49,49 @@ func (e EventsDeserializer) Deserialize(rawEvent []byte) (events.Event[EventsHan
                     AccountHolder: paymentData.DestinationAccount.AccountHolder,
                     AccountNumber: paymentData.DestinationAccount.AccountNumber,
                 },
+                CustomerTransactionId: paymentData.CustomerTransactionId,
+                Status:                paymentData.Status,
             },
         }
         return paymentCreated, nil
+    case "PaymentUpdated":
+        var paymentData PaymentData
+        err := json.Unmarshal(eventEnvelope.Data, &paymentData)
+        if err != nil {
+            return nil, fmt.Errorf("failed unmarshalling PaymentUpdated event: %w", err)
+        }
+        if !validStatus(paymentData.Status) {
+            return nil, fmt.Errorf("invalid PaymentUpdated event status: %s", paymentData.Status)
+        }
+        paymentUpdated := PaymentUpdated{
+            EventType: "PaymentUpdated",
+            Data: PaymentData{
+                Id:       paymentData.Id,
+                Amount:   paymentData.Amount,
+                Currency: paymentData.Currency,
+                SourceAccount: Account{
+                    AccountHolder: paymentData.SourceAccount.AccountHolder,
+                    AccountNumber: paymentData.SourceAccount.AccountNumber,
+                },
+                DestinationAccount: Account{
+                    AccountHolder: paymentData.DestinationAccount.AccountHolder,
+                    AccountNumber: paymentData.DestinationAccount.AccountNumber,
+                },
+                CustomerTransactionId: paymentData.CustomerTransactionId,
+                Status:                paymentData.Status,
+            },
+        }
+        return paymentUpdated, nil
     default:
         return nil, fmt.Errorf("unexpected event type: %s", eventEnvelope.Type)
     }
 }
+
+func validStatus(status string) bool {
+    switch dinopayapi.PaymentStatus(status) {
+    case dinopayapi.PaymentStatusPending, dinopayapi.PaymentStatusConfirmed, dinopayapi.PaymentStatusRejected:
+        return true
+    default:
+        return false
+    }
+}
@@ -3,14 +3,23 @@ package dinopay
 import (
 	"context"
 	"log/slog"
+	"slices"
 	"time"
 
 	"github.com/google/uuid"
 	accountsapi "github.com/walletera/accounts/publicapi"
+	"github.com/walletera/dinopay-gateway/internal/domain/events/walletera/gateway"
 	"github.com/walletera/dinopay-gateway/internal/domain/events/walletera/gateway/inbound"
-	gatewayevents "github.com/walletera/dinopay-gateway/internal/domain/events/walletera/gateway/outbound"
+	"github.com/walletera/dinopay-gateway/internal/domain/events/walletera/gateway/outbound"
+	"github.com/walletera/dinopay-gateway/internal/domain/mapping"
+	"github.com/walletera/dinopay-gateway/internal/domain/money"
+	"github.com/walletera/dinopay-gateway/internal/domain/ports/output/accounts"
+	riskport "github.com/walletera/dinopay-gateway/internal/domain/ports/output/risk"
+	"github.com/walletera/dinopay-gateway/internal/domain/risk"
+	"github.com/walletera/dinopay-gateway/internal/domain/screening"
 	"github.com/walletera/dinopay-gateway/pkg/logattr"
 	"github.com/walletera/dinopay-gateway/pkg/wuuid"
+	"github.com/walletera/eventskit/events"
 	"github.com/walletera/eventskit/eventsourcing"
 	paymentsapi "github.com/walletera/payments-types/privateapi"
 	"github.com/walletera/werrors"
@@ -18,35 +27,59 @@ import (
 
 type EventsHandler interface {
 	HandlePaymentCreated(ctx context.Context, event PaymentCreated) werrors.WError
+	HandlePaymentUpdated(ctx context.Context, event PaymentUpdated) werrors.WError
 }
 
 type EventsHandlerImpl struct {
 	db                eventsourcing.DB
-	accountsApiClient *accountsapi.Client
+	accountsApiClient accounts.Client
 	paymentsApiClient *paymentsapi.Client
+	returnRules       inbound.ReturnRules
+	ownAccounts       []string
+	screeningService  *screening.Service
+	riskService       *risk.Service
 	logger            *slog.Logger
 }
 
 func NewEventsHandlerImpl(
 	db eventsourcing.DB,
-	accountsApiClient *accountsapi.Client,
+	accountsApiClient accounts.Client,
 	paymentsApiClient *paymentsapi.Client,
+	returnRules inbound.ReturnRules,
+	ownAccounts []string,
+	screeningService *screening.Service,
+	riskService *risk.Service,
 	logger *slog.Logger,
 ) *EventsHandlerImpl {
 	return &EventsHandlerImpl{
 		db:                db,
 		accountsApiClient: accountsApiClient,
 		paymentsApiClient: paymentsApiClient,
+		returnRules:       returnRules,
+		ownAccounts:       ownAccounts,
+		screeningService:  screeningService,
+		riskService:       riskService,
 		logger:            logger.With(logattr.Component("dinopay.EventsHandler")),
 	}
 }
 
 func (ev EventsHandlerImpl) HandlePaymentCreated(ctx context.Context, event PaymentCreated) werrors.WError {
+	isOwnPayment, werr := ev.handleOwnPayment(ctx, event)
+	if werr != nil || isOwnPayment {
+		return werr
+	}
+	// the money already left the sender, so a deposit with an amount
+	// the Payments API can't take is kept for an operator to return it
+	err := money.Validate(event.Data.Amount, event.Data.Currency)
+	if err != nil {
+		ev.logger.With(logattr.DinopayPaymentId(event.Data.Id.String())).Warn("invalid deposit amount", logattr.Error(err.Error()))
+		return ev.moveToSuspense(ctx, event, inbound.UnmatchedReasonInvalidAmount)
+	}
 	// TODO get correlation id from PaymentCreated and copy into PaymentReceived
 	eventUUID := wuuid.NewUUID()
 	depositUUID := wuuid.NewUUID()
 	accountNumber := event.Data.DestinationAccount.AccountNumber
-	resp, err := ev.accountsApiClient.ListAccounts(ctx, accountsapi.ListAccountsParams{DinopayAccountNumber: accountsapi.NewOptString(accountNumber)})
+	resp, err := ev.listAccounts(ctx, accountsapi.ListAccountsParams{DinopayAccountNumber: accountsapi.NewOptString(accountNumber)})
 	if err != nil {
 		ev.logger.Error("failed to list accounts", logattr.Error(err.Error()))
 		return werrors.NewRetryableInternalError("failed to list accounts for dinopay payment: %s", err.Error())
@@ -56,17 +89,19 @@ func (ev EventsHandlerImpl) HandlePaymentCreated(ctx context.Context, event Paym
 	case *accountsapi.ListAccountsOKApplicationJSON:
 		accountList := resp.(*accountsapi.ListAccountsOKApplicationJSON)
 		if len(*accountList) == 0 {
-			ev.logger.With("account-number", accountNumber).Error("no account found")
-			return werrors.NewNonRetryableInternalError("no account found")
+			ev.logger.With("account-number", accountNumber).Warn("no account found")
+			return ev.moveToSuspense(ctx, event, inbound.UnmatchedReasonNoAccountFound)
 		}
 		if len(*accountList) > 1 {
-			ev.logger.With("account-number", accountNumber).Error("multiple accounts found")
-			return werrors.NewNonRetryableInternalError("multiple accounts found")
+			ev.logger.With("account-number", accountNumber).Warn("multiple accounts found")
+			return ev.moveToSuspense(ctx, event, inbound.UnmatchedReasonMultipleAccountsFound)
 		}
 		customerUUID = (*accountList)[0].CustomerId
 	case *accountsapi.ListAccountsUnauthorized:
+		// the token is refreshed after a 401, so a rotated token
+		// eventually succeeds and the deposit must not be lost
 		ev.logger.Error("unauthorized to list accounts")
-		return werrors.NewNonRetryableInternalError("unauthorized to list accounts")
+		return werrors.NewRetryableInternalError("unauthorized to list accounts")
 	case *accountsapi.ApiError:
 		ev.logger.Error("error listing accounts")
 		return werrors.NewNonRetryableInternalError("error listing accounts")
@@ -75,6 +110,75 @@ func (ev EventsHandlerImpl) HandlePaymentCreated(ctx context.Context, event Paym
 		return werrors.NewNonRetryableInternalError("unknown response type")
 	}
 
+	deposit := inbound.Deposit{
+		DinopayPaymentId: event.Data.Id,
+		CustomerId:       customerUUID,
+		Amount:           event.Data.Amount,
+		Currency:         event.Data.Currency,
+		SourceAccount: inbound.Account{
+			AccountHolder: event.Data.SourceAccount.AccountHolder,
+			AccountNumber: event.Data.SourceAccount.AccountNumber,
+		},
+		DestinationAccount: inbound.Account{
+			AccountHolder: event.Data.DestinationAccount.AccountHolder,
+			AccountNumber: event.Data.DestinationAccount.AccountNumber,
+		},
+	}
+	// the screening result and the risk decision are recorded
+	// along with the event acting upon them
+	var decisionEvents []events.EventData
+	// deposits in suspense aren't screened, an operator reviews them anyway
+	sender := deposit.SourceAccount.AccountHolder
+	screeningResult, werr := ev.screeningService.Screen(ctx, event.Data.Id.String(), screening.PartySource, sender)
+	if werr != nil {
+		ev.logger.Error("failed screening deposit sender", logattr.Error(werr.Error()))
+		return werr
+	}
+	if screeningResult != nil {
+		decisionEvents = append(decisionEvents, inbound.PaymentScreened{
+			Id:               wuuid.NewUUID(),
+			DinopayPaymentId: deposit.DinopayPaymentId,
+			Party:            screening.PartySource,
+			Name:             sender,
+			Result:           *screeningResult,
+			EventCreatedAt:   time.Now(),
+		})
+		if screeningResult.Hit {
+			return ev.holdForReview(ctx, event, deposit, gateway.HoldReasonSanctionsHit, screening.Details(screening.PartySource, sender, *screeningResult), decisionEvents...)
+		}
+	}
+	decision, werr := ev.riskService.Assess(ctx, riskport.Payment{
+		Id:           event.Data.Id.String(),
+		Direction:    riskport.DirectionInbound,
+		CustomerId:   customerUUID,
+		Amount:       deposit.Amount,
+		Currency:     deposit.Currency,
+		Counterparty: deposit.SourceAccount,
+	})
+	if werr != nil {
+		ev.logger.Error("failed assessing deposit risk", logattr.Error(werr.Error()))
+		return werr
+	}
+	if decision != nil {
+		decisionEvents = append(decisionEvents, inbound.PaymentRiskAssessed{
+			Id:               wuuid.NewUUID(),
+			DinopayPaymentId: deposit.DinopayPaymentId,
+			CustomerId:       deposit.CustomerId,
+			Outcome:          decision.Outcome,
+			Reasons:          decision.Reasons,
+			EventCreatedAt:   time.Now(),
+		})
+		switch decision.Outcome {
+		case riskport.OutcomeBlock:
+			return ev.returnToSender(ctx, event, deposit, inbound.ReturnReasonRiskBlocked, decisionEvents...)
+		case riskport.OutcomeReview:
+			return ev.holdForReview(ctx, event, deposit, gateway.HoldReasonRiskReview, risk.Details(*decision), decisionEvents...)
+		}
+	}
+	if reason, mustReturn := ev.returnRules.Evaluate(deposit); mustReturn {
+		return ev.returnToSender(ctx, event, deposit, reason, decisionEvents...)
+	}
+
 	inboundPaymentReceived := inbound.PaymentReceived{
 		Id:               eventUUID,
 		DinopayPaymentId: event.Data.Id,
@@ -90,14 +194,299 @@ func (ev EventsHandlerImpl) HandlePaymentCreated(ctx context.Context, event Paym
 			AccountHolder: event.Data.DestinationAccount.AccountHolder,
 			AccountNumber: event.Data.DestinationAccount.AccountNumber,
 		},
-		EventCreatedAt: time.Now(),
+		EventCreatedAt:       time.Now(),
+		DinopayPaymentStatus: event.Data.Status,
+	}
+	werr = ev.appendInboundPaymentEvent(ctx, event, append(decisionEvents, inboundPaymentReceived)...)
+	if werr != nil {
+		return werr
+	}
+	ev.logger.Info("DinoPay event PaymentCreated processed successfully", logattr.EventType(event.Type()))
+	return nil
+}
+
+// handleOwnPayment recognises the payments the gateway itself created on DinoPay,
+// so they are never credited as deposits. The webhook of a recorded payout is
+// recorded in the payout stream. It returns false when the payment isn't ours.
+func (ev EventsHandlerImpl) handleOwnPayment(ctx context.Context, event PaymentCreated) (bool, werrors.WError) {
+	logger := ev.logger.With(logattr.DinopayPaymentId(event.Data.Id.String()))
+	payment, werr := outbound.LoadPayment(ctx, ev.db, event.Data.Id)
+	if werr == nil {
+		return true, ev.notifyOutboundPayment(ctx, event, payment)
+	}
+	if werr.Code() != werrors.ResourceNotFoundErrorCode {
+		logger.Error("failed loading outbound payment", logattr.Error(werr.Error()))
+		return true, werrors.NewWrappedError(werr, "failed loading outbound payment")
+	}
+	// the CustomerTransactionId of our payouts is the Walletera payment id
+	paymentId, err := uuid.Parse(event.Data.CustomerTransactionId)
+	if err == nil {
+		submission, werr := outbound.LoadSubmission(ctx, ev.db, paymentId)
+		if werr != nil {
+			logger.Error("failed loading outbound payment submission", logattr.Error(werr.Error()))
+			return true, werrors.NewWrappedError(werr, "failed loading outbound payment submission")
+		}
+		if submission.Exists {
+			// the Submitter records the submission before calling DinoPay, so the webhook
+			// of any payout finds it. The payout stream is appended once DinoPay's answer
+			// is recorded or the outcome is resolved, so the webhook must be delivered again.
+			logger.Warn("dinopay notified an outbound payment not recorded yet", logattr.PaymentId(paymentId.String()))
+			return true, werrors.NewRetryableInternalError("outbound payment " + paymentId.String() + " is not recorded yet")
+		}
+	}
+	if slices.Contains(ev.ownAccounts, event.Data.SourceAccount.AccountNumber) {
+		// the returns to senders, whose CustomerTransactionId isn't a payout id
+		logger.Info("ignoring dinopay payment sent from an own account", slog.String("account-number", event.Data.SourceAccount.AccountNumber))
+		return true, nil
+	}
+	return false, nil
+}
+
+func (ev EventsHandlerImpl) notifyOutboundPayment(ctx context.Context, event PaymentCreated, payment *outbound.Payment) werrors.WError {
+	logger := ev.logger.With(
+		logattr.PaymentId(payment.PaymentId.String()),
+		logattr.DinopayPaymentId(payment.DinopayPaymentId.String()),
+	)
+	if !payment.NotifiedAt.IsZero() {
+		logger.Info("dinopay notification of outbound payment already recorded")
+		return nil
+	}
+	_, werr := ev.db.AppendEvents(
+		ctx,
+		gateway.BuildOutboundPaymentStreamName(payment.DinopayPaymentId.String()),
+		eventsourcing.ExpectedAggregateVersion{Version: payment.Version},
+		outbound.PaymentNotified{
+			Id:               wuuid.NewUUID(),
+			PaymentId:        payment.PaymentId,
+			DinopayPaymentId: payment.DinopayPaymentId,
+			Amount:           event.Data.Amount,
+			Currency:         event.Data.Currency,
+			SourceAccount: mapping.Account{
+				AccountHolder: event.Data.SourceAccount.AccountHolder,
+				AccountNumber: event.Data.SourceAccount.AccountNumber,
+			},
+			DestinationAccount: mapping.Account{
+				AccountHolder: event.Data.DestinationAccount.AccountHolder,
+				AccountNumber: event.Data.DestinationAccount.AccountNumber,
+			},
+			EventCreatedAt: time.Now().UnixMilli(),
+		},
+	)
+	if werr != nil {
+		logger.Error("failed appending OutboundPaymentNotified event", logattr.Error(werr.Error()))
+		return werrors.NewWrappedError(werr, "failed appending OutboundPaymentNotified event")
+	}
+	logger.Info("dinopay PaymentCreated event recorded for outbound payment")
+	return nil
+}
+
+// HandlePaymentUpdated records the status changes DinoPay notifies. The deposits
+// that were pending are settled in their inbound stream, and the payouts are
+// updated the same way their polled status changes are.
+// listAccounts looks the accounts up. A cached "not found" may predate the
+// account, so it's checked again with the Accounts service bypassing the
+// cache before the deposit is moved to suspense.
+func (ev EventsHandlerImpl) listAccounts(ctx context.Context, params accountsapi.ListAccountsParams) (accountsapi.ListAccountsRes, error) {
+	resp, err := ev.accountsApiClient.ListAccounts(ctx, params)
+	if err != nil {
+		return nil, err
+	}
+	accountList, ok := resp.(*accountsapi.ListAccountsOKApplicationJSON)
+	if !ok || len(*accountList) > 0 {
+		return resp, nil
+	}
+	refresher, ok := ev.accountsApiClient.(accounts.Refresher)
+	if !ok {
+		return resp, nil
+	}
+	return refresher.RefreshAccounts(ctx, params)
+}
+
+func (ev EventsHandlerImpl) HandlePaymentUpdated(ctx context.Context, event PaymentUpdated) werrors.WError {
+	logger := ev.logger.With(
+		logattr.DinopayPaymentId(event.Data.Id.String()),
+		slog.String("status", event.Data.Status),
+	)
+	payout, werr := outbound.LoadPayment(ctx, ev.db, event.Data.Id)
+	if werr == nil {
+		return ev.updateOutboundPayment(ctx, event, payout)
+	}
+	if werr.Code() != werrors.ResourceNotFoundErrorCode {
+		logger.Error("failed loading outbound payment", logattr.Error(werr.Error()))
+		return werrors.NewWrappedError(werr, "failed loading outbound payment")
+	}
+	deposit, werr := inbound.LoadPayment(ctx, ev.db, event.Data.Id)
+	if werr != nil {
+		if werr.Code() != werrors.ResourceNotFoundErrorCode {
+			logger.Error("failed loading inbound payment", logattr.Error(werr.Error()))
+			return werrors.NewWrappedError(werr, "failed loading inbound payment")
+		}
+		if slices.Contains(ev.ownAccounts, event.Data.SourceAccount.AccountNumber) {
+			// the returns to senders aren't tracked by their own stream
+			logger.Info("ignoring status of dinopay payment sent from an own account")
+			return nil
+		}
+		// the PaymentCreated webhook of the deposit must be handled first
+		logger.Warn("dinopay updated a payment not recorded yet")
+		return werrors.NewRetryableInternalError("dinopay payment " + event.Data.Id.String() + " is not recorded yet")
+	}
+	if !deposit.IsSettledBy(event.Data.Status) {
+		logger.Info("dinopay status of inbound payment already recorded")
+		return nil
+	}
+	_, werr = ev.db.AppendEvents(
+		ctx,
+		gateway.BuildInboundPaymentStreamName(event.Data.Id.String()),
+		eventsourcing.ExpectedAggregateVersion{Version: deposit.Version},
+		inbound.PaymentStatusUpdated{
+			Id:                   wuuid.NewUUID(),
+			DinopayPaymentId:     event.Data.Id,
+			PaymentId:            deposit.PaymentId,
+			DinopayPaymentStatus: event.Data.Status,
+			EventCreatedAt:       time.Now(),
+		},
+	)
+	if werr != nil {
+		logger.Error("failed appending InboundPaymentStatusUpdated event", logattr.Error(werr.Error()))
+		return werrors.NewWrappedError(werr, "failed appending InboundPaymentStatusUpdated event")
+	}
+	logger.Info("DinoPay event PaymentUpdated processed successfully", logattr.EventType(event.Type()))
+	return nil
+}
+
+func (ev EventsHandlerImpl) updateOutboundPayment(ctx context.Context, event PaymentUpdated, payment *outbound.Payment) werrors.WError {
+	logger := ev.logger.With(
+		logattr.PaymentId(payment.PaymentId.String()),
+		logattr.DinopayPaymentId(payment.DinopayPaymentId.String()),
+		slog.String("status", event.Data.Status),
+	)
+	if event.Data.Status == payment.DinopayPaymentStatus {
+		logger.Info("dinopay status of outbound payment already recorded")
+		return nil
+	}
+	_, werr := ev.db.AppendEvents(
+		ctx,
+		gateway.BuildOutboundPaymentStreamName(payment.DinopayPaymentId.String()),
+		eventsourcing.ExpectedAggregateVersion{Version: payment.Version},
+		outbound.PaymentUpdated{
+			Id:                              wuuid.NewUUID(),
+			DinopayPaymentId:                payment.DinopayPaymentId,
+			DinopayPaymentStatus:            event.Data.Status,
+			OutboundPaymentAggregateVersion: payment.Version + 1,
+			EventCreatedAt:                  time.Now().UnixMilli(),
+		},
+	)
+	if werr != nil {
+		logger.Error("failed appending OutboundPaymentUpdated event", logattr.Error(werr.Error()))
+		return werrors.NewWrappedError(werr, "failed appending OutboundPaymentUpdated event")
+	}
+	logger.Info("outbound payment status updated from dinopay")
+	return nil
+}
+
+// moveToSuspense records the deposit as unmatched so that it is acknowledged
+// to DinoPay and an operator can later assign it or return it to the sender
+func (ev EventsHandlerImpl) moveToSuspense(ctx context.Context, event PaymentCreated, reason string) werrors.WError {
+	inboundPaymentUnmatched := inbound.PaymentUnmatched{
+		Id:               wuuid.NewUUID(),
+		DinopayPaymentId: event.Data.Id,
+		Amount:           event.Data.Amount,
+		Currency:         event.Data.Currency,
+		SourceAccount: inbound.Account{
+			AccountHolder: event.Data.SourceAccount.AccountHolder,
+			AccountNumber: event.Data.SourceAccount.AccountNumber,
+		},
+		DestinationAccount: inbound.Account{
+			AccountHolder: event.Data.DestinationAccount.AccountHolder,
+			AccountNumber: event.Data.DestinationAccount.AccountNumber,
+		},
+		Reason:               reason,
+		EventCreatedAt:       time.Now(),
+		DinopayPaymentStatus: event.Data.Status,
+	}
+	werr := ev.appendInboundPaymentEvent(ctx, event, inboundPaymentUnmatched)
+	if werr != nil {
+		return werr
+	}
+	ev.logger.Info(
+		"DinoPay event PaymentCreated moved to suspense",
+		logattr.EventType(event.Type()),
+		logattr.DinopayPaymentId(event.Data.Id.String()),
+		logattr.Reason(reason),
+	)
+	return nil
+}
+
+// holdForReview records the deposit as held, after the given events, so that it is
+// acknowledged to DinoPay and an operator can later release it to the customer or return it
+func (ev EventsHandlerImpl) holdForReview(ctx context.Context, event PaymentCreated, deposit inbound.Deposit, reason gateway.HoldReason, details string, preceding ...events.EventData) werrors.WError {
+	inboundPaymentHeld := inbound.PaymentHeld{
+		Id:                   wuuid.NewUUID(),
+		DinopayPaymentId:     deposit.DinopayPaymentId,
+		CustomerId:           deposit.CustomerId,
+		Amount:               deposit.Amount,
+		Currency:             deposit.Currency,
+		SourceAccount:        deposit.SourceAccount,
+		DestinationAccount:   deposit.DestinationAccount,
+		Reason:               reason,
+		Details:              details,
+		EventCreatedAt:       time.Now(),
+		DinopayPaymentStatus: event.Data.Status,
 	}
-	streamName := gatewayevents.BuildInboundPaymentStreamName(inboundPaymentReceived.DinopayPaymentId.String())
-	_, werr := ev.db.AppendEvents(ctx, streamName, eventsourcing.ExpectedAggregateVersion{IsNew: true}, inboundPaymentReceived)
+	werr := ev.appendInboundPaymentEvent(ctx, event, append(preceding, inboundPaymentHeld)...)
+	if werr != nil {
+		return werr
+	}
+	ev.logger.Warn(
+		"DinoPay event PaymentCreated held for review",
+		logattr.EventType(event.Type()),
+		logattr.DinopayPaymentId(event.Data.Id.String()),
+		logattr.Reason(string(reason)),
+	)
+	return nil
+}
+
+// returnToSender records the deposit as rejected, after the given events,
+// together with the request to send it back to the DinoPay source account
+func (ev EventsHandlerImpl) returnToSender(ctx context.Context, event PaymentCreated, deposit inbound.Deposit, reason string, preceding ...events.EventData) werrors.WError {
+	now := time.Now()
+	inboundPaymentRejected := inbound.PaymentRejected{
+		Id:                 wuuid.NewUUID(),
+		DinopayPaymentId:   deposit.DinopayPaymentId,
+		CustomerId:         deposit.CustomerId,
+		Amount:             deposit.Amount,
+		Currency:           deposit.Currency,
+		SourceAccount:      deposit.SourceAccount,
+		DestinationAccount: deposit.DestinationAccount,
+		Reason:             reason,
+		EventCreatedAt:     now,
+	}
+	inboundPaymentReturnRequested := inbound.PaymentReturnRequested{
+		Id:               wuuid.NewUUID(),
+		DinopayPaymentId: deposit.DinopayPaymentId,
+		Reason:           reason,
+		RequestedBy:      inbound.ReturnRequestedByRules,
+		EventCreatedAt:   now,
+	}
+	werr := ev.appendInboundPaymentEvent(ctx, event, append(preceding, inboundPaymentRejected, inboundPaymentReturnRequested)...)
+	if werr != nil {
+		return werr
+	}
+	ev.logger.Info(
+		"DinoPay event PaymentCreated rejected and returned to sender",
+		logattr.EventType(event.Type()),
+		logattr.DinopayPaymentId(event.Data.Id.String()),
+		logattr.Reason(reason),
+	)
+	return nil
+}
+
+func (ev EventsHandlerImpl) appendInboundPaymentEvent(ctx context.Context, event PaymentCreated, inboundEvents ...events.EventData) werrors.WError {
+	streamName := gateway.BuildInboundPaymentStreamName(event.Data.Id.String())
+	_, werr := ev.db.AppendEvents(ctx, streamName, eventsourcing.ExpectedAggregateVersion{IsNew: true}, inboundEvents...)
 	if werr != nil {
 		ev.logger.Error("error handling dinopay PaymentCreated event", logattr.Error(werr.Error()))
 		return werrors.NewWrappedError(werr)
 	}
-	ev.logger.Info("DinoPay event PaymentCreated processed successfully", logattr.EventType(event.Type()))
 	return nil
 }
@@ -0,0 +1,265 @@
+package dinopay
+
+import (
+    "context"
+    "log/slog"
+    "testing"
+    "time"
+
+    "github.com/google/uuid"
+    "github.com/shopspring/decimal"
+    "github.com/stretchr/testify/require"
+    accountsapi "github.com/walletera/accounts/publicapi"
+    "github.com/walletera/dinopay-gateway/internal/domain/events/walletera/gateway"
+    "github.com/walletera/dinopay-gateway/internal/domain/events/walletera/gateway/inbound"
+    "github.com/walletera/dinopay-gateway/internal/domain/events/walletera/gateway/outbound"
+    screeningport "github.com/walletera/dinopay-gateway/internal/domain/ports/output/screening"
+    "github.com/walletera/dinopay-gateway/internal/domain/screening"
+    "github.com/walletera/dinopay-gateway/internal/testutil"
+    "github.com/walletera/eventskit/eventsourcing"
+)
+
+const ownAccountNumber = "1200079635"
+
+func paymentCreated(dinopayPaymentId uuid.UUID, customerTransactionId string, sourceAccountNumber string) PaymentCreated {
+    return PaymentCreated{
+        Id:        uuid.New(),
+        EventType: "PaymentCreated",
+        Time:      time.Now(),
+        Data: PaymentData{
+            Id:                    dinopayPaymentId,
+            Amount:                decimal.RequireFromString("100"),
+            Currency:              "USD",
+            SourceAccount:         Account{AccountHolder: "Walletera", AccountNumber: sourceAccountNumber},
+            DestinationAccount:    Account{AccountHolder: "John Doe", AccountNumber: "IE12BOFI90000112345678"},
+            CustomerTransactionId: customerTransactionId,
+        },
+    }
+}
+
+func TestEventsHandlerImpl_HandleOwnPayment(t *testing.T) {
+    ctx := context.Background()
+    db := testutil.NewFakeDB()
+    handler := NewEventsHandlerImpl(db, nil, nil, nil, []string{ownAccountNumber}, nil, nil, slog.New(slog.DiscardHandler))
+
+    paymentId := uuid.New()
+    dinopayPaymentId := uuid.New()
+    _, werr := db.AppendEvents(
+        ctx,
+        gateway.BuildOutboundPaymentStreamName(dinopayPaymentId.String()),
+        eventsourcing.ExpectedAggregateVersion{IsNew: true},
+        outbound.PaymentCreated{
+            Id:                   uuid.New(),
+            PaymentId:            paymentId,
+            DinopayPaymentId:     dinopayPaymentId,
+            DinopayPaymentStatus: "pending",
+            PaymentCreatedAt:     time.Now().UnixMilli(),
+        },
+    )
+    require.NoError(t, werr)
+
+    event := paymentCreated(dinopayPaymentId, paymentId.String(), ownAccountNumber)
+    require.NoError(t, handler.HandlePaymentCreated(ctx, event))
+    require.NoError(t, handler.HandlePaymentCreated(ctx, event), "a redelivered webhook must be acknowledged")
+    payment, werr := outbound.LoadPayment(ctx, db, dinopayPaymentId)
+    require.NoError(t, werr)
+    require.False(t, payment.NotifiedAt.IsZero())
+    require.Equal(t, uint64(1), payment.Version, "the webhook must be recorded once")
+
+    unresolvedPaymentId := uuid.New()
+    _, werr = db.AppendEvents(
+        ctx,
+        gateway.BuildOutboundPaymentStreamName(unresolvedPaymentId.String()),
+        eventsourcing.ExpectedAggregateVersion{IsNew: true},
+        outbound.PaymentOutcomeUnknown{
+            Id:             uuid.New(),
+            PaymentId:      unresolvedPaymentId,
+            EventCreatedAt: time.Now().UnixMilli(),
+        },
+    )
+    require.NoError(t, werr)
+    werr = handler.HandlePaymentCreated(ctx, paymentCreated(uuid.New(), unresolvedPaymentId.String(), "1200079636"))
+    require.Error(t, werr)
+    require.True(t, werr.IsRetryable(), "the webhook of a payout not recorded yet must be redelivered")
+
+    submittingPaymentId := uuid.New()
+    _, werr = db.AppendEvents(
+        ctx,
+        gateway.BuildOutboundPaymentStreamName(submittingPaymentId.String()),
+        eventsourcing.ExpectedAggregateVersion{IsNew: true},
+        outbound.PaymentSubmitting{
+            Id:             uuid.New(),
+            PaymentId:      submittingPaymentId,
+            EventCreatedAt: time.Now().UnixMilli(),
+        },
+    )
+    require.NoError(t, werr)
+    werr = handler.HandlePaymentCreated(ctx, paymentCreated(uuid.New(), submittingPaymentId.String(), "1200079636"))
+    require.Error(t, werr)
+    require.True(t, werr.IsRetryable(), "the webhook of a payout whose DinoPay response isn't recorded yet must be redelivered")
+
+    returnId := uuid.New()
+    require.NoError(t, handler.HandlePaymentCreated(ctx, paymentCreated(returnId, uuid.NewString(), ownAccountNumber)))
+    _, werr = db.ReadEvents(ctx, gateway.BuildInboundPaymentStreamName(returnId.String()))
+    require.Error(t, werr, "payments sent from an own account must not be credited")
+}
+
+func paymentUpdated(dinopayPaymentId uuid.UUID, status string, sourceAccountNumber string) PaymentUpdated {
+    return PaymentUpdated{
+        Id:        uuid.New(),
+        EventType: "PaymentUpdated",
+        Time:      time.Now(),
+        Data: PaymentData{
+            Id:                 dinopayPaymentId,
+            SourceAccount:      Account{AccountHolder: "Walletera", AccountNumber: sourceAccountNumber},
+            DestinationAccount: Account{AccountHolder: "John Doe", AccountNumber: "IE12BOFI90000112345678"},
+            Status:             status,
+        },
+    }
+}
+
+func TestEventsHandlerImpl_HandlePaymentUpdated(t *testing.T) {
+    ctx := context.Background()
+    db := testutil.NewFakeDB()
+    handler := NewEventsHandlerImpl(db, nil, nil, nil, []string{ownAccountNumber}, nil, nil, slog.New(slog.DiscardHandler))
+
+    dinopayPaymentId := uuid.New()
+    paymentId := uuid.New()
+    _, werr := db.AppendEvents(
+        ctx,
+        gateway.BuildInboundPaymentStreamName(dinopayPaymentId.String()),
+        eventsourcing.ExpectedAggregateVersion{IsNew: true},
+        inbound.PaymentReceived{
+            Id:                   uuid.New(),
+            DinopayPaymentId:     dinopayPaymentId,
+            CustomerId:           uuid.New(),
+            PaymentId:            paymentId,
+            Amount:               decimal.RequireFromString("100"),
+            Currency:             "USD",
+            EventCreatedAt:       time.Now(),
+            DinopayPaymentStatus: "pending",
+        },
+    )
+    require.NoError(t, werr)
+
+    event := paymentUpdated(dinopayPaymentId, "confirmed", "1200079636")
+    require.NoError(t, handler.HandlePaymentUpdated(ctx, event))
+    require.NoError(t, handler.HandlePaymentUpdated(ctx, event), "a redelivered webhook must be acknowledged")
+    deposit, werr := inbound.LoadPayment(ctx, db, dinopayPaymentId)
+    require.NoError(t, werr)
+    require.Equal(t, "confirmed", deposit.DinopayPaymentStatus)
+    require.Equal(t, uint64(1), deposit.Version, "the settlement must be recorded once")
+    require.NoError(t, handler.HandlePaymentUpdated(ctx, paymentUpdated(dinopayPaymentId, "pending", "1200079636")))
+    deposit, werr = inbound.LoadPayment(ctx, db, dinopayPaymentId)
+    require.NoError(t, werr)
+    require.Equal(t, "confirmed", deposit.DinopayPaymentStatus, "a settled deposit must not go back to pending")
+
+    werr = handler.HandlePaymentUpdated(ctx, paymentUpdated(uuid.New(), "rejected", "1200079636"))
+    require.Error(t, werr)
+    require.True(t, werr.IsRetryable(), "the update of a deposit not recorded yet must be redelivered")
+
+    require.NoError(t, handler.HandlePaymentUpdated(ctx, paymentUpdated(uuid.New(), "confirmed", ownAccountNumber)))
+}
+
+func TestEventsHandlerImpl_HandlePaymentCreated_InvalidAmount(t *testing.T) {
+    ctx := context.Background()
+    db := testutil.NewFakeDB()
+    // without an accounts client, looking the account up panics
+    handler := NewEventsHandlerImpl(db, nil, nil, nil, []string{ownAccountNumber}, nil, nil, slog.New(slog.DiscardHandler))
+
+    for _, tt := range []struct {
+        amount   string
+        currency string
+    }{
+        {amount: "100.555", currency: "USD"},
+        {amount: "100", currency: "XYZ"},
+        {amount: "0", currency: "USD"},
+    } {
+        dinopayPaymentId := uuid.New()
+        event := paymentCreated(dinopayPaymentId, uuid.NewString(), "IE12BOFI90000112349876")
+        event.Data.Amount = decimal.RequireFromString(tt.amount)
+        event.Data.Currency = tt.currency
+        require.NoError(t, handler.HandlePaymentCreated(ctx, event), "a deposit with an invalid amount must not be dropped")
+
+        payment, werr := inbound.LoadPayment(ctx, db, dinopayPaymentId)
+        require.NoError(t, werr)
+        require.Equal(t, inbound.PaymentStatusUnmatched, payment.Status)
+        require.Equal(t, inbound.UnmatchedReasonInvalidAmount, payment.UnmatchedReason)
+    }
+}
+
+type fakeAccountsClient struct {
+    customerId uuid.UUID
+}
+
+func (c fakeAccountsClient) ListAccounts(_ context.Context, _ accountsapi.ListAccountsParams) (accountsapi.ListAccountsRes, error) {
+    return &accountsapi.ListAccountsOKApplicationJSON{{ID: uuid.New(), CustomerId: c.customerId}}, nil
+}
+
+type fakeScreener struct {
+    result screeningport.Result
+}
+
+func (s fakeScreener) Screen(_ context.Context, _ string) (screeningport.Result, error) {
+    return s.result, nil
+}
+
+func TestEventsHandlerImpl_HandlePaymentCreated_SanctionsHit(t *testing.T) {
+    ctx := context.Background()
+    db := testutil.NewFakeDB()
+    hit := screeningport.Result{
+        Hit:         true,
+        Matches:     []screeningport.Match{{EntryId: "SDN-1", Name: "Ivan Petrov", Score: 0.95}},
+        ListVersion: "v1",
+    }
+    screeningService := screening.NewService(fakeScreener{result: hit}, slog.New(slog.DiscardHandler))
+    // the deposit is held before its risk is assessed, so there is no risk service
+    handler := NewEventsHandlerImpl(db, fakeAccountsClient{customerId: uuid.New()}, nil, nil, []string{ownAccountNumber}, screeningService, nil, slog.New(slog.DiscardHandler))
+    dinopayPaymentId := uuid.New()
+
+    require.NoError(t, handler.HandlePaymentCreated(ctx, paymentCreated(dinopayPaymentId, uuid.NewString(), "IE12BOFI90000112349876")))
+
+    // the screening result is recorded in the deposit stream, along with the hold acting upon it
+    stream := db.Stream(gateway.BuildInboundPaymentStreamName(dinopayPaymentId.String()))
+    require.Len(t, stream, 2)
+    event, err := inbound.NewEventsDeserializer().Deserialize(stream[0])
+    require.NoError(t, err)
+    paymentScreened, ok := event.(inbound.PaymentScreened)
+    require.True(t, ok)
+    require.Equal(t, screening.PartySource, paymentScreened.Party)
+    require.Equal(t, hit, paymentScreened.Result)
+    payment, werr := inbound.LoadPayment(ctx, db, dinopayPaymentId)
+    require.NoError(t, werr)
+    require.Equal(t, inbound.PaymentStatusHeld, payment.Status)
+    require.Equal(t, gateway.HoldReasonSanctionsHit, payment.HeldReason)
+}
+
+// cachingAccountsClient serves a cached "not found" until it is refreshed
+type cachingAccountsClient struct {
+    customerId uuid.UUID
+}
+
+func (c cachingAccountsClient) ListAccounts(_ context.Context, _ accountsapi.ListAccountsParams) (accountsapi.ListAccountsRes, error) {
+    return &accountsapi.ListAccountsOKApplicationJSON{}, nil
+}
+
+func (c cachingAccountsClient) RefreshAccounts(_ context.Context, _ accountsapi.ListAccountsParams) (accountsapi.ListAccountsRes, error) {
+    return &accountsapi.ListAccountsOKApplicationJSON{{ID: uuid.New(), CustomerId: c.customerId}}, nil
+}
+
+func TestEventsHandlerImpl_HandlePaymentCreated_RefreshesTheCachedNotFound(t *testing.T) {
+    ctx := context.Background()
+    db := testutil.NewFakeDB()
+    customerId := uuid.New()
+    // the sanctions hit holds the deposit, so it's not credited
+    screeningService := screening.NewService(fakeScreener{result: screeningport.Result{Hit: true}}, slog.New(slog.DiscardHandler))
+    handler := NewEventsHandlerImpl(db, cachingAccountsClient{customerId: customerId}, nil, nil, []string{ownAccountNumber}, screeningService, nil, slog.New(slog.DiscardHandler))
+    dinopayPaymentId := uuid.New()
+
+    require.NoError(t, handler.HandlePaymentCreated(ctx, paymentCreated(dinopayPaymentId, uuid.NewString(), "IE12BOFI90000112349876")))
+
+    payment, werr := inbound.LoadPayment(ctx, db, dinopayPaymentId)
+    require.NoError(t, werr)
+    require.Equal(t, inbound.PaymentStatusHeld, payment.Status)
+    require.Equal(t, customerId, payment.CustomerId)
+}
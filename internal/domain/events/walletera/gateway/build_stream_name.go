@@ -0,0 +1,56 @@
+package gateway
+
+import "fmt"
+
+const (
+    OutboundPaymentStreamNamePrefix     = "outboundPayment"
+    InboundPaymentStreamNamePrefix      = "inboundPayment"
+    LeaseStreamNamePrefix               = "gatewayLease"
+    ReconciliationStreamNamePrefix      = "reconciliation"
+    CustomerVelocityStreamNamePrefix    = "velocityCustomer"
+    BeneficiaryVelocityStreamNamePrefix = "velocityBeneficiary"
+    CustomerRiskStreamNamePrefix        = "riskCustomer"
+    PollingCheckpointStreamNamePrefix   = "dinopayPolling"
+    ProcessedEventStreamNamePrefix      = "processedEvent"
+)
+
+// BuildOutboundPaymentStreamName names the two streams of an outbound payment.
+// Named after the Walletera payment id, it holds the decisions taken before and
+// while submitting the payment to DinoPay, including its PaymentFailed when DinoPay
+// or the gateway rejects it. Named after the DinoPay payment id, it follows the
+// payment once DinoPay created it, that is the stream read by outbound.LoadPayment.
+func BuildOutboundPaymentStreamName(id string) string {
+    return fmt.Sprintf("%s.%s", OutboundPaymentStreamNamePrefix, id)
+}
+
+func BuildInboundPaymentStreamName(id string) string {
+    return fmt.Sprintf("%s.%s", InboundPaymentStreamNamePrefix, id)
+}
+
+func BuildLeaseStreamName(name string) string {
+    return fmt.Sprintf("%s.%s", LeaseStreamNamePrefix, name)
+}
+
+func BuildReconciliationStreamName(statementId string) string {
+    return fmt.Sprintf("%s.%s", ReconciliationStreamNamePrefix, statementId)
+}
+
+func BuildCustomerVelocityStreamName(customerId string) string {
+    return fmt.Sprintf("%s.%s", CustomerVelocityStreamNamePrefix, customerId)
+}
+
+func BuildBeneficiaryVelocityStreamName(accountNumber string) string {
+    return fmt.Sprintf("%s.%s", BeneficiaryVelocityStreamNamePrefix, accountNumber)
+}
+
+func BuildCustomerRiskStreamName(customerId string) string {
+    return fmt.Sprintf("%s.%s", CustomerRiskStreamNamePrefix, customerId)
+}
+
+func BuildPollingCheckpointStreamName(name string) string {
+    return fmt.Sprintf("%s.%s", PollingCheckpointStreamNamePrefix, name)
+}
+
+func BuildProcessedEventStreamName(consumer string, eventId string) string {
+    return fmt.Sprintf("%s.%s-%s", ProcessedEventStreamNamePrefix, consumer, eventId)
+}
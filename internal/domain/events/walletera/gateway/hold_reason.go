@@ -0,0 +1,11 @@
+package gateway
+
+// HoldReason tells why a payment, outbound or inbound, was held for review
+type HoldReason string
+
+const (
+    // HoldReasonVelocityLimit holds the payouts breaching the velocity limits
+    HoldReasonVelocityLimit HoldReason = "velocity_limit"
+    HoldReasonSanctionsHit  HoldReason = "sanctions_hit"
+    HoldReasonRiskReview    HoldReason = "risk_review"
+)
@@ -30,6 +30,62 @@ func (d *EventsDeserializer) Deserialize(rawPayload []byte) (events.Event[Events
             log.Printf("error deserializing InboundPaymentReceived event data %s: %s", event.Data, err.Error())
         }
         return paymentReceived, nil
+    case "InboundPaymentUnmatched":
+        var paymentUnmatched PaymentUnmatched
+        err := json.Unmarshal(event.Data, &paymentUnmatched)
+        if err != nil {
+            return nil, fmt.Errorf("error deserializing InboundPaymentUnmatched event data %s: %w", event.Data, err)
+        }
+        return paymentUnmatched, nil
+    case "InboundPaymentHeld":
+        var paymentHeld PaymentHeld
+        err := json.Unmarshal(event.Data, &paymentHeld)
+        if err != nil {
+            return nil, fmt.Errorf("error deserializing InboundPaymentHeld event data %s: %w", event.Data, err)
+        }
+        return paymentHeld, nil
+    case "InboundPaymentRiskAssessed":
+        var paymentRiskAssessed PaymentRiskAssessed
+        err := json.Unmarshal(event.Data, &paymentRiskAssessed)
+        if err != nil {
+            return nil, fmt.Errorf("error deserializing InboundPaymentRiskAssessed event data %s: %w", event.Data, err)
+        }
+        return paymentRiskAssessed, nil
+    case "InboundPaymentScreened":
+        var paymentScreened PaymentScreened
+        err := json.Unmarshal(event.Data, &paymentScreened)
+        if err != nil {
+            return nil, fmt.Errorf("error deserializing InboundPaymentScreened event data %s: %w", event.Data, err)
+        }
+        return paymentScreened, nil
+    case "InboundPaymentRejected":
+        var paymentRejected PaymentRejected
+        err := json.Unmarshal(event.Data, &paymentRejected)
+        if err != nil {
+            return nil, fmt.Errorf("error deserializing InboundPaymentRejected event data %s: %w", event.Data, err)
+        }
+        return paymentRejected, nil
+    case "InboundPaymentReturnRequested":
+        var paymentReturnRequested PaymentReturnRequested
+        err := json.Unmarshal(event.Data, &paymentReturnRequested)
+        if err != nil {
+            return nil, fmt.Errorf("error deserializing InboundPaymentReturnRequested event data %s: %w", event.Data, err)
+        }
+        return paymentReturnRequested, nil
+    case "InboundPaymentReturned":
+        var paymentReturned PaymentReturned
+        err := json.Unmarshal(event.Data, &paymentReturned)
+        if err != nil {
+            return nil, fmt.Errorf("error deserializing InboundPaymentReturned event data %s: %w", event.Data, err)
+        }
+        return paymentReturned, nil
+    case "InboundPaymentStatusUpdated":
+        var paymentStatusUpdated PaymentStatusUpdated
+        err := json.Unmarshal(event.Data, &paymentStatusUpdated)
+        if err != nil {
+            return nil, fmt.Errorf("error deserializing InboundPaymentStatusUpdated event data %s: %w", event.Data, err)
+        }
+        return paymentStatusUpdated, nil
     default:
         return nil, fmt.Errorf("unexpected event type: %s", event.Type)
     }
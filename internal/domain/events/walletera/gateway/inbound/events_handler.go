@@ -2,60 +2,275 @@ package inbound
 
 import (
     "context"
+    "fmt"
     "log/slog"
+    "time"
 
+    "github.com/google/uuid"
+    "github.com/walletera/dinopay-gateway/internal/domain/events/walletera/gateway"
+    "github.com/walletera/dinopay-gateway/internal/domain/mapping"
+    "github.com/walletera/dinopay-gateway/internal/domain/ports/output/dinopay"
     "github.com/walletera/dinopay-gateway/pkg/logattr"
-    builders "github.com/walletera/payments-types/builders/privateapi"
+    "github.com/walletera/dinopay-gateway/pkg/wuuid"
+    dinopayapi "github.com/walletera/dinopay/api"
+    "github.com/walletera/eventskit/eventsourcing"
     paymentsapi "github.com/walletera/payments-types/privateapi"
     "github.com/walletera/werrors"
 )
 
+// returnNamespace is used to derive the id of a return from the id of the returned deposit
+var returnNamespace = uuid.MustParse("abbd7b7a-cb85-4920-a921-f48d7258d498")
+
 type EventsHandler interface {
     HandleInboundPaymentReceived(ctx context.Context, inboundPaymentReceived PaymentReceived) werrors.WError
+    HandleInboundPaymentUnmatched(ctx context.Context, inboundPaymentUnmatched PaymentUnmatched) werrors.WError
+    HandleInboundPaymentHeld(ctx context.Context, inboundPaymentHeld PaymentHeld) werrors.WError
+    HandleInboundPaymentRiskAssessed(ctx context.Context, inboundPaymentRiskAssessed PaymentRiskAssessed) werrors.WError
+    HandleInboundPaymentScreened(ctx context.Context, inboundPaymentScreened PaymentScreened) werrors.WError
+    HandleInboundPaymentRejected(ctx context.Context, inboundPaymentRejected PaymentRejected) werrors.WError
+    HandleInboundPaymentReturnRequested(ctx context.Context, inboundPaymentReturnRequested PaymentReturnRequested) werrors.WError
+    HandleInboundPaymentReturned(ctx context.Context, inboundPaymentReturned PaymentReturned) werrors.WError
+    HandleInboundPaymentStatusUpdated(ctx context.Context, inboundPaymentStatusUpdated PaymentStatusUpdated) werrors.WError
 }
 
 type EventsHandlerImpl struct {
+    db                eventsourcing.DB
     paymentsApiClient *paymentsapi.Client
+    dinopayClient     dinopay.Client
     deserializer      *EventsDeserializer
     logger            *slog.Logger
 }
 
-func NewEventsHandlerImpl(client *paymentsapi.Client, logger *slog.Logger) *EventsHandlerImpl {
+func NewEventsHandlerImpl(db eventsourcing.DB, client *paymentsapi.Client, dinopayClient dinopay.Client, logger *slog.Logger) *EventsHandlerImpl {
     return &EventsHandlerImpl{
+        db:                db,
         paymentsApiClient: client,
+        dinopayClient:     dinopayClient,
         deserializer:      NewEventsDeserializer(),
         logger:            logger.With(logattr.Component("gateway.inbound.EventsHandlerImpl")),
     }
 }
 
 func (ev *EventsHandlerImpl) HandleInboundPaymentReceived(ctx context.Context, inboundPaymentReceived PaymentReceived) werrors.WError {
-    postPaymentReq := &paymentsapi.PostPaymentReq{
-        ID:         inboundPaymentReceived.PaymentId,
-        Amount:     inboundPaymentReceived.Amount,
-        Currency:   paymentsapi.Currency(inboundPaymentReceived.Currency),
-        Gateway:    paymentsapi.GatewayDinopay,
-        Direction:  paymentsapi.DirectionInbound,
+    status, werr := PaymentsStatus(inboundPaymentReceived.DinopayPaymentStatus)
+    if werr != nil {
+        ev.logger.Error("failed mapping inbound payment status", logattr.Error(werr.Error()))
+        return werr
+    }
+    postPaymentReq, err := mapping.ToPostPaymentReq(inboundPaymentReceived.DinopayPayment(), mapping.PaymentRecord{
+        PaymentId:  inboundPaymentReceived.PaymentId,
         CustomerId: inboundPaymentReceived.CustomerId,
-        Status:     paymentsapi.PaymentStatusConfirmed,
-        ExternalId: paymentsapi.NewOptString(inboundPaymentReceived.DinopayPaymentId.String()),
-        Debtor: builders.NewDinopayAccountBuilder().
-            WithCurrency(paymentsapi.Currency(inboundPaymentReceived.Currency)).
-            WithAccountHolder(inboundPaymentReceived.SourceAccount.AccountHolder).
-            WithAccountNumber(inboundPaymentReceived.SourceAccount.AccountNumber).
-            Build(),
-        Beneficiary: builders.NewDinopayAccountBuilder().
-            WithCurrency(paymentsapi.Currency(inboundPaymentReceived.Currency)).
-            WithAccountHolder(inboundPaymentReceived.DestinationAccount.AccountHolder).
-            WithAccountNumber(inboundPaymentReceived.DestinationAccount.AccountNumber).
-            Build(),
-    }
-    _, err := ev.paymentsApiClient.PostPayment(ctx, postPaymentReq, paymentsapi.PostPaymentParams{})
+        Direction:  paymentsapi.DirectionInbound,
+        Status:     status,
+    })
     if err != nil {
-        // TODO handle this error properly
-        ev.logger.Error("failed creating payment on payments api", logattr.Error(err.Error()))
-        return werrors.NewRetryableInternalError(err.Error())
+        ev.logger.Error("failed mapping inbound payment", logattr.Error(err.Error()))
+        return werrors.NewNonRetryableInternalError(err.Error())
+    }
+    resp, err := ev.paymentsApiClient.PostPayment(ctx, postPaymentReq, paymentsapi.PostPaymentParams{})
+    werr = gateway.PostPaymentError(resp, err)
+    if werr != nil {
+        ev.logger.Error(werr.Message(), logattr.PaymentId(inboundPaymentReceived.PaymentId.String()))
+        return werr
+    }
+    if _, ok := resp.(*paymentsapi.PostPaymentConflict); ok {
+        // the payment was created by a previous attempt
+        ev.logger.Info("payment already exists on payments api", logattr.PaymentId(inboundPaymentReceived.PaymentId.String()))
     }
-    // TODO handle response
     ev.logger.Info("Gateway event InboundPaymentReceived processed successfully", logattr.EventType(inboundPaymentReceived.Type()))
     return nil
 }
+
+func (ev *EventsHandlerImpl) HandleInboundPaymentUnmatched(_ context.Context, inboundPaymentUnmatched PaymentUnmatched) werrors.WError {
+    // Nothing to do until an operator resolves the suspense
+    ev.logger.Warn(
+        "inbound payment is waiting in suspense",
+        logattr.DinopayPaymentId(inboundPaymentUnmatched.DinopayPaymentId.String()),
+        logattr.Reason(inboundPaymentUnmatched.Reason),
+    )
+    return nil
+}
+
+func (ev *EventsHandlerImpl) HandleInboundPaymentHeld(_ context.Context, inboundPaymentHeld PaymentHeld) werrors.WError {
+    // Nothing to do until an operator releases or returns the deposit
+    ev.logger.Warn(
+        "inbound payment is held for review",
+        logattr.DinopayPaymentId(inboundPaymentHeld.DinopayPaymentId.String()),
+        logattr.Reason(string(inboundPaymentHeld.Reason)),
+    )
+    return nil
+}
+
+func (ev *EventsHandlerImpl) HandleInboundPaymentRiskAssessed(_ context.Context, inboundPaymentRiskAssessed PaymentRiskAssessed) werrors.WError {
+    // The event appended along with this one acts upon the decision
+    ev.logger.Info(
+        "inbound payment risk assessed",
+        logattr.DinopayPaymentId(inboundPaymentRiskAssessed.DinopayPaymentId.String()),
+        slog.String("outcome", string(inboundPaymentRiskAssessed.Outcome)),
+    )
+    return nil
+}
+
+func (ev *EventsHandlerImpl) HandleInboundPaymentScreened(_ context.Context, inboundPaymentScreened PaymentScreened) werrors.WError {
+    // The event appended along with this one acts upon the result
+    ev.logger.Info(
+        "inbound payment screened",
+        logattr.DinopayPaymentId(inboundPaymentScreened.DinopayPaymentId.String()),
+        slog.String("party", string(inboundPaymentScreened.Party)),
+        slog.Bool("hit", inboundPaymentScreened.Result.Hit),
+    )
+    return nil
+}
+
+func (ev *EventsHandlerImpl) HandleInboundPaymentRejected(_ context.Context, inboundPaymentRejected PaymentRejected) werrors.WError {
+    // The PaymentReturnRequested appended along with this event does the work
+    ev.logger.Info(
+        "inbound payment rejected",
+        logattr.DinopayPaymentId(inboundPaymentRejected.DinopayPaymentId.String()),
+        logattr.Reason(inboundPaymentRejected.Reason),
+    )
+    return nil
+}
+
+func (ev *EventsHandlerImpl) HandleInboundPaymentReturnRequested(ctx context.Context, inboundPaymentReturnRequested PaymentReturnRequested) werrors.WError {
+    logger := ev.logger.With(
+        logattr.EventType(inboundPaymentReturnRequested.Type()),
+        logattr.DinopayPaymentId(inboundPaymentReturnRequested.DinopayPaymentId.String()),
+    )
+    payment, werr := LoadPayment(ctx, ev.db, inboundPaymentReturnRequested.DinopayPaymentId)
+    if werr != nil {
+        logger.Error("failed loading inbound payment", logattr.Error(werr.Error()))
+        return werrors.NewWrappedError(werr, "failed loading inbound payment")
+    }
+    if payment.Status == PaymentStatusReturned {
+        logger.Info("inbound payment was already returned")
+        return nil
+    }
+    dinopayReq := mapping.ToDinopayReturnRequest(payment.ReturnDinopayPayment(), ReturnPaymentId(payment.DinopayPaymentId).String())
+    dinopayResp, err := ev.dinopayClient.CreatePayment(ctx, dinopayReq)
+    if err != nil {
+        werr := werrors.NewRetryableInternalError("failed creating return payment on dinopay: %s", err.Error())
+        logger.Error(werr.Error())
+        return werr
+    }
+    dinopayPayment, ok := dinopayResp.(*dinopayapi.Payment)
+    if !ok {
+        werr := werrors.NewNonRetryableInternalError(fmt.Sprintf("unexpected dinopay response type %T", dinopayResp))
+        logger.Error(werr.Error())
+        return werr
+    }
+    paymentReturned := PaymentReturned{
+        Id:                         wuuid.NewUUID(),
+        DinopayPaymentId:           payment.DinopayPaymentId,
+        DinopayReturnPaymentId:     dinopayPayment.ID.Value,
+        DinopayReturnPaymentStatus: string(dinopayPayment.Status.Value),
+        EventCreatedAt:             time.Now(),
+    }
+    _, werr = ev.db.AppendEvents(
+        ctx,
+        streamNameOf(payment),
+        eventsourcing.ExpectedAggregateVersion{Version: payment.Version},
+        paymentReturned,
+    )
+    if werr != nil {
+        logger.Error("failed appending InboundPaymentReturned event", logattr.Error(werr.Error()))
+        return werrors.NewWrappedError(werr, "failed appending InboundPaymentReturned event")
+    }
+    logger.Info("Gateway event InboundPaymentReturnRequested processed successfully")
+    return nil
+}
+
+func (ev *EventsHandlerImpl) HandleInboundPaymentReturned(ctx context.Context, inboundPaymentReturned PaymentReturned) werrors.WError {
+    logger := ev.logger.With(
+        logattr.EventType(inboundPaymentReturned.Type()),
+        logattr.DinopayPaymentId(inboundPaymentReturned.DinopayPaymentId.String()),
+    )
+    payment, werr := LoadPayment(ctx, ev.db, inboundPaymentReturned.DinopayPaymentId)
+    if werr != nil {
+        logger.Error("failed loading inbound payment", logattr.Error(werr.Error()))
+        return werrors.NewWrappedError(werr, "failed loading inbound payment")
+    }
+    if payment.PaymentId == uuid.Nil {
+        // deposits returned from suspense, from review or by the rules were never credited to a customer
+        logger.Info("inbound payment returned to sender")
+        return nil
+    }
+    postPaymentReq, err := mapping.ToPostPaymentReq(payment.ReturnDinopayPayment(), mapping.PaymentRecord{
+        PaymentId:  ReturnPaymentId(payment.DinopayPaymentId),
+        CustomerId: payment.CustomerId,
+        Direction:  paymentsapi.DirectionOutbound,
+        Status:     returnPaymentStatus(inboundPaymentReturned.DinopayReturnPaymentStatus),
+    })
+    if err != nil {
+        logger.Error("failed mapping return payment", logattr.Error(err.Error()))
+        return werrors.NewNonRetryableInternalError(err.Error())
+    }
+    resp, err := ev.paymentsApiClient.PostPayment(ctx, postPaymentReq, paymentsapi.PostPaymentParams{})
+    // a conflict means the return was already recorded by a previous attempt
+    werr = gateway.PostPaymentError(resp, err)
+    if werr != nil {
+        logger.Error("failed creating return payment on payments api", logattr.Error(werr.Error()))
+        return werr
+    }
+    logger.Info("inbound payment returned to sender")
+    return nil
+}
+
+// HandleInboundPaymentStatusUpdated moves a deposit credited while it was
+// pending on DinoPay to its final status on the Payments API. The deposits
+// credited later are created with the updated status.
+func (ev *EventsHandlerImpl) HandleInboundPaymentStatusUpdated(ctx context.Context, inboundPaymentStatusUpdated PaymentStatusUpdated) werrors.WError {
+    logger := ev.logger.With(
+        logattr.DinopayPaymentId(inboundPaymentStatusUpdated.DinopayPaymentId.String()),
+        slog.String("status", inboundPaymentStatusUpdated.DinopayPaymentStatus),
+    )
+    if inboundPaymentStatusUpdated.PaymentId == uuid.Nil {
+        logger.Info("status of inbound payment not credited yet updated")
+        return nil
+    }
+    status, werr := PaymentsStatus(inboundPaymentStatusUpdated.DinopayPaymentStatus)
+    if werr != nil {
+        logger.Error("failed mapping inbound payment status", logattr.Error(werr.Error()))
+        return werr
+    }
+    resp, err := ev.paymentsApiClient.PatchPayment(
+        ctx,
+        &paymentsapi.PaymentUpdate{
+            PaymentId:  inboundPaymentStatusUpdated.PaymentId,
+            ExternalId: paymentsapi.NewOptString(inboundPaymentStatusUpdated.DinopayPaymentId.String()),
+            Status:     status,
+        },
+        paymentsapi.PatchPaymentParams{
+            PaymentId: inboundPaymentStatusUpdated.PaymentId,
+        })
+    if gateway.IsPaymentNotFound(err) {
+        // the InboundPaymentReceived creating the payment is processed concurrently and may not be done yet
+        logger.Warn("inbound payment not created on payments api yet", logattr.PaymentId(inboundPaymentStatusUpdated.PaymentId.String()))
+        return werrors.NewRetryableInternalError("inbound payment not created on payments api yet")
+    }
+    werr = gateway.PatchPaymentError(resp, err)
+    if werr != nil {
+        logger.Error(werr.Message(), logattr.PaymentId(inboundPaymentStatusUpdated.PaymentId.String()))
+        return werr
+    }
+    logger.Info("Gateway event InboundPaymentStatusUpdated processed successfully", logattr.PaymentId(inboundPaymentStatusUpdated.PaymentId.String()))
+    return nil
+}
+
+// ReturnPaymentId is derived from the id of the returned deposit and used both
+// as the DinoPay CustomerTransactionId and as the Payments API id of the return,
+// so retries never create a second return
+func ReturnPaymentId(dinopayPaymentId uuid.UUID) uuid.UUID {
+    return uuid.NewSHA1(returnNamespace, []byte(dinopayPaymentId.String()))
+}
+
+func returnPaymentStatus(dinopayStatus string) paymentsapi.PaymentStatus {
+    switch dinopayapi.PaymentStatus(dinopayStatus) {
+    case dinopayapi.PaymentStatusConfirmed:
+        return paymentsapi.PaymentStatusConfirmed
+    case dinopayapi.PaymentStatusRejected:
+        return paymentsapi.PaymentStatusRejected
+    default:
+        return paymentsapi.PaymentStatusPending
+    }
+}
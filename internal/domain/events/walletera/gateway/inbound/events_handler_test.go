@@ -0,0 +1,46 @@
+package inbound
+
+import (
+    "context"
+    "log/slog"
+    "testing"
+    "time"
+
+    "github.com/google/uuid"
+    "github.com/shopspring/decimal"
+    "github.com/stretchr/testify/require"
+    "github.com/walletera/dinopay-gateway/internal/domain/events/walletera/gateway"
+    "github.com/walletera/dinopay-gateway/internal/testutil"
+    "github.com/walletera/eventskit/eventsourcing"
+)
+
+func TestEventsHandlerImpl_HandleInboundPaymentReturned_NotCredited(t *testing.T) {
+    ctx := context.Background()
+    db := testutil.NewFakeDB()
+    dinopayPaymentId := uuid.New()
+    // the deposit matched a customer but the rules returned it before crediting it
+    _, werr := db.AppendEvents(
+        ctx,
+        gateway.BuildInboundPaymentStreamName(dinopayPaymentId.String()),
+        eventsourcing.ExpectedAggregateVersion{IsNew: true},
+        PaymentRejected{
+            Id:               uuid.New(),
+            DinopayPaymentId: dinopayPaymentId,
+            CustomerId:       uuid.New(),
+            Amount:           decimal.NewFromInt(100),
+            Currency:         "USD",
+            Reason:           "blocked source account",
+            EventCreatedAt:   time.Now(),
+        },
+    )
+    require.Nil(t, werr)
+
+    // without a payments api client, any call to the Payments API panics
+    handler := NewEventsHandlerImpl(db, nil, nil, slog.Default())
+    werr = handler.HandleInboundPaymentReturned(ctx, PaymentReturned{
+        Id:               uuid.New(),
+        DinopayPaymentId: dinopayPaymentId,
+        EventCreatedAt:   time.Now(),
+    })
+    require.Nil(t, werr)
+}
@@ -0,0 +1,74 @@
+package inbound
+
+import (
+    "context"
+    "encoding/json"
+    "fmt"
+    "time"
+
+    "github.com/google/uuid"
+    "github.com/shopspring/decimal"
+    "github.com/walletera/dinopay-gateway/internal/domain/events/walletera/gateway"
+    "github.com/walletera/eventskit/events"
+    "github.com/walletera/werrors"
+)
+
+var _ events.Event[EventsHandler] = PaymentHeld{}
+
+// PaymentHeld is recorded when a DinoPay deposit matched with a customer
+// must be reviewed before being credited. The deposit stays held until an
+// operator releases it to the customer or returns it to the sender.
+type PaymentHeld struct {
+    Id                 uuid.UUID          `json:"id,omitempty"`
+    DinopayPaymentId   uuid.UUID          `json:"externalId,omitempty"`
+    CustomerId         uuid.UUID          `json:"customerId,omitempty"`
+    Amount             decimal.Decimal    `json:"amount"`
+    Currency           string             `json:"currency"`
+    SourceAccount      Account            `json:"sourceAccount"`
+    DestinationAccount Account            `json:"destinationAccount"`
+    Reason             gateway.HoldReason `json:"reason"`
+    Details            string             `json:"details,omitempty"`
+    EventCreatedAt     time.Time          `json:"eventCreatedAt,omitempty"`
+    // DinopayPaymentStatus is the DinoPay status of the deposit when it was received
+    DinopayPaymentStatus string `json:"dinopayPaymentStatus,omitempty"`
+}
+
+func (h PaymentHeld) ID() string {
+    return h.Id.String()
+}
+
+func (h PaymentHeld) Type() string {
+    return "InboundPaymentHeld"
+}
+
+func (h PaymentHeld) DataContentType() string {
+    return "application/json"
+}
+
+func (h PaymentHeld) CorrelationID() string {
+    return ""
+}
+
+func (h PaymentHeld) AggregateVersion() uint64 {
+    return 0
+}
+
+func (h PaymentHeld) CreatedAt() time.Time {
+    return h.EventCreatedAt
+}
+
+func (h PaymentHeld) Accept(ctx context.Context, handler EventsHandler) werrors.WError {
+    return handler.HandleInboundPaymentHeld(ctx, h)
+}
+
+func (h PaymentHeld) Serialize() ([]byte, error) {
+    data, err := json.Marshal(h)
+    if err != nil {
+        return nil, fmt.Errorf("failed serializing InboundPaymentHeld event: %w", err)
+    }
+    envelope := gateway.EventEnvelope{
+        Type: "InboundPaymentHeld",
+        Data: data,
+    }
+    return json.Marshal(envelope)
+}
@@ -7,7 +7,9 @@ import (
     "time"
 
     "github.com/google/uuid"
+    "github.com/shopspring/decimal"
     "github.com/walletera/dinopay-gateway/internal/domain/events/walletera/gateway"
+    "github.com/walletera/dinopay-gateway/internal/domain/mapping"
     "github.com/walletera/eventskit/events"
     "github.com/walletera/werrors"
 )
@@ -15,19 +17,34 @@ import (
 var _ events.Event[EventsHandler] = PaymentReceived{}
 
 type PaymentReceived struct {
-    Id                 uuid.UUID `json:"id,omitempty"`
-    DinopayPaymentId   uuid.UUID `json:"externalId,omitempty"`
-    CustomerId         uuid.UUID `json:"customerId,omitempty"`
-    PaymentId          uuid.UUID `json:"depositId,omitempty"`
-    Amount             float64   `json:"amount"`
-    Currency           string    `json:"currency"`
-    SourceAccount      Account   `json:"sourceAccount"`
-    DestinationAccount Account   `json:"destinationAccount"`
-    EventCreatedAt     time.Time `json:"eventCreatedAt,omitempty"`
+    Id                 uuid.UUID       `json:"id,omitempty"`
+    DinopayPaymentId   uuid.UUID       `json:"externalId,omitempty"`
+    CustomerId         uuid.UUID       `json:"customerId,omitempty"`
+    PaymentId          uuid.UUID       `json:"depositId,omitempty"`
+    Amount             decimal.Decimal `json:"amount"`
+    Currency           string          `json:"currency"`
+    SourceAccount      Account         `json:"sourceAccount"`
+    DestinationAccount Account         `json:"destinationAccount"`
+    EventCreatedAt     time.Time       `json:"eventCreatedAt,omitempty"`
+    // AssignedBy is the operator that assigned a deposit in suspense to the customer
+    AssignedBy string `json:"assignedBy,omitempty"`
+    // ReleasedBy is the operator that released a held deposit to the customer
+    ReleasedBy string `json:"releasedBy,omitempty"`
+    // DinopayPaymentStatus is the DinoPay status of the deposit when it was credited,
+    // empty for the deposits credited before it was recorded, which were all final
+    DinopayPaymentStatus string `json:"dinopayPaymentStatus,omitempty"`
 }
-type Account struct {
-    AccountHolder string `json:"accountHolder"`
-    AccountNumber string `json:"accountNumber"`
+type Account = mapping.Account
+
+// DinopayPayment returns the DinoPay payment that was received
+func (i PaymentReceived) DinopayPayment() mapping.DinopayPayment {
+    return mapping.DinopayPayment{
+        Id:                 i.DinopayPaymentId,
+        Amount:             i.Amount,
+        Currency:           i.Currency,
+        SourceAccount:      i.SourceAccount,
+        DestinationAccount: i.DestinationAccount,
+    }
 }
 
 func (i PaymentReceived) ID() string {
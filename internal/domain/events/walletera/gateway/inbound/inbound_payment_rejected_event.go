@@ -0,0 +1,71 @@
+package inbound
+
+import (
+    "context"
+    "encoding/json"
+    "fmt"
+    "time"
+
+    "github.com/google/uuid"
+    "github.com/shopspring/decimal"
+    "github.com/walletera/dinopay-gateway/internal/domain/events/walletera/gateway"
+    "github.com/walletera/eventskit/events"
+    "github.com/walletera/werrors"
+)
+
+var _ events.Event[EventsHandler] = PaymentRejected{}
+
+// PaymentRejected is recorded when a DinoPay deposit matched a Walletera
+// account but one of the configured ReturnRules forbids crediting it.
+// It is always followed by a PaymentReturnRequested.
+type PaymentRejected struct {
+    Id                 uuid.UUID       `json:"id,omitempty"`
+    DinopayPaymentId   uuid.UUID       `json:"externalId,omitempty"`
+    CustomerId         uuid.UUID       `json:"customerId,omitempty"`
+    Amount             decimal.Decimal `json:"amount"`
+    Currency           string          `json:"currency"`
+    SourceAccount      Account         `json:"sourceAccount"`
+    DestinationAccount Account         `json:"destinationAccount"`
+    Reason             string          `json:"reason"`
+    EventCreatedAt     time.Time       `json:"eventCreatedAt,omitempty"`
+}
+
+func (r PaymentRejected) ID() string {
+    return r.Id.String()
+}
+
+func (r PaymentRejected) Type() string {
+    return "InboundPaymentRejected"
+}
+
+func (r PaymentRejected) DataContentType() string {
+    return "application/json"
+}
+
+func (r PaymentRejected) CorrelationID() string {
+    panic("not implemented yet")
+}
+
+func (r PaymentRejected) AggregateVersion() uint64 {
+    return 0
+}
+
+func (r PaymentRejected) CreatedAt() time.Time {
+    return r.EventCreatedAt
+}
+
+func (r PaymentRejected) Accept(ctx context.Context, handler EventsHandler) werrors.WError {
+    return handler.HandleInboundPaymentRejected(ctx, r)
+}
+
+func (r PaymentRejected) Serialize() ([]byte, error) {
+    data, err := json.Marshal(r)
+    if err != nil {
+        return nil, fmt.Errorf("failed serializing InboundPaymentRejected event: %w", err)
+    }
+    envelope := gateway.EventEnvelope{
+        Type: "InboundPaymentRejected",
+        Data: data,
+    }
+    return json.Marshal(envelope)
+}
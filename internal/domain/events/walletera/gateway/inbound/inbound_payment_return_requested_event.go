@@ -0,0 +1,65 @@
+package inbound
+
+import (
+    "context"
+    "encoding/json"
+    "fmt"
+    "time"
+
+    "github.com/google/uuid"
+    "github.com/walletera/dinopay-gateway/internal/domain/events/walletera/gateway"
+    "github.com/walletera/eventskit/events"
+    "github.com/walletera/werrors"
+)
+
+var _ events.Event[EventsHandler] = PaymentReturnRequested{}
+
+// PaymentReturnRequested is recorded when a deposit must be sent
+// back to the DinoPay account it came from.
+type PaymentReturnRequested struct {
+    Id               uuid.UUID `json:"id,omitempty"`
+    DinopayPaymentId uuid.UUID `json:"externalId,omitempty"`
+    Reason           string    `json:"reason"`
+    RequestedBy      string    `json:"requestedBy,omitempty"`
+    EventCreatedAt   time.Time `json:"eventCreatedAt,omitempty"`
+}
+
+func (r PaymentReturnRequested) ID() string {
+    return r.Id.String()
+}
+
+func (r PaymentReturnRequested) Type() string {
+    return "InboundPaymentReturnRequested"
+}
+
+func (r PaymentReturnRequested) DataContentType() string {
+    return "application/json"
+}
+
+func (r PaymentReturnRequested) CorrelationID() string {
+    panic("not implemented yet")
+}
+
+func (r PaymentReturnRequested) AggregateVersion() uint64 {
+    return 0
+}
+
+func (r PaymentReturnRequested) CreatedAt() time.Time {
+    return r.EventCreatedAt
+}
+
+func (r PaymentReturnRequested) Accept(ctx context.Context, handler EventsHandler) werrors.WError {
+    return handler.HandleInboundPaymentReturnRequested(ctx, r)
+}
+
+func (r PaymentReturnRequested) Serialize() ([]byte, error) {
+    data, err := json.Marshal(r)
+    if err != nil {
+        return nil, fmt.Errorf("failed serializing InboundPaymentReturnRequested event: %w", err)
+    }
+    envelope := gateway.EventEnvelope{
+        Type: "InboundPaymentReturnRequested",
+        Data: data,
+    }
+    return json.Marshal(envelope)
+}
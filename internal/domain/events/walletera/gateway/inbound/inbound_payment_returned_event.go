@@ -0,0 +1,65 @@
+package inbound
+
+import (
+    "context"
+    "encoding/json"
+    "fmt"
+    "time"
+
+    "github.com/google/uuid"
+    "github.com/walletera/dinopay-gateway/internal/domain/events/walletera/gateway"
+    "github.com/walletera/eventskit/events"
+    "github.com/walletera/werrors"
+)
+
+var _ events.Event[EventsHandler] = PaymentReturned{}
+
+// PaymentReturned is recorded once the payment returning
+// the deposit to its sender was created on DinoPay.
+type PaymentReturned struct {
+    Id                         uuid.UUID `json:"id,omitempty"`
+    DinopayPaymentId           uuid.UUID `json:"externalId,omitempty"`
+    DinopayReturnPaymentId     uuid.UUID `json:"returnExternalId,omitempty"`
+    DinopayReturnPaymentStatus string    `json:"returnStatus,omitempty"`
+    EventCreatedAt             time.Time `json:"eventCreatedAt,omitempty"`
+}
+
+func (r PaymentReturned) ID() string {
+    return r.Id.String()
+}
+
+func (r PaymentReturned) Type() string {
+    return "InboundPaymentReturned"
+}
+
+func (r PaymentReturned) DataContentType() string {
+    return "application/json"
+}
+
+func (r PaymentReturned) CorrelationID() string {
+    panic("not implemented yet")
+}
+
+func (r PaymentReturned) AggregateVersion() uint64 {
+    return 0
+}
+
+func (r PaymentReturned) CreatedAt() time.Time {
+    return r.EventCreatedAt
+}
+
+func (r PaymentReturned) Accept(ctx context.Context, handler EventsHandler) werrors.WError {
+    return handler.HandleInboundPaymentReturned(ctx, r)
+}
+
+func (r PaymentReturned) Serialize() ([]byte, error) {
+    data, err := json.Marshal(r)
+    if err != nil {
+        return nil, fmt.Errorf("failed serializing InboundPaymentReturned event: %w", err)
+    }
+    envelope := gateway.EventEnvelope{
+        Type: "InboundPaymentReturned",
+        Data: data,
+    }
+    return json.Marshal(envelope)
+}
@@ -0,0 +1,68 @@
+package inbound
+
+import (
+    "context"
+    "encoding/json"
+    "fmt"
+    "time"
+
+    "github.com/google/uuid"
+    "github.com/walletera/dinopay-gateway/internal/domain/events/walletera/gateway"
+    riskport "github.com/walletera/dinopay-gateway/internal/domain/ports/output/risk"
+    "github.com/walletera/eventskit/events"
+    "github.com/walletera/werrors"
+)
+
+var _ events.Event[EventsHandler] = PaymentRiskAssessed{}
+
+// PaymentRiskAssessed records the risk decision on a deposit. It's appended
+// along with the event acting upon the decision: an allowed deposit is
+// received, a reviewed one is held and a blocked one is returned.
+type PaymentRiskAssessed struct {
+    Id               uuid.UUID        `json:"id,omitempty"`
+    DinopayPaymentId uuid.UUID        `json:"externalId,omitempty"`
+    CustomerId       uuid.UUID        `json:"customerId,omitempty"`
+    Outcome          riskport.Outcome `json:"outcome"`
+    Reasons          []string         `json:"reasons,omitempty"`
+    EventCreatedAt   time.Time        `json:"eventCreatedAt,omitempty"`
+}
+
+func (ra PaymentRiskAssessed) ID() string {
+    return ra.Id.String()
+}
+
+func (ra PaymentRiskAssessed) Type() string {
+    return "InboundPaymentRiskAssessed"
+}
+
+func (ra PaymentRiskAssessed) DataContentType() string {
+    return "application/json"
+}
+
+func (ra PaymentRiskAssessed) CorrelationID() string {
+    return ""
+}
+
+func (ra PaymentRiskAssessed) AggregateVersion() uint64 {
+    return 0
+}
+
+func (ra PaymentRiskAssessed) CreatedAt() time.Time {
+    return ra.EventCreatedAt
+}
+
+func (ra PaymentRiskAssessed) Accept(ctx context.Context, handler EventsHandler) werrors.WError {
+    return handler.HandleInboundPaymentRiskAssessed(ctx, ra)
+}
+
+func (ra PaymentRiskAssessed) Serialize() ([]byte, error) {
+    data, err := json.Marshal(ra)
+    if err != nil {
+        return nil, fmt.Errorf("failed serializing InboundPaymentRiskAssessed event: %w", err)
+    }
+    envelope := gateway.EventEnvelope{
+        Type: "InboundPaymentRiskAssessed",
+        Data: data,
+    }
+    return json.Marshal(envelope)
+}
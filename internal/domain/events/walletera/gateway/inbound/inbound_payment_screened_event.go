@@ -0,0 +1,69 @@
+package inbound
+
+import (
+    "context"
+    "encoding/json"
+    "fmt"
+    "time"
+
+    "github.com/google/uuid"
+    "github.com/walletera/dinopay-gateway/internal/domain/events/walletera/gateway"
+    screeningport "github.com/walletera/dinopay-gateway/internal/domain/ports/output/screening"
+    "github.com/walletera/dinopay-gateway/internal/domain/screening"
+    "github.com/walletera/eventskit/events"
+    "github.com/walletera/werrors"
+)
+
+var _ events.Event[EventsHandler] = PaymentScreened{}
+
+// PaymentScreened records the result of screening the sender of a deposit,
+// hit or clean, for audit. It's appended along with the event acting upon
+// the result: a deposit whose sender is a hit is held.
+type PaymentScreened struct {
+    Id               uuid.UUID            `json:"id,omitempty"`
+    DinopayPaymentId uuid.UUID            `json:"externalId,omitempty"`
+    Party            screening.Party      `json:"party"`
+    Name             string               `json:"name"`
+    Result           screeningport.Result `json:"result"`
+    EventCreatedAt   time.Time            `json:"eventCreatedAt,omitempty"`
+}
+
+func (ps PaymentScreened) ID() string {
+    return ps.Id.String()
+}
+
+func (ps PaymentScreened) Type() string {
+    return "InboundPaymentScreened"
+}
+
+func (ps PaymentScreened) DataContentType() string {
+    return "application/json"
+}
+
+func (ps PaymentScreened) CorrelationID() string {
+    return ""
+}
+
+func (ps PaymentScreened) AggregateVersion() uint64 {
+    return 0
+}
+
+func (ps PaymentScreened) CreatedAt() time.Time {
+    return ps.EventCreatedAt
+}
+
+func (ps PaymentScreened) Accept(ctx context.Context, handler EventsHandler) werrors.WError {
+    return handler.HandleInboundPaymentScreened(ctx, ps)
+}
+
+func (ps PaymentScreened) Serialize() ([]byte, error) {
+    data, err := json.Marshal(ps)
+    if err != nil {
+        return nil, fmt.Errorf("failed serializing InboundPaymentScreened event: %w", err)
+    }
+    envelope := gateway.EventEnvelope{
+        Type: "InboundPaymentScreened",
+        Data: data,
+    }
+    return json.Marshal(envelope)
+}
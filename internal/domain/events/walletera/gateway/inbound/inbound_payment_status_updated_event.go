@@ -0,0 +1,66 @@
+package inbound
+
+import (
+    "context"
+    "encoding/json"
+    "fmt"
+    "time"
+
+    "github.com/google/uuid"
+    "github.com/walletera/dinopay-gateway/internal/domain/events/walletera/gateway"
+    "github.com/walletera/eventskit/events"
+    "github.com/walletera/werrors"
+)
+
+var _ events.Event[EventsHandler] = PaymentStatusUpdated{}
+
+// PaymentStatusUpdated is recorded when DinoPay settles a deposit that was
+// pending when it was received. PaymentId is only set when the deposit was
+// already credited to a customer.
+type PaymentStatusUpdated struct {
+    Id                   uuid.UUID `json:"id,omitempty"`
+    DinopayPaymentId     uuid.UUID `json:"externalId,omitempty"`
+    PaymentId            uuid.UUID `json:"depositId,omitempty"`
+    DinopayPaymentStatus string    `json:"dinopayPaymentStatus"`
+    EventCreatedAt       time.Time `json:"eventCreatedAt,omitempty"`
+}
+
+func (u PaymentStatusUpdated) ID() string {
+    return u.Id.String()
+}
+
+func (u PaymentStatusUpdated) Type() string {
+    return "InboundPaymentStatusUpdated"
+}
+
+func (u PaymentStatusUpdated) DataContentType() string {
+    return "application/json"
+}
+
+func (u PaymentStatusUpdated) CorrelationID() string {
+    panic("not implemented yet")
+}
+
+func (u PaymentStatusUpdated) AggregateVersion() uint64 {
+    return 0
+}
+
+func (u PaymentStatusUpdated) CreatedAt() time.Time {
+    return u.EventCreatedAt
+}
+
+func (u PaymentStatusUpdated) Accept(ctx context.Context, handler EventsHandler) werrors.WError {
+    return handler.HandleInboundPaymentStatusUpdated(ctx, u)
+}
+
+func (u PaymentStatusUpdated) Serialize() ([]byte, error) {
+    data, err := json.Marshal(u)
+    if err != nil {
+        return nil, fmt.Errorf("failed serializing InboundPaymentStatusUpdated event: %w", err)
+    }
+    envelope := gateway.EventEnvelope{
+        Type: "InboundPaymentStatusUpdated",
+        Data: data,
+    }
+    return json.Marshal(envelope)
+}
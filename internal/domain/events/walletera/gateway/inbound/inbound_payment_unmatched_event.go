@@ -0,0 +1,80 @@
+package inbound
+
+import (
+    "context"
+    "encoding/json"
+    "fmt"
+    "time"
+
+    "github.com/google/uuid"
+    "github.com/shopspring/decimal"
+    "github.com/walletera/dinopay-gateway/internal/domain/events/walletera/gateway"
+    "github.com/walletera/eventskit/events"
+    "github.com/walletera/werrors"
+)
+
+const (
+    UnmatchedReasonNoAccountFound        = "no_account_found"
+    UnmatchedReasonMultipleAccountsFound = "multiple_accounts_found"
+    // UnmatchedReasonInvalidAmount is for the deposits whose amount isn't valid
+    // for its currency. They can't be credited, only returned to the sender.
+    UnmatchedReasonInvalidAmount = "invalid_amount"
+)
+
+var _ events.Event[EventsHandler] = PaymentUnmatched{}
+
+// PaymentUnmatched is recorded when a DinoPay deposit can't be matched
+// with exactly one Walletera account. The deposit stays in suspense
+// until an operator assigns it to a customer or returns it to the sender.
+type PaymentUnmatched struct {
+    Id                 uuid.UUID       `json:"id,omitempty"`
+    DinopayPaymentId   uuid.UUID       `json:"externalId,omitempty"`
+    Amount             decimal.Decimal `json:"amount"`
+    Currency           string          `json:"currency"`
+    SourceAccount      Account         `json:"sourceAccount"`
+    DestinationAccount Account         `json:"destinationAccount"`
+    Reason             string          `json:"reason"`
+    EventCreatedAt     time.Time       `json:"eventCreatedAt,omitempty"`
+    // DinopayPaymentStatus is the DinoPay status of the deposit when it was received
+    DinopayPaymentStatus string `json:"dinopayPaymentStatus,omitempty"`
+}
+
+func (u PaymentUnmatched) ID() string {
+    return u.Id.String()
+}
+
+func (u PaymentUnmatched) Type() string {
+    return "InboundPaymentUnmatched"
+}
+
+func (u PaymentUnmatched) DataContentType() string {
+    return "application/json"
+}
+
+func (u PaymentUnmatched) CorrelationID() string {
+    panic("not implemented yet")
+}
+
+func (u PaymentUnmatched) AggregateVersion() uint64 {
+    return 0
+}
+
+func (u PaymentUnmatched) CreatedAt() time.Time {
+    return u.EventCreatedAt
+}
+
+func (u PaymentUnmatched) Accept(ctx context.Context, handler EventsHandler) werrors.WError {
+    return handler.HandleInboundPaymentUnmatched(ctx, u)
+}
+
+func (u PaymentUnmatched) Serialize() ([]byte, error) {
+    data, err := json.Marshal(u)
+    if err != nil {
+        return nil, fmt.Errorf("failed serializing InboundPaymentUnmatched event: %w", err)
+    }
+    envelope := gateway.EventEnvelope{
+        Type: "InboundPaymentUnmatched",
+        Data: data,
+    }
+    return json.Marshal(envelope)
+}
@@ -0,0 +1,202 @@
+package inbound
+
+import (
+    "context"
+    "fmt"
+    "time"
+
+    "github.com/google/uuid"
+    "github.com/shopspring/decimal"
+    "github.com/walletera/dinopay-gateway/internal/domain/events/walletera/gateway"
+    "github.com/walletera/dinopay-gateway/internal/domain/mapping"
+    dinopayapi "github.com/walletera/dinopay/api"
+    "github.com/walletera/eventskit/eventsourcing"
+    paymentsapi "github.com/walletera/payments-types/privateapi"
+    "github.com/walletera/werrors"
+)
+
+type PaymentStatus string
+
+const (
+    PaymentStatusUnmatched       PaymentStatus = "unmatched"
+    PaymentStatusHeld            PaymentStatus = "held"
+    PaymentStatusReceived        PaymentStatus = "received"
+    PaymentStatusRejected        PaymentStatus = "rejected"
+    PaymentStatusReturnRequested PaymentStatus = "return_requested"
+    PaymentStatusReturned        PaymentStatus = "returned"
+)
+
+// Payment is the state of an inbound payment rebuilt
+// from the events of its inboundPayment stream.
+type Payment struct {
+    DinopayPaymentId           uuid.UUID          `json:"dinopayPaymentId"`
+    Status                     PaymentStatus      `json:"status"`
+    Amount                     decimal.Decimal    `json:"amount"`
+    Currency                   string             `json:"currency"`
+    SourceAccount              Account            `json:"sourceAccount"`
+    DestinationAccount         Account            `json:"destinationAccount"`
+    CustomerId                 uuid.UUID          `json:"customerId,omitempty"`
+    PaymentId                  uuid.UUID          `json:"paymentId,omitempty"`
+    UnmatchedReason            string             `json:"unmatchedReason,omitempty"`
+    RejectedReason             string             `json:"rejectedReason,omitempty"`
+    HeldReason                 gateway.HoldReason `json:"heldReason,omitempty"`
+    HeldDetails                string             `json:"heldDetails,omitempty"`
+    RiskOutcome                string             `json:"riskOutcome,omitempty"`
+    RiskReasons                []string           `json:"riskReasons,omitempty"`
+    ReturnReason               string             `json:"returnReason,omitempty"`
+    DinopayReturnPaymentId     uuid.UUID          `json:"dinopayReturnPaymentId,omitempty"`
+    DinopayReturnPaymentStatus string             `json:"dinopayReturnPaymentStatus,omitempty"`
+    DinopayPaymentStatus       string             `json:"dinopayPaymentStatus,omitempty"`
+    ReceivedAt                 time.Time          `json:"receivedAt"`
+    Version                    uint64             `json:"-"`
+}
+
+var _ EventsHandler = (*Payment)(nil)
+
+// LoadPayment reads the inboundPayment stream of the given DinoPay payment
+func LoadPayment(ctx context.Context, db eventsourcing.DB, dinopayPaymentId uuid.UUID) (*Payment, werrors.WError) {
+    retrievedEvents, werr := db.ReadEvents(ctx, gateway.BuildInboundPaymentStreamName(dinopayPaymentId.String()))
+    if werr != nil {
+        return nil, werr
+    }
+    payment := &Payment{}
+    deserializer := NewEventsDeserializer()
+    for _, retrievedEvent := range retrievedEvents {
+        event, err := deserializer.Deserialize(retrievedEvent.RawEvent)
+        if err != nil {
+            return nil, werrors.NewNonRetryableInternalError("failed deserializing inbound payment event: " + err.Error())
+        }
+        werr = event.Accept(ctx, payment)
+        if werr != nil {
+            return nil, werr
+        }
+        payment.Version = retrievedEvent.AggregateVersion
+    }
+    return payment, nil
+}
+
+// ReturnDinopayPayment returns the DinoPay payment sending the deposit back to its sender
+func (p *Payment) ReturnDinopayPayment() mapping.DinopayPayment {
+    return mapping.DinopayPayment{
+        Id:                 p.DinopayReturnPaymentId,
+        Amount:             p.Amount,
+        Currency:           p.Currency,
+        SourceAccount:      p.DestinationAccount,
+        DestinationAccount: p.SourceAccount,
+    }
+}
+
+// IsSettledBy returns whether DinoPay moving the deposit to the given status must be
+// recorded. Only the deposits that were pending are settled later, the deposits
+// without a DinoPay status were final when they were received.
+func (p *Payment) IsSettledBy(dinopayPaymentStatus string) bool {
+    return p.DinopayPaymentStatus == string(dinopayapi.PaymentStatusPending) &&
+        dinopayPaymentStatus != "" &&
+        dinopayPaymentStatus != p.DinopayPaymentStatus
+}
+
+// PaymentsStatus returns the status of a deposit on the Payments API given its DinoPay status.
+// The deposits without a DinoPay status were final when they were received.
+func PaymentsStatus(dinopayPaymentStatus string) (paymentsapi.PaymentStatus, werrors.WError) {
+    switch dinopayPaymentStatus {
+    case "", string(dinopayapi.PaymentStatusConfirmed):
+        return paymentsapi.PaymentStatusConfirmed, nil
+    case string(dinopayapi.PaymentStatusPending):
+        return paymentsapi.PaymentStatusPending, nil
+    case string(dinopayapi.PaymentStatusRejected):
+        return paymentsapi.PaymentStatusFailed, nil
+    default:
+        return "", werrors.NewNonRetryableInternalError(fmt.Sprintf("unknown dinopay payment status %s", dinopayPaymentStatus))
+    }
+}
+
+func streamNameOf(payment *Payment) string {
+    return gateway.BuildInboundPaymentStreamName(payment.DinopayPaymentId.String())
+}
+
+func (p *Payment) HandleInboundPaymentReceived(_ context.Context, paymentReceived PaymentReceived) werrors.WError {
+    p.DinopayPaymentId = paymentReceived.DinopayPaymentId
+    p.Status = PaymentStatusReceived
+    p.Amount = paymentReceived.Amount
+    p.Currency = paymentReceived.Currency
+    p.SourceAccount = paymentReceived.SourceAccount
+    p.DestinationAccount = paymentReceived.DestinationAccount
+    p.CustomerId = paymentReceived.CustomerId
+    p.PaymentId = paymentReceived.PaymentId
+    p.DinopayPaymentStatus = paymentReceived.DinopayPaymentStatus
+    if p.ReceivedAt.IsZero() {
+        p.ReceivedAt = paymentReceived.EventCreatedAt
+    }
+    return nil
+}
+
+func (p *Payment) HandleInboundPaymentUnmatched(_ context.Context, paymentUnmatched PaymentUnmatched) werrors.WError {
+    p.DinopayPaymentId = paymentUnmatched.DinopayPaymentId
+    p.Status = PaymentStatusUnmatched
+    p.Amount = paymentUnmatched.Amount
+    p.Currency = paymentUnmatched.Currency
+    p.SourceAccount = paymentUnmatched.SourceAccount
+    p.DestinationAccount = paymentUnmatched.DestinationAccount
+    p.UnmatchedReason = paymentUnmatched.Reason
+    p.DinopayPaymentStatus = paymentUnmatched.DinopayPaymentStatus
+    p.ReceivedAt = paymentUnmatched.EventCreatedAt
+    return nil
+}
+
+func (p *Payment) HandleInboundPaymentHeld(_ context.Context, paymentHeld PaymentHeld) werrors.WError {
+    p.DinopayPaymentId = paymentHeld.DinopayPaymentId
+    p.Status = PaymentStatusHeld
+    p.Amount = paymentHeld.Amount
+    p.Currency = paymentHeld.Currency
+    p.SourceAccount = paymentHeld.SourceAccount
+    p.DestinationAccount = paymentHeld.DestinationAccount
+    p.CustomerId = paymentHeld.CustomerId
+    p.HeldReason = paymentHeld.Reason
+    p.HeldDetails = paymentHeld.Details
+    p.DinopayPaymentStatus = paymentHeld.DinopayPaymentStatus
+    p.ReceivedAt = paymentHeld.EventCreatedAt
+    return nil
+}
+
+func (p *Payment) HandleInboundPaymentRiskAssessed(_ context.Context, riskAssessed PaymentRiskAssessed) werrors.WError {
+    p.DinopayPaymentId = riskAssessed.DinopayPaymentId
+    p.RiskOutcome = string(riskAssessed.Outcome)
+    p.RiskReasons = riskAssessed.Reasons
+    return nil
+}
+
+func (p *Payment) HandleInboundPaymentScreened(_ context.Context, paymentScreened PaymentScreened) werrors.WError {
+    p.DinopayPaymentId = paymentScreened.DinopayPaymentId
+    return nil
+}
+
+func (p *Payment) HandleInboundPaymentRejected(_ context.Context, paymentRejected PaymentRejected) werrors.WError {
+    p.DinopayPaymentId = paymentRejected.DinopayPaymentId
+    p.Status = PaymentStatusRejected
+    p.Amount = paymentRejected.Amount
+    p.Currency = paymentRejected.Currency
+    p.SourceAccount = paymentRejected.SourceAccount
+    p.DestinationAccount = paymentRejected.DestinationAccount
+    p.CustomerId = paymentRejected.CustomerId
+    p.RejectedReason = paymentRejected.Reason
+    p.ReceivedAt = paymentRejected.EventCreatedAt
+    return nil
+}
+
+func (p *Payment) HandleInboundPaymentReturnRequested(_ context.Context, returnRequested PaymentReturnRequested) werrors.WError {
+    p.Status = PaymentStatusReturnRequested
+    p.ReturnReason = returnRequested.Reason
+    return nil
+}
+
+func (p *Payment) HandleInboundPaymentReturned(_ context.Context, paymentReturned PaymentReturned) werrors.WError {
+    p.Status = PaymentStatusReturned
+    p.DinopayReturnPaymentId = paymentReturned.DinopayReturnPaymentId
+    p.DinopayReturnPaymentStatus = paymentReturned.DinopayReturnPaymentStatus
+    return nil
+}
+
+func (p *Payment) HandleInboundPaymentStatusUpdated(_ context.Context, paymentStatusUpdated PaymentStatusUpdated) werrors.WError {
+    p.DinopayPaymentStatus = paymentStatusUpdated.DinopayPaymentStatus
+    return nil
+}
@@ -0,0 +1,90 @@
+package inbound
+
+import (
+    "context"
+    "testing"
+    "time"
+
+    "github.com/google/uuid"
+    "github.com/shopspring/decimal"
+    "github.com/stretchr/testify/require"
+    paymentsapi "github.com/walletera/payments-types/privateapi"
+)
+
+func TestPaymentsStatus(t *testing.T) {
+    tests := []struct {
+        dinopayStatus string
+        want          paymentsapi.PaymentStatus
+        wantErr       bool
+    }{
+        {dinopayStatus: "", want: paymentsapi.PaymentStatusConfirmed},
+        {dinopayStatus: "pending", want: paymentsapi.PaymentStatusPending},
+        {dinopayStatus: "confirmed", want: paymentsapi.PaymentStatusConfirmed},
+        {dinopayStatus: "rejected", want: paymentsapi.PaymentStatusFailed},
+        {dinopayStatus: "reversed", wantErr: true},
+    }
+    for _, tt := range tests {
+        t.Run(tt.dinopayStatus, func(t *testing.T) {
+            status, werr := PaymentsStatus(tt.dinopayStatus)
+            if tt.wantErr {
+                require.Error(t, werr)
+                require.False(t, werr.IsRetryable())
+                return
+            }
+            require.Nil(t, werr)
+            require.Equal(t, tt.want, status)
+        })
+    }
+}
+
+func TestPayment_SuspenseLifecycle(t *testing.T) {
+    ctx := context.Background()
+    dinopayPaymentId := uuid.New()
+    receivedAt := time.Now()
+    payment := &Payment{}
+
+    require.Nil(t, PaymentUnmatched{
+        Id:                   uuid.New(),
+        DinopayPaymentId:     dinopayPaymentId,
+        Amount:               decimal.RequireFromString("100.50"),
+        Currency:             "USD",
+        SourceAccount:        Account{AccountHolder: "John Doe", AccountNumber: "1200079635"},
+        DestinationAccount:   Account{AccountHolder: "Jane Doe", AccountNumber: "1200079636"},
+        Reason:               UnmatchedReasonMultipleAccountsFound,
+        EventCreatedAt:       receivedAt,
+        DinopayPaymentStatus: "pending",
+    }.Accept(ctx, payment))
+    require.Equal(t, dinopayPaymentId, payment.DinopayPaymentId)
+    require.Equal(t, PaymentStatusUnmatched, payment.Status)
+    require.Equal(t, UnmatchedReasonMultipleAccountsFound, payment.UnmatchedReason)
+    require.Equal(t, "pending", payment.DinopayPaymentStatus)
+    require.True(t, receivedAt.Equal(payment.ReceivedAt))
+
+    require.Nil(t, PaymentStatusUpdated{
+        Id:                   uuid.New(),
+        DinopayPaymentId:     dinopayPaymentId,
+        DinopayPaymentStatus: "confirmed",
+    }.Accept(ctx, payment))
+    require.Equal(t, PaymentStatusUnmatched, payment.Status)
+    require.Equal(t, "confirmed", payment.DinopayPaymentStatus)
+
+    require.Nil(t, PaymentReturnRequested{
+        Id:               uuid.New(),
+        DinopayPaymentId: dinopayPaymentId,
+        Reason:           "unknown beneficiary",
+        RequestedBy:      "operator-1",
+    }.Accept(ctx, payment))
+    require.Equal(t, PaymentStatusReturnRequested, payment.Status)
+    require.Equal(t, "unknown beneficiary", payment.ReturnReason)
+
+    dinopayReturnPaymentId := uuid.New()
+    require.Nil(t, PaymentReturned{
+        Id:                         uuid.New(),
+        DinopayPaymentId:           dinopayPaymentId,
+        DinopayReturnPaymentId:     dinopayReturnPaymentId,
+        DinopayReturnPaymentStatus: "pending",
+    }.Accept(ctx, payment))
+    require.Equal(t, PaymentStatusReturned, payment.Status)
+    require.Equal(t, dinopayReturnPaymentId, payment.DinopayReturnPaymentId)
+    require.Equal(t, "pending", payment.DinopayReturnPaymentStatus)
+}
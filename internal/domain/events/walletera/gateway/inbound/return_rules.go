@@ -0,0 +1,87 @@
+package inbound
+
+import (
+    "slices"
+
+    "github.com/google/uuid"
+    "github.com/shopspring/decimal"
+)
+
+const (
+    ReturnReasonAccountClosed       = "account_closed"
+    ReturnReasonUnsupportedCurrency = "unsupported_currency"
+    ReturnReasonComplianceRejected  = "compliance_rejected"
+    ReturnReasonRiskBlocked         = "risk_blocked"
+)
+
+// ReturnRequestedByRules is the RequestedBy of the returns
+// triggered automatically by the ReturnRules
+const ReturnRequestedByRules = "dinopay-gateway"
+
+// Deposit is what the ReturnRules know about
+// a DinoPay deposit matched with a Walletera account
+type Deposit struct {
+    DinopayPaymentId   uuid.UUID
+    CustomerId         uuid.UUID
+    Amount             decimal.Decimal
+    Currency           string
+    SourceAccount      Account
+    DestinationAccount Account
+}
+
+// ReturnRule decides whether a deposit can't be credited and must be
+// returned to its sender. It returns the reason of the return when it applies.
+type ReturnRule interface {
+    Evaluate(deposit Deposit) (reason string, mustReturn bool)
+}
+
+// ReturnRules evaluates its rules in order, the first one that applies wins
+type ReturnRules []ReturnRule
+
+func (rules ReturnRules) Evaluate(deposit Deposit) (string, bool) {
+    for _, rule := range rules {
+        reason, mustReturn := rule.Evaluate(deposit)
+        if mustReturn {
+            return reason, true
+        }
+    }
+    return "", false
+}
+
+// ReturnRuleFunc adapts a function to the ReturnRule interface
+type ReturnRuleFunc func(deposit Deposit) (string, bool)
+
+func (f ReturnRuleFunc) Evaluate(deposit Deposit) (string, bool) {
+    return f(deposit)
+}
+
+// NewSupportedCurrenciesRule returns the deposits whose currency isn't in the given list
+func NewSupportedCurrenciesRule(currencies ...string) ReturnRule {
+    return ReturnRuleFunc(func(deposit Deposit) (string, bool) {
+        if slices.Contains(currencies, deposit.Currency) {
+            return "", false
+        }
+        return ReturnReasonUnsupportedCurrency, true
+    })
+}
+
+// NewClosedAccountsRule returns the deposits sent to any of the given DinoPay account numbers
+func NewClosedAccountsRule(accountNumbers ...string) ReturnRule {
+    return ReturnRuleFunc(func(deposit Deposit) (string, bool) {
+        if slices.Contains(accountNumbers, deposit.DestinationAccount.AccountNumber) {
+            return ReturnReasonAccountClosed, true
+        }
+        return "", false
+    })
+}
+
+// NewBlockedSendersRule returns the deposits sent from any of the
+// given DinoPay account numbers, usually flagged by compliance
+func NewBlockedSendersRule(accountNumbers ...string) ReturnRule {
+    return ReturnRuleFunc(func(deposit Deposit) (string, bool) {
+        if slices.Contains(accountNumbers, deposit.SourceAccount.AccountNumber) {
+            return ReturnReasonComplianceRejected, true
+        }
+        return "", false
+    })
+}
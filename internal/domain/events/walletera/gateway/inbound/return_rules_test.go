@@ -0,0 +1,67 @@
+package inbound
+
+import (
+    "testing"
+
+    "github.com/stretchr/testify/assert"
+)
+
+func TestReturnRules_Evaluate(t *testing.T) {
+    rules := ReturnRules{
+        NewSupportedCurrenciesRule("USD", "ARS"),
+        NewClosedAccountsRule("IE12BOFI90000199999999"),
+        NewBlockedSendersRule("IE12BOFI90000100000000"),
+    }
+    tests := []struct {
+        name           string
+        deposit        Deposit
+        expectedReason string
+        expectedReturn bool
+    }{
+        {
+            name: "deposit can be credited",
+            deposit: Deposit{
+                Currency:           "USD",
+                SourceAccount:      Account{AccountNumber: "IE12BOFI90000112345678"},
+                DestinationAccount: Account{AccountNumber: "IE12BOFI90000112349876"},
+            },
+        },
+        {
+            name: "unsupported currency",
+            deposit: Deposit{
+                Currency:           "EUR",
+                SourceAccount:      Account{AccountNumber: "IE12BOFI90000112345678"},
+                DestinationAccount: Account{AccountNumber: "IE12BOFI90000112349876"},
+            },
+            expectedReason: ReturnReasonUnsupportedCurrency,
+            expectedReturn: true,
+        },
+        {
+            name: "closed account",
+            deposit: Deposit{
+                Currency:           "USD",
+                SourceAccount:      Account{AccountNumber: "IE12BOFI90000112345678"},
+                DestinationAccount: Account{AccountNumber: "IE12BOFI90000199999999"},
+            },
+            expectedReason: ReturnReasonAccountClosed,
+            expectedReturn: true,
+        },
+        {
+            name: "blocked sender",
+            deposit: Deposit{
+                Currency:           "USD",
+                SourceAccount:      Account{AccountNumber: "IE12BOFI90000100000000"},
+                DestinationAccount: Account{AccountNumber: "IE12BOFI90000112349876"},
+            },
+            expectedReason: ReturnReasonComplianceRejected,
+            expectedReturn: true,
+        },
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            reason, mustReturn := rules.Evaluate(tt.deposit)
+            assert.Equal(t, tt.expectedReturn, mustReturn)
+            assert.Equal(t, tt.expectedReason, reason)
+        })
+    }
+}
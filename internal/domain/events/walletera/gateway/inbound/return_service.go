@@ -0,0 +1,50 @@
+package inbound
+
+import (
+    "context"
+    "fmt"
+    "time"
+
+    "github.com/google/uuid"
+    "github.com/walletera/dinopay-gateway/pkg/wuuid"
+    "github.com/walletera/eventskit/eventsourcing"
+    "github.com/walletera/werrors"
+)
+
+// ReturnService lets operators return any inbound payment to its
+// sender, whether it was credited, is held or is still in suspense.
+type ReturnService struct {
+    db eventsourcing.DB
+}
+
+func NewReturnService(db eventsourcing.DB) *ReturnService {
+    return &ReturnService{
+        db: db,
+    }
+}
+
+// RequestReturn records the return of the given deposit. The DinoPay payment
+// and the Payments API record are created when the event is processed.
+func (s *ReturnService) RequestReturn(ctx context.Context, dinopayPaymentId uuid.UUID, reason string, requestedBy string) werrors.WError {
+    payment, werr := LoadPayment(ctx, s.db, dinopayPaymentId)
+    if werr != nil {
+        return werr
+    }
+    if payment.Status != PaymentStatusReceived && payment.Status != PaymentStatusHeld && payment.Status != PaymentStatusUnmatched {
+        return werrors.NewValidationError(fmt.Sprintf("inbound payment %s can't be returned (status %s)", dinopayPaymentId, payment.Status))
+    }
+    returnRequested := PaymentReturnRequested{
+        Id:               wuuid.NewUUID(),
+        DinopayPaymentId: dinopayPaymentId,
+        Reason:           reason,
+        RequestedBy:      requestedBy,
+        EventCreatedAt:   time.Now(),
+    }
+    _, werr = s.db.AppendEvents(
+        ctx,
+        streamNameOf(payment),
+        eventsourcing.ExpectedAggregateVersion{Version: payment.Version},
+        returnRequested,
+    )
+    return werr
+}
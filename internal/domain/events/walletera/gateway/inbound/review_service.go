@@ -0,0 +1,74 @@
+package inbound
+
+import (
+    "context"
+    "fmt"
+    "time"
+
+    "github.com/google/uuid"
+    "github.com/walletera/dinopay-gateway/pkg/wuuid"
+    "github.com/walletera/eventskit/eventsourcing"
+    "github.com/walletera/werrors"
+)
+
+// ReviewService lets operators release the held deposits to their customer.
+// Held deposits that must not be credited are returned with the ReturnService.
+type ReviewService struct {
+    db                 eventsourcing.DB
+    categoryStreamName string
+}
+
+func NewReviewService(db eventsourcing.DB, categoryStreamName string) *ReviewService {
+    return &ReviewService{
+        db:                 db,
+        categoryStreamName: categoryStreamName,
+    }
+}
+
+// ListHeld returns the deposits waiting for review, oldest first.
+// It folds the whole inboundPayment category so it is meant for operators, not for hot paths.
+func (s *ReviewService) ListHeld(ctx context.Context) ([]*Payment, werrors.WError) {
+    payments, werr := ListPayments(ctx, s.db, s.categoryStreamName)
+    if werr != nil {
+        return nil, werr
+    }
+    var held []*Payment
+    for _, payment := range payments {
+        if payment.Status == PaymentStatusHeld {
+            held = append(held, payment)
+        }
+    }
+    return held, nil
+}
+
+// Release credits a held deposit to the customer it was matched with.
+// The InboundPaymentReceived event continues the regular deposit flow.
+func (s *ReviewService) Release(ctx context.Context, dinopayPaymentId uuid.UUID, releasedBy string) werrors.WError {
+    payment, werr := LoadPayment(ctx, s.db, dinopayPaymentId)
+    if werr != nil {
+        return werr
+    }
+    if payment.Status != PaymentStatusHeld {
+        return werrors.NewValidationError(fmt.Sprintf("inbound payment %s is not held (status %s)", dinopayPaymentId, payment.Status))
+    }
+    paymentReceived := PaymentReceived{
+        Id:                   wuuid.NewUUID(),
+        DinopayPaymentId:     dinopayPaymentId,
+        CustomerId:           payment.CustomerId,
+        PaymentId:            wuuid.NewUUID(),
+        Amount:               payment.Amount,
+        Currency:             payment.Currency,
+        SourceAccount:        payment.SourceAccount,
+        DestinationAccount:   payment.DestinationAccount,
+        EventCreatedAt:       time.Now(),
+        DinopayPaymentStatus: payment.DinopayPaymentStatus,
+        ReleasedBy:           releasedBy,
+    }
+    _, werr = s.db.AppendEvents(
+        ctx,
+        streamNameOf(payment),
+        eventsourcing.ExpectedAggregateVersion{Version: payment.Version},
+        paymentReceived,
+    )
+    return werr
+}
@@ -0,0 +1,171 @@
+package inbound
+
+import (
+    "context"
+    "fmt"
+    "sort"
+    "time"
+
+    "github.com/google/uuid"
+    "github.com/walletera/dinopay-gateway/pkg/wuuid"
+    "github.com/walletera/eventskit/events"
+    "github.com/walletera/eventskit/eventsourcing"
+    "github.com/walletera/werrors"
+)
+
+// SuspenseService lets operators resolve the deposits
+// that couldn't be matched with a Walletera account.
+type SuspenseService struct {
+    db                 eventsourcing.DB
+    categoryStreamName string
+}
+
+func NewSuspenseService(db eventsourcing.DB, categoryStreamName string) *SuspenseService {
+    return &SuspenseService{
+        db:                 db,
+        categoryStreamName: categoryStreamName,
+    }
+}
+
+// ListUnmatched returns the deposits in suspense, oldest first.
+// It folds the whole inboundPayment category so it is meant for operators, not for hot paths.
+func (s *SuspenseService) ListUnmatched(ctx context.Context) ([]*Payment, werrors.WError) {
+    payments, werr := ListPayments(ctx, s.db, s.categoryStreamName)
+    if werr != nil {
+        return nil, werr
+    }
+    var unmatched []*Payment
+    for _, payment := range payments {
+        if payment.Status == PaymentStatusUnmatched {
+            unmatched = append(unmatched, payment)
+        }
+    }
+    return unmatched, nil
+}
+
+// ListPayments folds the given inboundPayment category stream into the
+// inbound payments, oldest first. The payments have no Version set.
+func ListPayments(ctx context.Context, db eventsourcing.DB, categoryStreamName string) ([]*Payment, werrors.WError) {
+    retrievedEvents, werr := db.ReadEvents(ctx, categoryStreamName)
+    if werr != nil {
+        if werr.Code() == werrors.ResourceNotFoundErrorCode {
+            return nil, nil
+        }
+        return nil, werr
+    }
+    deserializer := NewEventsDeserializer()
+    paymentsById := make(map[uuid.UUID]*Payment)
+    var payments []*Payment
+    for _, retrievedEvent := range retrievedEvents {
+        event, err := deserializer.Deserialize(retrievedEvent.RawEvent)
+        if err != nil {
+            return nil, werrors.NewNonRetryableInternalError("failed deserializing inbound payment event: " + err.Error())
+        }
+        dinopayPaymentId, ok := dinopayPaymentIdOf(event)
+        if !ok {
+            continue
+        }
+        payment, ok := paymentsById[dinopayPaymentId]
+        if !ok {
+            payment = &Payment{}
+            paymentsById[dinopayPaymentId] = payment
+            payments = append(payments, payment)
+        }
+        werr = event.Accept(ctx, payment)
+        if werr != nil {
+            return nil, werr
+        }
+    }
+    sort.SliceStable(payments, func(i, j int) bool {
+        return payments[i].ReceivedAt.Before(payments[j].ReceivedAt)
+    })
+    return payments, nil
+}
+
+// Assign credits a deposit in suspense to the given customer.
+// The InboundPaymentReceived event continues the regular deposit flow.
+func (s *SuspenseService) Assign(ctx context.Context, dinopayPaymentId uuid.UUID, customerId uuid.UUID, assignedBy string) werrors.WError {
+    payment, werr := s.loadUnmatched(ctx, dinopayPaymentId)
+    if werr != nil {
+        return werr
+    }
+    if payment.UnmatchedReason == UnmatchedReasonInvalidAmount {
+        return werrors.NewValidationError(fmt.Sprintf("inbound payment %s has an invalid amount, it can only be returned", dinopayPaymentId))
+    }
+    paymentReceived := PaymentReceived{
+        Id:                   wuuid.NewUUID(),
+        DinopayPaymentId:     dinopayPaymentId,
+        CustomerId:           customerId,
+        PaymentId:            wuuid.NewUUID(),
+        Amount:               payment.Amount,
+        Currency:             payment.Currency,
+        SourceAccount:        payment.SourceAccount,
+        DestinationAccount:   payment.DestinationAccount,
+        EventCreatedAt:       time.Now(),
+        DinopayPaymentStatus: payment.DinopayPaymentStatus,
+        AssignedBy:           assignedBy,
+    }
+    return s.append(ctx, payment, paymentReceived)
+}
+
+// Return requests sending a deposit in suspense back to its sender
+func (s *SuspenseService) Return(ctx context.Context, dinopayPaymentId uuid.UUID, reason string, requestedBy string) werrors.WError {
+    payment, werr := s.loadUnmatched(ctx, dinopayPaymentId)
+    if werr != nil {
+        return werr
+    }
+    returnRequested := PaymentReturnRequested{
+        Id:               wuuid.NewUUID(),
+        DinopayPaymentId: dinopayPaymentId,
+        Reason:           reason,
+        RequestedBy:      requestedBy,
+        EventCreatedAt:   time.Now(),
+    }
+    return s.append(ctx, payment, returnRequested)
+}
+
+func (s *SuspenseService) loadUnmatched(ctx context.Context, dinopayPaymentId uuid.UUID) (*Payment, werrors.WError) {
+    payment, werr := LoadPayment(ctx, s.db, dinopayPaymentId)
+    if werr != nil {
+        return nil, werr
+    }
+    if payment.Status != PaymentStatusUnmatched {
+        return nil, werrors.NewValidationError(fmt.Sprintf("inbound payment %s is not in suspense (status %s)", dinopayPaymentId, payment.Status))
+    }
+    return payment, nil
+}
+
+func (s *SuspenseService) append(ctx context.Context, payment *Payment, event events.EventData) werrors.WError {
+    _, werr := s.db.AppendEvents(
+        ctx,
+        streamNameOf(payment),
+        eventsourcing.ExpectedAggregateVersion{Version: payment.Version},
+        event,
+    )
+    return werr
+}
+
+func dinopayPaymentIdOf(event any) (uuid.UUID, bool) {
+    switch e := event.(type) {
+    case PaymentReceived:
+        return e.DinopayPaymentId, true
+    case PaymentUnmatched:
+        return e.DinopayPaymentId, true
+    case PaymentHeld:
+        return e.DinopayPaymentId, true
+    case PaymentRiskAssessed:
+        return e.DinopayPaymentId, true
+    case PaymentRejected:
+        return e.DinopayPaymentId, true
+    case PaymentReturnRequested:
+        return e.DinopayPaymentId, true
+    case PaymentReturned:
+        return e.DinopayPaymentId, true
+    case PaymentScreened:
+        return e.DinopayPaymentId, true
+    case PaymentStatusUpdated:
+        return e.DinopayPaymentId, true
+    default:
+        return uuid.Nil, false
+    }
+}
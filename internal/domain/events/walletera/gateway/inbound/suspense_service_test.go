@@ -0,0 +1,206 @@
+package inbound
+
+import (
+    "context"
+    "testing"
+    "time"
+
+    "github.com/google/uuid"
+    "github.com/shopspring/decimal"
+    "github.com/stretchr/testify/require"
+    "github.com/walletera/dinopay-gateway/internal/domain/events/walletera/gateway"
+    "github.com/walletera/dinopay-gateway/internal/domain/screening"
+    "github.com/walletera/dinopay-gateway/internal/testutil"
+    "github.com/walletera/eventskit/eventsourcing"
+    "github.com/walletera/werrors"
+)
+
+const testCategoryStreamName = "$ce-inboundPayment"
+
+func appendUnmatched(t *testing.T, db eventsourcing.DB, receivedAt time.Time) uuid.UUID {
+    dinopayPaymentId := uuid.New()
+    _, werr := db.AppendEvents(
+        context.Background(),
+        gateway.BuildInboundPaymentStreamName(dinopayPaymentId.String()),
+        eventsourcing.ExpectedAggregateVersion{IsNew: true},
+        PaymentUnmatched{
+            Id:                   uuid.New(),
+            DinopayPaymentId:     dinopayPaymentId,
+            Amount:               decimal.RequireFromString("100.50"),
+            Currency:             "USD",
+            SourceAccount:        Account{AccountHolder: "John Doe", AccountNumber: "1200079635"},
+            DestinationAccount:   Account{AccountHolder: "Jane Doe", AccountNumber: "1200079636"},
+            Reason:               UnmatchedReasonNoAccountFound,
+            EventCreatedAt:       receivedAt,
+            DinopayPaymentStatus: "confirmed",
+        },
+    )
+    require.Nil(t, werr)
+    return dinopayPaymentId
+}
+
+func TestSuspenseService_ListUnmatched(t *testing.T) {
+    ctx := context.Background()
+    db := testutil.NewFakeDB()
+    now := time.Now()
+    newer := appendUnmatched(t, db, now)
+    older := appendUnmatched(t, db, now.Add(-time.Hour))
+    assigned := appendUnmatched(t, db, now.Add(-2*time.Hour))
+    service := NewSuspenseService(db, testCategoryStreamName)
+    require.Nil(t, service.Assign(ctx, assigned, uuid.New(), "operator-1"))
+
+    payments, werr := service.ListUnmatched(ctx)
+    require.Nil(t, werr)
+    require.Len(t, payments, 2)
+    require.Equal(t, older, payments[0].DinopayPaymentId)
+    require.Equal(t, newer, payments[1].DinopayPaymentId)
+    require.Equal(t, PaymentStatusUnmatched, payments[0].Status)
+    require.Equal(t, UnmatchedReasonNoAccountFound, payments[0].UnmatchedReason)
+}
+
+func TestSuspenseService_Assign(t *testing.T) {
+    ctx := context.Background()
+    db := testutil.NewFakeDB()
+    dinopayPaymentId := appendUnmatched(t, db, time.Now())
+    service := NewSuspenseService(db, testCategoryStreamName)
+    customerId := uuid.New()
+
+    require.Nil(t, service.Assign(ctx, dinopayPaymentId, customerId, "operator-1"))
+
+    payment, werr := LoadPayment(ctx, db, dinopayPaymentId)
+    require.Nil(t, werr)
+    require.Equal(t, PaymentStatusReceived, payment.Status)
+    require.Equal(t, customerId, payment.CustomerId)
+    require.NotEqual(t, uuid.Nil, payment.PaymentId)
+    require.True(t, decimal.RequireFromString("100.50").Equal(payment.Amount))
+    require.Equal(t, "confirmed", payment.DinopayPaymentStatus)
+
+    rawEvents := db.Stream(gateway.BuildInboundPaymentStreamName(dinopayPaymentId.String()))
+    event, err := NewEventsDeserializer().Deserialize(rawEvents[len(rawEvents)-1])
+    require.NoError(t, err)
+    require.Equal(t, "operator-1", event.(PaymentReceived).AssignedBy)
+
+    // the deposit is not in suspense anymore
+    werr = service.Assign(ctx, dinopayPaymentId, uuid.New(), "operator-2")
+    require.NotNil(t, werr)
+    require.Equal(t, werrors.ValidationErrorCode, werr.Code())
+}
+
+func TestSuspenseService_Return(t *testing.T) {
+    ctx := context.Background()
+    db := testutil.NewFakeDB()
+    dinopayPaymentId := appendUnmatched(t, db, time.Now())
+    service := NewSuspenseService(db, testCategoryStreamName)
+
+    require.Nil(t, service.Return(ctx, dinopayPaymentId, "unknown beneficiary", "operator-1"))
+
+    payment, werr := LoadPayment(ctx, db, dinopayPaymentId)
+    require.Nil(t, werr)
+    require.Equal(t, PaymentStatusReturnRequested, payment.Status)
+    require.Equal(t, "unknown beneficiary", payment.ReturnReason)
+
+    rawEvents := db.Stream(gateway.BuildInboundPaymentStreamName(dinopayPaymentId.String()))
+    event, err := NewEventsDeserializer().Deserialize(rawEvents[len(rawEvents)-1])
+    require.NoError(t, err)
+    require.Equal(t, "operator-1", event.(PaymentReturnRequested).RequestedBy)
+
+    werr = service.Assign(ctx, dinopayPaymentId, uuid.New(), "operator-2")
+    require.NotNil(t, werr)
+    require.Equal(t, werrors.ValidationErrorCode, werr.Code())
+}
+
+func TestSuspenseService_UnknownPayment(t *testing.T) {
+    service := NewSuspenseService(testutil.NewFakeDB(), testCategoryStreamName)
+
+    werr := service.Assign(context.Background(), uuid.New(), uuid.New(), "operator-1")
+    require.NotNil(t, werr)
+    require.Equal(t, werrors.ResourceNotFoundErrorCode, werr.Code())
+}
+
+func TestSuspenseService_InvalidAmount(t *testing.T) {
+    ctx := context.Background()
+    db := testutil.NewFakeDB()
+    dinopayPaymentId := uuid.New()
+    _, werr := db.AppendEvents(
+        ctx,
+        gateway.BuildInboundPaymentStreamName(dinopayPaymentId.String()),
+        eventsourcing.ExpectedAggregateVersion{IsNew: true},
+        PaymentUnmatched{
+            Id:               uuid.New(),
+            DinopayPaymentId: dinopayPaymentId,
+            Amount:           decimal.RequireFromString("100.555"),
+            Currency:         "USD",
+            Reason:           UnmatchedReasonInvalidAmount,
+            EventCreatedAt:   time.Now(),
+        },
+    )
+    require.Nil(t, werr)
+    service := NewSuspenseService(db, testCategoryStreamName)
+
+    werr = service.Assign(ctx, dinopayPaymentId, uuid.New(), "operator-1")
+    require.NotNil(t, werr, "a deposit with an invalid amount can't be credited")
+    require.Equal(t, werrors.ValidationErrorCode, werr.Code())
+    require.Nil(t, service.Return(ctx, dinopayPaymentId, "invalid amount", "operator-1"))
+}
+
+func TestListPayments_FoldsTheScreeningIntoItsDeposit(t *testing.T) {
+    ctx := context.Background()
+    db := testutil.NewFakeDB()
+    dinopayPaymentId := uuid.New()
+    _, werr := db.AppendEvents(
+        ctx,
+        gateway.BuildInboundPaymentStreamName(dinopayPaymentId.String()),
+        eventsourcing.ExpectedAggregateVersion{IsNew: true},
+        PaymentScreened{
+            Id:               uuid.New(),
+            DinopayPaymentId: dinopayPaymentId,
+            Party:            screening.PartySource,
+            Name:             "John Doe",
+            EventCreatedAt:   time.Now(),
+        },
+        PaymentUnmatched{
+            Id:               uuid.New(),
+            DinopayPaymentId: dinopayPaymentId,
+            Amount:           decimal.RequireFromString("100.50"),
+            Currency:         "USD",
+            Reason:           UnmatchedReasonNoAccountFound,
+            EventCreatedAt:   time.Now(),
+        },
+    )
+    require.Nil(t, werr)
+
+    payments, werr := ListPayments(ctx, db, testCategoryStreamName)
+    require.Nil(t, werr)
+    require.Len(t, payments, 1)
+    require.Equal(t, dinopayPaymentId, payments[0].DinopayPaymentId)
+    require.Equal(t, PaymentStatusUnmatched, payments[0].Status)
+}
+
+func TestListPayments_AppliesTheStatusUpdateToItsDeposit(t *testing.T) {
+    ctx := context.Background()
+    db := testutil.NewFakeDB()
+    updated := appendUnmatched(t, db, time.Now())
+    other := appendUnmatched(t, db, time.Now().Add(-time.Hour))
+    payment, werr := LoadPayment(ctx, db, updated)
+    require.Nil(t, werr)
+    _, werr = db.AppendEvents(
+        ctx,
+        gateway.BuildInboundPaymentStreamName(updated.String()),
+        eventsourcing.ExpectedAggregateVersion{Version: payment.Version},
+        PaymentStatusUpdated{
+            Id:                   uuid.New(),
+            DinopayPaymentId:     updated,
+            DinopayPaymentStatus: "reversed",
+            EventCreatedAt:       time.Now(),
+        },
+    )
+    require.Nil(t, werr)
+
+    payments, werr := ListPayments(ctx, db, testCategoryStreamName)
+    require.Nil(t, werr)
+    require.Len(t, payments, 2)
+    require.Equal(t, other, payments[0].DinopayPaymentId)
+    require.Equal(t, "confirmed", payments[0].DinopayPaymentStatus)
+    require.Equal(t, updated, payments[1].DinopayPaymentId)
+    require.Equal(t, "reversed", payments[1].DinopayPaymentStatus)
+}
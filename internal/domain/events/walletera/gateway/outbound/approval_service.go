@@ -0,0 +1,226 @@
+package outbound
+
+import (
+    "context"
+    "log/slog"
+    "time"
+
+    "github.com/google/uuid"
+    "github.com/shopspring/decimal"
+    "github.com/walletera/dinopay-gateway/internal/domain/events/walletera/gateway"
+    "github.com/walletera/dinopay-gateway/internal/domain/mapping"
+    "github.com/walletera/dinopay-gateway/pkg/logattr"
+    "github.com/walletera/dinopay-gateway/pkg/wuuid"
+    "github.com/walletera/eventskit/events"
+    "github.com/walletera/eventskit/eventsourcing"
+    "github.com/walletera/werrors"
+)
+
+// ApprovalThresholds maps a currency to the amount above which its payouts must be approved
+type ApprovalThresholds map[string]decimal.Decimal
+
+// Requires tells whether the payout must be approved, and the threshold it's above of
+func (t ApprovalThresholds) Requires(payment mapping.DinopayPayment) (decimal.Decimal, bool) {
+    threshold, ok := t[payment.Currency]
+    if !ok || !payment.Amount.GreaterThan(threshold) {
+        return decimal.Decimal{}, false
+    }
+    return threshold, true
+}
+
+// ApprovalService keeps the payouts above the ApprovalThresholds from being
+// submitted to DinoPay until two different operators approve them. The
+// operators are the ones authenticated by the operator api.
+type ApprovalService struct {
+    db                 eventsourcing.DB
+    submitter          *Submitter
+    categoryStreamName string
+    logger             *slog.Logger
+}
+
+func NewApprovalService(db eventsourcing.DB, submitter *Submitter, categoryStreamName string, logger *slog.Logger) *ApprovalService {
+    return &ApprovalService{
+        db:                 db,
+        submitter:          submitter,
+        categoryStreamName: categoryStreamName,
+        logger:             logger.With(logattr.Component("outbound.ApprovalService")),
+    }
+}
+
+// RequestApproval records the payment as awaiting approval, after the given events.
+// Requesting it twice is a no-op.
+func (s *ApprovalService) RequestApproval(
+    ctx context.Context,
+    paymentId uuid.UUID,
+    customerId uuid.UUID,
+    payment mapping.DinopayPayment,
+    threshold decimal.Decimal,
+    requestedBy string,
+    preceding ...events.EventData,
+) werrors.WError {
+    logger := s.logger.With(logattr.PaymentId(paymentId.String()))
+    paymentAwaitingApproval := PaymentAwaitingApproval{
+        Id:                 wuuid.NewUUID(),
+        PaymentId:          paymentId,
+        CustomerId:         customerId,
+        Amount:             payment.Amount,
+        Currency:           payment.Currency,
+        SourceAccount:      payment.SourceAccount,
+        DestinationAccount: payment.DestinationAccount,
+        Threshold:          threshold,
+        RequestedBy:        requestedBy,
+        EventCreatedAt:     time.Now().UnixMilli(),
+    }
+    _, werr := s.db.AppendEvents(
+        ctx,
+        gateway.BuildOutboundPaymentStreamName(paymentId.String()),
+        eventsourcing.ExpectedAggregateVersion{IsNew: true},
+        append(preceding, paymentAwaitingApproval)...,
+    )
+    if werr != nil {
+        if werr.Code() == werrors.ResourceAlreadyExistErrorCode {
+            logger.Info("payment approval already requested")
+            return nil
+        }
+        werr := werrors.NewWrappedError(werr, "failed appending outbound PaymentAwaitingApproval event")
+        logger.Error(werr.Error())
+        return werr
+    }
+    logger.Info("payment awaiting approval", slog.String("threshold", threshold.String()))
+    return nil
+}
+
+// ListAwaitingApproval returns the payments waiting for an approval
+func (s *ApprovalService) ListAwaitingApproval(ctx context.Context) ([]*HeldPayment, werrors.WError) {
+    return ListHeldPayments(ctx, s.db, s.categoryStreamName, HoldStatusAwaitingApproval)
+}
+
+// Approve records the approval of an operator. The first approval is only
+// recorded; the second one, which must come from another operator, submits
+// the payment to DinoPay. Approving a payment already approved submits it
+// again when its submission failed before recording an outcome.
+func (s *ApprovalService) Approve(ctx context.Context, paymentId uuid.UUID, approvedBy string) werrors.WError {
+    logger := s.logger.With(logattr.PaymentId(paymentId.String()))
+    if approvedBy == "" {
+        return werrors.NewValidationError("outbound payment " + paymentId.String() + " can't be approved by an unknown operator")
+    }
+    heldPayment, werr := LoadHeldPayment(ctx, s.db, paymentId)
+    if werr != nil {
+        return werr
+    }
+    switch heldPayment.Status {
+    case HoldStatusApproved:
+        logger.Info("resuming submission of approved payment", slog.String("approved_by", approvedBy))
+        return s.submitter.Submit(
+            ctx,
+            logger,
+            paymentId,
+            heldPayment.DinopayPayment(),
+            eventsourcing.ExpectedAggregateVersion{Version: heldPayment.DecisionVersion},
+        )
+    case HoldStatusAwaitingApproval:
+    default:
+        return werrors.NewValidationError("outbound payment " + paymentId.String() + " is " + string(heldPayment.Status) + ", not awaiting approval")
+    }
+    if heldPayment.FirstApprovedBy == "" {
+        return s.firstApprove(ctx, logger, heldPayment, approvedBy)
+    }
+    if approvedBy == heldPayment.FirstApprovedBy {
+        return werrors.NewValidationError("outbound payment " + paymentId.String() + " was already approved by " + approvedBy + ", a second operator must approve it")
+    }
+    paymentApproved := PaymentApproved{
+        Id:             wuuid.NewUUID(),
+        PaymentId:      paymentId,
+        ApprovedBy:     approvedBy,
+        EventCreatedAt: time.Now().UnixMilli(),
+    }
+    _, werr = s.db.AppendEvents(
+        ctx,
+        gateway.BuildOutboundPaymentStreamName(paymentId.String()),
+        eventsourcing.ExpectedAggregateVersion{Version: heldPayment.Version},
+        paymentApproved,
+    )
+    if werr != nil {
+        return werrors.NewWrappedError(werr, "failed appending outbound PaymentApproved event")
+    }
+    logger.Info(
+        "payment approved",
+        slog.String("first_approved_by", heldPayment.FirstApprovedBy),
+        slog.String("approved_by", approvedBy),
+    )
+    return s.submitter.Submit(
+        ctx,
+        logger,
+        paymentId,
+        heldPayment.DinopayPayment(),
+        eventsourcing.ExpectedAggregateVersion{Version: heldPayment.Version + 1},
+    )
+}
+
+func (s *ApprovalService) firstApprove(ctx context.Context, logger *slog.Logger, heldPayment *HeldPayment, approvedBy string) werrors.WError {
+    _, werr := s.db.AppendEvents(
+        ctx,
+        gateway.BuildOutboundPaymentStreamName(heldPayment.PaymentId.String()),
+        eventsourcing.ExpectedAggregateVersion{Version: heldPayment.Version},
+        PaymentFirstApproved{
+            Id:             wuuid.NewUUID(),
+            PaymentId:      heldPayment.PaymentId,
+            ApprovedBy:     approvedBy,
+            EventCreatedAt: time.Now().UnixMilli(),
+        },
+    )
+    if werr != nil {
+        return werrors.NewWrappedError(werr, "failed appending outbound PaymentFirstApproved event")
+    }
+    logger.Info("payment first approved, awaiting a second approval", slog.String("approved_by", approvedBy))
+    return nil
+}
+
+// Reject fails the payment without submitting it
+func (s *ApprovalService) Reject(ctx context.Context, paymentId uuid.UUID, rejectedBy string, comment string) werrors.WError {
+    logger := s.logger.With(logattr.PaymentId(paymentId.String()))
+    heldPayment, werr := s.loadAwaitingApproval(ctx, paymentId)
+    if werr != nil {
+        return werr
+    }
+    details := "approval rejected by " + rejectedBy
+    if comment != "" {
+        details += ": " + comment
+    }
+    now := time.Now().UnixMilli()
+    _, werr = s.db.AppendEvents(
+        ctx,
+        gateway.BuildOutboundPaymentStreamName(paymentId.String()),
+        eventsourcing.ExpectedAggregateVersion{Version: heldPayment.Version},
+        PaymentApprovalRejected{
+            Id:             wuuid.NewUUID(),
+            PaymentId:      paymentId,
+            RejectedBy:     rejectedBy,
+            Comment:        comment,
+            EventCreatedAt: now,
+        },
+        PaymentFailed{
+            Id:             wuuid.NewUUID(),
+            PaymentId:      paymentId,
+            Reason:         FailureReasonRejectedOnReview,
+            Details:        details,
+            EventCreatedAt: now,
+        },
+    )
+    if werr != nil {
+        return werrors.NewWrappedError(werr, "failed appending outbound PaymentApprovalRejected event")
+    }
+    logger.Info("payment approval rejected", slog.String("rejected_by", rejectedBy))
+    return nil
+}
+
+func (s *ApprovalService) loadAwaitingApproval(ctx context.Context, paymentId uuid.UUID) (*HeldPayment, werrors.WError) {
+    heldPayment, werr := LoadHeldPayment(ctx, s.db, paymentId)
+    if werr != nil {
+        return nil, werr
+    }
+    if heldPayment.Status != HoldStatusAwaitingApproval {
+        return nil, werrors.NewValidationError("outbound payment " + paymentId.String() + " is " + string(heldPayment.Status) + ", not awaiting approval")
+    }
+    return heldPayment, nil
+}
@@ -0,0 +1,105 @@
+package outbound
+
+import (
+    "context"
+    "log/slog"
+    "testing"
+
+    "github.com/google/uuid"
+    "github.com/shopspring/decimal"
+    "github.com/stretchr/testify/require"
+    "github.com/walletera/dinopay-gateway/internal/domain/events/walletera/gateway"
+    "github.com/walletera/dinopay-gateway/internal/domain/mapping"
+    "github.com/walletera/dinopay-gateway/internal/testutil"
+    dinopayapi "github.com/walletera/dinopay/api"
+    "github.com/walletera/werrors"
+)
+
+func TestApprovalThresholds_Requires(t *testing.T) {
+    thresholds := ApprovalThresholds{"USD": decimal.RequireFromString("10000")}
+    payout := func(amount string, currency string) mapping.DinopayPayment {
+        return mapping.DinopayPayment{Amount: decimal.RequireFromString(amount), Currency: currency}
+    }
+
+    _, required := thresholds.Requires(payout("10000", "USD"))
+    require.False(t, required, "payout at the threshold must not require approval")
+    threshold, required := thresholds.Requires(payout("10000.01", "USD"))
+    require.True(t, required)
+    require.True(t, decimal.RequireFromString("10000").Equal(threshold))
+    _, required = thresholds.Requires(payout("1000000", "ARS"))
+    require.False(t, required, "currency without threshold must not require approval")
+}
+
+func TestApprovalService(t *testing.T) {
+    ctx := context.Background()
+    db := testutil.NewFakeDB()
+    dinopayClient := &fakeDinopayClient{createRes: &dinopayapi.Payment{
+        ID:     dinopayapi.NewOptUUID(uuid.New()),
+        Status: dinopayapi.NewOptPaymentStatus(dinopayapi.PaymentStatusPending),
+    }}
+    service := NewApprovalService(db, NewSubmitter(db, dinopayClient), testCategoryStreamName, slog.Default())
+    payment := mapping.DinopayPayment{
+        Amount:             decimal.RequireFromString("50000"),
+        Currency:           "USD",
+        SourceAccount:      mapping.Account{AccountHolder: "John Doe", AccountNumber: "1200079635"},
+        DestinationAccount: mapping.Account{AccountHolder: "Jane Doe", AccountNumber: "1200079636"},
+    }
+    threshold := decimal.RequireFromString("10000")
+    approvedId, rejectedId := uuid.New(), uuid.New()
+    for _, paymentId := range []uuid.UUID{approvedId, rejectedId} {
+        require.NoError(t, service.RequestApproval(ctx, paymentId, uuid.New(), payment, threshold, "requester"))
+    }
+    // requesting approval twice is a no-op
+    require.NoError(t, service.RequestApproval(ctx, approvedId, uuid.New(), payment, threshold, "requester"))
+
+    awaiting, werr := service.ListAwaitingApproval(ctx)
+    require.NoError(t, werr)
+    require.Len(t, awaiting, 2)
+
+    // the first approval doesn't submit the payout
+    require.NoError(t, service.Approve(ctx, approvedId, "first-approver"))
+    require.Empty(t, dinopayClient.created)
+    firstApproved, werr := LoadHeldPayment(ctx, db, approvedId)
+    require.NoError(t, werr)
+    require.Equal(t, HoldStatusAwaitingApproval, firstApproved.Status)
+    require.Equal(t, "first-approver", firstApproved.FirstApprovedBy)
+
+    werr = service.Approve(ctx, approvedId, "first-approver")
+    require.Error(t, werr, "an operator must not give both approvals")
+    require.Equal(t, werrors.ValidationErrorCode, werr.Code())
+    require.Empty(t, dinopayClient.created)
+
+    werr = service.Approve(ctx, approvedId, "")
+    require.Error(t, werr, "an unknown operator must not approve")
+    require.Equal(t, werrors.ValidationErrorCode, werr.Code())
+
+    require.NoError(t, service.Approve(ctx, approvedId, "approver"))
+    require.Len(t, dinopayClient.created, 1)
+    require.NoError(t, service.Approve(ctx, rejectedId, "first-approver"))
+    require.NoError(t, service.Reject(ctx, rejectedId, "approver", "beneficiary not verified"))
+
+    lastEventType := func(paymentId uuid.UUID) string {
+        stream := db.Stream(gateway.BuildOutboundPaymentStreamName(paymentId.String()))
+        lastEvent, err := NewEventsDeserializer().Deserialize(stream[len(stream)-1])
+        require.NoError(t, err)
+        return lastEvent.Type()
+    }
+    require.Equal(t, "OutboundPaymentSubmitted", lastEventType(approvedId))
+    require.Equal(t, "OutboundPaymentFailed", lastEventType(rejectedId))
+
+    approved, werr := LoadHeldPayment(ctx, db, approvedId)
+    require.NoError(t, werr)
+    require.Equal(t, HoldStatusApproved, approved.Status)
+    require.Equal(t, "approver", approved.ReviewedBy)
+    rejected, werr := LoadHeldPayment(ctx, db, rejectedId)
+    require.NoError(t, werr)
+    require.Equal(t, HoldStatusRejected, rejected.Status)
+
+    awaiting, werr = service.ListAwaitingApproval(ctx)
+    require.NoError(t, werr)
+    require.Empty(t, awaiting)
+
+    // a decided payment can't be decided again, nor released as a held one
+    require.Error(t, service.Approve(ctx, rejectedId, "approver"))
+    require.Error(t, NewReviewService(db, NewSubmitter(db, dinopayClient), testCategoryStreamName, slog.Default()).Release(ctx, rejectedId, "approver"))
+}
@@ -0,0 +1,218 @@
+package outbound
+
+import (
+    "context"
+    "errors"
+    "log/slog"
+    "time"
+
+    "github.com/google/uuid"
+    "github.com/walletera/dinopay-gateway/internal/domain/events/walletera/gateway"
+    "github.com/walletera/dinopay-gateway/internal/domain/ports/output/dinopay"
+    "github.com/walletera/dinopay-gateway/pkg/logattr"
+    "github.com/walletera/dinopay-gateway/pkg/wuuid"
+    dinopayapi "github.com/walletera/dinopay/api"
+    "github.com/walletera/eventskit/eventsourcing"
+    "github.com/walletera/werrors"
+)
+
+// CancellationService handles the cancellations of outbound payments
+// requested by the Payments service. A payment still pending on DinoPay is
+// cancelled there, and a payment that hasn't been submitted yet is blocked.
+// The decision is taken on the stream named after the Walletera payment id,
+// which the Submitter appends to before calling DinoPay, and the result is
+// recorded in the outbound stream of the payment, which reports it back to
+// the Payments API.
+type CancellationService struct {
+    db            eventsourcing.DB
+    dinopayClient dinopay.Client
+    logger        *slog.Logger
+}
+
+func NewCancellationService(db eventsourcing.DB, dinopayClient dinopay.Client, logger *slog.Logger) *CancellationService {
+    return &CancellationService{
+        db:            db,
+        dinopayClient: dinopayClient,
+        logger:        logger.With(logattr.Component("outbound.CancellationService")),
+    }
+}
+
+// Cancel cancels the given Walletera payment. Cancelling a payment twice is a no-op.
+func (s *CancellationService) Cancel(ctx context.Context, paymentId uuid.UUID) werrors.WError {
+    logger := s.logger.With(logattr.PaymentId(paymentId.String()))
+    submission, werr := LoadSubmission(ctx, s.db, paymentId)
+    if werr != nil {
+        return werr
+    }
+    switch {
+    case !submission.Exists:
+        return s.cancelUnrecorded(ctx, logger, paymentId)
+    case submission.Cancelled:
+        logger.Info("outbound payment already cancelled")
+        return nil
+    case submission.DinopayPaymentId != uuid.Nil:
+        return s.cancelOnDinopay(ctx, logger.With(logattr.DinopayPaymentId(submission.DinopayPaymentId.String())), submission.DinopayPaymentId)
+    case submission.Failed:
+        logger.Info("outbound payment already failed, nothing to cancel")
+        return nil
+    case submission.OutcomeUnknown:
+        if submission.CancellationFailed {
+            logger.Info("outbound payment cancellation already failed")
+            return nil
+        }
+        // the payment may exist on DinoPay, it can't be cancelled until the outcome is resolved
+        return s.submissionCancellationFailed(ctx, logger, submission, "payment outcome on dinopay is unknown")
+    case submission.Submitting:
+        // the Submitter records the outcome right after DinoPay answers
+        return werrors.NewRetryableInternalError("outbound payment " + paymentId.String() + " is being submitted to dinopay")
+    default:
+        // the payment is waiting for an operator or for its submission,
+        // cancelling it keeps it from being submitted
+        return s.blockSubmission(ctx, logger, submission)
+    }
+}
+
+// cancelUnrecorded handles the payments without events in the stream named after
+// the Walletera payment id. The payments submitted before the Submitter recorded
+// its submissions there are looked up on DinoPay by their CustomerTransactionId,
+// which is the Walletera payment id. Any other payment hasn't been submitted yet.
+func (s *CancellationService) cancelUnrecorded(ctx context.Context, logger *slog.Logger, paymentId uuid.UUID) werrors.WError {
+    dinopayPayment, err := s.dinopayClient.FindPaymentByCustomerTransactionId(ctx, paymentId.String())
+    if err != nil {
+        return werrors.NewRetryableInternalError("failed looking up payment on dinopay: " + err.Error())
+    }
+    if dinopayPayment == nil {
+        return s.blockSubmission(ctx, logger, &Submission{PaymentId: paymentId})
+    }
+    dinopayPaymentId := dinopayPayment.ID.Value
+    return s.cancelOnDinopay(ctx, logger.With(logattr.DinopayPaymentId(dinopayPaymentId.String())), dinopayPaymentId)
+}
+
+func (s *CancellationService) cancelOnDinopay(ctx context.Context, logger *slog.Logger, dinopayPaymentId uuid.UUID) werrors.WError {
+    // the stream is read again to get the version to append with
+    payment, werr := LoadPayment(ctx, s.db, dinopayPaymentId)
+    if werr != nil {
+        return werr
+    }
+    if !payment.CancelledAt.IsZero() {
+        logger.Info("outbound payment already cancelled")
+        return nil
+    }
+    if payment.CancellationFailed {
+        logger.Info("outbound payment cancellation already failed")
+        return nil
+    }
+    if payment.DinopayPaymentStatus != string(dinopayapi.PaymentStatusPending) {
+        return s.cancellationFailed(ctx, logger, payment, "payment is already "+payment.DinopayPaymentStatus+" on dinopay")
+    }
+    dinopayPayment, err := s.dinopayClient.CancelPayment(ctx, dinopayPaymentId)
+    if errors.Is(err, dinopay.ErrPaymentNotCancellable) {
+        return s.cancellationFailed(ctx, logger, payment, "dinopay already processed the payment")
+    }
+    if err != nil {
+        return werrors.NewRetryableInternalError("failed cancelling payment on dinopay: " + err.Error())
+    }
+    paymentCancelled := PaymentCancelled{
+        Id:                   wuuid.NewUUID(),
+        PaymentId:            payment.PaymentId,
+        DinopayPaymentId:     dinopayPaymentId,
+        DinopayPaymentStatus: string(dinopayPayment.Status.Value),
+        EventCreatedAt:       time.Now().UnixMilli(),
+    }
+    _, werr = s.db.AppendEvents(
+        ctx,
+        gateway.BuildOutboundPaymentStreamName(dinopayPaymentId.String()),
+        eventsourcing.ExpectedAggregateVersion{Version: payment.Version},
+        paymentCancelled,
+    )
+    if werr != nil {
+        return werrors.NewWrappedError(werr, "failed appending outbound PaymentCancelled event")
+    }
+    logger.Info("outbound payment cancelled on dinopay")
+    return nil
+}
+
+func (s *CancellationService) cancellationFailed(ctx context.Context, logger *slog.Logger, payment *Payment, reason string) werrors.WError {
+    _, werr := s.db.AppendEvents(
+        ctx,
+        gateway.BuildOutboundPaymentStreamName(payment.DinopayPaymentId.String()),
+        eventsourcing.ExpectedAggregateVersion{Version: payment.Version},
+        PaymentCancellationFailed{
+            Id:                   wuuid.NewUUID(),
+            PaymentId:            payment.PaymentId,
+            DinopayPaymentId:     payment.DinopayPaymentId,
+            DinopayPaymentStatus: payment.DinopayPaymentStatus,
+            Reason:               reason,
+            EventCreatedAt:       time.Now().UnixMilli(),
+        },
+    )
+    if werr != nil {
+        return werrors.NewWrappedError(werr, "failed appending outbound PaymentCancellationFailed event")
+    }
+    logger.Warn("outbound payment can't be cancelled", logattr.Reason(reason))
+    return nil
+}
+
+// blockSubmission cancels a payment that wasn't submitted to DinoPay. It's appended
+// with the version the submission was read at, so it fails if the Submitter
+// started submitting the payment meanwhile, and the retry cancels it on DinoPay.
+func (s *CancellationService) blockSubmission(ctx context.Context, logger *slog.Logger, submission *Submission) werrors.WError {
+    _, werr := s.db.AppendEvents(
+        ctx,
+        gateway.BuildOutboundPaymentStreamName(submission.PaymentId.String()),
+        submission.ExpectedVersion(),
+        PaymentCancelled{
+            Id:             wuuid.NewUUID(),
+            PaymentId:      submission.PaymentId,
+            EventCreatedAt: time.Now().UnixMilli(),
+        },
+    )
+    if werr != nil {
+        return werrors.NewWrappedError(werr, "failed appending outbound PaymentCancelled event")
+    }
+    logger.Info("outbound payment cancelled before being submitted")
+    return nil
+}
+
+func (s *CancellationService) submissionCancellationFailed(ctx context.Context, logger *slog.Logger, submission *Submission, reason string) werrors.WError {
+    _, werr := s.db.AppendEvents(
+        ctx,
+        gateway.BuildOutboundPaymentStreamName(submission.PaymentId.String()),
+        submission.ExpectedVersion(),
+        PaymentCancellationFailed{
+            Id:             wuuid.NewUUID(),
+            PaymentId:      submission.PaymentId,
+            Reason:         reason,
+            EventCreatedAt: time.Now().UnixMilli(),
+        },
+    )
+    if werr != nil {
+        return werrors.NewWrappedError(werr, "failed appending outbound PaymentCancellationFailed event")
+    }
+    logger.Warn("outbound payment can't be cancelled", logattr.Reason(reason))
+    return nil
+}
+
+// Blocked tells whether the submission of the given Walletera payment
+// was blocked by a cancellation or handed over to a review or an approval
+func (s *CancellationService) Blocked(ctx context.Context, paymentId uuid.UUID) (bool, werrors.WError) {
+    retrievedEvents, werr := s.db.ReadEvents(ctx, gateway.BuildOutboundPaymentStreamName(paymentId.String()))
+    if werr != nil {
+        if werr.Code() == werrors.ResourceNotFoundErrorCode {
+            return false, nil
+        }
+        return false, werr
+    }
+    deserializer := NewEventsDeserializer()
+    for _, retrievedEvent := range retrievedEvents {
+        event, err := deserializer.Deserialize(retrievedEvent.RawEvent)
+        if err != nil {
+            return false, werrors.NewNonRetryableInternalError("failed deserializing outbound payment event: " + err.Error())
+        }
+        switch event.(type) {
+        case PaymentCancelled, PaymentHeld, PaymentAwaitingApproval:
+            return true, nil
+        }
+    }
+    return false, nil
+}
@@ -0,0 +1,257 @@
+package outbound
+
+import (
+    "context"
+    "errors"
+    "log/slog"
+    "testing"
+
+    "github.com/google/uuid"
+    "github.com/shopspring/decimal"
+    "github.com/stretchr/testify/require"
+    "github.com/walletera/dinopay-gateway/internal/domain/events/walletera/gateway"
+    "github.com/walletera/dinopay-gateway/internal/domain/mapping"
+    "github.com/walletera/dinopay-gateway/internal/domain/ports/output/dinopay"
+    riskport "github.com/walletera/dinopay-gateway/internal/domain/ports/output/risk"
+    "github.com/walletera/dinopay-gateway/internal/testutil"
+    dinopayapi "github.com/walletera/dinopay/api"
+    "github.com/walletera/eventskit/events"
+    "github.com/walletera/eventskit/eventsourcing"
+)
+
+func TestCancellationService_Cancel(t *testing.T) {
+    paymentId := uuid.New()
+    dinopayPaymentId := uuid.New()
+    created := func(status dinopayapi.PaymentStatus) []events.EventData {
+        return []events.EventData{PaymentCreated{
+            Id:                   uuid.New(),
+            PaymentId:            paymentId,
+            DinopayPaymentId:     dinopayPaymentId,
+            DinopayPaymentStatus: string(status),
+        }}
+    }
+    // the payments created on dinopay before the submissions were recorded are looked up there
+    found := &dinopayapi.Payment{ID: dinopayapi.NewOptUUID(dinopayPaymentId)}
+    tests := []struct {
+        name              string
+        streamName        string
+        streamEvents      []events.EventData
+        dinopayClient     *fakeDinopayClient
+        wantCancelCalls   int
+        wantStream        string
+        wantLastEventType string
+    }{
+        {
+            name:         "pending payment is cancelled on dinopay",
+            streamName:   gateway.BuildOutboundPaymentStreamName(dinopayPaymentId.String()),
+            streamEvents: created(dinopayapi.PaymentStatusPending),
+            dinopayClient: &fakeDinopayClient{found: found, cancelRes: &dinopayapi.Payment{
+                ID:     dinopayapi.NewOptUUID(dinopayPaymentId),
+                Status: dinopayapi.NewOptPaymentStatus(dinopayapi.PaymentStatusRejected),
+            }},
+            wantCancelCalls:   1,
+            wantStream:        gateway.BuildOutboundPaymentStreamName(dinopayPaymentId.String()),
+            wantLastEventType: "OutboundPaymentCancelled",
+        },
+        {
+            name:              "pending payment already processed by dinopay",
+            streamName:        gateway.BuildOutboundPaymentStreamName(dinopayPaymentId.String()),
+            streamEvents:      created(dinopayapi.PaymentStatusPending),
+            dinopayClient:     &fakeDinopayClient{found: found, cancelErr: dinopay.ErrPaymentNotCancellable},
+            wantCancelCalls:   1,
+            wantStream:        gateway.BuildOutboundPaymentStreamName(dinopayPaymentId.String()),
+            wantLastEventType: "OutboundPaymentCancellationFailed",
+        },
+        {
+            name:              "confirmed payment is not cancelled",
+            streamName:        gateway.BuildOutboundPaymentStreamName(dinopayPaymentId.String()),
+            streamEvents:      created(dinopayapi.PaymentStatusConfirmed),
+            dinopayClient:     &fakeDinopayClient{found: found},
+            wantStream:        gateway.BuildOutboundPaymentStreamName(dinopayPaymentId.String()),
+            wantLastEventType: "OutboundPaymentCancellationFailed",
+        },
+        {
+            name:              "payment not submitted yet is blocked",
+            dinopayClient:     &fakeDinopayClient{},
+            wantStream:        gateway.BuildOutboundPaymentStreamName(paymentId.String()),
+            wantLastEventType: "OutboundPaymentCancelled",
+        },
+        {
+            name:       "payment allowed by the risk scoring but not submitted yet is blocked",
+            streamName: gateway.BuildOutboundPaymentStreamName(paymentId.String()),
+            streamEvents: []events.EventData{PaymentRiskAssessed{
+                Id:        uuid.New(),
+                PaymentId: paymentId,
+                Outcome:   riskport.OutcomeAllow,
+            }},
+            dinopayClient:     &fakeDinopayClient{},
+            wantStream:        gateway.BuildOutboundPaymentStreamName(paymentId.String()),
+            wantLastEventType: "OutboundPaymentCancelled",
+        },
+        {
+            name:       "payment with unknown outcome is not cancelled",
+            streamName: gateway.BuildOutboundPaymentStreamName(paymentId.String()),
+            streamEvents: []events.EventData{PaymentOutcomeUnknown{
+                Id:        uuid.New(),
+                PaymentId: paymentId,
+            }},
+            dinopayClient:     &fakeDinopayClient{},
+            wantStream:        gateway.BuildOutboundPaymentStreamName(paymentId.String()),
+            wantLastEventType: "OutboundPaymentCancellationFailed",
+        },
+        {
+            name:       "failed payment is left as is",
+            streamName: gateway.BuildOutboundPaymentStreamName(paymentId.String()),
+            streamEvents: []events.EventData{PaymentFailed{
+                Id:        uuid.New(),
+                PaymentId: paymentId,
+            }},
+            dinopayClient:     &fakeDinopayClient{},
+            wantStream:        gateway.BuildOutboundPaymentStreamName(paymentId.String()),
+            wantLastEventType: "OutboundPaymentFailed",
+        },
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            ctx := context.Background()
+            db := testutil.NewFakeDB()
+            if tt.streamEvents != nil {
+                _, werr := db.AppendEvents(ctx, tt.streamName, eventsourcing.ExpectedAggregateVersion{IsNew: true}, tt.streamEvents...)
+                require.NoError(t, werr)
+            }
+            service := NewCancellationService(db, tt.dinopayClient, slog.Default())
+
+            require.NoError(t, service.Cancel(ctx, paymentId))
+            // cancelling twice is a no-op
+            require.NoError(t, service.Cancel(ctx, paymentId))
+
+            require.Len(t, tt.dinopayClient.cancelled, tt.wantCancelCalls)
+            stream := db.Stream(tt.wantStream)
+            require.NotEmpty(t, stream)
+            lastEvent, err := NewEventsDeserializer().Deserialize(stream[len(stream)-1])
+            require.NoError(t, err)
+            require.Equal(t, tt.wantLastEventType, lastEvent.Type())
+        })
+    }
+}
+
+func TestCancellationService_CancelFailsLookingUpDinopay(t *testing.T) {
+    ctx := context.Background()
+    db := testutil.NewFakeDB()
+    paymentId := uuid.New()
+    service := NewCancellationService(db, &fakeDinopayClient{lookupErr: errors.New("connection refused")}, slog.Default())
+
+    werr := service.Cancel(ctx, paymentId)
+    require.Error(t, werr)
+    require.True(t, werr.IsRetryable())
+    require.Empty(t, db.Stream(gateway.BuildOutboundPaymentStreamName(paymentId.String())))
+}
+
+func TestCancellationService_Blocked(t *testing.T) {
+    ctx := context.Background()
+    db := testutil.NewFakeDB()
+    service := NewCancellationService(db, &fakeDinopayClient{}, slog.Default())
+    paymentId := uuid.New()
+
+    blocked, werr := service.Blocked(ctx, paymentId)
+    require.NoError(t, werr)
+    require.False(t, blocked)
+
+    require.NoError(t, service.Cancel(ctx, paymentId))
+
+    blocked, werr = service.Blocked(ctx, paymentId)
+    require.NoError(t, werr)
+    require.True(t, blocked)
+}
+
+func TestCancellationService_CancelRacingSubmission(t *testing.T) {
+    ctx := context.Background()
+    payment := mapping.DinopayPayment{
+        Amount:             decimal.RequireFromString("100"),
+        Currency:           "USD",
+        SourceAccount:      mapping.Account{AccountHolder: "John Doe", AccountNumber: "1200079635"},
+        DestinationAccount: mapping.Account{AccountHolder: "Jane Doe", AccountNumber: "1200079636"},
+    }
+
+    t.Run("payment cancelled before being submitted is not created on dinopay", func(t *testing.T) {
+        db := testutil.NewFakeDB()
+        dinopayClient := &fakeDinopayClient{}
+        paymentId := uuid.New()
+        require.NoError(t, NewCancellationService(db, dinopayClient, slog.Default()).Cancel(ctx, paymentId))
+
+        werr := NewSubmitter(db, dinopayClient).Submit(ctx, slog.Default(), paymentId, payment, eventsourcing.ExpectedAggregateVersion{IsNew: true})
+        require.NoError(t, werr)
+        require.Empty(t, dinopayClient.created)
+    })
+
+    t.Run("payment being submitted is not cancelled until dinopay answers", func(t *testing.T) {
+        db := testutil.NewFakeDB()
+        dinopayClient := &fakeDinopayClient{}
+        paymentId := uuid.New()
+        _, werr := db.AppendEvents(
+            ctx,
+            gateway.BuildOutboundPaymentStreamName(paymentId.String()),
+            eventsourcing.ExpectedAggregateVersion{IsNew: true},
+            PaymentSubmitting{Id: uuid.New(), PaymentId: paymentId},
+        )
+        require.NoError(t, werr)
+
+        werr = NewCancellationService(db, dinopayClient, slog.Default()).Cancel(ctx, paymentId)
+        require.Error(t, werr)
+        require.True(t, werr.IsRetryable())
+        require.Len(t, db.Stream(gateway.BuildOutboundPaymentStreamName(paymentId.String())), 1)
+    })
+
+    t.Run("submitted payment is cancelled on dinopay", func(t *testing.T) {
+        db := testutil.NewFakeDB()
+        dinopayPaymentId := uuid.New()
+        dinopayClient := &fakeDinopayClient{
+            createRes: &dinopayapi.Payment{
+                ID:     dinopayapi.NewOptUUID(dinopayPaymentId),
+                Status: dinopayapi.NewOptPaymentStatus(dinopayapi.PaymentStatusPending),
+            },
+            cancelRes: &dinopayapi.Payment{
+                ID:     dinopayapi.NewOptUUID(dinopayPaymentId),
+                Status: dinopayapi.NewOptPaymentStatus(dinopayapi.PaymentStatusRejected),
+            },
+        }
+        paymentId := uuid.New()
+        werr := NewSubmitter(db, dinopayClient).Submit(ctx, slog.Default(), paymentId, payment, eventsourcing.ExpectedAggregateVersion{IsNew: true})
+        require.NoError(t, werr)
+
+        require.NoError(t, NewCancellationService(db, dinopayClient, slog.Default()).Cancel(ctx, paymentId))
+        require.Equal(t, []uuid.UUID{dinopayPaymentId}, dinopayClient.cancelled)
+        stream := db.Stream(gateway.BuildOutboundPaymentStreamName(dinopayPaymentId.String()))
+        lastEvent, err := NewEventsDeserializer().Deserialize(stream[len(stream)-1])
+        require.NoError(t, err)
+        require.Equal(t, "OutboundPaymentCancelled", lastEvent.Type())
+    })
+}
+
+func TestSubmitter_SubmitRedelivered(t *testing.T) {
+    ctx := context.Background()
+    payment := mapping.DinopayPayment{
+        Amount:             decimal.RequireFromString("100"),
+        Currency:           "USD",
+        SourceAccount:      mapping.Account{AccountHolder: "John Doe", AccountNumber: "1200079635"},
+        DestinationAccount: mapping.Account{AccountHolder: "Jane Doe", AccountNumber: "1200079636"},
+    }
+    db := testutil.NewFakeDB()
+    dinopayClient := &fakeDinopayClient{}
+    paymentId := uuid.New()
+    // a previous attempt stopped right after appending the PaymentSubmitting event
+    _, werr := db.AppendEvents(
+        ctx,
+        gateway.BuildOutboundPaymentStreamName(paymentId.String()),
+        eventsourcing.ExpectedAggregateVersion{IsNew: true},
+        PaymentSubmitting{Id: uuid.New(), PaymentId: paymentId},
+    )
+    require.NoError(t, werr)
+
+    werr = NewSubmitter(db, dinopayClient).Submit(ctx, slog.Default(), paymentId, payment, eventsourcing.ExpectedAggregateVersion{IsNew: true})
+    require.NoError(t, werr)
+    require.Empty(t, dinopayClient.created, "the payment must not be created twice on dinopay")
+    submission, werr := LoadSubmission(ctx, db, paymentId)
+    require.NoError(t, werr)
+    require.True(t, submission.OutcomeUnknown)
+}
@@ -37,6 +37,139 @@ func (d *EventsDeserializer) Deserialize(rawPayload []byte) (events.Event[Events
             log.Printf("error deserializing OutboundPaymentUpdated event data %s: %s", event.Data, err.Error())
         }
         return outboundPaymentUpdated, nil
+    case "OutboundPaymentFailed":
+        var outboundPaymentFailed PaymentFailed
+        err := json.Unmarshal(event.Data, &outboundPaymentFailed)
+        if err != nil {
+            return nil, fmt.Errorf("error deserializing OutboundPaymentFailed event data %s: %w", event.Data, err)
+        }
+        return outboundPaymentFailed, nil
+    case "OutboundPaymentOutcomeUnknown":
+        var outboundPaymentOutcomeUnknown PaymentOutcomeUnknown
+        err := json.Unmarshal(event.Data, &outboundPaymentOutcomeUnknown)
+        if err != nil {
+            return nil, fmt.Errorf("error deserializing OutboundPaymentOutcomeUnknown event data %s: %w", event.Data, err)
+        }
+        return outboundPaymentOutcomeUnknown, nil
+    case "OutboundPaymentResolutionFailed":
+        var outboundPaymentResolutionFailed PaymentResolutionFailed
+        err := json.Unmarshal(event.Data, &outboundPaymentResolutionFailed)
+        if err != nil {
+            return nil, fmt.Errorf("error deserializing OutboundPaymentResolutionFailed event data %s: %w", event.Data, err)
+        }
+        return outboundPaymentResolutionFailed, nil
+    case "OutboundPaymentOutcomeResolved":
+        var outboundPaymentOutcomeResolved PaymentOutcomeResolved
+        err := json.Unmarshal(event.Data, &outboundPaymentOutcomeResolved)
+        if err != nil {
+            return nil, fmt.Errorf("error deserializing OutboundPaymentOutcomeResolved event data %s: %w", event.Data, err)
+        }
+        return outboundPaymentOutcomeResolved, nil
+    case "OutboundPaymentEscalated":
+        var outboundPaymentEscalated PaymentEscalated
+        err := json.Unmarshal(event.Data, &outboundPaymentEscalated)
+        if err != nil {
+            return nil, fmt.Errorf("error deserializing OutboundPaymentEscalated event data %s: %w", event.Data, err)
+        }
+        return outboundPaymentEscalated, nil
+    case "OutboundPaymentStuck":
+        var outboundPaymentStuck PaymentStuck
+        err := json.Unmarshal(event.Data, &outboundPaymentStuck)
+        if err != nil {
+            return nil, fmt.Errorf("error deserializing OutboundPaymentStuck event data %s: %w", event.Data, err)
+        }
+        return outboundPaymentStuck, nil
+    case "OutboundPaymentCancelled":
+        var outboundPaymentCancelled PaymentCancelled
+        err := json.Unmarshal(event.Data, &outboundPaymentCancelled)
+        if err != nil {
+            return nil, fmt.Errorf("error deserializing OutboundPaymentCancelled event data %s: %w", event.Data, err)
+        }
+        return outboundPaymentCancelled, nil
+    case "OutboundPaymentCancellationFailed":
+        var outboundPaymentCancellationFailed PaymentCancellationFailed
+        err := json.Unmarshal(event.Data, &outboundPaymentCancellationFailed)
+        if err != nil {
+            return nil, fmt.Errorf("error deserializing OutboundPaymentCancellationFailed event data %s: %w", event.Data, err)
+        }
+        return outboundPaymentCancellationFailed, nil
+    case "OutboundPaymentHeld":
+        var outboundPaymentHeld PaymentHeld
+        err := json.Unmarshal(event.Data, &outboundPaymentHeld)
+        if err != nil {
+            return nil, fmt.Errorf("error deserializing OutboundPaymentHeld event data %s: %w", event.Data, err)
+        }
+        return outboundPaymentHeld, nil
+    case "OutboundPaymentReleased":
+        var outboundPaymentReleased PaymentReleased
+        err := json.Unmarshal(event.Data, &outboundPaymentReleased)
+        if err != nil {
+            return nil, fmt.Errorf("error deserializing OutboundPaymentReleased event data %s: %w", event.Data, err)
+        }
+        return outboundPaymentReleased, nil
+    case "OutboundPaymentAwaitingApproval":
+        var outboundPaymentAwaitingApproval PaymentAwaitingApproval
+        err := json.Unmarshal(event.Data, &outboundPaymentAwaitingApproval)
+        if err != nil {
+            return nil, fmt.Errorf("error deserializing OutboundPaymentAwaitingApproval event data %s: %w", event.Data, err)
+        }
+        return outboundPaymentAwaitingApproval, nil
+    case "OutboundPaymentFirstApproved":
+        var outboundPaymentFirstApproved PaymentFirstApproved
+        err := json.Unmarshal(event.Data, &outboundPaymentFirstApproved)
+        if err != nil {
+            return nil, fmt.Errorf("error deserializing OutboundPaymentFirstApproved event data %s: %w", event.Data, err)
+        }
+        return outboundPaymentFirstApproved, nil
+    case "OutboundPaymentApproved":
+        var outboundPaymentApproved PaymentApproved
+        err := json.Unmarshal(event.Data, &outboundPaymentApproved)
+        if err != nil {
+            return nil, fmt.Errorf("error deserializing OutboundPaymentApproved event data %s: %w", event.Data, err)
+        }
+        return outboundPaymentApproved, nil
+    case "OutboundPaymentApprovalRejected":
+        var outboundPaymentApprovalRejected PaymentApprovalRejected
+        err := json.Unmarshal(event.Data, &outboundPaymentApprovalRejected)
+        if err != nil {
+            return nil, fmt.Errorf("error deserializing OutboundPaymentApprovalRejected event data %s: %w", event.Data, err)
+        }
+        return outboundPaymentApprovalRejected, nil
+    case "OutboundPaymentRiskAssessed":
+        var outboundPaymentRiskAssessed PaymentRiskAssessed
+        err := json.Unmarshal(event.Data, &outboundPaymentRiskAssessed)
+        if err != nil {
+            return nil, fmt.Errorf("error deserializing OutboundPaymentRiskAssessed event data %s: %w", event.Data, err)
+        }
+        return outboundPaymentRiskAssessed, nil
+    case "OutboundPaymentScreened":
+        var outboundPaymentScreened PaymentScreened
+        err := json.Unmarshal(event.Data, &outboundPaymentScreened)
+        if err != nil {
+            return nil, fmt.Errorf("error deserializing OutboundPaymentScreened event data %s: %w", event.Data, err)
+        }
+        return outboundPaymentScreened, nil
+    case "OutboundPaymentNotified":
+        var outboundPaymentNotified PaymentNotified
+        err := json.Unmarshal(event.Data, &outboundPaymentNotified)
+        if err != nil {
+            return nil, fmt.Errorf("error deserializing OutboundPaymentNotified event data %s: %w", event.Data, err)
+        }
+        return outboundPaymentNotified, nil
+    case "OutboundPaymentSubmitting":
+        var outboundPaymentSubmitting PaymentSubmitting
+        err := json.Unmarshal(event.Data, &outboundPaymentSubmitting)
+        if err != nil {
+            return nil, fmt.Errorf("error deserializing OutboundPaymentSubmitting event data %s: %w", event.Data, err)
+        }
+        return outboundPaymentSubmitting, nil
+    case "OutboundPaymentSubmitted":
+        var outboundPaymentSubmitted PaymentSubmitted
+        err := json.Unmarshal(event.Data, &outboundPaymentSubmitted)
+        if err != nil {
+            return nil, fmt.Errorf("error deserializing OutboundPaymentSubmitted event data %s: %w", event.Data, err)
+        }
+        return outboundPaymentSubmitted, nil
     default:
         return nil, fmt.Errorf("unexpected event type: %s", event.Type)
     }
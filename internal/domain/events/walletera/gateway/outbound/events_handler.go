@@ -4,6 +4,7 @@ import (
     "context"
     "errors"
     "log/slog"
+    "time"
 
     "github.com/walletera/dinopay-gateway/internal/domain/events/walletera/gateway/inbound"
     "github.com/walletera/dinopay-gateway/pkg/logattr"
@@ -15,6 +16,25 @@ import (
 type EventsHandler interface {
     HandleOutboundPaymentCreated(ctx context.Context, outboundPaymentCreated PaymentCreated) werrors.WError
     HandleOutboundPaymentUpdated(ctx context.Context, outboundPaymentUpdated PaymentUpdated) werrors.WError
+    HandleOutboundPaymentFailed(ctx context.Context, outboundPaymentFailed PaymentFailed) werrors.WError
+    HandleOutboundPaymentOutcomeUnknown(ctx context.Context, outboundPaymentOutcomeUnknown PaymentOutcomeUnknown) werrors.WError
+    HandleOutboundPaymentResolutionFailed(ctx context.Context, outboundPaymentResolutionFailed PaymentResolutionFailed) werrors.WError
+    HandleOutboundPaymentOutcomeResolved(ctx context.Context, outboundPaymentOutcomeResolved PaymentOutcomeResolved) werrors.WError
+    HandleOutboundPaymentEscalated(ctx context.Context, outboundPaymentEscalated PaymentEscalated) werrors.WError
+    HandleOutboundPaymentStuck(ctx context.Context, outboundPaymentStuck PaymentStuck) werrors.WError
+    HandleOutboundPaymentCancelled(ctx context.Context, outboundPaymentCancelled PaymentCancelled) werrors.WError
+    HandleOutboundPaymentCancellationFailed(ctx context.Context, outboundPaymentCancellationFailed PaymentCancellationFailed) werrors.WError
+    HandleOutboundPaymentHeld(ctx context.Context, outboundPaymentHeld PaymentHeld) werrors.WError
+    HandleOutboundPaymentReleased(ctx context.Context, outboundPaymentReleased PaymentReleased) werrors.WError
+    HandleOutboundPaymentAwaitingApproval(ctx context.Context, outboundPaymentAwaitingApproval PaymentAwaitingApproval) werrors.WError
+    HandleOutboundPaymentFirstApproved(ctx context.Context, outboundPaymentFirstApproved PaymentFirstApproved) werrors.WError
+    HandleOutboundPaymentApproved(ctx context.Context, outboundPaymentApproved PaymentApproved) werrors.WError
+    HandleOutboundPaymentApprovalRejected(ctx context.Context, outboundPaymentApprovalRejected PaymentApprovalRejected) werrors.WError
+    HandleOutboundPaymentRiskAssessed(ctx context.Context, outboundPaymentRiskAssessed PaymentRiskAssessed) werrors.WError
+    HandleOutboundPaymentScreened(ctx context.Context, outboundPaymentScreened PaymentScreened) werrors.WError
+    HandleOutboundPaymentNotified(ctx context.Context, outboundPaymentNotified PaymentNotified) werrors.WError
+    HandleOutboundPaymentSubmitting(ctx context.Context, outboundPaymentSubmitting PaymentSubmitting) werrors.WError
+    HandleOutboundPaymentSubmitted(ctx context.Context, outboundPaymentSubmitted PaymentSubmitted) werrors.WError
 }
 
 type EventsHandlerImpl struct {
@@ -56,6 +76,246 @@ func (ev *EventsHandlerImpl) HandleOutboundPaymentUpdated(ctx context.Context, o
     return nil
 }
 
+func (ev *EventsHandlerImpl) HandleOutboundPaymentFailed(ctx context.Context, outboundPaymentFailed PaymentFailed) werrors.WError {
+    logger := ev.logger.With(
+        logattr.EventType(outboundPaymentFailed.Type()),
+        logattr.PaymentId(outboundPaymentFailed.PaymentId.String()),
+        logattr.Reason(string(outboundPaymentFailed.Reason)),
+    )
+    err := failPayment(ctx, ev.paymentsClient, outboundPaymentFailed.PaymentId)
+    if err != nil {
+        logger.Error(err.Message())
+        return werrors.NewWrappedError(err, "failed handling outbound PaymentFailed event")
+    }
+    logger.Info("OutboundPaymentFailed event processed successfully")
+    return nil
+}
+
+// HandleOutboundPaymentOutcomeUnknown only logs, the UnknownOutcomeResolver resolves the payment
+func (ev *EventsHandlerImpl) HandleOutboundPaymentOutcomeUnknown(_ context.Context, outboundPaymentOutcomeUnknown PaymentOutcomeUnknown) werrors.WError {
+    ev.logger.Warn(
+        "outbound payment outcome is unknown",
+        logattr.EventType(outboundPaymentOutcomeUnknown.Type()),
+        logattr.PaymentId(outboundPaymentOutcomeUnknown.PaymentId.String()),
+        logattr.Error(outboundPaymentOutcomeUnknown.Error),
+    )
+    return nil
+}
+
+func (ev *EventsHandlerImpl) HandleOutboundPaymentResolutionFailed(_ context.Context, outboundPaymentResolutionFailed PaymentResolutionFailed) werrors.WError {
+    ev.logger.Warn(
+        "outbound payment resolution attempt failed",
+        logattr.EventType(outboundPaymentResolutionFailed.Type()),
+        logattr.PaymentId(outboundPaymentResolutionFailed.PaymentId.String()),
+        slog.Int("attempt", outboundPaymentResolutionFailed.Attempt),
+        logattr.Error(outboundPaymentResolutionFailed.Error),
+    )
+    return nil
+}
+
+// HandleOutboundPaymentOutcomeResolved only logs, the OutboundPaymentCreated
+// event appended together with it updates the payment on the Payments API
+func (ev *EventsHandlerImpl) HandleOutboundPaymentOutcomeResolved(_ context.Context, outboundPaymentOutcomeResolved PaymentOutcomeResolved) werrors.WError {
+    ev.logger.Info(
+        "outbound payment outcome resolved",
+        logattr.EventType(outboundPaymentOutcomeResolved.Type()),
+        logattr.PaymentId(outboundPaymentOutcomeResolved.PaymentId.String()),
+        logattr.DinopayPaymentId(outboundPaymentOutcomeResolved.DinopayPaymentId.String()),
+        slog.String("resolution", string(outboundPaymentOutcomeResolved.Resolution)),
+    )
+    return nil
+}
+
+func (ev *EventsHandlerImpl) HandleOutboundPaymentEscalated(_ context.Context, outboundPaymentEscalated PaymentEscalated) werrors.WError {
+    ev.logger.Error(
+        "outbound payment outcome is still unknown, escalated to operators",
+        logattr.EventType(outboundPaymentEscalated.Type()),
+        logattr.PaymentId(outboundPaymentEscalated.PaymentId.String()),
+        slog.Int("attempts", outboundPaymentEscalated.Attempts),
+    )
+    return nil
+}
+
+func (ev *EventsHandlerImpl) HandleOutboundPaymentStuck(_ context.Context, outboundPaymentStuck PaymentStuck) werrors.WError {
+    ev.logger.Error(
+        "outbound payment is stuck in pending on dinopay",
+        logattr.EventType(outboundPaymentStuck.Type()),
+        logattr.PaymentId(outboundPaymentStuck.PaymentId.String()),
+        logattr.DinopayPaymentId(outboundPaymentStuck.DinopayPaymentId.String()),
+        slog.Time("pending_since", time.UnixMilli(outboundPaymentStuck.PendingSince)),
+    )
+    return nil
+}
+
+// HandleOutboundPaymentCancelled confirms the cancellation to the Payments API
+func (ev *EventsHandlerImpl) HandleOutboundPaymentCancelled(ctx context.Context, outboundPaymentCancelled PaymentCancelled) werrors.WError {
+    logger := ev.logger.With(
+        logattr.EventType(outboundPaymentCancelled.Type()),
+        logattr.PaymentId(outboundPaymentCancelled.PaymentId.String()),
+        logattr.DinopayPaymentId(outboundPaymentCancelled.DinopayPaymentId.String()),
+    )
+    err := reportPaymentStatus(ctx, ev.paymentsClient, outboundPaymentCancelled.PaymentId, outboundPaymentCancelled.DinopayPaymentId, paymentsapi.PaymentStatusRejected)
+    if err != nil {
+        logger.Error(err.Message())
+        return werrors.NewWrappedError(err, "failed handling outbound PaymentCancelled event")
+    }
+    logger.Info("OutboundPaymentCancelled event processed successfully")
+    return nil
+}
+
+// HandleOutboundPaymentCancellationFailed reports the status the payment still
+// has, so the Payments service reverts the cancellation
+func (ev *EventsHandlerImpl) HandleOutboundPaymentCancellationFailed(ctx context.Context, outboundPaymentCancellationFailed PaymentCancellationFailed) werrors.WError {
+    logger := ev.logger.With(
+        logattr.EventType(outboundPaymentCancellationFailed.Type()),
+        logattr.PaymentId(outboundPaymentCancellationFailed.PaymentId.String()),
+        logattr.DinopayPaymentId(outboundPaymentCancellationFailed.DinopayPaymentId.String()),
+        logattr.Reason(outboundPaymentCancellationFailed.Reason),
+    )
+    status := paymentsapi.PaymentStatusPending
+    if outboundPaymentCancellationFailed.DinopayPaymentStatus != "" {
+        var err werrors.WError
+        status, err = dinopayStatus2PaymentsStatus(outboundPaymentCancellationFailed.DinopayPaymentStatus)
+        if err != nil {
+            logger.Error(err.Message())
+            return werrors.NewWrappedError(err, "failed handling outbound PaymentCancellationFailed event")
+        }
+    }
+    err := reportPaymentStatus(ctx, ev.paymentsClient, outboundPaymentCancellationFailed.PaymentId, outboundPaymentCancellationFailed.DinopayPaymentId, status)
+    if err != nil {
+        logger.Error(err.Message())
+        return werrors.NewWrappedError(err, "failed handling outbound PaymentCancellationFailed event")
+    }
+    logger.Warn("outbound payment cancellation failed")
+    return nil
+}
+
+// HandleOutboundPaymentHeld only logs, the payment waits for an operator
+// and stays pending on the Payments API meanwhile
+func (ev *EventsHandlerImpl) HandleOutboundPaymentHeld(_ context.Context, outboundPaymentHeld PaymentHeld) werrors.WError {
+    ev.logger.Warn(
+        "outbound payment held for review",
+        logattr.EventType(outboundPaymentHeld.Type()),
+        logattr.PaymentId(outboundPaymentHeld.PaymentId.String()),
+        logattr.Reason(string(outboundPaymentHeld.Reason)),
+        slog.String("details", outboundPaymentHeld.Details),
+    )
+    return nil
+}
+
+func (ev *EventsHandlerImpl) HandleOutboundPaymentReleased(_ context.Context, outboundPaymentReleased PaymentReleased) werrors.WError {
+    ev.logger.Info(
+        "outbound payment released",
+        logattr.EventType(outboundPaymentReleased.Type()),
+        logattr.PaymentId(outboundPaymentReleased.PaymentId.String()),
+        slog.String("released_by", outboundPaymentReleased.ReleasedBy),
+    )
+    return nil
+}
+
+// HandleOutboundPaymentAwaitingApproval only logs, the payment stays
+// pending on the Payments API until it's approved or rejected
+func (ev *EventsHandlerImpl) HandleOutboundPaymentAwaitingApproval(_ context.Context, outboundPaymentAwaitingApproval PaymentAwaitingApproval) werrors.WError {
+    ev.logger.Info(
+        "outbound payment awaiting approval",
+        logattr.EventType(outboundPaymentAwaitingApproval.Type()),
+        logattr.PaymentId(outboundPaymentAwaitingApproval.PaymentId.String()),
+        slog.String("threshold", outboundPaymentAwaitingApproval.Threshold.String()),
+    )
+    return nil
+}
+
+func (ev *EventsHandlerImpl) HandleOutboundPaymentFirstApproved(_ context.Context, outboundPaymentFirstApproved PaymentFirstApproved) werrors.WError {
+    ev.logger.Info(
+        "outbound payment first approved",
+        logattr.EventType(outboundPaymentFirstApproved.Type()),
+        logattr.PaymentId(outboundPaymentFirstApproved.PaymentId.String()),
+        slog.String("approved_by", outboundPaymentFirstApproved.ApprovedBy),
+    )
+    return nil
+}
+
+func (ev *EventsHandlerImpl) HandleOutboundPaymentApproved(_ context.Context, outboundPaymentApproved PaymentApproved) werrors.WError {
+    ev.logger.Info(
+        "outbound payment approved",
+        logattr.EventType(outboundPaymentApproved.Type()),
+        logattr.PaymentId(outboundPaymentApproved.PaymentId.String()),
+        slog.String("approved_by", outboundPaymentApproved.ApprovedBy),
+    )
+    return nil
+}
+
+// HandleOutboundPaymentApprovalRejected only logs, the PaymentFailed event
+// appended with it reports the payment as failed
+func (ev *EventsHandlerImpl) HandleOutboundPaymentApprovalRejected(_ context.Context, outboundPaymentApprovalRejected PaymentApprovalRejected) werrors.WError {
+    ev.logger.Info(
+        "outbound payment approval rejected",
+        logattr.EventType(outboundPaymentApprovalRejected.Type()),
+        logattr.PaymentId(outboundPaymentApprovalRejected.PaymentId.String()),
+        slog.String("rejected_by", outboundPaymentApprovalRejected.RejectedBy),
+    )
+    return nil
+}
+
+// HandleOutboundPaymentRiskAssessed only logs, the event appended
+// after it acts upon the decision
+func (ev *EventsHandlerImpl) HandleOutboundPaymentRiskAssessed(_ context.Context, outboundPaymentRiskAssessed PaymentRiskAssessed) werrors.WError {
+    ev.logger.Info(
+        "outbound payment risk assessed",
+        logattr.EventType(outboundPaymentRiskAssessed.Type()),
+        logattr.PaymentId(outboundPaymentRiskAssessed.PaymentId.String()),
+        slog.String("outcome", string(outboundPaymentRiskAssessed.Outcome)),
+    )
+    return nil
+}
+
+// HandleOutboundPaymentScreened only logs, the event appended
+// along with it acts upon the result
+func (ev *EventsHandlerImpl) HandleOutboundPaymentScreened(_ context.Context, outboundPaymentScreened PaymentScreened) werrors.WError {
+    ev.logger.Info(
+        "outbound payment screened",
+        logattr.EventType(outboundPaymentScreened.Type()),
+        logattr.PaymentId(outboundPaymentScreened.PaymentId.String()),
+        slog.String("party", string(outboundPaymentScreened.Party)),
+        slog.Bool("hit", outboundPaymentScreened.Result.Hit),
+    )
+    return nil
+}
+
+// HandleOutboundPaymentNotified only logs, DinoPay's webhook for
+// the payout carries no status to report to the Payments API
+func (ev *EventsHandlerImpl) HandleOutboundPaymentNotified(_ context.Context, outboundPaymentNotified PaymentNotified) werrors.WError {
+    ev.logger.Info(
+        "dinopay notified outbound payment",
+        logattr.EventType(outboundPaymentNotified.Type()),
+        logattr.PaymentId(outboundPaymentNotified.PaymentId.String()),
+        logattr.DinopayPaymentId(outboundPaymentNotified.DinopayPaymentId.String()),
+    )
+    return nil
+}
+
+// HandleOutboundPaymentSubmitting only logs, the payment is being created on DinoPay
+func (ev *EventsHandlerImpl) HandleOutboundPaymentSubmitting(_ context.Context, outboundPaymentSubmitting PaymentSubmitting) werrors.WError {
+    ev.logger.Debug(
+        "outbound payment submitting",
+        logattr.EventType(outboundPaymentSubmitting.Type()),
+        logattr.PaymentId(outboundPaymentSubmitting.PaymentId.String()),
+    )
+    return nil
+}
+
+// HandleOutboundPaymentSubmitted only logs, the OutboundPaymentCreated
+// event appended after it updates the payment on the Payments API
+func (ev *EventsHandlerImpl) HandleOutboundPaymentSubmitted(_ context.Context, outboundPaymentSubmitted PaymentSubmitted) werrors.WError {
+    ev.logger.Info(
+        "outbound payment submitted",
+        logattr.EventType(outboundPaymentSubmitted.Type()),
+        logattr.PaymentId(outboundPaymentSubmitted.PaymentId.String()),
+        logattr.DinopayPaymentId(outboundPaymentSubmitted.DinopayPaymentId.String()),
+    )
+    return nil
+}
+
 func (ev *EventsHandlerImpl) HandleInboundPaymentReceived(ctx context.Context, inboundPaymentReceived inbound.PaymentReceived) werrors.WError {
     //err := NewInboundPaymentReceivedHandler(ev.db, ev.paymentsClient).Handle(ctx, inboundPaymentReceived)
     //if err != nil {
@@ -0,0 +1,57 @@
+package outbound
+
+import (
+    "io"
+    "strings"
+)
+
+// maxRejectionDetailsSize limits how much of a DinoPay error response is kept
+const maxRejectionDetailsSize = 1024
+
+// FailureReason explains why DinoPay, the ValidationRules or the risk
+// scoring before submitting it, or the operator reviewing it rejected an
+// outbound payment
+type FailureReason string
+
+const (
+    FailureReasonInvalidAccount      FailureReason = "invalid_account"
+    FailureReasonInsufficientFunds   FailureReason = "insufficient_funds"
+    FailureReasonLimitExceeded       FailureReason = "limit_exceeded"
+    FailureReasonUnsupportedCurrency FailureReason = "unsupported_currency"
+    FailureReasonInvalidAmount       FailureReason = "invalid_amount"
+    FailureReasonSameAccount         FailureReason = "same_account"
+    FailureReasonRejectedOnReview    FailureReason = "rejected_on_review"
+    FailureReasonRiskBlocked         FailureReason = "risk_blocked"
+    FailureReasonUnknown             FailureReason = "unknown"
+)
+
+var failureReasonKeywords = []struct {
+    reason   FailureReason
+    keywords []string
+}{
+    {FailureReasonInsufficientFunds, []string{"insufficient funds", "insufficient balance", "not enough funds"}},
+    {FailureReasonLimitExceeded, []string{"limit exceeded", "exceeds the limit", "exceeds limit", "over limit"}},
+    {FailureReasonInvalidAccount, []string{"invalid account", "account not found", "unknown account", "account does not exist", "account is closed"}},
+}
+
+// ParseDinopayRejection classifies the body of a DinoPay 400 response.
+// DinoPay answers with a human readable text/html message instead
+// of an error code, so the classification is based on keywords.
+func ParseDinopayRejection(body string) FailureReason {
+    message := strings.ToLower(body)
+    for _, candidate := range failureReasonKeywords {
+        for _, keyword := range candidate.keywords {
+            if strings.Contains(message, keyword) {
+                return candidate.reason
+            }
+        }
+    }
+    return FailureReasonUnknown
+}
+
+// ReadDinopayRejection reads the body of a DinoPay 400 response,
+// truncated to maxRejectionDetailsSize bytes
+func ReadDinopayRejection(body io.Reader) (string, error) {
+    details, err := io.ReadAll(io.LimitReader(body, maxRejectionDetailsSize))
+    return string(details), err
+}
@@ -0,0 +1,26 @@
+package outbound
+
+import (
+    "testing"
+
+    "github.com/stretchr/testify/assert"
+)
+
+func TestParseDinopayRejection(t *testing.T) {
+    tests := []struct {
+        body     string
+        expected FailureReason
+    }{
+        {body: "<html><body>Insufficient funds in source account</body></html>", expected: FailureReasonInsufficientFunds},
+        {body: "daily limit exceeded", expected: FailureReasonLimitExceeded},
+        {body: "Invalid account number", expected: FailureReasonInvalidAccount},
+        {body: "destination account not found", expected: FailureReasonInvalidAccount},
+        {body: "something went wrong", expected: FailureReasonUnknown},
+        {body: "", expected: FailureReasonUnknown},
+    }
+    for _, tt := range tests {
+        t.Run(tt.body, func(t *testing.T) {
+            assert.Equal(t, tt.expected, ParseDinopayRejection(tt.body))
+        })
+    }
+}
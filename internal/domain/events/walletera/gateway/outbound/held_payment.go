@@ -0,0 +1,248 @@
+package outbound
+
+import (
+    "context"
+    "time"
+
+    "github.com/google/uuid"
+    "github.com/shopspring/decimal"
+    "github.com/walletera/dinopay-gateway/internal/domain/events/walletera/gateway"
+    "github.com/walletera/dinopay-gateway/internal/domain/mapping"
+    "github.com/walletera/eventskit/eventsourcing"
+    "github.com/walletera/werrors"
+)
+
+type HoldStatus string
+
+const (
+    HoldStatusHeld             HoldStatus = "held"
+    HoldStatusReleased         HoldStatus = "released"
+    HoldStatusAwaitingApproval HoldStatus = "awaiting_approval"
+    HoldStatusApproved         HoldStatus = "approved"
+    HoldStatusRejected         HoldStatus = "rejected"
+    HoldStatusCancelled        HoldStatus = "cancelled"
+)
+
+// HeldPayment is the state of an outbound payment held for review or
+// awaiting approval, rebuilt from the events of the stream named after
+// the Walletera payment id.
+type HeldPayment struct {
+    PaymentId          uuid.UUID          `json:"paymentId"`
+    CustomerId         uuid.UUID          `json:"customerId"`
+    Status             HoldStatus         `json:"status"`
+    Amount             decimal.Decimal    `json:"amount"`
+    Currency           string             `json:"currency"`
+    SourceAccount      mapping.Account    `json:"sourceAccount"`
+    DestinationAccount mapping.Account    `json:"destinationAccount"`
+    Reason             gateway.HoldReason `json:"reason,omitempty"`
+    Details            string             `json:"details,omitempty"`
+    Threshold          *decimal.Decimal   `json:"threshold,omitempty"`
+    RequestedBy        string             `json:"requestedBy,omitempty"`
+    FirstApprovedBy    string             `json:"firstApprovedBy,omitempty"`
+    HeldAt             time.Time          `json:"heldAt"`
+    ReviewedBy         string             `json:"reviewedBy,omitempty"`
+    Version            uint64             `json:"-"`
+    // DecisionVersion is the version of the PaymentReleased or PaymentApproved
+    // event, the submission of the payment continues from it
+    DecisionVersion uint64 `json:"-"`
+}
+
+var _ EventsHandler = (*HeldPayment)(nil)
+
+// LoadHeldPayment reads the outboundPayment stream of the given Walletera payment
+func LoadHeldPayment(ctx context.Context, db eventsourcing.DB, paymentId uuid.UUID) (*HeldPayment, werrors.WError) {
+    retrievedEvents, werr := db.ReadEvents(ctx, gateway.BuildOutboundPaymentStreamName(paymentId.String()))
+    if werr != nil {
+        return nil, werr
+    }
+    payment := &HeldPayment{}
+    deserializer := NewEventsDeserializer()
+    for _, retrievedEvent := range retrievedEvents {
+        event, err := deserializer.Deserialize(retrievedEvent.RawEvent)
+        if err != nil {
+            return nil, werrors.NewNonRetryableInternalError("failed deserializing outbound payment event: " + err.Error())
+        }
+        werr = event.Accept(ctx, payment)
+        if werr != nil {
+            return nil, werr
+        }
+        switch event.(type) {
+        case PaymentReleased, PaymentApproved:
+            payment.DecisionVersion = retrievedEvent.AggregateVersion
+        }
+        payment.Version = retrievedEvent.AggregateVersion
+    }
+    if payment.PaymentId == uuid.Nil {
+        return nil, werrors.NewResourceNotFoundError("outbound payment " + paymentId.String() + " was neither held nor awaiting approval")
+    }
+    return payment, nil
+}
+
+// ListHeldPayments returns the payments in the given status, oldest first
+func ListHeldPayments(ctx context.Context, db eventsourcing.DB, categoryStreamName string, status HoldStatus) ([]*HeldPayment, werrors.WError) {
+    retrievedEvents, werr := db.ReadEvents(ctx, categoryStreamName)
+    if werr != nil {
+        if werr.Code() == werrors.ResourceNotFoundErrorCode {
+            return nil, nil
+        }
+        return nil, werr
+    }
+    deserializer := NewEventsDeserializer()
+    var heldPayments []*HeldPayment
+    for _, retrievedEvent := range retrievedEvents {
+        event, err := deserializer.Deserialize(retrievedEvent.RawEvent)
+        if err != nil {
+            return nil, werrors.NewNonRetryableInternalError("failed deserializing outbound payment event: " + err.Error())
+        }
+        var paymentId uuid.UUID
+        switch e := event.(type) {
+        case PaymentHeld:
+            paymentId = e.PaymentId
+        case PaymentAwaitingApproval:
+            paymentId = e.PaymentId
+        default:
+            continue
+        }
+        heldPayment, werr := LoadHeldPayment(ctx, db, paymentId)
+        if werr != nil {
+            return nil, werr
+        }
+        if heldPayment.Status == status {
+            heldPayments = append(heldPayments, heldPayment)
+        }
+    }
+    return heldPayments, nil
+}
+
+// DinopayPayment returns the DinoPay payment to submit once released
+func (p *HeldPayment) DinopayPayment() mapping.DinopayPayment {
+    return mapping.DinopayPayment{
+        Amount:             p.Amount,
+        Currency:           p.Currency,
+        SourceAccount:      p.SourceAccount,
+        DestinationAccount: p.DestinationAccount,
+    }
+}
+
+func (p *HeldPayment) HandleOutboundPaymentHeld(_ context.Context, paymentHeld PaymentHeld) werrors.WError {
+    p.PaymentId = paymentHeld.PaymentId
+    p.CustomerId = paymentHeld.CustomerId
+    p.Status = HoldStatusHeld
+    p.Amount = paymentHeld.Amount
+    p.Currency = paymentHeld.Currency
+    p.SourceAccount = paymentHeld.SourceAccount
+    p.DestinationAccount = paymentHeld.DestinationAccount
+    p.Reason = paymentHeld.Reason
+    p.Details = paymentHeld.Details
+    p.HeldAt = paymentHeld.CreatedAt()
+    return nil
+}
+
+func (p *HeldPayment) HandleOutboundPaymentReleased(_ context.Context, paymentReleased PaymentReleased) werrors.WError {
+    p.Status = HoldStatusReleased
+    p.ReviewedBy = paymentReleased.ReleasedBy
+    return nil
+}
+
+func (p *HeldPayment) HandleOutboundPaymentAwaitingApproval(_ context.Context, paymentAwaitingApproval PaymentAwaitingApproval) werrors.WError {
+    p.PaymentId = paymentAwaitingApproval.PaymentId
+    p.CustomerId = paymentAwaitingApproval.CustomerId
+    p.Status = HoldStatusAwaitingApproval
+    p.Amount = paymentAwaitingApproval.Amount
+    p.Currency = paymentAwaitingApproval.Currency
+    p.SourceAccount = paymentAwaitingApproval.SourceAccount
+    p.DestinationAccount = paymentAwaitingApproval.DestinationAccount
+    p.Threshold = &paymentAwaitingApproval.Threshold
+    p.RequestedBy = paymentAwaitingApproval.RequestedBy
+    p.HeldAt = paymentAwaitingApproval.CreatedAt()
+    return nil
+}
+
+func (p *HeldPayment) HandleOutboundPaymentFirstApproved(_ context.Context, paymentFirstApproved PaymentFirstApproved) werrors.WError {
+    p.FirstApprovedBy = paymentFirstApproved.ApprovedBy
+    return nil
+}
+
+func (p *HeldPayment) HandleOutboundPaymentApproved(_ context.Context, paymentApproved PaymentApproved) werrors.WError {
+    p.Status = HoldStatusApproved
+    p.ReviewedBy = paymentApproved.ApprovedBy
+    return nil
+}
+
+func (p *HeldPayment) HandleOutboundPaymentApprovalRejected(_ context.Context, paymentApprovalRejected PaymentApprovalRejected) werrors.WError {
+    p.ReviewedBy = paymentApprovalRejected.RejectedBy
+    return nil
+}
+
+func (p *HeldPayment) HandleOutboundPaymentRiskAssessed(_ context.Context, _ PaymentRiskAssessed) werrors.WError {
+    return nil
+}
+
+func (p *HeldPayment) HandleOutboundPaymentScreened(_ context.Context, _ PaymentScreened) werrors.WError {
+    return nil
+}
+
+func (p *HeldPayment) HandleOutboundPaymentNotified(_ context.Context, _ PaymentNotified) werrors.WError {
+    return nil
+}
+
+func (p *HeldPayment) HandleOutboundPaymentSubmitting(_ context.Context, _ PaymentSubmitting) werrors.WError {
+    return nil
+}
+
+func (p *HeldPayment) HandleOutboundPaymentSubmitted(_ context.Context, _ PaymentSubmitted) werrors.WError {
+    return nil
+}
+
+// HandleOutboundPaymentFailed marks the payment as rejected when it was failed
+// before being submitted. Once submitted, the failure comes from DinoPay instead.
+func (p *HeldPayment) HandleOutboundPaymentFailed(_ context.Context, _ PaymentFailed) werrors.WError {
+    if p.pendingDecision() {
+        p.Status = HoldStatusRejected
+    }
+    return nil
+}
+
+func (p *HeldPayment) HandleOutboundPaymentCancelled(_ context.Context, _ PaymentCancelled) werrors.WError {
+    if p.pendingDecision() {
+        p.Status = HoldStatusCancelled
+    }
+    return nil
+}
+
+// pendingDecision tells whether the payment still waits for an operator
+func (p *HeldPayment) pendingDecision() bool {
+    return p.Status == HoldStatusHeld || p.Status == HoldStatusAwaitingApproval
+}
+
+func (p *HeldPayment) HandleOutboundPaymentCreated(_ context.Context, _ PaymentCreated) werrors.WError {
+    return nil
+}
+
+func (p *HeldPayment) HandleOutboundPaymentUpdated(_ context.Context, _ PaymentUpdated) werrors.WError {
+    return nil
+}
+
+func (p *HeldPayment) HandleOutboundPaymentOutcomeUnknown(_ context.Context, _ PaymentOutcomeUnknown) werrors.WError {
+    return nil
+}
+
+func (p *HeldPayment) HandleOutboundPaymentResolutionFailed(_ context.Context, _ PaymentResolutionFailed) werrors.WError {
+    return nil
+}
+
+func (p *HeldPayment) HandleOutboundPaymentOutcomeResolved(_ context.Context, _ PaymentOutcomeResolved) werrors.WError {
+    return nil
+}
+
+func (p *HeldPayment) HandleOutboundPaymentEscalated(_ context.Context, _ PaymentEscalated) werrors.WError {
+    return nil
+}
+
+func (p *HeldPayment) HandleOutboundPaymentStuck(_ context.Context, _ PaymentStuck) werrors.WError {
+    return nil
+}
+
+func (p *HeldPayment) HandleOutboundPaymentCancellationFailed(_ context.Context, _ PaymentCancellationFailed) werrors.WError {
+    return nil
+}
@@ -0,0 +1,216 @@
+package outbound
+
+import (
+    "context"
+    "sort"
+    "time"
+
+    "github.com/google/uuid"
+    "github.com/walletera/dinopay-gateway/internal/domain/events/walletera/gateway"
+    "github.com/walletera/eventskit/eventsourcing"
+    "github.com/walletera/werrors"
+)
+
+// Payment is the state of an outbound payment rebuilt from the
+// events of the stream named after its DinoPay payment id.
+type Payment struct {
+    PaymentId            uuid.UUID `json:"paymentId"`
+    DinopayPaymentId     uuid.UUID `json:"dinopayPaymentId"`
+    DinopayPaymentStatus string    `json:"dinopayPaymentStatus"`
+    CreatedAt            time.Time `json:"createdAt"`
+    StatusSince          time.Time `json:"statusSince"`
+    LastAlertedAt        time.Time `json:"lastAlertedAt,omitempty"`
+    CancelledAt          time.Time `json:"cancelledAt,omitempty"`
+    CancellationFailed   bool      `json:"cancellationFailed,omitempty"`
+    NotifiedAt           time.Time `json:"notifiedAt,omitempty"`
+    Version              uint64    `json:"-"`
+}
+
+var _ EventsHandler = (*Payment)(nil)
+
+// LoadPayment reads the outboundPayment stream of the given DinoPay payment.
+// A payment DinoPay rejected has no such stream, its PaymentFailed is in the
+// stream named after the Walletera payment id, read by LoadHeldPayment.
+func LoadPayment(ctx context.Context, db eventsourcing.DB, dinopayPaymentId uuid.UUID) (*Payment, werrors.WError) {
+    retrievedEvents, werr := db.ReadEvents(ctx, gateway.BuildOutboundPaymentStreamName(dinopayPaymentId.String()))
+    if werr != nil {
+        return nil, werr
+    }
+    payment := &Payment{}
+    deserializer := NewEventsDeserializer()
+    for _, retrievedEvent := range retrievedEvents {
+        event, err := deserializer.Deserialize(retrievedEvent.RawEvent)
+        if err != nil {
+            return nil, werrors.NewNonRetryableInternalError("failed deserializing outbound payment event: " + err.Error())
+        }
+        werr = event.Accept(ctx, payment)
+        if werr != nil {
+            return nil, werr
+        }
+        payment.Version = retrievedEvent.AggregateVersion
+    }
+    return payment, nil
+}
+
+// ListPayments folds the given outboundPayment category stream into the
+// payments created on DinoPay, oldest first. The payments have no Version set.
+func ListPayments(ctx context.Context, db eventsourcing.DB, categoryStreamName string) ([]*Payment, werrors.WError) {
+    retrievedEvents, werr := db.ReadEvents(ctx, categoryStreamName)
+    if werr != nil {
+        if werr.Code() == werrors.ResourceNotFoundErrorCode {
+            return nil, nil
+        }
+        return nil, werr
+    }
+    deserializer := NewEventsDeserializer()
+    paymentsById := make(map[uuid.UUID]*Payment)
+    var payments []*Payment
+    for _, retrievedEvent := range retrievedEvents {
+        event, err := deserializer.Deserialize(retrievedEvent.RawEvent)
+        if err != nil {
+            return nil, werrors.NewNonRetryableInternalError("failed deserializing outbound payment event: " + err.Error())
+        }
+        dinopayPaymentId, ok := dinopayPaymentIdOf(event)
+        if !ok {
+            // the event belongs to the stream of a Walletera payment
+            continue
+        }
+        payment, ok := paymentsById[dinopayPaymentId]
+        if !ok {
+            payment = &Payment{}
+            paymentsById[dinopayPaymentId] = payment
+            payments = append(payments, payment)
+        }
+        werr = event.Accept(ctx, payment)
+        if werr != nil {
+            return nil, werr
+        }
+    }
+    sort.SliceStable(payments, func(i, j int) bool {
+        return payments[i].CreatedAt.Before(payments[j].CreatedAt)
+    })
+    return payments, nil
+}
+
+func dinopayPaymentIdOf(event any) (uuid.UUID, bool) {
+    switch e := event.(type) {
+    case PaymentCreated:
+        return e.DinopayPaymentId, true
+    case PaymentUpdated:
+        return e.DinopayPaymentId, true
+    case PaymentStuck:
+        return e.DinopayPaymentId, true
+    case PaymentCancelled:
+        return e.DinopayPaymentId, e.DinopayPaymentId != uuid.Nil
+    case PaymentCancellationFailed:
+        return e.DinopayPaymentId, e.DinopayPaymentId != uuid.Nil
+    case PaymentNotified:
+        return e.DinopayPaymentId, true
+    default:
+        return uuid.Nil, false
+    }
+}
+
+func (p *Payment) HandleOutboundPaymentCreated(_ context.Context, paymentCreated PaymentCreated) werrors.WError {
+    p.PaymentId = paymentCreated.PaymentId
+    p.DinopayPaymentId = paymentCreated.DinopayPaymentId
+    p.DinopayPaymentStatus = paymentCreated.DinopayPaymentStatus
+    p.CreatedAt = paymentCreated.CreatedAt()
+    p.StatusSince = paymentCreated.CreatedAt()
+    return nil
+}
+
+func (p *Payment) HandleOutboundPaymentUpdated(_ context.Context, paymentUpdated PaymentUpdated) werrors.WError {
+    if paymentUpdated.DinopayPaymentStatus != p.DinopayPaymentStatus {
+        p.StatusSince = paymentUpdated.CreatedAt()
+    }
+    p.DinopayPaymentId = paymentUpdated.DinopayPaymentId
+    p.DinopayPaymentStatus = paymentUpdated.DinopayPaymentStatus
+    return nil
+}
+
+func (p *Payment) HandleOutboundPaymentStuck(_ context.Context, paymentStuck PaymentStuck) werrors.WError {
+    p.LastAlertedAt = paymentStuck.CreatedAt()
+    return nil
+}
+
+func (p *Payment) HandleOutboundPaymentCancelled(_ context.Context, paymentCancelled PaymentCancelled) werrors.WError {
+    if paymentCancelled.DinopayPaymentStatus != p.DinopayPaymentStatus {
+        p.StatusSince = paymentCancelled.CreatedAt()
+    }
+    p.DinopayPaymentStatus = paymentCancelled.DinopayPaymentStatus
+    p.CancelledAt = paymentCancelled.CreatedAt()
+    return nil
+}
+
+func (p *Payment) HandleOutboundPaymentCancellationFailed(_ context.Context, _ PaymentCancellationFailed) werrors.WError {
+    p.CancellationFailed = true
+    return nil
+}
+
+func (p *Payment) HandleOutboundPaymentNotified(_ context.Context, paymentNotified PaymentNotified) werrors.WError {
+    p.NotifiedAt = paymentNotified.CreatedAt()
+    return nil
+}
+
+func (p *Payment) HandleOutboundPaymentSubmitting(_ context.Context, _ PaymentSubmitting) werrors.WError {
+    return nil
+}
+
+func (p *Payment) HandleOutboundPaymentSubmitted(_ context.Context, _ PaymentSubmitted) werrors.WError {
+    return nil
+}
+
+// The events below live in the stream named after the Walletera payment id
+
+func (p *Payment) HandleOutboundPaymentFailed(_ context.Context, _ PaymentFailed) werrors.WError {
+    return nil
+}
+
+func (p *Payment) HandleOutboundPaymentOutcomeUnknown(_ context.Context, _ PaymentOutcomeUnknown) werrors.WError {
+    return nil
+}
+
+func (p *Payment) HandleOutboundPaymentResolutionFailed(_ context.Context, _ PaymentResolutionFailed) werrors.WError {
+    return nil
+}
+
+func (p *Payment) HandleOutboundPaymentOutcomeResolved(_ context.Context, _ PaymentOutcomeResolved) werrors.WError {
+    return nil
+}
+
+func (p *Payment) HandleOutboundPaymentEscalated(_ context.Context, _ PaymentEscalated) werrors.WError {
+    return nil
+}
+
+func (p *Payment) HandleOutboundPaymentHeld(_ context.Context, _ PaymentHeld) werrors.WError {
+    return nil
+}
+
+func (p *Payment) HandleOutboundPaymentReleased(_ context.Context, _ PaymentReleased) werrors.WError {
+    return nil
+}
+
+func (p *Payment) HandleOutboundPaymentAwaitingApproval(_ context.Context, _ PaymentAwaitingApproval) werrors.WError {
+    return nil
+}
+
+func (p *Payment) HandleOutboundPaymentFirstApproved(_ context.Context, _ PaymentFirstApproved) werrors.WError {
+    return nil
+}
+
+func (p *Payment) HandleOutboundPaymentApproved(_ context.Context, _ PaymentApproved) werrors.WError {
+    return nil
+}
+
+func (p *Payment) HandleOutboundPaymentApprovalRejected(_ context.Context, _ PaymentApprovalRejected) werrors.WError {
+    return nil
+}
+
+func (p *Payment) HandleOutboundPaymentRiskAssessed(_ context.Context, _ PaymentRiskAssessed) werrors.WError {
+    return nil
+}
+
+func (p *Payment) HandleOutboundPaymentScreened(_ context.Context, _ PaymentScreened) werrors.WError {
+    return nil
+}
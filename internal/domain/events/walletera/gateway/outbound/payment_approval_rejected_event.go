@@ -0,0 +1,65 @@
+package outbound
+
+import (
+    "context"
+    "encoding/json"
+    "fmt"
+    "time"
+
+    "github.com/google/uuid"
+    "github.com/walletera/dinopay-gateway/internal/domain/events/walletera/gateway"
+    "github.com/walletera/eventskit/events"
+    "github.com/walletera/werrors"
+)
+
+var _ events.Event[EventsHandler] = PaymentApprovalRejected{}
+
+// PaymentApprovalRejected is recorded when an operator rejects a payment
+// awaiting approval, together with the PaymentFailed event failing it.
+type PaymentApprovalRejected struct {
+    Id             uuid.UUID `json:"id,omitempty"`
+    PaymentId      uuid.UUID `json:"withdrawal_id,omitempty"`
+    RejectedBy     string    `json:"rejected_by"`
+    Comment        string    `json:"comment,omitempty"`
+    EventCreatedAt int64     `json:"created_at,omitempty"`
+}
+
+func (par PaymentApprovalRejected) ID() string {
+    return fmt.Sprintf("%s-%s", par.Type(), par.Id)
+}
+
+func (par PaymentApprovalRejected) Type() string {
+    return "OutboundPaymentApprovalRejected"
+}
+
+func (par PaymentApprovalRejected) DataContentType() string {
+    return "application/json"
+}
+
+func (par PaymentApprovalRejected) CorrelationID() string {
+    panic("not implemented yet")
+}
+
+func (par PaymentApprovalRejected) AggregateVersion() uint64 {
+    return 0
+}
+
+func (par PaymentApprovalRejected) CreatedAt() time.Time {
+    return time.UnixMilli(par.EventCreatedAt)
+}
+
+func (par PaymentApprovalRejected) Accept(ctx context.Context, handler EventsHandler) werrors.WError {
+    return handler.HandleOutboundPaymentApprovalRejected(ctx, par)
+}
+
+func (par PaymentApprovalRejected) Serialize() ([]byte, error) {
+    data, err := json.Marshal(par)
+    if err != nil {
+        return nil, fmt.Errorf("failed serializing OutboundPaymentApprovalRejected event: %w", err)
+    }
+    envelope := gateway.EventEnvelope{
+        Type: "OutboundPaymentApprovalRejected",
+        Data: data,
+    }
+    return json.Marshal(envelope)
+}
@@ -0,0 +1,65 @@
+package outbound
+
+import (
+    "context"
+    "encoding/json"
+    "fmt"
+    "time"
+
+    "github.com/google/uuid"
+    "github.com/walletera/dinopay-gateway/internal/domain/events/walletera/gateway"
+    "github.com/walletera/eventskit/events"
+    "github.com/walletera/werrors"
+)
+
+var _ events.Event[EventsHandler] = PaymentApproved{}
+
+// PaymentApproved is recorded when a second operator, other than the one who
+// gave the first approval, approves a payment awaiting approval, right before
+// it's submitted to DinoPay.
+type PaymentApproved struct {
+    Id             uuid.UUID `json:"id,omitempty"`
+    PaymentId      uuid.UUID `json:"withdrawal_id,omitempty"`
+    ApprovedBy     string    `json:"approved_by"`
+    EventCreatedAt int64     `json:"created_at,omitempty"`
+}
+
+func (pa PaymentApproved) ID() string {
+    return fmt.Sprintf("%s-%s", pa.Type(), pa.Id)
+}
+
+func (pa PaymentApproved) Type() string {
+    return "OutboundPaymentApproved"
+}
+
+func (pa PaymentApproved) DataContentType() string {
+    return "application/json"
+}
+
+func (pa PaymentApproved) CorrelationID() string {
+    panic("not implemented yet")
+}
+
+func (pa PaymentApproved) AggregateVersion() uint64 {
+    return 0
+}
+
+func (pa PaymentApproved) CreatedAt() time.Time {
+    return time.UnixMilli(pa.EventCreatedAt)
+}
+
+func (pa PaymentApproved) Accept(ctx context.Context, handler EventsHandler) werrors.WError {
+    return handler.HandleOutboundPaymentApproved(ctx, pa)
+}
+
+func (pa PaymentApproved) Serialize() ([]byte, error) {
+    data, err := json.Marshal(pa)
+    if err != nil {
+        return nil, fmt.Errorf("failed serializing OutboundPaymentApproved event: %w", err)
+    }
+    envelope := gateway.EventEnvelope{
+        Type: "OutboundPaymentApproved",
+        Data: data,
+    }
+    return json.Marshal(envelope)
+}
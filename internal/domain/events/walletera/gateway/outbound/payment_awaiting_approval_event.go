@@ -0,0 +1,84 @@
+package outbound
+
+import (
+    "context"
+    "encoding/json"
+    "fmt"
+    "time"
+
+    "github.com/google/uuid"
+    "github.com/shopspring/decimal"
+    "github.com/walletera/dinopay-gateway/internal/domain/events/walletera/gateway"
+    "github.com/walletera/dinopay-gateway/internal/domain/mapping"
+    "github.com/walletera/eventskit/events"
+    "github.com/walletera/werrors"
+)
+
+var _ events.Event[EventsHandler] = PaymentAwaitingApproval{}
+
+// PaymentAwaitingApproval is recorded instead of submitting the payment to DinoPay
+// when its amount is above the approval threshold of its currency. It keeps
+// everything needed to submit the payment once approved by two different
+// operators. RequestedBy is the customer who requested the payout.
+type PaymentAwaitingApproval struct {
+    Id                 uuid.UUID       `json:"id,omitempty"`
+    PaymentId          uuid.UUID       `json:"withdrawal_id,omitempty"`
+    CustomerId         uuid.UUID       `json:"customer_id,omitempty"`
+    Amount             decimal.Decimal `json:"amount"`
+    Currency           string          `json:"currency"`
+    SourceAccount      mapping.Account `json:"source_account"`
+    DestinationAccount mapping.Account `json:"destination_account"`
+    Threshold          decimal.Decimal `json:"threshold"`
+    RequestedBy        string          `json:"requested_by"`
+    EventCreatedAt     int64           `json:"created_at,omitempty"`
+}
+
+func (pa PaymentAwaitingApproval) ID() string {
+    return fmt.Sprintf("%s-%s", pa.Type(), pa.Id)
+}
+
+func (pa PaymentAwaitingApproval) Type() string {
+    return "OutboundPaymentAwaitingApproval"
+}
+
+func (pa PaymentAwaitingApproval) DataContentType() string {
+    return "application/json"
+}
+
+func (pa PaymentAwaitingApproval) CorrelationID() string {
+    panic("not implemented yet")
+}
+
+func (pa PaymentAwaitingApproval) AggregateVersion() uint64 {
+    return 0
+}
+
+func (pa PaymentAwaitingApproval) CreatedAt() time.Time {
+    return time.UnixMilli(pa.EventCreatedAt)
+}
+
+func (pa PaymentAwaitingApproval) Accept(ctx context.Context, handler EventsHandler) werrors.WError {
+    return handler.HandleOutboundPaymentAwaitingApproval(ctx, pa)
+}
+
+func (pa PaymentAwaitingApproval) Serialize() ([]byte, error) {
+    data, err := json.Marshal(pa)
+    if err != nil {
+        return nil, fmt.Errorf("failed serializing OutboundPaymentAwaitingApproval event: %w", err)
+    }
+    envelope := gateway.EventEnvelope{
+        Type: "OutboundPaymentAwaitingApproval",
+        Data: data,
+    }
+    return json.Marshal(envelope)
+}
+
+// DinopayPayment returns the DinoPay payment whose creation outcome is unknown
+func (pa PaymentAwaitingApproval) DinopayPayment() mapping.DinopayPayment {
+    return mapping.DinopayPayment{
+        Amount:             pa.Amount,
+        Currency:           pa.Currency,
+        SourceAccount:      pa.SourceAccount,
+        DestinationAccount: pa.DestinationAccount,
+    }
+}
@@ -0,0 +1,68 @@
+package outbound
+
+import (
+    "context"
+    "encoding/json"
+    "fmt"
+    "time"
+
+    "github.com/google/uuid"
+    "github.com/walletera/dinopay-gateway/internal/domain/events/walletera/gateway"
+    "github.com/walletera/eventskit/events"
+    "github.com/walletera/werrors"
+)
+
+var _ events.Event[EventsHandler] = PaymentCancellationFailed{}
+
+// PaymentCancellationFailed is recorded when a cancellation requested by the
+// Payments service can't be honoured, e.g. because DinoPay already processed the
+// payment. DinopayPaymentStatus is the status reported back to the Payments API,
+// it's empty when the outcome of the payment on DinoPay is unknown.
+type PaymentCancellationFailed struct {
+    Id                   uuid.UUID `json:"id,omitempty"`
+    PaymentId            uuid.UUID `json:"withdrawal_id,omitempty"`
+    DinopayPaymentId     uuid.UUID `json:"dinopay_payment_id,omitempty"`
+    DinopayPaymentStatus string    `json:"dinopay_payment_status,omitempty"`
+    Reason               string    `json:"reason,omitempty"`
+    EventCreatedAt       int64     `json:"created_at,omitempty"`
+}
+
+func (pcf PaymentCancellationFailed) ID() string {
+    return fmt.Sprintf("%s-%s", pcf.Type(), pcf.Id)
+}
+
+func (pcf PaymentCancellationFailed) Type() string {
+    return "OutboundPaymentCancellationFailed"
+}
+
+func (pcf PaymentCancellationFailed) DataContentType() string {
+    return "application/json"
+}
+
+func (pcf PaymentCancellationFailed) CorrelationID() string {
+    panic("not implemented yet")
+}
+
+func (pcf PaymentCancellationFailed) AggregateVersion() uint64 {
+    return 0
+}
+
+func (pcf PaymentCancellationFailed) CreatedAt() time.Time {
+    return time.UnixMilli(pcf.EventCreatedAt)
+}
+
+func (pcf PaymentCancellationFailed) Accept(ctx context.Context, handler EventsHandler) werrors.WError {
+    return handler.HandleOutboundPaymentCancellationFailed(ctx, pcf)
+}
+
+func (pcf PaymentCancellationFailed) Serialize() ([]byte, error) {
+    data, err := json.Marshal(pcf)
+    if err != nil {
+        return nil, fmt.Errorf("failed serializing OutboundPaymentCancellationFailed event: %w", err)
+    }
+    envelope := gateway.EventEnvelope{
+        Type: "OutboundPaymentCancellationFailed",
+        Data: data,
+    }
+    return json.Marshal(envelope)
+}
@@ -0,0 +1,68 @@
+package outbound
+
+import (
+    "context"
+    "encoding/json"
+    "fmt"
+    "time"
+
+    "github.com/google/uuid"
+    "github.com/walletera/dinopay-gateway/internal/domain/events/walletera/gateway"
+    "github.com/walletera/eventskit/events"
+    "github.com/walletera/werrors"
+)
+
+var _ events.Event[EventsHandler] = PaymentCancelled{}
+
+// PaymentCancelled is recorded when a cancellation requested by the Payments
+// service succeeds. When the payment was cancelled on DinoPay the event lives in
+// the stream of the DinoPay payment. When the payment hadn't been submitted yet
+// DinopayPaymentId is nil and the event lives in the stream named after the
+// Walletera payment id, blocking its submission.
+type PaymentCancelled struct {
+    Id                   uuid.UUID `json:"id,omitempty"`
+    PaymentId            uuid.UUID `json:"withdrawal_id,omitempty"`
+    DinopayPaymentId     uuid.UUID `json:"dinopay_payment_id,omitempty"`
+    DinopayPaymentStatus string    `json:"dinopay_payment_status,omitempty"`
+    EventCreatedAt       int64     `json:"created_at,omitempty"`
+}
+
+func (pc PaymentCancelled) ID() string {
+    return fmt.Sprintf("%s-%s", pc.Type(), pc.Id)
+}
+
+func (pc PaymentCancelled) Type() string {
+    return "OutboundPaymentCancelled"
+}
+
+func (pc PaymentCancelled) DataContentType() string {
+    return "application/json"
+}
+
+func (pc PaymentCancelled) CorrelationID() string {
+    panic("not implemented yet")
+}
+
+func (pc PaymentCancelled) AggregateVersion() uint64 {
+    return 0
+}
+
+func (pc PaymentCancelled) CreatedAt() time.Time {
+    return time.UnixMilli(pc.EventCreatedAt)
+}
+
+func (pc PaymentCancelled) Accept(ctx context.Context, handler EventsHandler) werrors.WError {
+    return handler.HandleOutboundPaymentCancelled(ctx, pc)
+}
+
+func (pc PaymentCancelled) Serialize() ([]byte, error) {
+    data, err := json.Marshal(pc)
+    if err != nil {
+        return nil, fmt.Errorf("failed serializing OutboundPaymentCancelled event: %w", err)
+    }
+    envelope := gateway.EventEnvelope{
+        Type: "OutboundPaymentCancelled",
+        Data: data,
+    }
+    return json.Marshal(envelope)
+}
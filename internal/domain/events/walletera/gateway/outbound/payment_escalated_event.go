@@ -0,0 +1,64 @@
+package outbound
+
+import (
+    "context"
+    "encoding/json"
+    "fmt"
+    "time"
+
+    "github.com/google/uuid"
+    "github.com/walletera/dinopay-gateway/internal/domain/events/walletera/gateway"
+    "github.com/walletera/eventskit/events"
+    "github.com/walletera/werrors"
+)
+
+var _ events.Event[EventsHandler] = PaymentEscalated{}
+
+// PaymentEscalated is recorded when the outcome of the payment is still unknown
+// after the max number of resolution attempts. Operators must resolve it.
+type PaymentEscalated struct {
+    Id             uuid.UUID `json:"id,omitempty"`
+    PaymentId      uuid.UUID `json:"withdrawal_id,omitempty"`
+    Attempts       int       `json:"attempts"`
+    EventCreatedAt int64     `json:"created_at,omitempty"`
+}
+
+func (pe PaymentEscalated) ID() string {
+    return fmt.Sprintf("%s-%s", pe.Type(), pe.Id)
+}
+
+func (pe PaymentEscalated) Type() string {
+    return "OutboundPaymentEscalated"
+}
+
+func (pe PaymentEscalated) DataContentType() string {
+    return "application/json"
+}
+
+func (pe PaymentEscalated) CorrelationID() string {
+    panic("not implemented yet")
+}
+
+func (pe PaymentEscalated) AggregateVersion() uint64 {
+    return 0
+}
+
+func (pe PaymentEscalated) CreatedAt() time.Time {
+    return time.UnixMilli(pe.EventCreatedAt)
+}
+
+func (pe PaymentEscalated) Accept(ctx context.Context, handler EventsHandler) werrors.WError {
+    return handler.HandleOutboundPaymentEscalated(ctx, pe)
+}
+
+func (pe PaymentEscalated) Serialize() ([]byte, error) {
+    data, err := json.Marshal(pe)
+    if err != nil {
+        return nil, fmt.Errorf("failed serializing OutboundPaymentEscalated event: %w", err)
+    }
+    envelope := gateway.EventEnvelope{
+        Type: "OutboundPaymentEscalated",
+        Data: data,
+    }
+    return json.Marshal(envelope)
+}
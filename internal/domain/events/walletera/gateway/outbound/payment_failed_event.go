@@ -0,0 +1,65 @@
+package outbound
+
+import (
+    "context"
+    "encoding/json"
+    "fmt"
+    "time"
+
+    "github.com/google/uuid"
+    "github.com/walletera/dinopay-gateway/internal/domain/events/walletera/gateway"
+    "github.com/walletera/eventskit/events"
+    "github.com/walletera/werrors"
+)
+
+var _ events.Event[EventsHandler] = PaymentFailed{}
+
+// PaymentFailed is recorded when DinoPay rejects an outbound payment.
+// No DinoPay payment exists, so the stream is named after the Walletera payment id.
+type PaymentFailed struct {
+    Id             uuid.UUID     `json:"id,omitempty"`
+    PaymentId      uuid.UUID     `json:"withdrawal_id,omitempty"`
+    Reason         FailureReason `json:"reason"`
+    Details        string        `json:"details,omitempty"`
+    EventCreatedAt int64         `json:"created_at,omitempty"`
+}
+
+func (pf PaymentFailed) ID() string {
+    return fmt.Sprintf("%s-%s", pf.Type(), pf.Id)
+}
+
+func (pf PaymentFailed) Type() string {
+    return "OutboundPaymentFailed"
+}
+
+func (pf PaymentFailed) DataContentType() string {
+    return "application/json"
+}
+
+func (pf PaymentFailed) CorrelationID() string {
+    panic("not implemented yet")
+}
+
+func (pf PaymentFailed) AggregateVersion() uint64 {
+    return 0
+}
+
+func (pf PaymentFailed) CreatedAt() time.Time {
+    return time.UnixMilli(pf.EventCreatedAt)
+}
+
+func (pf PaymentFailed) Accept(ctx context.Context, handler EventsHandler) werrors.WError {
+    return handler.HandleOutboundPaymentFailed(ctx, pf)
+}
+
+func (pf PaymentFailed) Serialize() ([]byte, error) {
+    data, err := json.Marshal(pf)
+    if err != nil {
+        return nil, fmt.Errorf("failed serializing OutboundPaymentFailed event: %w", err)
+    }
+    envelope := gateway.EventEnvelope{
+        Type: "OutboundPaymentFailed",
+        Data: data,
+    }
+    return json.Marshal(envelope)
+}
@@ -0,0 +1,65 @@
+package outbound
+
+import (
+    "context"
+    "encoding/json"
+    "fmt"
+    "time"
+
+    "github.com/google/uuid"
+    "github.com/walletera/dinopay-gateway/internal/domain/events/walletera/gateway"
+    "github.com/walletera/eventskit/events"
+    "github.com/walletera/werrors"
+)
+
+var _ events.Event[EventsHandler] = PaymentFirstApproved{}
+
+// PaymentFirstApproved is recorded when a first operator approves a payment
+// awaiting approval. The payment keeps awaiting the approval of a second,
+// different operator.
+type PaymentFirstApproved struct {
+    Id             uuid.UUID `json:"id,omitempty"`
+    PaymentId      uuid.UUID `json:"withdrawal_id,omitempty"`
+    ApprovedBy     string    `json:"approved_by"`
+    EventCreatedAt int64     `json:"created_at,omitempty"`
+}
+
+func (pf PaymentFirstApproved) ID() string {
+    return fmt.Sprintf("%s-%s", pf.Type(), pf.Id)
+}
+
+func (pf PaymentFirstApproved) Type() string {
+    return "OutboundPaymentFirstApproved"
+}
+
+func (pf PaymentFirstApproved) DataContentType() string {
+    return "application/json"
+}
+
+func (pf PaymentFirstApproved) CorrelationID() string {
+    panic("not implemented yet")
+}
+
+func (pf PaymentFirstApproved) AggregateVersion() uint64 {
+    return 0
+}
+
+func (pf PaymentFirstApproved) CreatedAt() time.Time {
+    return time.UnixMilli(pf.EventCreatedAt)
+}
+
+func (pf PaymentFirstApproved) Accept(ctx context.Context, handler EventsHandler) werrors.WError {
+    return handler.HandleOutboundPaymentFirstApproved(ctx, pf)
+}
+
+func (pf PaymentFirstApproved) Serialize() ([]byte, error) {
+    data, err := json.Marshal(pf)
+    if err != nil {
+        return nil, fmt.Errorf("failed serializing OutboundPaymentFirstApproved event: %w", err)
+    }
+    envelope := gateway.EventEnvelope{
+        Type: "OutboundPaymentFirstApproved",
+        Data: data,
+    }
+    return json.Marshal(envelope)
+}
@@ -0,0 +1,83 @@
+package outbound
+
+import (
+    "context"
+    "encoding/json"
+    "fmt"
+    "time"
+
+    "github.com/google/uuid"
+    "github.com/shopspring/decimal"
+    "github.com/walletera/dinopay-gateway/internal/domain/events/walletera/gateway"
+    "github.com/walletera/dinopay-gateway/internal/domain/mapping"
+    "github.com/walletera/eventskit/events"
+    "github.com/walletera/werrors"
+)
+
+var _ events.Event[EventsHandler] = PaymentHeld{}
+
+// PaymentHeld is recorded instead of submitting the payment to DinoPay when
+// it must be reviewed by an operator first, e.g. because it breached a velocity
+// limit. It keeps everything needed to submit the payment once released.
+type PaymentHeld struct {
+    Id                 uuid.UUID          `json:"id,omitempty"`
+    PaymentId          uuid.UUID          `json:"withdrawal_id,omitempty"`
+    CustomerId         uuid.UUID          `json:"customer_id,omitempty"`
+    Amount             decimal.Decimal    `json:"amount"`
+    Currency           string             `json:"currency"`
+    SourceAccount      mapping.Account    `json:"source_account"`
+    DestinationAccount mapping.Account    `json:"destination_account"`
+    Reason             gateway.HoldReason `json:"reason"`
+    Details            string             `json:"details,omitempty"`
+    EventCreatedAt     int64              `json:"created_at,omitempty"`
+}
+
+func (ph PaymentHeld) ID() string {
+    return fmt.Sprintf("%s-%s", ph.Type(), ph.Id)
+}
+
+func (ph PaymentHeld) Type() string {
+    return "OutboundPaymentHeld"
+}
+
+func (ph PaymentHeld) DataContentType() string {
+    return "application/json"
+}
+
+func (ph PaymentHeld) CorrelationID() string {
+    panic("not implemented yet")
+}
+
+func (ph PaymentHeld) AggregateVersion() uint64 {
+    return 0
+}
+
+func (ph PaymentHeld) CreatedAt() time.Time {
+    return time.UnixMilli(ph.EventCreatedAt)
+}
+
+func (ph PaymentHeld) Accept(ctx context.Context, handler EventsHandler) werrors.WError {
+    return handler.HandleOutboundPaymentHeld(ctx, ph)
+}
+
+func (ph PaymentHeld) Serialize() ([]byte, error) {
+    data, err := json.Marshal(ph)
+    if err != nil {
+        return nil, fmt.Errorf("failed serializing OutboundPaymentHeld event: %w", err)
+    }
+    envelope := gateway.EventEnvelope{
+        Type: "OutboundPaymentHeld",
+        Data: data,
+    }
+    return json.Marshal(envelope)
+}
+
+// DinopayPayment returns the DinoPay payment whose creation outcome is unknown
+func (ph PaymentHeld) DinopayPayment() mapping.DinopayPayment {
+    return mapping.DinopayPayment{
+        Amount:             ph.Amount,
+        Currency:           ph.Currency,
+        SourceAccount:      ph.SourceAccount,
+        DestinationAccount: ph.DestinationAccount,
+    }
+}
@@ -0,0 +1,70 @@
+package outbound
+
+import (
+    "context"
+    "encoding/json"
+    "fmt"
+    "time"
+
+    "github.com/google/uuid"
+    "github.com/shopspring/decimal"
+    "github.com/walletera/dinopay-gateway/internal/domain/events/walletera/gateway"
+    "github.com/walletera/dinopay-gateway/internal/domain/mapping"
+    "github.com/walletera/eventskit/events"
+    "github.com/walletera/werrors"
+)
+
+var _ events.Event[EventsHandler] = PaymentNotified{}
+
+// PaymentNotified records the PaymentCreated webhook DinoPay sent for
+// a payout the gateway created, with the payment as DinoPay reported it.
+type PaymentNotified struct {
+    Id                 uuid.UUID       `json:"id,omitempty"`
+    PaymentId          uuid.UUID       `json:"withdrawal_id,omitempty"`
+    DinopayPaymentId   uuid.UUID       `json:"dinopay_payment_id,omitempty"`
+    Amount             decimal.Decimal `json:"amount"`
+    Currency           string          `json:"currency"`
+    SourceAccount      mapping.Account `json:"source_account"`
+    DestinationAccount mapping.Account `json:"destination_account"`
+    EventCreatedAt     int64           `json:"created_at,omitempty"`
+}
+
+func (pn PaymentNotified) ID() string {
+    return fmt.Sprintf("%s-%s", pn.Type(), pn.Id)
+}
+
+func (pn PaymentNotified) Type() string {
+    return "OutboundPaymentNotified"
+}
+
+func (pn PaymentNotified) DataContentType() string {
+    return "application/json"
+}
+
+func (pn PaymentNotified) CorrelationID() string {
+    panic("not implemented yet")
+}
+
+func (pn PaymentNotified) AggregateVersion() uint64 {
+    return 0
+}
+
+func (pn PaymentNotified) CreatedAt() time.Time {
+    return time.UnixMilli(pn.EventCreatedAt)
+}
+
+func (pn PaymentNotified) Accept(ctx context.Context, handler EventsHandler) werrors.WError {
+    return handler.HandleOutboundPaymentNotified(ctx, pn)
+}
+
+func (pn PaymentNotified) Serialize() ([]byte, error) {
+    data, err := json.Marshal(pn)
+    if err != nil {
+        return nil, fmt.Errorf("failed serializing OutboundPaymentNotified event: %w", err)
+    }
+    envelope := gateway.EventEnvelope{
+        Type: "OutboundPaymentNotified",
+        Data: data,
+    }
+    return json.Marshal(envelope)
+}
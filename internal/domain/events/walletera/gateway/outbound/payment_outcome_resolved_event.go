@@ -0,0 +1,66 @@
+package outbound
+
+import (
+    "context"
+    "encoding/json"
+    "fmt"
+    "time"
+
+    "github.com/google/uuid"
+    "github.com/walletera/dinopay-gateway/internal/domain/events/walletera/gateway"
+    "github.com/walletera/eventskit/events"
+    "github.com/walletera/werrors"
+)
+
+var _ events.Event[EventsHandler] = PaymentOutcomeResolved{}
+
+// PaymentOutcomeResolved is recorded once the payment with an unknown outcome
+// is known to exist on DinoPay because it was found (adopted). Older events
+// may also record a payment that was created again (resubmitted).
+type PaymentOutcomeResolved struct {
+    Id               uuid.UUID  `json:"id,omitempty"`
+    PaymentId        uuid.UUID  `json:"withdrawal_id,omitempty"`
+    DinopayPaymentId uuid.UUID  `json:"dinopay_payment_id,omitempty"`
+    Resolution       Resolution `json:"resolution"`
+    EventCreatedAt   int64      `json:"created_at,omitempty"`
+}
+
+func (por PaymentOutcomeResolved) ID() string {
+    return fmt.Sprintf("%s-%s", por.Type(), por.Id)
+}
+
+func (por PaymentOutcomeResolved) Type() string {
+    return "OutboundPaymentOutcomeResolved"
+}
+
+func (por PaymentOutcomeResolved) DataContentType() string {
+    return "application/json"
+}
+
+func (por PaymentOutcomeResolved) CorrelationID() string {
+    panic("not implemented yet")
+}
+
+func (por PaymentOutcomeResolved) AggregateVersion() uint64 {
+    return 0
+}
+
+func (por PaymentOutcomeResolved) CreatedAt() time.Time {
+    return time.UnixMilli(por.EventCreatedAt)
+}
+
+func (por PaymentOutcomeResolved) Accept(ctx context.Context, handler EventsHandler) werrors.WError {
+    return handler.HandleOutboundPaymentOutcomeResolved(ctx, por)
+}
+
+func (por PaymentOutcomeResolved) Serialize() ([]byte, error) {
+    data, err := json.Marshal(por)
+    if err != nil {
+        return nil, fmt.Errorf("failed serializing OutboundPaymentOutcomeResolved event: %w", err)
+    }
+    envelope := gateway.EventEnvelope{
+        Type: "OutboundPaymentOutcomeResolved",
+        Data: data,
+    }
+    return json.Marshal(envelope)
+}
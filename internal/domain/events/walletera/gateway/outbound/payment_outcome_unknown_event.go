@@ -0,0 +1,82 @@
+package outbound
+
+import (
+    "context"
+    "encoding/json"
+    "fmt"
+    "time"
+
+    "github.com/google/uuid"
+    "github.com/shopspring/decimal"
+    "github.com/walletera/dinopay-gateway/internal/domain/events/walletera/gateway"
+    "github.com/walletera/dinopay-gateway/internal/domain/mapping"
+    "github.com/walletera/eventskit/events"
+    "github.com/walletera/werrors"
+)
+
+var _ events.Event[EventsHandler] = PaymentOutcomeUnknown{}
+
+// PaymentOutcomeUnknown is recorded when the call creating the payment on DinoPay
+// failed without a response (e.g. a timeout), so the payment may or may not exist
+// on DinoPay. It keeps everything needed to look the payment up or to resubmit it.
+type PaymentOutcomeUnknown struct {
+    Id                    uuid.UUID       `json:"id,omitempty"`
+    PaymentId             uuid.UUID       `json:"withdrawal_id,omitempty"`
+    Amount                decimal.Decimal `json:"amount"`
+    Currency              string          `json:"currency"`
+    SourceAccount         mapping.Account `json:"source_account"`
+    DestinationAccount    mapping.Account `json:"destination_account"`
+    CustomerTransactionId string          `json:"customer_transaction_id"`
+    Error                 string          `json:"error,omitempty"`
+    EventCreatedAt        int64           `json:"created_at,omitempty"`
+}
+
+func (pu PaymentOutcomeUnknown) ID() string {
+    return fmt.Sprintf("%s-%s", pu.Type(), pu.Id)
+}
+
+func (pu PaymentOutcomeUnknown) Type() string {
+    return "OutboundPaymentOutcomeUnknown"
+}
+
+func (pu PaymentOutcomeUnknown) DataContentType() string {
+    return "application/json"
+}
+
+func (pu PaymentOutcomeUnknown) CorrelationID() string {
+    panic("not implemented yet")
+}
+
+func (pu PaymentOutcomeUnknown) AggregateVersion() uint64 {
+    return 0
+}
+
+func (pu PaymentOutcomeUnknown) CreatedAt() time.Time {
+    return time.UnixMilli(pu.EventCreatedAt)
+}
+
+func (pu PaymentOutcomeUnknown) Accept(ctx context.Context, handler EventsHandler) werrors.WError {
+    return handler.HandleOutboundPaymentOutcomeUnknown(ctx, pu)
+}
+
+func (pu PaymentOutcomeUnknown) Serialize() ([]byte, error) {
+    data, err := json.Marshal(pu)
+    if err != nil {
+        return nil, fmt.Errorf("failed serializing OutboundPaymentOutcomeUnknown event: %w", err)
+    }
+    envelope := gateway.EventEnvelope{
+        Type: "OutboundPaymentOutcomeUnknown",
+        Data: data,
+    }
+    return json.Marshal(envelope)
+}
+
+// DinopayPayment returns the DinoPay payment whose creation outcome is unknown
+func (pu PaymentOutcomeUnknown) DinopayPayment() mapping.DinopayPayment {
+    return mapping.DinopayPayment{
+        Amount:             pu.Amount,
+        Currency:           pu.Currency,
+        SourceAccount:      pu.SourceAccount,
+        DestinationAccount: pu.DestinationAccount,
+    }
+}
@@ -0,0 +1,64 @@
+package outbound
+
+import (
+    "context"
+    "encoding/json"
+    "fmt"
+    "time"
+
+    "github.com/google/uuid"
+    "github.com/walletera/dinopay-gateway/internal/domain/events/walletera/gateway"
+    "github.com/walletera/eventskit/events"
+    "github.com/walletera/werrors"
+)
+
+var _ events.Event[EventsHandler] = PaymentReleased{}
+
+// PaymentReleased is recorded when an operator releases a held payment,
+// right before it's submitted to DinoPay.
+type PaymentReleased struct {
+    Id             uuid.UUID `json:"id,omitempty"`
+    PaymentId      uuid.UUID `json:"withdrawal_id,omitempty"`
+    ReleasedBy     string    `json:"released_by"`
+    EventCreatedAt int64     `json:"created_at,omitempty"`
+}
+
+func (pr PaymentReleased) ID() string {
+    return fmt.Sprintf("%s-%s", pr.Type(), pr.Id)
+}
+
+func (pr PaymentReleased) Type() string {
+    return "OutboundPaymentReleased"
+}
+
+func (pr PaymentReleased) DataContentType() string {
+    return "application/json"
+}
+
+func (pr PaymentReleased) CorrelationID() string {
+    panic("not implemented yet")
+}
+
+func (pr PaymentReleased) AggregateVersion() uint64 {
+    return 0
+}
+
+func (pr PaymentReleased) CreatedAt() time.Time {
+    return time.UnixMilli(pr.EventCreatedAt)
+}
+
+func (pr PaymentReleased) Accept(ctx context.Context, handler EventsHandler) werrors.WError {
+    return handler.HandleOutboundPaymentReleased(ctx, pr)
+}
+
+func (pr PaymentReleased) Serialize() ([]byte, error) {
+    data, err := json.Marshal(pr)
+    if err != nil {
+        return nil, fmt.Errorf("failed serializing OutboundPaymentReleased event: %w", err)
+    }
+    envelope := gateway.EventEnvelope{
+        Type: "OutboundPaymentReleased",
+        Data: data,
+    }
+    return json.Marshal(envelope)
+}
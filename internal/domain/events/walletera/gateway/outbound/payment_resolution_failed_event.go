@@ -0,0 +1,65 @@
+package outbound
+
+import (
+    "context"
+    "encoding/json"
+    "fmt"
+    "time"
+
+    "github.com/google/uuid"
+    "github.com/walletera/dinopay-gateway/internal/domain/events/walletera/gateway"
+    "github.com/walletera/eventskit/events"
+    "github.com/walletera/werrors"
+)
+
+var _ events.Event[EventsHandler] = PaymentResolutionFailed{}
+
+// PaymentResolutionFailed is recorded every time the UnknownOutcomeResolver
+// couldn't find the payment on DinoPay.
+type PaymentResolutionFailed struct {
+    Id             uuid.UUID `json:"id,omitempty"`
+    PaymentId      uuid.UUID `json:"withdrawal_id,omitempty"`
+    Attempt        int       `json:"attempt"`
+    Error          string    `json:"error,omitempty"`
+    EventCreatedAt int64     `json:"created_at,omitempty"`
+}
+
+func (prf PaymentResolutionFailed) ID() string {
+    return fmt.Sprintf("%s-%s", prf.Type(), prf.Id)
+}
+
+func (prf PaymentResolutionFailed) Type() string {
+    return "OutboundPaymentResolutionFailed"
+}
+
+func (prf PaymentResolutionFailed) DataContentType() string {
+    return "application/json"
+}
+
+func (prf PaymentResolutionFailed) CorrelationID() string {
+    panic("not implemented yet")
+}
+
+func (prf PaymentResolutionFailed) AggregateVersion() uint64 {
+    return 0
+}
+
+func (prf PaymentResolutionFailed) CreatedAt() time.Time {
+    return time.UnixMilli(prf.EventCreatedAt)
+}
+
+func (prf PaymentResolutionFailed) Accept(ctx context.Context, handler EventsHandler) werrors.WError {
+    return handler.HandleOutboundPaymentResolutionFailed(ctx, prf)
+}
+
+func (prf PaymentResolutionFailed) Serialize() ([]byte, error) {
+    data, err := json.Marshal(prf)
+    if err != nil {
+        return nil, fmt.Errorf("failed serializing OutboundPaymentResolutionFailed event: %w", err)
+    }
+    envelope := gateway.EventEnvelope{
+        Type: "OutboundPaymentResolutionFailed",
+        Data: data,
+    }
+    return json.Marshal(envelope)
+}
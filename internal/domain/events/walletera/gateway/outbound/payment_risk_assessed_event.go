@@ -0,0 +1,68 @@
+package outbound
+
+import (
+    "context"
+    "encoding/json"
+    "fmt"
+    "time"
+
+    "github.com/google/uuid"
+    "github.com/walletera/dinopay-gateway/internal/domain/events/walletera/gateway"
+    riskport "github.com/walletera/dinopay-gateway/internal/domain/ports/output/risk"
+    "github.com/walletera/eventskit/events"
+    "github.com/walletera/werrors"
+)
+
+var _ events.Event[EventsHandler] = PaymentRiskAssessed{}
+
+// PaymentRiskAssessed records the risk decision on a payment before it's
+// acted upon: an allowed payment is submitted to DinoPay, a reviewed one is
+// held and a blocked one is failed.
+type PaymentRiskAssessed struct {
+    Id             uuid.UUID        `json:"id,omitempty"`
+    PaymentId      uuid.UUID        `json:"withdrawal_id,omitempty"`
+    CustomerId     uuid.UUID        `json:"customer_id,omitempty"`
+    Outcome        riskport.Outcome `json:"outcome"`
+    Reasons        []string         `json:"reasons,omitempty"`
+    EventCreatedAt int64            `json:"created_at,omitempty"`
+}
+
+func (ra PaymentRiskAssessed) ID() string {
+    return fmt.Sprintf("%s-%s", ra.Type(), ra.Id)
+}
+
+func (ra PaymentRiskAssessed) Type() string {
+    return "OutboundPaymentRiskAssessed"
+}
+
+func (ra PaymentRiskAssessed) DataContentType() string {
+    return "application/json"
+}
+
+func (ra PaymentRiskAssessed) CorrelationID() string {
+    panic("not implemented yet")
+}
+
+func (ra PaymentRiskAssessed) AggregateVersion() uint64 {
+    return 0
+}
+
+func (ra PaymentRiskAssessed) CreatedAt() time.Time {
+    return time.UnixMilli(ra.EventCreatedAt)
+}
+
+func (ra PaymentRiskAssessed) Accept(ctx context.Context, handler EventsHandler) werrors.WError {
+    return handler.HandleOutboundPaymentRiskAssessed(ctx, ra)
+}
+
+func (ra PaymentRiskAssessed) Serialize() ([]byte, error) {
+    data, err := json.Marshal(ra)
+    if err != nil {
+        return nil, fmt.Errorf("failed serializing OutboundPaymentRiskAssessed event: %w", err)
+    }
+    envelope := gateway.EventEnvelope{
+        Type: "OutboundPaymentRiskAssessed",
+        Data: data,
+    }
+    return json.Marshal(envelope)
+}
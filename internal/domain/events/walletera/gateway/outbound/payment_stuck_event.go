@@ -0,0 +1,66 @@
+package outbound
+
+import (
+    "context"
+    "encoding/json"
+    "fmt"
+    "time"
+
+    "github.com/google/uuid"
+    "github.com/walletera/dinopay-gateway/internal/domain/events/walletera/gateway"
+    "github.com/walletera/eventskit/events"
+    "github.com/walletera/werrors"
+)
+
+var _ events.Event[EventsHandler] = PaymentStuck{}
+
+// PaymentStuck is the alert recorded by the PendingSweeper when DinoPay
+// still reports the payment as pending after the pending SLA.
+type PaymentStuck struct {
+    Id                   uuid.UUID `json:"id,omitempty"`
+    PaymentId            uuid.UUID `json:"withdrawal_id,omitempty"`
+    DinopayPaymentId     uuid.UUID `json:"dinopay_payment_id,omitempty"`
+    DinopayPaymentStatus string    `json:"dinopay_payment_status,omitempty"`
+    PendingSince         int64     `json:"pending_since,omitempty"`
+    EventCreatedAt       int64     `json:"created_at,omitempty"`
+}
+
+func (ps PaymentStuck) ID() string {
+    return fmt.Sprintf("%s-%s", ps.Type(), ps.Id)
+}
+
+func (ps PaymentStuck) Type() string {
+    return "OutboundPaymentStuck"
+}
+
+func (ps PaymentStuck) DataContentType() string {
+    return "application/json"
+}
+
+func (ps PaymentStuck) CorrelationID() string {
+    panic("not implemented yet")
+}
+
+func (ps PaymentStuck) AggregateVersion() uint64 {
+    return 0
+}
+
+func (ps PaymentStuck) CreatedAt() time.Time {
+    return time.UnixMilli(ps.EventCreatedAt)
+}
+
+func (ps PaymentStuck) Accept(ctx context.Context, handler EventsHandler) werrors.WError {
+    return handler.HandleOutboundPaymentStuck(ctx, ps)
+}
+
+func (ps PaymentStuck) Serialize() ([]byte, error) {
+    data, err := json.Marshal(ps)
+    if err != nil {
+        return nil, fmt.Errorf("failed serializing OutboundPaymentStuck event: %w", err)
+    }
+    envelope := gateway.EventEnvelope{
+        Type: "OutboundPaymentStuck",
+        Data: data,
+    }
+    return json.Marshal(envelope)
+}
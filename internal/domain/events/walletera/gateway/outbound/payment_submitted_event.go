@@ -0,0 +1,66 @@
+package outbound
+
+import (
+    "context"
+    "encoding/json"
+    "fmt"
+    "time"
+
+    "github.com/google/uuid"
+    "github.com/walletera/dinopay-gateway/internal/domain/events/walletera/gateway"
+    "github.com/walletera/eventskit/events"
+    "github.com/walletera/werrors"
+)
+
+var _ events.Event[EventsHandler] = PaymentSubmitted{}
+
+// PaymentSubmitted records, in the stream named after the Walletera payment id,
+// the DinoPay payment created by the submission. The OutboundPaymentCreated
+// event in the stream of the DinoPay payment is appended after it.
+type PaymentSubmitted struct {
+    Id                   uuid.UUID `json:"id,omitempty"`
+    PaymentId            uuid.UUID `json:"withdrawal_id,omitempty"`
+    DinopayPaymentId     uuid.UUID `json:"dinopay_payment_id,omitempty"`
+    DinopayPaymentStatus string    `json:"dinopay_payment_status,omitempty"`
+    EventCreatedAt       int64     `json:"created_at,omitempty"`
+}
+
+func (ps PaymentSubmitted) ID() string {
+    return fmt.Sprintf("%s-%s", ps.Type(), ps.Id)
+}
+
+func (ps PaymentSubmitted) Type() string {
+    return "OutboundPaymentSubmitted"
+}
+
+func (ps PaymentSubmitted) DataContentType() string {
+    return "application/json"
+}
+
+func (ps PaymentSubmitted) CorrelationID() string {
+    panic("not implemented yet")
+}
+
+func (ps PaymentSubmitted) AggregateVersion() uint64 {
+    return 0
+}
+
+func (ps PaymentSubmitted) CreatedAt() time.Time {
+    return time.UnixMilli(ps.EventCreatedAt)
+}
+
+func (ps PaymentSubmitted) Accept(ctx context.Context, handler EventsHandler) werrors.WError {
+    return handler.HandleOutboundPaymentSubmitted(ctx, ps)
+}
+
+func (ps PaymentSubmitted) Serialize() ([]byte, error) {
+    data, err := json.Marshal(ps)
+    if err != nil {
+        return nil, fmt.Errorf("failed serializing OutboundPaymentSubmitted event: %w", err)
+    }
+    envelope := gateway.EventEnvelope{
+        Type: "OutboundPaymentSubmitted",
+        Data: data,
+    }
+    return json.Marshal(envelope)
+}
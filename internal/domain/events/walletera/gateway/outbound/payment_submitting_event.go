@@ -0,0 +1,65 @@
+package outbound
+
+import (
+    "context"
+    "encoding/json"
+    "fmt"
+    "time"
+
+    "github.com/google/uuid"
+    "github.com/walletera/dinopay-gateway/internal/domain/events/walletera/gateway"
+    "github.com/walletera/eventskit/events"
+    "github.com/walletera/werrors"
+)
+
+var _ events.Event[EventsHandler] = PaymentSubmitting{}
+
+// PaymentSubmitting is appended to the stream named after the Walletera payment id
+// right before the payment is created on DinoPay. From then on the payment can't
+// be cancelled without cancelling it on DinoPay, so the cancellations appended
+// with the stream version read before this event fail.
+type PaymentSubmitting struct {
+    Id             uuid.UUID `json:"id,omitempty"`
+    PaymentId      uuid.UUID `json:"withdrawal_id,omitempty"`
+    EventCreatedAt int64     `json:"created_at,omitempty"`
+}
+
+func (ps PaymentSubmitting) ID() string {
+    return fmt.Sprintf("%s-%s", ps.Type(), ps.Id)
+}
+
+func (ps PaymentSubmitting) Type() string {
+    return "OutboundPaymentSubmitting"
+}
+
+func (ps PaymentSubmitting) DataContentType() string {
+    return "application/json"
+}
+
+func (ps PaymentSubmitting) CorrelationID() string {
+    panic("not implemented yet")
+}
+
+func (ps PaymentSubmitting) AggregateVersion() uint64 {
+    return 0
+}
+
+func (ps PaymentSubmitting) CreatedAt() time.Time {
+    return time.UnixMilli(ps.EventCreatedAt)
+}
+
+func (ps PaymentSubmitting) Accept(ctx context.Context, handler EventsHandler) werrors.WError {
+    return handler.HandleOutboundPaymentSubmitting(ctx, ps)
+}
+
+func (ps PaymentSubmitting) Serialize() ([]byte, error) {
+    data, err := json.Marshal(ps)
+    if err != nil {
+        return nil, fmt.Errorf("failed serializing OutboundPaymentSubmitting event: %w", err)
+    }
+    envelope := gateway.EventEnvelope{
+        Type: "OutboundPaymentSubmitting",
+        Data: data,
+    }
+    return json.Marshal(envelope)
+}
@@ -4,6 +4,7 @@ import (
     "context"
     "fmt"
 
+    "github.com/walletera/dinopay-gateway/internal/domain/events/walletera/gateway"
     "github.com/walletera/eventskit/eventsourcing"
     paymentsApi "github.com/walletera/payments-types/privateapi"
     "github.com/walletera/werrors"
@@ -24,6 +25,7 @@ type PaymentUpdatedHandler struct {
     deserializer   *EventsDeserializer
 
     outboundPaymentCreated *PaymentCreated
+    cancelled              bool
 }
 
 func NewOutboundPaymentUpdatedHandler(db eventsourcing.DB, client *paymentsApi.Client) *PaymentUpdatedHandler {
@@ -35,7 +37,7 @@ func NewOutboundPaymentUpdatedHandler(db eventsourcing.DB, client *paymentsApi.C
 }
 
 func (h *PaymentUpdatedHandler) Handle(ctx context.Context, outboundPaymentUpdated PaymentUpdated) werrors.WError {
-    streamName := BuildOutboundPaymentStreamName(outboundPaymentUpdated.DinopayPaymentId.String())
+    streamName := gateway.BuildOutboundPaymentStreamName(outboundPaymentUpdated.DinopayPaymentId.String())
     retrievedEvents, werr := h.db.ReadEvents(ctx, streamName)
     if werr != nil {
         return werrors.NewWrappedError(werr)
@@ -58,13 +60,100 @@ func (h *PaymentUpdatedHandler) HandleOutboundPaymentCreated(_ context.Context,
     return nil
 }
 
+func (h *PaymentUpdatedHandler) HandleOutboundPaymentFailed(_ context.Context, _ PaymentFailed) werrors.WError {
+    // A failed payment never reaches DinoPay so it can't be updated
+    return nil
+}
+
+// The unknown outcome events live in the stream named after the Walletera
+// payment id, never in the stream of a DinoPay payment, so they are ignored
+
+func (h *PaymentUpdatedHandler) HandleOutboundPaymentOutcomeUnknown(_ context.Context, _ PaymentOutcomeUnknown) werrors.WError {
+    return nil
+}
+
+func (h *PaymentUpdatedHandler) HandleOutboundPaymentResolutionFailed(_ context.Context, _ PaymentResolutionFailed) werrors.WError {
+    return nil
+}
+
+func (h *PaymentUpdatedHandler) HandleOutboundPaymentOutcomeResolved(_ context.Context, _ PaymentOutcomeResolved) werrors.WError {
+    return nil
+}
+
+func (h *PaymentUpdatedHandler) HandleOutboundPaymentEscalated(_ context.Context, _ PaymentEscalated) werrors.WError {
+    return nil
+}
+
+// HandleOutboundPaymentStuck ignores the alert, it doesn't change the payment status
+func (h *PaymentUpdatedHandler) HandleOutboundPaymentStuck(_ context.Context, _ PaymentStuck) werrors.WError {
+    return nil
+}
+
+// HandleOutboundPaymentCancelled stops reporting the updates that follow the
+// cancellation, the Payments API already has the payment as rejected
+func (h *PaymentUpdatedHandler) HandleOutboundPaymentCancelled(_ context.Context, _ PaymentCancelled) werrors.WError {
+    h.cancelled = true
+    return nil
+}
+
+func (h *PaymentUpdatedHandler) HandleOutboundPaymentCancellationFailed(_ context.Context, _ PaymentCancellationFailed) werrors.WError {
+    return nil
+}
+
 func (h *PaymentUpdatedHandler) HandleOutboundPaymentUpdated(ctx context.Context, outboundPaymentUpdated PaymentUpdated) werrors.WError {
     if h.outboundPaymentCreated == nil {
         return werrors.NewNonRetryableInternalError("missing OutboundPaymentCreated event")
     }
+    if h.cancelled {
+        return nil
+    }
     err := updatePaymentStatus(ctx, h.paymentsClient, h.outboundPaymentCreated.PaymentId, outboundPaymentUpdated.DinopayPaymentId, outboundPaymentUpdated.DinopayPaymentStatus)
     if err != nil {
         return werrors.NewWrappedError(err, "failed handling outbound PaymentCreated event")
     }
     return nil
 }
+
+func (h *PaymentUpdatedHandler) HandleOutboundPaymentHeld(_ context.Context, _ PaymentHeld) werrors.WError {
+    return nil
+}
+
+func (h *PaymentUpdatedHandler) HandleOutboundPaymentReleased(_ context.Context, _ PaymentReleased) werrors.WError {
+    return nil
+}
+
+func (h *PaymentUpdatedHandler) HandleOutboundPaymentAwaitingApproval(_ context.Context, _ PaymentAwaitingApproval) werrors.WError {
+    return nil
+}
+
+func (h *PaymentUpdatedHandler) HandleOutboundPaymentFirstApproved(_ context.Context, _ PaymentFirstApproved) werrors.WError {
+    return nil
+}
+
+func (h *PaymentUpdatedHandler) HandleOutboundPaymentApproved(_ context.Context, _ PaymentApproved) werrors.WError {
+    return nil
+}
+
+func (h *PaymentUpdatedHandler) HandleOutboundPaymentApprovalRejected(_ context.Context, _ PaymentApprovalRejected) werrors.WError {
+    return nil
+}
+
+func (h *PaymentUpdatedHandler) HandleOutboundPaymentRiskAssessed(_ context.Context, _ PaymentRiskAssessed) werrors.WError {
+    return nil
+}
+
+func (h *PaymentUpdatedHandler) HandleOutboundPaymentScreened(_ context.Context, _ PaymentScreened) werrors.WError {
+    return nil
+}
+
+func (h *PaymentUpdatedHandler) HandleOutboundPaymentNotified(_ context.Context, _ PaymentNotified) werrors.WError {
+    return nil
+}
+
+func (h *PaymentUpdatedHandler) HandleOutboundPaymentSubmitting(_ context.Context, _ PaymentSubmitting) werrors.WError {
+    return nil
+}
+
+func (h *PaymentUpdatedHandler) HandleOutboundPaymentSubmitted(_ context.Context, _ PaymentSubmitted) werrors.WError {
+    return nil
+}
@@ -0,0 +1,240 @@
+package outbound
+
+import (
+    "context"
+    "log/slog"
+    "time"
+
+    "github.com/google/uuid"
+    "github.com/walletera/dinopay-gateway/internal/domain/events/walletera/gateway"
+    "github.com/walletera/dinopay-gateway/internal/domain/ports/output/dinopay"
+    "github.com/walletera/dinopay-gateway/pkg/logattr"
+    "github.com/walletera/dinopay-gateway/pkg/wuuid"
+    dinopayapi "github.com/walletera/dinopay/api"
+    "github.com/walletera/eventskit/events"
+    "github.com/walletera/eventskit/eventsourcing"
+    "github.com/walletera/werrors"
+    "go.opentelemetry.io/otel"
+    "go.opentelemetry.io/otel/metric"
+)
+
+const (
+    DefaultSweepInterval = 5 * time.Minute
+    DefaultPendingSLA    = 1 * time.Hour
+
+    stuckPaymentsMetricName = "dinopay_gateway.outbound_payments.stuck"
+)
+
+// Lease makes sure a periodic job runs on a single replica at a time
+type Lease interface {
+    // Acquire returns true when the caller holds the lease until the next call
+    Acquire(ctx context.Context) (bool, werrors.WError)
+}
+
+// PendingSweeper polls DinoPay for the outbound payments that are pending for
+// longer than the pending SLA, in case the webhook reporting the new status was
+// lost. A status change is recorded with OutboundPaymentUpdated, which updates
+// the payment on the Payments API. Otherwise, an OutboundPaymentStuck alert is
+// recorded (at most once per SLA period) and the stuck payments metric is increased.
+type PendingSweeper struct {
+    db                 eventsourcing.DB
+    dinopayClient      dinopay.Client
+    lease              Lease
+    categoryStreamName string
+    interval           time.Duration
+    sla                time.Duration
+    now                func() time.Time
+    stuckPayments      metric.Int64Counter
+    logger             *slog.Logger
+}
+
+type SweeperOpt func(s *PendingSweeper)
+
+// WithSweepInterval sets how often the pending payments are checked
+func WithSweepInterval(interval time.Duration) SweeperOpt {
+    return func(s *PendingSweeper) { s.interval = interval }
+}
+
+// WithPendingSLA sets for how long a payment can be pending before DinoPay is polled
+func WithPendingSLA(sla time.Duration) SweeperOpt {
+    return func(s *PendingSweeper) { s.sla = sla }
+}
+
+// WithSweeperClock replaces time.Now, mostly for tests
+func WithSweeperClock(now func() time.Time) SweeperOpt {
+    return func(s *PendingSweeper) { s.now = now }
+}
+
+// WithSweeperMeter replaces the otel global meter
+func WithSweeperMeter(meter metric.Meter) SweeperOpt {
+    return func(s *PendingSweeper) { s.stuckPayments = newStuckPaymentsCounter(meter) }
+}
+
+func NewPendingSweeper(
+    db eventsourcing.DB,
+    dinopayClient dinopay.Client,
+    lease Lease,
+    categoryStreamName string,
+    logger *slog.Logger,
+    opts ...SweeperOpt,
+) *PendingSweeper {
+    s := &PendingSweeper{
+        db:                 db,
+        dinopayClient:      dinopayClient,
+        lease:              lease,
+        categoryStreamName: categoryStreamName,
+        interval:           DefaultSweepInterval,
+        sla:                DefaultPendingSLA,
+        now:                time.Now,
+        stuckPayments:      newStuckPaymentsCounter(otel.Meter("github.com/walletera/dinopay-gateway")),
+        logger:             logger.With(logattr.Component("outbound.PendingSweeper")),
+    }
+    for _, opt := range opts {
+        opt(s)
+    }
+    return s
+}
+
+// Run sweeps the pending payments every interval until ctx is done
+func (s *PendingSweeper) Run(ctx context.Context) {
+    ticker := time.NewTicker(s.interval)
+    defer ticker.Stop()
+    for {
+        select {
+        case <-ctx.Done():
+            return
+        case <-ticker.C:
+            werr := s.Sweep(ctx)
+            if werr != nil {
+                s.logger.Error("failed sweeping pending outbound payments", logattr.Error(werr.Error()))
+            }
+        }
+    }
+}
+
+// Sweep polls DinoPay for every payment pending longer than the SLA.
+// It does nothing when another replica holds the lease.
+func (s *PendingSweeper) Sweep(ctx context.Context) werrors.WError {
+    acquired, werr := s.lease.Acquire(ctx)
+    if werr != nil {
+        return werrors.NewWrappedError(werr, "failed acquiring pending sweeper lease")
+    }
+    if !acquired {
+        s.logger.Debug("pending sweeper lease is held by another replica")
+        return nil
+    }
+    dinopayPaymentIds, werr := s.listPending(ctx)
+    if werr != nil {
+        return werr
+    }
+    for _, dinopayPaymentId := range dinopayPaymentIds {
+        werr = s.check(ctx, dinopayPaymentId)
+        if werr != nil {
+            s.logger.Warn(
+                "failed checking pending outbound payment",
+                logattr.DinopayPaymentId(dinopayPaymentId.String()),
+                logattr.Error(werr.Error()),
+            )
+        }
+    }
+    return nil
+}
+
+func (s *PendingSweeper) check(ctx context.Context, dinopayPaymentId uuid.UUID) werrors.WError {
+    // the stream is read again to get the version to append with
+    payment, werr := LoadPayment(ctx, s.db, dinopayPaymentId)
+    if werr != nil {
+        return werr
+    }
+    if !s.overdue(payment) {
+        return nil
+    }
+    logger := s.logger.With(
+        logattr.PaymentId(payment.PaymentId.String()),
+        logattr.DinopayPaymentId(dinopayPaymentId.String()),
+    )
+    dinopayPayment, err := s.dinopayClient.GetPayment(ctx, dinopayPaymentId)
+    if err != nil {
+        return werrors.NewRetryableInternalError("failed getting payment from dinopay: " + err.Error())
+    }
+    if dinopayPayment == nil {
+        return werrors.NewResourceNotFoundError("payment " + dinopayPaymentId.String() + " not found on dinopay")
+    }
+    now := s.now()
+    status := string(dinopayPayment.Status.Value)
+    if status != payment.DinopayPaymentStatus {
+        werr = s.append(ctx, payment, PaymentUpdated{
+            Id:                              wuuid.NewUUID(),
+            DinopayPaymentId:                dinopayPaymentId,
+            DinopayPaymentStatus:            status,
+            OutboundPaymentAggregateVersion: payment.Version + 1,
+            EventCreatedAt:                  now.UnixMilli(),
+        })
+        if werr != nil {
+            return werr
+        }
+        logger.Info("pending outbound payment status updated from dinopay", slog.String("status", status))
+        return nil
+    }
+    if !payment.LastAlertedAt.IsZero() && now.Sub(payment.LastAlertedAt) < s.sla {
+        return nil
+    }
+    werr = s.append(ctx, payment, PaymentStuck{
+        Id:                   wuuid.NewUUID(),
+        PaymentId:            payment.PaymentId,
+        DinopayPaymentId:     dinopayPaymentId,
+        DinopayPaymentStatus: status,
+        PendingSince:         payment.StatusSince.UnixMilli(),
+        EventCreatedAt:       now.UnixMilli(),
+    })
+    if werr != nil {
+        return werr
+    }
+    s.stuckPayments.Add(ctx, 1)
+    logger.Warn("outbound payment is still pending on dinopay", slog.Time("pending_since", payment.StatusSince))
+    return nil
+}
+
+func (s *PendingSweeper) overdue(payment *Payment) bool {
+    return payment.DinopayPaymentStatus == string(dinopayapi.PaymentStatusPending) &&
+        s.now().Sub(payment.StatusSince) >= s.sla
+}
+
+func (s *PendingSweeper) append(ctx context.Context, payment *Payment, event events.EventData) werrors.WError {
+    _, werr := s.db.AppendEvents(
+        ctx,
+        gateway.BuildOutboundPaymentStreamName(payment.DinopayPaymentId.String()),
+        eventsourcing.ExpectedAggregateVersion{Version: payment.Version},
+        event,
+    )
+    if werr != nil {
+        return werrors.NewWrappedError(werr, "failed appending "+event.Type()+" event")
+    }
+    return nil
+}
+
+// listPending returns the DinoPay payment ids of the pending payments
+func (s *PendingSweeper) listPending(ctx context.Context) ([]uuid.UUID, werrors.WError) {
+    payments, werr := ListPayments(ctx, s.db, s.categoryStreamName)
+    if werr != nil {
+        return nil, werr
+    }
+    var pending []uuid.UUID
+    for _, payment := range payments {
+        if payment.DinopayPaymentStatus == string(dinopayapi.PaymentStatusPending) {
+            pending = append(pending, payment.DinopayPaymentId)
+        }
+    }
+    return pending, nil
+}
+
+func newStuckPaymentsCounter(meter metric.Meter) metric.Int64Counter {
+    counter, err := meter.Int64Counter(
+        stuckPaymentsMetricName,
+        metric.WithDescription("Outbound payments still pending on DinoPay after the pending SLA"),
+    )
+    if err != nil {
+        otel.Handle(err)
+    }
+    return counter
+}
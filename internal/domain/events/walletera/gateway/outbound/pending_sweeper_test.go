@@ -0,0 +1,124 @@
+package outbound
+
+import (
+    "context"
+    "log/slog"
+    "testing"
+    "time"
+
+    "github.com/google/uuid"
+    "github.com/stretchr/testify/require"
+    "github.com/walletera/dinopay-gateway/internal/domain/events/walletera/gateway"
+    "github.com/walletera/dinopay-gateway/internal/testutil"
+    dinopayapi "github.com/walletera/dinopay/api"
+    "github.com/walletera/eventskit/eventsourcing"
+    "github.com/walletera/werrors"
+)
+
+type fakeLease struct {
+    acquired bool
+}
+
+func (l fakeLease) Acquire(_ context.Context) (bool, werrors.WError) {
+    return l.acquired, nil
+}
+
+func TestPendingSweeper_Sweep(t *testing.T) {
+    const sla = time.Hour
+    tests := []struct {
+        name          string
+        leaseAcquired bool
+        pendingFor    time.Duration
+        dinopayStatus dinopayapi.PaymentStatus
+        sweeps        int
+        wantStatus    string
+        wantAlerts    int
+    }{
+        {
+            name:          "status changed on dinopay is recorded",
+            leaseAcquired: true,
+            pendingFor:    2 * sla,
+            dinopayStatus: dinopayapi.PaymentStatusConfirmed,
+            sweeps:        1,
+            wantStatus:    string(dinopayapi.PaymentStatusConfirmed),
+        },
+        {
+            name:          "payment still pending is alerted once per sla",
+            leaseAcquired: true,
+            pendingFor:    2 * sla,
+            dinopayStatus: dinopayapi.PaymentStatusPending,
+            sweeps:        2,
+            wantStatus:    string(dinopayapi.PaymentStatusPending),
+            wantAlerts:    1,
+        },
+        {
+            name:          "payment pending within the sla is not polled",
+            leaseAcquired: true,
+            pendingFor:    sla / 2,
+            dinopayStatus: dinopayapi.PaymentStatusConfirmed,
+            sweeps:        1,
+            wantStatus:    string(dinopayapi.PaymentStatusPending),
+        },
+        {
+            name:          "nothing is done without the lease",
+            leaseAcquired: false,
+            pendingFor:    2 * sla,
+            dinopayStatus: dinopayapi.PaymentStatusConfirmed,
+            sweeps:        1,
+            wantStatus:    string(dinopayapi.PaymentStatusPending),
+        },
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            ctx := context.Background()
+            db := testutil.NewFakeDB()
+            now := time.Now()
+            dinopayPaymentId := uuid.New()
+            streamName := gateway.BuildOutboundPaymentStreamName(dinopayPaymentId.String())
+            _, werr := db.AppendEvents(
+                ctx,
+                streamName,
+                eventsourcing.ExpectedAggregateVersion{IsNew: true},
+                PaymentCreated{
+                    Id:                   uuid.New(),
+                    PaymentId:            uuid.New(),
+                    DinopayPaymentId:     dinopayPaymentId,
+                    DinopayPaymentStatus: string(dinopayapi.PaymentStatusPending),
+                    PaymentCreatedAt:     now.Add(-tt.pendingFor).UnixMilli(),
+                },
+            )
+            require.NoError(t, werr)
+
+            dinopayClient := &fakeDinopayClient{found: &dinopayapi.Payment{
+                ID:     dinopayapi.NewOptUUID(dinopayPaymentId),
+                Status: dinopayapi.NewOptPaymentStatus(tt.dinopayStatus),
+            }}
+            sweeper := NewPendingSweeper(
+                db,
+                dinopayClient,
+                fakeLease{acquired: tt.leaseAcquired},
+                testCategoryStreamName,
+                slog.Default(),
+                WithPendingSLA(sla),
+                WithSweeperClock(func() time.Time { return now }),
+            )
+            for i := 0; i < tt.sweeps; i++ {
+                require.NoError(t, sweeper.Sweep(ctx))
+                now = now.Add(time.Minute)
+            }
+
+            payment, werr := LoadPayment(ctx, db, dinopayPaymentId)
+            require.NoError(t, werr)
+            require.Equal(t, tt.wantStatus, payment.DinopayPaymentStatus)
+            alerts := 0
+            for _, rawEvent := range db.Stream(streamName) {
+                event, err := NewEventsDeserializer().Deserialize(rawEvent)
+                require.NoError(t, err)
+                if _, ok := event.(PaymentStuck); ok {
+                    alerts++
+                }
+            }
+            require.Equal(t, tt.wantAlerts, alerts)
+        })
+    }
+}
@@ -0,0 +1,170 @@
+package outbound
+
+import (
+    "context"
+    "log/slog"
+    "time"
+
+    "github.com/google/uuid"
+    "github.com/walletera/dinopay-gateway/internal/domain/events/walletera/gateway"
+    "github.com/walletera/dinopay-gateway/internal/domain/mapping"
+    "github.com/walletera/dinopay-gateway/pkg/logattr"
+    "github.com/walletera/dinopay-gateway/pkg/wuuid"
+    "github.com/walletera/eventskit/events"
+    "github.com/walletera/eventskit/eventsourcing"
+    "github.com/walletera/werrors"
+)
+
+// ReviewService holds outbound payments for review by an operator instead of
+// submitting them to DinoPay. A released payment is submitted with the
+// Submitter, a rejected one is failed.
+type ReviewService struct {
+    db                 eventsourcing.DB
+    submitter          *Submitter
+    categoryStreamName string
+    logger             *slog.Logger
+}
+
+func NewReviewService(db eventsourcing.DB, submitter *Submitter, categoryStreamName string, logger *slog.Logger) *ReviewService {
+    return &ReviewService{
+        db:                 db,
+        submitter:          submitter,
+        categoryStreamName: categoryStreamName,
+        logger:             logger.With(logattr.Component("outbound.ReviewService")),
+    }
+}
+
+// Hold records the payment as held, after the given events. Holding a payment
+// twice is a no-op. version is the expected version of the stream named after
+// the Walletera payment id, IsNew when the payment has no events there yet.
+func (s *ReviewService) Hold(
+    ctx context.Context,
+    paymentId uuid.UUID,
+    customerId uuid.UUID,
+    payment mapping.DinopayPayment,
+    reason gateway.HoldReason,
+    details string,
+    version eventsourcing.ExpectedAggregateVersion,
+    preceding ...events.EventData,
+) werrors.WError {
+    logger := s.logger.With(logattr.PaymentId(paymentId.String()))
+    paymentHeld := PaymentHeld{
+        Id:                 wuuid.NewUUID(),
+        PaymentId:          paymentId,
+        CustomerId:         customerId,
+        Amount:             payment.Amount,
+        Currency:           payment.Currency,
+        SourceAccount:      payment.SourceAccount,
+        DestinationAccount: payment.DestinationAccount,
+        Reason:             reason,
+        Details:            details,
+        EventCreatedAt:     time.Now().UnixMilli(),
+    }
+    _, werr := s.db.AppendEvents(
+        ctx,
+        gateway.BuildOutboundPaymentStreamName(paymentId.String()),
+        version,
+        append(preceding, paymentHeld)...,
+    )
+    if werr != nil {
+        if werr.Code() == werrors.ResourceAlreadyExistErrorCode {
+            logger.Info("payment hold already recorded")
+            return nil
+        }
+        werr := werrors.NewWrappedError(werr, "failed appending outbound PaymentHeld event")
+        logger.Error(werr.Error())
+        return werr
+    }
+    logger.Warn("payment held for review", logattr.Reason(string(reason)), slog.String("details", details))
+    return nil
+}
+
+// ListHeld returns the payments waiting for review
+func (s *ReviewService) ListHeld(ctx context.Context) ([]*HeldPayment, werrors.WError) {
+    return ListHeldPayments(ctx, s.db, s.categoryStreamName, HoldStatusHeld)
+}
+
+// Release submits the held payment to DinoPay. Releasing a payment already
+// released submits it again when its submission failed before recording an
+// outcome, the submission continues from what the stream recorded.
+func (s *ReviewService) Release(ctx context.Context, paymentId uuid.UUID, releasedBy string) werrors.WError {
+    logger := s.logger.With(logattr.PaymentId(paymentId.String()))
+    heldPayment, werr := LoadHeldPayment(ctx, s.db, paymentId)
+    if werr != nil {
+        return werr
+    }
+    switch heldPayment.Status {
+    case HoldStatusReleased:
+        logger.Info("resuming submission of released payment", slog.String("released_by", releasedBy))
+        return s.submitter.Submit(
+            ctx,
+            logger,
+            paymentId,
+            heldPayment.DinopayPayment(),
+            eventsourcing.ExpectedAggregateVersion{Version: heldPayment.DecisionVersion},
+        )
+    case HoldStatusHeld:
+    default:
+        return werrors.NewValidationError("outbound payment " + paymentId.String() + " is " + string(heldPayment.Status) + ", not held")
+    }
+    paymentReleased := PaymentReleased{
+        Id:             wuuid.NewUUID(),
+        PaymentId:      paymentId,
+        ReleasedBy:     releasedBy,
+        EventCreatedAt: time.Now().UnixMilli(),
+    }
+    releasedVersion, werr := s.db.AppendEvents(
+        ctx,
+        gateway.BuildOutboundPaymentStreamName(paymentId.String()),
+        eventsourcing.ExpectedAggregateVersion{Version: heldPayment.Version},
+        paymentReleased,
+    )
+    if werr != nil {
+        return werrors.NewWrappedError(werr, "failed appending outbound PaymentReleased event")
+    }
+    logger.Info("held payment released", slog.String("released_by", releasedBy))
+    return s.submitter.Submit(
+        ctx,
+        logger,
+        paymentId,
+        heldPayment.DinopayPayment(),
+        eventsourcing.ExpectedAggregateVersion{Version: releasedVersion},
+    )
+}
+
+// Reject fails the held payment without submitting it
+func (s *ReviewService) Reject(ctx context.Context, paymentId uuid.UUID, rejectedBy string, comment string) werrors.WError {
+    logger := s.logger.With(logattr.PaymentId(paymentId.String()))
+    heldPayment, werr := s.loadPendingReview(ctx, paymentId)
+    if werr != nil {
+        return werr
+    }
+    details := "rejected by " + rejectedBy
+    if comment != "" {
+        details += ": " + comment
+    }
+    werr = s.submitter.recordFailure(
+        ctx,
+        logger,
+        paymentId,
+        FailureReasonRejectedOnReview,
+        details,
+        eventsourcing.ExpectedAggregateVersion{Version: heldPayment.Version},
+    )
+    if werr != nil {
+        return werr
+    }
+    logger.Info("held payment rejected", slog.String("rejected_by", rejectedBy))
+    return nil
+}
+
+func (s *ReviewService) loadPendingReview(ctx context.Context, paymentId uuid.UUID) (*HeldPayment, werrors.WError) {
+    heldPayment, werr := LoadHeldPayment(ctx, s.db, paymentId)
+    if werr != nil {
+        return nil, werr
+    }
+    if heldPayment.Status != HoldStatusHeld {
+        return nil, werrors.NewValidationError("outbound payment " + paymentId.String() + " is " + string(heldPayment.Status) + ", not held")
+    }
+    return heldPayment, nil
+}
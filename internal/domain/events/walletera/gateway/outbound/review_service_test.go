@@ -0,0 +1,179 @@
+package outbound
+
+import (
+    "context"
+    "log/slog"
+    "testing"
+
+    "github.com/google/uuid"
+    "github.com/shopspring/decimal"
+    "github.com/stretchr/testify/require"
+    "github.com/walletera/dinopay-gateway/internal/domain/events/walletera/gateway"
+    "github.com/walletera/dinopay-gateway/internal/domain/mapping"
+    "github.com/walletera/dinopay-gateway/internal/testutil"
+    dinopayapi "github.com/walletera/dinopay/api"
+    "github.com/walletera/eventskit/eventsourcing"
+    "github.com/walletera/werrors"
+)
+
+func TestReviewService(t *testing.T) {
+    payment := mapping.DinopayPayment{
+        Amount:             decimal.RequireFromString("100"),
+        Currency:           "USD",
+        SourceAccount:      mapping.Account{AccountHolder: "John Doe", AccountNumber: "1200079635"},
+        DestinationAccount: mapping.Account{AccountHolder: "Jane Doe", AccountNumber: "1200079636"},
+    }
+    dinopayPaymentId := uuid.New()
+    tests := []struct {
+        name              string
+        review            func(ctx context.Context, service *ReviewService, paymentId uuid.UUID) error
+        wantStatus        HoldStatus
+        wantCreateCalls   int
+        wantLastEventType string
+    }{
+        {
+            name: "released payment is submitted to dinopay",
+            review: func(ctx context.Context, service *ReviewService, paymentId uuid.UUID) error {
+                return service.Release(ctx, paymentId, "operator-1")
+            },
+            wantStatus:        HoldStatusReleased,
+            wantCreateCalls:   1,
+            wantLastEventType: "OutboundPaymentSubmitted",
+        },
+        {
+            name: "rejected payment is failed",
+            review: func(ctx context.Context, service *ReviewService, paymentId uuid.UUID) error {
+                return service.Reject(ctx, paymentId, "operator-1", "suspicious beneficiary")
+            },
+            wantStatus:        HoldStatusRejected,
+            wantLastEventType: "OutboundPaymentFailed",
+        },
+        {
+            name: "cancelled payment is not held anymore",
+            review: func(ctx context.Context, service *ReviewService, paymentId uuid.UUID) error {
+                return NewCancellationService(service.db, &fakeDinopayClient{}, slog.Default()).Cancel(ctx, paymentId)
+            },
+            wantStatus:        HoldStatusCancelled,
+            wantLastEventType: "OutboundPaymentCancelled",
+        },
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            ctx := context.Background()
+            db := testutil.NewFakeDB()
+            dinopayClient := &fakeDinopayClient{createRes: &dinopayapi.Payment{
+                ID:     dinopayapi.NewOptUUID(dinopayPaymentId),
+                Status: dinopayapi.NewOptPaymentStatus(dinopayapi.PaymentStatusPending),
+            }}
+            service := NewReviewService(db, NewSubmitter(db, dinopayClient), testCategoryStreamName, slog.Default())
+            paymentId := uuid.New()
+
+            require.NoError(t, service.Hold(ctx, paymentId, uuid.New(), payment, gateway.HoldReasonVelocityLimit, "limit breached", eventsourcing.ExpectedAggregateVersion{IsNew: true}))
+            // holding twice is a no-op
+            require.NoError(t, service.Hold(ctx, paymentId, uuid.New(), payment, gateway.HoldReasonVelocityLimit, "limit breached", eventsourcing.ExpectedAggregateVersion{IsNew: true}))
+
+            held, werr := service.ListHeld(ctx)
+            require.NoError(t, werr)
+            require.Len(t, held, 1)
+            require.Equal(t, paymentId, held[0].PaymentId)
+
+            require.NoError(t, tt.review(ctx, service, paymentId))
+
+            heldPayment, werr := LoadHeldPayment(ctx, db, paymentId)
+            require.NoError(t, werr)
+            require.Equal(t, tt.wantStatus, heldPayment.Status)
+            require.Len(t, dinopayClient.created, tt.wantCreateCalls)
+            stream := db.Stream(gateway.BuildOutboundPaymentStreamName(paymentId.String()))
+            lastEvent, err := NewEventsDeserializer().Deserialize(stream[len(stream)-1])
+            require.NoError(t, err)
+            require.Equal(t, tt.wantLastEventType, lastEvent.Type())
+
+            held, werr = service.ListHeld(ctx)
+            require.NoError(t, werr)
+            require.Empty(t, held)
+
+            werr = service.Release(ctx, paymentId, "operator-2")
+            if tt.wantStatus == HoldStatusReleased {
+                // releasing again continues the recorded submission without submitting twice
+                require.NoError(t, werr)
+                require.Len(t, dinopayClient.created, tt.wantCreateCalls)
+                return
+            }
+            // a reviewed payment can't be reviewed again
+            require.Error(t, werr)
+            require.Equal(t, werrors.ValidationErrorCode, werr.Code())
+        })
+    }
+}
+
+func TestReviewService_ResumesInterruptedSubmission(t *testing.T) {
+    payment := mapping.DinopayPayment{
+        Amount:             decimal.RequireFromString("50000"),
+        Currency:           "USD",
+        SourceAccount:      mapping.Account{AccountHolder: "John Doe", AccountNumber: "1200079635"},
+        DestinationAccount: mapping.Account{AccountHolder: "Jane Doe", AccountNumber: "1200079636"},
+    }
+    tests := []struct {
+        name   string
+        decide func(ctx context.Context, db eventsourcing.DB, paymentId uuid.UUID) error
+        // resume repeats the decision that was interrupted before the payment was submitted
+        resume func(ctx context.Context, db eventsourcing.DB, submitter *Submitter, paymentId uuid.UUID) error
+    }{
+        {
+            name: "released payment",
+            decide: func(ctx context.Context, db eventsourcing.DB, paymentId uuid.UUID) error {
+                _, werr := db.AppendEvents(ctx, gateway.BuildOutboundPaymentStreamName(paymentId.String()),
+                    eventsourcing.ExpectedAggregateVersion{IsNew: true},
+                    PaymentHeld{Id: uuid.New(), PaymentId: paymentId, Amount: payment.Amount, Currency: payment.Currency,
+                        SourceAccount: payment.SourceAccount, DestinationAccount: payment.DestinationAccount, Reason: gateway.HoldReasonVelocityLimit},
+                    PaymentReleased{Id: uuid.New(), PaymentId: paymentId, ReleasedBy: "operator-1"},
+                )
+                return werr
+            },
+            resume: func(ctx context.Context, db eventsourcing.DB, submitter *Submitter, paymentId uuid.UUID) error {
+                return NewReviewService(db, submitter, testCategoryStreamName, slog.Default()).Release(ctx, paymentId, "operator-1")
+            },
+        },
+        {
+            name: "approved payment",
+            decide: func(ctx context.Context, db eventsourcing.DB, paymentId uuid.UUID) error {
+                _, werr := db.AppendEvents(ctx, gateway.BuildOutboundPaymentStreamName(paymentId.String()),
+                    eventsourcing.ExpectedAggregateVersion{IsNew: true},
+                    PaymentAwaitingApproval{Id: uuid.New(), PaymentId: paymentId, Amount: payment.Amount, Currency: payment.Currency,
+                        SourceAccount: payment.SourceAccount, DestinationAccount: payment.DestinationAccount, Threshold: decimal.RequireFromString("10000")},
+                    PaymentFirstApproved{Id: uuid.New(), PaymentId: paymentId, ApprovedBy: "first-approver"},
+                    PaymentApproved{Id: uuid.New(), PaymentId: paymentId, ApprovedBy: "approver"},
+                )
+                return werr
+            },
+            resume: func(ctx context.Context, db eventsourcing.DB, submitter *Submitter, paymentId uuid.UUID) error {
+                return NewApprovalService(db, submitter, testCategoryStreamName, slog.Default()).Approve(ctx, paymentId, "approver")
+            },
+        },
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            ctx := context.Background()
+            db := testutil.NewFakeDB()
+            dinopayClient := &fakeDinopayClient{createRes: &dinopayapi.Payment{
+                ID:     dinopayapi.NewOptUUID(uuid.New()),
+                Status: dinopayapi.NewOptPaymentStatus(dinopayapi.PaymentStatusPending),
+            }}
+            submitter := NewSubmitter(db, dinopayClient)
+            paymentId := uuid.New()
+
+            // the decision was recorded but the process stopped before submitting the payment
+            require.NoError(t, tt.decide(ctx, db, paymentId))
+
+            require.NoError(t, tt.resume(ctx, db, submitter, paymentId))
+            require.Len(t, dinopayClient.created, 1)
+            submission, werr := LoadSubmission(ctx, db, paymentId)
+            require.NoError(t, werr)
+            require.NotEqual(t, uuid.Nil, submission.DinopayPaymentId)
+
+            // resuming a submitted payment doesn't submit it again
+            require.NoError(t, tt.resume(ctx, db, submitter, paymentId))
+            require.Len(t, dinopayClient.created, 1)
+        })
+    }
+}
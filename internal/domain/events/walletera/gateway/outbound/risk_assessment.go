@@ -0,0 +1,62 @@
+package outbound
+
+import (
+    "context"
+    "time"
+
+    "github.com/google/uuid"
+    "github.com/walletera/dinopay-gateway/internal/domain/events/walletera/gateway"
+    riskport "github.com/walletera/dinopay-gateway/internal/domain/ports/output/risk"
+    "github.com/walletera/dinopay-gateway/pkg/wuuid"
+    "github.com/walletera/eventskit/events"
+    "github.com/walletera/eventskit/eventsourcing"
+    "github.com/walletera/werrors"
+)
+
+// RecordRiskAssessment records the risk decision on the payment, after the given
+// events, as the first events of the stream named after the Walletera payment id.
+// It returns the expected version of that stream for the event acting upon the
+// decision, or false when a previous attempt already acted upon it.
+func RecordRiskAssessment(
+    ctx context.Context,
+    db eventsourcing.DB,
+    paymentId uuid.UUID,
+    customerId uuid.UUID,
+    decision riskport.Decision,
+    preceding ...events.EventData,
+) (eventsourcing.ExpectedAggregateVersion, bool, werrors.WError) {
+    streamName := gateway.BuildOutboundPaymentStreamName(paymentId.String())
+    version, werr := db.AppendEvents(
+        ctx,
+        streamName,
+        eventsourcing.ExpectedAggregateVersion{IsNew: true},
+        append(preceding, PaymentRiskAssessed{
+            Id:             wuuid.NewUUID(),
+            PaymentId:      paymentId,
+            CustomerId:     customerId,
+            Outcome:        decision.Outcome,
+            Reasons:        decision.Reasons,
+            EventCreatedAt: time.Now().UnixMilli(),
+        })...,
+    )
+    if werr == nil {
+        return eventsourcing.ExpectedAggregateVersion{Version: version}, true, nil
+    }
+    if werr.Code() != werrors.ResourceAlreadyExistErrorCode {
+        return eventsourcing.ExpectedAggregateVersion{}, false, werrors.NewWrappedError(werr, "failed appending outbound PaymentRiskAssessed event")
+    }
+    retrievedEvents, werr := db.ReadEvents(ctx, streamName)
+    if werr != nil {
+        return eventsourcing.ExpectedAggregateVersion{}, false, werrors.NewWrappedError(werr, "failed reading outbound payment stream "+streamName)
+    }
+    // the decision is pending while it's the last event of the stream
+    last := retrievedEvents[len(retrievedEvents)-1]
+    event, err := NewEventsDeserializer().Deserialize(last.RawEvent)
+    if err != nil {
+        return eventsourcing.ExpectedAggregateVersion{}, false, werrors.NewNonRetryableInternalError("failed deserializing outbound payment event: " + err.Error())
+    }
+    if _, ok := event.(PaymentRiskAssessed); !ok {
+        return eventsourcing.ExpectedAggregateVersion{}, false, nil
+    }
+    return eventsourcing.ExpectedAggregateVersion{Version: last.AggregateVersion}, true, nil
+}
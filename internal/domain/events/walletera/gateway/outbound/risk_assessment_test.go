@@ -0,0 +1,70 @@
+package outbound
+
+import (
+    "context"
+    "testing"
+
+    "github.com/google/uuid"
+    "github.com/stretchr/testify/require"
+    "github.com/walletera/dinopay-gateway/internal/domain/events/walletera/gateway"
+    riskport "github.com/walletera/dinopay-gateway/internal/domain/ports/output/risk"
+    screeningport "github.com/walletera/dinopay-gateway/internal/domain/ports/output/screening"
+    "github.com/walletera/dinopay-gateway/internal/domain/screening"
+    "github.com/walletera/dinopay-gateway/internal/testutil"
+    "github.com/walletera/eventskit/eventsourcing"
+)
+
+func TestRecordRiskAssessment(t *testing.T) {
+    ctx := context.Background()
+    db := testutil.NewFakeDB()
+    paymentId := uuid.New()
+    decision := riskport.Decision{Outcome: riskport.OutcomeReview, Reasons: []string{"new beneficiary"}}
+
+    version, pending, werr := RecordRiskAssessment(ctx, db, paymentId, uuid.New(), decision)
+    require.NoError(t, werr)
+    require.True(t, pending)
+    require.Equal(t, eventsourcing.ExpectedAggregateVersion{Version: 0}, version)
+
+    // a redelivery still has to act upon the decision
+    version, pending, werr = RecordRiskAssessment(ctx, db, paymentId, uuid.New(), decision)
+    require.NoError(t, werr)
+    require.True(t, pending)
+    require.Equal(t, eventsourcing.ExpectedAggregateVersion{Version: 0}, version)
+
+    _, werr = db.AppendEvents(ctx, gateway.BuildOutboundPaymentStreamName(paymentId.String()), version, PaymentHeld{Id: uuid.New(), PaymentId: paymentId})
+    require.NoError(t, werr)
+
+    // once acted upon, there is nothing left to do
+    _, pending, werr = RecordRiskAssessment(ctx, db, paymentId, uuid.New(), decision)
+    require.NoError(t, werr)
+    require.False(t, pending)
+}
+
+func TestRecordRiskAssessment_AfterTheScreening(t *testing.T) {
+    ctx := context.Background()
+    db := testutil.NewFakeDB()
+    paymentId := uuid.New()
+    decision := riskport.Decision{Outcome: riskport.OutcomeAllow}
+    paymentScreened := PaymentScreened{
+        Id:        uuid.New(),
+        PaymentId: paymentId,
+        Party:     screening.PartyBeneficiary,
+        Name:      "Jane Doe",
+        Result:    screeningport.Result{ListVersion: "v1"},
+    }
+
+    version, pending, werr := RecordRiskAssessment(ctx, db, paymentId, uuid.New(), decision, paymentScreened)
+    require.NoError(t, werr)
+    require.True(t, pending)
+    require.Equal(t, eventsourcing.ExpectedAggregateVersion{Version: 1}, version)
+
+    // a redelivery finds the recorded screening and still has to act upon the decision
+    recorded, werr := LoadScreening(ctx, db, paymentId, screening.PartyBeneficiary)
+    require.NoError(t, werr)
+    require.NotNil(t, recorded)
+    require.Equal(t, paymentScreened.Result, recorded.Result)
+    version, pending, werr = RecordRiskAssessment(ctx, db, paymentId, uuid.New(), decision)
+    require.NoError(t, werr)
+    require.True(t, pending)
+    require.Equal(t, eventsourcing.ExpectedAggregateVersion{Version: 1}, version)
+}
@@ -0,0 +1,37 @@
+package outbound
+
+import (
+    "context"
+
+    "github.com/google/uuid"
+    "github.com/walletera/dinopay-gateway/internal/domain/events/walletera/gateway"
+    "github.com/walletera/dinopay-gateway/internal/domain/screening"
+    "github.com/walletera/eventskit/eventsourcing"
+    "github.com/walletera/werrors"
+)
+
+// LoadScreening returns the screening of the given party recorded in the stream
+// named after the Walletera payment id, or nil when the party wasn't screened yet.
+// A redelivered payment is decided upon the recorded result, so that a list
+// update doesn't change the decision a previous attempt made.
+func LoadScreening(ctx context.Context, db eventsourcing.DB, paymentId uuid.UUID, party screening.Party) (*PaymentScreened, werrors.WError) {
+    retrievedEvents, werr := db.ReadEvents(ctx, gateway.BuildOutboundPaymentStreamName(paymentId.String()))
+    if werr != nil {
+        if werr.Code() == werrors.ResourceNotFoundErrorCode {
+            return nil, nil
+        }
+        return nil, werrors.NewWrappedError(werr, "failed reading outbound payment stream")
+    }
+    deserializer := NewEventsDeserializer()
+    for _, retrievedEvent := range retrievedEvents {
+        event, err := deserializer.Deserialize(retrievedEvent.RawEvent)
+        if err != nil {
+            return nil, werrors.NewNonRetryableInternalError("failed deserializing outbound payment event: " + err.Error())
+        }
+        paymentScreened, ok := event.(PaymentScreened)
+        if ok && paymentScreened.Party == party {
+            return &paymentScreened, nil
+        }
+    }
+    return nil, nil
+}
@@ -0,0 +1,52 @@
+package outbound
+
+import (
+    "context"
+    "log/slog"
+    "testing"
+
+    "github.com/google/uuid"
+    "github.com/stretchr/testify/require"
+    "github.com/walletera/dinopay-gateway/internal/domain/events/walletera/gateway"
+    screeningport "github.com/walletera/dinopay-gateway/internal/domain/ports/output/screening"
+    "github.com/walletera/dinopay-gateway/internal/domain/screening"
+    "github.com/walletera/dinopay-gateway/internal/testutil"
+    "github.com/walletera/eventskit/eventsourcing"
+)
+
+func TestLoadScreening(t *testing.T) {
+    ctx := context.Background()
+    db := testutil.NewFakeDB()
+    paymentId := uuid.New()
+
+    recorded, werr := LoadScreening(ctx, db, paymentId, screening.PartyBeneficiary)
+    require.NoError(t, werr)
+    require.Nil(t, recorded, "a payment without stream wasn't screened")
+
+    hit := screeningport.Result{
+        Hit:         true,
+        Matches:     []screeningport.Match{{EntryId: "SDN-1", Name: "Ivan Petrov", Score: 0.95}},
+        ListVersion: "v1",
+    }
+    _, werr = db.AppendEvents(
+        ctx,
+        gateway.BuildOutboundPaymentStreamName(paymentId.String()),
+        eventsourcing.ExpectedAggregateVersion{IsNew: true},
+        PaymentScreened{Id: uuid.New(), PaymentId: paymentId, Party: screening.PartySource, Name: "John Doe", Result: screeningport.Result{ListVersion: "v1"}},
+        PaymentScreened{Id: uuid.New(), PaymentId: paymentId, Party: screening.PartyBeneficiary, Name: "Ivan Petrof", Result: hit},
+        PaymentHeld{Id: uuid.New(), PaymentId: paymentId, Reason: gateway.HoldReasonSanctionsHit},
+    )
+    require.NoError(t, werr)
+
+    // only the result of the given party counts
+    recorded, werr = LoadScreening(ctx, db, paymentId, screening.PartyBeneficiary)
+    require.NoError(t, werr)
+    require.NotNil(t, recorded)
+    require.Equal(t, "Ivan Petrof", recorded.Name)
+    require.Equal(t, hit, recorded.Result)
+
+    // the screening doesn't change what the payment stream tells
+    blocked, werr := NewCancellationService(db, &fakeDinopayClient{}, slog.Default()).Blocked(ctx, paymentId)
+    require.NoError(t, werr)
+    require.True(t, blocked)
+}
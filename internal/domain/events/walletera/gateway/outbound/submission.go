@@ -0,0 +1,87 @@
+package outbound
+
+import (
+    "context"
+
+    "github.com/google/uuid"
+    "github.com/walletera/dinopay-gateway/internal/domain/events/walletera/gateway"
+    "github.com/walletera/eventskit/eventsourcing"
+    "github.com/walletera/werrors"
+)
+
+// Submission is the state of the submission of an outbound payment to DinoPay,
+// rebuilt from the stream named after the Walletera payment id. The submission
+// and the cancellation of a payment both append to that stream with optimistic
+// concurrency, so they can't both succeed.
+type Submission struct {
+    PaymentId uuid.UUID
+    // Exists is false when nothing was recorded for the payment yet
+    Exists             bool
+    Cancelled          bool
+    CancellationFailed bool
+    // Submitting is true once the payment is being created on DinoPay
+    Submitting bool
+    // DinopayPaymentId is set once the payment is known to exist on DinoPay
+    DinopayPaymentId     uuid.UUID
+    DinopayPaymentStatus string
+    Failed               bool
+    OutcomeUnknown       bool
+    Version              uint64
+}
+
+// LoadSubmission reads the stream named after the given Walletera payment id
+func LoadSubmission(ctx context.Context, db eventsourcing.DB, paymentId uuid.UUID) (*Submission, werrors.WError) {
+    submission := &Submission{PaymentId: paymentId}
+    retrievedEvents, werr := db.ReadEvents(ctx, gateway.BuildOutboundPaymentStreamName(paymentId.String()))
+    if werr != nil {
+        if werr.Code() == werrors.ResourceNotFoundErrorCode {
+            return submission, nil
+        }
+        return nil, werrors.NewWrappedError(werr, "failed reading outbound payment stream")
+    }
+    deserializer := NewEventsDeserializer()
+    for _, retrievedEvent := range retrievedEvents {
+        event, err := deserializer.Deserialize(retrievedEvent.RawEvent)
+        if err != nil {
+            return nil, werrors.NewNonRetryableInternalError("failed deserializing outbound payment event: " + err.Error())
+        }
+        switch e := event.(type) {
+        case PaymentCancelled:
+            submission.Cancelled = true
+        case PaymentCancellationFailed:
+            submission.CancellationFailed = true
+        case PaymentSubmitting:
+            submission.Submitting = true
+        case PaymentSubmitted:
+            submission.DinopayPaymentId = e.DinopayPaymentId
+            submission.DinopayPaymentStatus = e.DinopayPaymentStatus
+        case PaymentFailed:
+            submission.Failed = true
+        case PaymentOutcomeUnknown:
+            submission.OutcomeUnknown = true
+        case PaymentOutcomeResolved:
+            submission.OutcomeUnknown = false
+            submission.DinopayPaymentId = e.DinopayPaymentId
+        }
+        submission.Exists = true
+        submission.Version = retrievedEvent.AggregateVersion
+    }
+    return submission, nil
+}
+
+// ExpectedVersion is the version to append the next event of the submission with
+func (s *Submission) ExpectedVersion() eventsourcing.ExpectedAggregateVersion {
+    if !s.Exists {
+        return eventsourcing.ExpectedAggregateVersion{IsNew: true}
+    }
+    return eventsourcing.ExpectedAggregateVersion{Version: s.Version}
+}
+
+// HasOutcome tells whether the outcome of creating the payment on DinoPay was recorded
+func (s *Submission) HasOutcome() bool {
+    return s.DinopayPaymentId != uuid.Nil || s.Failed || s.OutcomeUnknown
+}
+
+func isConflict(werr werrors.WError) bool {
+    return werr.Code() == werrors.ResourceAlreadyExistErrorCode || werr.Code() == werrors.WrongResourceVersionErrorCode
+}
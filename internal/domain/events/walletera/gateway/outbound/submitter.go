@@ -0,0 +1,321 @@
+package outbound
+
+import (
+    "context"
+    "errors"
+    "fmt"
+    "log/slog"
+    "time"
+
+    "github.com/google/uuid"
+    "github.com/walletera/dinopay-gateway/internal/domain/events/walletera/gateway"
+    "github.com/walletera/dinopay-gateway/internal/domain/mapping"
+    "github.com/walletera/dinopay-gateway/internal/domain/ports/output/dinopay"
+    "github.com/walletera/dinopay-gateway/pkg/logattr"
+    "github.com/walletera/dinopay-gateway/pkg/wuuid"
+    dinopayapi "github.com/walletera/dinopay/api"
+    "github.com/walletera/eventskit/events"
+    "github.com/walletera/eventskit/eventsourcing"
+    "github.com/walletera/werrors"
+)
+
+// Submitter creates outbound payments on DinoPay and records the outcome.
+// A PaymentSubmitting event is appended to the stream named after the Walletera
+// payment id before calling DinoPay, so a cancellation racing with the
+// submission fails instead of cancelling a payment that is being sent.
+// The outcome is recorded in the same stream. A created payment also gets its
+// own stream, named after the DinoPay payment id, where the
+// OutboundPaymentCreated event continues the regular outbound flow.
+type Submitter struct {
+    db            eventsourcing.DB
+    dinopayClient dinopay.Client
+}
+
+func NewSubmitter(db eventsourcing.DB, dinopayClient dinopay.Client) *Submitter {
+    return &Submitter{
+        db:            db,
+        dinopayClient: dinopayClient,
+    }
+}
+
+// Submit creates the payment on DinoPay, with the Walletera payment id as
+// CustomerTransactionId. version is the expected version of the stream named
+// after the Walletera payment id, IsNew when the payment has no events there yet.
+// The given events are appended along with the first event of the submission.
+// A payment whose stream moved past version is never created again on DinoPay,
+// the submission continues from what the stream recorded.
+func (s *Submitter) Submit(
+    ctx context.Context,
+    logger *slog.Logger,
+    paymentId uuid.UUID,
+    payment mapping.DinopayPayment,
+    version eventsourcing.ExpectedAggregateVersion,
+    preceding ...events.EventData,
+) werrors.WError {
+    dinopayReq, err := mapping.ToDinopayRequest(payment, paymentId.String())
+    if err != nil {
+        violation := MappingViolation(err)
+        return s.Fail(ctx, logger, paymentId, violation.Reason, violation.Details, version, preceding...)
+    }
+    submittingVersion, werr := s.db.AppendEvents(
+        ctx,
+        gateway.BuildOutboundPaymentStreamName(paymentId.String()),
+        version,
+        append(preceding, PaymentSubmitting{
+            Id:             wuuid.NewUUID(),
+            PaymentId:      paymentId,
+            EventCreatedAt: time.Now().UnixMilli(),
+        })...,
+    )
+    if werr != nil {
+        if isConflict(werr) {
+            return s.continueSubmission(ctx, logger, paymentId, payment, dinopayReq.CustomerTransactionId.Value)
+        }
+        werr := werrors.NewWrappedError(werr, "failed appending outbound PaymentSubmitting event")
+        logger.Error(werr.Error())
+        return werr
+    }
+    version = eventsourcing.ExpectedAggregateVersion{Version: submittingVersion}
+    dinopayResp, err := s.dinopayClient.CreatePayment(ctx, dinopayReq)
+    if err != nil {
+        logger.Error("failed creating payment on dinopay", logattr.Error(err.Error()))
+        return s.recordUnknownOutcome(ctx, logger, paymentId, payment, dinopayReq.CustomerTransactionId.Value, err, version)
+    }
+    if dinopayResp == nil {
+        logger.Error("dinopay response is nil")
+        return s.recordUnknownOutcome(ctx, logger, paymentId, payment, dinopayReq.CustomerTransactionId.Value, errors.New("dinopay response is nil"), version)
+    }
+    if badRequest, ok := dinopayResp.(*dinopayapi.CreatePaymentBadRequest); ok {
+        return s.handleDinopayRejection(ctx, logger, paymentId, badRequest, version)
+    }
+    dinopayPayment, ok := dinopayResp.(*dinopayapi.Payment)
+    if !ok {
+        err := fmt.Errorf("unexpected dinopay response type %T", dinopayResp)
+        logger.Error(err.Error())
+        return s.recordUnknownOutcome(ctx, logger, paymentId, payment, dinopayReq.CustomerTransactionId.Value, err, version)
+    }
+
+    logger.Info("dinopay dinopayPayment created successfully")
+
+    _, werr = s.db.AppendEvents(
+        ctx,
+        gateway.BuildOutboundPaymentStreamName(paymentId.String()),
+        version,
+        PaymentSubmitted{
+            Id:                   wuuid.NewUUID(),
+            PaymentId:            paymentId,
+            DinopayPaymentId:     dinopayPayment.ID.Value,
+            DinopayPaymentStatus: string(dinopayPayment.Status.Value),
+            EventCreatedAt:       time.Now().UnixMilli(),
+        },
+    )
+    if werr != nil {
+        // the redelivered event finds the payment submitting and records its outcome as unknown
+        werr := werrors.NewWrappedError(werr, "failed appending outbound PaymentSubmitted event")
+        logger.Error(werr.Error(), logattr.DinopayPaymentId(dinopayPayment.ID.Value.String()))
+        return werr
+    }
+    return s.recordCreated(ctx, logger, paymentId, dinopayPayment.ID.Value, string(dinopayPayment.Status.Value))
+}
+
+// continueSubmission handles a submission whose stream moved past the expected
+// version, because the payment was cancelled or a previous attempt submitted it
+func (s *Submitter) continueSubmission(
+    ctx context.Context,
+    logger *slog.Logger,
+    paymentId uuid.UUID,
+    payment mapping.DinopayPayment,
+    customerTransactionId string,
+) werrors.WError {
+    submission, werr := LoadSubmission(ctx, s.db, paymentId)
+    if werr != nil {
+        return werr
+    }
+    switch {
+    case submission.Cancelled:
+        logger.Info("outbound payment was cancelled before being submitted")
+        return nil
+    case submission.DinopayPaymentId != uuid.Nil:
+        // the OutboundPaymentCreated event may be missing if the previous attempt stopped after PaymentSubmitted
+        return s.recordCreated(ctx, logger, paymentId, submission.DinopayPaymentId, submission.DinopayPaymentStatus)
+    case submission.HasOutcome():
+        logger.Info("outbound payment submission outcome already recorded")
+        return nil
+    case submission.Submitting:
+        // the previous attempt stopped without recording whether DinoPay created the payment
+        err := errors.New("submission interrupted before recording the dinopay response")
+        return s.recordUnknownOutcome(ctx, logger, paymentId, payment, customerTransactionId, err, submission.ExpectedVersion())
+    default:
+        werr := werrors.NewRetryableInternalError("outbound payment " + paymentId.String() + " changed while submitting it")
+        logger.Warn(werr.Error())
+        return werr
+    }
+}
+
+// recordCreated appends the OutboundPaymentCreated event that continues the
+// regular outbound flow in the stream of the DinoPay payment
+func (s *Submitter) recordCreated(
+    ctx context.Context,
+    logger *slog.Logger,
+    paymentId uuid.UUID,
+    dinopayPaymentId uuid.UUID,
+    dinopayPaymentStatus string,
+) werrors.WError {
+    streamName := gateway.BuildOutboundPaymentStreamName(dinopayPaymentId.String())
+    _, werr := s.db.AppendEvents(
+        ctx,
+        streamName,
+        eventsourcing.ExpectedAggregateVersion{IsNew: true},
+        PaymentCreated{
+            Id:                   wuuid.NewUUID(),
+            PaymentId:            paymentId,
+            DinopayPaymentId:     dinopayPaymentId,
+            DinopayPaymentStatus: dinopayPaymentStatus,
+            PaymentCreatedAt:     time.Now().UnixMilli(),
+        },
+    )
+    if werr != nil {
+        if werr.Code() == werrors.ResourceAlreadyExistErrorCode {
+            logger.Info("outbound payment creation already recorded")
+            return nil
+        }
+        werr := werrors.NewWrappedError(werr, "failed appending outbound PaymentCreated event to stream "+streamName)
+        logger.Error(werr.Error())
+        return werr
+    }
+    return nil
+}
+
+// Fail fails the payment without submitting it, after the given events.
+// The OutboundPaymentFailed event marks the payment as failed on the Payments API.
+func (s *Submitter) Fail(
+    ctx context.Context,
+    logger *slog.Logger,
+    paymentId uuid.UUID,
+    reason FailureReason,
+    details string,
+    version eventsourcing.ExpectedAggregateVersion,
+    preceding ...events.EventData,
+) werrors.WError {
+    werr := s.recordFailure(ctx, logger, paymentId, reason, details, version, preceding...)
+    if werr != nil {
+        return werr
+    }
+    logger.Warn("payment failed validation", logattr.Reason(string(reason)), slog.String("details", details))
+    return nil
+}
+
+// handleDinopayRejection records the reason DinoPay gave for rejecting the payment
+func (s *Submitter) handleDinopayRejection(
+    ctx context.Context,
+    logger *slog.Logger,
+    paymentId uuid.UUID,
+    badRequest *dinopayapi.CreatePaymentBadRequest,
+    version eventsourcing.ExpectedAggregateVersion,
+) werrors.WError {
+    details, err := ReadDinopayRejection(badRequest)
+    if err != nil {
+        logger.Warn("failed reading dinopay rejection body", logattr.Error(err.Error()))
+    }
+    reason := ParseDinopayRejection(details)
+    werr := s.recordFailure(ctx, logger, paymentId, reason, details, version)
+    if werr != nil {
+        return werr
+    }
+    logger.Info("dinopay rejected the payment", logattr.Reason(string(reason)))
+    return nil
+}
+
+func (s *Submitter) recordFailure(
+    ctx context.Context,
+    logger *slog.Logger,
+    paymentId uuid.UUID,
+    reason FailureReason,
+    details string,
+    version eventsourcing.ExpectedAggregateVersion,
+    preceding ...events.EventData,
+) werrors.WError {
+    outboundPaymentFailed := PaymentFailed{
+        Id:             wuuid.NewUUID(),
+        PaymentId:      paymentId,
+        Reason:         reason,
+        Details:        details,
+        EventCreatedAt: time.Now().UnixMilli(),
+    }
+    _, werr := s.db.AppendEvents(
+        ctx,
+        gateway.BuildOutboundPaymentStreamName(paymentId.String()),
+        version,
+        append(preceding, outboundPaymentFailed)...,
+    )
+    if werr != nil {
+        if isConflict(werr) {
+            return s.checkRecorded(ctx, logger, paymentId)
+        }
+        werr := werrors.NewWrappedError(werr, "failed appending outbound PaymentFailed event")
+        logger.Error(werr.Error())
+        return werr
+    }
+    return nil
+}
+
+// recordUnknownOutcome is called when DinoPay didn't answer the payment creation.
+// The payment may exist on DinoPay, so instead of retrying blindly the
+// outcome is recorded as unknown for the UnknownOutcomeResolver.
+func (s *Submitter) recordUnknownOutcome(
+    ctx context.Context,
+    logger *slog.Logger,
+    paymentId uuid.UUID,
+    payment mapping.DinopayPayment,
+    customerTransactionId string,
+    dinopayErr error,
+    version eventsourcing.ExpectedAggregateVersion,
+) werrors.WError {
+    outboundPaymentOutcomeUnknown := PaymentOutcomeUnknown{
+        Id:                    wuuid.NewUUID(),
+        PaymentId:             paymentId,
+        Amount:                payment.Amount,
+        Currency:              payment.Currency,
+        SourceAccount:         payment.SourceAccount,
+        DestinationAccount:    payment.DestinationAccount,
+        CustomerTransactionId: customerTransactionId,
+        Error:                 dinopayErr.Error(),
+        EventCreatedAt:        time.Now().UnixMilli(),
+    }
+    _, werr := s.db.AppendEvents(
+        ctx,
+        gateway.BuildOutboundPaymentStreamName(paymentId.String()),
+        version,
+        outboundPaymentOutcomeUnknown,
+    )
+    if werr != nil {
+        if isConflict(werr) {
+            return s.checkRecorded(ctx, logger, paymentId)
+        }
+        werr := werrors.NewWrappedError(werr, "failed appending outbound PaymentOutcomeUnknown event")
+        logger.Error(werr.Error())
+        return werr
+    }
+    logger.Warn("payment outcome on dinopay is unknown")
+    return nil
+}
+
+// checkRecorded handles a conflict recording the outcome of a payment. It is only
+// ignored when a previous attempt recorded the outcome or the payment was cancelled.
+func (s *Submitter) checkRecorded(ctx context.Context, logger *slog.Logger, paymentId uuid.UUID) werrors.WError {
+    submission, werr := LoadSubmission(ctx, s.db, paymentId)
+    if werr != nil {
+        return werr
+    }
+    if submission.Cancelled {
+        logger.Info("outbound payment was cancelled before being submitted")
+        return nil
+    }
+    if submission.HasOutcome() {
+        logger.Info("outbound payment submission outcome already recorded")
+        return nil
+    }
+    werr = werrors.NewRetryableInternalError("outbound payment " + paymentId.String() + " changed while recording its outcome")
+    logger.Warn(werr.Error())
+    return werr
+}
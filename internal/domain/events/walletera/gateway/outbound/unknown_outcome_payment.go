@@ -0,0 +1,193 @@
+package outbound
+
+import (
+    "context"
+    "time"
+
+    "github.com/google/uuid"
+    "github.com/shopspring/decimal"
+    "github.com/walletera/dinopay-gateway/internal/domain/events/walletera/gateway"
+    "github.com/walletera/dinopay-gateway/internal/domain/mapping"
+    "github.com/walletera/eventskit/eventsourcing"
+    "github.com/walletera/werrors"
+)
+
+// Resolution tells how the outcome of a payment was resolved
+type Resolution string
+
+const (
+    // ResolutionAdopted means the payment was found on DinoPay
+    ResolutionAdopted Resolution = "adopted"
+    // ResolutionResubmitted means the payment wasn't found on DinoPay and was created again.
+    // The resolver doesn't resubmit payments anymore, it's only found in older events.
+    ResolutionResubmitted Resolution = "resubmitted"
+)
+
+type UnknownOutcomeStatus string
+
+const (
+    UnknownOutcomeStatusUnknown   UnknownOutcomeStatus = "unknown"
+    UnknownOutcomeStatusResolved  UnknownOutcomeStatus = "resolved"
+    UnknownOutcomeStatusFailed    UnknownOutcomeStatus = "failed"
+    UnknownOutcomeStatusEscalated UnknownOutcomeStatus = "escalated"
+)
+
+// UnknownOutcomePayment is the state of an outbound payment whose creation
+// outcome on DinoPay is unknown, rebuilt from the events of the stream
+// named after the Walletera payment id.
+type UnknownOutcomePayment struct {
+    PaymentId             uuid.UUID            `json:"paymentId"`
+    Status                UnknownOutcomeStatus `json:"status"`
+    Amount                decimal.Decimal      `json:"amount"`
+    Currency              string               `json:"currency"`
+    SourceAccount         mapping.Account      `json:"sourceAccount"`
+    DestinationAccount    mapping.Account      `json:"destinationAccount"`
+    CustomerTransactionId string               `json:"customerTransactionId"`
+    DinopayPaymentId      uuid.UUID            `json:"dinopayPaymentId,omitempty"`
+    Resolution            Resolution           `json:"resolution,omitempty"`
+    Attempts              int                  `json:"attempts"`
+    LastError             string               `json:"lastError,omitempty"`
+    UnknownSince          time.Time            `json:"unknownSince"`
+    LastAttemptAt         time.Time            `json:"lastAttemptAt,omitempty"`
+    Version               uint64               `json:"-"`
+}
+
+var _ EventsHandler = (*UnknownOutcomePayment)(nil)
+
+// LoadUnknownOutcomePayment reads the outboundPayment stream of the given Walletera payment
+func LoadUnknownOutcomePayment(ctx context.Context, db eventsourcing.DB, paymentId uuid.UUID) (*UnknownOutcomePayment, werrors.WError) {
+    retrievedEvents, werr := db.ReadEvents(ctx, gateway.BuildOutboundPaymentStreamName(paymentId.String()))
+    if werr != nil {
+        return nil, werr
+    }
+    payment := &UnknownOutcomePayment{}
+    deserializer := NewEventsDeserializer()
+    for _, retrievedEvent := range retrievedEvents {
+        event, err := deserializer.Deserialize(retrievedEvent.RawEvent)
+        if err != nil {
+            return nil, werrors.NewNonRetryableInternalError("failed deserializing outbound payment event: " + err.Error())
+        }
+        werr = event.Accept(ctx, payment)
+        if werr != nil {
+            return nil, werr
+        }
+        payment.Version = retrievedEvent.AggregateVersion
+    }
+    if payment.PaymentId == uuid.Nil {
+        return nil, werrors.NewResourceNotFoundError("outbound payment " + paymentId.String() + " has no unknown outcome")
+    }
+    return payment, nil
+}
+
+// DinopayPayment returns the DinoPay payment whose creation outcome is unknown
+func (p *UnknownOutcomePayment) DinopayPayment() mapping.DinopayPayment {
+    return mapping.DinopayPayment{
+        Amount:             p.Amount,
+        Currency:           p.Currency,
+        SourceAccount:      p.SourceAccount,
+        DestinationAccount: p.DestinationAccount,
+    }
+}
+
+func (p *UnknownOutcomePayment) HandleOutboundPaymentOutcomeUnknown(_ context.Context, paymentOutcomeUnknown PaymentOutcomeUnknown) werrors.WError {
+    p.PaymentId = paymentOutcomeUnknown.PaymentId
+    p.Status = UnknownOutcomeStatusUnknown
+    p.Amount = paymentOutcomeUnknown.Amount
+    p.Currency = paymentOutcomeUnknown.Currency
+    p.SourceAccount = paymentOutcomeUnknown.SourceAccount
+    p.DestinationAccount = paymentOutcomeUnknown.DestinationAccount
+    p.CustomerTransactionId = paymentOutcomeUnknown.CustomerTransactionId
+    p.LastError = paymentOutcomeUnknown.Error
+    p.UnknownSince = paymentOutcomeUnknown.CreatedAt()
+    return nil
+}
+
+func (p *UnknownOutcomePayment) HandleOutboundPaymentResolutionFailed(_ context.Context, paymentResolutionFailed PaymentResolutionFailed) werrors.WError {
+    p.Attempts = paymentResolutionFailed.Attempt
+    p.LastError = paymentResolutionFailed.Error
+    p.LastAttemptAt = paymentResolutionFailed.CreatedAt()
+    return nil
+}
+
+func (p *UnknownOutcomePayment) HandleOutboundPaymentOutcomeResolved(_ context.Context, paymentOutcomeResolved PaymentOutcomeResolved) werrors.WError {
+    p.Status = UnknownOutcomeStatusResolved
+    p.DinopayPaymentId = paymentOutcomeResolved.DinopayPaymentId
+    p.Resolution = paymentOutcomeResolved.Resolution
+    p.LastAttemptAt = paymentOutcomeResolved.CreatedAt()
+    return nil
+}
+
+func (p *UnknownOutcomePayment) HandleOutboundPaymentEscalated(_ context.Context, _ PaymentEscalated) werrors.WError {
+    p.Status = UnknownOutcomeStatusEscalated
+    return nil
+}
+
+func (p *UnknownOutcomePayment) HandleOutboundPaymentFailed(_ context.Context, paymentFailed PaymentFailed) werrors.WError {
+    p.Status = UnknownOutcomeStatusFailed
+    p.LastError = paymentFailed.Details
+    return nil
+}
+
+func (p *UnknownOutcomePayment) HandleOutboundPaymentCreated(_ context.Context, _ PaymentCreated) werrors.WError {
+    return nil
+}
+
+func (p *UnknownOutcomePayment) HandleOutboundPaymentUpdated(_ context.Context, _ PaymentUpdated) werrors.WError {
+    return nil
+}
+
+func (p *UnknownOutcomePayment) HandleOutboundPaymentStuck(_ context.Context, _ PaymentStuck) werrors.WError {
+    return nil
+}
+
+func (p *UnknownOutcomePayment) HandleOutboundPaymentCancelled(_ context.Context, _ PaymentCancelled) werrors.WError {
+    return nil
+}
+
+func (p *UnknownOutcomePayment) HandleOutboundPaymentCancellationFailed(_ context.Context, _ PaymentCancellationFailed) werrors.WError {
+    return nil
+}
+
+func (p *UnknownOutcomePayment) HandleOutboundPaymentHeld(_ context.Context, _ PaymentHeld) werrors.WError {
+    return nil
+}
+
+func (p *UnknownOutcomePayment) HandleOutboundPaymentReleased(_ context.Context, _ PaymentReleased) werrors.WError {
+    return nil
+}
+
+func (p *UnknownOutcomePayment) HandleOutboundPaymentAwaitingApproval(_ context.Context, _ PaymentAwaitingApproval) werrors.WError {
+    return nil
+}
+
+func (p *UnknownOutcomePayment) HandleOutboundPaymentFirstApproved(_ context.Context, _ PaymentFirstApproved) werrors.WError {
+    return nil
+}
+
+func (p *UnknownOutcomePayment) HandleOutboundPaymentApproved(_ context.Context, _ PaymentApproved) werrors.WError {
+    return nil
+}
+
+func (p *UnknownOutcomePayment) HandleOutboundPaymentApprovalRejected(_ context.Context, _ PaymentApprovalRejected) werrors.WError {
+    return nil
+}
+
+func (p *UnknownOutcomePayment) HandleOutboundPaymentRiskAssessed(_ context.Context, _ PaymentRiskAssessed) werrors.WError {
+    return nil
+}
+
+func (p *UnknownOutcomePayment) HandleOutboundPaymentScreened(_ context.Context, _ PaymentScreened) werrors.WError {
+    return nil
+}
+
+func (p *UnknownOutcomePayment) HandleOutboundPaymentNotified(_ context.Context, _ PaymentNotified) werrors.WError {
+    return nil
+}
+
+func (p *UnknownOutcomePayment) HandleOutboundPaymentSubmitting(_ context.Context, _ PaymentSubmitting) werrors.WError {
+    return nil
+}
+
+func (p *UnknownOutcomePayment) HandleOutboundPaymentSubmitted(_ context.Context, _ PaymentSubmitted) werrors.WError {
+    return nil
+}
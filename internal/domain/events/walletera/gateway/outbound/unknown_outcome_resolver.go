@@ -0,0 +1,296 @@
+package outbound
+
+import (
+    "context"
+    "log/slog"
+    "sort"
+    "time"
+
+    "github.com/google/uuid"
+    "github.com/walletera/dinopay-gateway/internal/domain/events/walletera/gateway"
+    "github.com/walletera/dinopay-gateway/internal/domain/ports/output/dinopay"
+    "github.com/walletera/dinopay-gateway/pkg/logattr"
+    "github.com/walletera/dinopay-gateway/pkg/wuuid"
+    dinopayapi "github.com/walletera/dinopay/api"
+    "github.com/walletera/eventskit/events"
+    "github.com/walletera/eventskit/eventsourcing"
+    "github.com/walletera/werrors"
+)
+
+const (
+    DefaultResolveInterval    = 30 * time.Second
+    DefaultResolveBackoff     = 1 * time.Minute
+    DefaultMaxResolveAttempts = 5
+)
+
+// UnknownOutcomeResolver resolves the outbound payments whose creation on DinoPay
+// timed out. The payment is looked up on DinoPay by its CustomerTransactionId and
+// adopted when found. It is never resubmitted: DinoPay isn't known to deduplicate
+// payments by CustomerTransactionId, and a payment that isn't found yet may still
+// show up late. Failed and empty lookups count as failed attempts, and a payment
+// still unknown after maxAttempts of them is escalated to operators.
+type UnknownOutcomeResolver struct {
+    db                 eventsourcing.DB
+    dinopayClient      dinopay.Client
+    lease              Lease
+    categoryStreamName string
+    interval           time.Duration
+    backoff            time.Duration
+    maxAttempts        int
+    now                func() time.Time
+    logger             *slog.Logger
+}
+
+type ResolverOpt func(r *UnknownOutcomeResolver)
+
+// WithResolveInterval sets how often the unresolved payments are checked
+func WithResolveInterval(interval time.Duration) ResolverOpt {
+    return func(r *UnknownOutcomeResolver) { r.interval = interval }
+}
+
+// WithResolveBackoff sets the min time between two attempts to resolve the same payment
+func WithResolveBackoff(backoff time.Duration) ResolverOpt {
+    return func(r *UnknownOutcomeResolver) { r.backoff = backoff }
+}
+
+// WithMaxResolveAttempts sets after how many failed attempts a payment is escalated
+func WithMaxResolveAttempts(maxAttempts int) ResolverOpt {
+    return func(r *UnknownOutcomeResolver) { r.maxAttempts = maxAttempts }
+}
+
+// WithResolverClock replaces time.Now, mostly for tests
+func WithResolverClock(now func() time.Time) ResolverOpt {
+    return func(r *UnknownOutcomeResolver) { r.now = now }
+}
+
+func NewUnknownOutcomeResolver(
+    db eventsourcing.DB,
+    dinopayClient dinopay.Client,
+    lease Lease,
+    categoryStreamName string,
+    logger *slog.Logger,
+    opts ...ResolverOpt,
+) *UnknownOutcomeResolver {
+    r := &UnknownOutcomeResolver{
+        db:                 db,
+        dinopayClient:      dinopayClient,
+        lease:              lease,
+        categoryStreamName: categoryStreamName,
+        interval:           DefaultResolveInterval,
+        backoff:            DefaultResolveBackoff,
+        maxAttempts:        DefaultMaxResolveAttempts,
+        now:                time.Now,
+        logger:             logger.With(logattr.Component("outbound.UnknownOutcomeResolver")),
+    }
+    for _, opt := range opts {
+        opt(r)
+    }
+    return r
+}
+
+// Run resolves the pending payments every interval until ctx is done
+func (r *UnknownOutcomeResolver) Run(ctx context.Context) {
+    ticker := time.NewTicker(r.interval)
+    defer ticker.Stop()
+    for {
+        select {
+        case <-ctx.Done():
+            return
+        case <-ticker.C:
+            werr := r.ResolvePending(ctx)
+            if werr != nil {
+                r.logger.Error("failed resolving outbound payments with unknown outcome", logattr.Error(werr.Error()))
+            }
+        }
+    }
+}
+
+// ResolvePending makes one resolution attempt for every unknown payment whose backoff elapsed.
+// It does nothing when another replica holds the lease.
+func (r *UnknownOutcomeResolver) ResolvePending(ctx context.Context) werrors.WError {
+    acquired, werr := r.lease.Acquire(ctx)
+    if werr != nil {
+        return werrors.NewWrappedError(werr, "failed acquiring unknown outcome resolver lease")
+    }
+    if !acquired {
+        r.logger.Debug("unknown outcome resolver lease is held by another replica")
+        return nil
+    }
+    payments, werr := r.list(ctx, UnknownOutcomeStatusUnknown)
+    if werr != nil {
+        return werr
+    }
+    for _, payment := range payments {
+        if !payment.LastAttemptAt.IsZero() && r.now().Sub(payment.LastAttemptAt) < r.backoff {
+            continue
+        }
+        werr = r.Resolve(ctx, payment)
+        if werr != nil {
+            // a concurrent resolution bumped the stream version, it's retried on the next tick
+            r.logger.Warn(
+                "failed resolving outbound payment",
+                logattr.PaymentId(payment.PaymentId.String()),
+                logattr.Error(werr.Error()),
+            )
+        }
+    }
+    return nil
+}
+
+// ListUnresolved returns the payments that are still unknown or were escalated, oldest first.
+// It folds the whole outboundPayment category so it is meant for operators, not for hot paths.
+func (r *UnknownOutcomeResolver) ListUnresolved(ctx context.Context) ([]*UnknownOutcomePayment, werrors.WError) {
+    return r.list(ctx, UnknownOutcomeStatusUnknown, UnknownOutcomeStatusEscalated)
+}
+
+// Resolve looks the payment up on DinoPay and adopts it or records the failed attempt
+func (r *UnknownOutcomeResolver) Resolve(ctx context.Context, payment *UnknownOutcomePayment) werrors.WError {
+    logger := r.logger.With(logattr.PaymentId(payment.PaymentId.String()))
+    dinopayPayment, err := r.dinopayClient.FindPaymentByCustomerTransactionId(ctx, payment.CustomerTransactionId)
+    if err != nil {
+        return r.attemptFailed(ctx, logger, payment, "failed looking up payment on dinopay: "+err.Error())
+    }
+    if dinopayPayment == nil {
+        return r.attemptFailed(ctx, logger, payment, "payment not found on dinopay")
+    }
+    return r.resolved(ctx, logger, payment, dinopayPayment, ResolutionAdopted)
+}
+
+// resolved records the DinoPay payment in its own stream, which continues
+// the regular outbound flow, and then marks the outcome as resolved
+func (r *UnknownOutcomeResolver) resolved(
+    ctx context.Context,
+    logger *slog.Logger,
+    payment *UnknownOutcomePayment,
+    dinopayPayment *dinopayapi.Payment,
+    resolution Resolution,
+) werrors.WError {
+    now := r.now()
+    dinopayPaymentId := dinopayPayment.ID.Value
+    outboundPaymentCreated := PaymentCreated{
+        Id:                   wuuid.NewUUID(),
+        PaymentId:            payment.PaymentId,
+        DinopayPaymentId:     dinopayPaymentId,
+        DinopayPaymentStatus: string(dinopayPayment.Status.Value),
+        PaymentCreatedAt:     now.UnixMilli(),
+    }
+    _, werr := r.db.AppendEvents(
+        ctx,
+        gateway.BuildOutboundPaymentStreamName(dinopayPaymentId.String()),
+        eventsourcing.ExpectedAggregateVersion{IsNew: true},
+        outboundPaymentCreated,
+    )
+    if werr != nil && werr.Code() != werrors.ResourceAlreadyExistErrorCode {
+        return werrors.NewWrappedError(werr, "failed appending outbound PaymentCreated event")
+    }
+    werr = r.appendEvents(ctx, payment, PaymentOutcomeResolved{
+        Id:               wuuid.NewUUID(),
+        PaymentId:        payment.PaymentId,
+        DinopayPaymentId: dinopayPaymentId,
+        Resolution:       resolution,
+        EventCreatedAt:   now.UnixMilli(),
+    })
+    if werr != nil {
+        return werr
+    }
+    logger.Info(
+        "outbound payment outcome resolved",
+        logattr.DinopayPaymentId(dinopayPaymentId.String()),
+        slog.String("resolution", string(resolution)),
+    )
+    return nil
+}
+
+func (r *UnknownOutcomeResolver) attemptFailed(ctx context.Context, logger *slog.Logger, payment *UnknownOutcomePayment, errMsg string) werrors.WError {
+    now := r.now()
+    attempt := payment.Attempts + 1
+    resolutionEvents := []events.EventData{
+        PaymentResolutionFailed{
+            Id:             wuuid.NewUUID(),
+            PaymentId:      payment.PaymentId,
+            Attempt:        attempt,
+            Error:          errMsg,
+            EventCreatedAt: now.UnixMilli(),
+        },
+    }
+    if attempt >= r.maxAttempts {
+        resolutionEvents = append(resolutionEvents, PaymentEscalated{
+            Id:             wuuid.NewUUID(),
+            PaymentId:      payment.PaymentId,
+            Attempts:       attempt,
+            EventCreatedAt: now.UnixMilli(),
+        })
+    }
+    werr := r.appendEvents(ctx, payment, resolutionEvents...)
+    if werr != nil {
+        return werr
+    }
+    logger.Warn("outbound payment resolution attempt failed", slog.Int("attempt", attempt), logattr.Error(errMsg))
+    return nil
+}
+
+func (r *UnknownOutcomeResolver) appendEvents(ctx context.Context, payment *UnknownOutcomePayment, resolutionEvents ...events.EventData) werrors.WError {
+    _, werr := r.db.AppendEvents(
+        ctx,
+        gateway.BuildOutboundPaymentStreamName(payment.PaymentId.String()),
+        eventsourcing.ExpectedAggregateVersion{Version: payment.Version},
+        resolutionEvents...,
+    )
+    if werr != nil {
+        return werrors.NewWrappedError(werr, "failed appending outbound payment resolution events")
+    }
+    return nil
+}
+
+// list folds the category into the payments with an unknown outcome and
+// returns the ones in any of the given statuses, oldest first
+func (r *UnknownOutcomeResolver) list(ctx context.Context, statuses ...UnknownOutcomeStatus) ([]*UnknownOutcomePayment, werrors.WError) {
+    retrievedEvents, werr := r.db.ReadEvents(ctx, r.categoryStreamName)
+    if werr != nil {
+        if werr.Code() == werrors.ResourceNotFoundErrorCode {
+            return nil, nil
+        }
+        return nil, werr
+    }
+    deserializer := NewEventsDeserializer()
+    var paymentIds []uuid.UUID
+    for _, retrievedEvent := range retrievedEvents {
+        event, err := deserializer.Deserialize(retrievedEvent.RawEvent)
+        if err != nil {
+            return nil, werrors.NewNonRetryableInternalError("failed deserializing outbound payment event: " + err.Error())
+        }
+        if paymentOutcomeUnknown, ok := event.(PaymentOutcomeUnknown); ok {
+            paymentIds = append(paymentIds, paymentOutcomeUnknown.PaymentId)
+        }
+    }
+    var payments []*UnknownOutcomePayment
+    for _, paymentId := range paymentIds {
+        // the stream is read again to get the version to append with
+        payment, werr := LoadUnknownOutcomePayment(ctx, r.db, paymentId)
+        if werr != nil {
+            return nil, werr
+        }
+        for _, status := range statuses {
+            if payment.Status == status {
+                payments = append(payments, payment)
+                break
+            }
+        }
+    }
+    sort.Slice(payments, func(i, j int) bool {
+        return payments[i].UnknownSince.Before(payments[j].UnknownSince)
+    })
+    return payments, nil
+}
+
+// ResolveById lets operators trigger a resolution attempt, escalated payments included
+func (r *UnknownOutcomeResolver) ResolveById(ctx context.Context, paymentId uuid.UUID) werrors.WError {
+    payment, werr := LoadUnknownOutcomePayment(ctx, r.db, paymentId)
+    if werr != nil {
+        return werr
+    }
+    if payment.Status != UnknownOutcomeStatusUnknown && payment.Status != UnknownOutcomeStatusEscalated {
+        return werrors.NewValidationError("outbound payment " + paymentId.String() + " is already " + string(payment.Status))
+    }
+    return r.Resolve(ctx, payment)
+}
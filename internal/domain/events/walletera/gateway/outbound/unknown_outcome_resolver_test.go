@@ -0,0 +1,168 @@
+package outbound
+
+import (
+    "context"
+    "errors"
+    "log/slog"
+    "testing"
+    "time"
+
+    "github.com/google/uuid"
+    "github.com/shopspring/decimal"
+    "github.com/stretchr/testify/require"
+    "github.com/walletera/dinopay-gateway/internal/domain/events/walletera/gateway"
+    "github.com/walletera/dinopay-gateway/internal/domain/mapping"
+    "github.com/walletera/dinopay-gateway/internal/domain/ports/output/dinopay"
+    "github.com/walletera/dinopay-gateway/internal/testutil"
+    dinopayapi "github.com/walletera/dinopay/api"
+    "github.com/walletera/eventskit/eventsourcing"
+)
+
+const testCategoryStreamName = "$ce-outboundPayment"
+
+type fakeDinopayClient struct {
+    found     *dinopayapi.Payment
+    lookupErr error
+    createRes dinopayapi.CreatePaymentRes
+    createErr error
+    created   []*dinopayapi.Payment
+    cancelRes *dinopayapi.Payment
+    cancelErr error
+    cancelled []uuid.UUID
+}
+
+func (c *fakeDinopayClient) CreatePayment(_ context.Context, req *dinopayapi.Payment) (dinopayapi.CreatePaymentRes, error) {
+    c.created = append(c.created, req)
+    return c.createRes, c.createErr
+}
+
+func (c *fakeDinopayClient) GetPayment(_ context.Context, _ uuid.UUID) (*dinopayapi.Payment, error) {
+    return c.found, c.lookupErr
+}
+
+func (c *fakeDinopayClient) FindPaymentByCustomerTransactionId(_ context.Context, _ string) (*dinopayapi.Payment, error) {
+    return c.found, c.lookupErr
+}
+
+func (c *fakeDinopayClient) CancelPayment(_ context.Context, id uuid.UUID) (*dinopayapi.Payment, error) {
+    c.cancelled = append(c.cancelled, id)
+    return c.cancelRes, c.cancelErr
+}
+
+func (c *fakeDinopayClient) ListPaymentChanges(_ context.Context, _ string) (dinopay.PaymentsPage, error) {
+    return dinopay.PaymentsPage{}, nil
+}
+
+func TestUnknownOutcomeResolver_ResolvePending(t *testing.T) {
+    dinopayPaymentId := uuid.New()
+    dinopayPayment := &dinopayapi.Payment{
+        ID:     dinopayapi.NewOptUUID(dinopayPaymentId),
+        Status: dinopayapi.NewOptPaymentStatus(dinopayapi.PaymentStatusPending),
+    }
+    tests := []struct {
+        name           string
+        dinopayClient  *fakeDinopayClient
+        leaseHeld      bool
+        attempts       int
+        wantStatus     UnknownOutcomeStatus
+        wantResolution Resolution
+        wantAttempts   int
+    }{
+        {
+            name:           "payment found on dinopay is adopted",
+            dinopayClient:  &fakeDinopayClient{found: dinopayPayment},
+            attempts:       1,
+            wantStatus:     UnknownOutcomeStatusResolved,
+            wantResolution: ResolutionAdopted,
+        },
+        {
+            name:          "payment not found on dinopay is not resubmitted",
+            dinopayClient: &fakeDinopayClient{createRes: dinopayPayment},
+            attempts:      1,
+            wantStatus:    UnknownOutcomeStatusUnknown,
+            wantAttempts:  1,
+        },
+        {
+            name:          "payment not found on dinopay is escalated after max attempts",
+            dinopayClient: &fakeDinopayClient{createRes: dinopayPayment},
+            attempts:      3,
+            wantStatus:    UnknownOutcomeStatusEscalated,
+            wantAttempts:  3,
+        },
+        {
+            name:          "payment is escalated after max attempts",
+            dinopayClient: &fakeDinopayClient{lookupErr: errors.New("timeout")},
+            attempts:      3,
+            wantStatus:    UnknownOutcomeStatusEscalated,
+            wantAttempts:  3,
+        },
+        {
+            name:          "payment is still unknown before max attempts",
+            dinopayClient: &fakeDinopayClient{lookupErr: errors.New("timeout")},
+            attempts:      2,
+            wantStatus:    UnknownOutcomeStatusUnknown,
+            wantAttempts:  2,
+        },
+        {
+            name:          "nothing is done while another replica holds the lease",
+            dinopayClient: &fakeDinopayClient{found: dinopayPayment},
+            leaseHeld:     true,
+            attempts:      1,
+            wantStatus:    UnknownOutcomeStatusUnknown,
+        },
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            ctx := context.Background()
+            db := testutil.NewFakeDB()
+            paymentId := uuid.New()
+            _, werr := db.AppendEvents(
+                ctx,
+                gateway.BuildOutboundPaymentStreamName(paymentId.String()),
+                eventsourcing.ExpectedAggregateVersion{IsNew: true},
+                PaymentOutcomeUnknown{
+                    Id:                    uuid.New(),
+                    PaymentId:             paymentId,
+                    Amount:                decimal.RequireFromString("100.50"),
+                    Currency:              "ARS",
+                    SourceAccount:         mapping.Account{AccountHolder: "John Doe", AccountNumber: "1200079635"},
+                    DestinationAccount:    mapping.Account{AccountHolder: "Jane Doe", AccountNumber: "1200079636"},
+                    CustomerTransactionId: paymentId.String(),
+                    EventCreatedAt:        time.Now().UnixMilli(),
+                },
+            )
+            require.NoError(t, werr)
+
+            now := time.Now()
+            resolver := NewUnknownOutcomeResolver(
+                db,
+                tt.dinopayClient,
+                fakeLease{acquired: !tt.leaseHeld},
+                testCategoryStreamName,
+                slog.Default(),
+                WithResolveBackoff(time.Minute),
+                WithMaxResolveAttempts(3),
+                WithResolverClock(func() time.Time { return now }),
+            )
+            for i := 0; i < tt.attempts; i++ {
+                require.NoError(t, resolver.ResolvePending(ctx))
+                // the backoff must elapse before the next attempt
+                require.NoError(t, resolver.ResolvePending(ctx))
+                now = now.Add(time.Minute)
+            }
+
+            payment, werr := LoadUnknownOutcomePayment(ctx, db, paymentId)
+            require.NoError(t, werr)
+            require.Equal(t, tt.wantStatus, payment.Status)
+            require.Equal(t, tt.wantResolution, payment.Resolution)
+            require.Equal(t, tt.wantAttempts, payment.Attempts)
+            if tt.wantResolution != "" {
+                require.Equal(t, dinopayPaymentId, payment.DinopayPaymentId)
+                exists := db.Stream(gateway.BuildOutboundPaymentStreamName(dinopayPaymentId.String())) != nil
+                require.True(t, exists, "missing OutboundPaymentCreated event")
+            }
+            // a payment is never created again on dinopay
+            require.Empty(t, tt.dinopayClient.created)
+        })
+    }
+}
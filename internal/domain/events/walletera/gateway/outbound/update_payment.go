@@ -5,6 +5,7 @@ import (
     "fmt"
 
     "github.com/google/uuid"
+    "github.com/walletera/dinopay-gateway/internal/domain/events/walletera/gateway"
     dinopayapi "github.com/walletera/dinopay/api"
     paymentsapi "github.com/walletera/payments-types/privateapi"
     "github.com/walletera/werrors"
@@ -15,7 +16,7 @@ func updatePaymentStatus(ctx context.Context, client *paymentsapi.Client, paymen
     if err != nil {
         return err
     }
-    _, patchPaymentErr := client.PatchPayment(
+    resp, patchPaymentErr := client.PatchPayment(
         ctx,
         &paymentsapi.PaymentUpdate{
             PaymentId:  paymentId,
@@ -25,11 +26,36 @@ func updatePaymentStatus(ctx context.Context, client *paymentsapi.Client, paymen
         paymentsapi.PatchPaymentParams{
             PaymentId: paymentId,
         })
-    if patchPaymentErr != nil {
-        // TODO handle error
-        return werrors.NewRetryableInternalError("failed updating payment in payments service: %s", patchPaymentErr.Error())
+    return gateway.PatchPaymentError(resp, patchPaymentErr)
+}
+
+// failPayment marks the payment as failed so the Payments service releases the customer funds.
+// PaymentUpdate has no field for the failure reason yet, it's kept in the OutboundPaymentFailed event.
+func failPayment(ctx context.Context, client *paymentsapi.Client, paymentId uuid.UUID) werrors.WError {
+    resp, err := client.PatchPayment(
+        ctx,
+        &paymentsapi.PaymentUpdate{
+            PaymentId: paymentId,
+            Status:    paymentsapi.PaymentStatusFailed,
+        },
+        paymentsapi.PatchPaymentParams{
+            PaymentId: paymentId,
+        })
+    return gateway.PatchPaymentError(resp, err)
+}
+
+// reportPaymentStatus sets the status of the payment on the Payments API.
+// The ExternalId is only set when the payment exists on DinoPay.
+func reportPaymentStatus(ctx context.Context, client *paymentsapi.Client, paymentId uuid.UUID, dinopayPaymentId uuid.UUID, status paymentsapi.PaymentStatus) werrors.WError {
+    update := &paymentsapi.PaymentUpdate{
+        PaymentId: paymentId,
+        Status:    status,
+    }
+    if dinopayPaymentId != uuid.Nil {
+        update.ExternalId = paymentsapi.NewOptString(dinopayPaymentId.String())
     }
-    return nil
+    resp, err := client.PatchPayment(ctx, update, paymentsapi.PatchPaymentParams{PaymentId: paymentId})
+    return gateway.PatchPaymentError(resp, err)
 }
 
 func dinopayStatus2PaymentsStatus(dinopayStatus string) (paymentsapi.PaymentStatus, werrors.WError) {
@@ -0,0 +1,134 @@
+package outbound
+
+import (
+    "errors"
+    "regexp"
+    "slices"
+    "strings"
+
+    "github.com/shopspring/decimal"
+    "github.com/walletera/dinopay-gateway/internal/domain/mapping"
+)
+
+// Violation is a ValidationRule broken by a payout
+type Violation struct {
+    Reason  FailureReason
+    Details string
+}
+
+// ValidationRule checks a payout before it's submitted to DinoPay.
+// It returns the violation when the payout breaks the rule.
+type ValidationRule interface {
+    Validate(payment mapping.DinopayPayment) (Violation, bool)
+}
+
+// ValidationRules validates a payout against its rules in order, the first violation wins
+type ValidationRules []ValidationRule
+
+func (rules ValidationRules) Validate(payment mapping.DinopayPayment) (Violation, bool) {
+    for _, rule := range rules {
+        violation, violated := rule.Validate(payment)
+        if violated {
+            return violation, true
+        }
+    }
+    return Violation{}, false
+}
+
+// ValidationRuleFunc adapts a function to the ValidationRule interface
+type ValidationRuleFunc func(payment mapping.DinopayPayment) (Violation, bool)
+
+func (f ValidationRuleFunc) Validate(payment mapping.DinopayPayment) (Violation, bool) {
+    return f(payment)
+}
+
+// NewCurrencyAllowlistRule rejects the payouts whose currency isn't in the given list
+func NewCurrencyAllowlistRule(currencies ...string) ValidationRule {
+    return ValidationRuleFunc(func(payment mapping.DinopayPayment) (Violation, bool) {
+        if slices.Contains(currencies, payment.Currency) {
+            return Violation{}, false
+        }
+        return Violation{
+            Reason:  FailureReasonUnsupportedCurrency,
+            Details: "currency " + payment.Currency + " is not supported, supported currencies are " + strings.Join(currencies, ", "),
+        }, true
+    })
+}
+
+// NewAmountLimitsRule rejects the payouts in the given currency whose amount is
+// out of [min, max]. A nil limit means the amount is unbounded on that side.
+func NewAmountLimitsRule(currency string, min *decimal.Decimal, max *decimal.Decimal) ValidationRule {
+    return ValidationRuleFunc(func(payment mapping.DinopayPayment) (Violation, bool) {
+        if payment.Currency != currency {
+            return Violation{}, false
+        }
+        if min != nil && payment.Amount.LessThan(*min) {
+            return Violation{
+                Reason:  FailureReasonLimitExceeded,
+                Details: "amount " + payment.Amount.String() + " " + currency + " is below the minimum of " + min.String(),
+            }, true
+        }
+        if max != nil && payment.Amount.GreaterThan(*max) {
+            return Violation{
+                Reason:  FailureReasonLimitExceeded,
+                Details: "amount " + payment.Amount.String() + " " + currency + " is above the maximum of " + max.String(),
+            }, true
+        }
+        return Violation{}, false
+    })
+}
+
+// NewAccountNumberPatternRule rejects the payouts in the given currency whose
+// source or destination account number doesn't match the pattern
+func NewAccountNumberPatternRule(currency string, pattern *regexp.Regexp) ValidationRule {
+    return ValidationRuleFunc(func(payment mapping.DinopayPayment) (Violation, bool) {
+        if payment.Currency != currency {
+            return Violation{}, false
+        }
+        accounts := []struct {
+            role    string
+            account mapping.Account
+        }{
+            {"debtor", payment.SourceAccount},
+            {"beneficiary", payment.DestinationAccount},
+        }
+        for _, candidate := range accounts {
+            if !pattern.MatchString(candidate.account.AccountNumber) {
+                return Violation{
+                    Reason:  FailureReasonInvalidAccount,
+                    Details: candidate.role + " account number " + candidate.account.AccountNumber + " is not a valid " + currency + " account number",
+                }, true
+            }
+        }
+        return Violation{}, false
+    })
+}
+
+// NewDistinctAccountsRule rejects the payouts whose debtor is also the beneficiary
+func NewDistinctAccountsRule() ValidationRule {
+    return ValidationRuleFunc(func(payment mapping.DinopayPayment) (Violation, bool) {
+        if payment.SourceAccount.AccountNumber != payment.DestinationAccount.AccountNumber {
+            return Violation{}, false
+        }
+        return Violation{
+            Reason:  FailureReasonSameAccount,
+            Details: "debtor and beneficiary are the same account " + payment.SourceAccount.AccountNumber,
+        }, true
+    })
+}
+
+// MappingViolation turns the error mapping a payout to DinoPay into a violation
+func MappingViolation(err error) Violation {
+    var reason FailureReason
+    switch {
+    case errors.Is(err, mapping.ErrUnsupportedCurrency):
+        reason = FailureReasonUnsupportedCurrency
+    case errors.Is(err, mapping.ErrInvalidAmount):
+        reason = FailureReasonInvalidAmount
+    case errors.Is(err, mapping.ErrUnsupportedAccountType):
+        reason = FailureReasonInvalidAccount
+    default:
+        reason = FailureReasonUnknown
+    }
+    return Violation{Reason: reason, Details: err.Error()}
+}
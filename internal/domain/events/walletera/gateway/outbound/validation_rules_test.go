@@ -0,0 +1,88 @@
+package outbound
+
+import (
+    "fmt"
+    "regexp"
+    "testing"
+
+    "github.com/shopspring/decimal"
+    "github.com/stretchr/testify/assert"
+    "github.com/walletera/dinopay-gateway/internal/domain/mapping"
+)
+
+func TestValidationRules_Validate(t *testing.T) {
+    minAmount := decimal.RequireFromString("1")
+    maxAmount := decimal.RequireFromString("10000")
+    rules := ValidationRules{
+        NewCurrencyAllowlistRule("USD", "ARS"),
+        NewAmountLimitsRule("USD", &minAmount, &maxAmount),
+        NewAccountNumberPatternRule("USD", regexp.MustCompile(`^[0-9]{10}$`)),
+        NewDistinctAccountsRule(),
+    }
+    payout := func(amount string, currency string, sourceAccountNumber string, destinationAccountNumber string) mapping.DinopayPayment {
+        return mapping.DinopayPayment{
+            Amount:             decimal.RequireFromString(amount),
+            Currency:           currency,
+            SourceAccount:      mapping.Account{AccountNumber: sourceAccountNumber},
+            DestinationAccount: mapping.Account{AccountNumber: destinationAccountNumber},
+        }
+    }
+    tests := []struct {
+        name           string
+        payout         mapping.DinopayPayment
+        expectedReason FailureReason
+        expectedFail   bool
+    }{
+        {
+            name:   "valid payout",
+            payout: payout("100", "USD", "1200079635", "1200079636"),
+        },
+        {
+            name:   "limits and patterns only apply to their currency",
+            payout: payout("50000", "ARS", "ARS-1", "ARS-2"),
+        },
+        {
+            name:           "unsupported currency",
+            payout:         payout("100", "EUR", "1200079635", "1200079636"),
+            expectedReason: FailureReasonUnsupportedCurrency,
+            expectedFail:   true,
+        },
+        {
+            name:           "amount below the minimum",
+            payout:         payout("0.5", "USD", "1200079635", "1200079636"),
+            expectedReason: FailureReasonLimitExceeded,
+            expectedFail:   true,
+        },
+        {
+            name:           "amount above the maximum",
+            payout:         payout("10000.01", "USD", "1200079635", "1200079636"),
+            expectedReason: FailureReasonLimitExceeded,
+            expectedFail:   true,
+        },
+        {
+            name:           "malformed beneficiary account number",
+            payout:         payout("100", "USD", "1200079635", "12-0007"),
+            expectedReason: FailureReasonInvalidAccount,
+            expectedFail:   true,
+        },
+        {
+            name:           "debtor equal to beneficiary",
+            payout:         payout("100", "USD", "1200079635", "1200079635"),
+            expectedReason: FailureReasonSameAccount,
+            expectedFail:   true,
+        },
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            violation, failed := rules.Validate(tt.payout)
+            assert.Equal(t, tt.expectedFail, failed)
+            assert.Equal(t, tt.expectedReason, violation.Reason)
+        })
+    }
+}
+
+func TestMappingViolation(t *testing.T) {
+    assert.Equal(t, FailureReasonInvalidAmount, MappingViolation(fmt.Errorf("%w: too many decimals", mapping.ErrInvalidAmount)).Reason)
+    assert.Equal(t, FailureReasonUnsupportedCurrency, MappingViolation(fmt.Errorf("%w: JPY", mapping.ErrUnsupportedCurrency)).Reason)
+    assert.Equal(t, FailureReasonInvalidAccount, MappingViolation(fmt.Errorf("%w: iban", mapping.ErrUnsupportedAccountType)).Reason)
+}
@@ -0,0 +1,74 @@
+package gateway
+
+import (
+    "errors"
+    "fmt"
+    "net/http"
+
+    "github.com/ogen-go/ogen/validate"
+    paymentsapi "github.com/walletera/payments-types/privateapi"
+    "github.com/walletera/werrors"
+)
+
+// PostPaymentError maps the result of a PostPayment call to the error the processing of the event fails with.
+// A conflict means the payment was created by a previous attempt, so it counts as a success.
+func PostPaymentError(resp paymentsapi.PostPaymentRes, err error) werrors.WError {
+    if err != nil {
+        return paymentsApiCallError("failed creating payment on payments api", err)
+    }
+    switch r := resp.(type) {
+    case *paymentsapi.Payment, *paymentsapi.PostPaymentConflict:
+        return nil
+    case *paymentsapi.PostPaymentBadRequest:
+        return werrors.NewNonRetryableInternalError(apiErrorMessage("payments api rejected the payment", r.ErrorCode, r.ErrorMessage))
+    case *paymentsapi.PostPaymentInternalServerError:
+        return werrors.NewRetryableInternalError(apiErrorMessage("payments api failed creating the payment", r.ErrorCode, r.ErrorMessage))
+    default:
+        // Unauthorized is expected to go away once the credentials are fixed
+        return werrors.NewRetryableInternalError(fmt.Sprintf("unexpected payments api response %T", resp))
+    }
+}
+
+// PatchPaymentError maps the result of a PatchPayment call to the error the processing of the event fails with
+func PatchPaymentError(resp paymentsapi.PatchPaymentRes, err error) werrors.WError {
+    if err != nil {
+        return paymentsApiCallError("failed updating payment on payments api", err)
+    }
+    switch r := resp.(type) {
+    case *paymentsapi.PatchPaymentOK:
+        return nil
+    case *paymentsapi.PatchPaymentBadRequest:
+        return werrors.NewNonRetryableInternalError(apiErrorMessage("payments api rejected the payment update", r.ErrorCode, r.ErrorMessage))
+    case *paymentsapi.PatchPaymentInternalServerError:
+        return werrors.NewRetryableInternalError(apiErrorMessage("payments api failed updating the payment", r.ErrorCode, r.ErrorMessage))
+    default:
+        return werrors.NewRetryableInternalError(fmt.Sprintf("unexpected payments api response %T", resp))
+    }
+}
+
+// IsPaymentNotFound returns whether a Payments API call failed because the payment doesn't exist
+func IsPaymentNotFound(err error) bool {
+    var statusCodeErr *validate.UnexpectedStatusCodeError
+    return errors.As(err, &statusCodeErr) && statusCodeErr.StatusCode == http.StatusNotFound
+}
+
+// paymentsApiCallError handles the status codes the payments api spec doesn't declare,
+// which the client returns as errors, and the transport errors
+func paymentsApiCallError(msg string, err error) werrors.WError {
+    var statusCodeErr *validate.UnexpectedStatusCodeError
+    if !errors.As(err, &statusCodeErr) {
+        return werrors.NewRetryableInternalError(msg + ": " + err.Error())
+    }
+    switch code := statusCodeErr.StatusCode; {
+    case code == http.StatusRequestTimeout, code == http.StatusTooManyRequests, code >= 500:
+        return werrors.NewRetryableInternalError(msg + ": " + err.Error())
+    case code >= 400:
+        return werrors.NewNonRetryableInternalError(msg + ": " + err.Error())
+    default:
+        return werrors.NewRetryableInternalError(msg + ": " + err.Error())
+    }
+}
+
+func apiErrorMessage(msg string, errorCode string, errorMessage string) string {
+    return fmt.Sprintf("%s: [%s] %s", msg, errorCode, errorMessage)
+}
@@ -0,0 +1,77 @@
+package gateway
+
+import (
+    "errors"
+    "net/http"
+    "testing"
+
+    "github.com/ogen-go/ogen/validate"
+    "github.com/stretchr/testify/require"
+    paymentsapi "github.com/walletera/payments-types/privateapi"
+)
+
+func TestPostPaymentError(t *testing.T) {
+    tests := []struct {
+        name          string
+        resp          paymentsapi.PostPaymentRes
+        err           error
+        wantErr       bool
+        wantRetryable bool
+    }{
+        {name: "created", resp: &paymentsapi.Payment{}},
+        {name: "conflict", resp: &paymentsapi.PostPaymentConflict{ErrorCode: "PaymentAlreadyExists"}},
+        {name: "bad request", resp: &paymentsapi.PostPaymentBadRequest{ErrorCode: "InvalidAmount"}, wantErr: true},
+        {name: "unauthorized", resp: &paymentsapi.PostPaymentUnauthorized{}, wantErr: true, wantRetryable: true},
+        {name: "internal server error", resp: &paymentsapi.PostPaymentInternalServerError{}, wantErr: true, wantRetryable: true},
+        {name: "not found", err: validate.UnexpectedStatusCode(http.StatusNotFound), wantErr: true},
+        {name: "too many requests", err: validate.UnexpectedStatusCode(http.StatusTooManyRequests), wantErr: true, wantRetryable: true},
+        {name: "service unavailable", err: validate.UnexpectedStatusCode(http.StatusServiceUnavailable), wantErr: true, wantRetryable: true},
+        {name: "transport error", err: errors.New("connection refused"), wantErr: true, wantRetryable: true},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            werr := PostPaymentError(tt.resp, tt.err)
+            if !tt.wantErr {
+                require.NoError(t, werr)
+                return
+            }
+            require.Error(t, werr)
+            require.Equal(t, tt.wantRetryable, werr.IsRetryable())
+        })
+    }
+}
+
+func TestPatchPaymentError(t *testing.T) {
+    tests := []struct {
+        name          string
+        resp          paymentsapi.PatchPaymentRes
+        err           error
+        wantErr       bool
+        wantRetryable bool
+    }{
+        {name: "ok", resp: &paymentsapi.PatchPaymentOK{}},
+        {name: "bad request", resp: &paymentsapi.PatchPaymentBadRequest{ErrorCode: "InvalidStatus"}, wantErr: true},
+        {name: "unauthorized", resp: &paymentsapi.PatchPaymentUnauthorized{}, wantErr: true, wantRetryable: true},
+        {name: "internal server error", resp: &paymentsapi.PatchPaymentInternalServerError{}, wantErr: true, wantRetryable: true},
+        {name: "not found", err: validate.UnexpectedStatusCode(http.StatusNotFound), wantErr: true},
+        {name: "conflict", err: validate.UnexpectedStatusCode(http.StatusConflict), wantErr: true},
+        {name: "bad gateway", err: validate.UnexpectedStatusCode(http.StatusBadGateway), wantErr: true, wantRetryable: true},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            werr := PatchPaymentError(tt.resp, tt.err)
+            if !tt.wantErr {
+                require.NoError(t, werr)
+                return
+            }
+            require.Error(t, werr)
+            require.Equal(t, tt.wantRetryable, werr.IsRetryable())
+        })
+    }
+}
+
+func TestIsPaymentNotFound(t *testing.T) {
+    require.True(t, IsPaymentNotFound(validate.UnexpectedStatusCode(http.StatusNotFound)))
+    require.False(t, IsPaymentNotFound(validate.UnexpectedStatusCode(http.StatusConflict)))
+    require.False(t, IsPaymentNotFound(errors.New("connection refused")))
+}
@@ -2,31 +2,77 @@ package payments
 
 import (
     "context"
+    "fmt"
     "log/slog"
+    "time"
 
     "github.com/google/uuid"
+    "github.com/walletera/dinopay-gateway/internal/domain/events/walletera/gateway"
     "github.com/walletera/dinopay-gateway/internal/domain/events/walletera/gateway/outbound"
+    "github.com/walletera/dinopay-gateway/internal/domain/mapping"
     "github.com/walletera/dinopay-gateway/internal/domain/ports/output/dinopay"
+    riskport "github.com/walletera/dinopay-gateway/internal/domain/ports/output/risk"
+    screeningport "github.com/walletera/dinopay-gateway/internal/domain/ports/output/screening"
+    "github.com/walletera/dinopay-gateway/internal/domain/risk"
+    "github.com/walletera/dinopay-gateway/internal/domain/screening"
+    "github.com/walletera/dinopay-gateway/internal/domain/velocity"
     "github.com/walletera/dinopay-gateway/pkg/logattr"
-    dinopayapi "github.com/walletera/dinopay/api"
+    "github.com/walletera/dinopay-gateway/pkg/wuuid"
+    "github.com/walletera/eventskit/events"
     "github.com/walletera/eventskit/eventsourcing"
     paymentEvents "github.com/walletera/payments-types/events"
+    paymentsapi "github.com/walletera/payments-types/privateapi"
     "github.com/walletera/werrors"
 )
 
+// newStream is the expected version of the outbound stream of a payment
+// handled for the first time
+var newStream = eventsourcing.ExpectedAggregateVersion{IsNew: true}
+
 type EventsHandler struct {
-    dinopayClient dinopay.Client
-    esDB          eventsourcing.DB
-    logger        *slog.Logger
+    db                  eventsourcing.DB
+    paymentsClient      *paymentsapi.Client
+    cancellationService *outbound.CancellationService
+    validationRules     outbound.ValidationRules
+    screeningService    *screening.Service
+    approvalThresholds  outbound.ApprovalThresholds
+    velocityChecker     *velocity.Checker
+    riskService         *risk.Service
+    submitter           *outbound.Submitter
+    reviewService       *outbound.ReviewService
+    approvalService     *outbound.ApprovalService
+    logger              *slog.Logger
 }
 
 var _ paymentEvents.Handler = (*EventsHandler)(nil)
 
-func NewEventsHandler(dinopayClient dinopay.Client, esDB eventsourcing.DB, logger *slog.Logger, ) *EventsHandler {
+func NewEventsHandler(
+    dinopayClient dinopay.Client,
+    esDB eventsourcing.DB,
+    paymentsClient *paymentsapi.Client,
+    cancellationService *outbound.CancellationService,
+    validationRules outbound.ValidationRules,
+    screeningService *screening.Service,
+    approvalThresholds outbound.ApprovalThresholds,
+    velocityChecker *velocity.Checker,
+    riskService *risk.Service,
+    reviewService *outbound.ReviewService,
+    approvalService *outbound.ApprovalService,
+    logger *slog.Logger,
+) *EventsHandler {
     return &EventsHandler{
-        dinopayClient: dinopayClient,
-        esDB:          esDB,
-        logger:        logger.With(logattr.Component("payments.EventsHandler")),
+        db:                  esDB,
+        paymentsClient:      paymentsClient,
+        cancellationService: cancellationService,
+        validationRules:     validationRules,
+        screeningService:    screeningService,
+        approvalThresholds:  approvalThresholds,
+        velocityChecker:     velocityChecker,
+        riskService:         riskService,
+        submitter:           outbound.NewSubmitter(esDB, dinopayClient),
+        reviewService:       reviewService,
+        approvalService:     approvalService,
+        logger:              logger.With(logattr.Component("payments.EventsHandler")),
     }
 }
 
@@ -38,78 +84,203 @@ func (ev *EventsHandler) HandlePaymentCreated(ctx context.Context, paymentCreate
         logattr.PaymentId(walleteraPaymentId.String()),
     )
     logger.Debug("handling PaymentCreated event")
-    if !paymentCreated.Data.Debtor.AccountDetails.OneOf.IsDinopayAccountDetails() {
-        return werrors.NewValidationError("invalid debtor account details type: %s", paymentCreated.Data.Beneficiary.AccountDetails.OneOf.Type)
-    }
-    if !paymentCreated.Data.Beneficiary.AccountDetails.OneOf.IsDinopayAccountDetails() {
-        return werrors.NewValidationError("invalid beneficiary account details type: %s", paymentCreated.Data.Beneficiary.AccountDetails.OneOf.Type)
-    }
-    dinopayResp, err := ev.dinopayClient.CreatePayment(ctx, &dinopayapi.Payment{
-        Amount:   paymentCreated.Data.Amount,
-        Currency: string(paymentCreated.Data.Currency),
-        SourceAccount: dinopayapi.Account{
-            AccountHolder: paymentCreated.Data.Debtor.AccountDetails.OneOf.DinopayAccountDetails.AccountHolder,
-            AccountNumber: paymentCreated.Data.Debtor.AccountDetails.OneOf.DinopayAccountDetails.AccountHolder,
-        },
-        DestinationAccount: dinopayapi.Account{
-            AccountHolder: paymentCreated.Data.Beneficiary.AccountDetails.OneOf.DinopayAccountDetails.AccountHolder,
-            AccountNumber: paymentCreated.Data.Beneficiary.AccountDetails.OneOf.DinopayAccountDetails.AccountNumber,
-        },
-        CustomerTransactionId: dinopayapi.OptString{
-            Value: walleteraPaymentId.String(),
-            Set:   true,
-        },
-    })
+    if paymentCreated.Data.Direction != paymentsapi.DirectionOutbound || paymentCreated.Data.Gateway != paymentsapi.GatewayDinopay {
+        // failing it below would fail a payment that isn't ours
+        logger.Debug("ignoring payment not sent through dinopay")
+        return nil
+    }
+    blocked, werr := ev.cancellationService.Blocked(ctx, walleteraPaymentId)
+    if werr != nil {
+        logger.Error("failed checking payment cancellation", logattr.Error(werr.Error()))
+        return werr
+    }
+    if blocked {
+        logger.Info("payment was cancelled, held or sent for approval before being submitted to dinopay")
+        return nil
+    }
+    payment, err := mapping.FromPayment(paymentCreated.Data)
     if err != nil {
-        werr := werrors.NewRetryableInternalError("failed creating payment on dinopay: %s", err.Error())
-        logger.Error(werr.Error())
+        violation := outbound.MappingViolation(err)
+        return ev.submitter.Fail(ctx, logger, walleteraPaymentId, violation.Reason, violation.Details, newStream)
+    }
+    if violation, violated := ev.validationRules.Validate(payment); violated {
+        return ev.submitter.Fail(ctx, logger, walleteraPaymentId, violation.Reason, violation.Details, newStream)
+    }
+    beneficiary := payment.DestinationAccount.AccountHolder
+    screeningResult, screeningEvents, werr := ev.screenBeneficiary(ctx, walleteraPaymentId, beneficiary)
+    if werr != nil {
+        logger.Error("failed screening payment beneficiary", logattr.Error(werr.Error()))
         return werr
     }
-    if dinopayResp == nil {
-        werr := werrors.NewRetryableInternalError("dinopay response is nil")
-        logger.Error(werr.Error())
+    // the screening result is recorded along with the event acting upon it
+    if screeningResult.Hit {
+        details := screening.Details(screening.PartyBeneficiary, beneficiary, screeningResult)
+        return ev.reviewService.Hold(ctx, walleteraPaymentId, paymentCreated.Data.CustomerId, payment, gateway.HoldReasonSanctionsHit, details, newStream, screeningEvents...)
+    }
+    if threshold, required := ev.approvalThresholds.Requires(payment); required {
+        // approved payouts are reviewed one by one, so the velocity limits
+        // and the risk scoring don't apply to them.
+        // The customer requested the payout, so it can't approve it.
+        return ev.approvalService.RequestApproval(ctx, walleteraPaymentId, paymentCreated.Data.CustomerId, payment, threshold, paymentCreated.Data.CustomerId.String(), screeningEvents...)
+    }
+    payout := velocity.Payout{
+        PaymentId:          walleteraPaymentId,
+        CustomerId:         paymentCreated.Data.CustomerId,
+        BeneficiaryAccount: payment.DestinationAccount.AccountNumber,
+        Amount:             payment.Amount,
+        Currency:           payment.Currency,
+    }
+    submission, werr := outbound.LoadSubmission(ctx, ev.db, walleteraPaymentId)
+    if werr != nil {
+        logger.Error("failed loading payment submission", logattr.Error(werr.Error()))
         return werr
     }
-    dinopayPayment, ok := dinopayResp.(*dinopayapi.Payment)
-    if !ok {
-        werr := werrors.NewNonRetryableInternalError("unexpected dinopay response type %t:", dinopayResp)
-        logger.Error(werr.Error())
+    if submission.Submitting {
+        // a previous delivery passed the checks below, the submission continues
+        // from what it recorded and the payout is recorded if it wasn't yet
+        return ev.submit(ctx, logger, payout, payment, newStream)
+    }
+    breach, werr := ev.velocityChecker.Check(ctx, payout)
+    if werr != nil {
+        logger.Error("failed checking velocity limits", logattr.Error(werr.Error()))
         return werr
     }
-
-    logger.Info("dinopay dinopayPayment created successfully")
-
-    outboundPaymentCreated := outbound.PaymentCreated{
-        Id:                   uuid.New(),
-        PaymentId:            walleteraPaymentId,
-        DinopayPaymentId:     dinopayPayment.ID.Value,
-        DinopayPaymentStatus: string(dinopayPayment.Status.Value),
+    if breach != nil {
+        // held payouts don't count towards the limits nor are scored,
+        // they're sent only if an operator releases them
+        return ev.reviewService.Hold(ctx, walleteraPaymentId, paymentCreated.Data.CustomerId, payment, gateway.HoldReasonVelocityLimit, breach.Details(), newStream, screeningEvents...)
     }
+    decision, werr := ev.riskService.Assess(ctx, riskport.Payment{
+        Id:           walleteraPaymentId.String(),
+        Direction:    riskport.DirectionOutbound,
+        CustomerId:   paymentCreated.Data.CustomerId,
+        Amount:       payment.Amount,
+        Currency:     payment.Currency,
+        Counterparty: payment.DestinationAccount,
+    })
+    if werr != nil {
+        logger.Error("failed assessing payment risk", logattr.Error(werr.Error()))
+        return werr
+    }
+    version := newStream
+    if decision != nil {
+        var pending bool
+        version, pending, werr = outbound.RecordRiskAssessment(ctx, ev.db, walleteraPaymentId, paymentCreated.Data.CustomerId, *decision, screeningEvents...)
+        if werr != nil {
+            logger.Error("failed recording payment risk assessment", logattr.Error(werr.Error()))
+            return werr
+        }
+        if !pending {
+            logger.Info("payment risk decision was already acted upon")
+            return nil
+        }
+        // the screening result was recorded along with the decision
+        screeningEvents = nil
+        switch decision.Outcome {
+        case riskport.OutcomeBlock:
+            return ev.submitter.Fail(ctx, logger, walleteraPaymentId, outbound.FailureReasonRiskBlocked, risk.Details(*decision), version)
+        case riskport.OutcomeReview:
+            return ev.reviewService.Hold(ctx, walleteraPaymentId, paymentCreated.Data.CustomerId, payment, gateway.HoldReasonRiskReview, risk.Details(*decision), version)
+        }
+    }
+    return ev.submit(ctx, logger, payout, payment, version, screeningEvents...)
+}
 
-    streamName := outbound.BuildOutboundPaymentStreamName(dinopayPayment.ID.Value.String())
+// screenBeneficiary returns the screening result a previous attempt recorded on the
+// payment, so that a list update doesn't change the decision made on a redelivered
+// event. Otherwise it screens the beneficiary and returns the event recording the
+// result, to be appended along with the event acting upon it.
+func (ev *EventsHandler) screenBeneficiary(ctx context.Context, paymentId uuid.UUID, beneficiary string) (screeningport.Result, []events.EventData, werrors.WError) {
+    recorded, werr := outbound.LoadScreening(ctx, ev.db, paymentId, screening.PartyBeneficiary)
+    if werr != nil {
+        return screeningport.Result{}, nil, werr
+    }
+    if recorded != nil {
+        return recorded.Result, nil, nil
+    }
+    result, werr := ev.screeningService.Screen(ctx, paymentId.String(), screening.PartyBeneficiary, beneficiary)
+    if werr != nil || result == nil {
+        return screeningport.Result{}, nil, werr
+    }
+    return *result, []events.EventData{outbound.PaymentScreened{
+        Id:             wuuid.NewUUID(),
+        PaymentId:      paymentId,
+        Party:          screening.PartyBeneficiary,
+        Name:           beneficiary,
+        Result:         *result,
+        EventCreatedAt: time.Now().UnixMilli(),
+    }}, nil
+}
 
-    _, appendEventsErr := ev.esDB.AppendEvents(
-        ctx,
-        streamName,
-        eventsourcing.ExpectedAggregateVersion{IsNew: true},
-        outboundPaymentCreated,
-    )
-    if appendEventsErr != nil {
-        werr := werrors.NewWrappedError(
-            appendEventsErr,
-            "failed appending outbound PaymentCreated event",
-            streamName,
-        )
-        logger.Error(werr.Error())
+// submit submits the payout and, once it is on DinoPay or may be, counts it
+// towards the velocity limits. A failed record fails the event, so the
+// redelivered event records it.
+func (ev *EventsHandler) submit(
+    ctx context.Context,
+    logger *slog.Logger,
+    payout velocity.Payout,
+    payment mapping.DinopayPayment,
+    version eventsourcing.ExpectedAggregateVersion,
+    preceding ...events.EventData,
+) werrors.WError {
+    werr := ev.submitter.Submit(ctx, logger, payout.PaymentId, payment, version, preceding...)
+    if werr != nil {
+        return werr
+    }
+    submission, werr := outbound.LoadSubmission(ctx, ev.db, payout.PaymentId)
+    if werr != nil {
+        logger.Error("failed loading payment submission", logattr.Error(werr.Error()))
         return werr
     }
+    if submission.DinopayPaymentId != uuid.Nil || submission.OutcomeUnknown {
+        werr = ev.velocityChecker.Record(ctx, payout)
+        if werr != nil {
+            logger.Error("failed recording payout velocity", logattr.Error(werr.Error()))
+            return werr
+        }
+    }
 
     logger.Info("PaymentCreated event processed successfully")
 
     return nil
 }
 
-func (ev *EventsHandler) HandlePaymentUpdated(_ context.Context, _ paymentEvents.PaymentUpdated) werrors.WError {
-    // Ignore, nothing to do
+// HandlePaymentUpdated handles the cancellations of DinoPay withdrawals.
+// The gateway never reports a withdrawal as rejected, so a rejected withdrawal
+// was cancelled by the Payments service or the customer. Any other update is
+// the gateway's own update coming back, so it's ignored.
+func (ev *EventsHandler) HandlePaymentUpdated(ctx context.Context, paymentUpdated paymentEvents.PaymentUpdated) werrors.WError {
+    if paymentUpdated.Data.Status != paymentsapi.PaymentStatusRejected {
+        return nil
+    }
+    paymentId := paymentUpdated.Data.PaymentId
+    logger := ev.logger.With(
+        logattr.CorrelationId(paymentUpdated.CorrelationID()),
+        logattr.EventType(paymentUpdated.Type()),
+        logattr.PaymentId(paymentId.String()),
+    )
+    logger.Debug("handling PaymentUpdated event")
+    resp, err := ev.paymentsClient.GetPayment(ctx, paymentsapi.GetPaymentParams{PaymentId: paymentId})
+    if err != nil {
+        werr := werrors.NewRetryableInternalError("failed getting payment from payments api: " + err.Error())
+        logger.Error(werr.Error())
+        return werr
+    }
+    payment, ok := resp.(*paymentsapi.Payment)
+    if !ok {
+        werr := werrors.NewRetryableInternalError(fmt.Sprintf("unexpected payments api response %T", resp))
+        logger.Error(werr.Error())
+        return werr
+    }
+    if payment.Direction != paymentsapi.DirectionOutbound || payment.Gateway != paymentsapi.GatewayDinopay {
+        return nil
+    }
+    werr := ev.cancellationService.Cancel(ctx, paymentId)
+    if werr != nil {
+        werr = werrors.NewWrappedError(werr, "failed cancelling payment")
+        logger.Error(werr.Error())
+        return werr
+    }
+    logger.Info("PaymentUpdated event processed successfully")
     return nil
 }
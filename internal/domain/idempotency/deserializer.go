@@ -0,0 +1,41 @@
+package idempotency
+
+import (
+    "context"
+
+    "github.com/walletera/eventskit/events"
+    "github.com/walletera/werrors"
+)
+
+// Deserializer decorates the deserializer of a message processor so that the
+// events it deserializes are processed through the guard
+type Deserializer[Handler any] struct {
+    deserializer events.Deserializer[Handler]
+    guard        *Guard
+}
+
+func NewDeserializer[Handler any](deserializer events.Deserializer[Handler], guard *Guard) *Deserializer[Handler] {
+    return &Deserializer[Handler]{
+        deserializer: deserializer,
+        guard:        guard,
+    }
+}
+
+func (d *Deserializer[Handler]) Deserialize(rawEvent []byte) (events.Event[Handler], error) {
+    event, err := d.deserializer.Deserialize(rawEvent)
+    if err != nil || event == nil {
+        return event, err
+    }
+    return guardedEvent[Handler]{Event: event, guard: d.guard}, nil
+}
+
+type guardedEvent[Handler any] struct {
+    events.Event[Handler]
+    guard *Guard
+}
+
+func (e guardedEvent[Handler]) Accept(ctx context.Context, handler Handler) werrors.WError {
+    return e.guard.Process(ctx, e.ID(), func(ctx context.Context) werrors.WError {
+        return e.Event.Accept(ctx, handler)
+    })
+}
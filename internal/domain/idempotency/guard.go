@@ -0,0 +1,202 @@
+// Package idempotency makes the processing of events idempotent by event id.
+// Every consumer records the ids of the events it processed in the event
+// store, and the duplicates of an event it already processed are skipped.
+package idempotency
+
+import (
+    "context"
+    "log/slog"
+    "strings"
+    "time"
+
+    "github.com/google/uuid"
+    "github.com/walletera/dinopay-gateway/internal/domain/events/walletera/gateway"
+    "github.com/walletera/dinopay-gateway/pkg/logattr"
+    "github.com/walletera/eventskit/eventsourcing"
+    "github.com/walletera/werrors"
+)
+
+const (
+    // DefaultRetention is how long a processed event id is remembered
+    DefaultRetention = 7 * 24 * time.Hour
+    // DefaultClaimTTL outlives the processing timeout of the message processors,
+    // so an event is only claimed again when the consumer processing it went away
+    DefaultClaimTTL = 15 * time.Minute
+)
+
+// StreamExpirer is implemented by the event stores that drop
+// the events of a stream once they are older than maxAge
+type StreamExpirer interface {
+    SetStreamMaxAge(ctx context.Context, streamName string, maxAge time.Duration) werrors.WError
+}
+
+// Guard runs the processing of every event at most once per consumer.
+//
+// Before processing an event the consumer claims its id, appending to the
+// stream of the event with optimistic concurrency, so when two duplicates are
+// processed concurrently only one of them wins the claim. The other one fails
+// with a retryable error and, once redelivered, is skipped as a duplicate.
+// A failed processing releases the claim so the event can be retried, and a
+// claim that was neither released nor completed expires after the claim ttl.
+//
+// The processed ids are remembered for the retention window, a duplicate
+// delivered after it is processed again. When the event store is a
+// StreamExpirer the records are dropped once they can't be live anymore,
+// otherwise they must be purged, see Purger.
+type Guard struct {
+    db        eventsourcing.DB
+    consumer  string
+    retention time.Duration
+    claimTTL  time.Duration
+    now       func() time.Time
+    logger    *slog.Logger
+}
+
+type Opt func(g *Guard)
+
+// WithRetention sets how long the processed event ids are remembered
+func WithRetention(retention time.Duration) Opt {
+    return func(g *Guard) { g.retention = retention }
+}
+
+// WithClaimTTL sets how long a claim lasts when its processing never finishes
+func WithClaimTTL(ttl time.Duration) Opt {
+    return func(g *Guard) { g.claimTTL = ttl }
+}
+
+// WithClock replaces time.Now, mostly for tests
+func WithClock(now func() time.Time) Opt {
+    return func(g *Guard) { g.now = now }
+}
+
+// NewGuard returns the guard of consumer, the consumers of the same events
+// must use different names to process each of them once
+func NewGuard(db eventsourcing.DB, consumer string, logger *slog.Logger, opts ...Opt) *Guard {
+    g := &Guard{
+        db:        db,
+        consumer:  consumer,
+        retention: DefaultRetention,
+        claimTTL:  DefaultClaimTTL,
+        now:       time.Now,
+        logger:    logger.With(logattr.Component("idempotency.Guard"), slog.String("consumer", consumer)),
+    }
+    for _, opt := range opts {
+        opt(g)
+    }
+    return g
+}
+
+// Process runs process unless the event was already processed, in which case it returns nil
+func (g *Guard) Process(ctx context.Context, eventId string, process func(ctx context.Context) werrors.WError) werrors.WError {
+    if eventId == "" || strings.HasSuffix(eventId, uuid.Nil.String()) {
+        // the event has no id to tell its duplicates apart
+        return process(ctx)
+    }
+    streamName := gateway.BuildProcessedEventStreamName(g.consumer, eventId)
+    version, outcome, werr := g.claim(ctx, streamName, eventId)
+    if werr != nil {
+        return werr
+    }
+    switch outcome {
+    case claimAlreadyProcessed:
+        g.logger.Info("skipping already processed event", slog.String("event_id", eventId))
+        return nil
+    case claimExpired:
+        // like any duplicate delivered after the retention window
+        g.logger.Info("processing again event whose records expired", slog.String("event_id", eventId))
+        return process(ctx)
+    }
+
+    werr = process(ctx)
+    if werr != nil {
+        _, releaseErr := g.save(ctx, streamName, eventId, eventsourcing.ExpectedAggregateVersion{Version: version}, StatusReleased, 0)
+        if releaseErr != nil {
+            // the claim expires anyway
+            g.logger.Error("failed releasing event claim", slog.String("event_id", eventId), logattr.Error(releaseErr.Error()))
+        }
+        return werr
+    }
+
+    _, saveErr := g.save(ctx, streamName, eventId, eventsourcing.ExpectedAggregateVersion{Version: version}, StatusProcessed, g.now().Add(g.retention).UnixMilli())
+    if saveErr != nil {
+        // the event was processed, failing it now would process it again
+        g.logger.Error("failed recording processed event", slog.String("event_id", eventId), logattr.Error(saveErr.Error()))
+    }
+    return nil
+}
+
+type claimOutcome int
+
+const (
+    claimClaimed claimOutcome = iota
+    claimAlreadyProcessed
+    // claimExpired is for the streams whose records were all dropped by the
+    // event store, the stream still exists so it can't be claimed again
+    claimExpired
+)
+
+// claim records the claim of the event and returns the version of the stream with it,
+// or why the event was not claimed
+func (g *Guard) claim(ctx context.Context, streamName string, eventId string) (uint64, claimOutcome, werrors.WError) {
+    expectedVersion := eventsourcing.ExpectedAggregateVersion{IsNew: true}
+    retrievedEvents, werr := g.db.ReadEvents(ctx, streamName)
+    if werr != nil && werr.Code() != werrors.ResourceNotFoundErrorCode {
+        return 0, claimClaimed, werrors.NewWrappedError(werr, "failed reading processed event")
+    }
+    if werr == nil && len(retrievedEvents) == 0 {
+        return 0, claimExpired, nil
+    }
+    if len(retrievedEvents) > 0 {
+        last := retrievedEvents[len(retrievedEvents)-1]
+        record, err := deserialize(last.RawEvent)
+        if err != nil {
+            return 0, claimClaimed, werrors.NewNonRetryableInternalError("failed deserializing processed event record: " + err.Error())
+        }
+        live := g.now().Before(time.UnixMilli(record.ExpiresAt))
+        switch {
+        case record.Status == StatusProcessed && live:
+            return 0, claimAlreadyProcessed, nil
+        case record.Status == StatusClaimed && live:
+            return 0, claimClaimed, werrors.NewRetryableInternalError("event " + eventId + " is being processed")
+        }
+        expectedVersion = eventsourcing.ExpectedAggregateVersion{Version: last.AggregateVersion}
+    }
+    version, werr := g.save(ctx, streamName, eventId, expectedVersion, StatusClaimed, g.now().Add(g.claimTTL).UnixMilli())
+    if werr != nil {
+        switch werr.Code() {
+        case werrors.ResourceAlreadyExistErrorCode, werrors.WrongResourceVersionErrorCode:
+            return 0, claimClaimed, werrors.NewRetryableInternalError("event " + eventId + " was claimed concurrently")
+        }
+        return 0, claimClaimed, werrors.NewWrappedError(werr, "failed claiming event")
+    }
+    if expectedVersion.IsNew {
+        g.expire(ctx, streamName, eventId)
+    }
+    return version, claimClaimed, nil
+}
+
+// expire makes the event store drop the records of the stream once they can't be live
+// anymore, which is the retention of a processed record or the ttl of a claim, and
+// never before the later of them
+func (g *Guard) expire(ctx context.Context, streamName string, eventId string) {
+    expirer, ok := g.db.(StreamExpirer)
+    if !ok {
+        return
+    }
+    werr := expirer.SetStreamMaxAge(ctx, streamName, g.retention+g.claimTTL)
+    if werr != nil {
+        // the record is still honored, it's only kept longer than needed
+        g.logger.Warn("failed setting processed event max age", slog.String("event_id", eventId), logattr.Error(werr.Error()))
+    }
+}
+
+func (g *Guard) save(ctx context.Context, streamName string, eventId string, expectedVersion eventsourcing.ExpectedAggregateVersion, status Status, expiresAt int64) (uint64, werrors.WError) {
+    return g.db.AppendEvents(ctx, streamName, expectedVersion, RecordSaved{
+        Id:             uuid.New(),
+        Consumer:       g.consumer,
+        EventId:        eventId,
+        Status:         status,
+        ExpiresAt:      expiresAt,
+        EventCreatedAt: g.now().UnixMilli(),
+    })
+}
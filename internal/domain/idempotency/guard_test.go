@@ -0,0 +1,103 @@
+package idempotency
+
+import (
+    "context"
+    "log/slog"
+    "testing"
+    "time"
+
+    "github.com/google/uuid"
+    "github.com/stretchr/testify/require"
+    "github.com/walletera/dinopay-gateway/internal/domain/events/walletera/gateway"
+    "github.com/walletera/dinopay-gateway/internal/testutil"
+    "github.com/walletera/werrors"
+)
+
+type counter struct {
+    calls int
+    err   werrors.WError
+}
+
+func (c *counter) process(_ context.Context) werrors.WError {
+    c.calls++
+    return c.err
+}
+
+func TestGuard_Process(t *testing.T) {
+    ctx := context.Background()
+    now := time.Now()
+    db := testutil.NewFakeDB()
+    guard := NewGuard(db, "payments", slog.New(slog.DiscardHandler), WithClock(func() time.Time { return now }))
+    eventId := "PaymentCreated-" + uuid.NewString()
+
+    failing := &counter{err: werrors.NewRetryableInternalError("payments api is down")}
+    require.Error(t, guard.Process(ctx, eventId, failing.process))
+
+    handler := &counter{}
+    require.NoError(t, guard.Process(ctx, eventId, handler.process), "a failed event must be processed again")
+    require.NoError(t, guard.Process(ctx, eventId, handler.process))
+    require.Equal(t, 1, handler.calls, "a duplicate must be skipped")
+
+    otherGuard := NewGuard(db, "integration", slog.New(slog.DiscardHandler))
+    require.NoError(t, otherGuard.Process(ctx, eventId, handler.process))
+    require.Equal(t, 2, handler.calls, "the consumers are deduplicated separately")
+
+    now = now.Add(DefaultRetention + time.Minute)
+    require.NoError(t, guard.Process(ctx, eventId, handler.process))
+    require.Equal(t, 3, handler.calls, "the event ids are forgotten after the retention window")
+}
+
+func TestGuard_ConcurrentDuplicates(t *testing.T) {
+    ctx := context.Background()
+    now := time.Now()
+    db := testutil.NewFakeDB()
+    guard := NewGuard(db, "payments", slog.New(slog.DiscardHandler), WithClock(func() time.Time { return now }))
+    eventId := uuid.NewString()
+
+    duplicate := &counter{}
+    var werr werrors.WError
+    require.NoError(t, guard.Process(ctx, eventId, func(ctx context.Context) werrors.WError {
+        werr = guard.Process(ctx, eventId, duplicate.process)
+        return nil
+    }))
+    require.Error(t, werr)
+    require.True(t, werr.IsRetryable(), "a duplicate processed concurrently must be redelivered")
+    require.Equal(t, 0, duplicate.calls)
+
+    require.NoError(t, guard.Process(ctx, eventId, duplicate.process))
+    require.Equal(t, 0, duplicate.calls, "the redelivered duplicate must be skipped")
+}
+
+func TestGuard_ExpiredClaim(t *testing.T) {
+    ctx := context.Background()
+    now := time.Now()
+    db := testutil.NewFakeDB()
+    guard := NewGuard(db, "payments", slog.New(slog.DiscardHandler), WithClock(func() time.Time { return now }))
+    eventId := uuid.NewString()
+
+    _, _, werr := guard.claim(ctx, gateway.BuildProcessedEventStreamName("payments", eventId), eventId)
+    require.NoError(t, werr)
+
+    handler := &counter{}
+    require.Error(t, guard.Process(ctx, eventId, handler.process))
+    now = now.Add(DefaultClaimTTL + time.Second)
+    require.NoError(t, guard.Process(ctx, eventId, handler.process), "the claim of a consumer that went away must expire")
+    require.Equal(t, 1, handler.calls)
+}
+
+func TestGuard_ExpiringRecords(t *testing.T) {
+    ctx := context.Background()
+    db := testutil.NewFakeDB()
+    guard := NewGuard(db, "payments", slog.New(slog.DiscardHandler))
+    eventId := uuid.NewString()
+    streamName := gateway.BuildProcessedEventStreamName("payments", eventId)
+    handler := &counter{}
+
+    require.NoError(t, guard.Process(ctx, eventId, handler.process))
+    require.Equal(t, DefaultRetention+DefaultClaimTTL, db.StreamMaxAge(streamName))
+
+    // the event store dropped every record, the stream reads as empty
+    require.NoError(t, db.SetStreamMaxCount(ctx, streamName, 0))
+    require.NoError(t, guard.Process(ctx, eventId, handler.process))
+    require.Equal(t, 2, handler.calls, "an event whose records expired must be processed again")
+}
@@ -0,0 +1,109 @@
+package idempotency
+
+import (
+    "context"
+    "log/slog"
+    "time"
+
+    "github.com/walletera/dinopay-gateway/internal/domain/events/walletera/gateway"
+    "github.com/walletera/dinopay-gateway/pkg/logattr"
+    "github.com/walletera/werrors"
+)
+
+// DefaultPurgeInterval is how often the Purger drops the expired records
+const DefaultPurgeInterval = time.Hour
+
+// CategoryPurger is implemented by the event stores that can delete
+// the events of a category created before a given time
+type CategoryPurger interface {
+    PurgeCategory(ctx context.Context, category string, createdBefore time.Time) (int64, werrors.WError)
+}
+
+// Lease makes sure the purge runs on a single replica at a time
+type Lease interface {
+    Acquire(ctx context.Context) (bool, werrors.WError)
+}
+
+// Purger deletes the processed event records that can't be live anymore from the
+// event stores that are not StreamExpirers, which would otherwise keep them forever.
+// A record is deleted once it is older than the retention plus the claim ttl, the
+// same max age the Guard sets on the streams of the event stores that expire them.
+type Purger struct {
+    db        CategoryPurger
+    lease     Lease
+    retention time.Duration
+    claimTTL  time.Duration
+    interval  time.Duration
+    now       func() time.Time
+    logger    *slog.Logger
+}
+
+type PurgerOpt func(p *Purger)
+
+// WithPurgeRetention sets how long the processed event ids are remembered,
+// it must be the retention of the guards
+func WithPurgeRetention(retention time.Duration) PurgerOpt {
+    return func(p *Purger) { p.retention = retention }
+}
+
+// WithPurgeInterval sets how often the expired records are deleted
+func WithPurgeInterval(interval time.Duration) PurgerOpt {
+    return func(p *Purger) { p.interval = interval }
+}
+
+// WithPurgeClock replaces time.Now, mostly for tests
+func WithPurgeClock(now func() time.Time) PurgerOpt {
+    return func(p *Purger) { p.now = now }
+}
+
+func NewPurger(db CategoryPurger, lease Lease, logger *slog.Logger, opts ...PurgerOpt) *Purger {
+    p := &Purger{
+        db:        db,
+        lease:     lease,
+        retention: DefaultRetention,
+        claimTTL:  DefaultClaimTTL,
+        interval:  DefaultPurgeInterval,
+        now:       time.Now,
+        logger:    logger.With(logattr.Component("idempotency.Purger")),
+    }
+    for _, opt := range opts {
+        opt(p)
+    }
+    return p
+}
+
+// Run purges the expired records every interval until ctx is done
+func (p *Purger) Run(ctx context.Context) {
+    ticker := time.NewTicker(p.interval)
+    defer ticker.Stop()
+    for {
+        select {
+        case <-ctx.Done():
+            return
+        case <-ticker.C:
+            werr := p.Purge(ctx)
+            if werr != nil {
+                p.logger.Error("failed purging processed event records", logattr.Error(werr.Error()))
+            }
+        }
+    }
+}
+
+// Purge deletes the expired records of every consumer.
+// It does nothing when another replica holds the lease.
+func (p *Purger) Purge(ctx context.Context) werrors.WError {
+    acquired, werr := p.lease.Acquire(ctx)
+    if werr != nil {
+        return werrors.NewWrappedError(werr, "failed acquiring idempotency purger lease")
+    }
+    if !acquired {
+        p.logger.Debug("idempotency purger lease is held by another replica")
+        return nil
+    }
+    purged, werr := p.db.PurgeCategory(ctx, gateway.ProcessedEventStreamNamePrefix, p.now().Add(-(p.retention + p.claimTTL)))
+    if werr != nil {
+        return werrors.NewWrappedError(werr, "failed purging processed event records")
+    }
+    p.logger.Info("processed event records purged", slog.Int64("purged", purged))
+    return nil
+}
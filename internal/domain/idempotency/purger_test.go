@@ -0,0 +1,47 @@
+package idempotency
+
+import (
+    "context"
+    "log/slog"
+    "testing"
+    "time"
+
+    "github.com/stretchr/testify/require"
+    "github.com/walletera/dinopay-gateway/internal/domain/events/walletera/gateway"
+    "github.com/walletera/werrors"
+)
+
+type fakeCategoryPurger struct {
+    category      string
+    createdBefore time.Time
+}
+
+func (f *fakeCategoryPurger) PurgeCategory(_ context.Context, category string, createdBefore time.Time) (int64, werrors.WError) {
+    f.category = category
+    f.createdBefore = createdBefore
+    return 0, nil
+}
+
+type fakeLease struct {
+    acquired bool
+}
+
+func (f fakeLease) Acquire(_ context.Context) (bool, werrors.WError) {
+    return f.acquired, nil
+}
+
+func TestPurger_Purge(t *testing.T) {
+    now := time.Now()
+    clock := WithPurgeClock(func() time.Time { return now })
+
+    db := &fakeCategoryPurger{}
+    purger := NewPurger(db, fakeLease{acquired: true}, slog.New(slog.DiscardHandler), WithPurgeRetention(time.Hour), clock)
+    require.NoError(t, purger.Purge(context.Background()))
+    require.Equal(t, gateway.ProcessedEventStreamNamePrefix, db.category)
+    require.Equal(t, now.Add(-time.Hour-DefaultClaimTTL), db.createdBefore)
+
+    db = &fakeCategoryPurger{}
+    purger = NewPurger(db, fakeLease{}, slog.New(slog.DiscardHandler), clock)
+    require.NoError(t, purger.Purge(context.Background()))
+    require.Empty(t, db.category, "only the replica holding the lease purges")
+}
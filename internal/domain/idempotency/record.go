@@ -0,0 +1,82 @@
+package idempotency
+
+import (
+    "encoding/json"
+    "fmt"
+    "time"
+
+    "github.com/google/uuid"
+    "github.com/walletera/dinopay-gateway/internal/domain/events/walletera/gateway"
+    "github.com/walletera/eventskit/events"
+)
+
+type Status string
+
+const (
+    StatusClaimed   Status = "claimed"
+    StatusProcessed Status = "processed"
+    StatusReleased  Status = "released"
+)
+
+var _ events.EventData = RecordSaved{}
+
+// RecordSaved is appended to the stream of a processed event every time
+// a consumer claims it, finishes processing it or gives it up
+type RecordSaved struct {
+    Id             uuid.UUID `json:"id"`
+    Consumer       string    `json:"consumer"`
+    EventId        string    `json:"event_id"`
+    Status         Status    `json:"status"`
+    ExpiresAt      int64     `json:"expires_at"`
+    EventCreatedAt int64     `json:"created_at"`
+}
+
+func (r RecordSaved) ID() string {
+    return fmt.Sprintf("%s-%s", r.Type(), r.Id)
+}
+
+func (r RecordSaved) Type() string {
+    return "IdempotencyRecordSaved"
+}
+
+func (r RecordSaved) DataContentType() string {
+    return "application/json"
+}
+
+func (r RecordSaved) CorrelationID() string {
+    return ""
+}
+
+func (r RecordSaved) AggregateVersion() uint64 {
+    return 0
+}
+
+func (r RecordSaved) CreatedAt() time.Time {
+    return time.UnixMilli(r.EventCreatedAt)
+}
+
+func (r RecordSaved) Serialize() ([]byte, error) {
+    data, err := json.Marshal(r)
+    if err != nil {
+        return nil, fmt.Errorf("failed serializing IdempotencyRecordSaved event: %w", err)
+    }
+    envelope := gateway.EventEnvelope{
+        Type: "IdempotencyRecordSaved",
+        Data: data,
+    }
+    return json.Marshal(envelope)
+}
+
+func deserialize(rawEvent []byte) (RecordSaved, error) {
+    var envelope gateway.EventEnvelope
+    err := json.Unmarshal(rawEvent, &envelope)
+    if err != nil {
+        return RecordSaved{}, err
+    }
+    if envelope.Type != "IdempotencyRecordSaved" {
+        return RecordSaved{}, fmt.Errorf("unexpected event type: %s", envelope.Type)
+    }
+    var record RecordSaved
+    err = json.Unmarshal(envelope.Data, &record)
+    return record, err
+}
@@ -0,0 +1,113 @@
+// Package integration publishes the lifecycle of the DinoPay deposits and
+// payouts as integration events, so other services can react to them without
+// polling the gateway. The events are fed from the category subscriptions of
+// the gateway streams, so an event is only acknowledged once it's published.
+package integration
+
+import (
+    "encoding/json"
+    "fmt"
+    "time"
+
+    "github.com/google/uuid"
+    "github.com/shopspring/decimal"
+    "github.com/walletera/eventskit/events"
+)
+
+// SchemaVersion is increased whenever a change of the events
+// breaks the consumers, which then route on the version
+const SchemaVersion = 1
+
+const Source = "dinopay-gateway"
+
+// The event types, also used as the routing keys of the events
+const (
+    PayoutSubmitted  = "dinopay.payout.submitted"
+    PayoutConfirmed  = "dinopay.payout.confirmed"
+    PayoutRejected   = "dinopay.payout.rejected"
+    PayoutFailed     = "dinopay.payout.failed"
+    PayoutCancelled  = "dinopay.payout.cancelled"
+    PayoutHeld       = "dinopay.payout.held"
+    DepositReceived  = "dinopay.deposit.received"
+    DepositUnmatched = "dinopay.deposit.unmatched"
+    DepositHeld      = "dinopay.deposit.held"
+    DepositRejected  = "dinopay.deposit.rejected"
+    DepositReturned  = "dinopay.deposit.returned"
+)
+
+var _ events.EventData = Event{}
+
+// Event is the envelope of every integration event. Its id is the id of the
+// gateway event it was published for, so consumers can drop the duplicates
+// of the at-least-once delivery.
+type Event struct {
+    Id            string    `json:"id"`
+    EventType     string    `json:"type"`
+    SchemaVersion int       `json:"version"`
+    Source        string    `json:"source"`
+    OccurredAt    time.Time `json:"occurredAt"`
+    Data          any       `json:"data"`
+}
+
+// PayoutData is the data of the dinopay.payout events
+type PayoutData struct {
+    PaymentId            uuid.UUID `json:"paymentId"`
+    DinopayPaymentId     uuid.UUID `json:"dinopayPaymentId,omitzero"`
+    DinopayPaymentStatus string    `json:"dinopayPaymentStatus,omitempty"`
+    Reason               string    `json:"reason,omitempty"`
+    Details              string    `json:"details,omitempty"`
+}
+
+// DepositData is the data of the dinopay.deposit events
+type DepositData struct {
+    DinopayPaymentId       uuid.UUID       `json:"dinopayPaymentId"`
+    PaymentId              uuid.UUID       `json:"paymentId,omitzero"`
+    CustomerId             uuid.UUID       `json:"customerId,omitzero"`
+    Amount                 decimal.Decimal `json:"amount"`
+    Currency               string          `json:"currency"`
+    Reason                 string          `json:"reason,omitempty"`
+    DinopayReturnPaymentId uuid.UUID       `json:"dinopayReturnPaymentId,omitzero"`
+}
+
+func newEvent(id uuid.UUID, eventType string, occurredAt time.Time, data any) Event {
+    return Event{
+        Id:            id.String(),
+        EventType:     eventType,
+        SchemaVersion: SchemaVersion,
+        Source:        Source,
+        OccurredAt:    occurredAt,
+        Data:          data,
+    }
+}
+
+func (e Event) ID() string {
+    return e.Id
+}
+
+func (e Event) Type() string {
+    return e.EventType
+}
+
+func (e Event) AggregateVersion() uint64 {
+    return 0
+}
+
+func (e Event) CorrelationID() string {
+    return ""
+}
+
+func (e Event) DataContentType() string {
+    return "application/json"
+}
+
+func (e Event) CreatedAt() time.Time {
+    return e.OccurredAt
+}
+
+func (e Event) Serialize() ([]byte, error) {
+    data, err := json.Marshal(e)
+    if err != nil {
+        return nil, fmt.Errorf("failed serializing %s integration event: %w", e.EventType, err)
+    }
+    return data, nil
+}
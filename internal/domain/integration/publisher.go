@@ -0,0 +1,253 @@
+package integration
+
+import (
+    "context"
+    "log/slog"
+
+    "github.com/walletera/dinopay-gateway/internal/domain/events/walletera/gateway/inbound"
+    "github.com/walletera/dinopay-gateway/internal/domain/events/walletera/gateway/outbound"
+    "github.com/walletera/dinopay-gateway/pkg/logattr"
+    dinopayapi "github.com/walletera/dinopay/api"
+    "github.com/walletera/eventskit/events"
+    "github.com/walletera/eventskit/eventsourcing"
+    "github.com/walletera/werrors"
+)
+
+var (
+    _ outbound.EventsHandler = (*Publisher)(nil)
+    _ inbound.EventsHandler  = (*Publisher)(nil)
+)
+
+// Publisher handles the gateway events of the outboundPayment and
+// inboundPayment categories and publishes the integration event of
+// the ones that change the status of a payment. The other gateway
+// events are internal to the gateway and publish nothing.
+//
+// A failed publish is retryable, so the gateway event is redelivered
+// until its integration event is published. The gateway events are
+// handled concurrently, consumers must not rely on the publishing order.
+type Publisher struct {
+    db        eventsourcing.DB
+    publisher events.Publisher
+    exchange  string
+    logger    *slog.Logger
+}
+
+func NewPublisher(db eventsourcing.DB, publisher events.Publisher, exchange string, logger *slog.Logger) *Publisher {
+    return &Publisher{
+        db:        db,
+        publisher: publisher,
+        exchange:  exchange,
+        logger:    logger.With(logattr.Component("integration.Publisher")),
+    }
+}
+
+func (p *Publisher) HandleOutboundPaymentCreated(ctx context.Context, paymentCreated outbound.PaymentCreated) werrors.WError {
+    return p.publish(ctx, newEvent(paymentCreated.Id, PayoutSubmitted, paymentCreated.CreatedAt(), PayoutData{
+        PaymentId:            paymentCreated.PaymentId,
+        DinopayPaymentId:     paymentCreated.DinopayPaymentId,
+        DinopayPaymentStatus: paymentCreated.DinopayPaymentStatus,
+    }))
+}
+
+func (p *Publisher) HandleOutboundPaymentUpdated(ctx context.Context, paymentUpdated outbound.PaymentUpdated) werrors.WError {
+    var eventType string
+    switch dinopayapi.PaymentStatus(paymentUpdated.DinopayPaymentStatus) {
+    case dinopayapi.PaymentStatusConfirmed:
+        eventType = PayoutConfirmed
+    case dinopayapi.PaymentStatusRejected:
+        eventType = PayoutRejected
+    default:
+        return nil
+    }
+    // the update only carries the DinoPay payment id
+    payment, werr := outbound.LoadPayment(ctx, p.db, paymentUpdated.DinopayPaymentId)
+    if werr != nil {
+        return werrors.NewWrappedError(werr, "failed loading outbound payment")
+    }
+    return p.publish(ctx, newEvent(paymentUpdated.Id, eventType, paymentUpdated.CreatedAt(), PayoutData{
+        PaymentId:            payment.PaymentId,
+        DinopayPaymentId:     paymentUpdated.DinopayPaymentId,
+        DinopayPaymentStatus: paymentUpdated.DinopayPaymentStatus,
+    }))
+}
+
+func (p *Publisher) HandleOutboundPaymentFailed(ctx context.Context, paymentFailed outbound.PaymentFailed) werrors.WError {
+    return p.publish(ctx, newEvent(paymentFailed.Id, PayoutFailed, paymentFailed.CreatedAt(), PayoutData{
+        PaymentId: paymentFailed.PaymentId,
+        Reason:    string(paymentFailed.Reason),
+        Details:   paymentFailed.Details,
+    }))
+}
+
+func (p *Publisher) HandleOutboundPaymentCancelled(ctx context.Context, paymentCancelled outbound.PaymentCancelled) werrors.WError {
+    return p.publish(ctx, newEvent(paymentCancelled.Id, PayoutCancelled, paymentCancelled.CreatedAt(), PayoutData{
+        PaymentId:            paymentCancelled.PaymentId,
+        DinopayPaymentId:     paymentCancelled.DinopayPaymentId,
+        DinopayPaymentStatus: paymentCancelled.DinopayPaymentStatus,
+    }))
+}
+
+func (p *Publisher) HandleOutboundPaymentHeld(ctx context.Context, paymentHeld outbound.PaymentHeld) werrors.WError {
+    return p.publish(ctx, newEvent(paymentHeld.Id, PayoutHeld, paymentHeld.CreatedAt(), PayoutData{
+        PaymentId: paymentHeld.PaymentId,
+        Reason:    string(paymentHeld.Reason),
+        Details:   paymentHeld.Details,
+    }))
+}
+
+func (p *Publisher) HandleOutboundPaymentOutcomeUnknown(_ context.Context, _ outbound.PaymentOutcomeUnknown) werrors.WError {
+    return nil
+}
+
+func (p *Publisher) HandleOutboundPaymentResolutionFailed(_ context.Context, _ outbound.PaymentResolutionFailed) werrors.WError {
+    return nil
+}
+
+// HandleOutboundPaymentOutcomeResolved publishes nothing, the
+// OutboundPaymentCreated event appended together with it does
+func (p *Publisher) HandleOutboundPaymentOutcomeResolved(_ context.Context, _ outbound.PaymentOutcomeResolved) werrors.WError {
+    return nil
+}
+
+func (p *Publisher) HandleOutboundPaymentEscalated(_ context.Context, _ outbound.PaymentEscalated) werrors.WError {
+    return nil
+}
+
+func (p *Publisher) HandleOutboundPaymentStuck(_ context.Context, _ outbound.PaymentStuck) werrors.WError {
+    return nil
+}
+
+func (p *Publisher) HandleOutboundPaymentCancellationFailed(_ context.Context, _ outbound.PaymentCancellationFailed) werrors.WError {
+    return nil
+}
+
+// HandleOutboundPaymentReleased publishes nothing, the released
+// payout is submitted and its OutboundPaymentCreated event is published
+func (p *Publisher) HandleOutboundPaymentReleased(_ context.Context, _ outbound.PaymentReleased) werrors.WError {
+    return nil
+}
+
+func (p *Publisher) HandleOutboundPaymentAwaitingApproval(_ context.Context, _ outbound.PaymentAwaitingApproval) werrors.WError {
+    return nil
+}
+
+func (p *Publisher) HandleOutboundPaymentFirstApproved(_ context.Context, _ outbound.PaymentFirstApproved) werrors.WError {
+    return nil
+}
+
+func (p *Publisher) HandleOutboundPaymentApproved(_ context.Context, _ outbound.PaymentApproved) werrors.WError {
+    return nil
+}
+
+func (p *Publisher) HandleOutboundPaymentApprovalRejected(_ context.Context, _ outbound.PaymentApprovalRejected) werrors.WError {
+    return nil
+}
+
+func (p *Publisher) HandleOutboundPaymentRiskAssessed(_ context.Context, _ outbound.PaymentRiskAssessed) werrors.WError {
+    return nil
+}
+
+func (p *Publisher) HandleOutboundPaymentScreened(_ context.Context, _ outbound.PaymentScreened) werrors.WError {
+    return nil
+}
+
+func (p *Publisher) HandleOutboundPaymentNotified(_ context.Context, _ outbound.PaymentNotified) werrors.WError {
+    return nil
+}
+
+func (p *Publisher) HandleOutboundPaymentSubmitting(_ context.Context, _ outbound.PaymentSubmitting) werrors.WError {
+    return nil
+}
+
+func (p *Publisher) HandleOutboundPaymentSubmitted(_ context.Context, _ outbound.PaymentSubmitted) werrors.WError {
+    return nil
+}
+
+func (p *Publisher) HandleInboundPaymentReceived(ctx context.Context, paymentReceived inbound.PaymentReceived) werrors.WError {
+    return p.publish(ctx, newEvent(paymentReceived.Id, DepositReceived, paymentReceived.CreatedAt(), DepositData{
+        DinopayPaymentId: paymentReceived.DinopayPaymentId,
+        PaymentId:        paymentReceived.PaymentId,
+        CustomerId:       paymentReceived.CustomerId,
+        Amount:           paymentReceived.Amount,
+        Currency:         paymentReceived.Currency,
+    }))
+}
+
+func (p *Publisher) HandleInboundPaymentUnmatched(ctx context.Context, paymentUnmatched inbound.PaymentUnmatched) werrors.WError {
+    return p.publish(ctx, newEvent(paymentUnmatched.Id, DepositUnmatched, paymentUnmatched.CreatedAt(), DepositData{
+        DinopayPaymentId: paymentUnmatched.DinopayPaymentId,
+        Amount:           paymentUnmatched.Amount,
+        Currency:         paymentUnmatched.Currency,
+        Reason:           paymentUnmatched.Reason,
+    }))
+}
+
+func (p *Publisher) HandleInboundPaymentHeld(ctx context.Context, paymentHeld inbound.PaymentHeld) werrors.WError {
+    return p.publish(ctx, newEvent(paymentHeld.Id, DepositHeld, paymentHeld.CreatedAt(), DepositData{
+        DinopayPaymentId: paymentHeld.DinopayPaymentId,
+        CustomerId:       paymentHeld.CustomerId,
+        Amount:           paymentHeld.Amount,
+        Currency:         paymentHeld.Currency,
+        Reason:           string(paymentHeld.Reason),
+    }))
+}
+
+func (p *Publisher) HandleInboundPaymentRiskAssessed(_ context.Context, _ inbound.PaymentRiskAssessed) werrors.WError {
+    return nil
+}
+
+func (p *Publisher) HandleInboundPaymentScreened(_ context.Context, _ inbound.PaymentScreened) werrors.WError {
+    return nil
+}
+
+func (p *Publisher) HandleInboundPaymentRejected(ctx context.Context, paymentRejected inbound.PaymentRejected) werrors.WError {
+    return p.publish(ctx, newEvent(paymentRejected.Id, DepositRejected, paymentRejected.CreatedAt(), DepositData{
+        DinopayPaymentId: paymentRejected.DinopayPaymentId,
+        CustomerId:       paymentRejected.CustomerId,
+        Amount:           paymentRejected.Amount,
+        Currency:         paymentRejected.Currency,
+        Reason:           paymentRejected.Reason,
+    }))
+}
+
+func (p *Publisher) HandleInboundPaymentReturnRequested(_ context.Context, _ inbound.PaymentReturnRequested) werrors.WError {
+    return nil
+}
+
+func (p *Publisher) HandleInboundPaymentStatusUpdated(_ context.Context, _ inbound.PaymentStatusUpdated) werrors.WError {
+    return nil
+}
+
+func (p *Publisher) HandleInboundPaymentReturned(ctx context.Context, paymentReturned inbound.PaymentReturned) werrors.WError {
+    payment, werr := inbound.LoadPayment(ctx, p.db, paymentReturned.DinopayPaymentId)
+    if werr != nil {
+        return werrors.NewWrappedError(werr, "failed loading inbound payment")
+    }
+    return p.publish(ctx, newEvent(paymentReturned.Id, DepositReturned, paymentReturned.CreatedAt(), DepositData{
+        DinopayPaymentId:       paymentReturned.DinopayPaymentId,
+        PaymentId:              payment.PaymentId,
+        CustomerId:             payment.CustomerId,
+        Amount:                 payment.Amount,
+        Currency:               payment.Currency,
+        Reason:                 payment.ReturnReason,
+        DinopayReturnPaymentId: paymentReturned.DinopayReturnPaymentId,
+    }))
+}
+
+func (p *Publisher) publish(ctx context.Context, event Event) werrors.WError {
+    err := p.publisher.Publish(ctx, event, events.RoutingInfo{
+        Topic:      p.exchange,
+        RoutingKey: event.EventType,
+    })
+    if err != nil {
+        p.logger.Error(
+            "failed publishing integration event",
+            logattr.EventType(event.EventType),
+            logattr.Error(err.Error()),
+        )
+        return werrors.NewRetryableInternalError("failed publishing " + event.EventType + " integration event: " + err.Error())
+    }
+    p.logger.Debug("integration event published", logattr.EventType(event.EventType), slog.String("event_id", event.Id))
+    return nil
+}
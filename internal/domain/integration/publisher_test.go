@@ -0,0 +1,149 @@
+package integration
+
+import (
+    "context"
+    "encoding/json"
+    "errors"
+    "log/slog"
+    "testing"
+    "time"
+
+    "github.com/google/uuid"
+    "github.com/shopspring/decimal"
+    "github.com/stretchr/testify/require"
+    "github.com/walletera/dinopay-gateway/internal/domain/events/walletera/gateway"
+    "github.com/walletera/dinopay-gateway/internal/domain/events/walletera/gateway/inbound"
+    "github.com/walletera/dinopay-gateway/internal/domain/events/walletera/gateway/outbound"
+    "github.com/walletera/dinopay-gateway/internal/testutil"
+    "github.com/walletera/eventskit/events"
+    "github.com/walletera/eventskit/eventsourcing"
+)
+
+const testExchange = "dinopay.gateway.events"
+
+type published struct {
+    routingInfo events.RoutingInfo
+    payload     map[string]any
+}
+
+type fakePublisher struct {
+    published []published
+    err       error
+}
+
+func (f *fakePublisher) Publish(_ context.Context, data events.EventData, info events.RoutingInfo) error {
+    if f.err != nil {
+        return f.err
+    }
+    raw, err := data.Serialize()
+    if err != nil {
+        return err
+    }
+    var payload map[string]any
+    err = json.Unmarshal(raw, &payload)
+    if err != nil {
+        return err
+    }
+    f.published = append(f.published, published{routingInfo: info, payload: payload})
+    return nil
+}
+
+func newTestPublisher() (*Publisher, *testutil.FakeDB, *fakePublisher) {
+    db := testutil.NewFakeDB()
+    fake := &fakePublisher{}
+    return NewPublisher(db, fake, testExchange, slog.New(slog.DiscardHandler)), db, fake
+}
+
+func TestPublisher_PayoutConfirmed(t *testing.T) {
+    ctx := context.Background()
+    publisher, db, fake := newTestPublisher()
+    paymentId := uuid.New()
+    dinopayPaymentId := uuid.New()
+    _, werr := db.AppendEvents(
+        ctx,
+        gateway.BuildOutboundPaymentStreamName(dinopayPaymentId.String()),
+        eventsourcing.ExpectedAggregateVersion{IsNew: true},
+        outbound.PaymentCreated{
+            Id:                   uuid.New(),
+            PaymentId:            paymentId,
+            DinopayPaymentId:     dinopayPaymentId,
+            DinopayPaymentStatus: "pending",
+            PaymentCreatedAt:     time.Now().UnixMilli(),
+        },
+    )
+    require.NoError(t, werr)
+
+    updateId := uuid.New()
+    werr = publisher.HandleOutboundPaymentUpdated(ctx, outbound.PaymentUpdated{
+        Id:                   updateId,
+        DinopayPaymentId:     dinopayPaymentId,
+        DinopayPaymentStatus: "confirmed",
+        EventCreatedAt:       time.Now().UnixMilli(),
+    })
+    require.NoError(t, werr)
+    require.Len(t, fake.published, 1)
+    require.Equal(t, events.RoutingInfo{Topic: testExchange, RoutingKey: PayoutConfirmed}, fake.published[0].routingInfo)
+    payload := fake.published[0].payload
+    require.Equal(t, updateId.String(), payload["id"])
+    require.Equal(t, PayoutConfirmed, payload["type"])
+    require.EqualValues(t, SchemaVersion, payload["version"])
+    require.Equal(t, paymentId.String(), payload["data"].(map[string]any)["paymentId"])
+
+    werr = publisher.HandleOutboundPaymentUpdated(ctx, outbound.PaymentUpdated{
+        Id:                   uuid.New(),
+        DinopayPaymentId:     dinopayPaymentId,
+        DinopayPaymentStatus: "pending",
+    })
+    require.NoError(t, werr)
+    require.Len(t, fake.published, 1, "non final statuses must not be published")
+}
+
+func TestPublisher_DepositReceived(t *testing.T) {
+    publisher, _, fake := newTestPublisher()
+    customerId := uuid.New()
+    werr := publisher.HandleInboundPaymentReceived(context.Background(), inbound.PaymentReceived{
+        Id:               uuid.New(),
+        DinopayPaymentId: uuid.New(),
+        CustomerId:       customerId,
+        PaymentId:        uuid.New(),
+        Amount:           decimal.RequireFromString("100.5"),
+        Currency:         "USD",
+        EventCreatedAt:   time.Now(),
+    })
+    require.NoError(t, werr)
+    require.Len(t, fake.published, 1)
+    require.Equal(t, DepositReceived, fake.published[0].routingInfo.RoutingKey)
+    data := fake.published[0].payload["data"].(map[string]any)
+    require.Equal(t, customerId.String(), data["customerId"])
+    require.Equal(t, "100.5", data["amount"])
+}
+
+func TestPublisher_DepositUnmatchedOmitsUnknownIds(t *testing.T) {
+    publisher, _, fake := newTestPublisher()
+    werr := publisher.HandleInboundPaymentUnmatched(context.Background(), inbound.PaymentUnmatched{
+        Id:               uuid.New(),
+        DinopayPaymentId: uuid.New(),
+        Amount:           decimal.RequireFromString("100.5"),
+        Currency:         "USD",
+        Reason:           inbound.UnmatchedReasonNoAccountFound,
+        EventCreatedAt:   time.Now(),
+    })
+    require.NoError(t, werr)
+    require.Len(t, fake.published, 1)
+    data := fake.published[0].payload["data"].(map[string]any)
+    require.NotContains(t, data, "paymentId")
+    require.NotContains(t, data, "customerId")
+    require.NotContains(t, data, "dinopayReturnPaymentId")
+}
+
+func TestPublisher_PublishFailureIsRetryable(t *testing.T) {
+    publisher, _, fake := newTestPublisher()
+    fake.err = errors.New("connection closed")
+    werr := publisher.HandleOutboundPaymentFailed(context.Background(), outbound.PaymentFailed{
+        Id:        uuid.New(),
+        PaymentId: uuid.New(),
+        Reason:    outbound.FailureReasonRiskBlocked,
+    })
+    require.Error(t, werr)
+    require.True(t, werr.IsRetryable())
+}
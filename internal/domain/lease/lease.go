@@ -0,0 +1,168 @@
+// Package lease implements a lock shared by the replicas of the gateway on
+// top of an eventsourcing.DB, so that periodic jobs run on one replica at a time.
+package lease
+
+import (
+    "context"
+    "encoding/json"
+    "fmt"
+    "time"
+
+    "github.com/google/uuid"
+    "github.com/walletera/dinopay-gateway/internal/domain/events/walletera/gateway"
+    "github.com/walletera/eventskit/events"
+    "github.com/walletera/eventskit/eventsourcing"
+    "github.com/walletera/werrors"
+)
+
+var _ events.EventData = Acquired{}
+
+// Acquired is appended to the lease stream every time a replica takes or renews the lease
+type Acquired struct {
+    Id             uuid.UUID `json:"id"`
+    Owner          string    `json:"owner"`
+    ExpiresAt      int64     `json:"expires_at"`
+    EventCreatedAt int64     `json:"created_at"`
+}
+
+func (a Acquired) ID() string {
+    return fmt.Sprintf("%s-%s", a.Type(), a.Id)
+}
+
+func (a Acquired) Type() string {
+    return "LeaseAcquired"
+}
+
+func (a Acquired) DataContentType() string {
+    return "application/json"
+}
+
+func (a Acquired) CorrelationID() string {
+    return ""
+}
+
+func (a Acquired) AggregateVersion() uint64 {
+    return 0
+}
+
+func (a Acquired) CreatedAt() time.Time {
+    return time.UnixMilli(a.EventCreatedAt)
+}
+
+func (a Acquired) Serialize() ([]byte, error) {
+    data, err := json.Marshal(a)
+    if err != nil {
+        return nil, fmt.Errorf("failed serializing LeaseAcquired event: %w", err)
+    }
+    envelope := gateway.EventEnvelope{
+        Type: "LeaseAcquired",
+        Data: data,
+    }
+    return json.Marshal(envelope)
+}
+
+// StreamTrimmer is implemented by the event stores that can drop the oldest events
+// of a stream. The stream version keeps growing, only the older events are dropped.
+type StreamTrimmer interface {
+    SetStreamMaxCount(ctx context.Context, streamName string, maxCount uint64) werrors.WError
+}
+
+// Lease is held by a single owner until it expires. Appending the Acquired
+// event with the expected stream version guarantees that when several
+// replicas race for an expired lease only one of them wins it.
+type Lease struct {
+    db         eventsourcing.DB
+    streamName string
+    owner      string
+    ttl        time.Duration
+    now        func() time.Time
+}
+
+type Opt func(l *Lease)
+
+// WithClock replaces time.Now, mostly for tests
+func WithClock(now func() time.Time) Opt {
+    return func(l *Lease) { l.now = now }
+}
+
+// New returns the lease with the given name held by owner for ttl every time it's acquired
+func New(db eventsourcing.DB, name string, owner string, ttl time.Duration, opts ...Opt) *Lease {
+    l := &Lease{
+        db:         db,
+        streamName: gateway.BuildLeaseStreamName(name),
+        owner:      owner,
+        ttl:        ttl,
+        now:        time.Now,
+    }
+    for _, opt := range opts {
+        opt(l)
+    }
+    return l
+}
+
+// Acquire takes the lease when it's free or expired, or renews it when
+// it's already held by the owner. It returns false when another owner holds it.
+//
+// Only the last Acquired event matters, so when the event store is a StreamTrimmer
+// the stream keeps only that one instead of growing with every renewal.
+func (l *Lease) Acquire(ctx context.Context) (bool, werrors.WError) {
+    expectedVersion := eventsourcing.ExpectedAggregateVersion{IsNew: true}
+    retrievedEvents, werr := l.db.ReadEvents(ctx, l.streamName)
+    if werr != nil && werr.Code() != werrors.ResourceNotFoundErrorCode {
+        return false, werr
+    }
+    if len(retrievedEvents) > 0 {
+        last := retrievedEvents[len(retrievedEvents)-1]
+        current, err := deserialize(last.RawEvent)
+        if err != nil {
+            return false, werrors.NewNonRetryableInternalError("failed deserializing lease event: " + err.Error())
+        }
+        if current.Owner != l.owner && l.now().Before(time.UnixMilli(current.ExpiresAt)) {
+            return false, nil
+        }
+        expectedVersion = eventsourcing.ExpectedAggregateVersion{Version: last.AggregateVersion}
+    }
+    now := l.now()
+    _, werr = l.db.AppendEvents(ctx, l.streamName, expectedVersion, Acquired{
+        Id:             uuid.New(),
+        Owner:          l.owner,
+        ExpiresAt:      now.Add(l.ttl).UnixMilli(),
+        EventCreatedAt: now.UnixMilli(),
+    })
+    if werr != nil {
+        switch werr.Code() {
+        case werrors.ResourceAlreadyExistErrorCode, werrors.WrongResourceVersionErrorCode:
+            // another replica won the race
+            return false, nil
+        }
+        return false, werr
+    }
+    if len(retrievedEvents) != 1 {
+        // the stream is new, or the old events were not dropped yet
+        l.trim(ctx)
+    }
+    return true, nil
+}
+
+func (l *Lease) trim(ctx context.Context) {
+    trimmer, ok := l.db.(StreamTrimmer)
+    if !ok {
+        return
+    }
+    // the lease was acquired anyway, the trim is retried on the next acquire
+    _ = trimmer.SetStreamMaxCount(ctx, l.streamName, 1)
+}
+
+func deserialize(rawEvent []byte) (Acquired, error) {
+    var envelope gateway.EventEnvelope
+    err := json.Unmarshal(rawEvent, &envelope)
+    if err != nil {
+        return Acquired{}, err
+    }
+    if envelope.Type != "LeaseAcquired" {
+        return Acquired{}, fmt.Errorf("unexpected event type: %s", envelope.Type)
+    }
+    var acquired Acquired
+    err = json.Unmarshal(envelope.Data, &acquired)
+    return acquired, err
+}
@@ -0,0 +1,54 @@
+package lease
+
+import (
+    "context"
+    "testing"
+    "time"
+
+    "github.com/stretchr/testify/require"
+    "github.com/walletera/dinopay-gateway/internal/domain/events/walletera/gateway"
+    "github.com/walletera/dinopay-gateway/internal/testutil"
+)
+
+func TestLease_Acquire(t *testing.T) {
+    ctx := context.Background()
+    db := testutil.NewFakeDB()
+    now := time.Now()
+    clock := WithClock(func() time.Time { return now })
+    replica1 := New(db, "sweeper", "replica-1", time.Minute, clock)
+    replica2 := New(db, "sweeper", "replica-2", time.Minute, clock)
+
+    acquire := func(l *Lease) bool {
+        acquired, werr := l.Acquire(ctx)
+        require.NoError(t, werr)
+        return acquired
+    }
+
+    require.True(t, acquire(replica1), "free lease must be acquired")
+    require.False(t, acquire(replica2), "lease held by another replica must not be acquired")
+    require.True(t, acquire(replica1), "lease must be renewed by its owner")
+
+    now = now.Add(2 * time.Minute)
+    require.True(t, acquire(replica2), "expired lease must be acquired")
+    require.False(t, acquire(replica1))
+}
+
+func TestLease_AcquireKeepsOnlyTheLastEvent(t *testing.T) {
+    ctx := context.Background()
+    db := testutil.NewFakeDB()
+    now := time.Now()
+    l := New(db, "sweeper", "replica-1", time.Minute, WithClock(func() time.Time { return now }))
+
+    for i := 0; i < 10; i++ {
+        acquired, werr := l.Acquire(ctx)
+        require.NoError(t, werr)
+        require.True(t, acquired)
+        now = now.Add(time.Second)
+    }
+
+    require.LessOrEqual(t, len(db.Stream(gateway.BuildLeaseStreamName("sweeper"))), 2)
+    other := New(db, "sweeper", "replica-2", time.Minute, WithClock(func() time.Time { return now }))
+    acquired, werr := other.Acquire(ctx)
+    require.NoError(t, werr)
+    require.False(t, acquired, "the trimmed stream must still tell the lease is held")
+}
@@ -0,0 +1,153 @@
+// Package mapping converts payments between the Walletera Payments API
+// model and the DinoPay model. Handlers must not map fields by themselves.
+package mapping
+
+import (
+    "errors"
+    "fmt"
+
+    "github.com/google/uuid"
+    "github.com/shopspring/decimal"
+    "github.com/walletera/dinopay-gateway/internal/domain/money"
+    dinopayapi "github.com/walletera/dinopay/api"
+    builders "github.com/walletera/payments-types/builders/privateapi"
+    paymentsapi "github.com/walletera/payments-types/privateapi"
+)
+
+var (
+    ErrUnsupportedCurrency    = errors.New("unsupported currency")
+    ErrUnsupportedAccountType = errors.New("unsupported account type")
+    ErrInvalidAmount          = errors.New("invalid amount")
+)
+
+// Account is a DinoPay account
+type Account struct {
+    AccountHolder string `json:"accountHolder"`
+    AccountNumber string `json:"accountNumber"`
+}
+
+// DinopayPayment is a payment between two DinoPay accounts
+type DinopayPayment struct {
+    // Id is the DinoPay payment id, nil when the payment wasn't created on DinoPay yet
+    Id                 uuid.UUID
+    Amount             decimal.Decimal
+    Currency           string
+    SourceAccount      Account
+    DestinationAccount Account
+}
+
+// PaymentRecord identifies the Payments API payment recording a DinopayPayment
+type PaymentRecord struct {
+    PaymentId  uuid.UUID
+    CustomerId uuid.UUID
+    Direction  paymentsapi.Direction
+    Status     paymentsapi.PaymentStatus
+}
+
+// FromPayment maps a Payments API payment to the DinoPay payment executing it.
+// The debtor becomes the source account and the beneficiary the destination account.
+func FromPayment(payment paymentsapi.Payment) (DinopayPayment, error) {
+    currency := string(payment.Currency)
+    amount, err := money.FromFloat(payment.Amount, currency)
+    if err != nil {
+        return DinopayPayment{}, amountError(currency, err)
+    }
+    sourceAccount, err := fromPaymentsAccount("debtor", payment.Debtor)
+    if err != nil {
+        return DinopayPayment{}, err
+    }
+    destinationAccount, err := fromPaymentsAccount("beneficiary", payment.Beneficiary)
+    if err != nil {
+        return DinopayPayment{}, err
+    }
+    return DinopayPayment{
+        Amount:             amount,
+        Currency:           currency,
+        SourceAccount:      sourceAccount,
+        DestinationAccount: destinationAccount,
+    }, nil
+}
+
+// ToDinopayRequest maps a DinoPay payment to the DinoPay API request creating it.
+// The customerTransactionId makes the creation idempotent on DinoPay.
+func ToDinopayRequest(payment DinopayPayment, customerTransactionId string) (*dinopayapi.Payment, error) {
+    err := money.Validate(payment.Amount, payment.Currency)
+    if err != nil {
+        return nil, amountError(payment.Currency, err)
+    }
+    return toDinopayRequest(payment, customerTransactionId), nil
+}
+
+// ToDinopayReturnRequest maps a DinoPay payment returning a deposit to the DinoPay
+// API request creating it. The amount is not validated: a return sends back exactly
+// what DinoPay delivered, even when the deposit amount was invalid for Walletera.
+func ToDinopayReturnRequest(payment DinopayPayment, customerTransactionId string) *dinopayapi.Payment {
+    return toDinopayRequest(payment, customerTransactionId)
+}
+
+func toDinopayRequest(payment DinopayPayment, customerTransactionId string) *dinopayapi.Payment {
+    return &dinopayapi.Payment{
+        Amount:   money.ToFloat(payment.Amount),
+        Currency: payment.Currency,
+        SourceAccount: dinopayapi.Account{
+            AccountHolder: payment.SourceAccount.AccountHolder,
+            AccountNumber: payment.SourceAccount.AccountNumber,
+        },
+        DestinationAccount: dinopayapi.Account{
+            AccountHolder: payment.DestinationAccount.AccountHolder,
+            AccountNumber: payment.DestinationAccount.AccountNumber,
+        },
+        CustomerTransactionId: dinopayapi.NewOptString(customerTransactionId),
+    }
+}
+
+// ToPostPaymentReq maps a DinoPay payment to the Payments API request recording it.
+// The source account becomes the debtor and the destination account the beneficiary.
+func ToPostPaymentReq(payment DinopayPayment, record PaymentRecord) (*paymentsapi.PostPaymentReq, error) {
+    err := money.Validate(payment.Amount, payment.Currency)
+    if err != nil {
+        return nil, amountError(payment.Currency, err)
+    }
+    currency := paymentsapi.Currency(payment.Currency)
+    req := &paymentsapi.PostPaymentReq{
+        ID:          record.PaymentId,
+        Amount:      money.ToFloat(payment.Amount),
+        Currency:    currency,
+        Gateway:     paymentsapi.GatewayDinopay,
+        Debtor:      toPaymentsAccount(currency, payment.SourceAccount),
+        Beneficiary: toPaymentsAccount(currency, payment.DestinationAccount),
+        Direction:   record.Direction,
+        CustomerId:  record.CustomerId,
+        Status:      record.Status,
+    }
+    if payment.Id != uuid.Nil {
+        req.ExternalId = paymentsapi.NewOptString(payment.Id.String())
+    }
+    return req, nil
+}
+
+func fromPaymentsAccount(role string, account paymentsapi.Account) (Account, error) {
+    if !account.AccountDetails.OneOf.IsDinopayAccountDetails() {
+        return Account{}, fmt.Errorf("%w: %s account type is %q", ErrUnsupportedAccountType, role, account.AccountDetails.OneOf.Type)
+    }
+    details := account.AccountDetails.OneOf.DinopayAccountDetails
+    return Account{
+        AccountHolder: details.AccountHolder,
+        AccountNumber: details.AccountNumber,
+    }, nil
+}
+
+func toPaymentsAccount(currency paymentsapi.Currency, account Account) paymentsapi.Account {
+    return builders.NewDinopayAccountBuilder().
+        WithCurrency(currency).
+        WithAccountHolder(account.AccountHolder).
+        WithAccountNumber(account.AccountNumber).
+        Build()
+}
+
+func amountError(currency string, err error) error {
+    if _, currencyErr := money.DecimalPlaces(currency); currencyErr != nil {
+        return fmt.Errorf("%w: %s", ErrUnsupportedCurrency, currencyErr.Error())
+    }
+    return fmt.Errorf("%w: %s", ErrInvalidAmount, err.Error())
+}
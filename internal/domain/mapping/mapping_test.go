@@ -0,0 +1,295 @@
+package mapping
+
+import (
+    "testing"
+
+    "github.com/google/uuid"
+    "github.com/shopspring/decimal"
+    "github.com/stretchr/testify/assert"
+    "github.com/stretchr/testify/require"
+    dinopayapi "github.com/walletera/dinopay/api"
+    builders "github.com/walletera/payments-types/builders/privateapi"
+    paymentsapi "github.com/walletera/payments-types/privateapi"
+)
+
+var (
+    debtor      = Account{AccountHolder: "john doe", AccountNumber: "IE12BOFI90000112345678"}
+    beneficiary = Account{AccountHolder: "jane doe", AccountNumber: "IE12BOFI90000112349876"}
+)
+
+func dinopayAccount(currency paymentsapi.Currency, account Account) paymentsapi.Account {
+    return builders.NewDinopayAccountBuilder().
+        WithCurrency(currency).
+        WithAccountHolder(account.AccountHolder).
+        WithAccountNumber(account.AccountNumber).
+        Build()
+}
+
+func cvuAccount() paymentsapi.Account {
+    return paymentsapi.Account{
+        Currency: paymentsapi.CurrencyARS,
+        AccountDetails: paymentsapi.AccountAccountDetails{
+            OneOf: paymentsapi.NewCvuAccountDetailsAccountAccountDetailsSum(paymentsapi.CvuAccountDetails{}),
+        },
+    }
+}
+
+func TestFromPayment(t *testing.T) {
+    tests := []struct {
+        name        string
+        payment     paymentsapi.Payment
+        expected    DinopayPayment
+        expectedErr error
+    }{
+        {
+            name: "maps debtor to source and beneficiary to destination",
+            payment: paymentsapi.Payment{
+                Amount:      100.5,
+                Currency:    paymentsapi.CurrencyUSD,
+                Debtor:      dinopayAccount(paymentsapi.CurrencyUSD, debtor),
+                Beneficiary: dinopayAccount(paymentsapi.CurrencyUSD, beneficiary),
+            },
+            expected: DinopayPayment{
+                Amount:             decimal.RequireFromString("100.5"),
+                Currency:           "USD",
+                SourceAccount:      debtor,
+                DestinationAccount: beneficiary,
+            },
+        },
+        {
+            name: "currency without decimals",
+            payment: paymentsapi.Payment{
+                Amount:      1500,
+                Currency:    paymentsapi.CurrencyCLP,
+                Debtor:      dinopayAccount(paymentsapi.CurrencyCLP, debtor),
+                Beneficiary: dinopayAccount(paymentsapi.CurrencyCLP, beneficiary),
+            },
+            expected: DinopayPayment{
+                Amount:             decimal.RequireFromString("1500"),
+                Currency:           "CLP",
+                SourceAccount:      debtor,
+                DestinationAccount: beneficiary,
+            },
+        },
+        {
+            name: "unsupported currency",
+            payment: paymentsapi.Payment{
+                Amount:      100,
+                Currency:    paymentsapi.Currency("GBP"),
+                Debtor:      dinopayAccount("GBP", debtor),
+                Beneficiary: dinopayAccount("GBP", beneficiary),
+            },
+            expectedErr: ErrUnsupportedCurrency,
+        },
+        {
+            name: "too many decimals",
+            payment: paymentsapi.Payment{
+                Amount:      100.555,
+                Currency:    paymentsapi.CurrencyEUR,
+                Debtor:      dinopayAccount(paymentsapi.CurrencyEUR, debtor),
+                Beneficiary: dinopayAccount(paymentsapi.CurrencyEUR, beneficiary),
+            },
+            expectedErr: ErrInvalidAmount,
+        },
+        {
+            name: "unsupported debtor account type",
+            payment: paymentsapi.Payment{
+                Amount:      100,
+                Currency:    paymentsapi.CurrencyARS,
+                Debtor:      cvuAccount(),
+                Beneficiary: dinopayAccount(paymentsapi.CurrencyARS, beneficiary),
+            },
+            expectedErr: ErrUnsupportedAccountType,
+        },
+        {
+            name: "unsupported beneficiary account type",
+            payment: paymentsapi.Payment{
+                Amount:      100,
+                Currency:    paymentsapi.CurrencyARS,
+                Debtor:      dinopayAccount(paymentsapi.CurrencyARS, debtor),
+                Beneficiary: cvuAccount(),
+            },
+            expectedErr: ErrUnsupportedAccountType,
+        },
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            payment, err := FromPayment(tt.payment)
+            if tt.expectedErr != nil {
+                assert.ErrorIs(t, err, tt.expectedErr)
+                return
+            }
+            require.NoError(t, err)
+            assert.True(t, tt.expected.Amount.Equal(payment.Amount), "amount %s", payment.Amount)
+            assert.Equal(t, tt.expected.Currency, payment.Currency)
+            assert.Equal(t, tt.expected.SourceAccount, payment.SourceAccount)
+            assert.Equal(t, tt.expected.DestinationAccount, payment.DestinationAccount)
+            assert.Equal(t, uuid.Nil, payment.Id)
+        })
+    }
+}
+
+func TestToDinopayRequest(t *testing.T) {
+    customerTransactionId := uuid.NewString()
+    tests := []struct {
+        name        string
+        payment     DinopayPayment
+        expected    *dinopayapi.Payment
+        expectedErr error
+    }{
+        {
+            name: "maps every field",
+            payment: DinopayPayment{
+                Amount:             decimal.RequireFromString("100.25"),
+                Currency:           "ARS",
+                SourceAccount:      debtor,
+                DestinationAccount: beneficiary,
+            },
+            expected: &dinopayapi.Payment{
+                Amount:   100.25,
+                Currency: "ARS",
+                SourceAccount: dinopayapi.Account{
+                    AccountHolder: "john doe",
+                    AccountNumber: "IE12BOFI90000112345678",
+                },
+                DestinationAccount: dinopayapi.Account{
+                    AccountHolder: "jane doe",
+                    AccountNumber: "IE12BOFI90000112349876",
+                },
+                CustomerTransactionId: dinopayapi.NewOptString(customerTransactionId),
+            },
+        },
+        {
+            name: "unsupported currency",
+            payment: DinopayPayment{
+                Amount:   decimal.RequireFromString("100"),
+                Currency: "GBP",
+            },
+            expectedErr: ErrUnsupportedCurrency,
+        },
+        {
+            name: "too many decimals",
+            payment: DinopayPayment{
+                Amount:   decimal.RequireFromString("100.5"),
+                Currency: "UYI",
+            },
+            expectedErr: ErrInvalidAmount,
+        },
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            req, err := ToDinopayRequest(tt.payment, customerTransactionId)
+            if tt.expectedErr != nil {
+                assert.ErrorIs(t, err, tt.expectedErr)
+                return
+            }
+            require.NoError(t, err)
+            assert.Equal(t, tt.expected, req)
+        })
+    }
+}
+
+func TestToDinopayReturnRequest(t *testing.T) {
+    customerTransactionId := uuid.NewString()
+    payment := DinopayPayment{
+        Amount:             decimal.RequireFromString("100.555"),
+        Currency:           "USD",
+        SourceAccount:      beneficiary,
+        DestinationAccount: debtor,
+    }
+
+    // the amount DinoPay delivered is sent back even when it isn't valid for Walletera
+    req := ToDinopayReturnRequest(payment, customerTransactionId)
+    assert.Equal(t, 100.555, req.Amount)
+    assert.Equal(t, "USD", req.Currency)
+    assert.Equal(t, "jane doe", req.SourceAccount.AccountHolder)
+    assert.Equal(t, "john doe", req.DestinationAccount.AccountHolder)
+    assert.Equal(t, dinopayapi.NewOptString(customerTransactionId), req.CustomerTransactionId)
+}
+
+func TestToPostPaymentReq(t *testing.T) {
+    dinopayPaymentId := uuid.New()
+    paymentId := uuid.New()
+    customerId := uuid.New()
+    tests := []struct {
+        name        string
+        payment     DinopayPayment
+        record      PaymentRecord
+        expected    *paymentsapi.PostPaymentReq
+        expectedErr error
+    }{
+        {
+            name: "maps source to debtor and destination to beneficiary",
+            payment: DinopayPayment{
+                Id:                 dinopayPaymentId,
+                Amount:             decimal.RequireFromString("100.1"),
+                Currency:           "BRL",
+                SourceAccount:      debtor,
+                DestinationAccount: beneficiary,
+            },
+            record: PaymentRecord{
+                PaymentId:  paymentId,
+                CustomerId: customerId,
+                Direction:  paymentsapi.DirectionInbound,
+                Status:     paymentsapi.PaymentStatusConfirmed,
+            },
+            expected: &paymentsapi.PostPaymentReq{
+                ID:          paymentId,
+                Amount:      100.1,
+                Currency:    paymentsapi.CurrencyBRL,
+                Gateway:     paymentsapi.GatewayDinopay,
+                Debtor:      dinopayAccount(paymentsapi.CurrencyBRL, debtor),
+                Beneficiary: dinopayAccount(paymentsapi.CurrencyBRL, beneficiary),
+                Direction:   paymentsapi.DirectionInbound,
+                CustomerId:  customerId,
+                Status:      paymentsapi.PaymentStatusConfirmed,
+                ExternalId:  paymentsapi.NewOptString(dinopayPaymentId.String()),
+            },
+        },
+        {
+            name: "payment not created on dinopay has no external id",
+            payment: DinopayPayment{
+                Amount:             decimal.RequireFromString("10"),
+                Currency:           "USD",
+                SourceAccount:      beneficiary,
+                DestinationAccount: debtor,
+            },
+            record: PaymentRecord{
+                PaymentId:  paymentId,
+                CustomerId: customerId,
+                Direction:  paymentsapi.DirectionOutbound,
+                Status:     paymentsapi.PaymentStatusPending,
+            },
+            expected: &paymentsapi.PostPaymentReq{
+                ID:          paymentId,
+                Amount:      10,
+                Currency:    paymentsapi.CurrencyUSD,
+                Gateway:     paymentsapi.GatewayDinopay,
+                Debtor:      dinopayAccount(paymentsapi.CurrencyUSD, beneficiary),
+                Beneficiary: dinopayAccount(paymentsapi.CurrencyUSD, debtor),
+                Direction:   paymentsapi.DirectionOutbound,
+                CustomerId:  customerId,
+                Status:      paymentsapi.PaymentStatusPending,
+            },
+        },
+        {
+            name: "unsupported currency",
+            payment: DinopayPayment{
+                Amount:   decimal.RequireFromString("10"),
+                Currency: "GBP",
+            },
+            expectedErr: ErrUnsupportedCurrency,
+        },
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            req, err := ToPostPaymentReq(tt.payment, tt.record)
+            if tt.expectedErr != nil {
+                assert.ErrorIs(t, err, tt.expectedErr)
+                return
+            }
+            require.NoError(t, err)
+            assert.Equal(t, tt.expected, req)
+        })
+    }
+}
@@ -0,0 +1,61 @@
+package money
+
+import (
+    "fmt"
+
+    "github.com/shopspring/decimal"
+    paymentsapi "github.com/walletera/payments-types/privateapi"
+)
+
+// decimalPlaces are the minor units of the currencies supported by the Payments API (ISO 4217)
+var decimalPlaces = map[paymentsapi.Currency]int32{
+    paymentsapi.CurrencyARS: 2,
+    paymentsapi.CurrencyUSD: 2,
+    paymentsapi.CurrencyEUR: 2,
+    paymentsapi.CurrencyBRL: 2,
+    paymentsapi.CurrencyCLP: 0,
+    paymentsapi.CurrencyUYI: 0,
+}
+
+// DecimalPlaces returns the number of decimals an amount of the given currency can have
+func DecimalPlaces(currency string) (int32, error) {
+    places, ok := decimalPlaces[paymentsapi.Currency(currency)]
+    if !ok {
+        return 0, fmt.Errorf("unsupported currency %q", currency)
+    }
+    return places, nil
+}
+
+// Validate checks that the amount is positive and doesn't have more
+// decimals than the currency allows. Amounts are never rounded.
+func Validate(amount decimal.Decimal, currency string) error {
+    places, err := DecimalPlaces(currency)
+    if err != nil {
+        return err
+    }
+    if !amount.IsPositive() {
+        return fmt.Errorf("amount %s must be positive", amount)
+    }
+    if !amount.Equal(amount.Truncate(places)) {
+        return fmt.Errorf("amount %s has more than %d decimals allowed for %s", amount, places, currency)
+    }
+    return nil
+}
+
+// FromFloat converts a float64 amount received from an external API.
+// The shortest decimal representation of the float is used, so an amount
+// that drifted (e.g. 0.30000000000000004) is rejected by the currency precision.
+func FromFloat(amount float64, currency string) (decimal.Decimal, error) {
+    decimalAmount := decimal.NewFromFloat(amount)
+    err := Validate(decimalAmount, currency)
+    if err != nil {
+        return decimal.Decimal{}, err
+    }
+    return decimalAmount, nil
+}
+
+// ToFloat converts an amount for the external APIs that still use float64.
+// Valid amounts have at most 2 decimals, so the float serializes back to the same decimal.
+func ToFloat(amount decimal.Decimal) float64 {
+    return amount.InexactFloat64()
+}
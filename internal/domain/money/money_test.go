@@ -0,0 +1,44 @@
+package money
+
+import (
+    "testing"
+
+    "github.com/shopspring/decimal"
+    "github.com/stretchr/testify/assert"
+)
+
+func TestValidate(t *testing.T) {
+    tests := []struct {
+        amount   string
+        currency string
+        valid    bool
+    }{
+        {amount: "100", currency: "USD", valid: true},
+        {amount: "100.25", currency: "ARS", valid: true},
+        {amount: "100.250", currency: "EUR", valid: true},
+        {amount: "100.255", currency: "BRL", valid: false},
+        {amount: "1500", currency: "CLP", valid: true},
+        {amount: "1500.5", currency: "CLP", valid: false},
+        {amount: "10.1", currency: "UYI", valid: false},
+        {amount: "0", currency: "USD", valid: false},
+        {amount: "-10", currency: "USD", valid: false},
+        {amount: "10", currency: "GBP", valid: false},
+    }
+    for _, tt := range tests {
+        t.Run(tt.amount+" "+tt.currency, func(t *testing.T) {
+            err := Validate(decimal.RequireFromString(tt.amount), tt.currency)
+            assert.Equal(t, tt.valid, err == nil, "unexpected validation result: %v", err)
+        })
+    }
+}
+
+func TestFromFloat_RejectsDriftedAmounts(t *testing.T) {
+    a, b := 0.1, 0.2
+    _, err := FromFloat(a+b, "USD")
+    assert.Error(t, err)
+
+    amount, err := FromFloat(0.3, "USD")
+    assert.NoError(t, err)
+    assert.True(t, amount.Equal(decimal.RequireFromString("0.3")))
+    assert.Equal(t, 0.3, ToFloat(amount))
+}
@@ -0,0 +1,341 @@
+// Package polling replaces the DinoPay webhooks in the environments DinoPay
+// can't reach the gateway from. The DinoPay payment changes are polled and fed
+// into the same paths the webhooks and the outbound status updates go through.
+package polling
+
+import (
+    "context"
+    "encoding/json"
+    "fmt"
+    "log/slog"
+    "time"
+
+    "github.com/google/uuid"
+    dinopayevents "github.com/walletera/dinopay-gateway/internal/domain/events/dinopay"
+    "github.com/walletera/dinopay-gateway/internal/domain/events/walletera/gateway"
+    "github.com/walletera/dinopay-gateway/internal/domain/events/walletera/gateway/outbound"
+    "github.com/walletera/dinopay-gateway/internal/domain/money"
+    "github.com/walletera/dinopay-gateway/internal/domain/ports/output/dinopay"
+    "github.com/walletera/dinopay-gateway/pkg/logattr"
+    "github.com/walletera/dinopay-gateway/pkg/wuuid"
+    dinopayapi "github.com/walletera/dinopay/api"
+    "github.com/walletera/eventskit/events"
+    "github.com/walletera/eventskit/eventsourcing"
+    "github.com/walletera/werrors"
+)
+
+const (
+    DefaultPollInterval = 30 * time.Second
+
+    checkpointName = "paymentChanges"
+)
+
+var _ events.EventData = CheckpointSaved{}
+
+// CheckpointSaved is appended to the checkpoint stream after every polled page
+type CheckpointSaved struct {
+    Id             uuid.UUID `json:"id"`
+    Cursor         string    `json:"cursor"`
+    EventCreatedAt int64     `json:"created_at"`
+}
+
+func (c CheckpointSaved) ID() string {
+    return fmt.Sprintf("%s-%s", c.Type(), c.Id)
+}
+
+func (c CheckpointSaved) Type() string {
+    return "PollingCheckpointSaved"
+}
+
+func (c CheckpointSaved) DataContentType() string {
+    return "application/json"
+}
+
+func (c CheckpointSaved) CorrelationID() string {
+    return ""
+}
+
+func (c CheckpointSaved) AggregateVersion() uint64 {
+    return 0
+}
+
+func (c CheckpointSaved) CreatedAt() time.Time {
+    return time.UnixMilli(c.EventCreatedAt)
+}
+
+func (c CheckpointSaved) Serialize() ([]byte, error) {
+    data, err := json.Marshal(c)
+    if err != nil {
+        return nil, fmt.Errorf("failed serializing PollingCheckpointSaved event: %w", err)
+    }
+    envelope := gateway.EventEnvelope{
+        Type: "PollingCheckpointSaved",
+        Data: data,
+    }
+    return json.Marshal(envelope)
+}
+
+// Poller lists the DinoPay payment changes after the saved checkpoint.
+// The status changes of the outbound payments are recorded with
+// OutboundPaymentUpdated, the payments the gateway doesn't know yet
+// are handled as the PaymentCreated webhook they'd have been announced with,
+// and the status changes of the deposits as their PaymentUpdated webhook.
+//
+// The checkpoint is only saved once every payment of a page was handled,
+// so a page with a retryable failure is polled again. Handling a payment
+// twice has no effect.
+type Poller struct {
+    db            eventsourcing.DB
+    dinopayClient dinopay.Client
+    handler       dinopayevents.EventsHandler
+    lease         outbound.Lease
+    interval      time.Duration
+    now           func() time.Time
+    logger        *slog.Logger
+}
+
+type Opt func(p *Poller)
+
+// WithPollInterval sets how often DinoPay is polled
+func WithPollInterval(interval time.Duration) Opt {
+    return func(p *Poller) { p.interval = interval }
+}
+
+// WithClock replaces time.Now, mostly for tests
+func WithClock(now func() time.Time) Opt {
+    return func(p *Poller) { p.now = now }
+}
+
+func NewPoller(
+    db eventsourcing.DB,
+    dinopayClient dinopay.Client,
+    handler dinopayevents.EventsHandler,
+    lease outbound.Lease,
+    logger *slog.Logger,
+    opts ...Opt,
+) *Poller {
+    p := &Poller{
+        db:            db,
+        dinopayClient: dinopayClient,
+        handler:       handler,
+        lease:         lease,
+        interval:      DefaultPollInterval,
+        now:           time.Now,
+        logger:        logger.With(logattr.Component("polling.Poller")),
+    }
+    for _, opt := range opts {
+        opt(p)
+    }
+    return p
+}
+
+// Run polls DinoPay every interval until ctx is done
+func (p *Poller) Run(ctx context.Context) {
+    ticker := time.NewTicker(p.interval)
+    defer ticker.Stop()
+    for {
+        select {
+        case <-ctx.Done():
+            return
+        case <-ticker.C:
+            werr := p.Poll(ctx)
+            if werr != nil {
+                p.logger.Error("failed polling dinopay payment changes", logattr.Error(werr.Error()))
+            }
+        }
+    }
+}
+
+// Poll handles the pages of payment changes until DinoPay has no more.
+// It does nothing when another replica holds the lease, and stops when the
+// lease can't be renewed between two pages.
+func (p *Poller) Poll(ctx context.Context) werrors.WError {
+    acquired, werr := p.lease.Acquire(ctx)
+    if werr != nil {
+        return werrors.NewWrappedError(werr, "failed acquiring polling lease")
+    }
+    if !acquired {
+        p.logger.Debug("polling lease is held by another replica")
+        return nil
+    }
+    cursor, version, werr := p.loadCheckpoint(ctx)
+    if werr != nil {
+        return werr
+    }
+    for {
+        page, err := p.dinopayClient.ListPaymentChanges(ctx, cursor)
+        if err != nil {
+            return werrors.NewRetryableInternalError("failed listing dinopay payment changes: " + err.Error())
+        }
+        if len(page.Payments) == 0 {
+            return nil
+        }
+        for _, payment := range page.Payments {
+            werr = p.handle(ctx, payment)
+            if werr != nil {
+                if werr.IsRetryable() {
+                    return werrors.NewWrappedError(werr, "failed handling dinopay payment "+payment.ID.Value.String())
+                }
+                // like an unprocessable webhook, it would fail on every poll
+                p.logger.Error(
+                    "skipping dinopay payment that can't be handled",
+                    logattr.DinopayPaymentId(payment.ID.Value.String()),
+                    logattr.Error(werr.Error()),
+                )
+            }
+        }
+        version, werr = p.saveCheckpoint(ctx, page.NextCursor, version)
+        if werr != nil {
+            return werr
+        }
+        if page.NextCursor == cursor {
+            return nil
+        }
+        cursor = page.NextCursor
+        // a long backlog may take longer than the lease ttl, the lease is renewed
+        // before every page so another replica doesn't poll the same changes
+        acquired, werr = p.lease.Acquire(ctx)
+        if werr != nil {
+            return werrors.NewWrappedError(werr, "failed renewing polling lease")
+        }
+        if !acquired {
+            p.logger.Warn("polling lease lost, stopping before the next page")
+            return nil
+        }
+    }
+}
+
+func (p *Poller) handle(ctx context.Context, dinopayPayment dinopayapi.Payment) werrors.WError {
+    if !dinopayPayment.ID.Set {
+        p.logger.Warn("ignoring dinopay payment without id")
+        return nil
+    }
+    dinopayPaymentId := dinopayPayment.ID.Value
+    payment, werr := outbound.LoadPayment(ctx, p.db, dinopayPaymentId)
+    switch {
+    case werr == nil:
+        return p.updateOutbound(ctx, payment, string(dinopayPayment.Status.Value))
+    case werr.Code() == werrors.ResourceNotFoundErrorCode:
+        return p.handleIncoming(ctx, dinopayPayment)
+    default:
+        return werr
+    }
+}
+
+func (p *Poller) updateOutbound(ctx context.Context, payment *outbound.Payment, status string) werrors.WError {
+    if status == "" || status == payment.DinopayPaymentStatus {
+        return nil
+    }
+    _, werr := p.db.AppendEvents(
+        ctx,
+        gateway.BuildOutboundPaymentStreamName(payment.DinopayPaymentId.String()),
+        eventsourcing.ExpectedAggregateVersion{Version: payment.Version},
+        outbound.PaymentUpdated{
+            Id:                              wuuid.NewUUID(),
+            DinopayPaymentId:                payment.DinopayPaymentId,
+            DinopayPaymentStatus:            status,
+            OutboundPaymentAggregateVersion: payment.Version + 1,
+            EventCreatedAt:                  p.now().UnixMilli(),
+        },
+    )
+    if werr != nil {
+        return werrors.NewWrappedError(werr, "failed appending OutboundPaymentUpdated event")
+    }
+    p.logger.Info(
+        "outbound payment status updated from dinopay",
+        logattr.PaymentId(payment.PaymentId.String()),
+        logattr.DinopayPaymentId(payment.DinopayPaymentId.String()),
+        slog.String("status", status),
+    )
+    return nil
+}
+
+func (p *Poller) handleIncoming(ctx context.Context, dinopayPayment dinopayapi.Payment) werrors.WError {
+    dinopayPaymentId := dinopayPayment.ID.Value
+    _, werr := p.db.ReadEvents(ctx, gateway.BuildInboundPaymentStreamName(dinopayPaymentId.String()))
+    if werr == nil {
+        // handled by a previous poll, its status may have changed since
+        return p.handler.HandlePaymentUpdated(ctx, dinopayevents.PaymentUpdated{
+            Id:        wuuid.NewUUID(),
+            EventType: "PaymentUpdated",
+            Time:      p.now(),
+            Data: dinopayevents.PaymentData{
+                Id:     dinopayPaymentId,
+                Status: string(dinopayPayment.Status.Value),
+            },
+        })
+    }
+    if werr.Code() != werrors.ResourceNotFoundErrorCode {
+        return werr
+    }
+    amount, err := money.FromFloat(dinopayPayment.Amount, dinopayPayment.Currency)
+    if err != nil {
+        // the webhook with such an amount would be unprocessable too
+        p.logger.Error(
+            "ignoring dinopay payment with invalid amount",
+            logattr.DinopayPaymentId(dinopayPaymentId.String()),
+            logattr.Error(err.Error()),
+        )
+        return nil
+    }
+    return p.handler.HandlePaymentCreated(ctx, dinopayevents.PaymentCreated{
+        Id:        wuuid.NewUUID(),
+        EventType: "PaymentCreated",
+        Time:      p.now(),
+        Data: dinopayevents.PaymentData{
+            Id:       dinopayPaymentId,
+            Amount:   amount,
+            Currency: dinopayPayment.Currency,
+            SourceAccount: dinopayevents.Account{
+                AccountHolder: dinopayPayment.SourceAccount.AccountHolder,
+                AccountNumber: dinopayPayment.SourceAccount.AccountNumber,
+            },
+            DestinationAccount: dinopayevents.Account{
+                AccountHolder: dinopayPayment.DestinationAccount.AccountHolder,
+                AccountNumber: dinopayPayment.DestinationAccount.AccountNumber,
+            },
+            CustomerTransactionId: dinopayPayment.CustomerTransactionId.Value,
+            Status:                string(dinopayPayment.Status.Value),
+        },
+    })
+}
+
+// loadCheckpoint returns the saved cursor and the version to save the next one with
+func (p *Poller) loadCheckpoint(ctx context.Context) (string, eventsourcing.ExpectedAggregateVersion, werrors.WError) {
+    retrievedEvents, werr := p.db.ReadEvents(ctx, gateway.BuildPollingCheckpointStreamName(checkpointName))
+    if werr != nil {
+        if werr.Code() == werrors.ResourceNotFoundErrorCode {
+            return "", eventsourcing.ExpectedAggregateVersion{IsNew: true}, nil
+        }
+        return "", eventsourcing.ExpectedAggregateVersion{}, werr
+    }
+    last := retrievedEvents[len(retrievedEvents)-1]
+    var envelope gateway.EventEnvelope
+    err := json.Unmarshal(last.RawEvent, &envelope)
+    if err != nil {
+        return "", eventsourcing.ExpectedAggregateVersion{}, werrors.NewNonRetryableInternalError("failed deserializing polling checkpoint: " + err.Error())
+    }
+    var checkpoint CheckpointSaved
+    err = json.Unmarshal(envelope.Data, &checkpoint)
+    if err != nil {
+        return "", eventsourcing.ExpectedAggregateVersion{}, werrors.NewNonRetryableInternalError("failed deserializing polling checkpoint: " + err.Error())
+    }
+    return checkpoint.Cursor, eventsourcing.ExpectedAggregateVersion{Version: last.AggregateVersion}, nil
+}
+
+func (p *Poller) saveCheckpoint(ctx context.Context, cursor string, version eventsourcing.ExpectedAggregateVersion) (eventsourcing.ExpectedAggregateVersion, werrors.WError) {
+    nextVersion, werr := p.db.AppendEvents(
+        ctx,
+        gateway.BuildPollingCheckpointStreamName(checkpointName),
+        version,
+        CheckpointSaved{
+            Id:             wuuid.NewUUID(),
+            Cursor:         cursor,
+            EventCreatedAt: p.now().UnixMilli(),
+        },
+    )
+    if werr != nil {
+        return version, werrors.NewWrappedError(werr, "failed saving polling checkpoint")
+    }
+    return eventsourcing.ExpectedAggregateVersion{Version: nextVersion}, nil
+}
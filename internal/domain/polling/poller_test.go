@@ -0,0 +1,189 @@
+package polling
+
+import (
+    "context"
+    "log/slog"
+    "testing"
+    "time"
+
+    "github.com/google/uuid"
+    "github.com/stretchr/testify/require"
+    dinopayevents "github.com/walletera/dinopay-gateway/internal/domain/events/dinopay"
+    "github.com/walletera/dinopay-gateway/internal/domain/events/walletera/gateway"
+    "github.com/walletera/dinopay-gateway/internal/domain/events/walletera/gateway/outbound"
+    "github.com/walletera/dinopay-gateway/internal/domain/ports/output/dinopay"
+    "github.com/walletera/dinopay-gateway/internal/testutil"
+    dinopayapi "github.com/walletera/dinopay/api"
+    "github.com/walletera/eventskit/eventsourcing"
+    "github.com/walletera/werrors"
+)
+
+type fakeLease struct{}
+
+func (fakeLease) Acquire(_ context.Context) (bool, werrors.WError) {
+    return true, nil
+}
+
+// expiringLease is granted a number of times, then held by another replica
+type expiringLease struct {
+    grants int
+}
+
+func (l *expiringLease) Acquire(_ context.Context) (bool, werrors.WError) {
+    if l.grants == 0 {
+        return false, nil
+    }
+    l.grants--
+    return true, nil
+}
+
+type fakeDinopayClient struct {
+    dinopay.Client
+    pages   map[string]dinopay.PaymentsPage
+    cursors []string
+}
+
+func (c *fakeDinopayClient) ListPaymentChanges(_ context.Context, cursor string) (dinopay.PaymentsPage, error) {
+    c.cursors = append(c.cursors, cursor)
+    return c.pages[cursor], nil
+}
+
+// fakeHandler records the deposits the way the real handler does, in their inbound stream
+type fakeHandler struct {
+    db      *testutil.FakeDB
+    handled []dinopayevents.PaymentCreated
+    updated []dinopayevents.PaymentUpdated
+    err     werrors.WError
+}
+
+func (h *fakeHandler) HandlePaymentCreated(ctx context.Context, event dinopayevents.PaymentCreated) werrors.WError {
+    if h.err != nil {
+        return h.err
+    }
+    h.handled = append(h.handled, event)
+    _, werr := h.db.AppendEvents(ctx, gateway.BuildInboundPaymentStreamName(event.Data.Id.String()), eventsourcing.ExpectedAggregateVersion{IsNew: true}, event)
+    return werr
+}
+
+func (h *fakeHandler) HandlePaymentUpdated(_ context.Context, event dinopayevents.PaymentUpdated) werrors.WError {
+    if h.err != nil {
+        return h.err
+    }
+    h.updated = append(h.updated, event)
+    return nil
+}
+
+func dinopayPayment(id uuid.UUID, status dinopayapi.PaymentStatus) dinopayapi.Payment {
+    return dinopayapi.Payment{
+        ID:                 dinopayapi.NewOptUUID(id),
+        Amount:             100,
+        Currency:           "USD",
+        SourceAccount:      dinopayapi.Account{AccountHolder: "John Doe", AccountNumber: "IE12BOFI90000112345678"},
+        DestinationAccount: dinopayapi.Account{AccountHolder: "Jane Doe", AccountNumber: "1200079635"},
+        Status:             dinopayapi.NewOptPaymentStatus(status),
+    }
+}
+
+func TestPoller_Poll(t *testing.T) {
+    ctx := context.Background()
+    db := testutil.NewFakeDB()
+    payoutId := uuid.New()
+    depositId := uuid.New()
+    _, werr := db.AppendEvents(
+        ctx,
+        gateway.BuildOutboundPaymentStreamName(payoutId.String()),
+        eventsourcing.ExpectedAggregateVersion{IsNew: true},
+        outbound.PaymentCreated{
+            Id:                   uuid.New(),
+            PaymentId:            uuid.New(),
+            DinopayPaymentId:     payoutId,
+            DinopayPaymentStatus: string(dinopayapi.PaymentStatusPending),
+            PaymentCreatedAt:     time.Now().UnixMilli(),
+        },
+    )
+    require.NoError(t, werr)
+    dinopayClient := &fakeDinopayClient{pages: map[string]dinopay.PaymentsPage{
+        "": {
+            Payments: []dinopayapi.Payment{
+                dinopayPayment(payoutId, dinopayapi.PaymentStatusConfirmed),
+                dinopayPayment(depositId, dinopayapi.PaymentStatusConfirmed),
+            },
+            NextCursor: "c1",
+        },
+    }}
+    handler := &fakeHandler{db: db, err: werrors.NewRetryableInternalError("accounts api is down")}
+    poller := NewPoller(db, dinopayClient, handler, fakeLease{}, slog.New(slog.DiscardHandler))
+
+    require.Error(t, poller.Poll(ctx))
+    _, werr = db.ReadEvents(ctx, gateway.BuildPollingCheckpointStreamName(checkpointName))
+    require.Error(t, werr, "the checkpoint must not be saved when the page failed")
+
+    handler.err = nil
+    require.NoError(t, poller.Poll(ctx))
+    require.Len(t, handler.handled, 1)
+    require.Equal(t, depositId, handler.handled[0].Data.Id)
+    require.Equal(t, "100", handler.handled[0].Data.Amount.String())
+    payout, werr := outbound.LoadPayment(ctx, db, payoutId)
+    require.NoError(t, werr)
+    require.Equal(t, string(dinopayapi.PaymentStatusConfirmed), payout.DinopayPaymentStatus)
+    require.Equal(t, uint64(1), payout.Version, "the payout status must be updated once")
+
+    cursor, _, werr := poller.loadCheckpoint(ctx)
+    require.NoError(t, werr)
+    require.Equal(t, "c1", cursor)
+
+    dinopayClient.cursors = nil
+    require.NoError(t, poller.Poll(ctx))
+    require.Equal(t, []string{"c1"}, dinopayClient.cursors, "polling must resume from the checkpoint")
+    require.Len(t, handler.handled, 1)
+}
+
+func TestPoller_PollDepositStatusChanges(t *testing.T) {
+    ctx := context.Background()
+    db := testutil.NewFakeDB()
+    depositId := uuid.New()
+    dinopayClient := &fakeDinopayClient{pages: map[string]dinopay.PaymentsPage{
+        "": {
+            Payments:   []dinopayapi.Payment{dinopayPayment(depositId, dinopayapi.PaymentStatusPending)},
+            NextCursor: "c1",
+        },
+        "c1": {
+            Payments:   []dinopayapi.Payment{dinopayPayment(depositId, dinopayapi.PaymentStatusConfirmed)},
+            NextCursor: "c2",
+        },
+    }}
+    handler := &fakeHandler{db: db}
+    poller := NewPoller(db, dinopayClient, handler, fakeLease{}, slog.New(slog.DiscardHandler))
+
+    require.NoError(t, poller.Poll(ctx))
+    require.Len(t, handler.handled, 1)
+    require.Equal(t, string(dinopayapi.PaymentStatusPending), handler.handled[0].Data.Status)
+    require.Len(t, handler.updated, 1, "the status change of a known deposit must be handled as its webhook")
+    require.Equal(t, depositId, handler.updated[0].Data.Id)
+    require.Equal(t, string(dinopayapi.PaymentStatusConfirmed), handler.updated[0].Data.Status)
+}
+
+func TestPoller_StopsWhenTheLeaseIsLost(t *testing.T) {
+    ctx := context.Background()
+    db := testutil.NewFakeDB()
+    dinopayClient := &fakeDinopayClient{pages: map[string]dinopay.PaymentsPage{
+        "": {
+            Payments:   []dinopayapi.Payment{dinopayPayment(uuid.New(), dinopayapi.PaymentStatusPending)},
+            NextCursor: "c1",
+        },
+        "c1": {
+            Payments:   []dinopayapi.Payment{dinopayPayment(uuid.New(), dinopayapi.PaymentStatusPending)},
+            NextCursor: "c2",
+        },
+    }}
+    handler := &fakeHandler{db: db}
+    // the lease is acquired to start polling and lost before the second page
+    poller := NewPoller(db, dinopayClient, handler, &expiringLease{grants: 1}, slog.New(slog.DiscardHandler))
+
+    require.NoError(t, poller.Poll(ctx))
+    require.Equal(t, []string{""}, dinopayClient.cursors)
+    require.Len(t, handler.handled, 1)
+    cursor, _, werr := poller.loadCheckpoint(ctx)
+    require.NoError(t, werr)
+    require.Equal(t, "c1", cursor, "the handled page must be checkpointed")
+}
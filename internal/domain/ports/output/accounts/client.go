@@ -0,0 +1,17 @@
+package accounts
+
+import (
+    "context"
+
+    "github.com/walletera/accounts/publicapi"
+)
+
+type Client interface {
+    ListAccounts(ctx context.Context, params publicapi.ListAccountsParams) (publicapi.ListAccountsRes, error)
+}
+
+// Refresher is implemented by the clients caching the lookups.
+// RefreshAccounts asks the Accounts service bypassing the cache.
+type Refresher interface {
+    RefreshAccounts(ctx context.Context, params publicapi.ListAccountsParams) (publicapi.ListAccountsRes, error)
+}
@@ -2,10 +2,33 @@ package dinopay
 
 import (
     "context"
+    "errors"
 
+    "github.com/google/uuid"
     "github.com/walletera/dinopay/api"
 )
 
+// ErrPaymentNotCancellable is returned by CancelPayment when DinoPay already processed the payment
+var ErrPaymentNotCancellable = errors.New("dinopay payment can't be cancelled")
+
+// PaymentsPage is a page of the DinoPay payments created or updated after a cursor
+type PaymentsPage struct {
+    Payments []api.Payment
+    // NextCursor is the cursor to list the changes that follow the page
+    NextCursor string
+}
+
 type Client interface {
     CreatePayment(ctx context.Context, req *api.Payment) (api.CreatePaymentRes, error)
+    // GetPayment returns the DinoPay payment with the given id, or nil when DinoPay doesn't have it
+    GetPayment(ctx context.Context, id uuid.UUID) (*api.Payment, error)
+    // FindPaymentByCustomerTransactionId returns the payment created on DinoPay with
+    // the given CustomerTransactionId, or nil when DinoPay answered the lookup without
+    // such payment. Any other answer, a 404 included, is an error.
+    FindPaymentByCustomerTransactionId(ctx context.Context, customerTransactionId string) (*api.Payment, error)
+    // CancelPayment cancels a payment DinoPay hasn't processed yet and returns it
+    CancelPayment(ctx context.Context, id uuid.UUID) (*api.Payment, error)
+    // ListPaymentChanges returns the payments created or updated after the given
+    // cursor, oldest change first. An empty cursor lists from the first change.
+    ListPaymentChanges(ctx context.Context, cursor string) (PaymentsPage, error)
 }
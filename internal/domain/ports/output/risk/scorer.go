@@ -0,0 +1,71 @@
+package risk
+
+import (
+    "context"
+    "time"
+
+    "github.com/google/uuid"
+    "github.com/shopspring/decimal"
+    "github.com/walletera/dinopay-gateway/internal/domain/mapping"
+)
+
+// Outcome is what must be done with a scored payment
+type Outcome string
+
+const (
+    OutcomeAllow  Outcome = "allow"
+    OutcomeReview Outcome = "review"
+    OutcomeBlock  Outcome = "block"
+)
+
+// Severity orders the outcomes, allow being the least severe
+func (o Outcome) Severity() int {
+    switch o {
+    case OutcomeBlock:
+        return 2
+    case OutcomeReview:
+        return 1
+    default:
+        return 0
+    }
+}
+
+type Direction string
+
+const (
+    DirectionInbound  Direction = "inbound"
+    DirectionOutbound Direction = "outbound"
+)
+
+// Payment is a DinoPay deposit or payout of a customer. The Counterparty
+// is the sender of a deposit or the beneficiary of a payout.
+type Payment struct {
+    Id           string          `json:"id"`
+    Direction    Direction       `json:"direction"`
+    CustomerId   uuid.UUID       `json:"customerId"`
+    Amount       decimal.Decimal `json:"amount"`
+    Currency     string          `json:"currency"`
+    Counterparty mapping.Account `json:"counterparty"`
+    CreatedAt    time.Time       `json:"createdAt"`
+}
+
+// Decision is the Outcome of scoring a payment and the reasons for it
+type Decision struct {
+    Outcome Outcome  `json:"outcome"`
+    Reasons []string `json:"reasons,omitempty"`
+}
+
+// Assessment is a payment scored in the past together with its decision
+type Assessment struct {
+    Payment  Payment  `json:"payment"`
+    Decision Decision `json:"decision"`
+}
+
+// History is the recent assessments of the payments of a customer, oldest first
+type History []Assessment
+
+// Scorer decides whether a payment can go on, must be reviewed by
+// an operator or must be blocked, given the recent payments of the customer
+type Scorer interface {
+    Score(ctx context.Context, payment Payment, history History) (Decision, error)
+}
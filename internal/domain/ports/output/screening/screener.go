@@ -0,0 +1,25 @@
+package screening
+
+import (
+    "context"
+)
+
+// Match is a sanctions list entry whose name matches the screened name
+type Match struct {
+    EntryId string  `json:"entryId"`
+    Name    string  `json:"name"`
+    Score   float64 `json:"score"`
+}
+
+// Result is the outcome of screening a name. ListVersion identifies the
+// version of the list the name was screened against, for audit.
+type Result struct {
+    Hit         bool    `json:"hit"`
+    Matches     []Match `json:"matches,omitempty"`
+    ListVersion string  `json:"listVersion"`
+}
+
+// Screener checks names against sanctions and watchlists
+type Screener interface {
+    Screen(ctx context.Context, name string) (Result, error)
+}
@@ -0,0 +1,113 @@
+package reconciliation
+
+import (
+    "encoding/json"
+    "fmt"
+    "time"
+
+    "github.com/google/uuid"
+    "github.com/shopspring/decimal"
+    "github.com/walletera/dinopay-gateway/internal/domain/events/walletera/gateway"
+    "github.com/walletera/eventskit/events"
+)
+
+var (
+    _ events.EventData = MismatchFound{}
+    _ events.EventData = StatementReconciled{}
+)
+
+// MismatchFound is recorded for every item of a statement that needs to be followed up
+type MismatchFound struct {
+    Id                    uuid.UUID        `json:"id"`
+    StatementId           string           `json:"statement_id"`
+    Kind                  ItemKind         `json:"kind"`
+    Direction             string           `json:"direction,omitempty"`
+    DinopayPaymentId      uuid.UUID        `json:"dinopay_payment_id,omitempty"`
+    CustomerTransactionId string           `json:"customer_transaction_id,omitempty"`
+    PaymentId             uuid.UUID        `json:"payment_id,omitempty"`
+    Currency              string           `json:"currency"`
+    DinopayAmount         *decimal.Decimal `json:"dinopay_amount,omitempty"`
+    GatewayAmount         *decimal.Decimal `json:"gateway_amount,omitempty"`
+    Details               string           `json:"details,omitempty"`
+    EventCreatedAt        int64            `json:"created_at"`
+}
+
+func (m MismatchFound) ID() string {
+    return fmt.Sprintf("%s-%s", m.Type(), m.Id)
+}
+
+func (m MismatchFound) Type() string {
+    return "ReconciliationMismatchFound"
+}
+
+func (m MismatchFound) DataContentType() string {
+    return "application/json"
+}
+
+func (m MismatchFound) CorrelationID() string {
+    return m.StatementId
+}
+
+func (m MismatchFound) AggregateVersion() uint64 {
+    return 0
+}
+
+func (m MismatchFound) CreatedAt() time.Time {
+    return time.UnixMilli(m.EventCreatedAt)
+}
+
+func (m MismatchFound) Serialize() ([]byte, error) {
+    return serialize(m.Type(), m)
+}
+
+// StatementReconciled closes the reconciliation stream of a statement
+type StatementReconciled struct {
+    Id               uuid.UUID `json:"id"`
+    StatementId      string    `json:"statement_id"`
+    StatementName    string    `json:"statement_name"`
+    Matched          int       `json:"matched"`
+    AmountMismatches int       `json:"amount_mismatches"`
+    MissingOnGateway int       `json:"missing_on_gateway"`
+    MissingAtDinopay int       `json:"missing_at_dinopay"`
+    EventCreatedAt   int64     `json:"created_at"`
+}
+
+func (s StatementReconciled) ID() string {
+    return fmt.Sprintf("%s-%s", s.Type(), s.Id)
+}
+
+func (s StatementReconciled) Type() string {
+    return "ReconciliationStatementReconciled"
+}
+
+func (s StatementReconciled) DataContentType() string {
+    return "application/json"
+}
+
+func (s StatementReconciled) CorrelationID() string {
+    return s.StatementId
+}
+
+func (s StatementReconciled) AggregateVersion() uint64 {
+    return 0
+}
+
+func (s StatementReconciled) CreatedAt() time.Time {
+    return time.UnixMilli(s.EventCreatedAt)
+}
+
+func (s StatementReconciled) Serialize() ([]byte, error) {
+    return serialize(s.Type(), s)
+}
+
+func serialize(eventType string, event any) ([]byte, error) {
+    data, err := json.Marshal(event)
+    if err != nil {
+        return nil, fmt.Errorf("failed serializing %s event: %w", eventType, err)
+    }
+    envelope := gateway.EventEnvelope{
+        Type: eventType,
+        Data: data,
+    }
+    return json.Marshal(envelope)
+}
@@ -0,0 +1,203 @@
+package reconciliation
+
+import (
+    "fmt"
+    "strings"
+    "time"
+
+    "github.com/google/uuid"
+    "github.com/shopspring/decimal"
+)
+
+type ItemKind string
+
+const (
+    ItemKindMatched          ItemKind = "matched"
+    ItemKindAmountMismatch   ItemKind = "amount_mismatch"
+    ItemKindMissingOnGateway ItemKind = "missing_on_gateway"
+    ItemKindMissingAtDinopay ItemKind = "missing_at_dinopay"
+)
+
+const (
+    DirectionOutbound = "outbound"
+    DirectionInbound  = "inbound"
+)
+
+// Record is the Payments API record of a payment
+type Record struct {
+    Amount   decimal.Decimal
+    Currency string
+}
+
+// Payment is a DinoPay payment known by the gateway
+type Payment struct {
+    Direction             string
+    DinopayPaymentId      uuid.UUID
+    CustomerTransactionId string
+    // PaymentId is the id of the Payments API record, nil when there is no record
+    PaymentId uuid.UUID
+    // Amount and Currency are the ones in the gateway stream, Amount is nil when the stream doesn't have them
+    Amount    *decimal.Decimal
+    Currency  string
+    CreatedAt time.Time
+    // Record is nil when the Payments API doesn't have the payment
+    Record *Record
+}
+
+// Item is the result of matching a statement line or a gateway payment
+type Item struct {
+    Kind                  ItemKind         `json:"kind"`
+    Direction             string           `json:"direction,omitempty"`
+    DinopayPaymentId      uuid.UUID        `json:"dinopayPaymentId,omitempty"`
+    CustomerTransactionId string           `json:"customerTransactionId,omitempty"`
+    PaymentId             uuid.UUID        `json:"paymentId,omitempty"`
+    Currency              string           `json:"currency"`
+    DinopayAmount         *decimal.Decimal `json:"dinopayAmount,omitempty"`
+    GatewayAmount         *decimal.Decimal `json:"gatewayAmount,omitempty"`
+    Details               string           `json:"details,omitempty"`
+}
+
+// Report is the result of reconciling a statement
+type Report struct {
+    StatementId       string    `json:"statementId"`
+    StatementName     string    `json:"statementName"`
+    From              time.Time `json:"from"`
+    To                time.Time `json:"to"`
+    AlreadyReconciled bool      `json:"alreadyReconciled"`
+    Matched           []Item    `json:"matched"`
+    AmountMismatches  []Item    `json:"amountMismatches"`
+    MissingOnGateway  []Item    `json:"missingOnGateway"`
+    MissingAtDinopay  []Item    `json:"missingAtDinopay"`
+}
+
+// Mismatches returns the items that need to be followed up
+func (r *Report) Mismatches() []Item {
+    var mismatches []Item
+    mismatches = append(mismatches, r.AmountMismatches...)
+    mismatches = append(mismatches, r.MissingOnGateway...)
+    mismatches = append(mismatches, r.MissingAtDinopay...)
+    return mismatches
+}
+
+// Match matches every line of the statement with a gateway payment, by DinoPay payment id
+// or else by CustomerTransactionId, and compares their amounts and currencies.
+// The gateway payments created within the statement period that no line matched
+// are reported as missing at DinoPay.
+func Match(statement Statement, payments []Payment) *Report {
+    from, to := statement.Period()
+    report := &Report{
+        StatementId:   statement.Id,
+        StatementName: statement.Name,
+        From:          from,
+        To:            to,
+    }
+    byDinopayPaymentId := make(map[uuid.UUID]int)
+    byCustomerTransactionId := make(map[string]int)
+    for i, payment := range payments {
+        if payment.DinopayPaymentId != uuid.Nil {
+            byDinopayPaymentId[payment.DinopayPaymentId] = i
+        }
+        if payment.CustomerTransactionId != "" {
+            byCustomerTransactionId[payment.CustomerTransactionId] = i
+        }
+    }
+    matched := make(map[int]bool)
+    for _, line := range statement.Lines {
+        i, found := byDinopayPaymentId[line.DinopayPaymentId]
+        if !found && line.CustomerTransactionId != "" {
+            i, found = byCustomerTransactionId[line.CustomerTransactionId]
+        }
+        if !found {
+            item := lineItem(line)
+            item.Kind = ItemKindMissingOnGateway
+            item.Details = "no gateway payment found"
+            report.MissingOnGateway = append(report.MissingOnGateway, item)
+            continue
+        }
+        matched[i] = true
+        item := matchLine(line, payments[i])
+        switch item.Kind {
+        case ItemKindMatched:
+            report.Matched = append(report.Matched, item)
+        case ItemKindAmountMismatch:
+            report.AmountMismatches = append(report.AmountMismatches, item)
+        case ItemKindMissingOnGateway:
+            report.MissingOnGateway = append(report.MissingOnGateway, item)
+        }
+    }
+    for i, payment := range payments {
+        if matched[i] || payment.CreatedAt.Before(from) || payment.CreatedAt.After(to) {
+            continue
+        }
+        item := paymentItem(payment)
+        item.Kind = ItemKindMissingAtDinopay
+        item.GatewayAmount = payment.Amount
+        if item.GatewayAmount == nil && payment.Record != nil {
+            item.GatewayAmount = &payment.Record.Amount
+            item.Currency = payment.Record.Currency
+        }
+        item.Details = "not in the statement"
+        report.MissingAtDinopay = append(report.MissingAtDinopay, item)
+    }
+    return report
+}
+
+func matchLine(line Line, payment Payment) Item {
+    item := paymentItem(payment)
+    item.DinopayAmount = &line.Amount
+    item.Currency = line.Currency
+    if item.DinopayPaymentId == uuid.Nil {
+        item.DinopayPaymentId = line.DinopayPaymentId
+    }
+    var differences []string
+    if payment.Amount != nil {
+        item.GatewayAmount = payment.Amount
+        if !payment.Amount.Equal(line.Amount) || payment.Currency != line.Currency {
+            differences = append(differences, fmt.Sprintf("gateway stream has %s %s", payment.Amount, payment.Currency))
+        }
+    }
+    if payment.PaymentId != uuid.Nil {
+        if payment.Record == nil {
+            item.Kind = ItemKindMissingOnGateway
+            item.Details = "no Payments API record " + payment.PaymentId.String()
+            return item
+        }
+        if item.GatewayAmount == nil {
+            item.GatewayAmount = &payment.Record.Amount
+        }
+        if !payment.Record.Amount.Equal(line.Amount) || payment.Record.Currency != line.Currency {
+            differences = append(differences, fmt.Sprintf("Payments API has %s %s", payment.Record.Amount, payment.Record.Currency))
+        }
+    }
+    if item.GatewayAmount == nil {
+        item.Kind = ItemKindMissingOnGateway
+        item.Details = "the gateway doesn't know the amount"
+        return item
+    }
+    if len(differences) > 0 {
+        item.Kind = ItemKindAmountMismatch
+        item.Details = fmt.Sprintf("dinopay has %s %s, %s", line.Amount, line.Currency, strings.Join(differences, ", "))
+        return item
+    }
+    item.Kind = ItemKindMatched
+    return item
+}
+
+func lineItem(line Line) Item {
+    return Item{
+        DinopayPaymentId:      line.DinopayPaymentId,
+        CustomerTransactionId: line.CustomerTransactionId,
+        Currency:              line.Currency,
+        DinopayAmount:         &line.Amount,
+    }
+}
+
+func paymentItem(payment Payment) Item {
+    return Item{
+        Direction:             payment.Direction,
+        DinopayPaymentId:      payment.DinopayPaymentId,
+        CustomerTransactionId: payment.CustomerTransactionId,
+        PaymentId:             payment.PaymentId,
+        Currency:              payment.Currency,
+    }
+}
@@ -0,0 +1,140 @@
+package reconciliation
+
+import (
+    "testing"
+    "time"
+
+    "github.com/google/uuid"
+    "github.com/shopspring/decimal"
+    "github.com/stretchr/testify/require"
+)
+
+func TestMatch(t *testing.T) {
+    day := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+    amount := decimal.RequireFromString("100.50")
+    otherAmount := decimal.RequireFromString("99.50")
+    line := Line{
+        DinopayPaymentId:      uuid.New(),
+        CustomerTransactionId: uuid.NewString(),
+        Amount:                amount,
+        Currency:              "ARS",
+        Date:                  day,
+    }
+    tests := []struct {
+        name     string
+        lines    []Line
+        payments []Payment
+        wantKind ItemKind
+    }{
+        {
+            name:  "inbound payment matched by dinopay payment id",
+            lines: []Line{line},
+            payments: []Payment{{
+                Direction:        DirectionInbound,
+                DinopayPaymentId: line.DinopayPaymentId,
+                Amount:           &amount,
+                Currency:         "ARS",
+                CreatedAt:        day.Add(time.Hour),
+            }},
+            wantKind: ItemKindMatched,
+        },
+        {
+            name:  "outbound payment matched by customer transaction id",
+            lines: []Line{line},
+            payments: []Payment{{
+                Direction:             DirectionOutbound,
+                DinopayPaymentId:      uuid.New(),
+                CustomerTransactionId: line.CustomerTransactionId,
+                PaymentId:             uuid.New(),
+                Record:                &Record{Amount: amount, Currency: "ARS"},
+                CreatedAt:             day.Add(time.Hour),
+            }},
+            wantKind: ItemKindMatched,
+        },
+        {
+            name:  "amount differs from the payments api record",
+            lines: []Line{line},
+            payments: []Payment{{
+                Direction:        DirectionOutbound,
+                DinopayPaymentId: line.DinopayPaymentId,
+                PaymentId:        uuid.New(),
+                Record:           &Record{Amount: otherAmount, Currency: "ARS"},
+                CreatedAt:        day.Add(time.Hour),
+            }},
+            wantKind: ItemKindAmountMismatch,
+        },
+        {
+            name:  "currency differs from the gateway stream",
+            lines: []Line{line},
+            payments: []Payment{{
+                Direction:        DirectionInbound,
+                DinopayPaymentId: line.DinopayPaymentId,
+                Amount:           &amount,
+                Currency:         "USD",
+                CreatedAt:        day.Add(time.Hour),
+            }},
+            wantKind: ItemKindAmountMismatch,
+        },
+        {
+            name:     "line without gateway payment",
+            lines:    []Line{line},
+            wantKind: ItemKindMissingOnGateway,
+        },
+        {
+            name:  "payment without payments api record",
+            lines: []Line{line},
+            payments: []Payment{{
+                Direction:        DirectionOutbound,
+                DinopayPaymentId: line.DinopayPaymentId,
+                PaymentId:        uuid.New(),
+                CreatedAt:        day.Add(time.Hour),
+            }},
+            wantKind: ItemKindMissingOnGateway,
+        },
+        {
+            name:  "gateway payment within the period without line",
+            lines: []Line{line},
+            payments: []Payment{
+                {
+                    Direction:        DirectionInbound,
+                    DinopayPaymentId: line.DinopayPaymentId,
+                    Amount:           &amount,
+                    Currency:         "ARS",
+                    CreatedAt:        day.Add(time.Hour),
+                },
+                {
+                    Direction:        DirectionInbound,
+                    DinopayPaymentId: uuid.New(),
+                    Amount:           &otherAmount,
+                    Currency:         "ARS",
+                    CreatedAt:        day.Add(23 * time.Hour),
+                },
+                {
+                    // out of the statement period
+                    Direction:        DirectionInbound,
+                    DinopayPaymentId: uuid.New(),
+                    Amount:           &otherAmount,
+                    Currency:         "ARS",
+                    CreatedAt:        day.Add(25 * time.Hour),
+                },
+            },
+            wantKind: ItemKindMissingAtDinopay,
+        },
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            report := Match(Statement{Id: "statement", Lines: tt.lines}, tt.payments)
+            byKind := map[ItemKind][]Item{
+                ItemKindMatched:          report.Matched,
+                ItemKindAmountMismatch:   report.AmountMismatches,
+                ItemKindMissingOnGateway: report.MissingOnGateway,
+                ItemKindMissingAtDinopay: report.MissingAtDinopay,
+            }
+            require.Len(t, byKind[tt.wantKind], 1)
+            require.Equal(t, tt.wantKind, byKind[tt.wantKind][0].Kind)
+            if tt.wantKind == ItemKindMatched {
+                require.Empty(t, report.Mismatches())
+            }
+        })
+    }
+}
@@ -0,0 +1,304 @@
+package reconciliation
+
+import (
+    "context"
+    "log/slog"
+    "sync"
+    "time"
+
+    "github.com/google/uuid"
+    "github.com/shopspring/decimal"
+    "github.com/walletera/dinopay-gateway/internal/domain/events/walletera/gateway"
+    "github.com/walletera/dinopay-gateway/internal/domain/events/walletera/gateway/inbound"
+    "github.com/walletera/dinopay-gateway/internal/domain/events/walletera/gateway/outbound"
+    "github.com/walletera/dinopay-gateway/internal/domain/money"
+    "github.com/walletera/dinopay-gateway/pkg/logattr"
+    "github.com/walletera/dinopay-gateway/pkg/wuuid"
+    "github.com/walletera/eventskit/events"
+    "github.com/walletera/eventskit/eventsourcing"
+    paymentsapi "github.com/walletera/payments-types/privateapi"
+    "github.com/walletera/werrors"
+    "golang.org/x/sync/errgroup"
+)
+
+// DefaultLookupConcurrency is how many Payments API lookups run at the same time.
+// The Payments API has no batch endpoint, so the lookups are batched by running
+// them concurrently.
+const DefaultLookupConcurrency = 8
+
+// PaymentsClient is the part of the Payments API client the Reconciler needs
+type PaymentsClient interface {
+    GetPayment(ctx context.Context, params paymentsapi.GetPaymentParams) (paymentsapi.GetPaymentRes, error)
+}
+
+// Reconciler reconciles DinoPay statements against the outboundPayment and
+// inboundPayment streams and the Payments API records. The mismatches of each
+// statement are recorded in the reconciliation stream of the statement.
+type Reconciler struct {
+    db                         eventsourcing.DB
+    paymentsClient             PaymentsClient
+    outboundCategoryStreamName string
+    inboundCategoryStreamName  string
+    lookupConcurrency          int
+    logger                     *slog.Logger
+}
+
+type Opt func(r *Reconciler)
+
+// WithLookupConcurrency sets how many Payments API lookups run at the same time
+func WithLookupConcurrency(concurrency int) Opt {
+    return func(r *Reconciler) { r.lookupConcurrency = concurrency }
+}
+
+func NewReconciler(
+    db eventsourcing.DB,
+    paymentsClient PaymentsClient,
+    outboundCategoryStreamName string,
+    inboundCategoryStreamName string,
+    logger *slog.Logger,
+    opts ...Opt,
+) *Reconciler {
+    r := &Reconciler{
+        db:                         db,
+        paymentsClient:             paymentsClient,
+        outboundCategoryStreamName: outboundCategoryStreamName,
+        inboundCategoryStreamName:  inboundCategoryStreamName,
+        lookupConcurrency:          DefaultLookupConcurrency,
+        logger:                     logger.With(logattr.Component("reconciliation.Reconciler")),
+    }
+    for _, opt := range opts {
+        opt(r)
+    }
+    return r
+}
+
+// Reconcile matches the statement and records its mismatches. A statement that was
+// already reconciled is matched again but its mismatches are not recorded twice.
+// It folds the whole outboundPayment and inboundPayment categories.
+func (r *Reconciler) Reconcile(ctx context.Context, statement Statement) (*Report, werrors.WError) {
+    reports, werr := r.ReconcileAll(ctx, []Statement{statement})
+    if werr != nil {
+        return nil, werr
+    }
+    return reports[0], nil
+}
+
+// ReconcileAll reconciles several statements folding the outboundPayment and
+// inboundPayment categories only once. Each Payments API record is looked up
+// once, even when its payment is relevant to several statements.
+func (r *Reconciler) ReconcileAll(ctx context.Context, statements []Statement) ([]*Report, werrors.WError) {
+    payments, werr := r.gatewayPayments(ctx)
+    if werr != nil {
+        return nil, werr
+    }
+    relevantPayments := make([][]Payment, len(statements))
+    var paymentIds []uuid.UUID
+    seen := make(map[uuid.UUID]bool)
+    for i, statement := range statements {
+        relevantPayments[i] = relevantTo(statement, payments)
+        for _, payment := range relevantPayments[i] {
+            if payment.PaymentId != uuid.Nil && !seen[payment.PaymentId] {
+                seen[payment.PaymentId] = true
+                paymentIds = append(paymentIds, payment.PaymentId)
+            }
+        }
+    }
+    records, werr := r.paymentsRecords(ctx, paymentIds)
+    if werr != nil {
+        return nil, werr
+    }
+    reports := make([]*Report, len(statements))
+    for i, statement := range statements {
+        relevant := relevantPayments[i]
+        for j := range relevant {
+            relevant[j].Record = records[relevant[j].PaymentId]
+        }
+        reports[i], werr = r.reconcile(ctx, statement, relevant)
+        if werr != nil {
+            return nil, werr
+        }
+    }
+    return reports, nil
+}
+
+func (r *Reconciler) reconcile(ctx context.Context, statement Statement, payments []Payment) (*Report, werrors.WError) {
+    report := Match(statement, payments)
+    werr := r.recordMismatches(ctx, report)
+    if werr != nil {
+        if werr.Code() != werrors.ResourceAlreadyExistErrorCode {
+            return nil, werrors.NewWrappedError(werr, "failed recording reconciliation of statement "+statement.Id)
+        }
+        report.AlreadyReconciled = true
+    }
+    r.logger.Info(
+        "dinopay statement reconciled",
+        slog.String("statement_id", report.StatementId),
+        slog.String("statement_name", report.StatementName),
+        slog.Int("matched", len(report.Matched)),
+        slog.Int("amount_mismatches", len(report.AmountMismatches)),
+        slog.Int("missing_on_gateway", len(report.MissingOnGateway)),
+        slog.Int("missing_at_dinopay", len(report.MissingAtDinopay)),
+        slog.Bool("already_reconciled", report.AlreadyReconciled),
+    )
+    return report, nil
+}
+
+func (r *Reconciler) gatewayPayments(ctx context.Context) ([]Payment, werrors.WError) {
+    outboundPayments, werr := outbound.ListPayments(ctx, r.db, r.outboundCategoryStreamName)
+    if werr != nil {
+        return nil, werr
+    }
+    inboundPayments, werr := inbound.ListPayments(ctx, r.db, r.inboundCategoryStreamName)
+    if werr != nil {
+        return nil, werr
+    }
+    var payments []Payment
+    for _, payment := range outboundPayments {
+        // the Walletera payment id is the CustomerTransactionId of the DinoPay payment
+        payments = append(payments, Payment{
+            Direction:             DirectionOutbound,
+            DinopayPaymentId:      payment.DinopayPaymentId,
+            CustomerTransactionId: payment.PaymentId.String(),
+            PaymentId:             payment.PaymentId,
+            CreatedAt:             payment.CreatedAt,
+        })
+    }
+    for _, payment := range inboundPayments {
+        amount := payment.Amount
+        payments = append(payments, Payment{
+            Direction:        DirectionInbound,
+            DinopayPaymentId: payment.DinopayPaymentId,
+            PaymentId:        payment.PaymentId,
+            Amount:           &amount,
+            Currency:         payment.Currency,
+            CreatedAt:        payment.ReceivedAt,
+        })
+        if payment.DinopayReturnPaymentId == uuid.Nil {
+            continue
+        }
+        returnPayment := Payment{
+            Direction:             DirectionOutbound,
+            DinopayPaymentId:      payment.DinopayReturnPaymentId,
+            CustomerTransactionId: inbound.ReturnPaymentId(payment.DinopayPaymentId).String(),
+            Amount:                &amount,
+            Currency:              payment.Currency,
+            CreatedAt:             payment.ReceivedAt,
+        }
+        // the return is recorded on the Payments API only for deposits credited to a customer
+        if payment.CustomerId != uuid.Nil {
+            returnPayment.PaymentId = inbound.ReturnPaymentId(payment.DinopayPaymentId)
+        }
+        payments = append(payments, returnPayment)
+    }
+    return payments, nil
+}
+
+// relevantTo returns the payments in the statement or created within its period
+func relevantTo(statement Statement, payments []Payment) []Payment {
+    from, to := statement.Period()
+    inStatement := make(map[string]bool)
+    for _, line := range statement.Lines {
+        inStatement[line.DinopayPaymentId.String()] = true
+        if line.CustomerTransactionId != "" {
+            inStatement[line.CustomerTransactionId] = true
+        }
+    }
+    var relevant []Payment
+    for _, payment := range payments {
+        withinPeriod := !payment.CreatedAt.Before(from) && !payment.CreatedAt.After(to)
+        if !withinPeriod && !inStatement[payment.DinopayPaymentId.String()] && !inStatement[payment.CustomerTransactionId] {
+            continue
+        }
+        relevant = append(relevant, payment)
+    }
+    return relevant
+}
+
+// paymentsRecords looks up the Payments API records of the given payments,
+// lookupConcurrency at a time. The payments without a record are left out.
+func (r *Reconciler) paymentsRecords(ctx context.Context, paymentIds []uuid.UUID) (map[uuid.UUID]*Record, werrors.WError) {
+    var mutex sync.Mutex
+    records := make(map[uuid.UUID]*Record, len(paymentIds))
+    group, groupCtx := errgroup.WithContext(ctx)
+    group.SetLimit(max(r.lookupConcurrency, 1))
+    for _, paymentId := range paymentIds {
+        group.Go(func() error {
+            record, werr := r.paymentsRecord(groupCtx, paymentId)
+            if werr != nil {
+                return werr
+            }
+            if record != nil {
+                mutex.Lock()
+                records[paymentId] = record
+                mutex.Unlock()
+            }
+            return nil
+        })
+    }
+    err := group.Wait()
+    if err != nil {
+        return nil, err.(werrors.WError)
+    }
+    return records, nil
+}
+
+func (r *Reconciler) paymentsRecord(ctx context.Context, paymentId uuid.UUID) (*Record, werrors.WError) {
+    resp, err := r.paymentsClient.GetPayment(ctx, paymentsapi.GetPaymentParams{PaymentId: paymentId})
+    if err != nil {
+        return nil, werrors.NewRetryableInternalError("failed getting payment " + paymentId.String() + " from payments api: " + err.Error())
+    }
+    switch resp := resp.(type) {
+    case *paymentsapi.Payment:
+        currency := string(resp.Currency)
+        amount, err := money.FromFloat(resp.Amount, currency)
+        if err != nil {
+            // keep the amount as is so that it's reported as a mismatch
+            amount = decimal.NewFromFloat(resp.Amount)
+        }
+        return &Record{Amount: amount, Currency: currency}, nil
+    case *paymentsapi.GetPaymentNotFound:
+        return nil, nil
+    case *paymentsapi.GetPaymentUnauthorized:
+        return nil, werrors.NewRetryableInternalError("unauthorized to get payment from payments api")
+    default:
+        return nil, werrors.NewRetryableInternalError("payments api failed getting payment " + paymentId.String())
+    }
+}
+
+func (r *Reconciler) recordMismatches(ctx context.Context, report *Report) werrors.WError {
+    now := time.Now().UnixMilli()
+    var reconciliationEvents []events.EventData
+    for _, item := range report.Mismatches() {
+        reconciliationEvents = append(reconciliationEvents, MismatchFound{
+            Id:                    wuuid.NewUUID(),
+            StatementId:           report.StatementId,
+            Kind:                  item.Kind,
+            Direction:             item.Direction,
+            DinopayPaymentId:      item.DinopayPaymentId,
+            CustomerTransactionId: item.CustomerTransactionId,
+            PaymentId:             item.PaymentId,
+            Currency:              item.Currency,
+            DinopayAmount:         item.DinopayAmount,
+            GatewayAmount:         item.GatewayAmount,
+            Details:               item.Details,
+            EventCreatedAt:        now,
+        })
+    }
+    reconciliationEvents = append(reconciliationEvents, StatementReconciled{
+        Id:               wuuid.NewUUID(),
+        StatementId:      report.StatementId,
+        StatementName:    report.StatementName,
+        Matched:          len(report.Matched),
+        AmountMismatches: len(report.AmountMismatches),
+        MissingOnGateway: len(report.MissingOnGateway),
+        MissingAtDinopay: len(report.MissingAtDinopay),
+        EventCreatedAt:   now,
+    })
+    _, werr := r.db.AppendEvents(
+        ctx,
+        gateway.BuildReconciliationStreamName(report.StatementId),
+        eventsourcing.ExpectedAggregateVersion{IsNew: true},
+        reconciliationEvents...,
+    )
+    return werr
+}
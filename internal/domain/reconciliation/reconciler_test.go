@@ -0,0 +1,88 @@
+package reconciliation
+
+import (
+    "context"
+    "log/slog"
+    "sync"
+    "testing"
+    "time"
+
+    "github.com/google/uuid"
+    "github.com/shopspring/decimal"
+    "github.com/stretchr/testify/require"
+    "github.com/walletera/dinopay-gateway/internal/domain/events/walletera/gateway"
+    "github.com/walletera/dinopay-gateway/internal/domain/events/walletera/gateway/inbound"
+    "github.com/walletera/dinopay-gateway/internal/testutil"
+    "github.com/walletera/eventskit/eventsourcing"
+    paymentsapi "github.com/walletera/payments-types/privateapi"
+)
+
+type fakePaymentsClient struct {
+    mutex   sync.Mutex
+    records map[uuid.UUID]*paymentsapi.Payment
+    lookups map[uuid.UUID]int
+}
+
+func (c *fakePaymentsClient) GetPayment(_ context.Context, params paymentsapi.GetPaymentParams) (paymentsapi.GetPaymentRes, error) {
+    c.mutex.Lock()
+    defer c.mutex.Unlock()
+    c.lookups[params.PaymentId]++
+    record, ok := c.records[params.PaymentId]
+    if !ok {
+        return &paymentsapi.GetPaymentNotFound{}, nil
+    }
+    return record, nil
+}
+
+func TestReconciler_ReconcileAll(t *testing.T) {
+    ctx := context.Background()
+    db := testutil.NewFakeDB()
+    paymentsClient := &fakePaymentsClient{
+        records: make(map[uuid.UUID]*paymentsapi.Payment),
+        lookups: make(map[uuid.UUID]int),
+    }
+    day := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+    var lines []Line
+    for i := 0; i < 3; i++ {
+        dinopayPaymentId, paymentId := uuid.New(), uuid.New()
+        amount := decimal.RequireFromString("100.50")
+        _, werr := db.AppendEvents(
+            ctx,
+            gateway.BuildInboundPaymentStreamName(dinopayPaymentId.String()),
+            eventsourcing.ExpectedAggregateVersion{IsNew: true},
+            inbound.PaymentReceived{
+                Id:               uuid.New(),
+                DinopayPaymentId: dinopayPaymentId,
+                CustomerId:       uuid.New(),
+                PaymentId:        paymentId,
+                Amount:           amount,
+                Currency:         "ARS",
+                EventCreatedAt:   day.Add(time.Hour),
+            },
+        )
+        require.Nil(t, werr)
+        paymentsClient.records[paymentId] = &paymentsapi.Payment{ID: paymentId, Amount: 100.5, Currency: "ARS"}
+        lines = append(lines, Line{DinopayPaymentId: dinopayPaymentId, Amount: amount, Currency: "ARS", Date: day})
+    }
+    reconciler := NewReconciler(db, paymentsClient, "$ce-outboundPayment", "$ce-inboundPayment", slog.Default(), WithLookupConcurrency(2))
+
+    // both statements cover the same day, so every deposit is relevant to both
+    reports, werr := reconciler.ReconcileAll(ctx, []Statement{
+        {Id: "statement-1", Name: "statement-1.csv", Lines: lines[:2]},
+        {Id: "statement-2", Name: "statement-2.csv", Lines: lines[1:]},
+    })
+    require.Nil(t, werr)
+    require.Len(t, reports, 2)
+    require.Len(t, reports[0].Matched, 2)
+    require.Len(t, reports[0].MissingAtDinopay, 1)
+    require.Len(t, reports[1].Matched, 2)
+    require.Len(t, reports[1].MissingAtDinopay, 1)
+
+    // every payments api record is looked up once
+    require.Len(t, paymentsClient.lookups, 3)
+    for _, lookups := range paymentsClient.lookups {
+        require.Equal(t, 1, lookups)
+    }
+    require.NotNil(t, db.Stream(gateway.BuildReconciliationStreamName("statement-1")))
+    require.NotNil(t, db.Stream(gateway.BuildReconciliationStreamName("statement-2")))
+}
@@ -0,0 +1,155 @@
+// Package reconciliation matches the DinoPay settlement statements against
+// the gateway streams and the Payments API records.
+package reconciliation
+
+import (
+    "bytes"
+    "crypto/sha256"
+    "encoding/csv"
+    "encoding/hex"
+    "encoding/json"
+    "errors"
+    "fmt"
+    "io"
+    "path/filepath"
+    "strings"
+    "time"
+
+    "github.com/google/uuid"
+    "github.com/shopspring/decimal"
+)
+
+var ErrUnsupportedFormat = errors.New("unsupported statement format")
+
+var csvColumns = []string{"dinopay_payment_id", "customer_transaction_id", "amount", "currency", "date"}
+
+// Line is a DinoPay payment reported in a settlement statement
+type Line struct {
+    DinopayPaymentId      uuid.UUID       `json:"dinopayPaymentId"`
+    CustomerTransactionId string          `json:"customerTransactionId,omitempty"`
+    Amount                decimal.Decimal `json:"amount"`
+    Currency              string          `json:"currency"`
+    Date                  time.Time       `json:"date"`
+}
+
+// Statement is a DinoPay settlement statement. Its Id is derived
+// from the content so that importing a file twice is detected.
+type Statement struct {
+    Id    string `json:"id"`
+    Name  string `json:"name"`
+    Lines []Line `json:"lines"`
+}
+
+// Period returns the dates of the oldest and the newest lines of the statement.
+// A newest date without time covers the whole day.
+func (s Statement) Period() (time.Time, time.Time) {
+    var from, to time.Time
+    for _, line := range s.Lines {
+        if from.IsZero() || line.Date.Before(from) {
+            from = line.Date
+        }
+        if line.Date.After(to) {
+            to = line.Date
+        }
+    }
+    if !to.IsZero() && to.Equal(to.Truncate(24*time.Hour)) {
+        to = to.Add(24*time.Hour - time.Nanosecond)
+    }
+    return from, to
+}
+
+// ParseStatement parses a statement in CSV or JSON, depending on the extension of its name.
+// CSV statements have a header with the columns dinopay_payment_id, customer_transaction_id,
+// amount, currency and date. JSON statements are an array of Line.
+func ParseStatement(name string, content []byte) (Statement, error) {
+    var lines []Line
+    var err error
+    switch strings.ToLower(filepath.Ext(name)) {
+    case ".csv":
+        lines, err = parseCSV(bytes.NewReader(content))
+    case ".json":
+        err = json.Unmarshal(content, &lines)
+    default:
+        return Statement{}, fmt.Errorf("%w: %s", ErrUnsupportedFormat, name)
+    }
+    if err != nil {
+        return Statement{}, fmt.Errorf("failed parsing statement %s: %w", name, err)
+    }
+    for i, line := range lines {
+        if line.DinopayPaymentId == uuid.Nil && line.CustomerTransactionId == "" {
+            return Statement{}, fmt.Errorf("statement %s line %d has neither dinopay payment id nor customer transaction id", name, i+1)
+        }
+        if line.Currency == "" || line.Date.IsZero() {
+            return Statement{}, fmt.Errorf("statement %s line %d misses the currency or the date", name, i+1)
+        }
+    }
+    sum := sha256.Sum256(content)
+    return Statement{
+        Id:    hex.EncodeToString(sum[:16]),
+        Name:  name,
+        Lines: lines,
+    }, nil
+}
+
+func parseCSV(r io.Reader) ([]Line, error) {
+    reader := csv.NewReader(r)
+    reader.TrimLeadingSpace = true
+    header, err := reader.Read()
+    if err != nil {
+        return nil, fmt.Errorf("failed reading header: %w", err)
+    }
+    index := make(map[string]int)
+    for i, column := range header {
+        index[strings.ToLower(strings.TrimSpace(column))] = i
+    }
+    for _, column := range csvColumns {
+        if _, ok := index[column]; !ok {
+            return nil, fmt.Errorf("missing column %s", column)
+        }
+    }
+    var lines []Line
+    for {
+        record, err := reader.Read()
+        if errors.Is(err, io.EOF) {
+            return lines, nil
+        }
+        if err != nil {
+            return nil, err
+        }
+        line, err := parseCSVRecord(record, index)
+        if err != nil {
+            return nil, fmt.Errorf("line %d: %w", len(lines)+1, err)
+        }
+        lines = append(lines, line)
+    }
+}
+
+func parseCSVRecord(record []string, index map[string]int) (Line, error) {
+    var line Line
+    var err error
+    if id := record[index["dinopay_payment_id"]]; id != "" {
+        line.DinopayPaymentId, err = uuid.Parse(id)
+        if err != nil {
+            return Line{}, fmt.Errorf("invalid dinopay_payment_id: %w", err)
+        }
+    }
+    line.CustomerTransactionId = record[index["customer_transaction_id"]]
+    line.Amount, err = decimal.NewFromString(record[index["amount"]])
+    if err != nil {
+        return Line{}, fmt.Errorf("invalid amount: %w", err)
+    }
+    line.Currency = record[index["currency"]]
+    line.Date, err = parseDate(record[index["date"]])
+    if err != nil {
+        return Line{}, fmt.Errorf("invalid date: %w", err)
+    }
+    return line, nil
+}
+
+func parseDate(value string) (time.Time, error) {
+    date, err := time.Parse(time.RFC3339, value)
+    if err == nil {
+        return date, nil
+    }
+    return time.Parse(time.DateOnly, value)
+}
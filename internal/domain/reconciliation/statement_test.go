@@ -0,0 +1,75 @@
+package reconciliation
+
+import (
+    "errors"
+    "testing"
+    "time"
+
+    "github.com/google/uuid"
+    "github.com/shopspring/decimal"
+    "github.com/stretchr/testify/require"
+)
+
+func TestParseStatement(t *testing.T) {
+    dinopayPaymentId := uuid.MustParse("0ae1733e-7538-4908-b90a-5721670cb093")
+    wantLine := Line{
+        DinopayPaymentId:      dinopayPaymentId,
+        CustomerTransactionId: "9fd3bc09-99da-4486-950a-11082f5fd966",
+        Amount:                decimal.RequireFromString("100.50"),
+        Currency:              "ARS",
+        Date:                  time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC),
+    }
+    tests := []struct {
+        name      string
+        fileName  string
+        content   string
+        wantLines []Line
+        wantErr   error
+    }{
+        {
+            name:     "csv statement",
+            fileName: "settlement.csv",
+            content: "dinopay_payment_id,customer_transaction_id,amount,currency,date\n" +
+                "0ae1733e-7538-4908-b90a-5721670cb093,9fd3bc09-99da-4486-950a-11082f5fd966,100.50,ARS,2024-03-01\n",
+            wantLines: []Line{wantLine},
+        },
+        {
+            name:     "json statement",
+            fileName: "settlement.JSON",
+            content: `[{"dinopayPaymentId":"0ae1733e-7538-4908-b90a-5721670cb093",` +
+                `"customerTransactionId":"9fd3bc09-99da-4486-950a-11082f5fd966",` +
+                `"amount":"100.50","currency":"ARS","date":"2024-03-01T00:00:00Z"}]`,
+            wantLines: []Line{wantLine},
+        },
+        {
+            name:     "csv statement missing a column",
+            fileName: "settlement.csv",
+            content:  "dinopay_payment_id,amount,currency,date\n",
+            wantErr:  errors.New("missing column customer_transaction_id"),
+        },
+        {
+            name:     "unsupported format",
+            fileName: "settlement.xlsx",
+            wantErr:  ErrUnsupportedFormat,
+        },
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            statement, err := ParseStatement(tt.fileName, []byte(tt.content))
+            if tt.wantErr != nil {
+                require.ErrorContains(t, err, tt.wantErr.Error())
+                return
+            }
+            require.NoError(t, err)
+            require.NotEmpty(t, statement.Id)
+            require.Len(t, statement.Lines, len(tt.wantLines))
+            for i, line := range statement.Lines {
+                require.Equal(t, tt.wantLines[i].DinopayPaymentId, line.DinopayPaymentId)
+                require.Equal(t, tt.wantLines[i].CustomerTransactionId, line.CustomerTransactionId)
+                require.True(t, tt.wantLines[i].Amount.Equal(line.Amount))
+                require.Equal(t, tt.wantLines[i].Currency, line.Currency)
+                require.True(t, tt.wantLines[i].Date.Equal(line.Date))
+            }
+        })
+    }
+}
@@ -0,0 +1,235 @@
+// Package risk scores the DinoPay deposits and payouts of the customers
+// with a riskport.Scorer. Every assessment is recorded in an
+// eventsourcing.DB stream per customer, which is the history the
+// next payments of the customer are scored with. Only the last
+// assessments of a customer are kept, so reading the history is bounded.
+package risk
+
+import (
+    "context"
+    "encoding/json"
+    "fmt"
+    "log/slog"
+    "strings"
+    "time"
+
+    "github.com/google/uuid"
+    "github.com/walletera/dinopay-gateway/internal/domain/events/walletera/gateway"
+    riskport "github.com/walletera/dinopay-gateway/internal/domain/ports/output/risk"
+    "github.com/walletera/dinopay-gateway/pkg/logattr"
+    "github.com/walletera/eventskit/events"
+    "github.com/walletera/eventskit/eventsourcing"
+    "github.com/walletera/werrors"
+)
+
+const (
+    DefaultLookback = 30 * 24 * time.Hour
+    // DefaultMaxHistory is how many assessments of a customer are kept
+    DefaultMaxHistory = 1000
+)
+
+var _ events.EventData = PaymentAssessed{}
+
+// PaymentAssessed is appended to the stream of the customer of every scored payment
+type PaymentAssessed struct {
+    Id             uuid.UUID           `json:"id"`
+    Assessment     riskport.Assessment `json:"assessment"`
+    EventCreatedAt int64               `json:"created_at"`
+}
+
+func (p PaymentAssessed) ID() string {
+    return fmt.Sprintf("%s-%s", p.Type(), p.Id)
+}
+
+func (p PaymentAssessed) Type() string {
+    return "RiskPaymentAssessed"
+}
+
+func (p PaymentAssessed) DataContentType() string {
+    return "application/json"
+}
+
+func (p PaymentAssessed) CorrelationID() string {
+    return ""
+}
+
+func (p PaymentAssessed) AggregateVersion() uint64 {
+    return 0
+}
+
+func (p PaymentAssessed) CreatedAt() time.Time {
+    return time.UnixMilli(p.EventCreatedAt)
+}
+
+func (p PaymentAssessed) Serialize() ([]byte, error) {
+    data, err := json.Marshal(p)
+    if err != nil {
+        return nil, fmt.Errorf("failed serializing RiskPaymentAssessed event: %w", err)
+    }
+    envelope := gateway.EventEnvelope{
+        Type: "RiskPaymentAssessed",
+        Data: data,
+    }
+    return json.Marshal(envelope)
+}
+
+// StreamTrimmer is implemented by the event stores that can drop the oldest events
+// of a stream. The stream version keeps growing, only the older events are dropped.
+type StreamTrimmer interface {
+    SetStreamMaxCount(ctx context.Context, streamName string, maxCount uint64) werrors.WError
+}
+
+// Service scores the payments and records the assessments. A payment is
+// scored once: scoring it again returns the recorded decision, as long as
+// it's still one of the last assessments of the customer.
+//
+// When the event store is a StreamTrimmer, the stream of a customer keeps
+// only the last max history assessments. Otherwise it grows with every
+// assessment and the whole stream is read to score a payment.
+type Service struct {
+    db         eventsourcing.DB
+    scorer     riskport.Scorer
+    lookback   time.Duration
+    maxHistory uint64
+    now        func() time.Time
+    logger     *slog.Logger
+}
+
+type Opt func(s *Service)
+
+// WithLookback sets how old the assessments in the history given to the scorer can be
+func WithLookback(lookback time.Duration) Opt {
+    return func(s *Service) { s.lookback = lookback }
+}
+
+// WithMaxHistory sets how many assessments of a customer are kept, it must be
+// more than the payments a customer makes within the lookback
+func WithMaxHistory(maxHistory uint64) Opt {
+    return func(s *Service) { s.maxHistory = maxHistory }
+}
+
+// WithClock replaces time.Now, mostly for tests
+func WithClock(now func() time.Time) Opt {
+    return func(s *Service) { s.now = now }
+}
+
+// NewService returns a Service. With a nil scorer nothing is scored.
+func NewService(db eventsourcing.DB, scorer riskport.Scorer, logger *slog.Logger, opts ...Opt) *Service {
+    s := &Service{
+        db:         db,
+        scorer:     scorer,
+        lookback:   DefaultLookback,
+        maxHistory: DefaultMaxHistory,
+        now:        time.Now,
+        logger:     logger.With(logattr.Component("risk.Service")),
+    }
+    for _, opt := range opts {
+        opt(s)
+    }
+    return s
+}
+
+// Assess scores the payment. It returns nil when there is no scorer.
+// The CreatedAt of the payment is set by the Service.
+func (s *Service) Assess(ctx context.Context, payment riskport.Payment) (*riskport.Decision, werrors.WError) {
+    if s.scorer == nil {
+        return nil, nil
+    }
+    logger := s.logger.With(logattr.PaymentId(payment.Id), slog.String("direction", string(payment.Direction)))
+    streamName := gateway.BuildCustomerRiskStreamName(payment.CustomerId.String())
+    history, expectedVersion, werr := s.readHistory(ctx, streamName)
+    if werr != nil {
+        return nil, werr
+    }
+    var recent riskport.History
+    from := s.now().Add(-s.lookback)
+    for _, assessment := range history {
+        if assessment.Payment.Id == payment.Id && assessment.Payment.Direction == payment.Direction {
+            logger.Debug("payment already assessed")
+            return &assessment.Decision, nil
+        }
+        if !assessment.Payment.CreatedAt.Before(from) {
+            recent = append(recent, assessment)
+        }
+    }
+    payment.CreatedAt = s.now()
+    decision, err := s.scorer.Score(ctx, payment, recent)
+    if err != nil {
+        return nil, werrors.NewRetryableInternalError("failed scoring " + string(payment.Direction) + " payment " + payment.Id + ": " + err.Error())
+    }
+    _, werr = s.db.AppendEvents(ctx, streamName, expectedVersion, PaymentAssessed{
+        Id:             uuid.New(),
+        Assessment:     riskport.Assessment{Payment: payment, Decision: decision},
+        EventCreatedAt: s.now().UnixMilli(),
+    })
+    if werr != nil {
+        switch werr.Code() {
+        case werrors.ResourceAlreadyExistErrorCode, werrors.WrongResourceVersionErrorCode:
+            // another payment of the customer was assessed meanwhile, it must be scored again
+            return nil, werrors.NewRetryableInternalError("risk stream " + streamName + " changed while assessing payment " + payment.Id)
+        }
+        return nil, werrors.NewWrappedError(werr, "failed appending RiskPaymentAssessed event to stream "+streamName)
+    }
+    if uint64(len(history)) > s.maxHistory {
+        s.trim(ctx, logger, streamName)
+    }
+    if decision.Outcome != riskport.OutcomeAllow {
+        logger.Warn("payment risk assessed", slog.String("outcome", string(decision.Outcome)), slog.String("reasons", Details(decision)))
+    } else {
+        logger.Debug("payment risk assessed", slog.String("outcome", string(decision.Outcome)))
+    }
+    return &decision, nil
+}
+
+// trim drops the assessments older than the max history. Failing to do it
+// only makes the next read longer, so the error is just logged.
+func (s *Service) trim(ctx context.Context, logger *slog.Logger, streamName string) {
+    trimmer, ok := s.db.(StreamTrimmer)
+    if !ok {
+        return
+    }
+    werr := trimmer.SetStreamMaxCount(ctx, streamName, s.maxHistory)
+    if werr != nil {
+        logger.Warn("failed trimming risk stream", logattr.Error(werr.Error()))
+    }
+}
+
+func (s *Service) readHistory(ctx context.Context, streamName string) (riskport.History, eventsourcing.ExpectedAggregateVersion, werrors.WError) {
+    expectedVersion := eventsourcing.ExpectedAggregateVersion{IsNew: true}
+    retrievedEvents, werr := s.db.ReadEvents(ctx, streamName)
+    if werr != nil {
+        if werr.Code() == werrors.ResourceNotFoundErrorCode {
+            return nil, expectedVersion, nil
+        }
+        return nil, expectedVersion, werr
+    }
+    var history riskport.History
+    for _, retrievedEvent := range retrievedEvents {
+        paymentAssessed, err := deserialize(retrievedEvent.RawEvent)
+        if err != nil {
+            return nil, expectedVersion, werrors.NewNonRetryableInternalError("failed deserializing risk event: " + err.Error())
+        }
+        history = append(history, paymentAssessed.Assessment)
+        expectedVersion = eventsourcing.ExpectedAggregateVersion{Version: retrievedEvent.AggregateVersion}
+    }
+    return history, expectedVersion, nil
+}
+
+// Details describes the reasons of a decision for the operators reviewing the payment
+func Details(decision riskport.Decision) string {
+    return "risk " + string(decision.Outcome) + ": " + strings.Join(decision.Reasons, "; ")
+}
+
+func deserialize(rawEvent []byte) (PaymentAssessed, error) {
+    var envelope gateway.EventEnvelope
+    err := json.Unmarshal(rawEvent, &envelope)
+    if err != nil {
+        return PaymentAssessed{}, err
+    }
+    if envelope.Type != "RiskPaymentAssessed" {
+        return PaymentAssessed{}, fmt.Errorf("unexpected event type: %s", envelope.Type)
+    }
+    var paymentAssessed PaymentAssessed
+    err = json.Unmarshal(envelope.Data, &paymentAssessed)
+    return paymentAssessed, err
+}
@@ -0,0 +1,180 @@
+package risk
+
+import (
+    "context"
+    "fmt"
+    "log/slog"
+    "testing"
+    "time"
+
+    "github.com/google/uuid"
+    "github.com/shopspring/decimal"
+    "github.com/stretchr/testify/require"
+    "github.com/walletera/dinopay-gateway/internal/domain/mapping"
+    riskport "github.com/walletera/dinopay-gateway/internal/domain/ports/output/risk"
+    "github.com/walletera/dinopay-gateway/internal/testutil"
+)
+
+func TestRules(t *testing.T) {
+    now := time.Now()
+    rules := Rules{
+        NewLargePayoutToNewBeneficiaryRule("USD", decimal.RequireFromString("1000"), riskport.OutcomeReview),
+        NewSmallDepositsFromSourceRule("USD", decimal.RequireFromString("100"), 3, 24*time.Hour, riskport.OutcomeBlock),
+    }
+    payout := func(amount string, beneficiary string) riskport.Payment {
+        return riskport.Payment{
+            Id:           uuid.NewString(),
+            Direction:    riskport.DirectionOutbound,
+            Amount:       decimal.RequireFromString(amount),
+            Currency:     "USD",
+            Counterparty: mapping.Account{AccountNumber: beneficiary},
+            CreatedAt:    now,
+        }
+    }
+    deposit := func(amount string, sender string, at time.Time) riskport.Payment {
+        return riskport.Payment{
+            Id:           uuid.NewString(),
+            Direction:    riskport.DirectionInbound,
+            Amount:       decimal.RequireFromString(amount),
+            Currency:     "USD",
+            Counterparty: mapping.Account{AccountNumber: sender},
+            CreatedAt:    at,
+        }
+    }
+    allowed := func(payment riskport.Payment) riskport.Assessment {
+        return riskport.Assessment{Payment: payment, Decision: riskport.Decision{Outcome: riskport.OutcomeAllow}}
+    }
+    tests := []struct {
+        name        string
+        payment     riskport.Payment
+        history     riskport.History
+        wantOutcome riskport.Outcome
+    }{
+        {
+            name:        "small payout to a new beneficiary is allowed",
+            payment:     payout("999", "beneficiary-1"),
+            wantOutcome: riskport.OutcomeAllow,
+        },
+        {
+            name:        "large payout to a new beneficiary is reviewed",
+            payment:     payout("1000", "beneficiary-1"),
+            wantOutcome: riskport.OutcomeReview,
+        },
+        {
+            name:        "large payout to a known beneficiary is allowed",
+            payment:     payout("5000", "beneficiary-1"),
+            history:     riskport.History{allowed(payout("10", "beneficiary-1"))},
+            wantOutcome: riskport.OutcomeAllow,
+        },
+        {
+            name:    "a reviewed payout doesn't make the beneficiary known",
+            payment: payout("5000", "beneficiary-1"),
+            history: riskport.History{{
+                Payment:  payout("5000", "beneficiary-1"),
+                Decision: riskport.Decision{Outcome: riskport.OutcomeReview},
+            }},
+            wantOutcome: riskport.OutcomeReview,
+        },
+        {
+            name:    "many small deposits from one sender are blocked",
+            payment: deposit("50", "sender-1", now),
+            history: riskport.History{
+                allowed(deposit("20", "sender-1", now.Add(-2*time.Hour))),
+                allowed(deposit("90", "sender-1", now.Add(-time.Hour))),
+            },
+            wantOutcome: riskport.OutcomeBlock,
+        },
+        {
+            name:    "small deposits from several senders or out of the window are allowed",
+            payment: deposit("50", "sender-1", now),
+            history: riskport.History{
+                allowed(deposit("20", "sender-1", now.Add(-48*time.Hour))),
+                allowed(deposit("90", "sender-2", now.Add(-time.Hour))),
+                allowed(deposit("500", "sender-1", now.Add(-time.Hour))),
+            },
+            wantOutcome: riskport.OutcomeAllow,
+        },
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            decision, err := rules.Score(context.Background(), tt.payment, tt.history)
+            require.NoError(t, err)
+            require.Equal(t, tt.wantOutcome, decision.Outcome)
+            if tt.wantOutcome == riskport.OutcomeAllow {
+                require.Empty(t, decision.Reasons)
+            } else {
+                require.Len(t, decision.Reasons, 1)
+            }
+        })
+    }
+}
+
+func TestService_AssessesEveryPaymentOnce(t *testing.T) {
+    db := testutil.NewFakeDB()
+    rules := Rules{NewLargePayoutToNewBeneficiaryRule("USD", decimal.RequireFromString("1000"), riskport.OutcomeReview)}
+    service := NewService(db, rules, slog.New(slog.DiscardHandler))
+    customerId := uuid.New()
+    payout := func(id string, amount string) riskport.Payment {
+        return riskport.Payment{
+            Id:           id,
+            Direction:    riskport.DirectionOutbound,
+            CustomerId:   customerId,
+            Amount:       decimal.RequireFromString(amount),
+            Currency:     "USD",
+            Counterparty: mapping.Account{AccountNumber: "beneficiary-1"},
+        }
+    }
+    ctx := context.Background()
+
+    decision, werr := service.Assess(ctx, payout("payout-1", "5000"))
+    require.Nil(t, werr)
+    require.Equal(t, riskport.OutcomeReview, decision.Outcome)
+
+    decision, werr = service.Assess(ctx, payout("payout-2", "10"))
+    require.Nil(t, werr)
+    require.Equal(t, riskport.OutcomeAllow, decision.Outcome)
+
+    // the beneficiary is known now, but the first payout keeps its decision
+    decision, werr = service.Assess(ctx, payout("payout-1", "5000"))
+    require.Nil(t, werr)
+    require.Equal(t, riskport.OutcomeReview, decision.Outcome)
+
+    decision, werr = service.Assess(ctx, payout("payout-3", "5000"))
+    require.Nil(t, werr)
+    require.Equal(t, riskport.OutcomeAllow, decision.Outcome)
+    require.Len(t, db.Stream("riskCustomer."+customerId.String()), 3)
+}
+
+func TestService_WithoutScorerNothingIsAssessed(t *testing.T) {
+    db := testutil.NewFakeDB()
+    service := NewService(db, nil, slog.New(slog.DiscardHandler))
+
+    decision, werr := service.Assess(context.Background(), riskport.Payment{Id: "payout-1", CustomerId: uuid.New()})
+    require.Nil(t, werr)
+    require.Nil(t, decision)
+    require.Zero(t, db.StreamCount())
+}
+
+func TestService_KeepsTheLastAssessments(t *testing.T) {
+    db := testutil.NewFakeDB()
+    service := NewService(db, Rules{}, slog.New(slog.DiscardHandler), WithMaxHistory(2))
+    customerId := uuid.New()
+    ctx := context.Background()
+    for i := range 4 {
+        _, werr := service.Assess(ctx, riskport.Payment{
+            Id:         fmt.Sprintf("payout-%d", i),
+            Direction:  riskport.DirectionOutbound,
+            CustomerId: customerId,
+            Amount:     decimal.RequireFromString("10"),
+            Currency:   "USD",
+        })
+        require.Nil(t, werr)
+        require.LessOrEqual(t, len(db.Stream("riskCustomer."+customerId.String())), 3, "the history must stay bounded")
+    }
+
+    // the assessments kept are still found, the stream version keeps growing
+    decision, werr := service.Assess(ctx, riskport.Payment{Id: "payout-3", Direction: riskport.DirectionOutbound, CustomerId: customerId})
+    require.Nil(t, werr)
+    require.Equal(t, riskport.OutcomeAllow, decision.Outcome)
+    require.Len(t, db.Stream("riskCustomer."+customerId.String()), 2)
+}
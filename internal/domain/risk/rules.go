@@ -0,0 +1,91 @@
+package risk
+
+import (
+    "context"
+    "strconv"
+    "time"
+
+    "github.com/shopspring/decimal"
+    riskport "github.com/walletera/dinopay-gateway/internal/domain/ports/output/risk"
+)
+
+// Rule scores a payment given the history of its customer.
+// It returns the outcome and its reason when it applies.
+type Rule interface {
+    Evaluate(payment riskport.Payment, history riskport.History) (riskport.Outcome, string, bool)
+}
+
+// RuleFunc adapts a function to the Rule interface
+type RuleFunc func(payment riskport.Payment, history riskport.History) (riskport.Outcome, string, bool)
+
+func (f RuleFunc) Evaluate(payment riskport.Payment, history riskport.History) (riskport.Outcome, string, bool) {
+    return f(payment, history)
+}
+
+// Rules is a local rules engine. Every rule is evaluated, the most severe
+// outcome wins and the reasons of all the rules that applied are kept.
+type Rules []Rule
+
+var _ riskport.Scorer = Rules(nil)
+
+func (rules Rules) Score(_ context.Context, payment riskport.Payment, history riskport.History) (riskport.Decision, error) {
+    decision := riskport.Decision{Outcome: riskport.OutcomeAllow}
+    for _, rule := range rules {
+        outcome, reason, applies := rule.Evaluate(payment, history)
+        if !applies {
+            continue
+        }
+        if outcome.Severity() > decision.Outcome.Severity() {
+            decision.Outcome = outcome
+        }
+        decision.Reasons = append(decision.Reasons, reason)
+    }
+    return decision, nil
+}
+
+// NewLargePayoutToNewBeneficiaryRule applies to the payouts in currency of at
+// least minAmount sent to a beneficiary the customer never paid successfully
+func NewLargePayoutToNewBeneficiaryRule(currency string, minAmount decimal.Decimal, outcome riskport.Outcome) Rule {
+    return RuleFunc(func(payment riskport.Payment, history riskport.History) (riskport.Outcome, string, bool) {
+        if payment.Direction != riskport.DirectionOutbound || payment.Currency != currency || payment.Amount.LessThan(minAmount) {
+            return "", "", false
+        }
+        for _, assessment := range history {
+            past := assessment.Payment
+            if past.Direction == riskport.DirectionOutbound &&
+                past.Counterparty.AccountNumber == payment.Counterparty.AccountNumber &&
+                assessment.Decision.Outcome == riskport.OutcomeAllow {
+                return "", "", false
+            }
+        }
+        return outcome, "payout of " + payment.Amount.String() + " " + currency +
+            " to new beneficiary " + payment.Counterparty.AccountNumber + ", large payouts start at " + minAmount.String(), true
+    })
+}
+
+// NewSmallDepositsFromSourceRule applies when a customer receives maxCount or more
+// deposits in currency of at most maxAmount from the same sender within window,
+// the deposit being scored included
+func NewSmallDepositsFromSourceRule(currency string, maxAmount decimal.Decimal, maxCount int, window time.Duration, outcome riskport.Outcome) Rule {
+    isSmallDeposit := func(payment riskport.Payment) bool {
+        return payment.Direction == riskport.DirectionInbound && payment.Currency == currency && !payment.Amount.GreaterThan(maxAmount)
+    }
+    return RuleFunc(func(payment riskport.Payment, history riskport.History) (riskport.Outcome, string, bool) {
+        if !isSmallDeposit(payment) {
+            return "", "", false
+        }
+        count := 1
+        from := payment.CreatedAt.Add(-window)
+        for _, assessment := range history {
+            past := assessment.Payment
+            if isSmallDeposit(past) && past.Counterparty.AccountNumber == payment.Counterparty.AccountNumber && !past.CreatedAt.Before(from) {
+                count++
+            }
+        }
+        if count < maxCount {
+            return "", "", false
+        }
+        return outcome, strconv.Itoa(count) + " deposits of at most " + maxAmount.String() + " " + currency +
+            " from " + payment.Counterparty.AccountNumber + " in the last " + window.String(), true
+    })
+}
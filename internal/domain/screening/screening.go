@@ -0,0 +1,73 @@
+// Package screening screens the counterparties of DinoPay payments against
+// sanctions and watchlists. The results, hit or clean, are recorded for audit
+// in the stream of the payment, along with the event acting upon them.
+package screening
+
+import (
+    "context"
+    "log/slog"
+    "strconv"
+
+    screeningport "github.com/walletera/dinopay-gateway/internal/domain/ports/output/screening"
+    "github.com/walletera/dinopay-gateway/pkg/logattr"
+    "github.com/walletera/werrors"
+)
+
+// Party is the counterparty of a payment that is screened
+type Party string
+
+const (
+    // PartySource is the sender of an inbound payment
+    PartySource Party = "source"
+    // PartyBeneficiary is the receiver of an outbound payment
+    PartyBeneficiary Party = "beneficiary"
+)
+
+// Service screens payment counterparties with a screeningport.Screener
+type Service struct {
+    screener screeningport.Screener
+    logger   *slog.Logger
+}
+
+// NewService returns a Service. With a nil screener nothing is screened.
+func NewService(screener screeningport.Screener, logger *slog.Logger) *Service {
+    return &Service{
+        screener: screener,
+        logger:   logger.With(logattr.Component("screening.Service")),
+    }
+}
+
+// Screen screens the name of the party of the payment. It returns nil when
+// nothing is screened, so there is no result to record.
+func (s *Service) Screen(ctx context.Context, paymentId string, party Party, name string) (*screeningport.Result, werrors.WError) {
+    if s.screener == nil {
+        return nil, nil
+    }
+    logger := s.logger.With(logattr.PaymentId(paymentId))
+    result, err := s.screener.Screen(ctx, name)
+    if err != nil {
+        return nil, werrors.NewRetryableInternalError("failed screening " + string(party) + " of payment " + paymentId + ": " + err.Error())
+    }
+    if result.Hit {
+        logger.Warn("payment party matches the sanctions list",
+            slog.String("party", string(party)),
+            slog.String("list_version", result.ListVersion),
+            slog.Int("matches", len(result.Matches)),
+        )
+    } else {
+        logger.Debug("payment party screened clean", slog.String("party", string(party)))
+    }
+    return &result, nil
+}
+
+// Details describes the matches of a hit for the operators reviewing the payment
+func Details(party Party, name string, result screeningport.Result) string {
+    details := string(party) + " " + name + " matches the sanctions list " + result.ListVersion + ":"
+    for i, match := range result.Matches {
+        if i > 0 {
+            details += ","
+        }
+        details += " " + match.EntryId + " " + match.Name + " (" + strconv.FormatFloat(match.Score, 'f', 2, 64) + ")"
+    }
+    return details
+}
@@ -0,0 +1,60 @@
+package screening
+
+import (
+    "context"
+    "errors"
+    "log/slog"
+    "testing"
+
+    "github.com/stretchr/testify/require"
+    screeningport "github.com/walletera/dinopay-gateway/internal/domain/ports/output/screening"
+)
+
+type fakeScreener struct {
+    result  screeningport.Result
+    err     error
+    screens int
+}
+
+func (s *fakeScreener) Screen(_ context.Context, _ string) (screeningport.Result, error) {
+    s.screens++
+    return s.result, s.err
+}
+
+func TestService_Screen(t *testing.T) {
+    hit := screeningport.Result{
+        Hit:         true,
+        Matches:     []screeningport.Match{{EntryId: "SDN-1", Name: "Ivan Petrov", Score: 0.95}},
+        ListVersion: "v1",
+    }
+    screener := &fakeScreener{result: hit}
+    service := NewService(screener, slog.New(slog.DiscardHandler))
+
+    result, werr := service.Screen(context.Background(), "payment-1", PartyBeneficiary, "Ivan Petrof")
+    require.Nil(t, werr)
+    require.NotNil(t, result)
+    require.Equal(t, hit, *result)
+
+    screener.result = screeningport.Result{ListVersion: "v1"}
+    result, werr = service.Screen(context.Background(), "payment-2", PartyBeneficiary, "Maria Gonzalez")
+    require.Nil(t, werr)
+    require.NotNil(t, result, "a clean result is recorded too")
+    require.False(t, result.Hit)
+    require.Equal(t, 2, screener.screens)
+}
+
+func TestService_ScreeningFailuresAreRetryable(t *testing.T) {
+    service := NewService(&fakeScreener{err: errors.New("list not found")}, slog.New(slog.DiscardHandler))
+
+    _, werr := service.Screen(context.Background(), "payment-1", PartySource, "Ivan Petrov")
+    require.NotNil(t, werr)
+    require.True(t, werr.IsRetryable())
+}
+
+func TestService_WithoutScreenerNothingIsScreened(t *testing.T) {
+    service := NewService(nil, slog.New(slog.DiscardHandler))
+
+    result, werr := service.Screen(context.Background(), "payment-1", PartySource, "Ivan Petrov")
+    require.Nil(t, werr)
+    require.Nil(t, result)
+}
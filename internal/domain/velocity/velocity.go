@@ -0,0 +1,296 @@
+// Package velocity limits how many outbound payouts, and how much money, a
+// customer can send or a beneficiary can receive within a rolling window.
+// Every submitted payout is recorded in an eventsourcing.DB stream per customer and
+// per beneficiary account, so the limits hold across the replicas of the gateway.
+package velocity
+
+import (
+    "context"
+    "encoding/json"
+    "fmt"
+    "strconv"
+    "time"
+
+    "github.com/google/uuid"
+    "github.com/shopspring/decimal"
+    "github.com/walletera/dinopay-gateway/internal/domain/events/walletera/gateway"
+    "github.com/walletera/eventskit/events"
+    "github.com/walletera/eventskit/eventsourcing"
+    "github.com/walletera/werrors"
+)
+
+var _ events.EventData = PayoutRecorded{}
+
+// PayoutRecorded is appended to the customer and beneficiary streams of every submitted payout
+type PayoutRecorded struct {
+    Id             uuid.UUID       `json:"id"`
+    PaymentId      uuid.UUID       `json:"withdrawal_id"`
+    Amount         decimal.Decimal `json:"amount"`
+    Currency       string          `json:"currency"`
+    EventCreatedAt int64           `json:"created_at"`
+}
+
+func (p PayoutRecorded) ID() string {
+    return fmt.Sprintf("%s-%s", p.Type(), p.Id)
+}
+
+func (p PayoutRecorded) Type() string {
+    return "VelocityPayoutRecorded"
+}
+
+func (p PayoutRecorded) DataContentType() string {
+    return "application/json"
+}
+
+func (p PayoutRecorded) CorrelationID() string {
+    return ""
+}
+
+func (p PayoutRecorded) AggregateVersion() uint64 {
+    return 0
+}
+
+func (p PayoutRecorded) CreatedAt() time.Time {
+    return time.UnixMilli(p.EventCreatedAt)
+}
+
+func (p PayoutRecorded) Serialize() ([]byte, error) {
+    data, err := json.Marshal(p)
+    if err != nil {
+        return nil, fmt.Errorf("failed serializing VelocityPayoutRecorded event: %w", err)
+    }
+    envelope := gateway.EventEnvelope{
+        Type: "VelocityPayoutRecorded",
+        Data: data,
+    }
+    return json.Marshal(envelope)
+}
+
+// Scope is who a Limit applies to
+type Scope string
+
+const (
+    ScopeCustomer    Scope = "customer"
+    ScopeBeneficiary Scope = "beneficiary"
+)
+
+// Limit caps the payouts in Currency of every customer or beneficiary within
+// the rolling Window. A zero MaxCount or a nil MaxAmount means unbounded.
+type Limit struct {
+    Scope     Scope
+    Currency  string
+    Window    time.Duration
+    MaxCount  int
+    MaxAmount *decimal.Decimal
+}
+
+// Payout is the outbound payment checked against the limits
+type Payout struct {
+    PaymentId          uuid.UUID
+    CustomerId         uuid.UUID
+    BeneficiaryAccount string
+    Amount             decimal.Decimal
+    Currency           string
+}
+
+// Breach is the Limit a payout would break
+type Breach struct {
+    Limit Limit
+    // Count and Amount are the payouts already recorded within the window
+    Count  int
+    Amount decimal.Decimal
+}
+
+func (b Breach) Details() string {
+    subject := string(b.Limit.Scope)
+    if b.Limit.MaxCount > 0 && b.Count+1 > b.Limit.MaxCount {
+        return subject + " already made " + strconv.Itoa(b.Count) + " " + b.Limit.Currency +
+            " payouts in the last " + b.Limit.Window.String() + ", the limit is " + strconv.Itoa(b.Limit.MaxCount)
+    }
+    return subject + " already moved " + b.Amount.String() + " " + b.Limit.Currency +
+        " in the last " + b.Limit.Window.String() + ", the limit is " + b.Limit.MaxAmount.String()
+}
+
+// Checker checks the payouts against the limits and records the submitted ones
+type Checker struct {
+    db     eventsourcing.DB
+    limits []Limit
+    now    func() time.Time
+}
+
+type Opt func(c *Checker)
+
+// WithClock replaces time.Now, mostly for tests
+func WithClock(now func() time.Time) Opt {
+    return func(c *Checker) { c.now = now }
+}
+
+func NewChecker(db eventsourcing.DB, limits []Limit, opts ...Opt) *Checker {
+    c := &Checker{
+        db:     db,
+        limits: limits,
+        now:    time.Now,
+    }
+    for _, opt := range opts {
+        opt(c)
+    }
+    return c
+}
+
+type scopeStream struct {
+    scope      Scope
+    streamName string
+}
+
+func (c *Checker) streams(payout Payout) []scopeStream {
+    return []scopeStream{
+        {ScopeCustomer, gateway.BuildCustomerVelocityStreamName(payout.CustomerId.String())},
+        {ScopeBeneficiary, gateway.BuildBeneficiaryVelocityStreamName(payout.BeneficiaryAccount)},
+    }
+}
+
+// Check returns the first limit the payout breaks. It records nothing, a payout
+// only counts towards the next checks once it is recorded with Record. A payout
+// already recorded is within the limits.
+func (c *Checker) Check(ctx context.Context, payout Payout) (*Breach, werrors.WError) {
+    for _, stream := range c.streams(payout) {
+        limits := c.limitsFor(stream.scope, payout.Currency)
+        if len(limits) == 0 {
+            continue
+        }
+        h, werr := c.readHistory(ctx, stream.streamName)
+        if werr != nil {
+            return nil, werr
+        }
+        if h.contains(payout.PaymentId) {
+            continue
+        }
+        for _, limit := range limits {
+            count, amount := h.within(payout.Currency, c.now().Add(-limit.Window))
+            breached := limit.MaxCount > 0 && count+1 > limit.MaxCount
+            breached = breached || limit.MaxAmount != nil && amount.Add(payout.Amount).GreaterThan(*limit.MaxAmount)
+            if breached {
+                return &Breach{Limit: limit, Count: count, Amount: amount}, nil
+            }
+        }
+    }
+    return nil, nil
+}
+
+// Record counts the payout towards the limits, it must be called once the payout
+// was submitted. Recording a payout already recorded doesn't count it twice.
+//
+// The payouts checked concurrently with the submission of another one don't see
+// it, so the payouts of a customer submitted at the same time can go over a limit.
+func (c *Checker) Record(ctx context.Context, payout Payout) werrors.WError {
+    for _, stream := range c.streams(payout) {
+        if len(c.limitsFor(stream.scope, payout.Currency)) == 0 {
+            continue
+        }
+        h, werr := c.readHistory(ctx, stream.streamName)
+        if werr != nil {
+            return werr
+        }
+        if h.contains(payout.PaymentId) {
+            continue
+        }
+        werr = c.record(ctx, stream.streamName, h.expectedVersion, payout)
+        if werr != nil {
+            return werr
+        }
+    }
+    return nil
+}
+
+func (c *Checker) limitsFor(scope Scope, currency string) []Limit {
+    var limits []Limit
+    for _, limit := range c.limits {
+        if limit.Scope == scope && limit.Currency == currency {
+            limits = append(limits, limit)
+        }
+    }
+    return limits
+}
+
+func (c *Checker) record(ctx context.Context, streamName string, expectedVersion eventsourcing.ExpectedAggregateVersion, payout Payout) werrors.WError {
+    _, werr := c.db.AppendEvents(ctx, streamName, expectedVersion, PayoutRecorded{
+        Id:             uuid.New(),
+        PaymentId:      payout.PaymentId,
+        Amount:         payout.Amount,
+        Currency:       payout.Currency,
+        EventCreatedAt: c.now().UnixMilli(),
+    })
+    if werr != nil {
+        switch werr.Code() {
+        case werrors.ResourceAlreadyExistErrorCode, werrors.WrongResourceVersionErrorCode:
+            // another payout was recorded meanwhile, the record is retried
+            return werrors.NewRetryableInternalError("velocity stream " + streamName + " changed while recording payout " + payout.PaymentId.String())
+        }
+        return werrors.NewWrappedError(werr, "failed appending VelocityPayoutRecorded event to stream "+streamName)
+    }
+    return nil
+}
+
+type history struct {
+    payouts         []PayoutRecorded
+    expectedVersion eventsourcing.ExpectedAggregateVersion
+}
+
+func (c *Checker) readHistory(ctx context.Context, streamName string) (*history, werrors.WError) {
+    h := &history{
+        expectedVersion: eventsourcing.ExpectedAggregateVersion{IsNew: true},
+    }
+    retrievedEvents, werr := c.db.ReadEvents(ctx, streamName)
+    if werr != nil {
+        if werr.Code() == werrors.ResourceNotFoundErrorCode {
+            return h, nil
+        }
+        return nil, werr
+    }
+    for _, retrievedEvent := range retrievedEvents {
+        payout, err := deserialize(retrievedEvent.RawEvent)
+        if err != nil {
+            return nil, werrors.NewNonRetryableInternalError("failed deserializing velocity event: " + err.Error())
+        }
+        h.payouts = append(h.payouts, payout)
+        h.expectedVersion = eventsourcing.ExpectedAggregateVersion{Version: retrievedEvent.AggregateVersion}
+    }
+    return h, nil
+}
+
+func (h *history) contains(paymentId uuid.UUID) bool {
+    for _, payout := range h.payouts {
+        if payout.PaymentId == paymentId {
+            return true
+        }
+    }
+    return false
+}
+
+// within returns the number and the sum of the payouts in currency recorded since from
+func (h *history) within(currency string, from time.Time) (int, decimal.Decimal) {
+    count := 0
+    amount := decimal.Zero
+    for _, payout := range h.payouts {
+        if payout.Currency != currency || payout.CreatedAt().Before(from) {
+            continue
+        }
+        count++
+        amount = amount.Add(payout.Amount)
+    }
+    return count, amount
+}
+
+func deserialize(rawEvent []byte) (PayoutRecorded, error) {
+    var envelope gateway.EventEnvelope
+    err := json.Unmarshal(rawEvent, &envelope)
+    if err != nil {
+        return PayoutRecorded{}, err
+    }
+    if envelope.Type != "VelocityPayoutRecorded" {
+        return PayoutRecorded{}, fmt.Errorf("unexpected event type: %s", envelope.Type)
+    }
+    var payout PayoutRecorded
+    err = json.Unmarshal(envelope.Data, &payout)
+    return payout, err
+}
@@ -0,0 +1,69 @@
+package velocity
+
+import (
+    "context"
+    "testing"
+    "time"
+
+    "github.com/google/uuid"
+    "github.com/shopspring/decimal"
+    "github.com/stretchr/testify/require"
+    "github.com/walletera/dinopay-gateway/internal/testutil"
+)
+
+func TestChecker_Check(t *testing.T) {
+    ctx := context.Background()
+    db := testutil.NewFakeDB()
+    now := time.Now()
+    maxAmount := decimal.RequireFromString("1000")
+    checker := NewChecker(db, []Limit{
+        {Scope: ScopeCustomer, Currency: "USD", Window: time.Hour, MaxCount: 3},
+        {Scope: ScopeBeneficiary, Currency: "USD", Window: 24 * time.Hour, MaxAmount: &maxAmount},
+    }, WithClock(func() time.Time { return now }))
+    customerId := uuid.New()
+    payout := func(amount string, currency string, beneficiaryAccount string) Payout {
+        return Payout{
+            PaymentId:          uuid.New(),
+            CustomerId:         customerId,
+            BeneficiaryAccount: beneficiaryAccount,
+            Amount:             decimal.RequireFromString(amount),
+            Currency:           currency,
+        }
+    }
+    // check records the payouts within the limits, as if they were submitted
+    check := func(p Payout) *Breach {
+        breach, werr := checker.Check(ctx, p)
+        require.NoError(t, werr)
+        if breach == nil {
+            require.NoError(t, checker.Record(ctx, p))
+        }
+        return breach
+    }
+
+    notSubmitted := payout("900", "USD", "1200079635")
+    breach, werr := checker.Check(ctx, notSubmitted)
+    require.NoError(t, werr)
+    require.Nil(t, breach)
+
+    first := payout("600", "USD", "1200079635")
+    require.Nil(t, check(first), "a payout that was not submitted must not count")
+    require.Nil(t, check(first), "checking a recorded payout again must not count it twice")
+    require.NoError(t, checker.Record(ctx, first), "recording a payout again must not count it twice")
+
+    breach = check(payout("500", "USD", "1200079635"))
+    require.NotNil(t, breach, "beneficiary amount limit must be breached")
+    require.Equal(t, ScopeBeneficiary, breach.Limit.Scope)
+    require.True(t, decimal.RequireFromString("600").Equal(breach.Amount))
+
+    require.Nil(t, check(payout("500", "USD", "1200079636")))
+    require.Nil(t, check(payout("5000", "ARS", "1200079637")), "limits only apply to their currency")
+    require.Nil(t, check(payout("10", "USD", "1200079638")))
+
+    breach = check(payout("10", "USD", "1200079639"))
+    require.NotNil(t, breach, "customer count limit must be breached")
+    require.Equal(t, ScopeCustomer, breach.Limit.Scope)
+    require.Equal(t, 3, breach.Count)
+
+    now = now.Add(2 * time.Hour)
+    require.Nil(t, check(payout("10", "USD", "1200079639")), "payouts out of the window must not count")
+}
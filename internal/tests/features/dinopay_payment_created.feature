@@ -27,3 +27,140 @@ Feature: process DinoPay webhook event PaymentCreated
     """
     Gateway event InboundPaymentReceived processed successfully
     """
+
+  Scenario: the payment doesn't match the accountNumber of any walletera user
+    Given a DinoPay PaymentCreated event:
+    """
+    data/dinopay_payment_created_unmatched_event.json
+    """
+    And  an accounts endpoint to get accounts:
+    """
+    data/accounts_get_account_not_found_endpoint_expectation.json
+    """
+    When the webhook event is received
+    Then the dinopay-gateway produces the following log:
+    """
+    DinoPay event PaymentCreated moved to suspense
+    """
+    And the dinopay-gateway produces the following log:
+    """
+    inbound payment is waiting in suspense
+    """
+
+  Scenario: the payment already exists on the Payments API
+    Given a DinoPay PaymentCreated event:
+    """
+    data/dinopay_payment_created_payments_conflict_event.json
+    """
+    And  an accounts endpoint to get accounts:
+    """
+    data/accounts_get_account_endpoint_expectation.json
+    """
+    And  a payments endpoint to create payments:
+    """
+    data/payments_create_payments_endpoint_conflict_expectation.json
+    """
+    When the webhook event is received
+    Then the dinopay-gateway creates the corresponding payment on the Payments API
+    And the dinopay-gateway produces the following log:
+    """
+    payment already exists on payments api
+    """
+    And the dinopay-gateway produces the following log:
+    """
+    Gateway event InboundPaymentReceived processed successfully
+    """
+
+  Scenario: the Payments API rejects the payment
+    Given a DinoPay PaymentCreated event:
+    """
+    data/dinopay_payment_created_payments_400_response_event.json
+    """
+    And  an accounts endpoint to get accounts:
+    """
+    data/accounts_get_account_endpoint_expectation.json
+    """
+    And  a payments endpoint to create payments:
+    """
+    data/payments_create_payments_endpoint_400_response_expectation.json
+    """
+    When the webhook event is received
+    Then the dinopay-gateway creates the corresponding payment on the Payments API
+    And the dinopay-gateway produces the following log:
+    """
+    payments api rejected the payment: [InvalidPayment] currency USD is not supported for the beneficiary account
+    """
+
+  Scenario: the Payments API responds with a status code it doesn't declare
+    Given a DinoPay PaymentCreated event:
+    """
+    data/dinopay_payment_created_payments_404_response_event.json
+    """
+    And  an accounts endpoint to get accounts:
+    """
+    data/accounts_get_account_endpoint_expectation.json
+    """
+    And  a payments endpoint to create payments:
+    """
+    data/payments_create_payments_endpoint_404_response_expectation.json
+    """
+    When the webhook event is received
+    Then the dinopay-gateway creates the corresponding payment on the Payments API
+    And the dinopay-gateway produces the following log:
+    """
+    failed creating payment on payments api: unexpected status code: 404
+    """
+
+  Scenario: the Payments API fails creating the payment
+    Given a DinoPay PaymentCreated event:
+    """
+    data/dinopay_payment_created_payments_500_response_event.json
+    """
+    And  an accounts endpoint to get accounts:
+    """
+    data/accounts_get_account_endpoint_expectation.json
+    """
+    And  a payments endpoint to create payments:
+    """
+    data/payments_create_payments_endpoint_500_response_expectation.json
+    """
+    When the webhook event is received
+    Then the dinopay-gateway creates the corresponding payment on the Payments API
+    And the dinopay-gateway produces the following log:
+    """
+    payments api failed creating the payment: [InternalError] something bad happened
+    """
+
+  Scenario: a payment pending on DinoPay is credited as pending and confirmed later
+    Given a DinoPay PaymentCreated event:
+    """
+    data/dinopay_payment_created_pending_event.json
+    """
+    And  a DinoPay PaymentUpdated event:
+    """
+    data/dinopay_payment_updated_confirmed_event.json
+    """
+    And  an accounts endpoint to get accounts:
+    """
+    data/accounts_get_account_endpoint_expectation.json
+    """
+    And  a payments endpoint to create payments:
+    """
+    data/payments_create_payments_endpoint_pending_expectation.json
+    """
+    And  a payments endpoint to update payments:
+    """
+    data/payments_update_payments_endpoint_deposit_confirmed_expectation.json
+    """
+    When the webhook event is received
+    Then the dinopay-gateway creates the corresponding payment on the Payments API
+    When the PaymentUpdated webhook event is received
+    Then the dinopay-gateway updates the payment on payments service
+    And the dinopay-gateway produces the following log:
+    """
+    DinoPay event PaymentUpdated processed successfully
+    """
+    And the dinopay-gateway produces the following log:
+    """
+    Gateway event InboundPaymentStatusUpdated processed successfully
+    """
@@ -48,3 +48,148 @@ Feature: process PaymentCreated event
     """
     failed creating payment on dinopay
     """
+    And  the dinopay-gateway produces the following log:
+    """
+    payment outcome on dinopay is unknown
+    """
+
+  Scenario: payment created event is rejected by Dinopay
+    Given a PaymentCreated event:
+    """
+    data/payment_created_rejected_event.json
+    """
+    And  a dinopay endpoint to create payments:
+    """
+    data/dinopay_create_payments_endpoint_400_response_expectation.json
+    """
+    And  a payments endpoint to update payments:
+    """
+    data/payments_update_payments_failed_endpoint_expectation.json
+    """
+    When the event is published
+    Then the dinopay-gateway fails creating the corresponding payment on the DinoPay API
+    And the dinopay-gateway updates the payment on payments service
+    And the dinopay-gateway produces the following log:
+    """
+    dinopay rejected the payment
+    """
+    And the dinopay-gateway produces the following log:
+    """
+    OutboundPaymentFailed event processed successfully
+    """
+
+  Scenario: payment created event fails validation before reaching Dinopay
+    Given a PaymentCreated event:
+    """
+    data/payment_created_invalid_amount_event.json
+    """
+    And  a payments endpoint to update payments:
+    """
+    data/payments_update_payments_invalid_amount_failed_endpoint_expectation.json
+    """
+    When the event is published
+    Then the dinopay-gateway updates the payment on payments service
+    And the dinopay-gateway produces the following log:
+    """
+    payment failed validation
+    """
+    And the dinopay-gateway produces the following log:
+    """
+    OutboundPaymentFailed event processed successfully
+    """
+
+  Scenario: the payments service rejects the payment update
+    Given a PaymentCreated event:
+    """
+    data/payment_created_update_400_response_event.json
+    """
+    And  a dinopay endpoint to create payments:
+    """
+    data/dinopay_create_payment_update_400_response_endpoint_expectation.json
+    """
+    And  a payments endpoint to update payments:
+    """
+    data/payments_update_payments_endpoint_400_response_expectation.json
+    """
+    When the event is published
+    Then the dinopay-gateway creates the corresponding payment on the DinoPay API
+    And the dinopay-gateway updates the payment on payments service
+    And the dinopay-gateway produces the following log:
+    """
+    payments api rejected the payment update: [InvalidStatusTransition] payment can't move from confirmed to pending
+    """
+
+  Scenario: the payments service fails updating the payment
+    Given a PaymentCreated event:
+    """
+    data/payment_created_update_500_response_event.json
+    """
+    And  a dinopay endpoint to create payments:
+    """
+    data/dinopay_create_payment_update_500_response_endpoint_expectation.json
+    """
+    And  a payments endpoint to update payments:
+    """
+    data/payments_update_payments_endpoint_500_response_expectation.json
+    """
+    When the event is published
+    Then the dinopay-gateway creates the corresponding payment on the DinoPay API
+    And the dinopay-gateway updates the payment on payments service
+    And the dinopay-gateway produces the following log:
+    """
+    payments api failed updating the payment: [InternalError] something bad happened
+    """
+
+  Scenario: the payments service doesn't find the payment to update
+    Given a PaymentCreated event:
+    """
+    data/payment_created_update_404_response_event.json
+    """
+    And  a dinopay endpoint to create payments:
+    """
+    data/dinopay_create_payment_update_404_response_endpoint_expectation.json
+    """
+    And  a payments endpoint to update payments:
+    """
+    data/payments_update_payments_endpoint_404_response_expectation.json
+    """
+    When the event is published
+    Then the dinopay-gateway creates the corresponding payment on the DinoPay API
+    And the dinopay-gateway updates the payment on payments service
+    And the dinopay-gateway produces the following log:
+    """
+    failed updating payment on payments api: unexpected status code: 404
+    """
+
+  Scenario: a payment cancelled before being submitted is never created on DinoPay
+    Given a PaymentUpdated event:
+    """
+    data/payment_updated_cancelled_event.json
+    """
+    And  a payments endpoint to get payments:
+    """
+    data/payments_get_payment_cancelled_endpoint_expectation.json
+    """
+    And  a dinopay endpoint to find payments:
+    """
+    data/dinopay_find_payments_not_found_endpoint_expectation.json
+    """
+    And  a payments endpoint to update payments:
+    """
+    data/payments_update_payments_cancelled_endpoint_expectation.json
+    """
+    And  a PaymentCreated event:
+    """
+    data/payment_created_cancelled_event.json
+    """
+    When the PaymentUpdated event is published
+    Then the dinopay-gateway updates the payment on payments service
+    And  the dinopay-gateway produces the following log:
+    """
+    outbound payment cancelled before being submitted
+    """
+    When the event is published
+    Then the dinopay-gateway produces the following log:
+    """
+    payment was cancelled, held or sent for approval before being submitted to dinopay
+    """
@@ -17,6 +17,7 @@ import (
 const (
 	mockserverPort               = "2090"
 	eventStoreDBPort             = "2113"
+	postgresPort                 = "5432"
 	containersStartTimeout       = 30 * time.Second
 	containersTerminationTimeout = 10 * time.Second
 )
@@ -24,19 +25,9 @@ const (
 func TestMain(m *testing.M) {
 	ctx, _ := context.WithTimeout(context.Background(), containersStartTimeout)
 
-	terminateEventSToreDBContainer, err := startEventStoreDBContainer(ctx)
+	terminateEventStoreContainer, err := startEventStoreContainer(ctx)
 	if err != nil {
-		panic("error starting esdb container: " + err.Error())
-	}
-
-	err = eventstoredb.EnableByCategoryProjection(ctx, eventStoreDBUrl)
-	if err != nil {
-		panic("failed enabling esdb by category projection: " + err.Error())
-	}
-
-	err = eventstoredb.SetESDBByCategoryProjectionSeparator(ctx, eventStoreDBUrl)
-	if err != nil {
-		panic("failed setting esdb by category projection separator: " + err.Error())
+		panic(err.Error())
 	}
 
 	terminateRabbitMQContainer, err := startRabbitMQContainer(ctx)
@@ -51,9 +42,9 @@ func TestMain(m *testing.M) {
 
 	status := m.Run()
 
-	err = terminateEventSToreDBContainer()
+	err = terminateEventStoreContainer()
 	if err != nil {
-		panic("error terminating esdb container: " + err.Error())
+		panic("error terminating event store container: " + err.Error())
 	}
 
 	err = terminateRabbitMQContainer()
@@ -69,6 +60,77 @@ func TestMain(m *testing.M) {
 	os.Exit(status)
 }
 
+// startEventStoreContainer starts the event store of the backend selected with
+// the EVENT_STORE_BACKEND env var, EventStoreDB unless it is set to postgres
+func startEventStoreContainer(ctx context.Context) (func() error, error) {
+	if usePostgresEventStore() {
+		terminate, err := startPostgresContainer(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("error starting postgres container: %w", err)
+		}
+		return terminate, nil
+	}
+
+	terminate, err := startEventStoreDBContainer(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("error starting esdb container: %w", err)
+	}
+
+	err = eventstoredb.EnableByCategoryProjection(ctx, eventStoreDBUrl)
+	if err != nil {
+		return nil, fmt.Errorf("failed enabling esdb by category projection: %w", err)
+	}
+
+	err = eventstoredb.SetESDBByCategoryProjectionSeparator(ctx, eventStoreDBUrl)
+	if err != nil {
+		return nil, fmt.Errorf("failed setting esdb by category projection separator: %w", err)
+	}
+	return terminate, nil
+}
+
+func usePostgresEventStore() bool {
+	return os.Getenv("EVENT_STORE_BACKEND") == "postgres"
+}
+
+func startPostgresContainer(ctx context.Context) (func() error, error) {
+	req := testcontainers.ContainerRequest{
+		Image: "postgres:16-alpine",
+		Name:  "postgres",
+		Env: map[string]string{
+			"POSTGRES_USER":     "dinopay-gateway",
+			"POSTGRES_PASSWORD": "dinopay-gateway",
+			"POSTGRES_DB":       "dinopay-gateway",
+		},
+		ExposedPorts: []string{
+			fmt.Sprintf("%s:%s", postgresPort, postgresPort),
+		},
+		WaitingFor: wait.
+			ForLog("database system is ready to accept connections").
+			WithOccurrence(2).
+			WithStartupTimeout(20 * time.Second),
+		LogConsumerCfg: &testcontainers.LogConsumerConfig{
+			Consumers: []testcontainers.LogConsumer{NewContainerLogConsumer("postgres")},
+		},
+	}
+	postgresC, err := testcontainers.GenericContainer(ctx, testcontainers.GenericContainerRequest{
+		ContainerRequest: req,
+		Started:          true,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error creating postgres container: %w", err)
+	}
+
+	return func() error {
+		terminationCtx, terminationCtxCancel := context.WithTimeout(context.Background(), containersTerminationTimeout)
+		defer terminationCtxCancel()
+		terminationErr := postgresC.Terminate(terminationCtx)
+		if terminationErr != nil {
+			return fmt.Errorf("failed terminating postgres container: %w", terminationErr)
+		}
+		return nil
+	}, nil
+}
+
 func startEventStoreDBContainer(ctx context.Context) (func() error, error) {
 	req := testcontainers.ContainerRequest{
 		Image: "eventstore/eventstore:21.10.7-buster-slim",
@@ -13,6 +13,7 @@ import (
 
 const (
     rawDinopayPaymentCreatedEventKey            = "rawDinopayPaymentCreatedEventKey"
+    rawDinopayPaymentUpdatedEventKey            = "rawDinopayPaymentUpdatedEventKey"
     accountsGetAccountEndpointExpectationKey    = "accountsGetAccountEndpointExpectationKey"
     paymentsCreateDepositEndpointExpectationKey = "paymentsCreateDepositEndpointExpectationKey"
 )
@@ -39,8 +40,12 @@ func InitializeProcessDinopayPaymentCreatedScenario(ctx *godog.ScenarioContext)
     ctx.Step(`^a DinoPay PaymentCreated event:$`, aDinoPayPaymentCreatedEvent)
     ctx.Step(`^an accounts endpoint to get accounts:$`, anAccountsEndpointToGetAccounts)
     ctx.Step(`^a payments endpoint to create payments:$`, aPaymentsEndpointToCreateDeposits)
+    ctx.Step(`^a DinoPay PaymentUpdated event:$`, aDinoPayPaymentUpdatedEvent)
+    ctx.Step(`^a payments endpoint to update payments:$`, aPaymentsEndpointToUpdatePayments)
     ctx.When(`^the webhook event is received$`, theWebhookEventIsReceived)
+    ctx.When(`^the PaymentUpdated webhook event is received$`, thePaymentUpdatedWebhookEventIsReceived)
     ctx.Step(`^the dinopay-gateway creates the corresponding payment on the Payments API$`, theDinopaygatewayCreatesTheCorrespondingPaymentOnThePaymentsAPI)
+    ctx.Step(`^the dinopay-gateway updates the payment on payments service$`, theDinopayGatewayUpdatesThePaymentOnPaymentsService)
     ctx.Step(`^the dinopay-gateway produces the following log:$`, theDinopayGatewayProducesTheFollowingLog)
     ctx.After(afterScenarioHook)
 }
@@ -49,6 +54,10 @@ func aDinoPayPaymentCreatedEvent(ctx context.Context, jsonEventFilePath *godog.D
     return context.WithValue(ctx, rawDinopayPaymentCreatedEventKey, readFile(jsonEventFilePath)), nil
 }
 
+func aDinoPayPaymentUpdatedEvent(ctx context.Context, jsonEventFilePath *godog.DocString) (context.Context, error) {
+    return context.WithValue(ctx, rawDinopayPaymentUpdatedEventKey, readFile(jsonEventFilePath)), nil
+}
+
 func anAccountsEndpointToGetAccounts(ctx context.Context, mockserverExpectationFilePath *godog.DocString) (context.Context, error) {
     return createMockServerExpectation(ctx, mockserverExpectationFilePath, accountsGetAccountEndpointExpectationKey)
 }
@@ -58,7 +67,14 @@ func aPaymentsEndpointToCreateDeposits(ctx context.Context, mockserverExpectatio
 }
 
 func theWebhookEventIsReceived(ctx context.Context) (context.Context, error) {
-    rawEvent := ctx.Value(rawDinopayPaymentCreatedEventKey).([]byte)
+    return sendWebhookEvent(ctx, ctx.Value(rawDinopayPaymentCreatedEventKey).([]byte))
+}
+
+func thePaymentUpdatedWebhookEventIsReceived(ctx context.Context) (context.Context, error) {
+    return sendWebhookEvent(ctx, ctx.Value(rawDinopayPaymentUpdatedEventKey).([]byte))
+}
+
+func sendWebhookEvent(ctx context.Context, rawEvent []byte) (context.Context, error) {
     url := fmt.Sprintf("http://127.0.0.1:%d/webhooks", app.WebhookServerPort)
     httpReq, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(rawEvent))
     if err != nil {
@@ -15,8 +15,11 @@ import (
 
 const (
     rawWithdrawalCreatedEventKey                     = "rawWithdrawalCreatedEvent"
+    rawPaymentUpdatedEventKey                        = "rawPaymentUpdatedEvent"
     dinoPayEndpointCreatePaymentsExpectationIdKey    = "dinoPayEndpointCreatePaymentsExpectationId"
+    dinoPayEndpointFindPaymentsExpectationIdKey      = "dinoPayEndpointFindPaymentsExpectationId"
     paymentsEndpointUpdateWithdrawalExpectationIdKey = "paymentsEndpointUpdateWithdrawalExpectationId"
+    paymentsEndpointGetPaymentExpectationIdKey       = "paymentsEndpointGetPaymentExpectationId"
     expectationTimeout                               = 5 * time.Second
 )
 
@@ -40,9 +43,13 @@ func InitializeProcessWithdrawalCreatedScenario(ctx *godog.ScenarioContext) {
     ctx.Before(beforeScenarioHook)
     ctx.Given(`^a running dinopay-gateway$`, aRunningDinopayGateway)
     ctx.Given(`^a PaymentCreated event:$`, aPaymentCreatedEvent)
+    ctx.Given(`^a PaymentUpdated event:$`, aPaymentUpdatedEvent)
     ctx.Given(`^a dinopay endpoint to create payments:$`, aDinopayEndpointToCreatePayments)
+    ctx.Given(`^a dinopay endpoint to find payments:$`, aDinopayEndpointToFindPayments)
+    ctx.Given(`^a payments endpoint to get payments:$`, aPaymentsEndpointToGetPayments)
     ctx.Given(`^a payments endpoint to update payments:$`, aPaymentsEndpointToUpdatePayments)
     ctx.When(`^the event is published$`, theEventIsPublished)
+    ctx.When(`^the PaymentUpdated event is published$`, thePaymentUpdatedEventIsPublished)
     ctx.Then(`^the dinopay-gateway creates the corresponding payment on the DinoPay API$`, theDinopayGatewayCreatesTheCorrespondingPaymentOnTheDinoPayAPI)
     ctx.Then(`^the dinopay-gateway updates the payment on payments service$`, theDinopayGatewayUpdatesThePaymentOnPaymentsService)
     ctx.Then(`the dinopay-gateway fails creating the corresponding payment on the DinoPay API$`, theDinoPayGatewayFailsCreatingTheCorrespondingPayment)
@@ -54,15 +61,37 @@ func aPaymentCreatedEvent(ctx context.Context, eventFilePath *godog.DocString) (
     return context.WithValue(ctx, rawWithdrawalCreatedEventKey, readFile(eventFilePath)), nil
 }
 
+func aPaymentUpdatedEvent(ctx context.Context, eventFilePath *godog.DocString) (context.Context, error) {
+    return context.WithValue(ctx, rawPaymentUpdatedEventKey, readFile(eventFilePath)), nil
+}
+
 func aDinopayEndpointToCreatePayments(ctx context.Context, mockserverExpectationFilePath *godog.DocString) (context.Context, error) {
     return createMockServerExpectation(ctx, mockserverExpectationFilePath, dinoPayEndpointCreatePaymentsExpectationIdKey)
 }
 
+func aDinopayEndpointToFindPayments(ctx context.Context, mockserverExpectationFilePath *godog.DocString) (context.Context, error) {
+    return createMockServerExpectation(ctx, mockserverExpectationFilePath, dinoPayEndpointFindPaymentsExpectationIdKey)
+}
+
 func aPaymentsEndpointToUpdatePayments(ctx context.Context, mockserverExpectationFilePath *godog.DocString) (context.Context, error) {
     return createMockServerExpectation(ctx, mockserverExpectationFilePath, paymentsEndpointUpdateWithdrawalExpectationIdKey)
 }
 
+func aPaymentsEndpointToGetPayments(ctx context.Context, mockserverExpectationFilePath *godog.DocString) (context.Context, error) {
+    return createMockServerExpectation(ctx, mockserverExpectationFilePath, paymentsEndpointGetPaymentExpectationIdKey)
+}
+
 func theEventIsPublished(ctx context.Context) (context.Context, error) {
+    rawEvent := ctx.Value(rawWithdrawalCreatedEventKey).([]byte)
+    return publishPaymentsEvent(ctx, rawEvent, app.RabbitMQPaymentCreatedRoutingKey)
+}
+
+func thePaymentUpdatedEventIsPublished(ctx context.Context) (context.Context, error) {
+    rawEvent := ctx.Value(rawPaymentUpdatedEventKey).([]byte)
+    return publishPaymentsEvent(ctx, rawEvent, app.RabbitMQPaymentUpdatedRoutingKey)
+}
+
+func publishPaymentsEvent(ctx context.Context, rawEvent []byte, routingKey string) (context.Context, error) {
     publisher, err := rabbitmq.NewClient(
         rabbitmq.WithExchangeName(app.RabbitMQPaymentsExchangeName),
         rabbitmq.WithExchangeType(app.RabbitMQExchangeType),
@@ -71,13 +100,12 @@ func theEventIsPublished(ctx context.Context) (context.Context, error) {
         return nil, fmt.Errorf("error creating rabbitmq client: %s", err.Error())
     }
 
-    rawEvent := ctx.Value(rawWithdrawalCreatedEventKey).([]byte)
     err = publisher.Publish(ctx, publishable{rawEvent: rawEvent}, events.RoutingInfo{
         Topic:      app.RabbitMQPaymentsExchangeName,
-        RoutingKey: app.RabbitMQPaymentCreatedRoutingKey,
+        RoutingKey: routingKey,
     })
     if err != nil {
-        return nil, fmt.Errorf("error publishing WithdrawalCreated event to rabbitmq: %s", err.Error())
+        return nil, fmt.Errorf("error publishing %s event to rabbitmq: %s", routingKey, err.Error())
     }
 
     return ctx, nil
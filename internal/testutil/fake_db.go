@@ -0,0 +1,124 @@
+// Package testutil holds the fakes shared by the unit tests
+package testutil
+
+import (
+    "context"
+    "strings"
+    "sync"
+    "time"
+
+    "github.com/walletera/eventskit/events"
+    "github.com/walletera/eventskit/eventsourcing"
+    "github.com/walletera/werrors"
+)
+
+const categoryStreamPrefix = "$ce-"
+
+// FakeDB is an in-memory eventsourcing.DB. Reading a `$ce-<category>` stream
+// returns the events appended to every stream of the category, in append order,
+// the way the EventStoreDB by-category projection does.
+type FakeDB struct {
+    mu         sync.Mutex
+    streams    map[string][][]byte
+    trimmed    map[string]uint64
+    maxAges    map[string]time.Duration
+    categories map[string][][]byte
+}
+
+func NewFakeDB() *FakeDB {
+    return &FakeDB{
+        streams:    make(map[string][][]byte),
+        trimmed:    make(map[string]uint64),
+        maxAges:    make(map[string]time.Duration),
+        categories: make(map[string][][]byte),
+    }
+}
+
+func (db *FakeDB) AppendEvents(_ context.Context, streamName string, version eventsourcing.ExpectedAggregateVersion, eventsData ...events.EventData) (uint64, werrors.WError) {
+    db.mu.Lock()
+    defer db.mu.Unlock()
+    stream, exists := db.streams[streamName]
+    if version.IsNew && exists {
+        return 0, werrors.NewResourceAlreadyExistError("stream " + streamName + " already exists")
+    }
+    trimmed := db.trimmed[streamName]
+    if !version.IsNew && (!exists || trimmed+uint64(len(stream)-1) != version.Version) {
+        return 0, werrors.NewWrongResourceVersionError("wrong version for stream " + streamName)
+    }
+    category, _, _ := strings.Cut(streamName, ".")
+    for _, eventData := range eventsData {
+        rawEvent, err := eventData.Serialize()
+        if err != nil {
+            return 0, werrors.NewNonRetryableInternalError(err.Error())
+        }
+        stream = append(stream, rawEvent)
+        db.categories[category] = append(db.categories[category], rawEvent)
+    }
+    db.streams[streamName] = stream
+    return trimmed + uint64(len(stream)-1), nil
+}
+
+func (db *FakeDB) ReadEvents(_ context.Context, streamName string) ([]eventsourcing.RetrievedEvent, werrors.WError) {
+    db.mu.Lock()
+    defer db.mu.Unlock()
+    stream, exists := db.streams[streamName]
+    trimmed := db.trimmed[streamName]
+    if category, found := strings.CutPrefix(streamName, categoryStreamPrefix); found {
+        stream = db.categories[category]
+        exists = len(stream) > 0
+        trimmed = 0
+    }
+    if !exists {
+        return nil, werrors.NewResourceNotFoundError("stream " + streamName + " not found")
+    }
+    retrievedEvents := make([]eventsourcing.RetrievedEvent, len(stream))
+    for i, rawEvent := range stream {
+        retrievedEvents[i] = eventsourcing.RetrievedEvent{RawEvent: rawEvent, AggregateVersion: trimmed + uint64(i)}
+    }
+    return retrievedEvents, nil
+}
+
+// SetStreamMaxCount drops all but the last maxCount events of the stream,
+// keeping the version of the remaining ones
+func (db *FakeDB) SetStreamMaxCount(_ context.Context, streamName string, maxCount uint64) werrors.WError {
+    db.mu.Lock()
+    defer db.mu.Unlock()
+    stream := db.streams[streamName]
+    if uint64(len(stream)) <= maxCount {
+        return nil
+    }
+    drop := uint64(len(stream)) - maxCount
+    db.streams[streamName] = stream[drop:]
+    db.trimmed[streamName] += drop
+    return nil
+}
+
+// SetStreamMaxAge records the max age of the stream, see StreamMaxAge.
+// The events are never dropped, SetStreamMaxCount with 0 drops them all.
+func (db *FakeDB) SetStreamMaxAge(_ context.Context, streamName string, maxAge time.Duration) werrors.WError {
+    db.mu.Lock()
+    defer db.mu.Unlock()
+    db.maxAges[streamName] = maxAge
+    return nil
+}
+
+// StreamMaxAge returns the max age set on the stream, 0 if none was set
+func (db *FakeDB) StreamMaxAge(streamName string) time.Duration {
+    db.mu.Lock()
+    defer db.mu.Unlock()
+    return db.maxAges[streamName]
+}
+
+// Stream returns the raw events appended to a stream, nil if it doesn't exist
+func (db *FakeDB) Stream(streamName string) [][]byte {
+    db.mu.Lock()
+    defer db.mu.Unlock()
+    return db.streams[streamName]
+}
+
+// StreamCount returns how many streams have been created
+func (db *FakeDB) StreamCount() int {
+    db.mu.Lock()
+    defer db.mu.Unlock()
+    return len(db.streams)
+}
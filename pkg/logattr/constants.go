@@ -22,6 +22,10 @@ func EventType(eventType string) slog.Attr {
     return slog.String("event_type", eventType)
 }
 
+func Reason(reason string) slog.Attr {
+    return slog.String("reason", reason)
+}
+
 func Error(err string) slog.Attr {
     return slog.String("error", err)
 }